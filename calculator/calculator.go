@@ -1,9 +1,5 @@
 package calculator
 
-import (
-	"errors"
-)
-
 func Add(a, b float64) float64 {
 	return a + b
 }
@@ -18,7 +14,7 @@ func Multiply(a, b float64) float64 {
 
 func Divide(a, b float64) (float64, error) {
 	if b == 0 {
-		return 0, errors.New("division by zero is not allowed")
+		return 0, ErrDivisionByZero
 	}
 	return a / b, nil
 }
@@ -1,6 +1,7 @@
 package calculator
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -88,3 +89,14 @@ func TestDivide(t *testing.T) {
 		}
 	}
 }
+
+func TestDivide_TypedError(t *testing.T) {
+	_, err := Divide(1, 0)
+	if !errors.Is(err, ErrDivisionByZero) {
+		t.Fatalf("Divide(1, 0) error = %v; want ErrDivisionByZero", err)
+	}
+	var ce *Error
+	if !errors.As(err, &ce) || ce.Kind != KindCalculation || ce.Field != "b" {
+		t.Fatalf("Divide(1, 0) error = %#v; want calculation_error on b", err)
+	}
+}
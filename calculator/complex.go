@@ -0,0 +1,151 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// Complex is a complex number in rectangular form.
+type Complex struct {
+	Re float64 `json:"re"`
+	Im float64 `json:"im"`
+}
+
+// Polar is a complex number in polar form; Theta is in radians in (-π, π].
+type Polar struct {
+	R     float64 `json:"r"`
+	Theta float64 `json:"theta"`
+}
+
+func fromBuiltin(c complex128) Complex { return Complex{Re: real(c), Im: imag(c)} }
+
+func (z Complex) builtin() complex128 { return complex(z.Re, z.Im) }
+
+func (z Complex) Add(w Complex) Complex { return Complex{z.Re + w.Re, z.Im + w.Im} }
+
+func (z Complex) Sub(w Complex) Complex { return Complex{z.Re - w.Re, z.Im - w.Im} }
+
+func (z Complex) Mul(w Complex) Complex { return fromBuiltin(z.builtin() * w.builtin()) }
+
+// Div returns z/w, or ErrDivisionByZero when w is zero.
+func (z Complex) Div(w Complex) (Complex, error) {
+	if w.Re == 0 && w.Im == 0 {
+		return Complex{}, ErrDivisionByZero
+	}
+	return fromBuiltin(z.builtin() / w.builtin()), nil
+}
+
+// Conj returns the complex conjugate of z.
+func (z Complex) Conj() Complex { return Complex{z.Re, -z.Im} }
+
+// Abs returns the modulus |z|.
+func (z Complex) Abs() float64 { return math.Hypot(z.Re, z.Im) }
+
+// Arg returns the argument of z in (-π, π].
+func (z Complex) Arg() float64 { return math.Atan2(z.Im, z.Re) }
+
+// Polar converts z to polar form.
+func (z Complex) Polar() Polar { return Polar{R: z.Abs(), Theta: z.Arg()} }
+
+// Rect converts p to rectangular form.
+func (p Polar) Rect() Complex { return fromBuiltin(cmplx.Rect(p.R, p.Theta)) }
+
+// Sqrt returns the principal square root of z.
+func (z Complex) Sqrt() Complex { return fromBuiltin(cmplx.Sqrt(z.builtin())) }
+
+// Exp returns e**z. Real arguments stay real even when e**z overflows.
+func (z Complex) Exp() Complex {
+	if z.Im == 0 {
+		return Complex{Re: math.Exp(z.Re), Im: z.Im}
+	}
+	return fromBuiltin(cmplx.Exp(z.builtin()))
+}
+
+// ErrLogOfZero is returned by Log for z = 0.
+var ErrLogOfZero = &Error{Kind: KindDomain, Op: "log", Field: "a", Msg: "logarithm of zero is undefined"}
+
+// Log returns the principal natural logarithm of z.
+func (z Complex) Log() (Complex, error) {
+	if z.Re == 0 && z.Im == 0 {
+		return Complex{}, ErrLogOfZero
+	}
+	return fromBuiltin(cmplx.Log(z.builtin())), nil
+}
+
+// String formats z as "a+bi", omitting a zero real part.
+func (z Complex) String() string {
+	im := strconv.FormatFloat(z.Im, 'g', -1, 64)
+	if z.Re == 0 && z.Im != 0 {
+		return im + "i"
+	}
+	re := strconv.FormatFloat(z.Re, 'g', -1, 64)
+	if z.Im == 0 && !math.Signbit(z.Im) {
+		return re
+	}
+	if !strings.HasPrefix(im, "-") {
+		im = "+" + im
+	}
+	return re + im + "i"
+}
+
+// ErrInvalidComplex is returned by ParseComplex for malformed input.
+var ErrInvalidComplex = errors.New("invalid complex number")
+
+// ParseComplex parses numbers such as "3+4i", "-2.5i", "i", "1e3-2j" or "7".
+// Whitespace is ignored.
+func ParseComplex(s string) (Complex, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return Complex{}, ErrInvalidComplex
+	}
+	last := s[len(s)-1]
+	if last != 'i' && last != 'j' {
+		re, err := parseComponent(s)
+		return Complex{Re: re}, err
+	}
+
+	body := s[:len(s)-1]
+	// The imaginary part starts at the last sign that is not an exponent sign.
+	split := 0
+	for i := len(body) - 1; i > 0; i-- {
+		if (body[i] == '+' || body[i] == '-') && body[i-1] != 'e' && body[i-1] != 'E' {
+			split = i
+			break
+		}
+	}
+	var (
+		z   Complex
+		err error
+	)
+	if split > 0 {
+		if z.Re, err = parseComponent(body[:split]); err != nil {
+			return Complex{}, err
+		}
+	}
+	switch im := body[split:]; im {
+	case "", "+":
+		z.Im = 1
+	case "-":
+		z.Im = -1
+	default:
+		if z.Im, err = parseComponent(im); err != nil {
+			return Complex{}, err
+		}
+	}
+	return z, nil
+}
+
+func parseComponent(s string) (float64, error) {
+	// Reject spellings ParseFloat accepts but that are not plain decimals.
+	if strings.ContainsAny(s, "nNxX_") {
+		return 0, ErrInvalidComplex
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, ErrInvalidComplex
+	}
+	return f, nil
+}
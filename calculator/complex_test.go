@@ -0,0 +1,105 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func closeTo(a, b float64) bool { return math.Abs(a-b) <= 1e-12*math.Max(1, math.Abs(b)) }
+
+func complexClose(z, w Complex) bool { return closeTo(z.Re, w.Re) && closeTo(z.Im, w.Im) }
+
+func TestComplexArithmetic(t *testing.T) {
+	z, w := Complex{3, 4}, Complex{1, -2}
+	if got := z.Add(w); got != (Complex{4, 2}) {
+		t.Errorf("Add = %v", got)
+	}
+	if got := z.Sub(w); got != (Complex{2, 6}) {
+		t.Errorf("Sub = %v", got)
+	}
+	if got := z.Mul(w); got != (Complex{11, -2}) {
+		t.Errorf("Mul = %v", got)
+	}
+	if got, err := z.Div(w); err != nil || !complexClose(got, Complex{-1, 2}) {
+		t.Errorf("Div = %v, %v", got, err)
+	}
+	if _, err := z.Div(Complex{}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Div by zero error = %v", err)
+	}
+	if got := z.Conj(); got != (Complex{3, -4}) {
+		t.Errorf("Conj = %v", got)
+	}
+	if got := z.Abs(); got != 5 {
+		t.Errorf("Abs = %v", got)
+	}
+	if got := (Complex{0, 1}).Arg(); got != math.Pi/2 {
+		t.Errorf("Arg = %v", got)
+	}
+}
+
+func TestComplexPolarRoundTrip(t *testing.T) {
+	for _, z := range []Complex{{3, 4}, {-1, 0}, {0, -2}, {-0.5, -0.25}} {
+		if got := z.Polar().Rect(); !complexClose(got, z) {
+			t.Errorf("Polar(%v).Rect() = %v", z, got)
+		}
+	}
+}
+
+func TestComplexFunctions(t *testing.T) {
+	if got := (Complex{-4, 0}).Sqrt(); !complexClose(got, Complex{0, 2}) {
+		t.Errorf("Sqrt(-4) = %v", got)
+	}
+	if got := (Complex{0, math.Pi}).Exp(); !complexClose(got, Complex{-1, 0}) {
+		t.Errorf("Exp(iπ) = %v", got)
+	}
+	if got, err := (Complex{-1, 0}).Log(); err != nil || !complexClose(got, Complex{0, math.Pi}) {
+		t.Errorf("Log(-1) = %v, %v", got, err)
+	}
+	if _, err := (Complex{}).Log(); err != ErrLogOfZero {
+		t.Errorf("Log(0) error = %v", err)
+	}
+}
+
+func TestParseComplex(t *testing.T) {
+	ok := []struct {
+		in   string
+		want Complex
+	}{
+		{"3+4i", Complex{3, 4}},
+		{"3 - 4i", Complex{3, -4}},
+		{"-2.5i", Complex{0, -2.5}},
+		{"i", Complex{0, 1}},
+		{"-i", Complex{0, -1}},
+		{"1e3-2e-1j", Complex{1000, -0.2}},
+		{"7", Complex{7, 0}},
+		{"-1e-3", Complex{-0.001, 0}},
+	}
+	for _, tc := range ok {
+		got, err := ParseComplex(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseComplex(%q) = %v, %v; want %v", tc.in, got, err, tc.want)
+		}
+	}
+	for _, in := range []string{"", "3+", "3+4", "4ii", "inf", "NaNi", "0x1p3", "3+4k", "1+2i+3i"} {
+		if got, err := ParseComplex(in); err == nil {
+			t.Errorf("ParseComplex(%q) = %v; want error", in, got)
+		}
+	}
+}
+
+func TestComplexString(t *testing.T) {
+	cases := map[Complex]string{
+		{3, 4}:    "3+4i",
+		{3, -4}:   "3-4i",
+		{0, 2}:    "2i",
+		{1.5, 0}:  "1.5",
+		{0, 0}:    "0",
+		{-1, 0.5}: "-1+0.5i",
+	}
+	for z, want := range cases {
+		if got := z.String(); got != want {
+			t.Errorf("%#v.String() = %q; want %q", z, got, want)
+		}
+	}
+}
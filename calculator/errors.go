@@ -0,0 +1,29 @@
+package calculator
+
+// Kind classifies a calculation error so callers can map it to a response
+// without matching on message text.
+type Kind string
+
+const (
+	// KindCalculation covers operations that are undefined for the given operands.
+	KindCalculation Kind = "calculation_error"
+	// KindDomain marks operands outside a function's domain, such as sqrt(-1).
+	KindDomain Kind = "domain_error"
+	// KindOverflow marks results too large to represent.
+	KindOverflow Kind = "overflow"
+	// KindDimension marks operands whose shapes do not fit the operation.
+	KindDimension Kind = "dimension_mismatch"
+)
+
+// Error is returned by calculator functions.
+type Error struct {
+	Kind  Kind
+	Op    string // operation that failed
+	Field string // offending operand ("a", "b"), if any
+	Msg   string
+}
+
+func (e *Error) Error() string { return e.Msg }
+
+// ErrDivisionByZero is returned by Divide when the divisor is zero.
+var ErrDivisionByZero = &Error{Kind: KindCalculation, Op: "divide", Field: "b", Msg: "division by zero is not allowed"}
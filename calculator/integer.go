@@ -0,0 +1,231 @@
+package calculator
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// IntType is a fixed-width two's complement integer type such as int32 or
+// uint8.
+type IntType struct {
+	Bits   uint // 8, 16, 32 or 64
+	Signed bool
+}
+
+// ParseIntType parses names like "int64" or "uint8".
+func ParseIntType(name string) (IntType, bool) {
+	t := IntType{Signed: true}
+	rest, ok := strings.CutPrefix(name, "int")
+	if !ok {
+		if rest, ok = strings.CutPrefix(name, "uint"); !ok {
+			return IntType{}, false
+		}
+		t.Signed = false
+	}
+	switch rest {
+	case "8", "16", "32", "64":
+		n, _ := strconv.Atoi(rest)
+		t.Bits = uint(n)
+		return t, true
+	}
+	return IntType{}, false
+}
+
+func (t IntType) String() string {
+	if t.Signed {
+		return "int" + strconv.Itoa(int(t.Bits))
+	}
+	return "uint" + strconv.Itoa(int(t.Bits))
+}
+
+func (t IntType) mask() uint64 { return ^uint64(0) >> (64 - t.Bits) }
+
+// bounds returns the smallest and largest value of t.
+func (t IntType) bounds() (lo, hi *big.Int) {
+	if !t.Signed {
+		return new(big.Int), new(big.Int).SetUint64(t.mask())
+	}
+	hi = new(big.Int).Lsh(big.NewInt(1), t.Bits-1)
+	lo = new(big.Int).Neg(hi)
+	return lo, hi.Sub(hi, big.NewInt(1))
+}
+
+// Int is a value of an IntType, stored as its bit pattern.
+type Int struct {
+	Type IntType
+	bits uint64
+}
+
+// fromBig converts v to t, wrapping modulo 2^Bits. It reports whether v was
+// in range.
+func (t IntType) fromBig(v *big.Int) (Int, bool) {
+	lo, hi := t.bounds()
+	inRange := v.Cmp(lo) >= 0 && v.Cmp(hi) <= 0
+	m := new(big.Int).Lsh(big.NewInt(1), t.Bits)
+	w := new(big.Int).Mod(v, m) // Euclidean, so always non-negative
+	return Int{Type: t, bits: w.Uint64()}, inRange
+}
+
+// Big returns x's numeric value.
+func (x Int) Big() *big.Int {
+	if x.Type.Signed {
+		return big.NewInt(x.Int64())
+	}
+	return new(big.Int).SetUint64(x.bits)
+}
+
+// Int64 returns x's value interpreted as signed when its type is signed.
+func (x Int) Int64() int64 {
+	if x.Type.Signed {
+		shift := 64 - x.Type.Bits
+		return int64(x.bits<<shift) >> shift
+	}
+	return int64(x.bits)
+}
+
+// Errors returned by IntType.Parse.
+var (
+	ErrInvalidInt = errors.New("invalid integer literal")
+	ErrIntRange   = errors.New("value is out of range for the integer type")
+)
+
+// Parse reads a decimal, hex (0xFF), binary (0b1010) or octal (0o17) literal
+// with an optional sign. Unsigned hex, binary and octal literals are bit
+// patterns, so 0xFF is -1 as an int8. Values that do not fit wrap when wrap is
+// set and fail with ErrIntRange otherwise.
+func (t IntType) Parse(s string, wrap bool) (Int, error) {
+	s = strings.TrimSpace(s)
+	neg := false
+	signed := false
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		neg, signed = s[0] == '-', true
+		s = s[1:]
+	}
+	base := 10
+	if len(s) > 2 && s[0] == '0' {
+		switch s[1] {
+		case 'x', 'X':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
+		}
+		if base != 10 {
+			s = s[2:]
+		}
+	}
+	if s == "" || strings.HasPrefix(s, "_") || strings.HasSuffix(s, "_") || strings.Contains(s, "__") {
+		return Int{}, ErrInvalidInt
+	}
+	v, ok := new(big.Int).SetString(strings.ReplaceAll(s, "_", ""), base)
+	if !ok {
+		return Int{}, ErrInvalidInt
+	}
+	if neg {
+		v.Neg(v)
+	}
+
+	if base != 10 && !signed && v.BitLen() <= int(t.Bits) {
+		return Int{Type: t, bits: v.Uint64()}, nil
+	}
+	x, inRange := t.fromBig(v)
+	if !inRange && !wrap {
+		return Int{}, ErrIntRange
+	}
+	return x, nil
+}
+
+// Format writes x in base 10 as a signed or unsigned number, or in base 2, 8
+// or 16 as its bit pattern with a 0b, 0o or 0x prefix.
+func (x Int) Format(base int) string {
+	switch base {
+	case 2:
+		return "0b" + strconv.FormatUint(x.bits, 2)
+	case 8:
+		return "0o" + strconv.FormatUint(x.bits, 8)
+	case 16:
+		return "0x" + strconv.FormatUint(x.bits, 16)
+	}
+	return x.Big().String()
+}
+
+func (x Int) String() string { return x.Format(10) }
+
+// IntArity returns the number of operands an integer op takes, or 0 for
+// unknown ops.
+func IntArity(op string) int {
+	switch op {
+	case "not":
+		return 1
+	case "add", "subtract", "multiply", "divide", "mod", "and", "or", "xor", "shl", "shr", "rotl", "rotr":
+		return 2
+	}
+	return 0
+}
+
+// IntOp applies op to operands of the same type. Arithmetic that leaves the
+// type's range wraps (two's complement) when wrap is set and fails with a
+// KindOverflow error otherwise. For not, b is ignored. Shift counts must be in
+// [0, Bits); rotate counts are taken modulo Bits.
+func IntOp(op string, a, b Int, wrap bool) (Int, error) {
+	t := a.Type
+	if IntArity(op) == 2 && b.Type != t {
+		return Int{}, &Error{Kind: KindDomain, Op: op, Field: "b", Msg: "operands must have the same integer type"}
+	}
+
+	var v *big.Int
+	switch op {
+	case "and":
+		return Int{t, a.bits & b.bits}, nil
+	case "or":
+		return Int{t, a.bits | b.bits}, nil
+	case "xor":
+		return Int{t, a.bits ^ b.bits}, nil
+	case "not":
+		return Int{t, ^a.bits & t.mask()}, nil
+	case "rotl", "rotr":
+		n := int(new(big.Int).Mod(b.Big(), big.NewInt(int64(t.Bits))).Int64())
+		if op == "rotr" {
+			n = (int(t.Bits) - n) % int(t.Bits)
+		}
+		return Int{t, (a.bits<<n | a.bits>>(int(t.Bits)-n)) & t.mask()}, nil
+	case "shl", "shr":
+		n := b.Big()
+		if n.Sign() < 0 || n.Cmp(big.NewInt(int64(t.Bits))) >= 0 {
+			return Int{}, &Error{Kind: KindDomain, Op: op, Field: "b", Msg: "shift count must be between 0 and the bit width minus one"}
+		}
+		if op == "shl" {
+			v = new(big.Int).Lsh(a.Big(), uint(n.Uint64()))
+		} else {
+			// Arithmetic shift: Rsh floors, which keeps the sign of signed values.
+			v = new(big.Int).Rsh(a.Big(), uint(n.Uint64()))
+		}
+	case "add":
+		v = new(big.Int).Add(a.Big(), b.Big())
+	case "subtract":
+		v = new(big.Int).Sub(a.Big(), b.Big())
+	case "multiply":
+		v = new(big.Int).Mul(a.Big(), b.Big())
+	case "divide", "mod":
+		if b.bits == 0 {
+			return Int{}, &Error{Kind: KindCalculation, Op: op, Field: "b", Msg: ErrDivisionByZero.Msg}
+		}
+		// Truncated division, as in Go and C.
+		if op == "divide" {
+			v = new(big.Int).Quo(a.Big(), b.Big())
+		} else {
+			v = new(big.Int).Rem(a.Big(), b.Big())
+		}
+	default:
+		return Int{}, &Error{Kind: KindCalculation, Op: op, Field: "op", Msg: "unknown integer operation"}
+	}
+
+	x, inRange := t.fromBig(v)
+	if !inRange && !wrap {
+		return Int{}, &Error{Kind: KindOverflow, Op: op, Msg: "integer overflow"}
+	}
+	return x, nil
+}
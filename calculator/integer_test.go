@@ -0,0 +1,119 @@
+package calculator
+
+import (
+	"errors"
+	"testing"
+)
+
+func mustType(t *testing.T, name string) IntType {
+	t.Helper()
+	it, ok := ParseIntType(name)
+	if !ok {
+		t.Fatalf("ParseIntType(%q) failed", name)
+	}
+	return it
+}
+
+func TestParseIntType(t *testing.T) {
+	for _, name := range []string{"int8", "uint16", "int32", "uint64"} {
+		if it := mustType(t, name); it.String() != name {
+			t.Errorf("String() = %q; want %q", it.String(), name)
+		}
+	}
+	for _, name := range []string{"int", "int12", "uint128", "float64", ""} {
+		if _, ok := ParseIntType(name); ok {
+			t.Errorf("ParseIntType(%q) should fail", name)
+		}
+	}
+}
+
+func TestIntParse(t *testing.T) {
+	i8, u8, u64 := mustType(t, "int8"), mustType(t, "uint8"), mustType(t, "uint64")
+	cases := []struct {
+		typ  IntType
+		in   string
+		wrap bool
+		want string
+		err  error
+	}{
+		{i8, "0xFF", false, "-1", nil}, // hex literals are bit patterns
+		{i8, "0b1000_0000", false, "-128", nil},
+		{i8, "-0x80", false, "-128", nil},
+		{i8, "127", false, "127", nil},
+		{i8, "128", false, "", ErrIntRange},
+		{i8, "128", true, "-128", nil},
+		{i8, "0x1FF", false, "", ErrIntRange},
+		{u8, "-1", true, "255", nil},
+		{u8, "0o377", false, "255", nil},
+		{u64, "0xFFFFFFFFFFFFFFFF", false, "18446744073709551615", nil},
+		{u8, "0x", false, "", ErrInvalidInt},
+		{u8, "12a", false, "", ErrInvalidInt},
+		{u8, "1__0", false, "", ErrInvalidInt},
+	}
+	for _, tc := range cases {
+		got, err := tc.typ.Parse(tc.in, tc.wrap)
+		if !errors.Is(err, tc.err) || (err == nil && got.String() != tc.want) {
+			t.Errorf("%s.Parse(%q, wrap=%v) = %v, %v; want %s, %v", tc.typ, tc.in, tc.wrap, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestIntFormat(t *testing.T) {
+	x, _ := mustType(t, "int16").Parse("-2", false)
+	for base, want := range map[int]string{10: "-2", 16: "0xfffe", 8: "0o177776", 2: "0b1111111111111110"} {
+		if got := x.Format(base); got != want {
+			t.Errorf("Format(%d) = %q; want %q", base, got, want)
+		}
+	}
+}
+
+func TestIntOp(t *testing.T) {
+	i8, u8, i32 := mustType(t, "int8"), mustType(t, "uint8"), mustType(t, "int32")
+	p := func(typ IntType, s string) Int {
+		x, err := typ.Parse(s, true)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", s, err)
+		}
+		return x
+	}
+	cases := []struct {
+		op   string
+		a, b Int
+		wrap bool
+		want string
+		kind Kind
+	}{
+		{"and", p(u8, "0xF0"), p(u8, "0x3C"), false, "48", ""},
+		{"or", p(u8, "0xF0"), p(u8, "0x0F"), false, "255", ""},
+		{"xor", p(u8, "0xFF"), p(u8, "0x0F"), false, "240", ""},
+		{"not", p(u8, "0x0F"), Int{Type: u8}, false, "240", ""},
+		{"not", p(i8, "0"), Int{Type: i8}, false, "-1", ""},
+		{"shl", p(u8, "1"), p(u8, "7"), false, "128", ""},
+		{"shl", p(u8, "2"), p(u8, "7"), false, "", KindOverflow},
+		{"shl", p(u8, "2"), p(u8, "7"), true, "0", ""},
+		{"shl", p(u8, "1"), p(u8, "8"), true, "", KindDomain},
+		{"shr", p(i8, "-8"), p(i8, "1"), false, "-4", ""},
+		{"rotl", p(u8, "0x81"), p(u8, "1"), false, "3", ""},
+		{"rotr", p(u8, "0x81"), p(u8, "1"), false, "192", ""},
+		{"rotl", p(u8, "0x81"), p(u8, "9"), false, "3", ""},
+		{"add", p(i8, "127"), p(i8, "1"), true, "-128", ""},
+		{"add", p(i8, "127"), p(i8, "1"), false, "", KindOverflow},
+		{"subtract", p(u8, "0"), p(u8, "1"), true, "255", ""},
+		{"multiply", p(i32, "65536"), p(i32, "65536"), true, "0", ""},
+		{"divide", p(i8, "-128"), p(i8, "-1"), false, "", KindOverflow},
+		{"divide", p(i8, "-7"), p(i8, "2"), false, "-3", ""},
+		{"mod", p(i8, "-7"), p(i8, "2"), false, "-1", ""},
+		{"divide", p(i8, "1"), p(i8, "0"), true, "", KindCalculation},
+		{"add", p(i8, "1"), p(u8, "1"), true, "", KindDomain},
+	}
+	for _, tc := range cases {
+		got, err := IntOp(tc.op, tc.a, tc.b, tc.wrap)
+		var ce *Error
+		switch {
+		case tc.kind != "" && (!errors.As(err, &ce) || ce.Kind != tc.kind):
+			t.Errorf("%s(%v, %v) error = %v; want %s", tc.op, tc.a, tc.b, err, tc.kind)
+		case tc.kind == "" && (err != nil || got.String() != tc.want):
+			t.Errorf("%s(%v, %v) = %v, %v; want %s", tc.op, tc.a, tc.b, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package calculator
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// Interval is the closed set of reals [Lo, Hi]. Operations round their bounds
+// outward, so the exact result for any operands drawn from the inputs lies
+// inside the returned interval. An infinite bound marks an unbounded side,
+// which overflow and extended division produce.
+type Interval struct {
+	Lo, Hi float64
+}
+
+// ErrDivisorContainsZero is returned by Interval.Div when the divisor contains
+// zero but is not [0, 0]; DivExtended handles such divisors.
+var ErrDivisorContainsZero = &Error{Kind: KindCalculation, Op: "divide", Field: "b", Msg: "divisor interval contains zero"}
+
+// Point returns the degenerate interval [x, x].
+func Point(x float64) Interval { return Interval{x, x} }
+
+// Valid reports whether i is a non-empty interval: no NaN bound, Lo <= Hi,
+// and neither side unbounded in the wrong direction.
+func (i Interval) Valid() bool {
+	return i.Lo <= i.Hi && !math.IsInf(i.Lo, 1) && !math.IsInf(i.Hi, -1)
+}
+
+// Contains reports whether x lies in i.
+func (i Interval) Contains(x float64) bool { return i.Lo <= x && x <= i.Hi }
+
+// Bounded reports whether both bounds of i are finite.
+func (i Interval) Bounded() bool { return !math.IsInf(i.Lo, 0) && !math.IsInf(i.Hi, 0) }
+
+func (i Interval) Add(j Interval) Interval {
+	return Interval{addRounded(i.Lo, j.Lo, -1), addRounded(i.Hi, j.Hi, 1)}
+}
+
+func (i Interval) Sub(j Interval) Interval {
+	return Interval{addRounded(i.Lo, -j.Hi, -1), addRounded(i.Hi, -j.Lo, 1)}
+}
+
+func (i Interval) Mul(j Interval) Interval {
+	return hull(mulRounded, i, j)
+}
+
+// Div returns i/j. It returns ErrDivisionByZero when j is [0, 0] and
+// ErrDivisorContainsZero when j otherwise contains zero.
+func (i Interval) Div(j Interval) (Interval, error) {
+	switch {
+	case j.Lo == 0 && j.Hi == 0:
+		return Interval{}, ErrDivisionByZero
+	case j.Contains(0):
+		return Interval{}, ErrDivisorContainsZero
+	}
+	return hull(divRounded, i, j), nil
+}
+
+// DivExtended returns i/j as one interval or, when j straddles zero, as the
+// two disjoint unbounded intervals of extended interval arithmetic, lower one
+// first. Only a divisor of [0, 0], whose quotient is empty, is an error.
+func (i Interval) DivExtended(j Interval) ([]Interval, error) {
+	inf := math.Inf(1)
+	switch {
+	case j.Lo == 0 && j.Hi == 0:
+		return nil, ErrDivisionByZero
+	case !j.Contains(0):
+		return []Interval{hull(divRounded, i, j)}, nil
+	case i.Lo == 0 && i.Hi == 0:
+		return []Interval{{0, 0}}, nil
+	case i.Contains(0):
+		return []Interval{{-inf, inf}}, nil
+	}
+
+	// Every quotient has the sign of i divided by that of the divisor, and
+	// grows without bound as the divisor approaches zero. The bound nearest
+	// zero comes from the numerator bound nearest zero.
+	x := i.Hi
+	if i.Lo > 0 {
+		x = i.Lo
+	}
+	var below, above []Interval
+	if j.Lo < 0 {
+		if q := (Interval{divRounded(x, j.Lo, -1), divRounded(x, j.Lo, 1)}); x > 0 {
+			below = []Interval{{-inf, q.Hi}}
+		} else {
+			above = []Interval{{q.Lo, inf}}
+		}
+	}
+	if j.Hi > 0 {
+		if q := (Interval{divRounded(x, j.Hi, -1), divRounded(x, j.Hi, 1)}); x > 0 {
+			above = []Interval{{q.Lo, inf}}
+		} else {
+			below = []Interval{{-inf, q.Hi}}
+		}
+	}
+	return append(below, above...), nil
+}
+
+// Hull returns the smallest interval containing every interval in parts.
+func Hull(parts []Interval) Interval {
+	h := Interval{math.Inf(1), math.Inf(-1)}
+	for _, p := range parts {
+		h.Lo, h.Hi = math.Min(h.Lo, p.Lo), math.Max(h.Hi, p.Hi)
+	}
+	return h
+}
+
+// hull applies op to every pair of bounds, rounding down for the lower bound
+// of the result and up for the upper one. NaN products of infinite bounds,
+// such as ∞/∞, carry no information and are skipped; the other pairs still
+// bound the result.
+func hull(op func(x, y, dir float64) float64, i, j Interval) Interval {
+	h := Interval{math.Inf(1), math.Inf(-1)}
+	for _, x := range []float64{i.Lo, i.Hi} {
+		for _, y := range []float64{j.Lo, j.Hi} {
+			if lo := op(x, y, -1); !math.IsNaN(lo) {
+				h.Lo = math.Min(h.Lo, lo)
+			}
+			if hi := op(x, y, 1); !math.IsNaN(hi) {
+				h.Hi = math.Max(h.Hi, hi)
+			}
+		}
+	}
+	return h
+}
+
+// directed returns the rounded result r, or its neighbour in direction dir
+// when the exact result lies that way: err is the exact result minus r, or
+// NaN when its sign is unknown.
+func directed(r, err, dir float64) float64 {
+	if math.IsNaN(err) || err != 0 && (err > 0) == (dir > 0) {
+		return math.Nextafter(r, math.Inf(int(dir)))
+	}
+	return r
+}
+
+// overflowed reports whether r is infinite although x and y are finite, in
+// which case the exact result is finite and lies back towards zero.
+func overflowed(r, x, y float64) bool {
+	return math.IsInf(r, 0) && !math.IsInf(x, 0) && !math.IsInf(y, 0)
+}
+
+// addRounded returns x+y rounded towards dir. The rounding error of a sum is
+// recovered exactly by Knuth's TwoSum.
+func addRounded(x, y, dir float64) float64 {
+	s := x + y
+	switch {
+	case overflowed(s, x, y):
+		return directed(s, -s, dir)
+	case math.IsInf(s, 0):
+		return s
+	}
+	bv := s - x
+	err := (x - (s - bv)) + (y - bv)
+	return directed(s, err, dir)
+}
+
+// mulRounded returns x*y rounded towards dir, taking 0·∞ as 0. The rounding
+// error of a product is exact from a fused multiply-add unless the product
+// is subnormal, where it may be lost and the product is stepped regardless.
+func mulRounded(x, y, dir float64) float64 {
+	if x == 0 || y == 0 {
+		return 0
+	}
+	p := x * y
+	switch {
+	case overflowed(p, x, y):
+		return directed(p, -p, dir)
+	case math.IsInf(p, 0):
+		return p
+	case math.Abs(p) < minNormal:
+		return directed(p, math.NaN(), dir)
+	}
+	return directed(p, math.FMA(x, y, -p), dir)
+}
+
+// divRounded returns x/y rounded towards dir for y != 0. The remainder
+// x − q·y is exact from a fused multiply-add, and the exact quotient exceeds
+// q when the remainder has the sign of y.
+func divRounded(x, y, dir float64) float64 {
+	q := x / y
+	switch {
+	case x == 0 || math.IsInf(y, 0) && !math.IsInf(x, 0):
+		return q
+	case overflowed(q, x, y):
+		return directed(q, -q, dir)
+	case math.IsInf(q, 0) || math.IsNaN(q):
+		return q
+	case math.Abs(q) < minNormal:
+		return directed(q, math.NaN(), dir)
+	}
+	rem := math.FMA(-q, y, x)
+	return directed(q, rem*math.Copysign(1, y), dir)
+}
+
+const minNormal = 0x1p-1022
+
+// MarshalJSON encodes i as [lo, hi], with null for an unbounded side.
+func (i Interval) MarshalJSON() ([]byte, error) {
+	bound := func(x float64) string {
+		if math.IsInf(x, 0) {
+			return "null"
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	return []byte("[" + bound(i.Lo) + "," + bound(i.Hi) + "]"), nil
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON.
+func (i *Interval) UnmarshalJSON(b []byte) error {
+	var bounds []*float64
+	if err := json.Unmarshal(b, &bounds); err != nil {
+		return err
+	}
+	if len(bounds) != 2 {
+		return errors.New("calculator: an interval must be [lo, hi]")
+	}
+	i.Lo, i.Hi = math.Inf(-1), math.Inf(1)
+	if bounds[0] != nil {
+		i.Lo = *bounds[0]
+	}
+	if bounds[1] != nil {
+		i.Hi = *bounds[1]
+	}
+	return nil
+}
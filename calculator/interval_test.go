@@ -0,0 +1,150 @@
+package calculator
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// exact returns op applied to x and y without rounding.
+func exact(op string, x, y float64) *big.Float {
+	a, b := new(big.Float).SetPrec(2000).SetFloat64(x), new(big.Float).SetPrec(2000).SetFloat64(y)
+	switch op {
+	case "add":
+		return a.Add(a, b)
+	case "sub":
+		return a.Sub(a, b)
+	case "mul":
+		return a.Mul(a, b)
+	}
+	return a.Quo(a, b)
+}
+
+func encloses(i Interval, v *big.Float) bool {
+	return big.NewFloat(i.Lo).Cmp(v) <= 0 && v.Cmp(big.NewFloat(i.Hi)) <= 0
+}
+
+func TestIntervalArithmetic(t *testing.T) {
+	a, b := Interval{1, 2}, Interval{-3, 4}
+	if got := a.Add(b); got != (Interval{-2, 6}) {
+		t.Errorf("Add = %v", got)
+	}
+	if got := a.Sub(b); got != (Interval{-3, 5}) {
+		t.Errorf("Sub = %v", got)
+	}
+	if got := a.Mul(b); got != (Interval{-6, 8}) {
+		t.Errorf("Mul = %v", got)
+	}
+	if got, err := b.Div(Interval{2, 4}); err != nil || got != (Interval{-1.5, 2}) {
+		t.Errorf("Div = %v, %v", got, err)
+	}
+}
+
+func TestIntervalOutwardRounding(t *testing.T) {
+	ops := map[string]func(x, y Interval) Interval{
+		"add": Interval.Add,
+		"sub": Interval.Sub,
+		"mul": Interval.Mul,
+		"div": func(x, y Interval) Interval { q, _ := x.Div(y); return q },
+	}
+	pairs := [][2]float64{{0.1, 0.2}, {1, 3}, {1e308, 1e308}, {-1e-310, 3}, {math.Pi, -math.E}, {2, 0.5}}
+	for name, op := range ops {
+		for _, p := range pairs {
+			got := op(Point(p[0]), Point(p[1]))
+			want := exact(name, p[0], p[1])
+			if !encloses(got, want) {
+				t.Errorf("%s(%v, %v) = %v does not contain %v", name, p[0], p[1], got, want)
+			}
+			// Rounding outward costs at most one ulp on each side.
+			if up := math.Inf(1); got.Bounded() && math.Nextafter(math.Nextafter(got.Lo, up), up) < got.Hi {
+				t.Errorf("%s(%v, %v) = %v is wider than two ulps", name, p[0], p[1], got)
+			}
+		}
+	}
+	// Exact results stay points.
+	if got := Point(1).Add(Point(2)); got != Point(3) {
+		t.Errorf("1+2 = %v", got)
+	}
+	if got := Point(0.1).Add(Point(0.2)); got.Lo >= got.Hi {
+		t.Errorf("0.1+0.2 = %v; want a non-degenerate interval", got)
+	}
+	if got := Point(math.MaxFloat64).Add(Point(math.MaxFloat64)); got != (Interval{math.MaxFloat64, math.Inf(1)}) {
+		t.Errorf("overflow = %v", got)
+	}
+}
+
+func TestIntervalDivisionByZero(t *testing.T) {
+	inf := math.Inf(1)
+	if _, err := (Interval{1, 2}).Div(Interval{-1, 1}); !errors.Is(err, ErrDivisorContainsZero) {
+		t.Errorf("Div error = %v", err)
+	}
+	if _, err := (Interval{1, 2}).Div(Interval{}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Div by [0, 0] error = %v", err)
+	}
+	if _, err := (Interval{1, 2}).DivExtended(Interval{}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("DivExtended by [0, 0] error = %v", err)
+	}
+
+	cases := []struct {
+		a, b Interval
+		want []Interval
+	}{
+		{Interval{1, 2}, Interval{2, 4}, []Interval{{0.25, 1}}},
+		{Interval{1, 2}, Interval{-1, 4}, []Interval{{-inf, -1}, {0.25, inf}}},
+		{Interval{-2, -1}, Interval{-1, 4}, []Interval{{-inf, -0.25}, {1, inf}}},
+		{Interval{1, 2}, Interval{0, 4}, []Interval{{0.25, inf}}},
+		{Interval{1, 2}, Interval{-4, 0}, []Interval{{-inf, -0.25}}},
+		{Interval{-2, -1}, Interval{0, 4}, []Interval{{-inf, -0.25}}},
+		{Interval{-1, 2}, Interval{-1, 4}, []Interval{{-inf, inf}}},
+		{Interval{0, 0}, Interval{-1, 4}, []Interval{{0, 0}}},
+	}
+	for _, tc := range cases {
+		got, err := tc.a.DivExtended(tc.b)
+		if err != nil || len(got) != len(tc.want) {
+			t.Errorf("%v / %v = %v, %v; want %v", tc.a, tc.b, got, err, tc.want)
+			continue
+		}
+		for k := range got {
+			if got[k] != tc.want[k] {
+				t.Errorf("%v / %v = %v; want %v", tc.a, tc.b, got, tc.want)
+			}
+		}
+	}
+	if got := Hull([]Interval{{-inf, -1}, {0.25, inf}}); got != (Interval{-inf, inf}) {
+		t.Errorf("Hull = %v", got)
+	}
+}
+
+func TestIntervalUnbounded(t *testing.T) {
+	inf := math.Inf(1)
+	if got := (Interval{0.25, inf}).Mul(Interval{0, 2}); got != (Interval{0, inf}) {
+		t.Errorf("[0.25, ∞]·[0, 2] = %v", got)
+	}
+	if got, err := (Interval{1, inf}).Div(Interval{1, inf}); err != nil || got != (Interval{0, inf}) {
+		t.Errorf("[1, ∞]/[1, ∞] = %v, %v", got, err)
+	}
+	if got := (Interval{-inf, 1}).Sub(Interval{0, inf}); got != (Interval{-inf, 1}) {
+		t.Errorf("[-∞, 1]-[0, ∞] = %v", got)
+	}
+}
+
+func TestIntervalJSON(t *testing.T) {
+	b, err := json.Marshal(Interval{-1.5, math.Inf(1)})
+	if err != nil || string(b) != "[-1.5,null]" {
+		t.Fatalf("Marshal = %s, %v", b, err)
+	}
+	var i Interval
+	if err := json.Unmarshal(b, &i); err != nil || i != (Interval{-1.5, math.Inf(1)}) {
+		t.Errorf("Unmarshal = %v, %v", i, err)
+	}
+	if err := json.Unmarshal([]byte("[1]"), &i); err == nil {
+		t.Error("Unmarshal of one bound succeeded")
+	}
+	for _, bad := range []Interval{{2, 1}, {math.NaN(), 1}, {math.Inf(1), math.Inf(1)}} {
+		if bad.Valid() {
+			t.Errorf("%v is valid", bad)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package calculator
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// OperandType is the kind of value an operand accepts.
+type OperandType string
+
+const (
+	// TypeNumber accepts any finite float64.
+	TypeNumber OperandType = "number"
+	// TypeInteger accepts finite float64 values without a fractional part.
+	TypeInteger OperandType = "integer"
+)
+
+// Operation is a real-valued operation of one or two operands, named a and b.
+type Operation struct {
+	// Name is the canonical name, which is also the REST route /v1/{Name}.
+	Name string
+	// Aliases are alternative spellings, such as "+" or "x", accepted
+	// wherever the name is.
+	Aliases []string
+	// Operands lists the type of a and, for binary operations, b; its
+	// length is the arity.
+	Operands []OperandType
+	// Doc is a one-line description.
+	Doc string
+	// Commutative operations give the same result for swapped operands.
+	Commutative bool
+	// Fn computes the result. Unary operations receive b = 0.
+	Fn func(a, b float64) (float64, error)
+	// Partials returns ∂Fn/∂a and ∂Fn/∂b at (a, b) for first-order
+	// uncertainty propagation. Operations without it reject measured
+	// operands.
+	Partials func(a, b float64) (da, db float64)
+
+	id uint64
+}
+
+// Arity returns the number of operands.
+func (op Operation) Arity() int { return len(op.Operands) }
+
+// ID identifies the registration of op. An operation replaced under the same
+// name gets a new ID, so results cached by ID do not outlive it.
+func (op Operation) ID() uint64 { return op.id }
+
+// lastID numbers registrations across all registries.
+var lastID atomic.Uint64
+
+// Registry is a set of operations looked up by name or alias. It is safe for
+// concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	ops   []Operation
+	index map[string]int // lower-cased name or alias → ops index
+}
+
+// validName is the form of operation names, which must work as a path
+// segment and a query value.
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reservedNames are the fixed routes of the REST API. They take precedence
+// over /v1/{Name}, so an operation or alias of that name could never be
+// called there.
+var reservedNames = map[string]bool{
+	"calculate": true, "complex": true, "convert": true, "dates": true, "evaluate": true,
+	"finance": true, "health": true, "history": true, "integrate": true, "interval": true,
+	"matrix": true, "metrics": true, "openapi.json": true, "operations": true, "scripts": true,
+	"solve": true, "stats": true, "sum": true, "symbolic": true, "units": true,
+}
+
+// NewRegistry returns a registry holding the built-in operations add,
+// subtract, multiply and divide.
+func NewRegistry() *Registry {
+	r := &Registry{index: map[string]int{}}
+	for _, op := range builtinOperations {
+		if err := r.Register(op); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds op. It fails when op is incomplete or its name or an alias
+// is already taken; names and aliases are matched without regard to case.
+func (r *Registry) Register(op Operation) error {
+	return r.Replace(nil, op)
+}
+
+// Replace removes the operations named in remove and registers add in one
+// step: lookups see either the old set or the new one, and on error nothing
+// changes. Built-in operations cannot be removed.
+func (r *Registry) Replace(remove []string, add ...Operation) error {
+	for _, name := range remove {
+		if slices.ContainsFunc(builtinOperations, func(op Operation) bool { return op.Name == name }) {
+			return fmt.Errorf("operation %q: built-in operations cannot be removed", name)
+		}
+	}
+	for _, op := range add {
+		if err := op.validate(); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ops := make([]Operation, 0, len(r.ops)+len(add))
+	for _, op := range r.ops {
+		if !slices.Contains(remove, op.Name) {
+			ops = append(ops, op)
+		}
+	}
+	for _, op := range add {
+		op.Aliases = slices.Clone(op.Aliases)
+		op.Operands = slices.Clone(op.Operands)
+		op.id = lastID.Add(1)
+		ops = append(ops, op)
+	}
+	index := make(map[string]int, len(r.index)+len(add))
+	for i, op := range ops {
+		for _, k := range append([]string{op.Name}, op.Aliases...) {
+			k = strings.ToLower(k)
+			if _, taken := index[k]; taken {
+				return fmt.Errorf("operation %q: %q is already registered", op.Name, k)
+			}
+			index[k] = i
+		}
+	}
+	r.ops, r.index = ops, index
+	return nil
+}
+
+func (op Operation) validate() error {
+	switch {
+	case !validName.MatchString(op.Name):
+		return fmt.Errorf("operation %q: name must be lower-case letters, digits and underscores", op.Name)
+	case reservedNames[op.Name]:
+		return fmt.Errorf("operation %q: name is reserved for a route of the API", op.Name)
+	case op.Arity() != 1 && op.Arity() != 2:
+		return fmt.Errorf("operation %q: arity must be 1 or 2", op.Name)
+	case op.Fn == nil:
+		return fmt.Errorf("operation %q: implementation is required", op.Name)
+	}
+	for _, t := range op.Operands {
+		if t != TypeNumber && t != TypeInteger {
+			return fmt.Errorf("operation %q: unknown operand type %q", op.Name, t)
+		}
+	}
+	for _, alias := range op.Aliases {
+		switch {
+		case strings.TrimSpace(alias) == "":
+			return fmt.Errorf("operation %q: aliases must not be blank", op.Name)
+		case reservedNames[strings.ToLower(alias)]:
+			return fmt.Errorf("operation %q: alias %q is reserved for a route of the API", op.Name, alias)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the operation with the given name or alias.
+func (r *Registry) Lookup(name string) (Operation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.index[strings.ToLower(name)]
+	if !ok {
+		return Operation{}, false
+	}
+	return r.ops[i], true
+}
+
+// Operations returns every operation in registration order.
+func (r *Registry) Operations() []Operation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Operation(nil), r.ops...)
+}
+
+// Names returns the operation names in registration order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.ops))
+	for i, op := range r.ops {
+		names[i] = op.Name
+	}
+	return names
+}
+
+// DefaultRegistry holds the built-in operations. Operations registered on it
+// are served by services that are not given a registry of their own.
+var DefaultRegistry = NewRegistry()
+
+var binary = []OperandType{TypeNumber, TypeNumber}
+
+var builtinOperations = []Operation{
+	{
+		Name: "add", Aliases: []string{"+"}, Operands: binary, Commutative: true,
+		Doc:      "Sum a + b.",
+		Fn:       func(a, b float64) (float64, error) { return Add(a, b), nil },
+		Partials: func(a, b float64) (float64, float64) { return 1, 1 },
+	},
+	{
+		Name: "subtract", Aliases: []string{"-"}, Operands: binary,
+		Doc:      "Difference a − b.",
+		Fn:       func(a, b float64) (float64, error) { return Subtract(a, b), nil },
+		Partials: func(a, b float64) (float64, float64) { return 1, -1 },
+	},
+	{
+		Name: "multiply", Aliases: []string{"*", "x"}, Operands: binary, Commutative: true,
+		Doc:      "Product a × b.",
+		Fn:       func(a, b float64) (float64, error) { return Multiply(a, b), nil },
+		Partials: func(a, b float64) (float64, float64) { return b, a },
+	},
+	{
+		Name: "divide", Aliases: []string{"/"}, Operands: binary,
+		Doc:      "Quotient a ÷ b; b must not be zero.",
+		Fn:       Divide,
+		Partials: func(a, b float64) (float64, float64) { return 1 / b, -a / b / b },
+	},
+}
@@ -0,0 +1,138 @@
+package calculator
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestRegistry_Builtins(t *testing.T) {
+	r := NewRegistry()
+	if got := r.Names(); !slices.Equal(got, []string{"add", "subtract", "multiply", "divide"}) {
+		t.Fatalf("Names = %v", got)
+	}
+	for alias, want := range map[string]string{"+": "add", "-": "subtract", "*": "multiply", "X": "multiply", "/": "divide", "Divide": "divide"} {
+		if op, ok := r.Lookup(alias); !ok || op.Name != want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", alias, op.Name, ok, want)
+		}
+	}
+	if _, ok := r.Lookup("pow"); ok {
+		t.Error("Lookup(pow) succeeded")
+	}
+	div, _ := r.Lookup("divide")
+	if _, err := div.Fn(1, 0); err != ErrDivisionByZero {
+		t.Errorf("divide by zero = %v", err)
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	hypot := Operation{
+		Name: "hypot", Operands: []OperandType{TypeNumber, TypeNumber}, Commutative: true,
+		Fn: func(a, b float64) (float64, error) { return math.Hypot(a, b), nil },
+	}
+	cases := []struct {
+		name string
+		op   Operation
+		ok   bool
+	}{
+		{"valid", hypot, true},
+		{"bad name", Operation{Name: "Hypot", Operands: hypot.Operands, Fn: hypot.Fn}, false},
+		{"no operands", Operation{Name: "zero", Fn: hypot.Fn}, false},
+		{"three operands", Operation{Name: "fma", Operands: []OperandType{TypeNumber, TypeNumber, TypeNumber}, Fn: hypot.Fn}, false},
+		{"no fn", Operation{Name: "noop", Operands: hypot.Operands}, false},
+		{"bad type", Operation{Name: "cbrt", Operands: []OperandType{"complex"}, Fn: hypot.Fn}, false},
+		{"taken name", Operation{Name: "add", Operands: hypot.Operands, Fn: hypot.Fn}, false},
+		{"taken alias", Operation{Name: "times", Aliases: []string{"x"}, Operands: hypot.Operands, Fn: hypot.Fn}, false},
+		{"repeated alias", Operation{Name: "mod", Aliases: []string{"%", "%"}, Operands: hypot.Operands, Fn: hypot.Fn}, false},
+		{"reserved name", Operation{Name: "stats", Operands: hypot.Operands, Fn: hypot.Fn}, false},
+		{"reserved alias", Operation{Name: "eval", Aliases: []string{"Evaluate"}, Operands: hypot.Operands, Fn: hypot.Fn}, false},
+		{"blank alias", Operation{Name: "rem", Aliases: []string{" "}, Operands: hypot.Operands, Fn: hypot.Fn}, false},
+	}
+	r := NewRegistry()
+	for _, tc := range cases {
+		if err := r.Register(tc.op); (err == nil) != tc.ok {
+			t.Errorf("%s: Register error = %v", tc.name, err)
+		}
+	}
+	if got := r.Names(); !slices.Equal(got, []string{"add", "subtract", "multiply", "divide", "hypot"}) {
+		t.Fatalf("Names = %v", got)
+	}
+	if _, ok := NewRegistry().Lookup("hypot"); ok {
+		t.Error("registration leaked into another registry")
+	}
+}
+
+func TestRegistry_Replace(t *testing.T) {
+	op := func(name string, aliases ...string) Operation {
+		return Operation{Name: name, Aliases: aliases, Operands: []OperandType{TypeNumber},
+			Fn: func(a, _ float64) (float64, error) { return a, nil }}
+	}
+	r := NewRegistry()
+	if err := r.Replace(nil, op("half"), op("twice", "double")); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := r.Lookup("twice")
+
+	// A failing replacement changes nothing.
+	if err := r.Replace([]string{"half"}, op("twice2", "x")); err == nil {
+		t.Fatal("taken alias accepted")
+	}
+	if err := r.Replace([]string{"add"}); err == nil {
+		t.Fatal("built-in removed")
+	}
+	if got := r.Names(); !slices.Equal(got, []string{"add", "subtract", "multiply", "divide", "half", "twice"}) {
+		t.Fatalf("Names after failed Replace = %v", got)
+	}
+
+	// Names and aliases of removed operations can be reused in the same step.
+	if err := r.Replace([]string{"half", "twice"}, op("twice", "double")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Lookup("half"); ok {
+		t.Error("half still registered")
+	}
+	if cur, ok := r.Lookup("double"); !ok || cur.ID() == old.ID() {
+		t.Errorf("replaced operation ID = %d, was %d", cur.ID(), old.ID())
+	}
+}
+
+func TestPropagate(t *testing.T) {
+	r := NewRegistry()
+	sqrt := Operation{
+		Name: "sqrt", Operands: []OperandType{TypeNumber},
+		Fn:       func(a, _ float64) (float64, error) { return math.Sqrt(a), nil },
+		Partials: func(a, _ float64) (float64, float64) { return 0.5 / math.Sqrt(a), 0 },
+	}
+	if err := r.Register(sqrt); err != nil {
+		t.Fatal(err)
+	}
+	x, y := Uncertain{10, 0.3}, Uncertain{4, 0.4}
+	for _, name := range []string{"add", "subtract", "multiply", "divide"} {
+		op, _ := r.Lookup(name)
+		got, err := Propagate(op, x, y)
+		var want Uncertain
+		switch name {
+		case "add":
+			want = x.Add(y)
+		case "subtract":
+			want = x.Sub(y)
+		case "multiply":
+			want = x.Mul(y)
+		case "divide":
+			want, _ = x.Div(y)
+		}
+		if err != nil || !closeTo(got.Value, want.Value) || !closeTo(got.Uncertainty, want.Uncertainty) {
+			t.Errorf("%s = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if got, err := Propagate(sqrt, Uncertain{4, 0.4}, Uncertain{}); err != nil || got != (Uncertain{2, 0.1}) {
+		t.Errorf("sqrt = %v, %v", got, err)
+	}
+	// An exact zero has no uncertainty to scale by the infinite derivative.
+	if got, err := Propagate(sqrt, Exact(0), Uncertain{}); err != nil || got != (Uncertain{}) {
+		t.Errorf("exact sqrt(0) = %v, %v", got, err)
+	}
+	if _, err := Propagate(Operation{Name: "floor", Fn: sqrt.Fn}, x, y); err == nil {
+		t.Error("operation without partials propagated")
+	}
+}
@@ -0,0 +1,57 @@
+package calculator
+
+import "math"
+
+// Uncertain is a measured value with its standard uncertainty. Operations
+// propagate uncertainty to first order, treating their operands as
+// independent: σ² = (∂f/∂a·σa)² + (∂f/∂b·σb)².
+type Uncertain struct {
+	Value       float64 `json:"value"`
+	Uncertainty float64 `json:"uncertainty"`
+}
+
+// Exact returns x with no uncertainty.
+func Exact(x float64) Uncertain { return Uncertain{Value: x} }
+
+func (x Uncertain) Add(y Uncertain) Uncertain {
+	return Uncertain{x.Value + y.Value, math.Hypot(x.Uncertainty, y.Uncertainty)}
+}
+
+func (x Uncertain) Sub(y Uncertain) Uncertain {
+	return Uncertain{x.Value - y.Value, math.Hypot(x.Uncertainty, y.Uncertainty)}
+}
+
+func (x Uncertain) Mul(y Uncertain) Uncertain {
+	return Uncertain{x.Value * y.Value, math.Hypot(y.Value*x.Uncertainty, x.Value*y.Uncertainty)}
+}
+
+// Div returns x/y, or ErrDivisionByZero when the value of y is zero.
+func (x Uncertain) Div(y Uncertain) (Uncertain, error) {
+	if y.Value == 0 {
+		return Uncertain{}, ErrDivisionByZero
+	}
+	q := x.Value / y.Value
+	return Uncertain{q, math.Hypot(x.Uncertainty/y.Value, q*y.Uncertainty/y.Value)}, nil
+}
+
+// Propagate applies op to measured values, propagating their uncertainties
+// through op.Partials. Operands without uncertainty contribute nothing, even
+// where a partial derivative is infinite.
+func Propagate(op Operation, a, b Uncertain) (Uncertain, error) {
+	if op.Partials == nil {
+		return Uncertain{}, &Error{Kind: KindDomain, Op: op.Name, Msg: "operation does not propagate uncertainty"}
+	}
+	v, err := op.Fn(a.Value, b.Value)
+	if err != nil {
+		return Uncertain{}, err
+	}
+	da, db := op.Partials(a.Value, b.Value)
+	return Uncertain{v, math.Hypot(term(da, a.Uncertainty), term(db, b.Uncertainty))}, nil
+}
+
+func term(d, sigma float64) float64 {
+	if sigma == 0 {
+		return 0
+	}
+	return d * sigma
+}
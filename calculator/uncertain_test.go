@@ -0,0 +1,43 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestUncertainArithmetic(t *testing.T) {
+	x, y := Uncertain{10, 0.3}, Uncertain{4, 0.4}
+	cases := []struct {
+		name string
+		got  Uncertain
+		want Uncertain
+	}{
+		{"add", x.Add(y), Uncertain{14, 0.5}},
+		{"sub", x.Sub(y), Uncertain{6, 0.5}},
+		// Relative uncertainties of 3% and 10% combine in quadrature.
+		{"mul", x.Mul(y), Uncertain{40, 40 * math.Hypot(0.03, 0.1)}},
+		{"exact factor", x.Mul(Exact(2)), Uncertain{20, 0.6}},
+	}
+	q, err := x.Div(y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases = append(cases, struct {
+		name string
+		got  Uncertain
+		want Uncertain
+	}{"div", q, Uncertain{2.5, 2.5 * math.Hypot(0.03, 0.1)}})
+
+	for _, tc := range cases {
+		if !closeTo(tc.got.Value, tc.want.Value) || !closeTo(tc.got.Uncertainty, tc.want.Uncertainty) {
+			t.Errorf("%s = %v; want %v", tc.name, tc.got, tc.want)
+		}
+	}
+	if got := (Uncertain{0, 0.1}).Mul(Uncertain{0, 0.1}); got != (Uncertain{}) {
+		t.Errorf("product of zeros = %v; first order has no uncertainty", got)
+	}
+	if _, err := x.Div(Uncertain{0, 0.1}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Div by zero error = %v", err)
+	}
+}
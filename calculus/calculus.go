@@ -0,0 +1,103 @@
+// Package calculus evaluates definite integrals, series and products of real
+// functions numerically. Every result carries an error estimate, and every
+// computation runs under a budget of function evaluations and a context
+// deadline, so that a slowly converging or pathological input fails with a
+// BudgetError instead of running on.
+package calculus
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// Func is a real function of one variable.
+type Func func(x float64) (float64, error)
+
+// Options control the accuracy and the evaluation budget of a computation.
+type Options struct {
+	// Tolerance is the target error, scaled by max(1, |result|).
+	Tolerance float64
+	// MaxEvals caps the function evaluations.
+	MaxEvals int
+}
+
+// DefaultOptions are used by the API when a request sets no limits. The
+// tolerance is about the square root of float64 precision, which every
+// method here reaches on well-behaved input.
+var DefaultOptions = Options{Tolerance: 1e-8, MaxEvals: 100000}
+
+// target returns the absolute error accepted for a result near v.
+func (o Options) target(v float64) float64 { return o.Tolerance * max(1, math.Abs(v)) }
+
+// Result is a computed value, an estimate of its absolute error and the
+// number of function evaluations it took.
+type Result struct {
+	Value float64
+	Error float64
+	Evals int
+}
+
+// BudgetError is returned when a computation runs out of evaluations or
+// time before meeting its tolerance. Estimate is the best value reached so
+// far and EstimateError its error, both NaN when there is none.
+type BudgetError struct {
+	Limit                   string // LimitEvals or LimitTime
+	Evals                   int
+	Estimate, EstimateError float64
+}
+
+// The budgets a BudgetError can exceed.
+const (
+	LimitEvals = "evaluations"
+	LimitTime  = "time"
+)
+
+func (e *BudgetError) Error() string {
+	if e.Limit == LimitTime {
+		return "computation ran out of time"
+	}
+	return "computation ran out of evaluations"
+}
+
+// errBudget is returned by counter.eval when the budget is spent; callers
+// turn it into a BudgetError carrying their estimate.
+var errBudget = errors.New("calculus: budget spent")
+
+// ctxCheckEvery is how many evaluations pass between deadline checks.
+const ctxCheckEvery = 64
+
+// counter evaluates f while enforcing the budget.
+type counter struct {
+	ctx   context.Context
+	f     Func
+	max   int
+	n     int
+	limit string // set once the budget is spent
+}
+
+func newCounter(ctx context.Context, f Func, o Options) *counter {
+	return &counter{ctx: ctx, f: f, max: o.MaxEvals}
+}
+
+func (c *counter) eval(x float64) (float64, error) {
+	if c.n >= c.max {
+		c.limit = LimitEvals
+		return 0, errBudget
+	}
+	if c.n%ctxCheckEvery == 0 && c.ctx.Err() != nil {
+		c.limit = LimitTime
+		return 0, errBudget
+	}
+	c.n++
+	return c.f(x)
+}
+
+// budgetError reports err as a BudgetError with the estimate so far if it
+// is the counter's budget running out, and passes other errors through.
+func (c *counter) budgetError(err error, value, errEst float64) error {
+	if err != errBudget {
+		return err
+	}
+	return &BudgetError{Limit: c.limit, Evals: c.n, Estimate: value, EstimateError: errEst}
+}
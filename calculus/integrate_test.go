@@ -0,0 +1,80 @@
+package calculus
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func fn(f func(float64) float64) Func {
+	return func(x float64) (float64, error) { return f(x), nil }
+}
+
+func TestIntegrate(t *testing.T) {
+	cases := []struct {
+		name    string
+		f       Func
+		a, b    float64
+		want    float64
+		methods []string
+	}{
+		{"sin", fn(math.Sin), 0, math.Pi, 2, []string{GaussKronrod, Simpson}},
+		{"gaussian", fn(func(x float64) float64 { return math.Exp(-x * x) }), -3, 3, math.Sqrt(math.Pi) * math.Erf(3), []string{GaussKronrod, Simpson}},
+		{"reversed", fn(func(x float64) float64 { return x * x }), 3, 0, -9, []string{GaussKronrod, Simpson}},
+		{"kink", fn(func(x float64) float64 { return math.Abs(x - 0.3) }), 0, 1, 0.29, []string{GaussKronrod, Simpson}},
+		{"empty", fn(math.Exp), 2, 2, 0, []string{GaussKronrod, Simpson}},
+		// Singular at the lower end, which only Gauss–Kronrod avoids.
+		{"log", fn(math.Log), 0, 1, -1, []string{GaussKronrod}},
+		{"inverse sqrt", fn(func(x float64) float64 { return 1 / math.Sqrt(x) }), 0, 4, 4, []string{GaussKronrod}},
+	}
+	for _, tc := range cases {
+		for _, m := range tc.methods {
+			r, err := Integrate(context.Background(), tc.f, tc.a, tc.b, m, DefaultOptions)
+			if err != nil {
+				t.Errorf("%s/%s: %v", tc.name, m, err)
+				continue
+			}
+			if got := math.Abs(r.Value - tc.want); got > r.Error || r.Error > DefaultOptions.target(tc.want) {
+				t.Errorf("%s/%s = %v ± %v; want %v", tc.name, m, r.Value, r.Error, tc.want)
+			}
+		}
+	}
+}
+
+func TestIntegrate_Errors(t *testing.T) {
+	ctx := context.Background()
+	domain := func(x float64) (float64, error) {
+		if x <= 0 {
+			return 0, &calculator.Error{Kind: calculator.KindDomain, Op: "ln", Msg: "argument is outside the domain of the function"}
+		}
+		return math.Log(x), nil
+	}
+	var ce *calculator.Error
+	if _, err := Integrate(ctx, domain, 0, 1, Simpson, DefaultOptions); !errors.As(err, &ce) || ce.Op != "ln" {
+		t.Errorf("simpson at a singular end: got %v", err)
+	}
+	if _, err := Integrate(ctx, fn(math.Sin), 0, 1, "trapezoid", DefaultOptions); err != ErrUnknownMethod {
+		t.Errorf("unknown method: got %v", err)
+	}
+
+	// sin(1/x) oscillates without end near 0 and exhausts any budget.
+	wild := fn(func(x float64) float64 { return math.Sin(1 / x) })
+	o := Options{Tolerance: 1e-12, MaxEvals: 3000}
+	_, err := Integrate(ctx, wild, 0, 1, GaussKronrod, o)
+	var be *BudgetError
+	if !errors.As(err, &be) || be.Limit != LimitEvals || be.Evals > o.MaxEvals {
+		t.Fatalf("got %v; want an evaluation budget error", err)
+	}
+	if math.Abs(be.Estimate-0.5040670619) > 1e-3 || math.IsNaN(be.EstimateError) {
+		t.Errorf("estimate = %v ± %v", be.Estimate, be.EstimateError)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Integrate(cancelled, fn(math.Sin), 0, 1, GaussKronrod, DefaultOptions); !errors.As(err, &be) || be.Limit != LimitTime {
+		t.Errorf("cancelled context: got %v; want a time budget error", err)
+	}
+}
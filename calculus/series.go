@@ -0,0 +1,320 @@
+package calculus
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"erikkruuse/calculator/calculator"
+)
+
+var (
+	// ErrSeriesDiverges is returned when the terms of an infinite series
+	// stop shrinking, or shrink no faster than 1/n.
+	ErrSeriesDiverges = &calculator.Error{Kind: calculator.KindDomain, Op: "sum", Msg: "the series diverges"}
+	// ErrProductDiverges is returned when the factors of an infinite product
+	// do not approach 1 fast enough for it to converge to a non-zero value.
+	ErrProductDiverges = &calculator.Error{Kind: calculator.KindDomain, Op: "product", Msg: "the product diverges"}
+)
+
+const eps = 0x1p-52
+
+// Sum returns the sum of f(n) for the integers n from from to to. An empty
+// range sums to 0.
+func Sum(ctx context.Context, f Func, from, to int64, o Options) (Result, error) {
+	if err := checkRange(from, to, o); err != nil {
+		return Result{}, err
+	}
+	c := newCounter(ctx, f, o)
+	var acc kahan
+	for n := from; n <= to; n++ {
+		t, err := c.eval(float64(n))
+		if err != nil {
+			return Result{}, c.budgetError(err, math.NaN(), math.NaN())
+		}
+		acc.add(t)
+	}
+	v := acc.value()
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return Result{}, overflow("sum")
+	}
+	return Result{Value: v, Error: eps * acc.abs, Evals: c.n}, nil
+}
+
+// Product returns the product of f(n) for the integers n from from to to.
+// An empty range multiplies to 1.
+func Product(ctx context.Context, f Func, from, to int64, o Options) (Result, error) {
+	if err := checkRange(from, to, o); err != nil {
+		return Result{}, err
+	}
+	c := newCounter(ctx, f, o)
+	p := 1.0
+	for n := from; n <= to; n++ {
+		t, err := c.eval(float64(n))
+		if err != nil {
+			return Result{}, c.budgetError(err, math.NaN(), math.NaN())
+		}
+		p *= t
+	}
+	if math.IsInf(p, 0) || math.IsNaN(p) {
+		return Result{}, overflow("product")
+	}
+	return Result{Value: p, Error: math.Abs(p) * float64(c.n) * eps, Evals: c.n}, nil
+}
+
+// checkRange rejects a finite range with more terms than the budget allows
+// before evaluating any of them.
+func checkRange(from, to int64, o Options) error {
+	if to >= from && uint64(to-from) >= uint64(o.MaxEvals) {
+		return &BudgetError{Limit: LimitEvals, Estimate: math.NaN(), EstimateError: math.NaN()}
+	}
+	return nil
+}
+
+// Series returns the sum of f(n) for the integers n from from on. It adds
+// terms until they fall off geometrically below the tolerance, or until the
+// Levin u-transform of the partial sums settles, which also covers
+// alternating and slowly converging series such as Σ1/n². It returns
+// ErrSeriesDiverges when the terms do not decay fast enough to converge.
+func Series(ctx context.Context, f Func, from int64, o Options) (Result, error) {
+	return series(newCounter(ctx, f, o), from, o, ErrSeriesDiverges)
+}
+
+// InfiniteProduct returns the product of f(n) for the integers n from from
+// on, by summing the logarithms of the factors as a series. A zero factor
+// makes the product zero.
+func InfiniteProduct(ctx context.Context, f Func, from int64, o Options) (Result, error) {
+	var negative, lastNegative bool
+	logf := func(x float64) (float64, error) {
+		v, err := f(x)
+		if err != nil {
+			return 0, err
+		}
+		if v == 0 {
+			return 0, errZeroFactor
+		}
+		lastNegative = v < 0
+		negative = negative != lastNegative
+		return math.Log(math.Abs(v)), nil
+	}
+	c := newCounter(ctx, logf, o)
+	r, err := series(c, from, o, ErrProductDiverges)
+	sign := 1.0
+	if negative {
+		sign = -1
+	}
+	var be *BudgetError
+	switch {
+	case errors.Is(err, errZeroFactor):
+		return Result{Evals: c.n}, nil
+	case errors.As(err, &be):
+		be.Estimate, be.EstimateError = sign*math.Exp(be.Estimate), math.Exp(be.Estimate)*math.Expm1(be.EstimateError)
+		return Result{}, be
+	case err != nil:
+		return Result{}, err
+	case lastNegative:
+		// The factors must approach +1; ones still negative never do.
+		return Result{}, ErrProductDiverges
+	}
+	p := math.Exp(r.Value)
+	if math.IsInf(p, 0) {
+		return Result{}, overflow("product")
+	}
+	return Result{Value: sign * p, Error: p * math.Expm1(r.Error), Evals: r.Evals}, nil
+}
+
+var errZeroFactor = errors.New("calculus: zero factor")
+
+func overflow(op string) error {
+	return &calculator.Error{Kind: calculator.KindOverflow, Op: op, Msg: "result overflows the float64 range"}
+}
+
+const (
+	// minTerms is the first checkpoint; later ones come at each doubling.
+	minTerms = 16
+	// divergenceTerms is the first checkpoint at which the decay of the
+	// terms is judged; earlier, a slow start looks like no decay at all.
+	divergenceTerms = 1024
+	// levinTerms caps the terms fed to the Levin transform, whose finite
+	// differences lose precision beyond it.
+	levinTerms = 32
+)
+
+func series(c *counter, from int64, o Options, diverges error) (Result, error) {
+	var (
+		terms         []float64
+		sums          []float64
+		acc           kahan
+		best, bestErr = math.NaN(), math.Inf(1)
+	)
+	for n := from; ; n++ {
+		t, err := c.eval(float64(n))
+		if err != nil {
+			if math.IsInf(bestErr, 1) {
+				bestErr = math.NaN()
+			}
+			return Result{}, c.budgetError(err, best, bestErr)
+		}
+		acc.add(t)
+		s := acc.value()
+		if math.IsInf(s, 0) || math.IsNaN(s) {
+			return Result{}, diverges
+		}
+		terms, sums = append(terms, t), append(sums, s)
+		N := len(terms)
+		if N < minTerms || N&(N-1) != 0 {
+			continue
+		}
+		if N >= divergenceTerms && noDecay(terms) {
+			return Result{}, diverges
+		}
+		if tail, ok := geometricTail(terms); ok && tail+eps*acc.abs < bestErr {
+			best, bestErr = s, tail+eps*acc.abs
+		}
+		// Accelerating terms that do not decay would assign a value to a
+		// divergent series such as Σ(−1)ⁿ.
+		if v, e, ok := levin(terms, sums); ok && e+eps*acc.abs < bestErr && decaying(terms) {
+			best, bestErr = v, e+eps*acc.abs
+		}
+		if bestErr <= o.target(best) {
+			return Result{Value: best, Error: bestErr, Evals: c.n}, nil
+		}
+	}
+}
+
+// noDecay reports whether the terms of a series have stopped decaying
+// fast enough to converge. For terms of one sign it compares the sums of
+// the blocks [N/4, N/2) and [N/2, N), which are equal for 1/n and shrink
+// for any faster power; otherwise it compares the largest terms of those
+// blocks, which must shrink for the terms to tend to zero.
+func noDecay(terms []float64) bool {
+	N := len(terms)
+	prev, last := terms[N/4:N/2], terms[N/2:]
+	pos, neg := true, true
+	for _, t := range terms[N/4:] {
+		pos = pos && t >= 0
+		neg = neg && t <= 0
+	}
+	if pos || neg {
+		return absSum(last) >= 0.99*absSum(prev) && absSum(last) > 0
+	}
+	return absMax(last) >= 0.99*absMax(prev)
+}
+
+// geometricTail bounds the rest of a series whose terms shrink
+// geometrically, from the decay of the largest terms over the last two
+// quarters of those seen.
+func geometricTail(terms []float64) (float64, bool) {
+	N := len(terms)
+	q := N / 4
+	m1, m2 := absMax(terms[N-q:]), absMax(terms[N-2*q:N-q])
+	if m1 == 0 {
+		return 0, true
+	}
+	if m2 == 0 {
+		return 0, false
+	}
+	r := math.Pow(m1/m2, 1/float64(q))
+	if r >= 0.95 {
+		return 0, false
+	}
+	return m1 * r / (1 - r), true
+}
+
+// decaying reports whether the largest of the last quarter of the terms is
+// smaller than the largest of the quarter before.
+func decaying(terms []float64) bool {
+	N := len(terms)
+	q := N / 4
+	return absMax(terms[N-q:]) < 0.99*absMax(terms[N-2*q:N-q])
+}
+
+// levin estimates the limit of the partial sums s of the terms a with the
+// Levin u-transform, starting from the first and from the second term. The
+// transform amplifies rounding in the terms, so in float64 it rarely gets
+// closer than 1e-10 for series like Σ1/n²; the error adds the transforms'
+// disagreement to the worse of their own convergence so as not to claim
+// more.
+func levin(a, s []float64) (float64, float64, bool) {
+	v0, e0, ok0 := levinFrom(a, s, 0)
+	v1, e1, ok1 := levinFrom(a, s, 1)
+	if !ok0 || !ok1 {
+		return 0, 0, false
+	}
+	return v0, max(e0, e1) + math.Abs(v0-v1), true
+}
+
+// levinFrom returns the u-transform estimate over terms n.. whose successive
+// orders agree best, and that agreement as its error.
+func levinFrom(a, s []float64, n int) (float64, float64, bool) {
+	K := min(len(a)-n, levinTerms)
+	if K < 4 {
+		return 0, 0, false
+	}
+	for _, t := range a[n : n+K] {
+		if t == 0 {
+			return 0, 0, false
+		}
+	}
+	const beta = 1.0
+	best, bestErr, prev, prevStep := 0.0, math.Inf(1), math.NaN(), math.Inf(1)
+	for k := 1; k < K; k++ {
+		var num, den float64
+		binom := 1.0
+		for j := 0; j <= k; j++ {
+			c := binom * math.Pow((beta+float64(n+j))/(beta+float64(n+k)), float64(k-1))
+			if j%2 == 1 {
+				c = -c
+			}
+			w := (beta + float64(n+j)) * a[n+j]
+			num += c * s[n+j] / w
+			den += c / w
+			binom = binom * float64(k-j) / float64(j+1)
+		}
+		v := num / den
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			continue
+		}
+		// Judge each order by its last two steps; one step can agree by
+		// chance.
+		d := math.Abs(v - prev)
+		if e := max(d, prevStep); k >= 3 && e < bestErr {
+			best, bestErr = v, e
+		}
+		prev, prevStep = v, d
+	}
+	return best, bestErr, !math.IsInf(bestErr, 1)
+}
+
+func absSum(ts []float64) float64 {
+	var s float64
+	for _, t := range ts {
+		s += math.Abs(t)
+	}
+	return s
+}
+
+func absMax(ts []float64) float64 {
+	var m float64
+	for _, t := range ts {
+		m = max(m, math.Abs(t))
+	}
+	return m
+}
+
+// kahan is a Neumaier compensated sum, also tracking the sum of magnitudes
+// that bounds its rounding error.
+type kahan struct{ sum, c, abs float64 }
+
+func (k *kahan) add(x float64) {
+	t := k.sum + x
+	if math.Abs(k.sum) >= math.Abs(x) {
+		k.c += (k.sum - t) + x
+	} else {
+		k.c += (x - t) + k.sum
+	}
+	k.sum = t
+	k.abs += math.Abs(x)
+}
+
+func (k *kahan) value() float64 { return k.sum + k.c }
@@ -0,0 +1,134 @@
+package calculus
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	ctx := context.Background()
+	r, err := Sum(ctx, fn(func(n float64) float64 { return n * n }), 1, 100, DefaultOptions)
+	if err != nil || r.Value != 338350 || r.Evals != 100 {
+		t.Errorf("Σn² to 100 = %+v, %v", r, err)
+	}
+	if r, err := Sum(ctx, fn(math.Sqrt), 5, 4, DefaultOptions); err != nil || r.Value != 0 {
+		t.Errorf("empty sum = %+v, %v", r, err)
+	}
+	r, err = Product(ctx, fn(func(n float64) float64 { return n }), 1, 20, DefaultOptions)
+	if err != nil || r.Value != 2432902008176640000 {
+		t.Errorf("20! = %+v, %v", r, err)
+	}
+	if r, err := Product(ctx, fn(math.Sqrt), 5, 4, DefaultOptions); err != nil || r.Value != 1 {
+		t.Errorf("empty product = %+v, %v", r, err)
+	}
+	if _, err := Product(ctx, fn(math.Exp), 1, 1000, DefaultOptions); err == nil {
+		t.Error("overflowing product succeeded")
+	}
+
+	var be *BudgetError
+	_, err = Sum(ctx, fn(math.Sqrt), 1, 1e12, DefaultOptions)
+	if !errors.As(err, &be) || be.Evals != 0 {
+		t.Errorf("sum longer than the budget: got %v", err)
+	}
+}
+
+func TestSeries(t *testing.T) {
+	cases := []struct {
+		name string
+		f    func(float64) float64
+		from int64
+		want float64
+	}{
+		{"geometric", func(n float64) float64 { return math.Pow(0.5, n) }, 0, 2},
+		{"exp", func(n float64) float64 { return 1 / math.Gamma(n+1) }, 0, math.E},
+		{"basel", func(n float64) float64 { return 1 / (n * n) }, 1, math.Pi * math.Pi / 6},
+		{"zeta 3/2", func(n float64) float64 { return math.Pow(n, -1.5) }, 1, 2.612375348685488},
+		{"alternating harmonic", func(n float64) float64 { return math.Pow(-1, n+1) / n }, 1, math.Ln2},
+		{"leibniz", func(n float64) float64 { return math.Pow(-1, n) / (2*n + 1) }, 0, math.Pi / 4},
+		{"telescoping", func(n float64) float64 { return 1 / (n * (n + 1)) }, 1, 1},
+		{"moment", func(n float64) float64 { return math.Pow(n, 5) * math.Exp(-n) }, 1, 119.99782676761603},
+	}
+	for _, tc := range cases {
+		r, err := Series(context.Background(), fn(tc.f), tc.from, DefaultOptions)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := math.Abs(r.Value - tc.want); got > max(r.Error, 1e-10*tc.want) || r.Error > DefaultOptions.target(tc.want) {
+			t.Errorf("%s = %v ± %v; want %v", tc.name, r.Value, r.Error, tc.want)
+		}
+	}
+}
+
+func TestSeries_Diverges(t *testing.T) {
+	for name, f := range map[string]func(float64) float64{
+		"harmonic":   func(n float64) float64 { return 1 / n },
+		"constant":   func(float64) float64 { return 1 },
+		"grandi":     func(n float64) float64 { return math.Pow(-1, n) },
+		"growing":    func(n float64) float64 { return n },
+		"oscillates": math.Cos,
+	} {
+		if _, err := Series(context.Background(), fn(f), 1, DefaultOptions); err != ErrSeriesDiverges {
+			t.Errorf("%s: got %v; want ErrSeriesDiverges", name, err)
+		}
+	}
+}
+
+func TestInfiniteProduct(t *testing.T) {
+	cases := []struct {
+		name string
+		f    func(float64) float64
+		from int64
+		want float64
+	}{
+		{"wallis", func(n float64) float64 { return 1 - 1/(4*n*n) }, 1, 2 / math.Pi},
+		{"half", func(n float64) float64 { return 1 - 1/(n*n) }, 2, 0.5},
+		{"sinh", func(n float64) float64 { return 1 + 1/(n*n) }, 1, math.Sinh(math.Pi) / math.Pi},
+		{"sign", func(n float64) float64 {
+			if n == 1 {
+				return -2
+			}
+			return 1 + math.Pow(0.5, n)
+		}, 1, -3.17897470537516},
+		{"zero factor", func(n float64) float64 { return 1 - 1/n }, 1, 0},
+	}
+	for _, tc := range cases {
+		r, err := InfiniteProduct(context.Background(), fn(tc.f), tc.from, DefaultOptions)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := math.Abs(r.Value - tc.want); got > max(r.Error, 1e-10) || r.Error > DefaultOptions.target(tc.want) {
+			t.Errorf("%s = %v ± %v; want %v", tc.name, r.Value, r.Error, tc.want)
+		}
+	}
+
+	for name, f := range map[string]func(float64) float64{
+		"to zero":     func(n float64) float64 { return 1 - 1/(n+1) },
+		"to infinity": func(n float64) float64 { return 1 + 1/n },
+		"negative":    func(float64) float64 { return -1 },
+	} {
+		if _, err := InfiniteProduct(context.Background(), fn(f), 1, DefaultOptions); err != ErrProductDiverges {
+			t.Errorf("%s: got %v; want ErrProductDiverges", name, err)
+		}
+	}
+}
+
+func TestSeries_Budget(t *testing.T) {
+	// Σ1/(n ln² n) converges, but far too slowly for any budget.
+	slow := fn(func(n float64) float64 { return 1 / (n * math.Log(n) * math.Log(n)) })
+	o := Options{Tolerance: 1e-8, MaxEvals: 5000}
+	_, err := Series(context.Background(), slow, 2, o)
+	var be *BudgetError
+	if !errors.As(err, &be) || be.Limit != LimitEvals || be.Evals != o.MaxEvals {
+		t.Fatalf("got %v; want an evaluation budget error", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Series(cancelled, slow, 2, DefaultOptions); !errors.As(err, &be) || be.Limit != LimitTime {
+		t.Errorf("cancelled context: got %v; want a time budget error", err)
+	}
+}
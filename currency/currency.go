@@ -0,0 +1,248 @@
+// Package currency converts and sums money amounts with exact decimal
+// arithmetic, using an exchange-rate table loaded from a local file. Results
+// are rounded to the minor units of the target currency.
+package currency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/decimal"
+)
+
+// Errors returned for malformed amounts, unknown currencies and missing tables.
+var (
+	ErrInvalidAmount   = errors.New("amount must be a decimal number such as 12.50")
+	ErrUnknownCurrency = errors.New("unknown currency")
+	ErrNoRates         = errors.New("no exchange-rate table is loaded")
+)
+
+// Amount is an exact decimal amount of a currency identified by its ISO 4217 code.
+type Amount struct {
+	Value *big.Rat
+	Code  string
+}
+
+// String formats a as "12.5 USD", with as many decimals as the value needs.
+func (a Amount) String() string {
+	places := decimal.Places(a.Value)
+	if places < 0 {
+		places = 10
+	}
+	return a.Value.FloatString(places) + " " + a.Code
+}
+
+// ParseAmount parses "12.50 USD" or "USD 12.50".
+func ParseAmount(s string) (Amount, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Amount{}, ErrInvalidAmount
+	}
+	num, code := fields[0], fields[1]
+	if isCode(num) {
+		num, code = code, num
+	}
+	v, err := decimal.Parse(num)
+	if err != nil {
+		return Amount{}, ErrInvalidAmount
+	}
+	if !isCode(code) {
+		return Amount{}, ErrUnknownCurrency
+	}
+	return Amount{Value: v, Code: code}, nil
+}
+
+func isCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Rate is the value of one unit of the table's base currency in Currency,
+// valid from Effective until the next rate for the same currency.
+type Rate struct {
+	Currency  string
+	Value     *big.Rat
+	Effective time.Time
+}
+
+// Table is an immutable snapshot of an exchange-rate file.
+type Table struct {
+	// ID identifies the snapshot by a hash of the file contents.
+	ID       string
+	Base     string
+	LoadedAt time.Time
+
+	rates map[string][]Rate // per currency, by ascending Effective
+	minor map[string]int
+}
+
+// tableFile is the on-disk format:
+//
+//	{
+//	  "base": "EUR",
+//	  "minor_units": {"XAU": 4},
+//	  "rates": [{"currency": "USD", "rate": "1.0850", "effective": "2026-10-01"}]
+//	}
+type tableFile struct {
+	Base       string         `json:"base"`
+	MinorUnits map[string]int `json:"minor_units"`
+	Rates      []struct {
+		Currency  string `json:"currency"`
+		Rate      string `json:"rate"`
+		Effective string `json:"effective"`
+	} `json:"rates"`
+}
+
+// ParseTable parses a rate file. Rates are strings so they are read exactly.
+func ParseTable(data []byte) (*Table, error) {
+	var tf tableFile
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&tf); err != nil {
+		return nil, err
+	}
+	if !isCode(tf.Base) {
+		return nil, fmt.Errorf("base %q is not an ISO 4217 code", tf.Base)
+	}
+
+	sum := sha256.Sum256(data)
+	t := &Table{
+		ID:    hex.EncodeToString(sum[:6]),
+		Base:  tf.Base,
+		rates: map[string][]Rate{},
+		minor: map[string]int{},
+	}
+	for code, n := range tf.MinorUnits {
+		if !isCode(code) || n < 0 || n > 8 {
+			return nil, fmt.Errorf("minor_units: invalid entry %q: %d", code, n)
+		}
+		t.minor[code] = n
+	}
+	for i, r := range tf.Rates {
+		if !isCode(r.Currency) || r.Currency == tf.Base {
+			return nil, fmt.Errorf("rates[%d]: invalid currency %q", i, r.Currency)
+		}
+		v, err := decimal.Parse(r.Rate)
+		if err != nil || v.Sign() <= 0 {
+			return nil, fmt.Errorf("rates[%d]: rate must be a positive decimal", i)
+		}
+		eff, err := time.Parse(time.DateOnly, r.Effective)
+		if err != nil {
+			return nil, fmt.Errorf("rates[%d]: effective: %w", i, err)
+		}
+		t.rates[r.Currency] = append(t.rates[r.Currency], Rate{Currency: r.Currency, Value: v, Effective: eff})
+	}
+	for code, rs := range t.rates {
+		sort.Slice(rs, func(i, j int) bool { return rs[i].Effective.Before(rs[j].Effective) })
+		for i := 1; i < len(rs); i++ {
+			if rs[i].Effective.Equal(rs[i-1].Effective) {
+				return nil, fmt.Errorf("rates: %s has two rates effective %s", code, rs[i].Effective.Format(time.DateOnly))
+			}
+		}
+	}
+	return t, nil
+}
+
+// Known reports whether code can be converted with t.
+func (t *Table) Known(code string) bool {
+	_, ok := t.rates[code]
+	return ok || code == t.Base
+}
+
+// MinorUnits returns the number of decimals code is rounded to.
+func (t *Table) MinorUnits(code string) int {
+	if n, ok := t.minor[code]; ok {
+		return n
+	}
+	if n, ok := iso4217Minor[code]; ok {
+		return n
+	}
+	return 2
+}
+
+// rate returns the base→code rate effective on day.
+func (t *Table) rate(code string, day time.Time) (Rate, error) {
+	if code == t.Base {
+		return Rate{Currency: code, Value: big.NewRat(1, 1)}, nil
+	}
+	rs, ok := t.rates[code]
+	if !ok {
+		return Rate{}, ErrUnknownCurrency
+	}
+	i := sort.Search(len(rs), func(i int) bool { return rs[i].Effective.After(day) })
+	if i == 0 {
+		return Rate{}, &calculator.Error{Kind: calculator.KindDomain, Op: "convert", Field: "date", Msg: "no exchange rate is effective on the requested date"}
+	}
+	return rs[i-1], nil
+}
+
+// Quote is the exact rate used to convert From into To.
+type Quote struct {
+	From, To string
+	Rate     *big.Rat
+	// Effective is the later of the two table rates' effective dates.
+	Effective time.Time
+}
+
+// Quote returns the cross rate from→to effective on day, derived from the
+// base-currency rates of both.
+func (t *Table) Quote(from, to string, day time.Time) (Quote, error) {
+	rf, err := t.rate(from, day)
+	if err != nil {
+		return Quote{}, err
+	}
+	rt, err := t.rate(to, day)
+	if err != nil {
+		return Quote{}, err
+	}
+	eff := rf.Effective
+	if rt.Effective.After(eff) {
+		eff = rt.Effective
+	}
+	return Quote{From: from, To: to, Rate: new(big.Rat).Quo(rt.Value, rf.Value), Effective: eff}, nil
+}
+
+// Sum converts every amount into to at the rates effective on day and adds
+// them exactly; only the total is rounded to to's minor units. The quotes
+// used are returned once per source currency.
+func (t *Table) Sum(amounts []Amount, to string, day time.Time) (Amount, []Quote, error) {
+	total := new(big.Rat)
+	var quotes []Quote
+	seen := map[string]Quote{}
+	for _, a := range amounts {
+		q, ok := seen[a.Code]
+		if !ok {
+			var err error
+			if q, err = t.Quote(a.Code, to, day); err != nil {
+				return Amount{}, nil, err
+			}
+			seen[a.Code] = q
+			quotes = append(quotes, q)
+		}
+		total.Add(total, new(big.Rat).Mul(a.Value, q.Rate))
+	}
+	return Amount{Value: decimal.Round(total, t.MinorUnits(to)), Code: to}, quotes, nil
+}
+
+// iso4217Minor lists currencies whose minor units differ from 2.
+var iso4217Minor = map[string]int{
+	"BHD": 3, "BIF": 0, "CLF": 4, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0,
+	"RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "UYW": 4, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+}
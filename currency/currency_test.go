@@ -0,0 +1,222 @@
+package currency
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/decimal"
+)
+
+const testTable = `{
+	"base": "EUR",
+	"rates": [
+		{"currency": "USD", "rate": "1.0850", "effective": "2026-10-01"},
+		{"currency": "USD", "rate": "1.1000", "effective": "2026-10-15"},
+		{"currency": "GBP", "rate": "0.8600", "effective": "2026-10-01"},
+		{"currency": "JPY", "rate": "162.35", "effective": "2026-10-01"},
+		{"currency": "KWD", "rate": "0.3321", "effective": "2026-10-01"}
+	]
+}`
+
+func day(s string) time.Time {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func mustTable(t *testing.T) *Table {
+	t.Helper()
+	tbl, err := ParseTable([]byte(testTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func mustAmount(t *testing.T, s string) Amount {
+	t.Helper()
+	a, err := ParseAmount(s)
+	if err != nil {
+		t.Fatalf("ParseAmount(%q): %v", s, err)
+	}
+	return a
+}
+
+func TestParseAmount(t *testing.T) {
+	for in, want := range map[string]string{
+		"12.50 USD": "12.5 USD",
+		"USD 12.50": "12.5 USD",
+		"-3 JPY":    "-3 JPY",
+		"1e3 EUR":   "1000 EUR",
+	} {
+		if got := mustAmount(t, in).String(); got != want {
+			t.Fatalf("ParseAmount(%q) = %s; want %s", in, got, want)
+		}
+	}
+	for _, bad := range []string{"12.50", "12.50 usd", "12 US", "ten USD", "1 USD 2"} {
+		if _, err := ParseAmount(bad); err == nil {
+			t.Fatalf("ParseAmount(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestQuoteUsesEffectiveDates(t *testing.T) {
+	tbl := mustTable(t)
+	q, err := tbl.Quote("EUR", "USD", day("2026-10-10"))
+	if err != nil || q.Rate.Cmp(big.NewRat(10850, 10000)) != 0 || !q.Effective.Equal(day("2026-10-01")) {
+		t.Fatalf("EUR→USD on 10-10 = %+v, %v", q, err)
+	}
+	q, _ = tbl.Quote("EUR", "USD", day("2026-10-15"))
+	if q.Rate.Cmp(big.NewRat(11, 10)) != 0 {
+		t.Fatalf("EUR→USD on 10-15 = %v", q.Rate)
+	}
+	q, _ = tbl.Quote("USD", "GBP", day("2026-10-20"))
+	if q.Rate.Cmp(new(big.Rat).Quo(big.NewRat(86, 100), big.NewRat(11, 10))) != 0 || !q.Effective.Equal(day("2026-10-15")) {
+		t.Fatalf("USD→GBP cross = %+v", q)
+	}
+
+	_, err = tbl.Quote("EUR", "USD", day("2026-09-30"))
+	var ce *calculator.Error
+	if !errors.As(err, &ce) || ce.Kind != calculator.KindDomain || ce.Field != "date" {
+		t.Fatalf("quote before first rate error = %v", err)
+	}
+	if _, err := tbl.Quote("EUR", "CHF", day("2026-10-10")); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("unknown currency error = %v", err)
+	}
+}
+
+func TestSumRoundsToMinorUnits(t *testing.T) {
+	tbl := mustTable(t)
+	cases := []struct {
+		amounts []string
+		to      string
+		want    string
+	}{
+		{[]string{"100 USD"}, "EUR", "92.17 EUR"}, // 92.1658…
+		{[]string{"100 EUR"}, "JPY", "16235 JPY"}, // no minor unit
+		{[]string{"10 EUR"}, "KWD", "3.321 KWD"},  // three decimals
+		{[]string{"10 USD", "10 USD", "10 USD"}, "EUR", "27.65 EUR"},
+		{[]string{"50 EUR", "43 GBP", "-10 EUR"}, "EUR", "90.00 EUR"},
+	}
+	for _, tc := range cases {
+		var amounts []Amount
+		for _, s := range tc.amounts {
+			amounts = append(amounts, mustAmount(t, s))
+		}
+		got, quotes, err := tbl.Sum(amounts, tc.to, day("2026-10-10"))
+		if err != nil {
+			t.Fatalf("Sum(%v): %v", tc.amounts, err)
+		}
+		if s := decimal.Format(got.Value, tbl.MinorUnits(tc.to)) + " " + got.Code; s != tc.want {
+			t.Fatalf("Sum(%v, %s) = %s; want %s", tc.amounts, tc.to, s, tc.want)
+		}
+		if len(quotes) == 0 || len(quotes) > len(tc.amounts) {
+			t.Fatalf("Sum(%v) quotes = %+v", tc.amounts, quotes)
+		}
+	}
+}
+
+func TestParseTableErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"base": "euro"}`,
+		`{"base": "EUR", "rates": [{"currency": "USD", "rate": "-1", "effective": "2026-10-01"}]}`,
+		`{"base": "EUR", "rates": [{"currency": "USD", "rate": "1", "effective": "10/01/2026"}]}`,
+		`{"base": "EUR", "rates": [{"currency": "EUR", "rate": "1", "effective": "2026-10-01"}]}`,
+		`{"base": "EUR", "rates": [{"currency": "USD", "rate": "1", "effective": "2026-10-01"}, {"currency": "USD", "rate": "2", "effective": "2026-10-01"}]}`,
+		`{"base": "EUR", "minor_units": {"USD": -1}}`,
+		`{"base": "EUR", "extra": 1}`,
+	} {
+		if _, err := ParseTable([]byte(bad)); err == nil {
+			t.Fatalf("ParseTable(%s) succeeded", bad)
+		}
+	}
+	tbl, err := ParseTable([]byte(`{"base": "EUR", "minor_units": {"XAU": 4}}`))
+	if err != nil || tbl.MinorUnits("XAU") != 4 || tbl.MinorUnits("JPY") != 0 || tbl.MinorUnits("USD") != 2 {
+		t.Fatalf("minor units = %v", err)
+	}
+}
+
+func TestSourceReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	write := func(s string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write(testTable, start)
+
+	src, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := src.Current()
+
+	if changed, err := src.Reload(); changed || err != nil {
+		t.Fatalf("unchanged file reloaded: %v, %v", changed, err)
+	}
+
+	write(`{"base": "EUR", "rates": [{"currency": "USD", "rate": "2", "effective": "2026-01-01"}]}`, start.Add(time.Minute))
+	if changed, err := src.Reload(); !changed || err != nil {
+		t.Fatalf("changed file not reloaded: %v, %v", changed, err)
+	}
+	second := src.Current()
+	if second.ID == first.ID || second.Known("GBP") {
+		t.Fatalf("snapshot not replaced: %s vs %s", second.ID, first.ID)
+	}
+
+	write(`{"base": `, start.Add(2*time.Minute))
+	if _, err := src.Reload(); err == nil {
+		t.Fatal("broken file accepted")
+	}
+	if src.Current() != second {
+		t.Fatal("broken file replaced the snapshot")
+	}
+}
+
+func TestSourceWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(testTable), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := src.Current().ID
+	for _, every := range []time.Duration{0, -time.Second} {
+		if err := src.Watch(every, nil); !errors.Is(err, ErrWatchInterval) {
+			t.Fatalf("Watch(%s) = %v; want ErrWatchInterval", every, err)
+		}
+	}
+	if err := src.Watch(5*time.Millisecond, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	later := time.Now().Add(time.Minute)
+	os.WriteFile(path, []byte(`{"base": "USD"}`), 0o644)
+	os.Chtimes(path, later, later)
+	deadline := time.Now().Add(2 * time.Second)
+	for src.Current().ID == first {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher did not pick up the new file")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if src.Current().Base != "USD" {
+		t.Fatalf("base = %s", src.Current().Base)
+	}
+	src.Close()
+	src.Close()
+}
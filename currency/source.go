@@ -0,0 +1,96 @@
+package currency
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"erikkruuse/calculator/internal/poll"
+)
+
+// ErrWatchInterval is returned by Watch for an interval that is not positive.
+var ErrWatchInterval = poll.ErrInterval
+
+// Source holds the current Table loaded from a file and can watch the file for
+// changes. Readers always see a complete snapshot; a file that fails to parse
+// leaves the previous snapshot in place.
+type Source struct {
+	path string
+	cur  atomic.Pointer[Table]
+
+	mu      sync.Mutex // serializes reloads
+	modTime time.Time
+	size    int64
+
+	watcher *poll.Poller
+}
+
+// Open loads the rate table at path.
+func Open(path string) (*Source, error) {
+	s := &Source{path: path}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Current returns the latest successfully loaded table.
+func (s *Source) Current() *Table {
+	if s == nil {
+		return nil
+	}
+	return s.cur.Load()
+}
+
+// Reload re-reads the file if its size or modification time changed and
+// reports whether a new snapshot was installed. Rewrites with identical
+// contents keep the current snapshot.
+func (s *Source) Reload() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return false, err
+	}
+	if s.cur.Load() != nil && fi.ModTime().Equal(s.modTime) && fi.Size() == s.size {
+		return false, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, err
+	}
+	t, err := ParseTable(data)
+	if err != nil {
+		return false, err
+	}
+	s.modTime, s.size = fi.ModTime(), fi.Size()
+	if old := s.cur.Load(); old != nil && old.ID == t.ID {
+		return false, nil
+	}
+	t.LoadedAt = time.Now()
+	s.cur.Store(t)
+	return true, nil
+}
+
+// Watch polls the file every interval and reloads it when it changes. Reload
+// errors are passed to onError, if set. Close stops the watcher. An interval
+// that is not positive is rejected with ErrWatchInterval.
+func (s *Source) Watch(every time.Duration, onError func(error)) error {
+	w, err := poll.Start(every, func() {
+		if _, err := s.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	s.watcher = w
+	return nil
+}
+
+// Close stops the watcher and waits for it to exit. It is safe to call more than once.
+func (s *Source) Close() {
+	s.watcher.Stop()
+}
@@ -0,0 +1,69 @@
+// Package decimal provides exact decimal arithmetic helpers on big.Rat:
+// parsing decimal text, rounding to a number of places and formatting.
+package decimal
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// ErrSyntax is returned for text that is not a plain decimal number.
+var ErrSyntax = errors.New("value must be a decimal number such as 12.50")
+
+// Parse parses a decimal string such as "-1234.5" or "1e3" exactly.
+// Fractions ("1/3"), hex and non-finite values are rejected.
+func Parse(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "/xXpP_") {
+		return nil, ErrSyntax
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, ErrSyntax
+	}
+	return r, nil
+}
+
+// Round rounds r to places decimal places, halves away from zero.
+func Round(r *big.Rat, places int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	num := new(big.Int).Mul(r.Num(), scale)
+	q, m := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	// Compare twice the remainder with the denominator to decide the half.
+	if m.Abs(m).Lsh(m, 1).Cmp(r.Denom()) >= 0 {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return new(big.Rat).SetFrac(q, scale)
+}
+
+// Format formats r with exactly places decimals, rounding as Round does.
+func Format(r *big.Rat, places int) string {
+	return Round(r, places).FloatString(places)
+}
+
+// Places returns the number of decimals needed to print r exactly, or -1 if
+// r has no finite decimal expansion.
+func Places(r *big.Rat) int {
+	d := new(big.Int).Set(r.Denom())
+	var counts [2]int
+	for i, p := range []int64{2, 5} {
+		f := big.NewInt(p)
+		for {
+			q, rem := new(big.Int).QuoRem(d, f, new(big.Int))
+			if rem.Sign() != 0 {
+				break
+			}
+			d = q
+			counts[i]++
+		}
+	}
+	if !d.IsInt64() || d.Int64() != 1 {
+		return -1
+	}
+	return max(counts[0], counts[1])
+}
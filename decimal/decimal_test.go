@@ -0,0 +1,49 @@
+package decimal
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestRoundAndFormat(t *testing.T) {
+	cases := []struct {
+		in     string
+		places int
+		want   string
+	}{
+		{"2.345", 2, "2.35"},
+		{"2.344", 2, "2.34"},
+		{"-2.345", 2, "-2.35"},
+		{"0.5", 0, "1"},
+		{"-0.5", 0, "-1"},
+		{"1234.5678", 3, "1234.568"},
+		{"7", 2, "7.00"},
+	}
+	for _, tc := range cases {
+		r, err := Parse(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Format(r, tc.places); got != tc.want {
+			t.Fatalf("Format(%s, %d) = %s; want %s", tc.in, tc.places, got, tc.want)
+		}
+	}
+	for _, bad := range []string{"", "1/3", "0x10", "1_000", "inf", "abc"} {
+		if _, err := Parse(bad); !errors.Is(err, ErrSyntax) {
+			t.Fatalf("Parse(%q) error = %v", bad, err)
+		}
+	}
+}
+
+func TestPlaces(t *testing.T) {
+	for in, want := range map[string]int{"12": 0, "12.5": 1, "0.125": 3, "1e-4": 4, "-3.20": 1} {
+		r, _ := Parse(in)
+		if got := Places(r); got != want {
+			t.Fatalf("Places(%s) = %d; want %d", in, got, want)
+		}
+	}
+	if got := Places(big.NewRat(1, 3)); got != -1 {
+		t.Fatalf("Places(1/3) = %d; want -1", got)
+	}
+}
@@ -0,0 +1,200 @@
+// Package expr parses arithmetic expressions over real variables into an
+// abstract syntax tree that can be evaluated numerically, differentiated,
+// simplified and printed back as infix text or LaTeX.
+//
+// Numeric literals are kept as exact rationals so simplification never
+// introduces rounding; evaluation converts them to float64.
+package expr
+
+import (
+	"math/big"
+	"strings"
+
+	"erikkruuse/calculator/decimal"
+)
+
+// Node is an expression tree node: *Num, *Var, *Neg, *Binary or *Call.
+type Node interface {
+	// String prints the node as infix text that Parse reads back.
+	String() string
+	prec() int
+}
+
+// Num is a numeric literal.
+type Num struct{ Value *big.Rat }
+
+// Var is a variable or one of the named constants pi and e.
+type Var struct{ Name string }
+
+// Neg is unary minus.
+type Neg struct{ X Node }
+
+// Binary is an arithmetic operation; Op is one of + - * / ^.
+type Binary struct {
+	Op   byte
+	L, R Node
+}
+
+// Call applies a built-in function to its arguments.
+type Call struct {
+	Fn   string
+	Args []Node
+}
+
+// Operator precedences, lowest first.
+const (
+	precSum = iota + 1
+	precProduct
+	precNeg
+	precPow
+	precAtom
+)
+
+func (n *Num) prec() int {
+	switch {
+	case n.Value.Sign() < 0:
+		return precNeg
+	case !n.Value.IsInt() && decimal.Places(n.Value) < 0:
+		return precProduct // printed as a fraction
+	}
+	return precAtom
+}
+
+func (*Var) prec() int  { return precAtom }
+func (*Neg) prec() int  { return precNeg }
+func (*Call) prec() int { return precAtom }
+
+func (b *Binary) prec() int {
+	switch b.Op {
+	case '+', '-':
+		return precSum
+	case '*', '/':
+		return precProduct
+	}
+	return precPow
+}
+
+// String prints the value as a decimal when it has a finite expansion and as
+// a fraction otherwise.
+func (n *Num) String() string {
+	if places := decimal.Places(n.Value); places >= 0 {
+		return decimal.Format(n.Value, places)
+	}
+	return n.Value.String()
+}
+
+func (v *Var) String() string { return v.Name }
+
+// String leaves products unparenthesized: -2*x means the same either way.
+func (n *Neg) String() string { return "-" + paren(n.X, n.X.prec() < precProduct) }
+
+func (c *Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+	return c.Fn + "(" + strings.Join(args, ", ") + ")"
+}
+
+// String puts spaces around + and - only, so "2*x^2 - x/3" reads as it
+// groups.
+func (b *Binary) String() string {
+	l, r := b.operands()
+	if b.Op == '+' || b.Op == '-' {
+		return l + " " + string(b.Op) + " " + r
+	}
+	return l + string(b.Op) + r
+}
+
+// operands prints both operands, parenthesized where precedence or
+// associativity requires it.
+func (b *Binary) operands() (string, string) {
+	p := b.prec()
+	switch b.Op {
+	case '^':
+		// Right-associative: the base needs parentheses unless it is an atom.
+		return paren(b.L, b.L.prec() <= p), paren(b.R, b.R.prec() < p)
+	case '+', '*':
+		return paren(b.L, b.L.prec() < p), paren(b.R, b.R.prec() < p || b.R.prec() == precNeg)
+	}
+	return paren(b.L, b.L.prec() < p), paren(b.R, b.R.prec() <= p || b.R.prec() == precNeg)
+}
+
+func paren(n Node, needed bool) string {
+	if needed {
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+// Vars returns the variables n depends on in order of first appearance,
+// excluding the named constants.
+func Vars(n Node) []string {
+	var out []string
+	seen := map[string]bool{}
+	walk(n, func(n Node) {
+		if v, ok := n.(*Var); ok && !seen[v.Name] && !IsConst(v.Name) {
+			seen[v.Name] = true
+			out = append(out, v.Name)
+		}
+	})
+	return out
+}
+
+// dependsOn reports whether variable name occurs in n.
+func dependsOn(n Node, name string) bool {
+	found := false
+	walk(n, func(n Node) {
+		if v, ok := n.(*Var); ok && v.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func walk(n Node, fn func(Node)) {
+	fn(n)
+	switch n := n.(type) {
+	case *Neg:
+		walk(n.X, fn)
+	case *Binary:
+		walk(n.L, fn)
+		walk(n.R, fn)
+	case *Call:
+		for _, a := range n.Args {
+			walk(a, fn)
+		}
+	}
+}
+
+// size counts the nodes of n as a tree, stopping once it exceeds limit.
+func size(n Node, limit int) int {
+	count := 0
+	var visit func(Node)
+	visit = func(n Node) {
+		if count++; count > limit {
+			return
+		}
+		switch n := n.(type) {
+		case *Neg:
+			visit(n.X)
+		case *Binary:
+			visit(n.L)
+			visit(n.R)
+		case *Call:
+			for _, a := range n.Args {
+				visit(a)
+			}
+		}
+	}
+	visit(n)
+	return count
+}
+
+// num returns a literal for the integer v.
+func num(v int64) *Num { return &Num{big.NewRat(v, 1)} }
+
+func isNum(n Node, v int64) bool {
+	x, ok := n.(*Num)
+	return ok && x.Value.Cmp(big.NewRat(v, 1)) == 0
+}
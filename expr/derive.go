@@ -0,0 +1,113 @@
+package expr
+
+import (
+	"errors"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// MaxNodes bounds the size of a derivative before simplification. The chain
+// rule repeats the inner expression at every level, so the derivative of a
+// deeply nested expression grows quadratically with its depth.
+const MaxNodes = 20000
+
+var (
+	// ErrConstantVariable is returned when asked to differentiate with
+	// respect to a named constant such as pi.
+	ErrConstantVariable = errors.New("cannot differentiate with respect to a constant")
+	// ErrTooLarge is returned when a derivative exceeds MaxNodes.
+	ErrTooLarge = &calculator.Error{Kind: calculator.KindCalculation, Op: "derivative", Msg: "derivative is too large to compute"}
+)
+
+// Derivative returns the simplified derivative of n with respect to the
+// variable v.
+func Derivative(n Node, v string) (Node, error) {
+	if IsConst(v) {
+		return nil, ErrConstantVariable
+	}
+	d := derive(n, v)
+	if size(d, MaxNodes) > MaxNodes {
+		return nil, ErrTooLarge
+	}
+	return Simplify(d), nil
+}
+
+func derive(n Node, v string) Node {
+	if !dependsOn(n, v) {
+		return num(0)
+	}
+	switch n := n.(type) {
+	case *Var:
+		return num(1)
+	case *Neg:
+		return &Neg{derive(n.X, v)}
+	case *Binary:
+		dl, dr := derive(n.L, v), derive(n.R, v)
+		switch n.Op {
+		case '+', '-':
+			return &Binary{n.Op, dl, dr}
+		case '*':
+			return add(mul(dl, n.R), mul(n.L, dr))
+		case '/':
+			return div(sub(mul(dl, n.R), mul(n.L, dr)), pow(n.R, num(2)))
+		}
+		switch {
+		case !dependsOn(n.R, v):
+			// Power rule: (u^c)' = c·u^(c−1)·u'
+			return mul(mul(n.R, pow(n.L, sub(n.R, num(1)))), dl)
+		case !dependsOn(n.L, v):
+			// (c^u)' = c^u·ln(c)·u'
+			return mul(mul(n, call("ln", n.L)), dr)
+		}
+		// (u^w)' = u^w·(w'·ln(u) + w·u'/u)
+		return mul(n, add(mul(dr, call("ln", n.L)), div(mul(n.R, dl), n.L)))
+	case *Call:
+		u := n.Args[0]
+		return mul(chainRule(n.Fn, u, n), derive(u, v))
+	}
+	panic("expr: unknown node type")
+}
+
+// chainRule returns f'(u) for the call self = f(u).
+func chainRule(fn string, u, self Node) Node {
+	switch fn {
+	case "sin":
+		return call("cos", u)
+	case "cos":
+		return &Neg{call("sin", u)}
+	case "tan":
+		return div(num(1), pow(call("cos", u), num(2)))
+	case "asin":
+		return div(num(1), call("sqrt", sub(num(1), pow(u, num(2)))))
+	case "acos":
+		return &Neg{div(num(1), call("sqrt", sub(num(1), pow(u, num(2)))))}
+	case "atan":
+		return div(num(1), add(num(1), pow(u, num(2))))
+	case "sinh":
+		return call("cosh", u)
+	case "cosh":
+		return call("sinh", u)
+	case "tanh":
+		return sub(num(1), pow(self, num(2)))
+	case "exp":
+		return self
+	case "ln":
+		return div(num(1), u)
+	case "log":
+		return div(num(1), mul(u, call("ln", num(10))))
+	case "sqrt":
+		return div(num(1), mul(num(2), self))
+	case "cbrt":
+		return div(num(1), mul(num(3), pow(self, num(2))))
+	case "abs":
+		return div(u, self)
+	}
+	panic("expr: no derivative for " + fn)
+}
+
+func add(a, b Node) Node          { return &Binary{'+', a, b} }
+func sub(a, b Node) Node          { return &Binary{'-', a, b} }
+func mul(a, b Node) Node          { return &Binary{'*', a, b} }
+func div(a, b Node) Node          { return &Binary{'/', a, b} }
+func pow(a, b Node) Node          { return &Binary{'^', a, b} }
+func call(fn string, u Node) Node { return &Call{fn, []Node{u}} }
@@ -0,0 +1,102 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// numericDerivative estimates dn/dv at vars by Richardson-extrapolated
+// central differences.
+func numericDerivative(n Node, v string, vars map[string]float64) (float64, error) {
+	at := func(h float64) (float64, error) {
+		p := map[string]float64{}
+		for k, x := range vars {
+			p[k] = x
+		}
+		p[v] = vars[v] + h
+		hi, err := Eval(n, p)
+		if err != nil {
+			return 0, err
+		}
+		p[v] = vars[v] - h
+		lo, err := Eval(n, p)
+		return (hi - lo) / (2 * h), err
+	}
+	const h = 1e-3
+	d1, err := at(h)
+	if err != nil {
+		return 0, err
+	}
+	d2, err := at(h / 2)
+	return (4*d2 - d1) / 3, err
+}
+
+func TestDerivative(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"x^3 - 2x - 5", "3*x^2 - 2"},
+		{"5", "0"},
+		{"y^2", "0"},
+		{"x*y", "y"},
+		{"sin(x)", "cos(x)"},
+		{"cos(2x)", "-2*sin(2*x)"},
+		{"e^(2x)", "2*e^(2*x)"},
+		{"ln(x)/x", "(1 - ln(x))/x^2"},
+		{"sqrt(x^2 + 1)", "x/sqrt(x^2 + 1)"},
+		{"x^x", "x^x*(ln(x) + 1)"},
+		{"2^x", "2^x*ln(2)"},
+		{"1/x", "-1/x^2"},
+	}
+	for _, tc := range cases {
+		n := mustParse(t, tc.in)
+		d, err := Derivative(n, "x")
+		if err != nil {
+			t.Fatalf("Derivative(%q): %v", tc.in, err)
+		}
+		if d.String() != tc.want {
+			t.Errorf("Derivative(%q) = %s; want %s", tc.in, d, tc.want)
+		}
+	}
+}
+
+// TestDerivative_Numeric compares every rule against finite differences.
+func TestDerivative_Numeric(t *testing.T) {
+	for _, s := range []string{
+		"x^3*y - 4x/y", "tan(x)*y", "asin(x/3)", "acos(x/3)", "atan(x^2)",
+		"sinh(x)*cosh(y*x)", "tanh(x)", "exp(-x^2)", "log(x^2 + 1)", "abs(x - 1)",
+		"x^y", "y^x", "cbrt(x - 1)", "x^(x/2)", "(x + 1)^-2/(x - y)", "-sqrt(x)*ln(x)",
+	} {
+		n := mustParse(t, s)
+		d, err := Derivative(n, "x")
+		if err != nil {
+			t.Fatalf("Derivative(%q): %v", s, err)
+		}
+		for _, vars := range samples {
+			want, err := numericDerivative(n, "x", vars)
+			if err != nil {
+				continue
+			}
+			got, err := Eval(d, vars)
+			if err != nil || math.Abs(got-want) > 1e-6*math.Max(1, math.Abs(want)) {
+				t.Errorf("d/dx %s = %s = %v at %v; numeric %v (%v)", s, d, got, vars, want, err)
+			}
+		}
+	}
+}
+
+func TestDerivative_Constant(t *testing.T) {
+	if _, err := Derivative(mustParse(t, "x"), "pi"); !errors.Is(err, ErrConstantVariable) {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestDerivative_TooLarge(t *testing.T) {
+	tower := "x" + strings.Repeat("^x", maxDepth-10)
+	if _, err := Derivative(mustParse(t, tower), "x"); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("error = %v", err)
+	}
+}
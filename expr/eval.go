@@ -0,0 +1,142 @@
+package expr
+
+import (
+	"math"
+	"sort"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// Func is a built-in function.
+type Func struct {
+	Arity  int
+	eval   func(float64) float64
+	domain func(float64) bool // nil when defined for every real
+}
+
+// Funcs lists the functions Parse accepts. log is base 10; ln is natural;
+// cbrt is the real cube root.
+var Funcs = map[string]Func{
+	"sin":  {1, math.Sin, nil},
+	"cos":  {1, math.Cos, nil},
+	"tan":  {1, math.Tan, nil},
+	"asin": {1, math.Asin, within1},
+	"acos": {1, math.Acos, within1},
+	"atan": {1, math.Atan, nil},
+	"sinh": {1, math.Sinh, nil},
+	"cosh": {1, math.Cosh, nil},
+	"tanh": {1, math.Tanh, nil},
+	"exp":  {1, math.Exp, nil},
+	"ln":   {1, math.Log, positive},
+	"log":  {1, math.Log10, positive},
+	"sqrt": {1, math.Sqrt, func(x float64) bool { return x >= 0 }},
+	"cbrt": {1, math.Cbrt, nil},
+	"abs":  {1, math.Abs, nil},
+}
+
+func within1(x float64) bool  { return x >= -1 && x <= 1 }
+func positive(x float64) bool { return x > 0 }
+
+// FuncNames returns the names in Funcs in sorted order.
+func FuncNames() []string {
+	names := make([]string, 0, len(Funcs))
+	for name := range Funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// consts are the named constants; they cannot be used as variables.
+var consts = map[string]float64{"pi": math.Pi, "e": math.E}
+
+// IsConst reports whether name is a named constant rather than a variable.
+func IsConst(name string) bool {
+	_, ok := consts[name]
+	return ok
+}
+
+// Eval evaluates n with the given variable values. Errors are
+// *calculator.Error: a variable without a value (Field names it), an argument
+// outside a function's domain, division by zero or overflow.
+func Eval(n Node, vars map[string]float64) (float64, error) {
+	switch n := n.(type) {
+	case *Num:
+		f, _ := n.Value.Float64()
+		return f, nil
+
+	case *Var:
+		if v, ok := consts[n.Name]; ok {
+			return v, nil
+		}
+		v, ok := vars[n.Name]
+		if !ok {
+			return 0, &calculator.Error{Kind: calculator.KindCalculation, Op: "evaluate", Field: n.Name, Msg: "variable has no value"}
+		}
+		return v, nil
+
+	case *Neg:
+		x, err := Eval(n.X, vars)
+		return -x, err
+
+	case *Binary:
+		l, err := Eval(n.L, vars)
+		if err != nil {
+			return 0, err
+		}
+		r, err := Eval(n.R, vars)
+		if err != nil {
+			return 0, err
+		}
+		return binary(n.Op, l, r)
+
+	case *Call:
+		x, err := Eval(n.Args[0], vars)
+		if err != nil {
+			return 0, err
+		}
+		fn := Funcs[n.Fn]
+		if fn.domain != nil && !fn.domain(x) {
+			return 0, domainError(n.Fn)
+		}
+		return finite(n.Fn, fn.eval(x))
+	}
+	panic("expr: unknown node type")
+}
+
+func binary(op byte, l, r float64) (float64, error) {
+	switch op {
+	case '+':
+		return finite("add", l+r)
+	case '-':
+		return finite("subtract", l-r)
+	case '*':
+		return finite("multiply", l*r)
+	case '/':
+		if r == 0 {
+			return 0, divisionByZero
+		}
+		return finite("divide", l/r)
+	}
+	if l == 0 && r < 0 {
+		return 0, divisionByZero
+	}
+	v := math.Pow(l, r)
+	if math.IsNaN(v) {
+		return 0, domainError("pow")
+	}
+	return finite("pow", v)
+}
+
+var divisionByZero = &calculator.Error{Kind: calculator.KindCalculation, Op: "divide", Msg: "division by zero is not allowed"}
+
+func domainError(op string) error {
+	return &calculator.Error{Kind: calculator.KindDomain, Op: op, Msg: "argument is outside the domain of the function"}
+}
+
+func finite(op string, v float64) (float64, error) {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, &calculator.Error{Kind: calculator.KindOverflow, Op: op, Msg: "result overflows the float64 range"}
+	}
+	return v, nil
+}
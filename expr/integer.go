@@ -0,0 +1,331 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// IntExpr is an expression in integer (programmer) mode. Its literals take
+// their value from the type it is evaluated in, so 0xFF is 255 as a uint8
+// and -1 as an int8.
+type IntExpr struct{ root intNode }
+
+type intNode interface{}
+
+// intLit is a literal as written, with any sign folded in.
+type intLit struct {
+	text string
+	pos  int
+}
+
+type intVar struct{ name string }
+
+// intCall applies a calculator.IntOp operation; operators are parsed into
+// the same form, with negation as subtraction from zero.
+type intCall struct {
+	op   string
+	args []intNode
+}
+
+// intLevels are the binary operators from lowest to highest precedence, as
+// in C.
+var intLevels = [][]struct{ tok, op string }{
+	{{"|", "or"}},
+	{{"^", "xor"}},
+	{{"&", "and"}},
+	{{"<<", "shl"}, {">>", "shr"}},
+	{{"+", "add"}, {"-", "subtract"}},
+	{{"*", "multiply"}, {"/", "divide"}, {"%", "mod"}},
+}
+
+// ParseInt reads an integer-mode expression. It understands decimal, hex
+// (0xFF), octal (0o17) and binary (0b1010) literals with _ separators, the
+// operators | ^ & << >> + - * / % with C precedence, unary - and ~ (not),
+// variables, and the operations of calculator.IntOp called by name, such as
+// rotl(x, 3).
+func ParseInt(s string) (*IntExpr, error) {
+	if len(s) > MaxLength {
+		return nil, &SyntaxError{MaxLength, "expression is too long"}
+	}
+	p := &intParser{src: s}
+	p.next()
+	n, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return &IntExpr{n}, nil
+}
+
+// Vars returns the variables e depends on in order of first appearance.
+func (e *IntExpr) Vars() []string {
+	var out []string
+	seen := map[string]bool{}
+	var visit func(intNode)
+	visit = func(n intNode) {
+		switch n := n.(type) {
+		case intVar:
+			if !seen[n.name] {
+				seen[n.name] = true
+				out = append(out, n.name)
+			}
+		case *intCall:
+			for _, a := range n.args {
+				visit(a)
+			}
+		}
+	}
+	visit(e.root)
+	return out
+}
+
+// Eval evaluates e in type t with the given variable values, which must have
+// type t. Arithmetic wraps on overflow when wrap is set, as do literals that
+// do not fit t. Errors are *calculator.Error: those of calculator.IntOp, a
+// literal out of range, or a variable without a value (Field names it).
+func (e *IntExpr) Eval(t calculator.IntType, vars map[string]calculator.Int, wrap bool) (calculator.Int, error) {
+	return evalInt(e.root, t, vars, wrap)
+}
+
+func evalInt(n intNode, t calculator.IntType, vars map[string]calculator.Int, wrap bool) (calculator.Int, error) {
+	switch n := n.(type) {
+	case intLit:
+		v, err := t.Parse(n.text, wrap)
+		if err != nil {
+			return calculator.Int{}, &calculator.Error{Kind: calculator.KindOverflow, Op: "evaluate", Msg: calculator.ErrIntRange.Error()}
+		}
+		return v, nil
+
+	case intVar:
+		v, ok := vars[n.name]
+		switch {
+		case !ok:
+			return calculator.Int{}, &calculator.Error{Kind: calculator.KindCalculation, Op: "evaluate", Field: n.name, Msg: "variable has no value"}
+		case v.Type != t:
+			return calculator.Int{}, &calculator.Error{Kind: calculator.KindDomain, Op: "evaluate", Field: n.name, Msg: "operands must have the same integer type"}
+		}
+		return v, nil
+
+	case *intCall:
+		args := []calculator.Int{{Type: t}, {Type: t}}
+		for i, a := range n.args {
+			v, err := evalInt(a, t, vars, wrap)
+			if err != nil {
+				return calculator.Int{}, err
+			}
+			args[i] = v
+		}
+		return calculator.IntOp(n.op, args[0], args[1], wrap)
+	}
+	panic("expr: unknown integer node type")
+}
+
+type intParser struct {
+	src   string
+	off   int
+	tok   token
+	depth int
+}
+
+func (p *intParser) fail(msg string) error { return &SyntaxError{p.tok.pos, msg} }
+
+// unexpected reports the current token, which the lexer passes through as an
+// operator when it is a character the grammar does not know.
+func (p *intParser) unexpected() error {
+	if p.tok.kind == tokOp && !intOps[p.tok.text] {
+		return p.fail("unexpected character")
+	}
+	return p.fail(p.tok.unexpected())
+}
+
+var intOps = map[string]bool{
+	"|": true, "^": true, "&": true, "<<": true, ">>": true, "+": true, "-": true,
+	"*": true, "/": true, "%": true, "~": true,
+}
+
+// next advances to the following token.
+func (p *intParser) next() {
+	for p.off < len(p.src) {
+		r, w := utf8.DecodeRuneInString(p.src[p.off:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		p.off += w
+	}
+	t := token{pos: p.off}
+	if p.off == len(p.src) {
+		p.tok = t
+		return
+	}
+
+	rest := p.src[p.off:]
+	r, _ := utf8.DecodeRuneInString(rest)
+	word := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' }
+	switch {
+	case r >= '0' && r <= '9':
+		t.kind, t.text = tokNum, rest[:wordEnd(rest, word)]
+	case unicode.IsLetter(r) || r == '_':
+		t.kind, t.text = tokIdent, rest[:wordEnd(rest, word)]
+	case strings.HasPrefix(rest, "<<") || strings.HasPrefix(rest, ">>"):
+		t.kind, t.text = tokOp, rest[:2]
+	case strings.ContainsRune("|^&+-*/%~", r):
+		t.kind, t.text = tokOp, rest[:1]
+	case r == '(':
+		t.kind, t.text = tokLParen, "("
+	case r == ')':
+		t.kind, t.text = tokRParen, ")"
+	case r == ',':
+		t.kind, t.text = tokComma, ","
+	default:
+		t.kind, t.text = tokOp, string(r)
+	}
+	p.off += len(t.text)
+	p.tok = t
+}
+
+func wordEnd(s string, stop func(rune) bool) int {
+	if i := strings.IndexFunc(s, stop); i >= 0 {
+		return i
+	}
+	return len(s)
+}
+
+func (p *intParser) enter() error {
+	if p.depth++; p.depth > maxDepth {
+		return p.fail("expression is nested too deeply")
+	}
+	return nil
+}
+
+func (p *intParser) leave() { p.depth-- }
+
+// binary parses the operators of intLevels[level] and above, left to right.
+func (p *intParser) binary(level int) (intNode, error) {
+	switch level {
+	case len(intLevels):
+		return p.unary()
+	case 0:
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+	}
+	n, err := p.binary(level + 1)
+	for err == nil {
+		op := ""
+		for _, o := range intLevels[level] {
+			if p.tok.kind == tokOp && p.tok.text == o.tok {
+				op = o.op
+			}
+		}
+		if op == "" {
+			return n, nil
+		}
+		p.next()
+		var r intNode
+		if r, err = p.binary(level + 1); err == nil {
+			n = &intCall{op, []intNode{n, r}}
+		}
+	}
+	return nil, err
+}
+
+// unary = ("-" | "+" | "~") unary | atom. A minus directly before a literal
+// is part of it, so -128 is an int8.
+func (p *intParser) unary() (intNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if p.tok.kind != tokOp || !strings.Contains("-+~", p.tok.text) {
+		return p.atom()
+	}
+	op := p.tok.text
+	p.next()
+	if op == "-" && p.tok.kind == tokNum {
+		lit, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		l := lit.(intLit)
+		return intLit{"-" + l.text, l.pos}, nil
+	}
+	x, err := p.unary()
+	switch {
+	case err != nil || op == "+":
+		return x, err
+	case op == "~":
+		return &intCall{"not", []intNode{x}}, nil
+	}
+	return &intCall{"subtract", []intNode{intLit{"0", 0}, x}}, nil
+}
+
+// atom = literal | variable | operation "(" args ")" | "(" expression ")"
+func (p *intParser) atom() (intNode, error) {
+	t := p.tok
+	switch t.kind {
+	case tokNum:
+		// Check the syntax now; the value depends on the type.
+		if _, err := (calculator.IntType{Bits: 64}).Parse(t.text, true); errors.Is(err, calculator.ErrInvalidInt) {
+			return nil, p.fail("malformed number")
+		}
+		p.next()
+		return intLit{t.text, t.pos}, nil
+
+	case tokIdent:
+		p.next()
+		arity := calculator.IntArity(t.text)
+		switch {
+		case arity > 0 && p.tok.kind == tokLParen:
+			return p.call(t, arity)
+		case arity > 0:
+			return nil, &SyntaxError{t.pos, "function name must be followed by ("}
+		case p.tok.kind == tokLParen:
+			return nil, &SyntaxError{t.pos, "unknown function"}
+		}
+		return intVar{t.text}, nil
+
+	case tokLParen:
+		p.next()
+		n, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.fail("missing closing parenthesis")
+		}
+		p.next()
+		return n, nil
+
+	}
+	return nil, p.unexpected()
+}
+
+func (p *intParser) call(name token, arity int) (intNode, error) {
+	p.next() // (
+	c := &intCall{op: name.text}
+	for p.tok.kind != tokRParen {
+		if len(c.args) > 0 {
+			if p.tok.kind != tokComma {
+				return nil, p.fail("missing closing parenthesis")
+			}
+			p.next()
+		}
+		a, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, a)
+	}
+	p.next()
+	if len(c.args) != arity {
+		return nil, &SyntaxError{name.pos, "wrong number of arguments"}
+	}
+	return c, nil
+}
@@ -0,0 +1,132 @@
+package expr
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func intType(t *testing.T, name string) calculator.IntType {
+	t.Helper()
+	it, ok := calculator.ParseIntType(name)
+	if !ok {
+		t.Fatalf("ParseIntType(%q) failed", name)
+	}
+	return it
+}
+
+func TestIntExpr_Eval(t *testing.T) {
+	cases := []struct {
+		in   string
+		typ  string
+		wrap bool
+		want string // in hex, the bit pattern
+	}{
+		{"0xF0 | 0b1010", "uint8", false, "0xfa"},
+		{"0xF0 & 0x3C ^ 0x0F", "uint8", false, "0x3f"},
+		{"~0", "uint16", false, "0xffff"},
+		{"1 << 4 + 1", "int32", false, "0x20"},
+		{"(1 << 4) + 1", "int32", false, "0x11"},
+		{"0x80 >> 7", "int8", false, "0xff"}, // arithmetic shift of -128
+		{"-128", "int8", false, "0x80"},
+		{"-0x80", "int8", false, "0x80"},
+		{"-x", "int8", false, "0xfd"},
+		{"7 / 2 * 2 + 7 % 2", "int64", false, "0x7"},
+		{"-7 / 2", "int64", false, "0xfffffffffffffffd"}, // truncated
+		{"rotl(0x81, 1)", "uint8", false, "0x3"},
+		{"rotr(x, 1) | not(0xFE)", "uint8", false, "0x81"},
+		{"255 + 1", "uint8", true, "0x0"},
+		{"0 - 1", "uint8", true, "0xff"},
+		{"300", "uint8", true, "0x2c"},
+		{"1_000_000 * 1_000", "uint32", false, "0x3b9aca00"},
+	}
+	for _, tc := range cases {
+		e, err := ParseInt(tc.in)
+		if err != nil {
+			t.Fatalf("ParseInt(%q): %v", tc.in, err)
+		}
+		typ := intType(t, tc.typ)
+		x, _ := typ.Parse("3", false)
+		got, err := e.Eval(typ, map[string]calculator.Int{"x": x}, tc.wrap)
+		if err != nil || got.Format(16) != tc.want {
+			t.Errorf("%q as %s = %v, %v; want %s", tc.in, tc.typ, got.Format(16), err, tc.want)
+		}
+	}
+}
+
+func TestIntExpr_EvalErrors(t *testing.T) {
+	cases := []struct {
+		in, typ string
+		kind    calculator.Kind
+		field   string
+	}{
+		{"255 + 1", "uint8", calculator.KindOverflow, ""},
+		{"-1", "uint8", calculator.KindOverflow, ""},
+		{"-x", "uint8", calculator.KindOverflow, ""},
+		{"0x1FF", "uint8", calculator.KindOverflow, ""},
+		{"1 / (x - 3)", "int32", calculator.KindCalculation, "b"},
+		{"1 << 8", "uint8", calculator.KindDomain, "b"},
+		{"y + 1", "int32", calculator.KindCalculation, "y"},
+	}
+	for _, tc := range cases {
+		e, err := ParseInt(tc.in)
+		if err != nil {
+			t.Fatalf("ParseInt(%q): %v", tc.in, err)
+		}
+		typ := intType(t, tc.typ)
+		x, _ := typ.Parse("3", false)
+		_, err = e.Eval(typ, map[string]calculator.Int{"x": x}, false)
+		var ce *calculator.Error
+		if !errors.As(err, &ce) || ce.Kind != tc.kind || ce.Field != tc.field {
+			t.Errorf("%q as %s: error = %v; want %s on %q", tc.in, tc.typ, err, tc.kind, tc.field)
+		}
+	}
+
+	// Variables must have the expression's type.
+	e, _ := ParseInt("x + 1")
+	x, _ := intType(t, "int16").Parse("1", false)
+	if _, err := e.Eval(intType(t, "int32"), map[string]calculator.Int{"x": x}, false); err == nil {
+		t.Fatal("int16 variable in an int32 expression succeeded")
+	}
+}
+
+func TestParseInt_Errors(t *testing.T) {
+	cases := []struct {
+		in     string
+		msg    string
+		offset int
+	}{
+		{"", "unexpected end of expression", 0},
+		{"1 |", "unexpected end of expression", 3},
+		{"(1 & 2", "missing closing parenthesis", 6},
+		{"1 + 2)", "unexpected symbol", 5},
+		{"2 $ 3", "unexpected character", 2},
+		{"0xZZ", "malformed number", 0},
+		{"1.5", "unexpected character", 1},
+		{"2x", "malformed number", 0},
+		{"sqrt(2)", "unknown function", 0},
+		{"rotl(1)", "wrong number of arguments", 0},
+		{"not 1", "function name must be followed by (", 0},
+		{strings.Repeat("(", 300) + "1" + strings.Repeat(")", 300), "expression is nested too deeply", 100},
+	}
+	for _, tc := range cases {
+		_, err := ParseInt(tc.in)
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Msg != tc.msg || se.Offset != tc.offset {
+			t.Errorf("ParseInt(%q) error = %v; want %q at %d", tc.in, err, tc.msg, tc.offset)
+		}
+	}
+}
+
+func TestIntExpr_Vars(t *testing.T) {
+	e, err := ParseInt("flags & mask | rotl(flags, n)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Vars(); !slices.Equal(got, []string{"flags", "mask", "n"}) {
+		t.Fatalf("Vars = %v", got)
+	}
+}
@@ -0,0 +1,134 @@
+package expr
+
+import "strings"
+
+// latexFuncs maps function names to their LaTeX operators.
+var latexFuncs = map[string]string{
+	"sin": `\sin`, "cos": `\cos`, "tan": `\tan`,
+	"asin": `\arcsin`, "acos": `\arccos`, "atan": `\arctan`,
+	"sinh": `\sinh`, "cosh": `\cosh`, "tanh": `\tanh`,
+	"ln": `\ln`, "log": `\log_{10}`,
+}
+
+// LaTeX renders n as a LaTeX math expression: quotients as \frac, powers as
+// superscripts, roots and abs with their own notation, and a coefficient
+// written next to what it multiplies (2x, 3\sin\left(x\right)).
+func LaTeX(n Node) string {
+	var b strings.Builder
+	latex(&b, n)
+	return b.String()
+}
+
+func latex(b *strings.Builder, n Node) {
+	switch n := n.(type) {
+	case *Num:
+		s := n.String()
+		if num, den, ok := strings.Cut(s, "/"); ok {
+			neg := strings.HasPrefix(num, "-")
+			if neg {
+				b.WriteString("-")
+			}
+			b.WriteString(`\frac{` + strings.TrimPrefix(num, "-") + `}{` + den + `}`)
+			return
+		}
+		b.WriteString(s)
+	case *Var:
+		switch {
+		case n.Name == "pi":
+			b.WriteString(`\pi`)
+		case len(n.Name) > 1:
+			b.WriteString(`\mathrm{` + strings.ReplaceAll(n.Name, "_", `\_`) + `}`)
+		default:
+			b.WriteString(n.Name)
+		}
+	case *Neg:
+		b.WriteString("-")
+		latexParen(b, n.X, n.X.prec() < precProduct)
+	case *Call:
+		latexCall(b, n)
+	case *Binary:
+		latexBinary(b, n)
+	}
+}
+
+func latexParen(b *strings.Builder, n Node, needed bool) {
+	if needed {
+		b.WriteString(`\left(`)
+		latex(b, n)
+		b.WriteString(`\right)`)
+		return
+	}
+	latex(b, n)
+}
+
+func latexCall(b *strings.Builder, c *Call) {
+	u := c.Args[0]
+	switch c.Fn {
+	case "sqrt":
+		b.WriteString(`\sqrt{`)
+		latex(b, u)
+		b.WriteString("}")
+	case "cbrt":
+		b.WriteString(`\sqrt[3]{`)
+		latex(b, u)
+		b.WriteString("}")
+	case "abs":
+		b.WriteString(`\left|`)
+		latex(b, u)
+		b.WriteString(`\right|`)
+	case "exp":
+		b.WriteString("e^{")
+		latex(b, u)
+		b.WriteString("}")
+	default:
+		b.WriteString(latexFuncs[c.Fn])
+		latexParen(b, u, true)
+	}
+}
+
+func latexBinary(b *strings.Builder, n *Binary) {
+	p := n.prec()
+	switch n.Op {
+	case '/':
+		b.WriteString(`\frac{`)
+		latex(b, n.L)
+		b.WriteString("}{")
+		latex(b, n.R)
+		b.WriteString("}")
+	case '^':
+		latexParen(b, n.L, n.L.prec() <= p || isCall(n.L))
+		b.WriteString("^{")
+		latex(b, n.R)
+		b.WriteString("}")
+	case '*':
+		latexParen(b, n.L, n.L.prec() < p)
+		// A literal coefficient sits next to the factor it scales.
+		if _, lit := n.L.(*Num); !lit || !juxtaposes(n.R) {
+			b.WriteString(` \cdot `)
+		}
+		latexParen(b, n.R, n.R.prec() < p || n.R.prec() == precNeg)
+	default:
+		latexParen(b, n.L, n.L.prec() < p)
+		b.WriteString(" " + string(n.Op) + " ")
+		latexParen(b, n.R, n.R.prec() < p || n.R.prec() == precNeg || (n.Op == '-' && n.R.prec() == p))
+	}
+}
+
+// juxtaposes reports whether n can follow a coefficient without a \cdot:
+// anything that does not start with a digit.
+func juxtaposes(n Node) bool {
+	switch n := n.(type) {
+	case *Var, *Call:
+		return true
+	case *Binary:
+		if n.Op == '^' || n.Op == '*' {
+			return juxtaposes(n.L)
+		}
+	}
+	return false
+}
+
+func isCall(n Node) bool {
+	_, ok := n.(*Call)
+	return ok
+}
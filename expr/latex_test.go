@@ -0,0 +1,25 @@
+package expr
+
+import "testing"
+
+func TestLaTeX(t *testing.T) {
+	for in, want := range map[string]string{
+		"3x^2 - 2":         `3x^{2} - 2`,
+		"x/(y + 1)":        `\frac{x}{y + 1}`,
+		"sqrt(x)*2":        `\sqrt{x} \cdot 2`,
+		"2 sin(x)":         `2\sin\left(x\right)`,
+		"abs(x - 1)":       `\left|x - 1\right|`,
+		"cbrt(2)":          `\sqrt[3]{2}`,
+		"exp(2x)":          `e^{2x}`,
+		"pi*r^2":           `\pi \cdot r^{2}`,
+		"2*3":              `2 \cdot 3`,
+		"(x + 1)^(1/2)":    `\left(x + 1\right)^{\frac{1}{2}}`,
+		"log(x) - (a - b)": `\log_{10}\left(x\right) - \left(a - b\right)`,
+		"speed_max*t":      `\mathrm{speed\_max} \cdot t`,
+		"-(x + y)":         `-\left(x + y\right)`,
+	} {
+		if got := LaTeX(mustParse(t, in)); got != want {
+			t.Errorf("LaTeX(%q) = %s; want %s", in, got, want)
+		}
+	}
+}
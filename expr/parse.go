@@ -0,0 +1,338 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"erikkruuse/calculator/decimal"
+)
+
+// Limits on parser input, so a request cannot exhaust the stack or memory.
+const (
+	MaxLength = 4096
+	maxDepth  = 200
+)
+
+// SyntaxError reports malformed input and the byte offset where parsing
+// stopped.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string { return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset) }
+
+// Parse reads an infix expression. It understands + - * / and ^ (also **)
+// with the usual precedence, unary minus binding looser than ^ so -x^2 is
+// -(x^2), implicit multiplication such as 2x or 3(x+1), the functions listed
+// in Funcs and the constants pi and e.
+func Parse(s string) (Node, error) {
+	if len(s) > MaxLength {
+		return nil, &SyntaxError{MaxLength, "expression is too long"}
+	}
+	p := &parser{src: s}
+	p.next()
+	n, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.fail(p.tok.unexpected())
+	}
+	return n, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNum
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind  tokKind
+	text  string
+	pos   int
+	glued bool // no whitespace before the token
+}
+
+// unexpected is the message for t where it does not belong, written out
+// whole so the catalogs can translate it.
+func (t token) unexpected() string {
+	switch t.kind {
+	case tokEOF:
+		return "unexpected end of expression"
+	case tokNum:
+		return "unexpected number"
+	case tokIdent:
+		return "unexpected name"
+	}
+	return "unexpected symbol"
+}
+
+type parser struct {
+	src   string
+	off   int
+	tok   token
+	depth int
+	err   *SyntaxError // lexer error, reported by the next parse step
+}
+
+func (p *parser) fail(msg string) error { return &SyntaxError{p.tok.pos, msg} }
+
+// next advances to the following token.
+func (p *parser) next() {
+	start := p.off
+	for p.off < len(p.src) {
+		r, w := utf8.DecodeRuneInString(p.src[p.off:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		p.off += w
+	}
+	t := token{pos: p.off, glued: p.off == start}
+	if p.off == len(p.src) {
+		t.kind = tokEOF
+		p.tok = t
+		return
+	}
+
+	rest := p.src[p.off:]
+	r, w := utf8.DecodeRuneInString(rest)
+	size := w // bytes consumed, when it differs from len(t.text)
+	switch {
+	case r >= '0' && r <= '9' || r == '.':
+		t.kind, t.text = tokNum, scanNumber(rest)
+		size = len(t.text)
+	case r == 'π':
+		t.kind, t.text = tokIdent, "pi"
+	case unicode.IsLetter(r) || r == '_':
+		size = strings.IndexFunc(rest, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		})
+		if size < 0 {
+			size = len(rest)
+		}
+		t.kind, t.text = tokIdent, rest[:size]
+	case strings.HasPrefix(rest, "**"):
+		t.kind, t.text, size = tokOp, "^", 2
+	case strings.ContainsRune("+-*/^", r):
+		t.kind, t.text = tokOp, rest[:1]
+	case r == '×' || r == '·':
+		t.kind, t.text = tokOp, "*"
+	case r == '÷':
+		t.kind, t.text = tokOp, "/"
+	case r == '(':
+		t.kind, t.text = tokLParen, "("
+	case r == ')':
+		t.kind, t.text = tokRParen, ")"
+	case r == ',':
+		t.kind, t.text = tokComma, ","
+	default:
+		// Report the character once the parser reaches it; until then it
+		// reads as the end of input.
+		p.err = &SyntaxError{p.off, "unexpected character"}
+		t.kind, size = tokEOF, 0
+	}
+	p.off += size
+	p.tok = t
+}
+
+// scanNumber returns the longest numeric literal at the start of s. An e is
+// only an exponent when digits follow, so 2e reads as 2 times the constant e.
+func scanNumber(s string) string {
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			i = j
+		}
+	}
+	return s[:i]
+}
+
+func (p *parser) enter() error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.depth++; p.depth > maxDepth {
+		return p.fail("expression is nested too deeply")
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+func (p *parser) isOp(ops string) bool {
+	return p.tok.kind == tokOp && strings.Contains(ops, p.tok.text)
+}
+
+// sum = product {("+" | "-") product}
+func (p *parser) sum() (Node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	n, err := p.product()
+	for err == nil && p.isOp("+-") {
+		op := p.tok.text[0]
+		p.next()
+		var r Node
+		if r, err = p.product(); err == nil {
+			n = &Binary{op, n, r}
+		}
+	}
+	return n, err
+}
+
+// product = unary {("*" | "/") unary | unary}, where the bare form is
+// implicit multiplication before a name or parenthesis.
+func (p *parser) product() (Node, error) {
+	n, err := p.unary()
+	for err == nil {
+		op := byte('*')
+		switch {
+		case p.isOp("*/"):
+			op = p.tok.text[0]
+			p.next()
+		case p.tok.kind == tokIdent || p.tok.kind == tokLParen:
+		default:
+			return n, p.lexErr()
+		}
+		var r Node
+		if r, err = p.unary(); err == nil {
+			n = &Binary{op, n, r}
+		}
+	}
+	return nil, err
+}
+
+// lexErr returns the pending lexer error, if any.
+func (p *parser) lexErr() error {
+	if p.err != nil {
+		return p.err
+	}
+	return nil
+}
+
+// unary = ("-" | "+") unary | power
+func (p *parser) unary() (Node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if p.isOp("+-") {
+		neg := p.tok.text == "-"
+		p.next()
+		x, err := p.unary()
+		if err != nil || !neg {
+			return x, err
+		}
+		return &Neg{x}, nil
+	}
+	return p.power()
+}
+
+// power = atom ["^" unary]
+func (p *parser) power() (Node, error) {
+	base, err := p.atom()
+	if err != nil || !p.isOp("^") {
+		return base, err
+	}
+	p.next()
+	exp, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &Binary{'^', base, exp}, nil
+}
+
+// atom = number | constant | variable | function "(" args ")" | "(" sum ")"
+func (p *parser) atom() (Node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	t := p.tok
+	switch t.kind {
+	case tokNum:
+		v, err := decimal.Parse(t.text)
+		if err != nil {
+			return nil, p.fail("malformed number")
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err != nil || math.IsInf(f, 0) {
+			return nil, p.fail("number is out of range")
+		}
+		p.next()
+		return &Num{v}, nil
+
+	case tokIdent:
+		p.next()
+		fn, isFunc := Funcs[t.text]
+		switch {
+		case isFunc && p.tok.kind == tokLParen:
+			return p.call(t, fn.Arity)
+		case isFunc:
+			return nil, &SyntaxError{t.pos, "function name must be followed by ("}
+		case p.tok.kind == tokLParen && p.tok.glued && utf8.RuneCountInString(t.text) > 1:
+			return nil, &SyntaxError{t.pos, "unknown function"}
+		}
+		return &Var{t.text}, nil
+
+	case tokLParen:
+		p.next()
+		n, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.fail("missing closing parenthesis")
+		}
+		p.next()
+		return n, nil
+	}
+	return nil, p.fail(t.unexpected())
+}
+
+func (p *parser) call(name token, arity int) (Node, error) {
+	p.next() // (
+	c := &Call{Fn: name.text}
+	for p.tok.kind != tokRParen {
+		if len(c.Args) > 0 {
+			if p.tok.kind != tokComma {
+				return nil, p.fail("missing closing parenthesis")
+			}
+			p.next()
+		}
+		a, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		c.Args = append(c.Args, a)
+	}
+	p.next()
+	if len(c.Args) != arity {
+		return nil, &SyntaxError{name.pos, "wrong number of arguments"}
+	}
+	return c, nil
+}
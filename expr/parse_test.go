@@ -0,0 +1,141 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) Node {
+	t.Helper()
+	n, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return n
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"1 + 2*3", "1 + 2*3"},
+		{"(1 + 2)*3", "(1 + 2)*3"},
+		{"2x^2", "2*x^2"},
+		{"-x^2", "-x^2"},
+		{"(-x)^2", "(-x)^2"},
+		{"2^3^2", "2^3^2"},
+		{"(2^3)^2", "(2^3)^2"},
+		{"x ** 2", "x^2"},
+		{"a - (b - c)", "a - (b - c)"},
+		{"a / (b * c)", "a/(b*c)"},
+		{"3(x + 1)(x - 1)", "3*(x + 1)*(x - 1)"},
+		{"2 sin(x)", "2*sin(x)"},
+		{"2e", "2*e"},
+		{"2e3", "2000"},
+		{"x^-1", "x^(-1)"},
+		{"6 ÷ 2 × π", "6/2*pi"},
+		{"x(x + 1)", "x*(x + 1)"},
+		{"0.125", "0.125"},
+	}
+	for _, tc := range cases {
+		if got := mustParse(t, tc.in).String(); got != tc.want {
+			t.Errorf("Parse(%q) = %s; want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []struct {
+		in     string
+		msg    string
+		offset int
+	}{
+		{"", "unexpected end of expression", 0},
+		{"1 +", "unexpected end of expression", 3},
+		{"(1 + 2", "missing closing parenthesis", 6},
+		{"1 + 2)", "unexpected symbol", 5},
+		{"2 $ 3", "unexpected character", 2},
+		{"foo(2)", "unknown function", 0},
+		{"sin x", "function name must be followed by (", 0},
+		{"sin(1, 2)", "wrong number of arguments", 0},
+		{"1.2.3", "malformed number", 0},
+		{"1e999", "number is out of range", 0},
+		{"2 3", "unexpected number", 2},
+	}
+	for _, tc := range cases {
+		_, err := Parse(tc.in)
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Msg != tc.msg || se.Offset != tc.offset {
+			t.Errorf("Parse(%q) error = %v; want %q at %d", tc.in, err, tc.msg, tc.offset)
+		}
+	}
+
+	deep := ""
+	for range maxDepth + 1 {
+		deep += "("
+	}
+	if _, err := Parse(deep + "1"); err == nil || err.(*SyntaxError).Msg != "expression is nested too deeply" {
+		t.Errorf("deep nesting: %v", err)
+	}
+}
+
+// TestString_RoundTrip checks that printed expressions parse back to the
+// same value.
+func TestString_RoundTrip(t *testing.T) {
+	vars := map[string]float64{"x": 0.7, "y": -1.3}
+	for _, s := range []string{
+		"x - (y - 1)", "x/(y/2)", "-(x + y)^2", "(-2)^3", "x^y^2", "2/3*x",
+		"-x*-y", "1/(x - y)", "x - -y", "sin(x)^2/cos(y)",
+	} {
+		n := mustParse(t, s)
+		back := mustParse(t, n.String())
+		a, err1 := Eval(n, vars)
+		b, err2 := Eval(back, vars)
+		if err1 != nil || err2 != nil || a != b {
+			t.Errorf("%q printed as %q: %v, %v vs %v, %v", s, n, a, err1, b, err2)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{"x": 2, "y": 3}
+	for in, want := range map[string]float64{
+		"x^3 - 2x - 5":     -1,
+		"-x^2":             -4,
+		"2^3^2":            512,
+		"sqrt(x*8)":        4,
+		"ln(e^y)":          3,
+		"log(1000)":        3,
+		"cos(pi)":          -1,
+		"abs(x - y)":       1,
+		"y/x + 1/2":        2,
+		"exp(0) + sinh(0)": 1,
+	} {
+		got, err := Eval(mustParse(t, in), vars)
+		if err != nil || math.Abs(got-want) > 1e-12 {
+			t.Errorf("Eval(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for in, kind := range map[string]string{
+		"1/(x - 2)":  "calculation_error",
+		"sqrt(-x)":   "domain_error",
+		"ln(0)":      "domain_error",
+		"(-8)^0.5":   "domain_error",
+		"10^400":     "overflow",
+		"x + z":      "calculation_error",
+		"asin(x)":    "domain_error",
+		"0^(-1)":     "calculation_error",
+		"exp(1000)":  "overflow",
+		"x^2 * w(2)": "calculation_error",
+	} {
+		n, err := Parse(in)
+		if err == nil {
+			_, err = Eval(n, vars)
+		}
+		if ce := asCalcError(err); ce == nil || string(ce.Kind) != kind {
+			t.Errorf("Eval(%q) error = %v; want %s", in, err, kind)
+		}
+	}
+}
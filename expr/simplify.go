@@ -0,0 +1,424 @@
+package expr
+
+import (
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+// Limits on exact folding of powers of literals, so 10^100000 stays symbolic
+// instead of becoming a huge rational.
+const (
+	maxFoldExponent = 64
+	maxFoldBits     = 4096
+)
+
+// Simplify returns an equivalent expression with constants folded, like
+// terms collected (x + 2x is 3x, x*x^2 is x^3) and identities removed (x+0,
+// 1*x, x^1, x^0). Folding is exact; function calls fold only when the result
+// is an integer, so sqrt(4) becomes 2 but sqrt(2) stays. Cancelling x/x to 1
+// assumes x is non-zero, as is usual in symbolic algebra.
+func Simplify(n Node) Node {
+	// A pass can expose new opportunities to the next, e.g. when folding a
+	// call to an integer lets it join a coefficient.
+	s := n.String()
+	for range 4 {
+		n = simplify(n)
+		t := n.String()
+		if t == s {
+			break
+		}
+		s = t
+	}
+	return n
+}
+
+func simplify(n Node) Node {
+	switch n := n.(type) {
+	case *Neg:
+		x := simplify(n.X)
+		if c, fs, ok := collect(x); ok {
+			return buildProduct(c.Neg(c), fs)
+		}
+		return &Neg{x}
+	case *Binary:
+		l, r := simplify(n.L), simplify(n.R)
+		switch n.Op {
+		case '+', '-':
+			return simplifySum(&Binary{n.Op, l, r})
+		case '*', '/':
+			b := &Binary{n.Op, l, r}
+			if c, fs, ok := collect(b); ok {
+				return buildProduct(c, fs)
+			}
+			return b
+		}
+		return simplifyPow(l, r)
+	case *Call:
+		c := &Call{Fn: n.Fn, Args: make([]Node, len(n.Args))}
+		for i, a := range n.Args {
+			c.Args[i] = simplify(a)
+		}
+		return foldCall(c)
+	}
+	return n
+}
+
+// factor is base^exp within a product.
+type factor struct {
+	base Node
+	exp  *big.Rat
+}
+
+// collect flattens a product or quotient into a rational coefficient and
+// factors with like bases merged. It fails on a literal zero divisor, which
+// is left for evaluation to report.
+func collect(n Node) (*big.Rat, []factor, bool) {
+	coef := big.NewRat(1, 1)
+	var fs []factor
+	ok := true
+	var visit func(n Node, inverse bool)
+	visit = func(n Node, inverse bool) {
+		switch x := n.(type) {
+		case *Num:
+			if !inverse {
+				coef.Mul(coef, x.Value)
+			} else if x.Value.Sign() == 0 {
+				ok = false
+			} else {
+				coef.Quo(coef, x.Value)
+			}
+			return
+		case *Neg:
+			coef.Neg(coef)
+			visit(x.X, inverse)
+			return
+		case *Binary:
+			switch x.Op {
+			case '*':
+				visit(x.L, inverse)
+				visit(x.R, inverse)
+				return
+			case '/':
+				visit(x.L, inverse)
+				visit(x.R, !inverse)
+				return
+			case '^':
+				if e, isNum := x.R.(*Num); isNum {
+					fs = appendFactor(fs, x.L, e.Value, inverse)
+					return
+				}
+			}
+		}
+		fs = appendFactor(fs, n, big.NewRat(1, 1), inverse)
+	}
+	visit(n, false)
+	if !ok {
+		return nil, nil, false
+	}
+
+	// Drop cancelled factors and fold literal bases with integer exponents.
+	out := fs[:0]
+	for _, f := range fs {
+		if f.exp.Sign() == 0 {
+			continue
+		}
+		if b, isNum := f.base.(*Num); isNum {
+			if v, folded := ratPow(b.Value, f.exp); folded {
+				coef.Mul(coef, v)
+				continue
+			}
+		}
+		out = append(out, f)
+	}
+	// Variables lead and function calls trail, as in 2*t*cos(t^2).
+	slices.SortStableFunc(out, func(a, b factor) int { return factorRank(a.base) - factorRank(b.base) })
+	return coef, out, true
+}
+
+func factorRank(n Node) int {
+	switch n.(type) {
+	case *Var:
+		return 0
+	case *Call:
+		return 2
+	}
+	return 1
+}
+
+// appendFactor adds base^exp to fs, merging it with an equal base.
+func appendFactor(fs []factor, base Node, exp *big.Rat, inverse bool) []factor {
+	e := new(big.Rat).Set(exp)
+	if inverse {
+		e.Neg(e)
+	}
+	key := base.String()
+	for i := range fs {
+		if fs[i].base.String() == key {
+			fs[i].exp.Add(fs[i].exp, e)
+			return fs
+		}
+	}
+	return append(fs, factor{base, e})
+}
+
+// buildProduct rebuilds coef·∏factors as coefficient times numerator over
+// denominator, e.g. -2*x^2/(3*y). A lone coefficient stays a single literal.
+func buildProduct(coef *big.Rat, fs []factor) Node {
+	if coef.Sign() == 0 || len(fs) == 0 {
+		return &Num{coef}
+	}
+	var top, bottom []Node
+	if p := new(big.Int).Abs(coef.Num()); !p.IsInt64() || p.Int64() != 1 {
+		top = append(top, &Num{new(big.Rat).SetInt(p)})
+	}
+	if q := coef.Denom(); !q.IsInt64() || q.Int64() != 1 {
+		bottom = append(bottom, &Num{new(big.Rat).SetInt(q)})
+	}
+	for _, f := range fs {
+		e := new(big.Rat).Abs(f.exp)
+		if f.exp.Sign() > 0 {
+			top = append(top, power(f.base, e))
+		} else {
+			bottom = append(bottom, power(f.base, e))
+		}
+	}
+	if len(top) == 0 {
+		top = append(top, num(1))
+	}
+	n := chain('*', top)
+	if len(bottom) > 0 {
+		n = &Binary{'/', n, chain('*', bottom)}
+	}
+	if coef.Sign() < 0 {
+		return &Neg{n}
+	}
+	return n
+}
+
+func power(base Node, exp *big.Rat) Node {
+	if exp.Cmp(big.NewRat(1, 1)) == 0 {
+		return base
+	}
+	return &Binary{'^', base, &Num{exp}}
+}
+
+// chain joins ns with op, left to right.
+func chain(op byte, ns []Node) Node {
+	n := ns[0]
+	for _, m := range ns[1:] {
+		n = &Binary{op, n, m}
+	}
+	return n
+}
+
+// term is coef times the product of factors.
+type term struct {
+	coef *big.Rat
+	fs   []factor
+	key  string
+}
+
+// simplifySum collects like terms of a sum or difference; the constant term
+// goes last.
+func simplifySum(n Node) Node {
+	var terms []*term
+	constant := new(big.Rat)
+	var visit func(n Node, neg bool)
+	visit = func(n Node, neg bool) {
+		if b, ok := n.(*Binary); ok && (b.Op == '+' || b.Op == '-') {
+			visit(b.L, neg)
+			visit(b.R, neg != (b.Op == '-'))
+			return
+		}
+		if x, ok := n.(*Neg); ok {
+			visit(x.X, !neg)
+			return
+		}
+		c, fs, ok := collect(n)
+		if !ok {
+			c, fs = big.NewRat(1, 1), []factor{{n, big.NewRat(1, 1)}}
+		}
+		if neg {
+			c.Neg(c)
+		}
+		if len(fs) == 0 {
+			constant.Add(constant, c)
+			return
+		}
+		key := termKey(fs)
+		for _, t := range terms {
+			if t.key == key {
+				t.coef.Add(t.coef, c)
+				return
+			}
+		}
+		terms = append(terms, &term{c, fs, key})
+	}
+	visit(n, false)
+
+	if constant.Sign() != 0 {
+		terms = append(terms, &term{coef: constant})
+	}
+	// Lead with a positive term where there is one: 1 - x rather than -x + 1.
+	for i, t := range terms {
+		if t.coef.Sign() > 0 {
+			terms = append(append([]*term{t}, terms[:i]...), terms[i+1:]...)
+			break
+		}
+	}
+
+	var out Node
+	add := func(coef *big.Rat, fs []factor) {
+		if coef.Sign() == 0 {
+			return
+		}
+		if out == nil {
+			out = buildProduct(coef, fs)
+			return
+		}
+		op := byte('+')
+		if coef.Sign() < 0 {
+			op = '-'
+		}
+		out = &Binary{op, out, buildProduct(new(big.Rat).Abs(coef), fs)}
+	}
+	for _, t := range terms {
+		add(t.coef, t.fs)
+	}
+	if out == nil {
+		return num(0)
+	}
+	return out
+}
+
+// termKey identifies like terms regardless of factor order, so x*y and y*x
+// combine.
+func termKey(fs []factor) string {
+	keys := make([]string, len(fs))
+	for i, f := range fs {
+		keys[i] = f.base.String() + "^" + f.exp.String()
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, "*")
+}
+
+// simplifyPow removes trivial exponents and folds literal powers exactly.
+func simplifyPow(base, exp Node) Node {
+	switch {
+	case isNum(exp, 0):
+		return num(1)
+	case isNum(exp, 1):
+		return base
+	case isNum(base, 1):
+		return num(1)
+	}
+	e, expNum := exp.(*Num)
+	if !expNum {
+		return &Binary{'^', base, exp}
+	}
+	if b, ok := base.(*Num); ok {
+		if v, folded := ratPow(b.Value, e.Value); folded {
+			return &Num{v}
+		}
+	}
+	// For integer n, (x^a)^n = x^(a·n) and (c·x/y)^n = c^n·x^n/y^n.
+	if inner, ok := base.(*Binary); ok && e.Value.IsInt() {
+		switch inner.Op {
+		case '^':
+			if a, ok := inner.R.(*Num); ok {
+				return simplifyPow(inner.L, &Num{new(big.Rat).Mul(a.Value, e.Value)})
+			}
+		case '*', '/':
+			if c, fs, ok := collect(inner); ok {
+				if cn, folded := ratPow(c, e.Value); folded {
+					for i := range fs {
+						fs[i].exp.Mul(fs[i].exp, e.Value)
+					}
+					return buildProduct(cn, fs)
+				}
+			}
+		}
+	}
+	if c, fs, ok := collect(&Binary{'^', base, exp}); ok {
+		return buildProduct(c, fs)
+	}
+	return &Binary{'^', base, exp}
+}
+
+// ratPow returns b^e exactly when e is a small integer and the result stays
+// within maxFoldBits.
+func ratPow(b, e *big.Rat) (*big.Rat, bool) {
+	if !e.IsInt() || !e.Num().IsInt64() {
+		return nil, false
+	}
+	n := e.Num().Int64()
+	switch {
+	case n == 0:
+		return big.NewRat(1, 1), true
+	case b.Sign() == 0:
+		return new(big.Rat), n > 0
+	case n > maxFoldExponent || n < -maxFoldExponent:
+		return nil, false
+	case int64(max(b.Num().BitLen(), b.Denom().BitLen()))*abs64(n) > maxFoldBits:
+		return nil, false
+	}
+	num := new(big.Int).Exp(b.Num(), big.NewInt(abs64(n)), nil)
+	den := new(big.Int).Exp(b.Denom(), big.NewInt(abs64(n)), nil)
+	if n < 0 {
+		num, den = den, num
+	}
+	return new(big.Rat).SetFrac(num, den), true
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// foldCall evaluates a call when the result is exact: ln(e), abs of any
+// literal, sqrt of a perfect square, ln(1), log of a power of ten,
+// and functions at zero such as cos(0).
+func foldCall(c *Call) Node {
+	if v, ok := c.Args[0].(*Var); ok && v.Name == "e" && c.Fn == "ln" {
+		return num(1)
+	}
+	x, ok := c.Args[0].(*Num)
+	if !ok {
+		return c
+	}
+	switch c.Fn {
+	case "abs":
+		return &Num{new(big.Rat).Abs(x.Value)}
+	case "sqrt":
+		if x.Value.Sign() < 0 {
+			return c
+		}
+		p, q := new(big.Int).Sqrt(x.Value.Num()), new(big.Int).Sqrt(x.Value.Denom())
+		r := new(big.Rat).SetFrac(p, q)
+		if new(big.Rat).Mul(r, r).Cmp(x.Value) == 0 {
+			return &Num{r}
+		}
+	case "ln":
+		if isNum(x, 1) {
+			return num(0)
+		}
+	case "log":
+		for k, p := int64(0), big.NewRat(1, 1); k <= 308 && p.Cmp(x.Value) <= 0; k, p = k+1, p.Mul(p, big.NewRat(10, 1)) {
+			if p.Cmp(x.Value) == 0 {
+				return num(k)
+			}
+		}
+	default:
+		if x.Value.Sign() != 0 {
+			return c
+		}
+		if v, err := Eval(c, nil); err == nil && v == math.Trunc(v) {
+			return num(int64(v))
+		}
+	}
+	return c
+}
@@ -0,0 +1,87 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func asCalcError(err error) *calculator.Error {
+	var ce *calculator.Error
+	if errors.As(err, &ce) {
+		return ce
+	}
+	return nil
+}
+
+// samples are the points at which rewritten expressions are compared with
+// the originals.
+var samples = []map[string]float64{
+	{"x": 0.3, "y": 1.7},
+	{"x": 1.9, "y": -0.4},
+	{"x": 2.5, "y": 3.1},
+	{"x": 0.05, "y": 0.9},
+}
+
+// sameValues reports whether a and b agree wherever a is defined.
+func sameValues(t *testing.T, a, b Node) {
+	t.Helper()
+	for _, vars := range samples {
+		want, err := Eval(a, vars)
+		if err != nil {
+			continue
+		}
+		got, err := Eval(b, vars)
+		if err != nil || math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+			t.Errorf("%s = %v at %v; %s = %v, %v", a, want, vars, b, got, err)
+		}
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"x + 0", "x"},
+		{"1*x", "x"},
+		{"x^1", "x"},
+		{"x^0", "1"},
+		{"0*sin(x)", "0"},
+		{"2 + 3*4 - 1", "13"},
+		{"1/3 + 1/6", "0.5"},
+		{"1/3 + 1/3", "2/3"},
+		{"0.1 + 0.2", "0.3"},
+		{"x + 2x - x/2", "5*x/2"},
+		{"x*x^2", "x^3"},
+		{"x*y - y*x", "0"},
+		{"x/x", "1"},
+		{"2*x*3*y", "6*x*y"},
+		{"(x/2)^2", "x^2/4"},
+		{"(x^2)^3", "x^6"},
+		{"--x", "x"},
+		{"-(2*x) + 3x", "x"},
+		{"x^-2", "1/x^2"},
+		{"sqrt(16) + sqrt(2)", "sqrt(2) + 4"},
+		{"log(1000) + ln(1) + cos(0) + abs(-2.5)", "6.5"},
+		{"ln(e)*x", "x"},
+		{"-x + 1", "1 - x"},
+		{"2^10", "1024"},
+		{"x/0", "x/0"},
+		{"10^100000", "10^100000"},
+	}
+	for _, tc := range cases {
+		n := mustParse(t, tc.in)
+		got := Simplify(n)
+		if got.String() != tc.want {
+			t.Errorf("Simplify(%q) = %s; want %s", tc.in, got, tc.want)
+		}
+		sameValues(t, n, got)
+		// Simplified output is a fixed point.
+		if again := Simplify(mustParse(t, got.String())).String(); again != got.String() {
+			t.Errorf("Simplify(%q) is not stable: %s then %s", tc.in, got, again)
+		}
+	}
+}
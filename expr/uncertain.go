@@ -0,0 +1,54 @@
+package expr
+
+import (
+	"errors"
+	"math"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// ErrNotDifferentiable is returned by EvalUncertain when a variable with an
+// uncertainty sits where the expression has no finite derivative, such as
+// sqrt(x) at x = 0; first-order propagation is undefined there.
+var ErrNotDifferentiable = &calculator.Error{Kind: calculator.KindDomain, Op: "propagate", Msg: "uncertainty cannot be propagated where the expression is not differentiable"}
+
+// EvalUncertain evaluates n with measured variable values and propagates
+// their standard uncertainties to first order, treating distinct variables as
+// independent: σ² = Σ (∂n/∂v·σv)². A variable used more than once, as in
+// x*x or x/sin(x), is correlated with itself through its derivative. Errors
+// are those of Eval, or ErrNotDifferentiable.
+func EvalUncertain(n Node, vars map[string]calculator.Uncertain) (calculator.Uncertain, error) {
+	values := make(map[string]float64, len(vars))
+	for name, u := range vars {
+		values[name] = u.Value
+	}
+	v, err := Eval(n, values)
+	if err != nil {
+		return calculator.Uncertain{}, err
+	}
+
+	var sigma float64
+	for _, name := range Vars(n) {
+		u := vars[name]
+		if u.Uncertainty == 0 {
+			continue
+		}
+		d, err := Derivative(n, name)
+		if err != nil {
+			return calculator.Uncertain{}, err
+		}
+		dv, err := Eval(d, values)
+		var ce *calculator.Error
+		switch {
+		case errors.As(err, &ce) && ce.Kind == calculator.KindOverflow:
+			return calculator.Uncertain{}, err
+		case err != nil:
+			return calculator.Uncertain{}, ErrNotDifferentiable
+		}
+		sigma = math.Hypot(sigma, dv*u.Uncertainty)
+	}
+	if math.IsInf(sigma, 0) {
+		return calculator.Uncertain{}, &calculator.Error{Kind: calculator.KindOverflow, Op: "propagate", Msg: "result overflows the float64 range"}
+	}
+	return calculator.Uncertain{Value: v, Uncertainty: sigma}, nil
+}
@@ -0,0 +1,63 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func TestEvalUncertain(t *testing.T) {
+	g := calculator.Uncertain{Value: 9.81, Uncertainty: 0.02}
+	l := calculator.Uncertain{Value: 2, Uncertainty: 0.01}
+	cases := []struct {
+		in   string
+		vars map[string]calculator.Uncertain
+		want calculator.Uncertain
+	}{
+		{"x + y", map[string]calculator.Uncertain{"x": {Value: 1, Uncertainty: 0.3}, "y": {Value: 2, Uncertainty: 0.4}}, calculator.Uncertain{Value: 3, Uncertainty: 0.5}},
+		// x*x is one measurement squared, not two independent ones.
+		{"x*x", map[string]calculator.Uncertain{"x": {Value: 3, Uncertainty: 0.1}}, calculator.Uncertain{Value: 9, Uncertainty: 0.6}},
+		{"sin(x)", map[string]calculator.Uncertain{"x": {Value: 0, Uncertainty: 0.01}}, calculator.Uncertain{Value: 0, Uncertainty: 0.01}},
+		{"ln(x)", map[string]calculator.Uncertain{"x": {Value: 4, Uncertainty: 0.2}}, calculator.Uncertain{Value: math.Log(4), Uncertainty: 0.05}},
+		{"exp(2x)", map[string]calculator.Uncertain{"x": {Value: 0, Uncertainty: 0.1}}, calculator.Uncertain{Value: 1, Uncertainty: 0.2}},
+		// Pendulum period T = 2π√(l/g).
+		{"2 pi sqrt(l/g)", map[string]calculator.Uncertain{"l": l, "g": g},
+			calculator.Uncertain{Value: 2 * math.Pi * math.Sqrt(2/9.81), Uncertainty: 2 * math.Pi * math.Sqrt(2/9.81) * math.Hypot(0.01/2, 0.02/9.81) / 2}},
+		{"x^2 + y", map[string]calculator.Uncertain{"x": {Value: 2}, "y": {Value: 1, Uncertainty: 0.1}}, calculator.Uncertain{Value: 5, Uncertainty: 0.1}},
+	}
+	for _, tc := range cases {
+		n, err := Parse(tc.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.in, err)
+		}
+		got, err := EvalUncertain(n, tc.vars)
+		if err != nil || math.Abs(got.Value-tc.want.Value) > 1e-12 || math.Abs(got.Uncertainty-tc.want.Uncertainty) > 1e-12 {
+			t.Errorf("%s = %+v, %v; want %+v", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestEvalUncertain_Errors(t *testing.T) {
+	cases := []struct {
+		in   string
+		vars map[string]calculator.Uncertain
+		want error
+	}{
+		{"sqrt(x)", map[string]calculator.Uncertain{"x": {Value: 0, Uncertainty: 0.1}}, ErrNotDifferentiable},
+		{"abs(x)", map[string]calculator.Uncertain{"x": {Value: 0, Uncertainty: 0.1}}, ErrNotDifferentiable},
+		{"1/x", map[string]calculator.Uncertain{"x": {Value: 0, Uncertainty: 0.1}}, divisionByZero},
+	}
+	for _, tc := range cases {
+		n, _ := Parse(tc.in)
+		if _, err := EvalUncertain(n, tc.vars); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v; want %v", tc.in, err, tc.want)
+		}
+	}
+	// Without an uncertainty the derivative is never needed.
+	n, _ := Parse("sqrt(x)")
+	if got, err := EvalUncertain(n, map[string]calculator.Uncertain{"x": {}}); err != nil || got != (calculator.Uncertain{}) {
+		t.Errorf("exact sqrt(0) = %+v, %v", got, err)
+	}
+}
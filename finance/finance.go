@@ -0,0 +1,161 @@
+// Package finance implements time-value-of-money functions, amortization,
+// compound interest and depreciation with decimal arithmetic on big.Rat.
+// Inputs are exact; powers and discount factors keep scale decimals, far
+// beyond any amount that is printed. Cash flows follow the spreadsheet sign convention: money paid out
+// is negative, money received is positive.
+package finance
+
+import (
+	"math"
+	"math/big"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/decimal"
+)
+
+// MaxPeriods bounds the number of periods and cash flows.
+const MaxPeriods = 20000
+
+// scale is the number of decimals kept in powers and discount factors; exact
+// arithmetic on a rate with many digits would grow without bound over
+// thousands of periods.
+const scale = 40
+
+// maxGrowthDigits bounds the magnitude of (1+rate)**n, and of its inverse,
+// to 10**±maxGrowthDigits.
+const maxGrowthDigits = 1000
+
+// When says whether payments fall at the end or the beginning of each period.
+type When int
+
+const (
+	End When = iota
+	Begin
+)
+
+var one = big.NewRat(1, 1)
+
+func domainError(op, field, msg string) error {
+	return &calculator.Error{Kind: calculator.KindDomain, Op: op, Field: field, Msg: msg}
+}
+
+// pow returns x**n for x >= 1 and n >= 0, rounding every product to scale
+// decimals. The relative error stays below n·10**-scale.
+func pow(x *big.Rat, n int) *big.Rat {
+	res := big.NewRat(1, 1)
+	base := decimal.Round(x, scale)
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			res = decimal.Round(res.Mul(res, base), scale)
+		}
+		if n > 1 {
+			base = decimal.Round(base.Mul(base, base), scale)
+		}
+	}
+	return res
+}
+
+// onePlus validates rate and returns 1+rate, checking that n periods at it
+// neither grow nor shrink a value beyond maxGrowthDigits.
+func onePlus(op string, rate *big.Rat, n int) (*big.Rat, error) {
+	x := new(big.Rat).Add(one, rate)
+	if x.Sign() <= 0 {
+		return nil, domainError(op, "rate", "rate must be greater than -1")
+	}
+	f, _ := x.Float64()
+	if math.Abs(float64(n)*math.Log10(f)) > maxGrowthDigits {
+		return nil, &calculator.Error{Kind: calculator.KindOverflow, Op: op, Field: "rate",
+			Msg: "rate compounds beyond 1e±1000 over the periods"}
+	}
+	return x, nil
+}
+
+// growth validates rate and n and returns (1+rate)**n. Shrinking factors are
+// computed as the inverse of a growing one so they keep their precision.
+func growth(op string, rate *big.Rat, n int) (*big.Rat, error) {
+	if n < 1 || n > MaxPeriods {
+		return nil, domainError(op, "periods", "periods must be between 1 and 20000")
+	}
+	x, err := onePlus(op, rate, n)
+	if err != nil {
+		return nil, err
+	}
+	if x.Cmp(one) >= 0 {
+		return pow(x, n), nil
+	}
+	return new(big.Rat).Inv(pow(new(big.Rat).Inv(x), n)), nil
+}
+
+// annuity returns (1+rate·when)·((1+rate)**n − 1)/rate, the value at the end
+// of n periods of payments of 1, or n when rate is zero.
+func annuity(rate, g *big.Rat, n int, when When) *big.Rat {
+	if rate.Sign() == 0 {
+		return big.NewRat(int64(n), 1)
+	}
+	f := new(big.Rat).Sub(g, one)
+	f.Quo(f, rate)
+	if when == Begin {
+		f.Mul(f, new(big.Rat).Add(one, rate))
+	}
+	return decimal.Round(f, scale)
+}
+
+// FV returns the future value of present value pv plus n payments of pmt at
+// rate per period.
+func FV(rate *big.Rat, n int, pmt, pv *big.Rat, when When) (*big.Rat, error) {
+	g, err := growth("fv", rate, n)
+	if err != nil {
+		return nil, err
+	}
+	fv := new(big.Rat).Mul(pv, g)
+	fv.Add(fv, new(big.Rat).Mul(pmt, annuity(rate, g, n, when)))
+	return fv.Neg(fv), nil
+}
+
+// PV returns the present value of n payments of pmt at rate per period plus a
+// final amount fv.
+func PV(rate *big.Rat, n int, pmt, fv *big.Rat, when When) (*big.Rat, error) {
+	g, err := growth("pv", rate, n)
+	if err != nil {
+		return nil, err
+	}
+	pv := new(big.Rat).Mul(pmt, annuity(rate, g, n, when))
+	pv.Add(pv, fv)
+	pv.Quo(pv, g)
+	return pv.Neg(pv), nil
+}
+
+// PMT returns the payment per period that pays off present value pv over n
+// periods at rate, leaving fv.
+func PMT(rate *big.Rat, n int, pv, fv *big.Rat, when When) (*big.Rat, error) {
+	g, err := growth("pmt", rate, n)
+	if err != nil {
+		return nil, err
+	}
+	owed := new(big.Rat).Mul(pv, g)
+	owed.Add(owed, fv)
+	owed.Quo(owed, annuity(rate, g, n, when))
+	return owed.Neg(owed), nil
+}
+
+// NPV returns the net present value of flows at rate per period. The first
+// flow occurs now and is not discounted.
+func NPV(rate *big.Rat, flows []*big.Rat) (*big.Rat, error) {
+	if len(flows) == 0 {
+		return nil, domainError("npv", "cashflows", "at least one cash flow is required")
+	}
+	if len(flows) > MaxPeriods {
+		return nil, domainError("npv", "cashflows", "too many cash flows")
+	}
+	x, err := onePlus("npv", rate, len(flows)-1)
+	if err != nil {
+		return nil, err
+	}
+	// Horner's scheme from the last flow back to the present.
+	d := decimal.Round(new(big.Rat).Inv(x), scale)
+	acc := new(big.Rat).Set(flows[len(flows)-1])
+	for i := len(flows) - 2; i >= 0; i-- {
+		acc = decimal.Round(acc.Mul(acc, d).Add(acc, flows[i]), scale)
+	}
+	return acc, nil
+}
@@ -0,0 +1,249 @@
+package finance
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/decimal"
+)
+
+func d(s string) *big.Rat {
+	r, err := decimal.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func flows(ss ...string) []*big.Rat {
+	out := make([]*big.Rat, len(ss))
+	for i, s := range ss {
+		out[i] = d(s)
+	}
+	return out
+}
+
+func TestTimeValue(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   func() (*big.Rat, error)
+		want string
+	}{
+		{"fv", func() (*big.Rat, error) { return FV(d("0.05"), 10, d("-100"), d("-1000"), End) }, "2886.68"},
+		{"fv begin", func() (*big.Rat, error) { return FV(d("0.05"), 10, d("-100"), d("0"), Begin) }, "1320.68"},
+		{"fv zero rate", func() (*big.Rat, error) { return FV(d("0"), 12, d("-10"), d("-5"), End) }, "125.00"},
+		{"pv", func() (*big.Rat, error) { return PV(d("0.08"), 20, d("500"), d("0"), End) }, "-4909.07"},
+		{"pmt", func() (*big.Rat, error) { return PMT(d("0.005"), 360, d("200000"), d("0"), End) }, "-1199.10"},
+		{"npv", func() (*big.Rat, error) { return NPV(d("0.1"), flows("-1000", "300", "400", "500")) }, "-21.04"},
+		// Many digits over many periods: powers are kept to scale decimals.
+		{"fv long", func() (*big.Rat, error) {
+			return FV(d("0.0000123456789123456789"), MaxPeriods, d("-1"), d("-1"), End)
+		}, "22686.67"},
+		{"pv shrinking", func() (*big.Rat, error) {
+			return PV(d("-0.0123456789123456789"), 100, d("0"), d("1"), End)
+		}, "-3.46"},
+	}
+	for _, tc := range cases {
+		got, err := tc.fn()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if s := decimal.Format(got, 2); s != tc.want {
+			t.Fatalf("%s = %s; want %s", tc.name, s, tc.want)
+		}
+	}
+}
+
+func TestTimeValueErrors(t *testing.T) {
+	for name, err := range map[string]error{
+		"periods": func() error { _, err := FV(d("0.1"), 0, d("1"), d("1"), End); return err }(),
+		"huge":    func() error { _, err := PV(d("0.1"), MaxPeriods+1, d("1"), d("1"), End); return err }(),
+		"rate":    func() error { _, err := PMT(d("-1"), 10, d("1"), d("0"), End); return err }(),
+		"empty":   func() error { _, err := NPV(d("0.1"), nil); return err }(),
+	} {
+		var ce *calculator.Error
+		if !errors.As(err, &ce) || ce.Kind != calculator.KindDomain {
+			t.Fatalf("%s: error = %v; want domain error", name, err)
+		}
+	}
+
+	// Growth beyond 1e±1000 is refused rather than computed.
+	for name, err := range map[string]error{
+		"growth":    func() error { _, err := FV(d("1"), 5000, d("0"), d("1"), End); return err }(),
+		"shrinking": func() error { _, err := PV(d("-0.99"), 600, d("0"), d("1"), End); return err }(),
+		"npv":       func() error { _, err := NPV(d("1e9"), slices.Repeat(flows("1"), 120)); return err }(),
+	} {
+		var ce *calculator.Error
+		if !errors.As(err, &ce) || ce.Kind != calculator.KindOverflow {
+			t.Fatalf("%s: error = %v; want overflow error", name, err)
+		}
+	}
+}
+
+func TestIRR(t *testing.T) {
+	r, err := IRR(context.Background(), flows("-100", "110"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Cmp(d("0.1")) != 0 {
+		t.Fatalf("IRR = %s; want 0.1", r.FloatString(20))
+	}
+
+	cf := flows("-1000", "300", "400", "500", "200")
+	r, err = IRR(context.Background(), cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	npv, _ := NPV(r, cf)
+	if new(big.Rat).Abs(npv).Cmp(d("1e-15")) > 0 {
+		t.Fatalf("NPV at IRR %s = %s; want ~0", r.FloatString(10), npv.FloatString(20))
+	}
+
+	// A bracket with one enormous end must not stall on the other.
+	long := []*big.Rat{d("-2500.3")}
+	for range 999 {
+		long = append(long, d("1.123456789123456789"))
+	}
+	r, err = IRR(context.Background(), long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	npv, _ = NPV(r, long)
+	if r.Sign() >= 0 || new(big.Rat).Abs(npv).Cmp(d("1e-9")) > 0 {
+		t.Fatalf("NPV at IRR %s = %s; want ~0", r.FloatString(10), npv.FloatString(20))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := IRR(ctx, cf); !errors.Is(err, context.Canceled) {
+		t.Fatalf("IRR error = %v; want context.Canceled", err)
+	}
+
+	if _, err := IRR(context.Background(), flows("100", "200")); err == nil {
+		t.Fatal("IRR without a payment succeeded")
+	}
+	// All flows on one side of zero over the whole grid: no root to bracket.
+	if _, err := IRR(context.Background(), flows("-1", "0.000001")); !errors.Is(err, ErrNoConvergence) {
+		t.Fatalf("IRR error = %v; want ErrNoConvergence", err)
+	}
+}
+
+func TestAmortize(t *testing.T) {
+	a, err := Amortize(context.Background(), d("1000"), d("0.01"), 12, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decimal.Format(a.Payment, 2); got != "88.85" {
+		t.Fatalf("payment = %s; want 88.85", got)
+	}
+	if len(a.Schedule) != 12 {
+		t.Fatalf("schedule has %d rows", len(a.Schedule))
+	}
+	first, last := a.Schedule[0], a.Schedule[11]
+	if decimal.Format(first.Interest, 2) != "10.00" || decimal.Format(first.Balance, 2) != "921.15" {
+		t.Fatalf("first row = %+v", first)
+	}
+	if last.Balance.Sign() != 0 {
+		t.Fatalf("final balance = %s", last.Balance.FloatString(2))
+	}
+	paid := new(big.Rat)
+	for _, p := range a.Schedule {
+		paid.Add(paid, p.Principal)
+	}
+	if paid.Cmp(d("1000")) != 0 {
+		t.Fatalf("principal repaid = %s", paid.FloatString(2))
+	}
+	if got := decimal.Format(a.TotalInterest, 2); got != "66.19" {
+		t.Fatalf("total interest = %s; want 66.19", got)
+	}
+}
+
+func TestCompound(t *testing.T) {
+	cases := []struct {
+		years   string
+		perYear int
+		want    string
+	}{
+		{"10", 1, "1628.89"},
+		{"10", 12, "1647.01"},
+		{"10", 0, "1648.72"},
+		{"0.5", 2, "1025.00"},
+		{"0", 4, "1000.00"},
+	}
+	for _, tc := range cases {
+		got, err := Compound(context.Background(), d("1000"), d("0.05"), d(tc.years), tc.perYear)
+		if err != nil {
+			t.Fatalf("Compound(%s, %d): %v", tc.years, tc.perYear, err)
+		}
+		if s := decimal.Format(got, 2); s != tc.want {
+			t.Fatalf("Compound(%s, %d) = %s; want %s", tc.years, tc.perYear, s, tc.want)
+		}
+	}
+	if _, err := Compound(context.Background(), d("1000"), d("0.05"), d("0.3"), 1); err == nil {
+		t.Fatal("fractional period succeeded")
+	}
+	for _, x := range []string{"1001", "-1001", "-1e10000"} {
+		if _, err := Compound(context.Background(), d("1"), d(x), d("1"), 0); err == nil {
+			t.Fatalf("continuous Compound at rate %s succeeded", x)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Compound(ctx, d("1000"), d("-1000"), d("1"), 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Compound with a canceled context: %v", err)
+	}
+	e, err := exp(context.Background(), d("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decimal.Format(e, 30); got != "2.718281828459045235360287471353" {
+		t.Fatalf("exp(1) = %s", got)
+	}
+}
+
+func TestDepreciate(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		method string
+		want   []string
+	}{
+		{StraightLine, []string{"1800", "1800", "1800", "1800", "1800"}},
+		{DecliningBalance, []string{"4000", "2400", "1440", "864", "296"}},
+		{SumOfYearsDigits, []string{"3000", "2400", "1800", "1200", "600"}},
+	}
+	for _, tc := range cases {
+		rows, err := Depreciate(ctx, tc.method, d("10000"), d("1000"), 5, d("2"), 2)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.method, err)
+		}
+		for i, row := range rows {
+			if row.Expense.Cmp(d(tc.want[i])) != 0 {
+				t.Fatalf("%s year %d = %s; want %s", tc.method, i+1, row.Expense.FloatString(2), tc.want[i])
+			}
+		}
+		if last := rows[len(rows)-1]; last.BookValue.Cmp(d("1000")) != 0 || last.Accumulated.Cmp(d("9000")) != 0 {
+			t.Fatalf("%s ends at book %s", tc.method, last.BookValue.FloatString(2))
+		}
+	}
+
+	// Straight line with a repeating share: rounding is absorbed by the last year.
+	rows, _ := Depreciate(ctx, StraightLine, d("100"), d("0"), 3, nil, 2)
+	if rows[2].Expense.Cmp(d("33.34")) != 0 {
+		t.Fatalf("last year = %s; want 33.34", rows[2].Expense.FloatString(2))
+	}
+
+	for name, err := range map[string]error{
+		"method":  func() error { _, err := Depreciate(ctx, "sideways", d("1"), d("0"), 1, nil, 2); return err }(),
+		"life":    func() error { _, err := Depreciate(ctx, StraightLine, d("1"), d("0"), 0, nil, 2); return err }(),
+		"salvage": func() error { _, err := Depreciate(ctx, StraightLine, d("1"), d("2"), 1, nil, 2); return err }(),
+	} {
+		var ce *calculator.Error
+		if !errors.As(err, &ce) || ce.Field != name {
+			t.Fatalf("%s: error = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package finance
+
+import (
+	"context"
+	"math/big"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/decimal"
+)
+
+// irrScale is the number of decimals kept in intermediate IRR values; exact
+// arithmetic would grow without bound across iterations.
+const irrScale = 40
+
+// irrGrid brackets the search for a sign change of the NPV, from -99% to 1000%.
+var irrGrid = []string{"-0.99", "-0.9", "-0.5", "-0.2", "-0.1", "0", "0.01", "0.05", "0.1", "0.2", "0.3", "0.5", "0.75", "1", "2", "5", "10"}
+
+const irrMaxIter = 500
+
+// ErrNoConvergence is returned when IRR finds no rate with a zero NPV.
+var ErrNoConvergence = &calculator.Error{Kind: calculator.KindCalculation, Op: "irr", Field: "cashflows", Msg: "irr did not converge"}
+
+// IRR returns the rate per period at which the NPV of flows is zero, rounded
+// to 20 decimals. The root is bracketed on a fixed grid and refined with the
+// Illinois variant of regula falsi, which always converges once bracketed;
+// a step that rounds onto the bracket falls back to bisection. With several
+// sign changes in flows, the lowest rate on the grid is returned. IRR stops
+// with ctx's error once it is done.
+func IRR(ctx context.Context, flows []*big.Rat) (*big.Rat, error) {
+	if len(flows) > MaxPeriods {
+		return nil, domainError("irr", "cashflows", "too many cash flows")
+	}
+	var pos, neg bool
+	for _, f := range flows {
+		pos = pos || f.Sign() > 0
+		neg = neg || f.Sign() < 0
+	}
+	if !pos || !neg {
+		return nil, domainError("irr", "cashflows", "cash flows must include both a payment and a receipt")
+	}
+
+	npv := func(r *big.Rat) *big.Rat {
+		d := decimal.Round(new(big.Rat).Inv(new(big.Rat).Add(one, r)), irrScale)
+		acc := new(big.Rat).Set(flows[len(flows)-1])
+		for i := len(flows) - 2; i >= 0; i-- {
+			acc = decimal.Round(acc.Mul(acc, d).Add(acc, flows[i]), irrScale)
+		}
+		return acc
+	}
+
+	var lo, hi, flo, fhi *big.Rat
+	for _, s := range irrGrid {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, _ := decimal.Parse(s)
+		f := npv(r)
+		if f.Sign() == 0 {
+			return r, nil
+		}
+		if flo != nil && f.Sign() != flo.Sign() {
+			hi, fhi = r, f
+			break
+		}
+		lo, flo = r, f
+	}
+	if hi == nil {
+		return nil, ErrNoConvergence
+	}
+
+	tol := new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(22), nil))
+	side := 0
+	for range irrMaxIter {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// x = hi - fhi·(hi-lo)/(fhi-flo)
+		x := new(big.Rat).Sub(hi, lo)
+		x.Mul(x, fhi)
+		x.Quo(x, new(big.Rat).Sub(fhi, flo))
+		x = decimal.Round(x.Sub(hi, x), irrScale)
+		if x.Cmp(lo) == 0 || x.Cmp(hi) == 0 {
+			// A lopsided bracket; regula falsi would stall on the endpoint.
+			x = decimal.Round(x.Add(lo, hi).Quo(x, big.NewRat(2, 1)), irrScale)
+			side = 0
+		}
+		fx := npv(x)
+
+		switch {
+		case fx.Sign() == 0:
+			return decimal.Round(x, 20), nil
+		case fx.Sign() == fhi.Sign():
+			hi, fhi = x, fx
+			if side == -1 {
+				flo = new(big.Rat).Quo(flo, big.NewRat(2, 1))
+			}
+			side = -1
+		default:
+			lo, flo = x, fx
+			if side == 1 {
+				fhi = new(big.Rat).Quo(fhi, big.NewRat(2, 1))
+			}
+			side = 1
+		}
+		// Stop once the bracket is below tol.
+		if new(big.Rat).Abs(new(big.Rat).Sub(hi, lo)).Cmp(tol) < 0 {
+			return decimal.Round(x, 20), nil
+		}
+	}
+	return nil, ErrNoConvergence
+}
@@ -0,0 +1,202 @@
+package finance
+
+import (
+	"context"
+	"math/big"
+
+	"erikkruuse/calculator/decimal"
+)
+
+// Payment is one row of an amortization schedule.
+type Payment struct {
+	Period    int
+	Payment   *big.Rat
+	Interest  *big.Rat
+	Principal *big.Rat
+	Balance   *big.Rat
+}
+
+// Amortization is a loan repayment plan with level payments.
+type Amortization struct {
+	Payment       *big.Rat // regular payment; the last one may differ by rounding
+	TotalInterest *big.Rat
+	Schedule      []Payment
+}
+
+// Amortize builds the schedule for repaying principal over n periods at rate
+// per period with payments at period end. Every amount is rounded to places
+// decimals as it would be on a statement, and the final payment absorbs the
+// accumulated rounding so the balance ends at exactly zero. Amortize stops
+// with ctx's error once it is done.
+func Amortize(ctx context.Context, principal, rate *big.Rat, n, places int) (Amortization, error) {
+	if principal.Sign() <= 0 {
+		return Amortization{}, domainError("amortization", "principal", "principal must be positive")
+	}
+	pmt, err := PMT(rate, n, principal, new(big.Rat), End)
+	if err != nil {
+		return Amortization{}, err
+	}
+	payment := decimal.Round(pmt.Neg(pmt), places)
+
+	out := Amortization{Payment: payment, TotalInterest: new(big.Rat), Schedule: make([]Payment, 0, n)}
+	balance := new(big.Rat).Set(principal)
+	for k := 1; k <= n; k++ {
+		if err := ctx.Err(); err != nil {
+			return Amortization{}, err
+		}
+		interest := decimal.Round(new(big.Rat).Mul(balance, rate), places)
+		pay := new(big.Rat).Set(payment)
+		princ := new(big.Rat).Sub(pay, interest)
+		if k == n {
+			princ.Set(balance)
+			pay.Add(princ, interest)
+		}
+		balance = new(big.Rat).Sub(balance, princ)
+		out.TotalInterest.Add(out.TotalInterest, interest)
+		out.Schedule = append(out.Schedule, Payment{Period: k, Payment: pay, Interest: interest, Principal: princ, Balance: balance})
+	}
+	return out, nil
+}
+
+// expScale is the number of decimals kept while computing e**x.
+const expScale = 40
+
+// maxExpArg bounds |x| in exp, keeping e**x within 1e±435.
+const maxExpArg = 1000
+
+// exp returns e**x to expScale decimals: x is halved until it is below 1/2,
+// the Taylor series is summed, and the result squared back up. It stops with
+// ctx's error once it is done.
+func exp(ctx context.Context, x *big.Rat) (*big.Rat, error) {
+	half := big.NewRat(1, 2)
+	k := 0
+	r := new(big.Rat).Set(x)
+	for new(big.Rat).Abs(r).Cmp(half) > 0 {
+		r.Quo(r, big.NewRat(2, 1))
+		k++
+	}
+	eps := new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(expScale+5), nil))
+	sum, term := big.NewRat(1, 1), big.NewRat(1, 1)
+	for i := int64(1); new(big.Rat).Abs(term).Cmp(eps) > 0; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		term = decimal.Round(term.Mul(term, r).Quo(term, big.NewRat(i, 1)), expScale+10)
+		sum.Add(sum, term)
+	}
+	for range k {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		sum = decimal.Round(sum.Mul(sum, sum), expScale+10)
+	}
+	return decimal.Round(sum, expScale), nil
+}
+
+// Compound returns principal grown at the nominal annual rate for years,
+// compounded perYear times a year, or continuously when perYear is zero.
+// years·perYear must be a whole number of periods, and rate·years at most
+// 1000 in magnitude when compounding continuously. Compound stops with ctx's
+// error once it is done.
+func Compound(ctx context.Context, principal, rate, years *big.Rat, perYear int) (*big.Rat, error) {
+	if years.Sign() < 0 {
+		return nil, domainError("compound", "years", "years must not be negative")
+	}
+	if perYear < 0 {
+		return nil, domainError("compound", "compounding", "compounding must be continuous or a positive number of periods per year")
+	}
+	if perYear == 0 {
+		x := new(big.Rat).Mul(rate, years)
+		if new(big.Rat).Abs(x).Cmp(big.NewRat(maxExpArg, 1)) > 0 {
+			return nil, domainError("compound", "rate", "rate × years must be between -1000 and 1000 for continuous compounding")
+		}
+		e, err := exp(ctx, x)
+		if err != nil {
+			return nil, err
+		}
+		return e.Mul(e, principal), nil
+	}
+
+	periods := new(big.Rat).Mul(years, big.NewRat(int64(perYear), 1))
+	if !periods.IsInt() {
+		return nil, domainError("compound", "years", "years × compounding must be a whole number of periods")
+	}
+	if periods.Sign() == 0 {
+		return new(big.Rat).Set(principal), nil
+	}
+	if !periods.Num().IsInt64() || periods.Num().Int64() > MaxPeriods {
+		return nil, domainError("compound", "periods", "periods must be between 1 and 20000")
+	}
+	g, err := growth("compound", new(big.Rat).Quo(rate, big.NewRat(int64(perYear), 1)), int(periods.Num().Int64()))
+	if err != nil {
+		return nil, err
+	}
+	return g.Mul(g, principal), nil
+}
+
+// Depreciation methods accepted by Depreciate.
+const (
+	StraightLine       = "straight_line"
+	DecliningBalance   = "declining_balance"
+	SumOfYearsDigits   = "sum_of_years_digits"
+	maxDepreciableLife = 1000
+)
+
+// Depreciation is one year of a depreciation schedule.
+type Depreciation struct {
+	Period      int
+	Expense     *big.Rat
+	Accumulated *big.Rat
+	BookValue   *big.Rat
+}
+
+// Depreciate returns the yearly schedule of an asset bought for cost with the
+// given salvage value and useful life in years. Declining balance applies
+// factor/life to the book value each year (factor 2 is double declining) and
+// never depreciates below salvage; the other methods end at salvage exactly.
+// Depreciate stops with ctx's error once it is done.
+func Depreciate(ctx context.Context, method string, cost, salvage *big.Rat, life int, factor *big.Rat, places int) ([]Depreciation, error) {
+	const op = "depreciation"
+	switch {
+	case life < 1 || life > maxDepreciableLife:
+		return nil, domainError(op, "life", "life must be between 1 and 1000 years")
+	case salvage.Sign() < 0 || salvage.Cmp(cost) > 0:
+		return nil, domainError(op, "salvage", "salvage must be between 0 and cost")
+	case method == DecliningBalance && factor.Sign() <= 0:
+		return nil, domainError(op, "factor", "factor must be positive")
+	}
+
+	base := new(big.Rat).Sub(cost, salvage)
+	syd := big.NewRat(int64(life*(life+1)/2), 1)
+	book := new(big.Rat).Set(cost)
+	acc := new(big.Rat)
+	out := make([]Depreciation, 0, life)
+	for k := 1; k <= life; k++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var dep *big.Rat
+		switch method {
+		case StraightLine:
+			dep = new(big.Rat).Quo(base, big.NewRat(int64(life), 1))
+		case SumOfYearsDigits:
+			dep = new(big.Rat).Mul(base, big.NewRat(int64(life-k+1), 1))
+			dep.Quo(dep, syd)
+		case DecliningBalance:
+			dep = new(big.Rat).Mul(book, factor)
+			dep.Quo(dep, big.NewRat(int64(life), 1))
+		default:
+			return nil, domainError(op, "method", "method must be straight_line, declining_balance or sum_of_years_digits")
+		}
+		dep = decimal.Round(dep, places)
+
+		remaining := new(big.Rat).Sub(book, salvage)
+		if dep.Cmp(remaining) > 0 || (k == life && method != DecliningBalance) {
+			dep = remaining
+		}
+		book = new(big.Rat).Sub(book, dep)
+		acc = new(big.Rat).Add(acc, dep)
+		out = append(out, Depreciation{Period: k, Expense: dep, Accumulated: acc, BookValue: book})
+	}
+	return out, nil
+}
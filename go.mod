@@ -1,3 +1,14 @@
 module erikkruuse/calculator
 
 go 1.25.3
+
+require (
+	github.com/fxamacker/cbor/v2 v2.9.4
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/text v0.30.0
+)
+
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+)
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"erikkruuse/calculator/calculator"
 	service "erikkruuse/calculator/internal/services"
 	"net/http"
 	"strconv"
@@ -16,19 +18,75 @@ func New(svc service.CalculatorService) *API {
 	return &API{svc: svc}
 }
 
+// TenantHeader selects the tenant whose history a request reads and writes.
+// The ID is asserted by the caller and not authenticated: any client may name
+// any tenant. It partitions history and retention, not access; deployments
+// that need isolation must set or strip it at a trusted proxy.
+const TenantHeader = "X-Tenant-ID"
+
+// svcFor returns the service view for the request's tenant, bypassing the
+// result cache when the client sends Cache-Control: no-cache.
+func (a *API) svcFor(r *http.Request) service.CalculatorService {
+	svc := a.svc
+	if tenant := strings.TrimSpace(r.Header.Get(TenantHeader)); tenant != "" {
+		svc = svc.ForTenant(tenant)
+	}
+	if noCache(r) {
+		svc = svc.WithoutCache()
+	}
+	return svc
+}
+
+func noCache(r *http.Request) bool {
+	for _, v := range r.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RegisterRoutes mounts the API on mux. Every route negotiates its request and
+// response media types through Codecs.
 func (a *API) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, Negotiated(Codecs, h))
+	}
+
+	handle("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, http.StatusOK, map[string]string{"status": "ok"})
 	})
 
-	mux.HandleFunc("GET /v1/history", a.getHistory)
-	mux.HandleFunc("DELETE /v1/history", a.clearHistory)
+	handle("GET /v1/metrics", a.getMetrics)
+
+	handle("GET /v1/history", a.getHistory)
+	handle("DELETE /v1/history", a.clearHistory)
+	handle("POST /v1/history/replay", a.replayHistory)
 
-	mux.HandleFunc("GET /v1/calculate", a.calculateQuery)
-	mux.HandleFunc("POST /v1/add", a.binaryOp(func(a1, b1 float64) (float64, error) { return a.svc.Add(a1, b1), nil }))
-	mux.HandleFunc("POST /v1/subtract", a.binaryOp(func(a1, b1 float64) (float64, error) { return a.svc.Subtract(a1, b1), nil }))
-	mux.HandleFunc("POST /v1/multiply", a.binaryOp(func(a1, b1 float64) (float64, error) { return a.svc.Multiply(a1, b1), nil }))
-	mux.HandleFunc("POST /v1/divide", a.binaryOp(a.svc.Divide))
+	handle("GET /v1/calculate", a.calculateQuery)
+	handle("GET /v1/operations", a.listOperations)
+	handle("GET /v1/openapi.json", a.openAPI)
+	// Registered operations; the fixed routes below take precedence, and
+	// the registry refuses their names (see calculator.reservedNames).
+	handle("POST /v1/{op}", a.registeredOp)
+
+	handle("POST /v1/complex/{op}", a.complexOp)
+	handle("POST /v1/interval/{op}", a.intervalOp)
+	handle("POST /v1/matrix/{op}", a.matrixOp)
+	handle("POST /v1/stats", a.describe)
+	handle("POST /v1/units", a.unitsExpr)
+	handle("POST /v1/units/{op}", a.unitsOp)
+	handle("POST /v1/convert", a.convertCurrency)
+	handle("POST /v1/dates/{op}", a.datesOp)
+	handle("POST /v1/finance/{fn}", a.financeFn)
+	handle("POST /v1/symbolic/{op}", a.symbolicOp)
+	handle("POST /v1/evaluate", a.evaluate)
+	handle("POST /v1/solve", a.solve)
+	handle("POST /v1/integrate", a.integrate)
+	handle("POST /v1/sum", a.sum)
+	handle("POST /v1/scripts/run", a.runScript)
 }
 
 func (a *API) getHistory(w http.ResponseWriter, r *http.Request) {
@@ -38,93 +96,311 @@ func (a *API) getHistory(w http.ResponseWriter, r *http.Request) {
 			limit = n
 		}
 	}
-	items := a.svc.GetHistory(limit)
-	WriteJSON(w, http.StatusOK, items)
+	svc := a.svcFor(r)
+	s := r.URL.Query().Get("run")
+	if s == "" {
+		Write(w, r, http.StatusOK, svc.GetHistory(limit))
+		return
+	}
+	// Entries of one script run, newest first like the full history.
+	run, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || run <= 0 {
+		writeError(w, r, newProblem(ProblemInvalidInput, "run", "run must be a positive integer"))
+		return
+	}
+	items := []service.HistoryEntry{}
+	for _, e := range svc.GetHistory(0) {
+		if e.Run == run && len(items) < limit {
+			items = append(items, e)
+		}
+	}
+	Write(w, r, http.StatusOK, items)
 }
 
 func (a *API) clearHistory(w http.ResponseWriter, r *http.Request) {
-	a.svc.ClearHistory()
-	WriteJSON(w, http.StatusOK, map[string]string{"status": "cleaned"})
+	a.svcFor(r).ClearHistory()
+	Write(w, r, http.StatusOK, map[string]string{"status": "cleaned"})
+}
+
+type metricsResponse struct {
+	History service.RetentionStats `json:"history"`
+	Cache   service.CacheStats     `json:"cache"`
+}
+
+func (a *API) getMetrics(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusOK, metricsResponse{
+		History: a.svc.RetentionStats(),
+		Cache:   a.svc.CacheStats(),
+	})
+}
+
+type replayRequest struct {
+	// Entries to replay, e.g. an export of GET /v1/history. When omitted the
+	// service's current history is replayed.
+	Entries   []service.HistoryEntry `json:"entries"`
+	Tolerance service.Tolerance      `json:"tolerance"`
+}
+
+func (a *API) replayHistory(w http.ResponseWriter, r *http.Request) {
+	var req replayRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	tol := req.Tolerance
+	if err := tol.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	entries := req.Entries
+	if entries == nil {
+		entries = a.svcFor(r).GetHistory(0)
+	}
+	Write(w, r, http.StatusOK, service.Replay(entries, tol))
 }
 
 type calcRequest struct {
-	A json.Number `json:"a"`
-	B json.Number `json:"b"`
+	A measurement `json:"a"`
+	B measurement `json:"b"`
+}
+
+// measurement is an operand of the basic operations: a number, or a measured
+// value {"value": 9.81, "uncertainty": 0.02} with its standard uncertainty.
+type measurement struct {
+	Value       json.Number `json:"value"`
+	Uncertainty json.Number `json:"uncertainty"`
+}
+
+func (m *measurement) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '{' {
+		type plain measurement
+		return decodeStrictJSON(bytes.NewReader(b), (*plain)(m))
+	}
+	return json.Unmarshal(b, &m.Value)
+}
+
+// operands parses the first arity of a and b, rejecting non-numbers,
+// non-finite values and negative uncertainties. A unary operation must not be
+// given b.
+func (req calcRequest) operands(arity int) (calculator.Uncertain, calculator.Uncertain, error) {
+	var out [2]calculator.Uncertain
+	for i, m := range []measurement{req.A, req.B} {
+		field := string(rune('a' + i))
+		if i >= arity {
+			if m != (measurement{}) {
+				return out[0], out[1], newProblem(ProblemInvalidInput, field, "b is not used by this operation")
+			}
+			continue
+		}
+		x, err := m.parse(field, "a and b must be numbers")
+		if err != nil {
+			return out[0], out[1], err
+		}
+		out[i] = x
+	}
+	return out[0], out[1], nil
+}
+
+// parse converts m for field, reporting notNumber when it is not a number.
+func (m measurement) parse(field, notNumber string) (calculator.Uncertain, error) {
+	// Parse to float64 now (may yield +Inf/-Inf/NaN).
+	v, err := parseJSONNumber(m.Value)
+	if err != nil {
+		return calculator.Uncertain{}, newProblem(ProblemInvalidJSON, field, notNumber)
+	}
+	var u float64
+	if m.Uncertainty != "" {
+		if u, err = parseJSONNumber(m.Uncertainty); err != nil {
+			return calculator.Uncertain{}, newProblem(ProblemInvalidJSON, field, notNumber)
+		}
+	}
+	return checkMeasurement(field, v, u)
+}
+
+// checkMeasurement rejects non-finite values and negative uncertainties.
+func checkMeasurement(field string, v, u float64) (calculator.Uncertain, error) {
+	switch {
+	case !isFinite(v) || !isFinite(u):
+		return calculator.Uncertain{}, newProblem(ProblemInvalidInput, field, "inputs must be finite numbers")
+	case u < 0:
+		return calculator.Uncertain{}, newProblem(ProblemInvalidInput, field, "uncertainty must not be negative")
+	}
+	return calculator.Uncertain{Value: v, Uncertainty: u}, nil
 }
 
 type calcResponse struct {
+	// Result is left out when it overflowed; Warnings then has "overflow".
 	Result float64 `json:"result,omitempty"`
-	Error  string  `json:"error,omitempty"`
+	// Formatted is the result in the requested locale (format=locale).
+	Formatted string `json:"formatted,omitempty"`
+	// Uncertainty is the propagated standard uncertainty of Result when an
+	// operand was a measured value.
+	Uncertainty *float64 `json:"uncertainty,omitempty"`
+	// Warnings lists precision problems such as "overflow" or "cancellation".
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type binOp func(svc service.CalculatorService, a, b calculator.Uncertain) (service.Result, error)
+
+// calculate returns the binOp that performs op through the service,
+// propagating uncertainty when either operand has one.
+func calculate(op string) binOp {
+	return func(svc service.CalculatorService, a, b calculator.Uncertain) (service.Result, error) {
+		if a.Uncertainty != 0 || b.Uncertainty != 0 {
+			return svc.CalculateUncertain(op, a, b)
+		}
+		return svc.Calculate(op, a.Value, b.Value)
+	}
+}
+
+// registeredOp performs the operation named by the path, looked up in the
+// service's registry so that newly registered operations need no route of
+// their own.
+func (a *API) registeredOp(w http.ResponseWriter, r *http.Request) {
+	ops := a.svc.Operations()
+	op, ok := ops.Lookup(r.PathValue("op"))
+	if !ok {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", opsHint(ops)))
+		return
+	}
+	a.operationOp(op.Arity(), calculate(op.Name))(w, r)
 }
 
-type binOp func(a, b float64) (float64, error)
+// opsHint lists the registered operation names, e.g. "use add|subtract".
+func opsHint(ops *calculator.Registry) string {
+	return "use " + strings.Join(ops.Names(), "|")
+}
 
-func (a *API) binaryOp(op binOp) http.HandlerFunc {
+func (a *API) operationOp(arity int, op binOp) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req calcRequest
-		if err := DecodeJSON(r, w, &req); err != nil {
-			WriteProblem(w, http.StatusBadRequest, "invalid_json", err.Error())
+		if !decodeBody(w, r, &req) {
 			return
 		}
 
-		// Parse to float64 now (may yield +Inf/-Inf/NaN).
-		av, errA := parseJSONNumber(req.A)
-		bv, errB := parseJSONNumber(req.B)
-		if errA != nil || errB != nil {
-			WriteProblem(w, http.StatusBadRequest, "invalid_json", "a and b must be numbers")
+		av, bv, err := req.operands(arity)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
-		if !isFinite(av) || !isFinite(bv) {
-			WriteProblem(w, http.StatusBadRequest, "invalid_input", "inputs must be finite numbers")
+		loc, err := resultLocale(r)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		res, err := op(av, bv)
+		res, err := op(a.svcFor(r), av, bv)
 		if err != nil {
-			WriteProblem(w, http.StatusBadRequest, "calculation_error", err.Error())
+			writeError(w, r, err)
 			return
 		}
-		WriteJSON(w, http.StatusOK, calcResponse{Result: res})
+		Write(w, r, http.StatusOK, calcResult(res, loc))
 	}
 }
 
 func (a *API) calculateQuery(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	op := strings.ToLower(q.Get("op"))
+	switch mode := q.Get("mode"); mode {
+	case "", "float64":
+	case service.ModeInterval:
+		a.calculateInterval(w, r)
+		return
+	default:
+		a.calculateInt(w, r, mode)
+		return
+	}
+	name := q.Get("op")
 	aStr, bStr := q.Get("a"), q.Get("b")
+	if name == "" {
+		writeError(w, r, newProblem(ProblemMissingParams, "op", "op, a, and b are required"))
+		return
+	}
+	ops := a.svc.Operations()
+	op, ok := ops.Lookup(name)
+	if !ok {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", opsHint(ops)))
+		return
+	}
 
-	if op == "" || aStr == "" || bStr == "" {
-		WriteProblem(w, http.StatusBadRequest, "missing_params", "op, a, and b are required")
+	switch {
+	case op.Arity() == 1 && aStr == "":
+		writeError(w, r, newProblem(ProblemMissingParams, "a", "op and a are required"))
+		return
+	case op.Arity() == 1 && bStr != "":
+		writeError(w, r, newProblem(ProblemInvalidInput, "b", "b is not used by this operation"))
 		return
+	case op.Arity() == 1 && q.Get("ub") != "":
+		writeError(w, r, newProblem(ProblemInvalidInput, "ub", "b is not used by this operation"))
+		return
+	case op.Arity() == 1:
+		// Parsed below like any other b, then ignored by the service.
+		bStr = "0"
+	}
+	for _, p := range []struct{ name, value string }{{"a", aStr}, {"b", bStr}} {
+		if p.value == "" {
+			writeError(w, r, newProblem(ProblemMissingParams, p.name, "op, a, and b are required"))
+			return
+		}
 	}
 
-	av, err1 := strconv.ParseFloat(aStr, 64)
-	bv, err2 := strconv.ParseFloat(bStr, 64)
-	if err1 != nil || err2 != nil || !isFinite(av) || !isFinite(bv) {
-		WriteProblem(w, http.StatusBadRequest, "invalid_input", "a and b must be valid finite numbers")
+	// With a locale parameter, operands may use its separators, e.g. 1.234,5.
+	numLoc, err := numberLocale(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	loc, err := resultLocale(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
+	parse := func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	if numLoc != nil {
+		parse = numLoc.ParseFloat
+	}
 
-	var (
-		res float64
-		err error
-	)
-	switch op {
-	case "add", "+":
-		res = a.svc.Add(av, bv)
-	case "subtract", "-":
-		res = a.svc.Subtract(av, bv)
-	case "multiply", "*", "x":
-		res = a.svc.Multiply(av, bv)
-	case "divide", "/":
-		res, err = a.svc.Divide(av, bv)
-	default:
-		WriteProblem(w, http.StatusBadRequest, "invalid_op", "use add|subtract|multiply|divide")
+	av, err1 := parse(aStr)
+	bv, err2 := parse(bStr)
+	switch {
+	case err1 != nil || !isFinite(av):
+		writeError(w, r, newProblem(ProblemInvalidInput, "a", "a and b must be valid finite numbers"))
+		return
+	case err2 != nil || !isFinite(bv):
+		writeError(w, r, newProblem(ProblemInvalidInput, "b", "a and b must be valid finite numbers"))
 		return
 	}
+
+	// ua and ub give standard uncertainties of measured operands.
+	var sigma [2]float64
+	for i, field := range []string{"ua", "ub"} {
+		s := q.Get(field)
+		if s == "" {
+			continue
+		}
+		u, err := parse(s)
+		if err == nil {
+			_, err = checkMeasurement(field, 0, u)
+		} else {
+			err = newProblem(ProblemInvalidInput, field, "inputs must be finite numbers")
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		sigma[i] = u
+	}
+
+	var res service.Result
+	if sigma[0] != 0 || sigma[1] != 0 {
+		res, err = a.svcFor(r).CalculateUncertain(op.Name,
+			calculator.Uncertain{Value: av, Uncertainty: sigma[0]}, calculator.Uncertain{Value: bv, Uncertainty: sigma[1]})
+	} else {
+		res, err = a.svcFor(r).Calculate(op.Name, av, bv)
+	}
 	if err != nil {
-		WriteProblem(w, http.StatusBadRequest, "calculation_error", err.Error())
+		writeError(w, r, err)
 		return
 	}
-	WriteJSON(w, http.StatusOK, calcResponse{Result: res})
+	Write(w, r, http.StatusOK, calcResult(res, loc))
 }
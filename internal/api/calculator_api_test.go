@@ -3,11 +3,13 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"erikkruuse/calculator/calculator"
 	service "erikkruuse/calculator/internal/services"
 )
 
@@ -246,9 +248,9 @@ func TestBinaryOp_InvalidJSON_NonNumericValues(t *testing.T) {
 	t.Parallel()
 
 	a := New(service.NewCalculatorService())
-	h := a.binaryOp(func(a, b float64) (float64, error) {
+	h := a.operationOp(2, func(_ service.CalculatorService, a, b calculator.Uncertain) (service.Result, error) {
 		t.Fatalf("op should not be invoked when parsing fails")
-		return 0, nil
+		return service.Result{}, nil
 	})
 
 	body := `{"a": "not-a-number", "b": 5}`
@@ -279,11 +281,11 @@ func TestBinaryOp_InvalidJSON_NonNumericValues(t *testing.T) {
 func TestBinaryOp_InvalidInput_NonFinite_FromJSON(t *testing.T) {
 	t.Parallel()
 
-	// Build handler for POST /v1/add path through binaryOp
+	// Build handler for POST /v1/add path through operationOp
 	a := New(service.NewCalculatorService())
-	h := a.binaryOp(func(a, b float64) (float64, error) {
+	h := a.operationOp(2, func(_ service.CalculatorService, a, b calculator.Uncertain) (service.Result, error) {
 		t.Fatalf("op must not be called when inputs are non-finite")
-		return 0, nil
+		return service.Result{}, nil
 	})
 
 	// Use a float64 overflow that decodes as json.Number -> Float64() -> +Inf
@@ -376,3 +378,287 @@ func TestHistory_Flow(t *testing.T) {
 		t.Fatalf("expected empty history after clear, got %d", len(items))
 	}
 }
+
+func TestHistoryReplay_LiveAndSupplied(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	_, _ = postJSON(t, ts.URL+"/v1/add", map[string]any{"a": 2, "b": 3})
+	_, _ = postJSON(t, ts.URL+"/v1/divide", map[string]any{"a": 5, "b": 0})
+
+	// No entries: replays the live history.
+	resp, body := postJSON(t, ts.URL+"/v1/history/replay", map[string]any{})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d body=%s", resp.StatusCode, string(body))
+	}
+	var report service.ReplayReport
+	json.Unmarshal(body, &report)
+	if report.Total != 2 || report.Matched != 2 || len(report.Mismatches) != 0 {
+		t.Fatalf("live replay report = %+v", report)
+	}
+
+	// Supplied entries with a loose tolerance.
+	resp, body = postJSON(t, ts.URL+"/v1/history/replay", map[string]any{
+		"entries": []map[string]any{
+			{"id": 1, "op": "add", "a": 0.1, "b": 0.2, "result": 0.3},
+			{"id": 2, "op": "multiply", "a": 2, "b": 2, "result": 5},
+		},
+		"tolerance": map[string]any{"abs": 1e-9},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d body=%s", resp.StatusCode, string(body))
+	}
+	report = service.ReplayReport{}
+	json.Unmarshal(body, &report)
+	if report.Matched != 1 || len(report.Mismatches) != 1 || report.Mismatches[0].Entry.ID != 2 {
+		t.Fatalf("supplied replay report = %+v", report)
+	}
+}
+
+func TestHistoryReplay_NegativeTolerance(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, body := postJSON(t, ts.URL+"/v1/history/replay", map[string]any{"tolerance": map[string]any{"rel": -1}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status=%d body=%s", resp.StatusCode, string(body))
+	}
+	var p Problem
+	json.Unmarshal(body, &p)
+	if p.Title != "invalid_input" {
+		t.Fatalf("want invalid_input, got %+v", p)
+	}
+}
+
+func TestHistory_TenantHeaderIsolates(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/add", strings.NewReader(`{"a":1,"b":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TenantHeader, "acme")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	readAll(t, resp)
+
+	_, body := get(t, ts.URL+"/v1/history")
+	var items []service.HistoryEntry
+	json.Unmarshal(body, &items)
+	if len(items) != 0 {
+		t.Fatalf("default tenant should not see acme history, got %+v", items)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/v1/history", nil)
+	req.Header.Set(TenantHeader, "acme")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	json.Unmarshal(readAll(t, resp), &items)
+	if len(items) != 1 || items[0].Tenant != "acme" {
+		t.Fatalf("acme history = %+v", items)
+	}
+}
+
+func TestMetrics_ReportsHistoryEvictions(t *testing.T) {
+	a := New(service.NewCalculatorService(service.WithMaxHistory(2)))
+	mux := http.NewServeMux()
+	a.RegisterRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for i := 0; i < 5; i++ {
+		_, _ = postJSON(t, ts.URL+"/v1/add", map[string]any{"a": i, "b": 1})
+	}
+
+	resp, body := get(t, ts.URL+"/v1/metrics")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d body=%s", resp.StatusCode, string(body))
+	}
+	var m struct {
+		History service.RetentionStats `json:"history"`
+	}
+	json.Unmarshal(body, &m)
+	if m.History.Entries != 2 || m.History.Evicted[service.RuleMaxCount] != 3 {
+		t.Fatalf("metrics = %+v", m)
+	}
+}
+
+func TestResultCache_NoCacheHeader(t *testing.T) {
+	svc := service.NewCalculatorService(service.WithResultCache(10, 0))
+	mux := http.NewServeMux()
+	New(svc).RegisterRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	_, _ = postJSON(t, ts.URL+"/v1/add", map[string]any{"a": 1, "b": 2})
+	_, _ = postJSON(t, ts.URL+"/v1/add", map[string]any{"a": 1, "b": 2})
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/calculate?op=add&a=1&b=2", nil)
+	req.Header.Set("Cache-Control", "max-age=0, no-cache")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	readAll(t, resp)
+
+	_, body := get(t, ts.URL+"/v1/metrics")
+	var m struct {
+		Cache service.CacheStats `json:"cache"`
+	}
+	json.Unmarshal(body, &m)
+	if m.Cache.Hits != 1 || m.Cache.Misses != 1 {
+		t.Fatalf("cache stats = %+v; want 1 hit, 1 miss", m.Cache)
+	}
+	if n := len(svc.GetHistory(0)); n != 3 {
+		t.Fatalf("history len=%d; want 3", n)
+	}
+}
+
+func TestOverflow_WarningsAndStrictMode(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, body := postJSON(t, srv.URL+"/v1/multiply", map[string]any{"a": 1e308, "b": 10})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("lenient status=%d body=%s", resp.StatusCode, body)
+	}
+	if string(body) != `{"warnings":["overflow"]}`+"\n" {
+		t.Fatalf("lenient response = %s", body)
+	}
+	// Neither do history or a measured operand whose uncertainty overflows.
+	resp, body = postJSON(t, srv.URL+"/v1/add", map[string]any{"a": map[string]any{"value": 1, "uncertainty": 1.5e308}, "b": map[string]any{"value": 1, "uncertainty": 1.5e308}})
+	if resp.StatusCode != http.StatusOK || string(body) != `{"result":2,"warnings":["overflow"]}`+"\n" {
+		t.Fatalf("uncertainty overflow = %d %s", resp.StatusCode, body)
+	}
+	resp, body = get(t, srv.URL+"/v1/history?limit=2")
+	var h []service.HistoryEntry
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(body, &h) != nil || len(h) != 2 || h[1].Result != 0 || h[0].Uncertainty != nil {
+		t.Fatalf("history = %d %s", resp.StatusCode, body)
+	}
+
+	mux := http.NewServeMux()
+	New(service.NewCalculatorService(service.WithStrictness(service.Strict))).RegisterRoutes(mux)
+	strict := httptest.NewServer(mux)
+	defer strict.Close()
+
+	resp, body = get(t, strict.URL+"/v1/calculate?op=x&a=1e308&b=10")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("strict status=%d body=%s", resp.StatusCode, body)
+	}
+	var p Problem
+	json.Unmarshal(body, &p)
+	if p.Type != ProblemOverflow.URI() || p.Extensions["op"] != "multiply" {
+		t.Fatalf("strict problem = %s", body)
+	}
+}
+
+func TestBinaryOp_Uncertainty(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name        string
+		path        string
+		body        string
+		status      int
+		result      float64
+		uncertainty float64 // -1 when the response must not carry one
+		code        string
+		field       string
+	}{
+		{"measured times exact", "/v1/multiply", `{"a":{"value":9.81,"uncertainty":0.02},"b":2}`, 200, 19.62, 0.04, "", ""},
+		{"quadrature", "/v1/add", `{"a":{"value":1,"uncertainty":0.3},"b":{"value":2,"uncertainty":0.4}}`, 200, 3, 0.5, "", ""},
+		{"relative", "/v1/divide", `{"a":{"value":10,"uncertainty":0.3},"b":{"value":4,"uncertainty":0.4}}`, 200, 2.5, 2.5 * math.Hypot(0.03, 0.1), "", ""},
+		{"exact object", "/v1/subtract", `{"a":{"value":5},"b":2}`, 200, 3, -1, "", ""},
+		{"negative", "/v1/add", `{"a":1,"b":{"value":2,"uncertainty":-0.1}}`, 400, 0, 0, "invalid_input", "b"},
+		{"infinite", "/v1/add", `{"a":{"value":1,"uncertainty":1e999},"b":2}`, 400, 0, 0, "invalid_input", "a"},
+		{"no value", "/v1/add", `{"a":{"uncertainty":0.1},"b":2}`, 400, 0, 0, "invalid_json", "a"},
+		{"unknown member", "/v1/add", `{"a":{"value":1,"sigma":0.1},"b":2}`, 400, 0, 0, "invalid_json", ""},
+		{"zero divisor", "/v1/divide", `{"a":1,"b":{"value":0,"uncertainty":0.1}}`, 400, 0, 0, "calculation_error", "b"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+tc.path, tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != orNil(tc.field) {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got calcResponse
+			json.Unmarshal(body, &got)
+			switch {
+			case math.Abs(got.Result-tc.result) > 1e-12:
+			case tc.uncertainty < 0 && got.Uncertainty == nil:
+				return
+			case tc.uncertainty >= 0 && got.Uncertainty != nil && math.Abs(*got.Uncertainty-tc.uncertainty) < 1e-12:
+				return
+			}
+			t.Fatalf("response = %s; want %v ± %v", body, tc.result, tc.uncertainty)
+		})
+	}
+
+	// Newest first: the failed divide, the exact subtract, the measured divide.
+	_, body := get(t, srv.URL+"/v1/history?limit=3")
+	var h []service.HistoryEntry
+	json.Unmarshal(body, &h)
+	if len(h) != 3 || h[0].Error == "" || *h[0].UncertaintyB != 0.1 || h[1].UncertaintyA != nil ||
+		*h[2].UncertaintyA != 0.3 || *h[2].UncertaintyB != 0.4 || h[2].Uncertainty == nil {
+		t.Fatalf("history = %s", body)
+	}
+}
+
+func TestCalculate_Uncertainty(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name        string
+		query       string
+		status      int
+		result      float64
+		uncertainty float64 // -1 when the response must not carry one
+		code        string
+		field       string
+	}{
+		{"measured a", "op=multiply&a=9.81&ua=0.02&b=2", 200, 19.62, 0.04, "", ""},
+		{"both measured", "op=add&a=1&ua=0.3&b=2&ub=0.4", 200, 3, 0.5, "", ""},
+		{"zero uncertainty", "op=add&a=1&ua=0&b=2", 200, 3, -1, "", ""},
+		{"negative", "op=add&a=1&ua=-0.1&b=2", 400, 0, 0, "invalid_input", "ua"},
+		{"not a number", "op=add&a=1&b=2&ub=x", 400, 0, 0, "invalid_input", "ub"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := get(t, srv.URL+"/v1/calculate?"+tc.query)
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != orNil(tc.field) {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got calcResponse
+			json.Unmarshal(body, &got)
+			switch {
+			case math.Abs(got.Result-tc.result) > 1e-12:
+			case tc.uncertainty < 0 && got.Uncertainty == nil:
+				return
+			case tc.uncertainty >= 0 && got.Uncertainty != nil && math.Abs(*got.Uncertainty-tc.uncertainty) < 1e-12:
+				return
+			}
+			t.Fatalf("response = %s; want %v ± %v", body, tc.result, tc.uncertainty)
+		})
+	}
+}
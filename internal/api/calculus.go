@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+
+	"erikkruuse/calculator/calculus"
+	"erikkruuse/calculator/expr"
+)
+
+// computeTimeout is the wall-clock budget of one integral or series,
+// independent of its evaluation budget.
+const computeTimeout = 2 * time.Second
+
+// maxEvaluations caps the max_evaluations a request may ask for.
+const maxEvaluations = 1000000
+
+// maxIndex bounds summation indices to the integers float64 holds exactly.
+const maxIndex = 1 << 53
+
+// integrateRequest is the body of POST /v1/integrate. method is
+// gauss_kronrod (the default) or simpson.
+type integrateRequest struct {
+	Expr           string    `json:"expr"`
+	Var            string    `json:"var"`
+	Interval       []float64 `json:"interval"`
+	Method         string    `json:"method"`
+	Tolerance      *float64  `json:"tolerance"`
+	MaxEvaluations *int      `json:"max_evaluations"`
+}
+
+// sumRequest is the body of POST /v1/sum: the sum, or with product the
+// product, of expr for the integers var = from..to. Without to the series
+// or product is infinite.
+type sumRequest struct {
+	Expr           string   `json:"expr"`
+	Var            string   `json:"var"`
+	From           *int64   `json:"from"`
+	To             *int64   `json:"to"`
+	Product        bool     `json:"product"`
+	Tolerance      *float64 `json:"tolerance"`
+	MaxEvaluations *int     `json:"max_evaluations"`
+}
+
+// calculusResponse gives a value with its estimated absolute error and the
+// evaluations of the expression it took.
+type calculusResponse struct {
+	Value       float64 `json:"value"`
+	Error       float64 `json:"error"`
+	Evaluations int     `json:"evaluations"`
+	Method      string  `json:"method,omitempty"`
+}
+
+// calculusOptions validates the accuracy and budget fields shared by
+// /v1/integrate and /v1/sum.
+func calculusOptions(tolerance *float64, maxEvals *int) (calculus.Options, error) {
+	o := calculus.DefaultOptions
+	if t := tolerance; t != nil {
+		if *t <= 0 || *t > 0.1 {
+			return o, newProblem(ProblemInvalidInput, "tolerance", "tolerance must be greater than 0 and at most 0.1")
+		}
+		o.Tolerance = *t
+	}
+	if n := maxEvals; n != nil {
+		if *n < 1 || *n > maxEvaluations {
+			return o, newProblem(ProblemInvalidInput, "max_evaluations", "max_evaluations must be between 1 and 1000000")
+		}
+		o.MaxEvals = *n
+	}
+	return o, nil
+}
+
+// calculusFunc parses expr as a function of its only variable, or of v.
+func calculusFunc(s, v string) (calculus.Func, error) {
+	if s == "" {
+		return nil, newProblem(ProblemMissingParams, "expr", "expr is required")
+	}
+	n, err := parseExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	if v, err = derivativeVar(n, v); err != nil {
+		return nil, err
+	}
+	for _, name := range expr.Vars(n) {
+		if name != v {
+			return nil, newProblem(ProblemInvalidInput, "expr", "expr may only use the variable var")
+		}
+	}
+	return func(x float64) (float64, error) { return expr.Eval(n, map[string]float64{v: x}) }, nil
+}
+
+func (a *API) integrate(w http.ResponseWriter, r *http.Request) {
+	var req integrateRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	f, err := calculusFunc(req.Expr, req.Var)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	switch {
+	case req.Interval == nil:
+		writeError(w, r, newProblem(ProblemMissingParams, "interval", "missing operand for this operation"))
+		return
+	case len(req.Interval) != 2:
+		writeError(w, r, newProblem(ProblemInvalidInput, "interval", "interval must be two numbers"))
+		return
+	}
+	method := req.Method
+	switch method {
+	case "":
+		method = calculus.GaussKronrod
+	case calculus.GaussKronrod, calculus.Simpson:
+	default:
+		writeError(w, r, newProblem(ProblemInvalidOp, "method", "use gauss_kronrod|simpson"))
+		return
+	}
+	o, err := calculusOptions(req.Tolerance, req.MaxEvaluations)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), computeTimeout)
+	defer cancel()
+	res, err := calculus.Integrate(ctx, f, req.Interval[0], req.Interval[1], method, o)
+	if err != nil {
+		writeError(w, r, budgetProblem(err))
+		return
+	}
+	Write(w, r, http.StatusOK, calculusResponse{Value: res.Value, Error: res.Error, Evaluations: res.Evals, Method: method})
+}
+
+func (a *API) sum(w http.ResponseWriter, r *http.Request) {
+	var req sumRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	f, err := calculusFunc(req.Expr, req.Var)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if req.From == nil {
+		writeError(w, r, newProblem(ProblemMissingParams, "from", "missing operand for this operation"))
+		return
+	}
+	for _, idx := range []struct {
+		field string
+		n     *int64
+	}{{"from", req.From}, {"to", req.To}} {
+		if idx.n != nil && (*idx.n > maxIndex || *idx.n < -maxIndex) {
+			writeError(w, r, newProblem(ProblemInvalidInput, idx.field, "index must be at most 2^53 in magnitude"))
+			return
+		}
+	}
+	o, err := calculusOptions(req.Tolerance, req.MaxEvaluations)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), computeTimeout)
+	defer cancel()
+	var res calculus.Result
+	switch {
+	case req.To == nil && req.Product:
+		res, err = calculus.InfiniteProduct(ctx, f, *req.From, o)
+	case req.To == nil:
+		res, err = calculus.Series(ctx, f, *req.From, o)
+	case req.Product:
+		res, err = calculus.Product(ctx, f, *req.From, *req.To, o)
+	default:
+		res, err = calculus.Sum(ctx, f, *req.From, *req.To, o)
+	}
+	if err != nil {
+		writeError(w, r, budgetProblem(err))
+		return
+	}
+	Write(w, r, http.StatusOK, calculusResponse{Value: res.Value, Error: res.Error, Evaluations: res.Evals})
+}
+
+func finite(v float64) bool { return !math.IsNaN(v) && !math.IsInf(v, 0) }
+
+// budgetProblem reports a spent budget with the best estimate reached, so a
+// client can judge whether it is good enough.
+func budgetProblem(err error) error {
+	be, ok := err.(*calculus.BudgetError)
+	if !ok {
+		return err
+	}
+	ext := map[string]any{"limit": be.Limit, "evaluations": be.Evals}
+	if finite(be.Estimate) {
+		ext["estimate"] = be.Estimate
+	}
+	if finite(be.EstimateError) {
+		ext["error"] = be.EstimateError
+	}
+	return &problemError{typ: ProblemBudgetExceeded, detail: be.Error(), ext: ext}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestCalculus(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		want   float64
+		code   string // problem title for errors
+		field  string
+	}{
+		{"integral", "/v1/integrate", `{"expr":"sin(x)","interval":[0,3.141592653589793]}`, 200, 2, "", ""},
+		{"simpson", "/v1/integrate", `{"expr":"t^2","var":"t","interval":[0,3],"method":"simpson"}`, 200, 9, "", ""},
+		{"singular end", "/v1/integrate", `{"expr":"ln(x)","interval":[0,1]}`, 200, -1, "", ""},
+		{"reversed", "/v1/integrate", `{"expr":"1","interval":[2,0]}`, 200, -2, "", ""},
+		{"domain", "/v1/integrate", `{"expr":"ln(x)","interval":[0,1],"method":"simpson"}`, 400, 0, "domain_error", ""},
+		{"no interval", "/v1/integrate", `{"expr":"x"}`, 400, 0, "missing_params", "interval"},
+		{"bad interval", "/v1/integrate", `{"expr":"x","interval":[0]}`, 400, 0, "invalid_input", "interval"},
+		{"bad method", "/v1/integrate", `{"expr":"x","interval":[0,1],"method":"trapezoid"}`, 400, 0, "invalid_op", "method"},
+		{"other variable", "/v1/integrate", `{"expr":"x*y","var":"x","interval":[0,1]}`, 400, 0, "invalid_input", "expr"},
+		{"integral budget", "/v1/integrate", `{"expr":"sin(1/x)","interval":[0,1],"max_evaluations":1000}`, 422, 0, "budget_exceeded", ""},
+
+		{"finite sum", "/v1/sum", `{"expr":"k^2","var":"k","from":1,"to":100}`, 200, 338350, "", ""},
+		{"finite product", "/v1/sum", `{"expr":"n","from":1,"to":10,"product":true}`, 200, 3628800, "", ""},
+		{"empty sum", "/v1/sum", `{"expr":"n","from":1,"to":0}`, 200, 0, "", ""},
+		{"basel", "/v1/sum", `{"expr":"1/n^2","from":1}`, 200, math.Pi * math.Pi / 6, "", ""},
+		{"alternating", "/v1/sum", `{"expr":"(-1)^(n+1)/n","from":1}`, 200, math.Ln2, "", ""},
+		{"wallis", "/v1/sum", `{"expr":"1 - 1/(4n^2)","from":1,"product":true}`, 200, 2 / math.Pi, "", ""},
+		{"harmonic", "/v1/sum", `{"expr":"1/n","from":1}`, 400, 0, "domain_error", ""},
+		{"product diverges", "/v1/sum", `{"expr":"1 + 1/n","from":1,"product":true}`, 400, 0, "domain_error", ""},
+		{"too many terms", "/v1/sum", `{"expr":"n","from":1,"to":1000000000}`, 422, 0, "budget_exceeded", ""},
+		{"no from", "/v1/sum", `{"expr":"n"}`, 400, 0, "missing_params", "from"},
+		{"huge index", "/v1/sum", `{"expr":"n","from":1,"to":9007199254740993}`, 400, 0, "invalid_input", "to"},
+		{"bad tolerance", "/v1/sum", `{"expr":"n","from":1,"tolerance":1}`, 400, 0, "invalid_input", "tolerance"},
+		{"bad budget", "/v1/sum", `{"expr":"n","from":1,"max_evaluations":2000000}`, 400, 0, "invalid_input", "max_evaluations"},
+		{"no expr", "/v1/sum", `{"from":1}`, 400, 0, "missing_params", "expr"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+tc.path, tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != orNil(tc.field) {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got calculusResponse
+			json.Unmarshal(body, &got)
+			if math.Abs(got.Value-tc.want) > max(got.Error, 1e-12) || got.Error > 1e-8*max(1, math.Abs(tc.want)) || got.Evaluations == 0 && tc.name != "empty sum" {
+				t.Fatalf("response = %s; want %v", body, tc.want)
+			}
+		})
+	}
+}
+
+func TestCalculus_BudgetEstimate(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	_, body := postRaw(t, srv.URL+"/v1/integrate", `{"expr":"sin(1/x)","interval":[0,1],"max_evaluations":3000}`, "application/json")
+	var p Problem
+	json.Unmarshal(body, &p)
+	est, _ := p.Extensions["estimate"].(float64)
+	if p.Extensions["limit"] != "evaluations" || p.Extensions["evaluations"] != float64(3000) ||
+		math.Abs(est-0.504067) > 1e-3 || p.Extensions["error"] == nil {
+		t.Fatalf("budget_exceeded = %s", body)
+	}
+}
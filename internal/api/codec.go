@@ -21,19 +21,7 @@ func DecodeJSON(r *http.Request, w http.ResponseWriter, v any) error {
 	}
 
 	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
-
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	dec.UseNumber()
-
-	if err := dec.Decode(v); err != nil {
-		return err
-	}
-	// No trailing payload allowed
-	if dec.More() {
-		return errors.New("unexpected trailing data")
-	}
-	return nil
+	return decodeStrictJSON(r.Body, v)
 }
 
 // WriteJSON writes a JSON response with the given status.
@@ -42,21 +30,3 @@ func WriteJSON(w http.ResponseWriter, status int, v any) {
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
-
-// Problem is a minimal error envelope (inspired by RFC7807).
-type Problem struct {
-	Type   string `json:"type,omitempty"`
-	Title  string `json:"title,omitempty"`
-	Status int    `json:"status,omitempty"`
-	Detail string `json:"detail,omitempty"`
-}
-
-// WriteProblem writes a standardized error response.
-func WriteProblem(w http.ResponseWriter, status int, title string, detail string) {
-	WriteJSON(w, status, Problem{
-		Type:   "", // could set a canonical URL per error class later
-		Title:  title,
-		Status: status,
-		Detail: detail,
-	})
-}
@@ -54,7 +54,7 @@ func TestWriteProblem(t *testing.T) {
 		t.Fatalf("status = %d; want 400", rr.Code)
 	}
 	ct := rr.Header().Get("Content-Type")
-	if ct == "" || ct[:16] != "application/json" {
-		t.Fatalf("content-type = %q; want application/json", ct)
+	if ct != ProblemContentType {
+		t.Fatalf("content-type = %q; want %s", ct, ProblemContentType)
 	}
 }
@@ -0,0 +1,506 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes responses and decodes requests for one family of media types.
+//
+// Every codec applies the DecodeJSON rules: unknown fields and trailing data are
+// rejected. Non-JSON codecs achieve this by decoding into a generic value and
+// running it through the strict JSON decoder, so struct tags and validation are
+// shared across formats.
+type Codec interface {
+	// MediaTypes lists the accepted media types; the first is used in responses.
+	MediaTypes() []string
+	Decode(r io.Reader, v any) error
+	Encode(w io.Writer, v any) error
+}
+
+/* ---------- JSON ---------- */
+
+type jsonCodec struct{}
+
+func (jsonCodec) MediaTypes() []string { return []string{"application/json"} }
+
+func (jsonCodec) Decode(r io.Reader, v any) error { return decodeStrictJSON(r, v) }
+
+func (jsonCodec) Encode(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+
+func decodeStrictJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	dec.UseNumber()
+
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	// No trailing payload allowed
+	if dec.More() {
+		return errors.New("unexpected trailing data")
+	}
+	return nil
+}
+
+// decodeViaJSON re-encodes a generically decoded value as JSON and decodes it
+// strictly into v.
+func decodeViaJSON(generic any, v any) error {
+	norm, err := jsonCompatible(generic)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(norm)
+	if err != nil {
+		return err
+	}
+	return decodeStrictJSON(bytes.NewReader(b), v)
+}
+
+// jsonCompatible converts values produced by the binary decoders into ones
+// encoding/json can marshal. Infinite floats become out-of-range number literals
+// so handlers report them exactly as they would for JSON input.
+func jsonCompatible(v any) (any, error) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, e := range t {
+			c, err := jsonCompatible(e)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = c
+		}
+		return t, nil
+	case map[any]any:
+		out := make(map[string]any, len(t))
+		for k, e := range t {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("map keys must be strings, got %T", k)
+			}
+			c, err := jsonCompatible(e)
+			if err != nil {
+				return nil, err
+			}
+			out[ks] = c
+		}
+		return out, nil
+	case []any:
+		for i, e := range t {
+			c, err := jsonCompatible(e)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = c
+		}
+		return t, nil
+	case float32:
+		return jsonCompatible(float64(t))
+	case float64:
+		switch {
+		case math.IsNaN(t):
+			return nil, errors.New("NaN is not a valid number")
+		case math.IsInf(t, 1):
+			return json.Number("1e999"), nil
+		case math.IsInf(t, -1):
+			return json.Number("-1e999"), nil
+		}
+		return t, nil
+	}
+	return v, nil
+}
+
+// toGeneric converts a response value into maps, slices and scalars following
+// its JSON representation, with numbers as int64 or float64.
+func toGeneric(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return unwrapNumbers(out), nil
+}
+
+func unwrapNumbers(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, e := range t {
+			t[k] = unwrapNumbers(e)
+		}
+	case []any:
+		for i, e := range t {
+			t[i] = unwrapNumbers(e)
+		}
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		f, _ := t.Float64()
+		return f
+	}
+	return v
+}
+
+// scalarFromText interprets text from XML or plain-text bodies: valid JSON
+// number literals and booleans keep their type, anything else is a string.
+func scalarFromText(s string) any {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if json.Valid([]byte(s)) && s != "" && (s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) {
+		return json.Number(s)
+	}
+	return s
+}
+
+/* ---------- CBOR ---------- */
+
+var (
+	typeOfGenericMap = reflect.TypeOf(map[string]any(nil))
+
+	cborDec, _ = cbor.DecOptions{
+		DefaultMapType: typeOfGenericMap,
+		DupMapKey:      cbor.DupMapKeyEnforcedAPF,
+	}.DecMode()
+	cborEnc, _ = cbor.CanonicalEncOptions().EncMode()
+)
+
+type cborCodec struct{}
+
+func (cborCodec) MediaTypes() []string { return []string{"application/cbor"} }
+
+func (cborCodec) Decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var generic any
+	rest, err := cborDec.UnmarshalFirst(data, &generic)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("unexpected trailing data")
+	}
+	return decodeViaJSON(generic, v)
+}
+
+func (cborCodec) Encode(w io.Writer, v any) error {
+	g, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	return cborEnc.NewEncoder(w).Encode(g)
+}
+
+/* ---------- MessagePack ---------- */
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (msgpackCodec) Decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	br := bytes.NewReader(data)
+	dec := msgpack.NewDecoder(br)
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return err
+	}
+	if br.Len() > 0 {
+		return errors.New("unexpected trailing data")
+	}
+	return decodeViaJSON(generic, v)
+}
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	g, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	enc := msgpack.NewEncoder(w)
+	enc.SetSortMapKeys(true)
+	return enc.Encode(g)
+}
+
+/* ---------- XML ---------- */
+
+// xmlItem names the elements that make up an array: <entries><item>…</item></entries>.
+const xmlItem = "item"
+
+type xmlCodec struct{}
+
+func (xmlCodec) MediaTypes() []string { return []string{"application/xml", "text/xml"} }
+
+func (xmlCodec) Decode(r io.Reader, v any) error {
+	dec := xml.NewDecoder(r)
+	root, err := nextStart(dec)
+	if err != nil {
+		return err
+	}
+	generic, err := xmlValue(dec, root)
+	if err != nil {
+		return err
+	}
+	// Only whitespace, comments and processing instructions may follow the root.
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return errors.New("unexpected trailing data")
+			}
+		case xml.StartElement:
+			return errors.New("unexpected trailing data")
+		}
+	}
+	return decodeViaJSON(generic, v)
+}
+
+func nextStart(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return xml.StartElement{}, errors.New("empty XML document")
+			}
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return xml.StartElement{}, errors.New("text outside the root element")
+			}
+		}
+	}
+}
+
+// xmlValue reads the content of start: text becomes a scalar, child elements an
+// object, and children all named "item" an array.
+func xmlValue(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	if len(start.Attr) > 0 {
+		return nil, fmt.Errorf("attributes are not supported on <%s>", start.Name.Local)
+	}
+	var (
+		text     strings.Builder
+		fields   = map[string]any{}
+		items    []any
+		children int
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			child, err := xmlValue(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			children++
+			name := t.Name.Local
+			if name == xmlItem {
+				items = append(items, child)
+				continue
+			}
+			if _, dup := fields[name]; dup {
+				return nil, fmt.Errorf("duplicate element <%s>", name)
+			}
+			fields[name] = child
+		case xml.EndElement:
+			switch {
+			case children == 0 && strings.TrimSpace(text.String()) == "":
+				return nil, nil
+			case children == 0:
+				return scalarFromText(text.String()), nil
+			case strings.TrimSpace(text.String()) != "":
+				return nil, fmt.Errorf("mixed content in <%s>", start.Name.Local)
+			case len(items) > 0 && len(fields) > 0:
+				return nil, fmt.Errorf("<%s> mixes <item> with named elements", start.Name.Local)
+			case len(items) > 0:
+				return items, nil
+			}
+			return fields, nil
+		}
+	}
+}
+
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// Walk JSON tokens rather than a decoded map so struct field order is kept.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	enc := xml.NewEncoder(w)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if err := writeXMLValue(dec, enc, "response"); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func writeXMLValue(dec *json.Decoder, enc *xml.Encoder, name string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		for dec.More() {
+			child := xmlItem
+			if t == '{' {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = keyTok.(string)
+			}
+			if err := writeXMLValue(dec, enc, child); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // closing delimiter
+			return err
+		}
+	case nil:
+		// empty element
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(t))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+/* ---------- plain text ---------- */
+
+// textCodec renders scalars as-is and objects as sorted key=value lines, with
+// nested values in JSON. Request bodies use the same key=value form.
+type textCodec struct{}
+
+func (textCodec) MediaTypes() []string { return []string{"text/plain"} }
+
+func (textCodec) Decode(r io.Reader, v any) error {
+	fields := map[string]any{}
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(s, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("line %d: want key=value", line)
+		}
+		if _, dup := fields[key]; dup {
+			return fmt.Errorf("line %d: duplicate key %q", line, key)
+		}
+		val = strings.TrimSpace(val)
+		if strings.HasPrefix(val, "{") || strings.HasPrefix(val, "[") {
+			var nested any
+			if err := json.Unmarshal([]byte(val), &nested); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			fields[key] = nested
+			continue
+		}
+		fields[key] = scalarFromText(val)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return decodeViaJSON(fields, v)
+}
+
+func (textCodec) Encode(w io.Writer, v any) error {
+	g, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	switch t := g.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&buf, "%s=%s\n", k, textScalar(t[k]))
+		}
+	case []any:
+		for _, e := range t {
+			fmt.Fprintln(&buf, textScalar(e))
+		}
+	default:
+		fmt.Fprintln(&buf, textScalar(t))
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func textScalar(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	case nil:
+		return ""
+	case map[string]any, []any:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type codecTarget struct {
+	A    float64   `json:"a"`
+	Tags []string  `json:"tags"`
+	Nums []float64 `json:"nums,omitempty"`
+}
+
+func mustCBOR(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatalf("cbor.Marshal: %v", err)
+	}
+	return b
+}
+
+func mustMsgpack(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		t.Fatalf("msgpack.Marshal: %v", err)
+	}
+	return b
+}
+
+func TestCodecs_DecodeEveryFormat(t *testing.T) {
+	payload := map[string]any{"a": 1.5, "tags": []string{"x", "y"}}
+	cases := []struct {
+		name  string
+		codec Codec
+		body  []byte
+	}{
+		{"json", jsonCodec{}, []byte(`{"a":1.5,"tags":["x","y"]}`)},
+		{"cbor", cborCodec{}, mustCBOR(t, payload)},
+		{"msgpack", msgpackCodec{}, mustMsgpack(t, payload)},
+		{"xml", xmlCodec{}, []byte(`<?xml version="1.0"?><request><a>1.5</a><tags><item>x</item><item>y</item></tags></request>`)},
+		{"text", textCodec{}, []byte("a = 1.5\ntags=[\"x\",\"y\"]\n")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got codecTarget
+			if err := tc.codec.Decode(bytes.NewReader(tc.body), &got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got.A != 1.5 || len(got.Tags) != 2 || got.Tags[1] != "y" {
+				t.Fatalf("decoded %+v", got)
+			}
+		})
+	}
+}
+
+func TestCodecs_StrictnessInEveryFormat(t *testing.T) {
+	withExtra := map[string]any{"a": 1, "extra": true}
+	cases := []struct {
+		name  string
+		codec Codec
+		body  []byte
+	}{
+		{"json unknown field", jsonCodec{}, []byte(`{"a":1,"extra":true}`)},
+		{"json trailing", jsonCodec{}, []byte(`{"a":1} {"a":2}`)},
+		{"cbor unknown field", cborCodec{}, mustCBOR(t, withExtra)},
+		{"cbor trailing", cborCodec{}, append(mustCBOR(t, map[string]any{"a": 1}), 0x01)},
+		{"msgpack unknown field", msgpackCodec{}, mustMsgpack(t, withExtra)},
+		{"msgpack trailing", msgpackCodec{}, append(mustMsgpack(t, map[string]any{"a": 1}), 0x01)},
+		{"xml unknown field", xmlCodec{}, []byte(`<r><a>1</a><extra>true</extra></r>`)},
+		{"xml trailing", xmlCodec{}, []byte(`<r><a>1</a></r><r/>`)},
+		{"xml attributes", xmlCodec{}, []byte(`<r a="1"></r>`)},
+		{"xml duplicate", xmlCodec{}, []byte(`<r><a>1</a><a>2</a></r>`)},
+		{"text unknown field", textCodec{}, []byte("a=1\nextra=true\n")},
+		{"text malformed", textCodec{}, []byte("a 1\n")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got codecTarget
+			if err := tc.codec.Decode(bytes.NewReader(tc.body), &got); err == nil {
+				t.Fatalf("expected error, decoded %+v", got)
+			}
+		})
+	}
+}
+
+func TestCodecs_NonFiniteBinaryFloatsStayOutOfRange(t *testing.T) {
+	var got struct {
+		A json.Number `json:"a"`
+	}
+	body := mustCBOR(t, map[string]any{"a": math.Inf(1)})
+	if err := (cborCodec{}).Decode(bytes.NewReader(body), &got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	f, err := parseJSONNumber(got.A)
+	if err != nil || !math.IsInf(f, 1) {
+		t.Fatalf("parseJSONNumber(%q) = %v, %v; want +Inf", got.A, f, err)
+	}
+}
+
+func TestCodecs_Encode(t *testing.T) {
+	v := struct {
+		Result float64  `json:"result"`
+		Tags   []string `json:"tags"`
+	}{Result: 3.75, Tags: []string{"a"}}
+
+	var xmlOut bytes.Buffer
+	if err := (xmlCodec{}).Encode(&xmlOut, v); err != nil {
+		t.Fatalf("xml Encode: %v", err)
+	}
+	if !strings.Contains(xmlOut.String(), "<response><result>3.75</result><tags><item>a</item></tags></response>") {
+		t.Fatalf("xml = %s", xmlOut.String())
+	}
+
+	var textOut bytes.Buffer
+	if err := (textCodec{}).Encode(&textOut, v); err != nil {
+		t.Fatalf("text Encode: %v", err)
+	}
+	if textOut.String() != "result=3.75\ntags=[\"a\"]\n" {
+		t.Fatalf("text = %q", textOut.String())
+	}
+
+	var cborOut bytes.Buffer
+	if err := (cborCodec{}).Encode(&cborOut, v); err != nil {
+		t.Fatalf("cbor Encode: %v", err)
+	}
+	var fromCBOR map[string]any
+	if err := cbor.Unmarshal(cborOut.Bytes(), &fromCBOR); err != nil || fromCBOR["result"] != 3.75 {
+		t.Fatalf("cbor round trip = %v (%v)", fromCBOR, err)
+	}
+
+	var mpOut bytes.Buffer
+	if err := (msgpackCodec{}).Encode(&mpOut, v); err != nil {
+		t.Fatalf("msgpack Encode: %v", err)
+	}
+	var fromMP map[string]any
+	if err := msgpack.Unmarshal(mpOut.Bytes(), &fromMP); err != nil || fromMP["result"] != 3.75 {
+		t.Fatalf("msgpack round trip = %v (%v)", fromMP, err)
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"erikkruuse/calculator/calculator"
+	service "erikkruuse/calculator/internal/services"
+)
+
+// complexRequest carries one or two complex operands. Each operand may be an
+// object {"re": 3, "im": 4}, a string such as "3+4i", or a plain number. For
+// rect, a is the modulus and b the angle in radians.
+type complexRequest struct {
+	A json.RawMessage `json:"a"`
+	B json.RawMessage `json:"b"`
+}
+
+type complexResponse struct {
+	// Result is a {"re","im"} object, a number (modulus, argument) or an
+	// {"r","theta"} object (polar).
+	Result any `json:"result"`
+	// Text is the "a+bi" form of complex results.
+	Text     string   `json:"text,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// parseComplexOperand decodes one operand in any of the accepted shapes.
+func parseComplexOperand(field string, raw json.RawMessage) (calculator.Complex, error) {
+	invalid := newProblem(ProblemInvalidInput, field, "a and b must be complex numbers")
+	raw = bytes.TrimSpace(raw)
+
+	var z calculator.Complex
+	switch raw[0] {
+	case '{':
+		var obj struct {
+			Re json.Number `json:"re"`
+			Im json.Number `json:"im"`
+		}
+		if err := decodeStrictJSON(bytes.NewReader(raw), &obj); err != nil {
+			return z, invalid
+		}
+		for _, part := range []struct {
+			n   json.Number
+			dst *float64
+		}{{obj.Re, &z.Re}, {obj.Im, &z.Im}} {
+			if part.n == "" {
+				continue
+			}
+			f, err := parseJSONNumber(part.n)
+			if err != nil {
+				return z, invalid
+			}
+			*part.dst = f
+		}
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return z, invalid
+		}
+		var err error
+		if z, err = calculator.ParseComplex(s); err != nil {
+			return z, invalid
+		}
+	default:
+		f, err := parseJSONNumber(json.Number(raw))
+		if err != nil {
+			return z, invalid
+		}
+		z.Re = f
+	}
+
+	if !isFinite(z.Re) || !isFinite(z.Im) {
+		return z, newProblem(ProblemInvalidInput, field, "inputs must be finite numbers")
+	}
+	return z, nil
+}
+
+const complexOpsHint = "use add|subtract|multiply|divide|conjugate|modulus|argument|polar|rect|sqrt|exp|log"
+
+func (a *API) complexOp(w http.ResponseWriter, r *http.Request) {
+	op := r.PathValue("op")
+	arity := service.ComplexArity(op)
+	if arity == 0 {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", complexOpsHint))
+		return
+	}
+
+	var req complexRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	raws := []json.RawMessage{req.A, req.B}
+	operands := make([]calculator.Complex, 0, arity)
+	for i, field := range []string{"a", "b"} {
+		switch {
+		case i >= arity && !isNull(raws[i]):
+			writeError(w, r, newProblem(ProblemInvalidInput, field, "b is not used by this operation"))
+			return
+		case i >= arity:
+		case isNull(raws[i]):
+			writeError(w, r, newProblem(ProblemMissingParams, field, "a and b are required"))
+			return
+		default:
+			z, err := parseComplexOperand(field, raws[i])
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			operands = append(operands, z)
+		}
+	}
+
+	res, err := a.svcFor(r).CalculateComplex(op, operands...)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := complexResponse{Warnings: res.Warnings}
+	switch {
+	case res.Value != nil:
+		resp.Result, resp.Text = res.Value, res.Value.String()
+	case res.Polar != nil:
+		resp.Result = res.Polar
+	case res.Real != nil:
+		resp.Result = *res.Real
+	}
+	Write(w, r, http.StatusOK, resp)
+}
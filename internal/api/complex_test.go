@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	service "erikkruuse/calculator/internal/services"
+)
+
+func TestComplexOp(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		op     string
+		body   string
+		status int
+		result string // JSON of the result member
+		text   string
+		field  string // problem field for errors
+	}{
+		{"objects", "multiply", `{"a":{"re":3,"im":4},"b":{"re":1,"im":-2}}`, 200, `{"re":11,"im":-2}`, "11-2i", ""},
+		{"strings", "add", `{"a":"3+4i","b":"-i"}`, 200, `{"re":3,"im":3}`, "3+3i", ""},
+		{"plain number", "sqrt", `{"a":-4}`, 200, `{"re":0,"im":2}`, "2i", ""},
+		{"modulus is real", "modulus", `{"a":"3+4i"}`, 200, `5`, "", ""},
+		{"polar", "polar", `{"a":{"re":-1}}`, 200, `{"r":1,"theta":3.141592653589793}`, "", ""},
+		{"rect", "rect", `{"a":2,"b":0}`, 200, `{"re":2,"im":0}`, "2", ""},
+		{"divide by zero", "divide", `{"a":"1+i","b":"0"}`, 400, "", "", "b"},
+		{"log of zero", "log", `{"a":0}`, 400, "", "", "a"},
+		{"malformed string", "add", `{"a":"3+4k","b":1}`, 400, "", "", "a"},
+		{"unknown member", "add", `{"a":{"re":1,"imag":2},"b":1}`, 400, "", "", "a"},
+		{"missing operand", "add", `{"a":1}`, 400, "", "", "b"},
+		{"extra operand", "conjugate", `{"a":1,"b":2}`, 400, "", "", "b"},
+		{"unknown op", "pow", `{"a":1,"b":2}`, 400, "", "", "op"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/complex/"+tc.op, tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Extensions["field"] != tc.field {
+					t.Fatalf("problem field = %v; want %q (%s)", p.Extensions["field"], tc.field, body)
+				}
+				return
+			}
+			var got struct {
+				Result json.RawMessage `json:"result"`
+				Text   string          `json:"text"`
+			}
+			json.Unmarshal(body, &got)
+			if string(got.Result) != tc.result || got.Text != tc.text {
+				t.Fatalf("got %s; want result=%s text=%q", body, tc.result, tc.text)
+			}
+		})
+	}
+}
+
+func TestComplexOp_RecordsComplexHistory(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	postRaw(t, srv.URL+"/v1/complex/conjugate", `{"a":"1+2i"}`, "application/json")
+	resp, body := get(t, srv.URL+"/v1/history")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	var h []service.HistoryEntry
+	json.Unmarshal(body, &h)
+	if len(h) != 1 || h[0].Mode != service.ModeComplex || h[0].Complex == nil || h[0].Complex.Im != -2 {
+		t.Fatalf("history = %s", body)
+	}
+}
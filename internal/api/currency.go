@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"erikkruuse/calculator/currency"
+	"erikkruuse/calculator/decimal"
+	service "erikkruuse/calculator/internal/services"
+)
+
+// convertRequest is the body of POST /v1/convert: either a single amount with
+// its currency or a list of amounts such as "12.50 USD" to sum, plus the
+// target currency and an optional rate date (default today).
+type convertRequest struct {
+	Amount  json.Number `json:"amount"`
+	From    string      `json:"from"`
+	Amounts []string    `json:"amounts"`
+	To      string      `json:"to"`
+	Date    string      `json:"date"`
+}
+
+type convertResponse struct {
+	Amount   string      `json:"amount"`
+	Currency string      `json:"currency"`
+	Text     string      `json:"text"`
+	Snapshot string      `json:"snapshot"`
+	Date     string      `json:"date"`
+	Rates    []rateQuote `json:"rates"`
+}
+
+// rateQuote is a rate used by a conversion, shown to ten decimals.
+type rateQuote struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Rate      string `json:"rate"`
+	Effective string `json:"effective"`
+}
+
+func (a *API) convertCurrency(w http.ResponseWriter, r *http.Request) {
+	var req convertRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	single := req.Amounts == nil
+	amounts, err := req.amounts()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var date time.Time
+	if req.Date != "" {
+		if date, err = time.Parse(time.DateOnly, req.Date); err != nil {
+			writeError(w, r, newProblem(ProblemInvalidInput, "date", "date must be YYYY-MM-DD"))
+			return
+		}
+	}
+
+	res, err := a.svcFor(r).ConvertCurrency(amounts, req.To, date)
+	var ie *service.InputError
+	switch {
+	case errors.Is(err, currency.ErrNoRates):
+		writeProblem(w, r, ProblemRatesUnavailable, err.Error(), nil)
+		return
+	case errors.As(err, &ie) && single && ie.Field == "amounts":
+		writeError(w, r, newProblem(ProblemInvalidInput, "from", ie.Msg))
+		return
+	case err != nil:
+		writeError(w, r, err)
+		return
+	}
+
+	resp := convertResponse{
+		Text:     res.Text,
+		Snapshot: res.Snapshot,
+		Date:     res.Date.Format(time.DateOnly),
+		Rates:    []rateQuote{},
+	}
+	resp.Amount, resp.Currency, _ = strings.Cut(res.Text, " ")
+	for _, q := range res.Quotes {
+		resp.Rates = append(resp.Rates, rateQuote{
+			From:      q.From,
+			To:        q.To,
+			Rate:      decimal.Format(q.Rate, 10),
+			Effective: q.Effective.Format(time.DateOnly),
+		})
+	}
+	Write(w, r, http.StatusOK, resp)
+}
+
+// amounts validates the two request forms and parses the amounts.
+func (req convertRequest) amounts() ([]currency.Amount, error) {
+	switch {
+	case req.To == "":
+		return nil, newProblem(ProblemMissingParams, "to", "to is required")
+	case req.Amounts != nil && (req.Amount != "" || req.From != ""):
+		return nil, newProblem(ProblemInvalidInput, "amounts", "give either amount and from or amounts, not both")
+	case req.Amounts == nil && (req.Amount == "" || req.From == ""):
+		return nil, newProblem(ProblemMissingParams, "", "amount and from are required")
+	}
+
+	if req.Amounts == nil {
+		v, err := decimal.Parse(req.Amount.String())
+		if err != nil {
+			return nil, newProblem(ProblemInvalidInput, "amount", currency.ErrInvalidAmount.Error())
+		}
+		return []currency.Amount{{Value: v, Code: req.From}}, nil
+	}
+	out := make([]currency.Amount, 0, len(req.Amounts))
+	for i, s := range req.Amounts {
+		a, err := currency.ParseAmount(s)
+		if err != nil {
+			return nil, newProblem(ProblemInvalidInput, fmt.Sprintf("amounts[%d]", i), err.Error())
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
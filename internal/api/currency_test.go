@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"erikkruuse/calculator/currency"
+	service "erikkruuse/calculator/internal/services"
+)
+
+func newCurrencyServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	os.WriteFile(path, []byte(`{"base": "EUR", "rates": [
+		{"currency": "USD", "rate": "1.0850", "effective": "2026-10-01"},
+		{"currency": "USD", "rate": "1.1000", "effective": "2026-10-15"},
+		{"currency": "JPY", "rate": "162.35", "effective": "2026-10-01"}
+	]}`), 0o644)
+	src, err := currency.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	New(service.NewCalculatorService(service.WithRates(src))).RegisterRoutes(mux)
+	return httptest.NewServer(mux)
+}
+
+func TestConvert(t *testing.T) {
+	srv := newCurrencyServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		text   string
+		typ    string // problem code for errors
+		field  string
+	}{
+		{"single", `{"amount":"100","from":"USD","to":"EUR","date":"2026-10-10"}`, 200, "92.17 EUR", "", ""},
+		{"numeric amount", `{"amount":100,"from":"USD","to":"EUR","date":"2026-10-10"}`, 200, "92.17 EUR", "", ""},
+		{"later rate", `{"amount":"100","from":"USD","to":"EUR","date":"2026-10-20"}`, 200, "90.91 EUR", "", ""},
+		{"no minor unit", `{"amount":"9.99","from":"EUR","to":"JPY","date":"2026-10-10"}`, 200, "1622 JPY", "", ""},
+		{"sum", `{"amounts":["100 USD","EUR 7.5","-1000 JPY"],"to":"EUR","date":"2026-10-10"}`, 200, "93.51 EUR", "", ""},
+		{"before first rate", `{"amount":"1","from":"USD","to":"EUR","date":"2026-01-01"}`, 400, "", "domain_error", "date"},
+		{"unknown from", `{"amount":"1","from":"CHF","to":"EUR"}`, 400, "", "invalid_input", "from"},
+		{"unknown to", `{"amount":"1","from":"USD","to":"CHF"}`, 400, "", "invalid_input", "to"},
+		{"bad amount", `{"amount":"1/3","from":"USD","to":"EUR"}`, 400, "", "invalid_json", ""},
+		{"bad list amount", `{"amounts":["1 USD","one EUR"],"to":"EUR"}`, 400, "", "invalid_input", "amounts[1]"},
+		{"bad date", `{"amount":"1","from":"USD","to":"EUR","date":"10/10/2026"}`, 400, "", "invalid_input", "date"},
+		{"both forms", `{"amount":"1","from":"USD","amounts":["1 USD"],"to":"EUR"}`, 400, "", "invalid_input", "amounts"},
+		{"missing to", `{"amount":"1","from":"USD"}`, 400, "", "missing_params", "to"},
+		{"missing from", `{"amount":"1","to":"EUR"}`, 400, "", "missing_params", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/convert", tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+			}
+			if tc.status == 200 {
+				var got convertResponse
+				json.Unmarshal(body, &got)
+				if got.Text != tc.text || got.Snapshot == "" || len(got.Rates) == 0 {
+					t.Fatalf("response = %s; want text %q", body, tc.text)
+				}
+				return
+			}
+			var p Problem
+			json.Unmarshal(body, &p)
+			if p.Title != tc.typ || (tc.field != "" && p.Extensions["field"] != tc.field) {
+				t.Fatalf("problem = %s; want %s on %q", body, tc.typ, tc.field)
+			}
+		})
+	}
+}
+
+func TestConvert_HistoryKeepsSnapshot(t *testing.T) {
+	srv := newCurrencyServer(t)
+	defer srv.Close()
+
+	_, body := postRaw(t, srv.URL+"/v1/convert", `{"amount":"100","from":"USD","to":"EUR","date":"2026-10-10"}`, "application/json")
+	var got convertResponse
+	json.Unmarshal(body, &got)
+	if got.Amount != "92.17" || got.Currency != "EUR" || got.Rates[0].Rate != "0.9216589862" || got.Rates[0].Effective != "2026-10-01" {
+		t.Fatalf("response = %s", body)
+	}
+
+	_, body = get(t, srv.URL+"/v1/history")
+	var h []service.HistoryEntry
+	json.Unmarshal(body, &h)
+	if len(h) != 1 || h[0].Rates == nil || h[0].Rates.ID != got.Snapshot || h[0].Rates.Rates["USD"] != "200/217" {
+		t.Fatalf("history = %s", body)
+	}
+}
+
+func TestConvert_NoRateTable(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, body := postRaw(t, srv.URL+"/v1/convert", `{"amount":"1","from":"USD","to":"EUR"}`, "application/json")
+	var p Problem
+	json.Unmarshal(body, &p)
+	if resp.StatusCode != http.StatusServiceUnavailable || p.Title != ProblemRatesUnavailable.Code {
+		t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+	}
+}
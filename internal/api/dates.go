@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"erikkruuse/calculator/temporal"
+)
+
+// datesRequest is the body of POST /v1/dates/{op}. Operands are dates,
+// date-times, durations ("P45D" or "45 days") or integers; dates without an
+// offset are read in zone (default UTC).
+type datesRequest struct {
+	A        string `json:"a"`
+	B        string `json:"b"`
+	Zone     string `json:"zone"`
+	Calendar string `json:"calendar"`
+}
+
+const dateOpsHint = "use add|subtract|diff|business_days|add_business_days"
+
+func (a *API) datesOp(w http.ResponseWriter, r *http.Request) {
+	op := r.PathValue("op")
+	if !temporal.IsOp(op) {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", dateOpsHint))
+		return
+	}
+
+	var req datesRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.A == "" || req.B == "" {
+		writeError(w, r, newProblem(ProblemMissingParams, "", "a and b are required"))
+		return
+	}
+	loc, err := temporal.LoadZone(req.Zone)
+	if err != nil {
+		writeError(w, r, newProblem(ProblemInvalidInput, "zone", err.Error()))
+		return
+	}
+	var operands [2]temporal.Value
+	for i, in := range [2]struct{ field, text string }{{"a", req.A}, {"b", req.B}} {
+		if operands[i], err = temporal.Parse(in.text, loc); err != nil {
+			writeError(w, r, newProblem(ProblemInvalidInput, in.field, err.Error()))
+			return
+		}
+	}
+
+	res, err := a.svcFor(r).CalculateDate(op, operands[0], operands[1], req.Calendar)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	Write(w, r, http.StatusOK, res)
+}
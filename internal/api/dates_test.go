@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	service "erikkruuse/calculator/internal/services"
+	"erikkruuse/calculator/temporal"
+)
+
+func TestDatesOp(t *testing.T) {
+	cal, err := temporal.ParseCalendar("de", strings.NewReader(`{"holidays": [{"date": "2026-04-03"}, {"date": "2026-04-06"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	New(service.NewCalculatorService(service.WithCalendars(map[string]*temporal.Calendar{"de": cal}))).RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		op     string
+		body   string
+		status int
+		want   string // {"type", "value"} of the result
+		typ    string // problem code for errors
+		field  string
+	}{
+		{"add days", "add", `{"a":"2026-03-01","b":"45 days"}`, 200, `{"type":"datetime","value":"2026-04-15T00:00:00Z"}`, "", ""},
+		{"add iso in zone", "add", `{"a":"2026-03-28T12:00","b":"P1D","zone":"Europe/Berlin"}`, 200, `{"type":"datetime","value":"2026-03-29T12:00:00+02:00[Europe/Berlin]"}`, "", ""},
+		{"diff", "diff", `{"a":"2026-03-01","b":"2026-04-15T06:30"}`, 200, `{"type":"duration","value":"P1M14DT6H30M"}`, "", ""},
+		{"business days", "business_days", `{"a":"2026-04-01","b":"2026-04-08","calendar":"de"}`, 200, `{"type":"integer","value":"3"}`, "", ""},
+		{"add business days", "add_business_days", `{"a":"2026-04-02","b":"1","calendar":"de"}`, 200, `{"type":"datetime","value":"2026-04-07T00:00:00Z"}`, "", ""},
+		{"wrong types", "add", `{"a":"2026-03-01","b":"2026-03-02"}`, 400, "", "domain_error", "b"},
+		{"bad operand", "add", `{"a":"2026-02-30","b":"P1D"}`, 400, "", "invalid_input", "a"},
+		{"bad zone", "add", `{"a":"2026-03-01","b":"P1D","zone":"Mars/Olympus"}`, 400, "", "invalid_input", "zone"},
+		{"unknown calendar", "business_days", `{"a":"2026-03-01","b":"2026-03-09","calendar":"xx"}`, 400, "", "invalid_input", "calendar"},
+		{"missing b", "diff", `{"a":"2026-03-01"}`, 400, "", "missing_params", ""},
+		{"unknown op", "weekday", `{"a":"2026-03-01","b":"1"}`, 400, "", "invalid_op", "op"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/dates/"+tc.op, tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+			}
+			if tc.status == 200 {
+				if got := strings.TrimSpace(string(body)); got != tc.want {
+					t.Fatalf("body = %s; want %s", got, tc.want)
+				}
+				return
+			}
+			var p Problem
+			json.Unmarshal(body, &p)
+			if p.Title != tc.typ || (tc.field != "" && p.Extensions["field"] != tc.field) {
+				t.Fatalf("problem = %s; want %s on %q", body, tc.typ, tc.field)
+			}
+		})
+	}
+
+	_, body := get(t, srv.URL+"/v1/history")
+	var h []service.HistoryEntry
+	json.Unmarshal(body, &h)
+	if len(h) == 0 || h[len(h)-1].Mode != service.ModeDates || len(h[len(h)-1].DateOperands) != 2 || h[len(h)-1].DateResult == nil {
+		t.Fatalf("history = %s", body)
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/expr"
+)
+
+// evaluateRequest is the body of POST /v1/evaluate. mode is float64 (the
+// default), where a variable may be a measured value {"value": 9.81,
+// "uncertainty": 0.02} whose uncertainty is propagated to the result, or an
+// integer type such as int32, in which expr is read by expr.ParseInt and vars
+// are integers in binary, octal, decimal or hex, as numbers or strings.
+// overflow and base work as in GET /v1/calculate.
+type evaluateRequest struct {
+	Expr     string                     `json:"expr"`
+	Vars     map[string]json.RawMessage `json:"vars"`
+	Mode     string                     `json:"mode"`
+	Overflow string                     `json:"overflow"`
+	Base     string                     `json:"base"`
+}
+
+// evaluateResponse is the result of a float64 evaluation; integer mode
+// answers with an intResponse.
+type evaluateResponse struct {
+	Mode   string  `json:"mode"`
+	Result float64 `json:"result"`
+	// Uncertainty is the propagated standard uncertainty when a variable
+	// was a measured value.
+	Uncertainty *float64 `json:"uncertainty,omitempty"`
+}
+
+func (a *API) evaluate(w http.ResponseWriter, r *http.Request) {
+	var req evaluateRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Expr == "" {
+		writeError(w, r, newProblem(ProblemMissingParams, "expr", "expr is required"))
+		return
+	}
+
+	var (
+		res any
+		err error
+	)
+	switch req.Mode {
+	case "", "float64":
+		res, err = req.evalFloat()
+	default:
+		t, ok := calculator.ParseIntType(req.Mode)
+		if !ok {
+			writeError(w, r, newProblem(ProblemInvalidInput, "mode", "mode must be float64 or one of int8|int16|int32|int64|uint8|uint16|uint32|uint64"))
+			return
+		}
+		res, err = req.evalInt(t)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	Write(w, r, http.StatusOK, res)
+}
+
+func (req *evaluateRequest) evalFloat() (any, error) {
+	for _, field := range []struct{ name, value string }{{"overflow", req.Overflow}, {"base", req.Base}} {
+		if field.value != "" {
+			return nil, newProblem(ProblemInvalidInput, field.name, "operand is not used by this operation")
+		}
+	}
+	n, err := parseExpr(req.Expr)
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]calculator.Uncertain{}
+	measured := false
+	for _, name := range expr.Vars(n) {
+		field := "vars." + name
+		raw, ok := req.Vars[name]
+		if !ok {
+			return nil, newProblem(ProblemMissingParams, field, "variable has no value")
+		}
+		var m measurement
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, newProblem(ProblemInvalidInput, field, "inputs must be finite numbers")
+		}
+		x, err := m.parse(field, "inputs must be finite numbers")
+		if err != nil {
+			return nil, err
+		}
+		vars[name] = x
+		measured = measured || x.Uncertainty != 0
+	}
+	if !measured {
+		values := make(map[string]float64, len(vars))
+		for name, x := range vars {
+			values[name] = x.Value
+		}
+		v, err := expr.Eval(n, values)
+		if err != nil {
+			return nil, exprError(err)
+		}
+		return evaluateResponse{Mode: "float64", Result: v}, nil
+	}
+	u, err := expr.EvalUncertain(n, vars)
+	if err != nil {
+		return nil, exprError(err)
+	}
+	return evaluateResponse{Mode: "float64", Result: u.Value, Uncertainty: &u.Uncertainty}, nil
+}
+
+func (req *evaluateRequest) evalInt(t calculator.IntType) (any, error) {
+	wrap, base, err := intOptions(req.Overflow, req.Base)
+	if err != nil {
+		return nil, err
+	}
+	e, err := expr.ParseInt(req.Expr)
+	if err != nil {
+		return nil, syntaxProblem("expr", err)
+	}
+	vars := map[string]calculator.Int{}
+	for _, name := range e.Vars() {
+		raw, ok := req.Vars[name]
+		if !ok {
+			return nil, newProblem(ProblemMissingParams, "vars."+name, "variable has no value")
+		}
+		s := string(bytes.TrimSpace(raw))
+		if len(s) > 0 && s[0] == '"' {
+			if err := json.Unmarshal(raw, &s); err != nil {
+				s = ""
+			}
+		}
+		v, err := t.Parse(s, wrap)
+		switch {
+		case errors.Is(err, calculator.ErrIntRange):
+			return nil, newProblem(ProblemOverflow, "vars."+name, "value is out of range for the integer type")
+		case err != nil:
+			return nil, newProblem(ProblemInvalidInput, "vars."+name, "variables must be integers in binary, octal, decimal or hex")
+		}
+		vars[name] = v
+	}
+	x, err := e.Eval(t, vars, wrap)
+	if err != nil {
+		return nil, exprError(err)
+	}
+	return newIntResponse(x, base), nil
+}
+
+// exprError reports an evaluation error against expr: the operand a or b of
+// the operation that failed means nothing to the caller.
+func exprError(err error) error {
+	var ce *calculator.Error
+	if errors.As(err, &ce) && (ce.Field == "a" || ce.Field == "b") {
+		c := *ce
+		c.Field = "expr"
+		return &c
+	}
+	return err
+}
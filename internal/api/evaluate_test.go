@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name        string
+		body        string
+		status      int
+		result      string // compact JSON of result
+		uncertainty string // compact JSON of uncertainty, "" when absent
+		code        string // problem title for errors
+		field       string
+	}{
+		{"float", `{"expr":"2x^2 + y","vars":{"x":3,"y":0.5}}`, 200, `18.5`, "", "", ""},
+		{"float constants", `{"expr":"cos(pi)","mode":"float64"}`, 200, `-1`, "", "", ""},
+		{"measured var", `{"expr":"x*y","vars":{"x":{"value":9.81,"uncertainty":0.02},"y":2}}`, 200, `19.62`, `0.04`, "", ""},
+		{"exact measured var", `{"expr":"x+1","vars":{"x":{"value":2}}}`, 200, `3`, "", "", ""},
+		{"negative uncertainty", `{"expr":"x","vars":{"x":{"value":1,"uncertainty":-1}}}`, 400, "", "", "invalid_input", "vars.x"},
+		{"int hex", `{"expr":"(flags & 0xF0) >> 4","vars":{"flags":"0xA5"},"mode":"uint8","base":"hex"}`, 200, `"0xa"`, "", "", ""},
+		{"int wraps", `{"expr":"x + 1","vars":{"x":255},"mode":"uint8"}`, 200, `"0"`, "", "", ""},
+		{"int bit pattern", `{"expr":"0xFF","mode":"int8"}`, 200, `"-1"`, "", "", ""},
+		{"int overflow error", `{"expr":"x + 1","vars":{"x":255},"mode":"uint8","overflow":"error"}`, 400, "", "", "overflow", ""},
+		{"int division by zero", `{"expr":"1 / (x - x)","vars":{"x":"0b11"},"mode":"int32"}`, 400, "", "", "calculation_error", "expr"},
+		{"int var out of range", `{"expr":"x","vars":{"x":"256"},"mode":"uint8","overflow":"error"}`, 400, "", "", "overflow", "vars.x"},
+		{"int bad var", `{"expr":"x","vars":{"x":1.5},"mode":"int32"}`, 400, "", "", "invalid_input", "vars.x"},
+		{"int syntax", `{"expr":"1 +* 2","mode":"int32"}`, 400, "", "", "invalid_input", "expr"},
+		{"missing var", `{"expr":"x * y","vars":{"x":1}}`, 400, "", "", "missing_params", "vars.y"},
+		{"bad float var", `{"expr":"x","vars":{"x":"one"}}`, 400, "", "", "invalid_input", "vars.x"},
+		{"domain", `{"expr":"sqrt(x)","vars":{"x":-1}}`, 400, "", "", "domain_error", ""},
+		{"base in float mode", `{"expr":"1","base":"hex"}`, 400, "", "", "invalid_input", "base"},
+		{"bad mode", `{"expr":"1","mode":"int128"}`, 400, "", "", "invalid_input", "mode"},
+		{"bad base", `{"expr":"1","mode":"int8","base":"3"}`, 400, "", "", "invalid_input", "base"},
+		{"missing expr", `{}`, 400, "", "", "missing_params", "expr"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/evaluate", tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != orNil(tc.field) {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got struct {
+				Result      json.RawMessage `json:"result"`
+				Uncertainty json.RawMessage `json:"uncertainty"`
+			}
+			json.Unmarshal(body, &got)
+			if string(got.Result) != tc.result || string(got.Uncertainty) != tc.uncertainty {
+				t.Fatalf("response = %s; want result %s ± %s", body, tc.result, tc.uncertainty)
+			}
+		})
+	}
+}
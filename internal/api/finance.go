@@ -0,0 +1,332 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"erikkruuse/calculator/decimal"
+	"erikkruuse/calculator/finance"
+)
+
+// financeRequest carries the arguments of POST /v1/finance/{fn}. Amounts and
+// rates are JSON numbers read as exact decimals; rates are per period except
+// for compound, which takes a nominal annual rate. Money is rounded to places
+// decimals (default 2, at most 10).
+type financeRequest struct {
+	Rate        json.Number   `json:"rate"`
+	Periods     *int          `json:"periods"`
+	Payment     json.Number   `json:"payment"`
+	PV          json.Number   `json:"pv"`
+	FV          json.Number   `json:"fv"`
+	When        string        `json:"when"`
+	Cashflows   []json.Number `json:"cashflows"`
+	Principal   json.Number   `json:"principal"`
+	Years       json.Number   `json:"years"`
+	Compounding string        `json:"compounding"`
+	Cost        json.Number   `json:"cost"`
+	Salvage     json.Number   `json:"salvage"`
+	Life        *int          `json:"life"`
+	Method      string        `json:"method"`
+	Factor      json.Number   `json:"factor"`
+	Places      *int          `json:"places"`
+}
+
+// financeResponse holds the result of a function. Schedule functions also
+// return one row per period.
+type financeResponse struct {
+	Result        string `json:"result"`
+	TotalInterest string `json:"total_interest,omitempty"`
+	Interest      string `json:"interest,omitempty"`
+	Schedule      any    `json:"schedule,omitempty"`
+}
+
+type paymentRow struct {
+	Period    int    `json:"period"`
+	Payment   string `json:"payment"`
+	Interest  string `json:"interest"`
+	Principal string `json:"principal"`
+	Balance   string `json:"balance"`
+}
+
+type depreciationRow struct {
+	Period       int    `json:"period"`
+	Depreciation string `json:"depreciation"`
+	Accumulated  string `json:"accumulated"`
+	BookValue    string `json:"book_value"`
+}
+
+// financeFns lists the arguments each function requires and accepts.
+var financeFns = map[string]struct{ required, optional []string }{
+	"pv":           {[]string{"rate", "periods", "payment"}, []string{"fv", "when"}},
+	"fv":           {[]string{"rate", "periods"}, []string{"payment", "pv", "when"}},
+	"pmt":          {[]string{"rate", "periods", "pv"}, []string{"fv", "when"}},
+	"npv":          {[]string{"rate", "cashflows"}, nil},
+	"irr":          {[]string{"cashflows"}, nil},
+	"amortization": {[]string{"principal", "rate", "periods"}, nil},
+	"compound":     {[]string{"principal", "rate", "years"}, []string{"compounding"}},
+	"depreciation": {[]string{"cost", "life"}, []string{"salvage", "method", "factor"}},
+}
+
+// compoundings maps compounding names to periods per year; zero is continuous.
+var compoundings = map[string]int{
+	"annually":     1,
+	"semiannually": 2,
+	"quarterly":    4,
+	"monthly":      12,
+	"weekly":       52,
+	"daily":        365,
+	"continuous":   0,
+}
+
+const (
+	defaultFinancePlaces = 2
+	maxFinancePlaces     = 10
+	// irrPlaces is the precision of the rate returned by irr.
+	irrPlaces = 10
+	// maxFinanceDigits and the exponent range bound the numbers an argument
+	// may be written with, and so the size of every amount derived from it.
+	maxFinanceDigits   = 30
+	minFinanceExponent = -1000
+	maxFinanceExponent = 30
+)
+
+// check rejects missing arguments and arguments the function does not use.
+func (req *financeRequest) check(fn string) error {
+	present := map[string]bool{
+		"rate":        req.Rate != "",
+		"periods":     req.Periods != nil,
+		"payment":     req.Payment != "",
+		"pv":          req.PV != "",
+		"fv":          req.FV != "",
+		"when":        req.When != "",
+		"cashflows":   req.Cashflows != nil,
+		"principal":   req.Principal != "",
+		"years":       req.Years != "",
+		"compounding": req.Compounding != "",
+		"cost":        req.Cost != "",
+		"salvage":     req.Salvage != "",
+		"life":        req.Life != nil,
+		"method":      req.Method != "",
+		"factor":      req.Factor != "",
+	}
+	spec := financeFns[fn]
+	for _, field := range spec.required {
+		if !present[field] {
+			return newProblem(ProblemMissingParams, field, "missing operand for this operation")
+		}
+		delete(present, field)
+	}
+	for _, field := range spec.optional {
+		delete(present, field)
+	}
+	for field, ok := range present {
+		if ok {
+			return newProblem(ProblemInvalidInput, field, "operand is not used by this operation")
+		}
+	}
+	if req.Places != nil && (*req.Places < 0 || *req.Places > maxFinancePlaces) {
+		return newProblem(ProblemInvalidInput, "places", "places must be between 0 and 10")
+	}
+	return nil
+}
+
+// num parses an optional decimal argument, returning def when it is absent.
+// The digits and exponent are checked before parsing: exact arithmetic on a
+// number such as 1e100000 is slow, and schedules would print it in every row.
+func num(field string, n json.Number, def int64) (*big.Rat, error) {
+	if n == "" {
+		return big.NewRat(def, 1), nil
+	}
+	mant, exp, hasExp := strings.Cut(strings.ToLower(n.String()), "e")
+	digits := 0
+	for _, c := range mant {
+		if '0' <= c && c <= '9' {
+			digits++
+		}
+	}
+	e, err := strconv.Atoi(exp)
+	if digits > maxFinanceDigits || (hasExp && (err != nil || e < minFinanceExponent || e > maxFinanceExponent)) {
+		return nil, newProblem(ProblemInvalidInput, field, "number must have at most 30 digits and an exponent between -1000 and 30")
+	}
+	v, err := decimal.Parse(n.String())
+	if err != nil {
+		return nil, newProblem(ProblemInvalidInput, field, err.Error())
+	}
+	return v, nil
+}
+
+func (a *API) financeFn(w http.ResponseWriter, r *http.Request) {
+	fn := r.PathValue("fn")
+	if _, ok := financeFns[fn]; !ok {
+		writeError(w, r, newProblem(ProblemInvalidOp, "fn", "use pv|fv|pmt|npv|irr|amortization|compound|depreciation"))
+		return
+	}
+
+	var req financeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if err := req.check(fn); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), computeTimeout)
+	defer cancel()
+	resp, err := req.eval(ctx, fn)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = &problemError{typ: ProblemBudgetExceeded, detail: "computation ran out of time", ext: map[string]any{"limit": "time"}}
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	Write(w, r, http.StatusOK, resp)
+}
+
+// eval parses the arguments of fn and runs it until ctx is done.
+func (req *financeRequest) eval(ctx context.Context, fn string) (financeResponse, error) {
+	places := defaultFinancePlaces
+	if req.Places != nil {
+		places = *req.Places
+	}
+	var (
+		args  = map[string]*big.Rat{}
+		err   error
+		flows []*big.Rat
+	)
+	for _, arg := range []struct {
+		field string
+		n     json.Number
+		def   int64
+	}{
+		{"rate", req.Rate, 0}, {"payment", req.Payment, 0}, {"pv", req.PV, 0}, {"fv", req.FV, 0},
+		{"principal", req.Principal, 0}, {"years", req.Years, 0}, {"cost", req.Cost, 0},
+		{"salvage", req.Salvage, 0}, {"factor", req.Factor, 2},
+	} {
+		if args[arg.field], err = num(arg.field, arg.n, arg.def); err != nil {
+			return financeResponse{}, err
+		}
+	}
+	for i, n := range req.Cashflows {
+		f, err := num(fmt.Sprintf("cashflows[%d]", i), n, 0)
+		if err != nil {
+			return financeResponse{}, err
+		}
+		flows = append(flows, f)
+	}
+	periods := 0
+	if req.Periods != nil {
+		periods = *req.Periods
+	}
+	when := finance.End
+	switch req.When {
+	case "", "end":
+	case "begin":
+		when = finance.Begin
+	default:
+		return financeResponse{}, newProblem(ProblemInvalidInput, "when", "when must be end or begin")
+	}
+
+	var res *big.Rat
+	switch fn {
+	case "pv":
+		res, err = finance.PV(args["rate"], periods, args["payment"], args["fv"], when)
+	case "fv":
+		res, err = finance.FV(args["rate"], periods, args["payment"], args["pv"], when)
+	case "pmt":
+		res, err = finance.PMT(args["rate"], periods, args["pv"], args["fv"], when)
+	case "npv":
+		res, err = finance.NPV(args["rate"], flows)
+	case "irr":
+		if req.Places == nil {
+			places = irrPlaces
+		}
+		res, err = finance.IRR(ctx, flows)
+	case "amortization":
+		return amortization(ctx, args["principal"], args["rate"], periods, places)
+	case "compound":
+		return compound(ctx, args["principal"], args["rate"], args["years"], req.Compounding, places)
+	case "depreciation":
+		return depreciation(ctx, req.Method, args["cost"], args["salvage"], *req.Life, args["factor"], places)
+	}
+	if err != nil {
+		return financeResponse{}, err
+	}
+	return financeResponse{Result: decimal.Format(res, places)}, nil
+}
+
+func amortization(ctx context.Context, principal, rate *big.Rat, periods, places int) (financeResponse, error) {
+	am, err := finance.Amortize(ctx, principal, rate, periods, places)
+	if err != nil {
+		return financeResponse{}, err
+	}
+	rows := make([]paymentRow, len(am.Schedule))
+	for i, p := range am.Schedule {
+		rows[i] = paymentRow{
+			Period:    p.Period,
+			Payment:   decimal.Format(p.Payment, places),
+			Interest:  decimal.Format(p.Interest, places),
+			Principal: decimal.Format(p.Principal, places),
+			Balance:   decimal.Format(p.Balance, places),
+		}
+	}
+	return financeResponse{
+		Result:        decimal.Format(am.Payment, places),
+		TotalInterest: decimal.Format(am.TotalInterest, places),
+		Schedule:      rows,
+	}, nil
+}
+
+// compound accepts a compounding name or a number of periods per year;
+// the default is annual.
+func compound(ctx context.Context, principal, rate, years *big.Rat, compounding string, places int) (financeResponse, error) {
+	perYear, ok := compoundings[compounding]
+	switch {
+	case compounding == "":
+		perYear = 1
+	case !ok:
+		n, err := strconv.Atoi(compounding)
+		if err != nil || n < 1 {
+			return financeResponse{}, newProblem(ProblemInvalidInput, "compounding", "compounding must be annually|semiannually|quarterly|monthly|weekly|daily|continuous or a positive number of periods per year")
+		}
+		perYear = n
+	}
+	amount, err := finance.Compound(ctx, principal, rate, years, perYear)
+	if err != nil {
+		return financeResponse{}, err
+	}
+	return financeResponse{
+		Result:   decimal.Format(amount, places),
+		Interest: decimal.Format(new(big.Rat).Sub(amount, principal), places),
+	}, nil
+}
+
+// depreciation defaults to the straight-line method.
+func depreciation(ctx context.Context, method string, cost, salvage *big.Rat, life int, factor *big.Rat, places int) (financeResponse, error) {
+	if method == "" {
+		method = finance.StraightLine
+	}
+	sched, err := finance.Depreciate(ctx, method, cost, salvage, life, factor, places)
+	if err != nil {
+		return financeResponse{}, err
+	}
+	rows := make([]depreciationRow, len(sched))
+	for i, d := range sched {
+		rows[i] = depreciationRow{
+			Period:       d.Period,
+			Depreciation: decimal.Format(d.Expense, places),
+			Accumulated:  decimal.Format(d.Accumulated, places),
+			BookValue:    decimal.Format(d.BookValue, places),
+		}
+	}
+	return financeResponse{
+		Result:   decimal.Format(sched[len(sched)-1].Accumulated, places),
+		Schedule: rows,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFinanceFn(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		fn     string
+		body   string
+		status int
+		result string
+		code   string // problem title for errors
+		field  string
+	}{
+		{"pmt", "pmt", `{"rate":0.005,"periods":360,"pv":200000}`, 200, "-1199.10", "", ""},
+		{"fv begin", "fv", `{"rate":0.05,"periods":10,"payment":-100,"when":"begin"}`, 200, "1320.68", "", ""},
+		{"pv places", "pv", `{"rate":0.08,"periods":20,"payment":500,"places":4}`, 200, "-4909.0737", "", ""},
+		{"npv", "npv", `{"rate":0.1,"cashflows":[-1000,300,400,500]}`, 200, "-21.04", "", ""},
+		{"irr", "irr", `{"cashflows":[-100,110]}`, 200, "0.1000000000", "", ""},
+		{"compound monthly", "compound", `{"principal":1000,"rate":0.05,"years":10,"compounding":"monthly"}`, 200, "1647.01", "", ""},
+		{"compound per year", "compound", `{"principal":1000,"rate":0.05,"years":10,"compounding":"12"}`, 200, "1647.01", "", ""},
+		{"exact decimals", "fv", `{"rate":0.1,"periods":1,"pv":-0.1,"places":10}`, 200, "0.1100000000", "", ""},
+		{"long rate", "fv", `{"rate":0.0123456789123456789,"periods":20000,"pv":-1e-100}`, 200, "3772847.43", "", ""},
+		{"growth out of range", "fv", `{"rate":1,"periods":5000,"pv":-1}`, 400, "", "overflow", "rate"},
+		{"irr no sign change", "irr", `{"cashflows":[100,200]}`, 400, "", "domain_error", "cashflows"},
+		{"irr no convergence", "irr", `{"cashflows":[-1,0.000001]}`, 400, "", "calculation_error", "cashflows"},
+		{"bad periods", "fv", `{"rate":0.1,"periods":0}`, 400, "", "domain_error", "periods"},
+		{"bad when", "pv", `{"rate":0.1,"periods":2,"payment":1,"when":"middle"}`, 400, "", "invalid_input", "when"},
+		{"bad compounding", "compound", `{"principal":1,"rate":0.1,"years":1,"compounding":"hourly"}`, 400, "", "invalid_input", "compounding"},
+		{"continuous rate too low", "compound", `{"principal":1,"rate":-1e30,"years":1,"compounding":"continuous"}`, 400, "", "domain_error", "rate"},
+		{"huge principal", "amortization", `{"principal":1e1000000,"rate":0.01,"periods":20000}`, 400, "", "invalid_input", "principal"},
+		{"too many digits", "npv", `{"rate":0.1,"cashflows":[1234567890123456789012345678901]}`, 400, "", "invalid_input", "cashflows[0]"},
+		{"bad method", "depreciation", `{"cost":100,"life":3,"method":"sideways"}`, 400, "", "domain_error", "method"},
+		{"bad places", "npv", `{"rate":0.1,"cashflows":[1],"places":11}`, 400, "", "invalid_input", "places"},
+		{"missing", "pmt", `{"rate":0.1,"periods":2}`, 400, "", "missing_params", "pv"},
+		{"unused", "irr", `{"cashflows":[-1,2],"rate":0.1}`, 400, "", "invalid_input", "rate"},
+		{"unknown fn", "xirr", `{}`, 400, "", "invalid_op", "fn"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/finance/"+tc.fn, tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != tc.field {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got financeResponse
+			json.Unmarshal(body, &got)
+			if got.Result != tc.result {
+				t.Fatalf("result = %s; want %s", got.Result, tc.result)
+			}
+		})
+	}
+}
+
+func TestFinanceFn_Schedules(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, body := postRaw(t, srv.URL+"/v1/finance/amortization", `{"principal":1000,"rate":0.01,"periods":12}`, "application/json")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+	}
+	var am struct {
+		Result        string       `json:"result"`
+		TotalInterest string       `json:"total_interest"`
+		Schedule      []paymentRow `json:"schedule"`
+	}
+	json.Unmarshal(body, &am)
+	if am.Result != "88.85" || am.TotalInterest != "66.19" || len(am.Schedule) != 12 {
+		t.Fatalf("amortization = %s", body)
+	}
+	if first := am.Schedule[0]; first != (paymentRow{1, "88.85", "10.00", "78.85", "921.15"}) {
+		t.Fatalf("first row = %+v", first)
+	}
+	if last := am.Schedule[11]; last.Balance != "0.00" {
+		t.Fatalf("last row = %+v", last)
+	}
+
+	resp, body = postRaw(t, srv.URL+"/v1/finance/depreciation", `{"cost":10000,"salvage":1000,"life":5,"method":"declining_balance"}`, "application/json")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+	}
+	var dep struct {
+		Result   string            `json:"result"`
+		Schedule []depreciationRow `json:"schedule"`
+	}
+	json.Unmarshal(body, &dep)
+	if dep.Result != "9000.00" || len(dep.Schedule) != 5 {
+		t.Fatalf("depreciation = %s", body)
+	}
+	if last := dep.Schedule[4]; last != (depreciationRow{5, "296.00", "9000.00", "1000.00"}) {
+		t.Fatalf("last row = %+v", last)
+	}
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader lets clients retry writes without repeating their effects.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyConfig bounds the replay cache used by Idempotency.
+type IdempotencyConfig struct {
+	TTL        time.Duration // how long a response is replayed (default 24h)
+	MaxEntries int           // cache capacity; least recently used stored keys are dropped (default 10000)
+}
+
+// Idempotency caches the first response to each POST or DELETE carrying an
+// Idempotency-Key and replays it for retries with an identical request.
+// Reusing a key for a different request yields 422. Concurrent duplicates wait
+// for the first request to finish. Server errors and 429 responses are not
+// cached, so a retry runs the request again. Wrapped around RateLimit, replays
+// are answered before the limiter and use none of the client's tokens or
+// quota. Keys still in flight are never evicted; a new key that finds the
+// cache full of them is refused with 503.
+func Idempotency(next http.Handler, cfg IdempotencyConfig) http.Handler {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 10000
+	}
+	c := &idemCache{
+		cfg:     cfg,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodDelete) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c.serve(next, w, r, key)
+	})
+}
+
+type idemEntry struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	done        chan struct{} // closed once the response is stored or abandoned
+
+	// Set before done is closed.
+	stored bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+type idemCache struct {
+	cfg     IdempotencyConfig
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front = most recently used
+	now     func() time.Time
+}
+
+func (c *idemCache) serve(next http.Handler, w http.ResponseWriter, r *http.Request, key string) {
+	// Scope keys per tenant so tenants cannot observe each other's responses.
+	key = r.Header.Get(TenantHeader) + "\x00" + key
+
+	fp, err := fingerprint(r)
+	if err != nil {
+		writeProblem(w, r, ProblemInvalidJSON, err.Error(), nil)
+		return
+	}
+
+	for {
+		e, owner := c.acquire(key, fp)
+		if e == nil {
+			w.Header().Set("Retry-After", "1")
+			writeProblem(w, r, ProblemIdempotencyBusy,
+				"too many requests with an Idempotency-Key are in progress", nil)
+			return
+		}
+		if e.fingerprint != fp {
+			writeProblem(w, r, ProblemIdempotencyKeyReused,
+				"Idempotency-Key was already used with a different request", nil)
+			return
+		}
+		if owner {
+			c.execute(next, w, r, e)
+			return
+		}
+
+		select {
+		case <-e.done:
+		case <-r.Context().Done():
+			return
+		}
+		if e.stored {
+			replay(w, e)
+			return
+		}
+		// The first attempt was not cacheable; try to become the owner.
+	}
+}
+
+// acquire returns the live entry for key, creating an in-flight one owned by
+// the caller if none exists. It returns nil if there is no room for a new
+// entry because every other one is still in flight.
+func (c *idemCache) acquire(key string, fp [sha256.Size]byte) (*idemEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*idemEntry)
+		if e.stored && now.After(e.expires) {
+			c.removeLocked(el)
+		} else {
+			c.lru.MoveToFront(el)
+			return e, false
+		}
+	}
+
+	// Evicting an in-flight entry would let a duplicate of its request run,
+	// so only stored ones are dropped.
+	for el := c.lru.Back(); el != nil && c.lru.Len() >= c.cfg.MaxEntries; {
+		prev := el.Prev()
+		if el.Value.(*idemEntry).stored {
+			c.removeLocked(el)
+		}
+		el = prev
+	}
+	if c.lru.Len() >= c.cfg.MaxEntries {
+		return nil, false
+	}
+
+	e := &idemEntry{key: key, fingerprint: fp, done: make(chan struct{})}
+	c.entries[key] = c.lru.PushFront(e)
+	return e, true
+}
+
+func (c *idemCache) removeLocked(el *list.Element) {
+	e := el.Value.(*idemEntry)
+	c.lru.Remove(el)
+	if cur, ok := c.entries[e.key]; ok && cur == el {
+		delete(c.entries, e.key)
+	}
+}
+
+func (c *idemCache) execute(next http.Handler, w http.ResponseWriter, r *http.Request, e *idemEntry) {
+	rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+	completed := false
+	defer func() {
+		c.mu.Lock()
+		if completed && rec.status < http.StatusInternalServerError && rec.status != http.StatusTooManyRequests {
+			e.stored = true
+			e.status = rec.status
+			e.header = w.Header().Clone()
+			e.body = rec.body.Bytes()
+			e.expires = c.now().Add(c.cfg.TTL)
+		} else if el, ok := c.entries[e.key]; ok && el.Value == e {
+			c.removeLocked(el)
+		}
+		c.mu.Unlock()
+		close(e.done)
+	}()
+	next.ServeHTTP(rec, r)
+	completed = true
+}
+
+func replay(w http.ResponseWriter, e *idemEntry) {
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(e.status)
+	_, _ = w.Write(e.body)
+}
+
+// fingerprint hashes everything that makes two requests "the same" and restores
+// the body for the downstream handler. Accept-Language is included because
+// problem details are localized: a cached body is only right for its language.
+func fingerprint(r *http.Request) ([sha256.Size]byte, error) {
+	var body []byte
+	if r.Body != nil {
+		b, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		body = b
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(b), r.Body))
+	}
+
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type"), r.Header.Get("Accept"), r.Header.Get("Accept-Language")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
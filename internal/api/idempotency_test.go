@@ -0,0 +1,317 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	service "erikkruuse/calculator/internal/services"
+)
+
+func newIdempotentServer(t *testing.T, cfg IdempotencyConfig) (*httptest.Server, service.CalculatorService) {
+	t.Helper()
+	svc := service.NewCalculatorService(service.WithMaxHistory(100))
+	mux := http.NewServeMux()
+	New(svc).RegisterRoutes(mux)
+	return httptest.NewServer(Idempotency(mux, cfg)), svc
+}
+
+func postWithKey(t *testing.T, url, key, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", url, err)
+	}
+	return resp, readAll(t, resp)
+}
+
+func TestIdempotency_RetryIsReplayed(t *testing.T) {
+	ts, svc := newIdempotentServer(t, IdempotencyConfig{})
+	defer ts.Close()
+
+	resp1, body1 := postWithKey(t, ts.URL+"/v1/add", "k1", `{"a":2,"b":3}`)
+	resp2, body2 := postWithKey(t, ts.URL+"/v1/add", "k1", `{"a":2,"b":3}`)
+
+	if resp1.StatusCode != http.StatusOK || resp2.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d/%d", resp1.StatusCode, resp2.StatusCode)
+	}
+	if string(body1) != string(body2) {
+		t.Fatalf("replayed body differs: %s vs %s", body1, body2)
+	}
+	if resp2.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry should be marked as replayed")
+	}
+	if n := len(svc.GetHistory(0)); n != 1 {
+		t.Fatalf("history len=%d; want 1 (no duplicate)", n)
+	}
+}
+
+func TestIdempotency_ErrorsAreReplayedToo(t *testing.T) {
+	ts, svc := newIdempotentServer(t, IdempotencyConfig{})
+	defer ts.Close()
+
+	resp1, _ := postWithKey(t, ts.URL+"/v1/divide", "k", `{"a":1,"b":0}`)
+	resp2, _ := postWithKey(t, ts.URL+"/v1/divide", "k", `{"a":1,"b":0}`)
+	if resp1.StatusCode != http.StatusBadRequest || resp2.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status=%d/%d; want 400/400", resp1.StatusCode, resp2.StatusCode)
+	}
+	if n := len(svc.GetHistory(0)); n != 1 {
+		t.Fatalf("history len=%d; want 1", n)
+	}
+}
+
+func TestIdempotency_ReusedKeyWithDifferentBody(t *testing.T) {
+	ts, _ := newIdempotentServer(t, IdempotencyConfig{})
+	defer ts.Close()
+
+	postWithKey(t, ts.URL+"/v1/add", "k1", `{"a":2,"b":3}`)
+	resp, body := postWithKey(t, ts.URL+"/v1/add", "k1", `{"a":2,"b":4}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("status=%d body=%s; want 422", resp.StatusCode, body)
+	}
+	var p Problem
+	json.Unmarshal(body, &p)
+	if p.Title != "idempotency_key_reused" || p.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("problem = %+v", p)
+	}
+}
+
+func TestIdempotency_LanguageIsPartOfTheRequest(t *testing.T) {
+	ts, _ := newIdempotentServer(t, IdempotencyConfig{})
+	defer ts.Close()
+
+	post := func(lang string) (*http.Response, []byte) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/divide", strings.NewReader(`{"a":1,"b":0}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", lang)
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, readAll(t, resp)
+	}
+	post("de")
+	if resp, body := post("de"); resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("same language was not replayed: %d %s", resp.StatusCode, body)
+	}
+	// A German body must not be replayed to a French retry.
+	if resp, body := post("fr"); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("status=%d body=%s; want 422", resp.StatusCode, body)
+	}
+}
+
+func TestIdempotency_WithoutKeyIsNotCached(t *testing.T) {
+	ts, svc := newIdempotentServer(t, IdempotencyConfig{})
+	defer ts.Close()
+
+	postWithKey(t, ts.URL+"/v1/add", "", `{"a":2,"b":3}`)
+	postWithKey(t, ts.URL+"/v1/add", "", `{"a":2,"b":3}`)
+	if n := len(svc.GetHistory(0)); n != 2 {
+		t.Fatalf("history len=%d; want 2", n)
+	}
+}
+
+func TestIdempotency_TTLAndCapacity(t *testing.T) {
+	ts, svc := newIdempotentServer(t, IdempotencyConfig{TTL: 20 * time.Millisecond, MaxEntries: 2})
+	defer ts.Close()
+
+	postWithKey(t, ts.URL+"/v1/add", "a", `{"a":1,"b":1}`)
+	time.Sleep(40 * time.Millisecond)
+	postWithKey(t, ts.URL+"/v1/add", "a", `{"a":1,"b":1}`) // expired: executes again
+	if n := len(svc.GetHistory(0)); n != 2 {
+		t.Fatalf("history len=%d; want 2 after TTL expiry", n)
+	}
+
+	svc.ClearHistory()
+	postWithKey(t, ts.URL+"/v1/add", "x", `{"a":1,"b":1}`)
+	postWithKey(t, ts.URL+"/v1/add", "y", `{"a":1,"b":1}`)
+	postWithKey(t, ts.URL+"/v1/add", "z", `{"a":1,"b":1}`) // evicts "x"
+	postWithKey(t, ts.URL+"/v1/add", "x", `{"a":1,"b":1}`)
+	if n := len(svc.GetHistory(0)); n != 4 {
+		t.Fatalf("history len=%d; want 4 after LRU eviction", n)
+	}
+}
+
+func TestIdempotency_ConcurrentDuplicatesRunOnce(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		WriteJSON(w, http.StatusCreated, map[string]string{"status": "done"})
+	})
+	ts := httptest.NewServer(Idempotency(slow, IdempotencyConfig{}))
+	defer ts.Close()
+
+	const n = 8
+	var wg sync.WaitGroup
+	codes := make([]int, n)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			resp, _ := postWithKey(t, ts.URL+"/work", "same", `{}`)
+			codes[i] = resp.StatusCode
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if c := calls.Load(); c != 1 {
+		t.Fatalf("handler ran %d times; want 1", c)
+	}
+	for i, code := range codes {
+		if code != http.StatusCreated {
+			t.Fatalf("request %d status=%d; want 201", i, code)
+		}
+	}
+}
+
+func TestIdempotency_ServerErrorsAreRetried(t *testing.T) {
+	var calls atomic.Int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			WriteProblem(w, http.StatusServiceUnavailable, "unavailable", "try again")
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]string{"status": fmt.Sprint(calls.Load())})
+	})
+	ts := httptest.NewServer(Idempotency(flaky, IdempotencyConfig{}))
+	defer ts.Close()
+
+	resp1, _ := postWithKey(t, ts.URL+"/work", "k", `{}`)
+	resp2, _ := postWithKey(t, ts.URL+"/work", "k", `{}`)
+	resp3, _ := postWithKey(t, ts.URL+"/work", "k", `{}`)
+	if resp1.StatusCode != http.StatusServiceUnavailable || resp2.StatusCode != http.StatusOK || resp3.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d/%d/%d; want 503/200/200", resp1.StatusCode, resp2.StatusCode, resp3.StatusCode)
+	}
+	if c := calls.Load(); c != 2 {
+		t.Fatalf("handler ran %d times; want 2", c)
+	}
+}
+
+func TestIdempotency_ReplaysBypassRateLimit(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	h := Idempotency(RateLimit(okHandler(), RateLimitConfig{Rate: 1, Burst: 1, DailyQuota: 1, now: clock.now}), IdempotencyConfig{})
+	post := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/add", strings.NewReader(`{}`))
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := post("a"); rr.Code != http.StatusOK {
+		t.Fatalf("first status=%d; want 200", rr.Code)
+	}
+	// The bucket and the quota are empty, yet retries of "a" are replayed.
+	for range 3 {
+		if rr := post("a"); rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatalf("retry status=%d replayed=%q; want a 200 replay", rr.Code, rr.Header().Get("Idempotent-Replayed"))
+		}
+	}
+	// A refused request is not cached: once the quota resets its retry runs.
+	if rr := post("b"); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("new key status=%d; want 429", rr.Code)
+	}
+	clock.advance(24 * time.Hour)
+	if rr := post("b"); rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry after reset status=%d replayed=%q; want a fresh 200", rr.Code, rr.Header().Get("Idempotent-Replayed"))
+	}
+}
+
+func TestIdempotency_InFlightKeysAreNotEvicted(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		WriteJSON(w, http.StatusCreated, map[string]string{"status": "done"})
+	})
+	ts := httptest.NewServer(Idempotency(h, IdempotencyConfig{MaxEntries: 2}))
+	defer ts.Close()
+
+	// "slow" stays in flight while other keys fill the cache past capacity.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	var first, dup int
+	go func() {
+		defer wg.Done()
+		resp, _ := postWithKey(t, ts.URL+"/work", "slow", `{}`)
+		first = resp.StatusCode
+	}()
+	<-started
+	for _, k := range []string{"a", "b", "c"} {
+		if resp, body := postWithKey(t, ts.URL+"/work", k, `{}`); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("key %s: status=%d (%s)", k, resp.StatusCode, body)
+		}
+	}
+	go func() {
+		defer wg.Done()
+		resp, _ := postWithKey(t, ts.URL+"/work", "slow", `{}`)
+		dup = resp.StatusCode
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if c := calls.Load(); c != 4 {
+		t.Fatalf("handler ran %d times; want 4 (duplicate of in-flight key executed)", c)
+	}
+	if first != http.StatusCreated || dup != http.StatusCreated {
+		t.Fatalf("status=%d/%d; want 201/201", first, dup)
+	}
+}
+
+func TestIdempotency_FullOfInFlightKeys(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		WriteJSON(w, http.StatusCreated, map[string]string{"status": "done"})
+	})
+	ts := httptest.NewServer(Idempotency(h, IdempotencyConfig{MaxEntries: 2}))
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	for _, k := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postWithKey(t, ts.URL+"/work", k, `{}`)
+		}()
+		<-started
+	}
+
+	resp, body := postWithKey(t, ts.URL+"/work", "c", `{}`)
+	var p Problem
+	json.Unmarshal(body, &p)
+	if resp.StatusCode != http.StatusServiceUnavailable || p.Title != ProblemIdempotencyBusy.Code || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("status=%d body=%s; want 503 idempotency_busy", resp.StatusCode, body)
+	}
+	close(release)
+	wg.Wait()
+
+	// Once the keys are stored they can be evicted again.
+	if resp, body := postWithKey(t, ts.URL+"/work", "c", `{}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("after release: status=%d (%s)", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// intResponse reports an integer-mode result in every supported base. Values
+// are strings because 64-bit integers do not survive a JSON float.
+type intResponse struct {
+	Mode    string `json:"mode"`
+	Result  string `json:"result"` // in the base selected by the "base" parameter
+	Decimal string `json:"decimal"`
+	Hex     string `json:"hex"`
+	Octal   string `json:"octal"`
+	Binary  string `json:"binary"`
+}
+
+// intOpAliases maps operator spellings accepted in integer mode to op names.
+var intOpAliases = map[string]string{
+	"+": "add", "-": "subtract", "*": "multiply", "x": "multiply", "/": "divide", "%": "mod",
+	"&": "and", "|": "or", "^": "xor", "~": "not", "<<": "shl", ">>": "shr",
+}
+
+var intBases = map[string]int{
+	"": 10, "10": 10, "dec": 10, "16": 16, "hex": 16, "8": 8, "oct": 8, "2": 2, "bin": 2,
+}
+
+// calculateInt serves GET /v1/calculate?mode=int32&op=and&a=0xF0&b=0b1010.
+// Arithmetic wraps on overflow unless overflow=error is given.
+func (a *API) calculateInt(w http.ResponseWriter, r *http.Request, mode string) {
+	q := r.URL.Query()
+	t, ok := calculator.ParseIntType(mode)
+	if !ok {
+		writeError(w, r, newProblem(ProblemInvalidInput, "mode", "mode must be float64, interval or one of int8|int16|int32|int64|uint8|uint16|uint32|uint64"))
+		return
+	}
+	op := strings.ToLower(q.Get("op"))
+	if alias, ok := intOpAliases[op]; ok {
+		op = alias
+	}
+
+	wrap, base, err := intOptions(q.Get("overflow"), q.Get("base"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if op == "" {
+		writeError(w, r, newProblem(ProblemMissingParams, "op", "op, a, and b are required"))
+		return
+	}
+	arity := calculator.IntArity(op)
+	if arity == 0 {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", "use add|subtract|multiply|divide|mod|and|or|xor|not|shl|shr|rotl|rotr"))
+		return
+	}
+
+	operands := []calculator.Int{{Type: t}, {Type: t}}
+	for i, field := range []string{"a", "b"}[:arity] {
+		s := q.Get(field)
+		if s == "" {
+			writeError(w, r, newProblem(ProblemMissingParams, field, "op, a, and b are required"))
+			return
+		}
+		v, err := t.Parse(s, wrap)
+		switch {
+		case errors.Is(err, calculator.ErrIntRange):
+			writeError(w, r, newProblem(ProblemOverflow, field, "value is out of range for the integer type"))
+			return
+		case err != nil:
+			writeError(w, r, newProblem(ProblemInvalidInput, field, "a and b must be integers in binary, octal, decimal or hex"))
+			return
+		}
+		operands[i] = v
+	}
+	if arity == 1 && q.Get("b") != "" {
+		writeError(w, r, newProblem(ProblemInvalidInput, "b", "b is not used by this operation"))
+		return
+	}
+
+	res, err := a.svcFor(r).CalculateInt(op, operands[0], operands[1], wrap)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	Write(w, r, http.StatusOK, newIntResponse(res, base))
+}
+
+// intOptions parses the overflow and base parameters of integer mode.
+// Arithmetic wraps unless overflow is error.
+func intOptions(overflow, base string) (wrap bool, b int, err error) {
+	switch overflow {
+	case "", "wrap":
+		wrap = true
+	case "error":
+	default:
+		return false, 0, newProblem(ProblemInvalidInput, "overflow", "overflow must be wrap or error")
+	}
+	b, ok := intBases[strings.ToLower(base)]
+	if !ok {
+		return false, 0, newProblem(ProblemInvalidInput, "base", "base must be 2, 8, 10 or 16")
+	}
+	return wrap, b, nil
+}
+
+func newIntResponse(x calculator.Int, base int) intResponse {
+	return intResponse{
+		Mode:    x.Type.String(),
+		Result:  x.Format(base),
+		Decimal: x.Format(10),
+		Hex:     x.Format(16),
+		Octal:   x.Format(8),
+		Binary:  x.Format(2),
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+)
+
+func TestCalculate_IntegerMode(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		query  url.Values
+		status int
+		result string
+		code   string
+		field  string
+	}{
+		{"and in hex", url.Values{"mode": {"uint8"}, "op": {"&"}, "a": {"0xF0"}, "b": {"0x3C"}, "base": {"hex"}}, 200, "0x30", "", ""},
+		{"not is unary", url.Values{"mode": {"int8"}, "op": {"not"}, "a": {"0"}}, 200, "-1", "", ""},
+		{"wraps by default", url.Values{"mode": {"int32"}, "op": {"add"}, "a": {"2147483647"}, "b": {"1"}}, 200, "-2147483648", "", ""},
+		{"64-bit exact", url.Values{"mode": {"uint64"}, "op": {"shl"}, "a": {"1"}, "b": {"63"}}, 200, "9223372036854775808", "", ""},
+		{"binary output", url.Values{"mode": {"uint16"}, "op": {"rotr"}, "a": {"1"}, "b": {"1"}, "base": {"2"}}, 200, "0b1000000000000000", "", ""},
+		{"overflow error", url.Values{"mode": {"int32"}, "op": {"add"}, "a": {"2147483647"}, "b": {"1"}, "overflow": {"error"}}, 400, "", "overflow", ""},
+		{"input out of range", url.Values{"mode": {"int8"}, "op": {"add"}, "a": {"300"}, "b": {"1"}, "overflow": {"error"}}, 400, "", "overflow", "a"},
+		{"bad literal", url.Values{"mode": {"int8"}, "op": {"add"}, "a": {"0xZZ"}, "b": {"1"}}, 400, "", "invalid_input", "a"},
+		{"missing b", url.Values{"mode": {"int8"}, "op": {"xor"}, "a": {"1"}}, 400, "", "missing_params", "b"},
+		{"unknown mode", url.Values{"mode": {"int128"}, "op": {"add"}, "a": {"1"}, "b": {"1"}}, 400, "", "invalid_input", "mode"},
+		{"bad shift", url.Values{"mode": {"uint8"}, "op": {"shl"}, "a": {"1"}, "b": {"8"}}, 400, "", "domain_error", "b"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := get(t, srv.URL+"/v1/calculate?"+tc.query.Encode())
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || (tc.field != "" && p.Extensions["field"] != tc.field) {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got intResponse
+			json.Unmarshal(body, &got)
+			if got.Result != tc.result || got.Mode != tc.query.Get("mode") {
+				t.Fatalf("response = %s; want result %s", body, tc.result)
+			}
+		})
+	}
+}
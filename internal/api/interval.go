@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"erikkruuse/calculator/calculator"
+	service "erikkruuse/calculator/internal/services"
+)
+
+// intervalRequest is the body of POST /v1/interval/{op}. Each operand is an
+// interval [lo, hi] or a plain number, which stands for [x, x]. division is
+// error (the default), failing when the divisor contains zero, or extended.
+type intervalRequest struct {
+	A        json.RawMessage `json:"a"`
+	B        json.RawMessage `json:"b"`
+	Division string          `json:"division"`
+}
+
+// intervalResponse gives an enclosure of the exact result, with unbounded
+// sides as null. When extended division splits the quotient, Result is the
+// hull of Parts.
+type intervalResponse struct {
+	Mode     string                `json:"mode"`
+	Result   calculator.Interval   `json:"result"`
+	Parts    []calculator.Interval `json:"parts,omitempty"`
+	Warnings []string              `json:"warnings,omitempty"`
+}
+
+const intervalOpsHint = "use add|subtract|multiply|divide"
+
+var intervalOpAliases = map[string]string{
+	"add": "add", "+": "add", "subtract": "subtract", "-": "subtract",
+	"multiply": "multiply", "*": "multiply", "x": "multiply", "divide": "divide", "/": "divide",
+}
+
+// parseBound parses a bound of an interval. A decimal that float64 cannot
+// hold exactly is rounded outward, down for lo and up for hi, so the interval
+// still contains the number the client wrote.
+func parseBound(n json.Number, lo bool) (float64, error) {
+	f, err := parseJSONNumber(n)
+	if err != nil || !isFinite(f) {
+		return f, err
+	}
+	exact, ok := new(big.Rat).SetString(n.String())
+	if !ok {
+		return 0, errors.New("invalid number")
+	}
+	switch c := new(big.Rat).SetFloat64(f).Cmp(exact); {
+	case lo && c > 0:
+		f = math.Nextafter(f, math.Inf(-1))
+	case !lo && c < 0:
+		f = math.Nextafter(f, math.Inf(1))
+	}
+	return f, nil
+}
+
+// parseIntervalOperand decodes [lo, hi] or a number.
+func parseIntervalOperand(field string, raw json.RawMessage) (calculator.Interval, error) {
+	invalid := newProblem(ProblemInvalidInput, field, "a and b must be intervals [lo, hi] or numbers")
+	raw = bytes.TrimSpace(raw)
+
+	var bounds []json.Number
+	if raw[0] == '[' {
+		if err := decodeStrictJSON(bytes.NewReader(raw), &bounds); err != nil || len(bounds) != 2 {
+			return calculator.Interval{}, invalid
+		}
+	} else {
+		bounds = []json.Number{json.Number(raw), json.Number(raw)}
+	}
+	var fs [2]float64
+	for k, n := range bounds {
+		f, err := parseBound(n, k == 0)
+		if err != nil {
+			return calculator.Interval{}, invalid
+		}
+		fs[k] = f
+	}
+	return checkInterval(field, calculator.Interval{Lo: fs[0], Hi: fs[1]})
+}
+
+// parseIntervalQuery parses the query form of an operand, "[lo,hi]", "lo,hi"
+// or a number.
+func parseIntervalQuery(field, s string) (calculator.Interval, error) {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "["), "]")
+	lo, hi, isPair := strings.Cut(s, ",")
+	if !isPair {
+		hi = lo
+	}
+	var fs [2]float64
+	for k, part := range []string{lo, hi} {
+		f, err := parseBound(json.Number(strings.TrimSpace(part)), k == 0)
+		if err != nil {
+			return calculator.Interval{}, newProblem(ProblemInvalidInput, field, "a and b must be intervals [lo, hi] or numbers")
+		}
+		fs[k] = f
+	}
+	return checkInterval(field, calculator.Interval{Lo: fs[0], Hi: fs[1]})
+}
+
+func checkInterval(field string, i calculator.Interval) (calculator.Interval, error) {
+	switch {
+	case !isFinite(i.Lo) || !isFinite(i.Hi):
+		return i, newProblem(ProblemInvalidInput, field, "inputs must be finite numbers")
+	case i.Lo > i.Hi:
+		return i, newProblem(ProblemInvalidInput, field, "lo must not be greater than hi")
+	}
+	return i, nil
+}
+
+// extendedDivision parses the division parameter.
+func extendedDivision(s string) (bool, error) {
+	switch s {
+	case "", "error":
+		return false, nil
+	case "extended":
+		return true, nil
+	}
+	return false, newProblem(ProblemInvalidInput, "division", "division must be error or extended")
+}
+
+func (a *API) intervalOp(w http.ResponseWriter, r *http.Request) {
+	op, ok := intervalOpAliases[r.PathValue("op")]
+	if !ok {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", intervalOpsHint))
+		return
+	}
+
+	var req intervalRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	extended, err := extendedDivision(req.Division)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var operands [2]calculator.Interval
+	for i, raw := range []json.RawMessage{req.A, req.B} {
+		field := string(rune('a' + i))
+		if isNull(raw) {
+			writeError(w, r, newProblem(ProblemMissingParams, field, "a and b are required"))
+			return
+		}
+		if operands[i], err = parseIntervalOperand(field, raw); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	a.writeInterval(w, r, op, operands, extended)
+}
+
+// calculateInterval serves GET /v1/calculate?mode=interval&op=divide&a=[1,2]&b=[-1,4].
+func (a *API) calculateInterval(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	extended, err := extendedDivision(q.Get("division"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	for _, field := range []string{"op", "a", "b"} {
+		if q.Get(field) == "" {
+			writeError(w, r, newProblem(ProblemMissingParams, field, "op, a, and b are required"))
+			return
+		}
+	}
+	var operands [2]calculator.Interval
+	for i, field := range []string{"a", "b"} {
+		if operands[i], err = parseIntervalQuery(field, q.Get(field)); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	op, ok := intervalOpAliases[strings.ToLower(q.Get("op"))]
+	if !ok {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", intervalOpsHint))
+		return
+	}
+	a.writeInterval(w, r, op, operands, extended)
+}
+
+func (a *API) writeInterval(w http.ResponseWriter, r *http.Request, op string, operands [2]calculator.Interval, extended bool) {
+	res, err := a.svcFor(r).CalculateInterval(op, operands[0], operands[1], extended)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	Write(w, r, http.StatusOK, intervalResponse{
+		Mode:     service.ModeInterval,
+		Result:   res.Value,
+		Parts:    res.Parts,
+		Warnings: res.Warnings,
+	})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+)
+
+func TestInterval(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		op     string
+		body   string
+		status int
+		result string // compact JSON of result, then parts if any
+		code   string
+		field  string
+	}{
+		{"add", "add", `{"a":[1,2],"b":[3,4]}`, 200, `[4,6]`, "", ""},
+		{"number operand", "multiply", `{"a":[-1,2],"b":3}`, 200, `[-3,6]`, "", ""},
+		{"rounds outward", "+", `{"a":0.1,"b":0.2}`, 200, `[0.29999999999999993,0.30000000000000004]`, "", ""},
+		{"exact operands stay exact", "+", `{"a":0.5,"b":[0.25,1]}`, 200, `[0.75,1.5]`, "", ""},
+		{"divide", "divide", `{"a":[1,2],"b":[2,4]}`, 200, `[0.25,1]`, "", ""},
+		{"half-open quotient", "divide", `{"a":[1,2],"b":[0,4],"division":"extended"}`, 200, `[0.25,null]`, "", ""},
+		{"split quotient", "divide", `{"a":[1,2],"b":[-1,4],"division":"extended"}`, 200, `[null,null][[null,-1],[0.25,null]]`, "", ""},
+		{"overflow", "multiply", `{"a":[1,1e308],"b":10}`, 200, `[10,null]`, "", ""},
+		{"contains zero", "divide", `{"a":[1,2],"b":[-1,4]}`, 400, "", "calculation_error", "b"},
+		{"zero divisor", "divide", `{"a":[1,2],"b":0,"division":"extended"}`, 400, "", "calculation_error", "b"},
+		{"reversed", "add", `{"a":[2,1],"b":1}`, 400, "", "invalid_input", "a"},
+		{"three bounds", "add", `{"a":[1,2,3],"b":1}`, 400, "", "invalid_input", "a"},
+		{"not finite", "add", `{"a":1,"b":[1,1e999]}`, 400, "", "invalid_input", "b"},
+		{"missing", "add", `{"a":1}`, 400, "", "missing_params", "b"},
+		{"bad division", "divide", `{"a":1,"b":1,"division":"hull"}`, 400, "", "invalid_input", "division"},
+		{"bad op", "sqrt", `{"a":1,"b":1}`, 400, "", "invalid_op", "op"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/interval/"+url.PathEscape(tc.op), tc.body, "application/json")
+			checkIntervalResponse(t, resp.StatusCode, body, tc.status, tc.result, tc.code, tc.field)
+		})
+	}
+}
+
+func TestCalculate_IntervalMode(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		query  url.Values
+		status int
+		result string
+		code   string
+		field  string
+	}{
+		{"brackets", url.Values{"op": {"subtract"}, "a": {"[1,2]"}, "b": {"[0.5,1]"}}, 200, `[0,1.5]`, "", ""},
+		{"bare pair", url.Values{"op": {"*"}, "a": {"-1,2"}, "b": {"-3"}}, 200, `[-6,3]`, "", ""},
+		{"extended", url.Values{"op": {"divide"}, "a": {"-2,-1"}, "b": {"0,4"}, "division": {"extended"}}, 200, `[null,-0.25]`, "", ""},
+		{"contains zero", url.Values{"op": {"divide"}, "a": {"1"}, "b": {"-1,1"}}, 400, "", "calculation_error", "b"},
+		{"bad operand", url.Values{"op": {"add"}, "a": {"1,x"}, "b": {"1"}}, 400, "", "invalid_input", "a"},
+		{"missing op", url.Values{"a": {"1"}, "b": {"1"}}, 400, "", "missing_params", "op"},
+		{"bad op", url.Values{"op": {"mod"}, "a": {"1"}, "b": {"1"}}, 400, "", "invalid_op", "op"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.query.Set("mode", "interval")
+			resp, body := get(t, srv.URL+"/v1/calculate?"+tc.query.Encode())
+			checkIntervalResponse(t, resp.StatusCode, body, tc.status, tc.result, tc.code, tc.field)
+		})
+	}
+}
+
+func TestInterval_ContainsExactDecimalResult(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		op, a, b string
+		exact    func(a, b *big.Rat) *big.Rat
+	}{
+		{"multiply", "0.001", "1", func(a, b *big.Rat) *big.Rat { return new(big.Rat).Mul(a, b) }},
+		{"multiply", "0.1", "0.3", func(a, b *big.Rat) *big.Rat { return new(big.Rat).Mul(a, b) }},
+		{"add", "0.1", "0.7", func(a, b *big.Rat) *big.Rat { return new(big.Rat).Add(a, b) }},
+		{"subtract", "1.1", "0.3", func(a, b *big.Rat) *big.Rat { return new(big.Rat).Sub(a, b) }},
+		{"divide", "0.3", "0.7", func(a, b *big.Rat) *big.Rat { return new(big.Rat).Quo(a, b) }},
+		{"multiply", "1e-320", "3", func(a, b *big.Rat) *big.Rat { return new(big.Rat).Mul(a, b) }},
+	}
+	for _, tc := range cases {
+		body := fmt.Sprintf(`{"a":[%s,%s],"b":[%s,%s]}`, tc.a, tc.a, tc.b, tc.b)
+		resp, raw := postRaw(t, srv.URL+"/v1/interval/"+tc.op, body, "application/json")
+		var got intervalResponse
+		json.Unmarshal(raw, &got)
+		if resp.StatusCode != 200 {
+			t.Fatalf("%s: status = %d (%s)", body, resp.StatusCode, raw)
+		}
+		a, _ := new(big.Rat).SetString(tc.a)
+		b, _ := new(big.Rat).SetString(tc.b)
+		want := tc.exact(a, b)
+		if new(big.Rat).SetFloat64(got.Result.Lo).Cmp(want) > 0 || new(big.Rat).SetFloat64(got.Result.Hi).Cmp(want) < 0 {
+			t.Fatalf("%s %s: %v does not contain %s", tc.op, body, got.Result, want.FloatString(30))
+		}
+	}
+}
+
+func checkIntervalResponse(t *testing.T, status int, body []byte, wantStatus int, result, code, field string) {
+	t.Helper()
+	if status != wantStatus {
+		t.Fatalf("status = %d; want %d (%s)", status, wantStatus, body)
+	}
+	if status != 200 {
+		var p Problem
+		json.Unmarshal(body, &p)
+		if p.Title != code || p.Extensions["field"] != orNil(field) {
+			t.Fatalf("problem = %s; want %s on %q", body, code, field)
+		}
+		return
+	}
+	var got struct {
+		Mode   string          `json:"mode"`
+		Result json.RawMessage `json:"result"`
+		Parts  json.RawMessage `json:"parts"`
+	}
+	json.Unmarshal(body, &got)
+	if got.Mode != "interval" || string(got.Result)+string(got.Parts) != result {
+		t.Fatalf("response = %s; want result %s", body, result)
+	}
+}
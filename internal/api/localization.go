@@ -0,0 +1,66 @@
+package api
+
+import (
+	"math"
+	"net/http"
+
+	"erikkruuse/calculator/internal/i18n"
+	service "erikkruuse/calculator/internal/services"
+)
+
+// localeFor returns the locale negotiated from the request's Accept-Language.
+func localeFor(r *http.Request) *i18n.Locale {
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
+
+// setContentLanguage announces the negotiated language on localized responses.
+func setContentLanguage(w http.ResponseWriter, r *http.Request, loc *i18n.Locale) {
+	if r.Header.Get("Accept-Language") == "" {
+		return
+	}
+	w.Header().Set("Content-Language", loc.Tag.String())
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+// numberLocale returns the locale named by the "locale" query parameter, or
+// nil when the parameter is absent.
+func numberLocale(r *http.Request) (*i18n.Locale, error) {
+	name := r.URL.Query().Get("locale")
+	if name == "" {
+		return nil, nil
+	}
+	loc, ok := i18n.Lookup(name)
+	if !ok {
+		return nil, newProblem(ProblemInvalidInput, "locale", "unsupported locale")
+	}
+	return loc, nil
+}
+
+// resultLocale returns the locale results are formatted in when the client
+// asks for it with format=locale: the "locale" parameter if given, otherwise
+// Accept-Language. It returns nil when no formatting was requested.
+func resultLocale(r *http.Request) (*i18n.Locale, error) {
+	if r.URL.Query().Get("format") != "locale" {
+		return nil, nil
+	}
+	loc, err := numberLocale(r)
+	if err != nil || loc != nil {
+		return loc, err
+	}
+	return localeFor(r), nil
+}
+
+// calcResult builds the response for res, adding the formatted result when loc
+// is set.
+func calcResult(res service.Result, loc *i18n.Locale) calcResponse {
+	resp := calcResponse{Result: res.Value, Uncertainty: res.Uncertainty, Warnings: res.Warnings}
+	if math.IsInf(res.Value, 0) {
+		// Overflowed: there is no number to give, the warning says so.
+		resp.Result = 0
+		return resp
+	}
+	if loc != nil {
+		resp.Formatted = loc.FormatFloat(res.Value)
+	}
+	return resp
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLocalization_TranslatesProblemDetail(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/divide", strings.NewReader(`{"a":1,"b":0}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	var p Problem
+	json.Unmarshal(readAll(t, resp), &p)
+	if p.Detail != "Division durch null ist nicht erlaubt" {
+		t.Fatalf("detail = %q", p.Detail)
+	}
+	if p.Title != "calculation_error" {
+		t.Fatalf("title must stay the stable code, got %q", p.Title)
+	}
+	if got := resp.Header.Get("Content-Language"); got != "de" {
+		t.Fatalf("Content-Language = %q; want de", got)
+	}
+
+	// Unsupported languages fall back to English.
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/v1/divide", strings.NewReader(`{"a":1,"b":0}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "ja")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	p = Problem{}
+	json.Unmarshal(readAll(t, resp), &p)
+	if p.Detail != "division by zero is not allowed" {
+		t.Fatalf("fallback detail = %q", p.Detail)
+	}
+}
+
+func TestLocalization_CalculateWithLocale(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name      string
+		query     string
+		status    int
+		result    float64
+		formatted string
+	}{
+		{"german operands", "op=add&a=1.234,5&b=0,25&locale=de", 200, 1234.75, ""},
+		{"formatted result", "op=multiply&a=1.000&b=1.000,5&locale=de&format=locale", 200, 1000500, "1.000.500"},
+		{"french grouping", "op=add&a=1%20000,5&b=1&locale=fr-FR&format=locale", 200, 1001.5, "1\u202f001,5"},
+		{"ambiguous grouping", "op=add&a=1.5&b=1&locale=de", 400, 0, ""},
+		{"unknown locale", "op=add&a=1&b=1&locale=xx", 400, 0, ""},
+		{"plain without locale", "op=add&a=1.5&b=1", 200, 2.5, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := get(t, srv.URL+"/v1/calculate?"+tc.query)
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				return
+			}
+			var got calcResponse
+			json.Unmarshal(body, &got)
+			if got.Result != tc.result || got.Formatted != tc.formatted {
+				t.Fatalf("got %+v; want result=%v formatted=%q", got, tc.result, tc.formatted)
+			}
+		})
+	}
+}
+
+func TestLocalization_FormatFromAcceptLanguage(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/add?format=locale", strings.NewReader(`{"a":1000,"b":0.5}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "es")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	var got calcResponse
+	json.Unmarshal(readAll(t, resp), &got)
+	if got.Result != 1000.5 || got.Formatted != "1.000,5" {
+		t.Fatalf("got %+v", got)
+	}
+}
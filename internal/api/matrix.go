@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+
+	"erikkruuse/calculator/linalg"
+)
+
+// Size limits for /v1/matrix. Most operations are cubic in the matrix
+// dimension, so these bound the CPU spent on a single request.
+const (
+	maxMatrixDim = 64
+	maxVectorLen = 4096
+)
+
+// matrixRequest carries the operands of a matrix or vector operation:
+// matrices a and b, vectors u and v, and the norm order p (1, 2 or "inf";
+// default 2). solve takes a and the right-hand side v.
+type matrixRequest struct {
+	A linalg.Matrix `json:"a"`
+	B linalg.Matrix `json:"b"`
+	U linalg.Vector `json:"u"`
+	V linalg.Vector `json:"v"`
+	P *normOrder    `json:"p"`
+}
+
+// normOrder accepts a number or the string "inf".
+type normOrder float64
+
+func (p *normOrder) UnmarshalJSON(b []byte) error {
+	if string(b) == `"inf"` {
+		*p = normOrder(math.Inf(1))
+		return nil
+	}
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	*p = normOrder(f)
+	return nil
+}
+
+type matrixResponse struct {
+	Result any `json:"result"`
+}
+
+// matrixOps lists the operands each operation requires.
+var matrixOps = map[string][]string{
+	"add":         {"a", "b"},
+	"multiply":    {"a", "b"},
+	"transpose":   {"a"},
+	"determinant": {"a"},
+	"inverse":     {"a"},
+	"rank":        {"a"},
+	"lu":          {"a"},
+	"qr":          {"a"},
+	"solve":       {"a", "v"},
+	"dot":         {"u", "v"},
+	"cross":       {"u", "v"},
+	"norm":        {"u"},
+}
+
+// check rejects missing operands and operands above the size limits before
+// any work is done.
+func (req *matrixRequest) check(op string) error {
+	present := map[string]bool{"a": req.A != nil, "b": req.B != nil, "u": req.U != nil, "v": req.V != nil}
+	for _, field := range matrixOps[op] {
+		if !present[field] {
+			return newProblem(ProblemMissingParams, field, "missing operand for this operation")
+		}
+		delete(present, field)
+	}
+	for field, ok := range present {
+		if ok {
+			return newProblem(ProblemInvalidInput, field, "operand is not used by this operation")
+		}
+	}
+	if req.P != nil && op != "norm" {
+		return newProblem(ProblemInvalidInput, "p", "operand is not used by this operation")
+	}
+
+	for _, m := range []struct {
+		field string
+		m     linalg.Matrix
+	}{{"a", req.A}, {"b", req.B}} {
+		if len(m.m) > maxMatrixDim {
+			return tooLarge(m.field, maxMatrixDim)
+		}
+		for _, row := range m.m {
+			if len(row) > maxMatrixDim {
+				return tooLarge(m.field, maxMatrixDim)
+			}
+		}
+	}
+	if len(req.U) > maxVectorLen {
+		return tooLarge("u", maxVectorLen)
+	}
+	if len(req.V) > maxVectorLen {
+		return tooLarge("v", maxVectorLen)
+	}
+	return nil
+}
+
+func tooLarge(field string, limit int) error {
+	return &problemError{
+		typ:    ProblemInvalidInput,
+		field:  field,
+		detail: "operand exceeds the size limit",
+		ext:    map[string]any{"limit": limit},
+	}
+}
+
+func (a *API) matrixOp(w http.ResponseWriter, r *http.Request) {
+	op := r.PathValue("op")
+	if _, ok := matrixOps[op]; !ok {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", "use add|multiply|transpose|determinant|inverse|rank|lu|qr|solve|dot|cross|norm"))
+		return
+	}
+
+	var req matrixRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if err := req.check(op); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var (
+		res any
+		err error
+	)
+	switch op {
+	case "add":
+		res, err = linalg.Add(req.A, req.B)
+	case "multiply":
+		res, err = linalg.Multiply(req.A, req.B)
+	case "transpose":
+		res, err = linalg.Transpose(req.A)
+	case "determinant":
+		res, err = linalg.Determinant(req.A)
+	case "inverse":
+		res, err = linalg.Inverse(req.A)
+	case "rank":
+		res, err = linalg.Rank(req.A)
+	case "lu":
+		res, err = linalg.LU(req.A)
+	case "qr":
+		res, err = linalg.QR(req.A)
+	case "solve":
+		res, err = linalg.Solve(req.A, req.V)
+	case "dot":
+		res, err = linalg.Dot(req.U, req.V)
+	case "cross":
+		res, err = linalg.Cross(req.U, req.V)
+	case "norm":
+		p := 2.0
+		if req.P != nil {
+			p = float64(*req.P)
+		}
+		res, err = linalg.Norm(req.U, p)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	Write(w, r, http.StatusOK, matrixResponse{Result: res})
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatrixOp(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		op     string
+		body   string
+		status int
+		result string // JSON of the result member
+		code   string // problem title for errors
+		field  string
+	}{
+		{"multiply", "multiply", `{"a":[[1,2],[3,4]],"b":[[5],[6]]}`, 200, `[[17],[39]]`, "", ""},
+		{"determinant", "determinant", `{"a":[[1,2],[3,4]]}`, 200, `-2`, "", ""},
+		{"rank", "rank", `{"a":[[1,2],[2,4]]}`, 200, `1`, "", ""},
+		{"solve", "solve", `{"a":[[2,0],[0,4]],"v":[2,8]}`, 200, `[1,2]`, "", ""},
+		{"lu", "lu", `{"a":[[1]]}`, 200, `{"l":[[1]],"u":[[1]],"p":[[1]]}`, "", ""},
+		{"cross", "cross", `{"u":[1,0,0],"v":[0,1,0]}`, 200, `[0,0,1]`, "", ""},
+		{"max norm", "norm", `{"u":[3,-4],"p":"inf"}`, 200, `4`, "", ""},
+		{"dimension mismatch", "multiply", `{"a":[[1,2]],"b":[[1,2]]}`, 400, "", "dimension_mismatch", "b"},
+		{"ragged", "transpose", `{"a":[[1,2],[3]]}`, 400, "", "dimension_mismatch", "a"},
+		{"singular", "inverse", `{"a":[[1,2],[2,4]]}`, 400, "", "calculation_error", "a"},
+		{"missing operand", "add", `{"a":[[1]]}`, 400, "", "missing_params", "b"},
+		{"unused operand", "rank", `{"a":[[1]],"u":[1]}`, 400, "", "invalid_input", "u"},
+		{"unknown op", "eigen", `{"a":[[1]]}`, 400, "", "invalid_op", "op"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/matrix/"+tc.op, tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != tc.field {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got struct {
+				Result json.RawMessage `json:"result"`
+			}
+			json.Unmarshal(body, &got)
+			if string(got.Result) != tc.result {
+				t.Fatalf("result = %s; want %s", got.Result, tc.result)
+			}
+		})
+	}
+}
+
+func TestMatrixOp_SizeLimit(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	row := "[" + strings.TrimSuffix(strings.Repeat("1,", maxMatrixDim+1), ",") + "]"
+	body := fmt.Sprintf(`{"a":[%s]}`, row)
+	resp, raw := postRaw(t, srv.URL+"/v1/matrix/transpose", body, "application/json")
+	if resp.StatusCode != 400 {
+		t.Fatalf("status = %d; want 400 (%s)", resp.StatusCode, raw)
+	}
+	var p Problem
+	json.Unmarshal(raw, &p)
+	if p.Title != "invalid_input" || p.Extensions["field"] != "a" || p.Extensions["limit"] != float64(maxMatrixDim) {
+		t.Fatalf("problem = %s", raw)
+	}
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Registry maps media types to codecs. Codecs registered first win ties during
+// Accept negotiation.
+type Registry struct {
+	codecs []Codec
+	byType map[string]Codec
+}
+
+// NewRegistry builds a registry from codecs in order of preference.
+func NewRegistry(codecs ...Codec) *Registry {
+	reg := &Registry{byType: map[string]Codec{}}
+	for _, c := range codecs {
+		reg.Register(c)
+	}
+	return reg
+}
+
+// Register adds c, replacing any codec previously registered for its media types.
+func (reg *Registry) Register(c Codec) {
+	reg.codecs = append(reg.codecs, c)
+	for _, mt := range c.MediaTypes() {
+		reg.byType[mt] = c
+	}
+}
+
+// Codecs is the default registry: JSON, CBOR, MessagePack, XML and plain text.
+var Codecs = NewRegistry(jsonCodec{}, cborCodec{}, msgpackCodec{}, xmlCodec{}, textCodec{})
+
+// Errors returned by negotiation; handlers map them to 415 and 406.
+var (
+	ErrMissingContentType     = errors.New("Content-Type is required")
+	ErrUnsupportedContentType = errors.New("unsupported Content-Type")
+	ErrNotAcceptable          = errors.New("none of the Accept media types can be produced")
+)
+
+// ForContentType returns the codec that decodes the given Content-Type header.
+func (reg *Registry) ForContentType(contentType string) (Codec, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return nil, ErrMissingContentType
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedContentType
+	}
+	c, ok := reg.byType[mt]
+	if !ok {
+		return nil, ErrUnsupportedContentType
+	}
+	return c, nil
+}
+
+// Negotiate picks the codec and media type that best satisfy an Accept header.
+// An empty header accepts the first registered codec.
+func (reg *Registry) Negotiate(accept string) (Codec, string, error) {
+	if strings.TrimSpace(accept) == "" {
+		c := reg.codecs[0]
+		return c, c.MediaTypes()[0], nil
+	}
+
+	ranges := parseAccept(accept)
+	var (
+		best   Codec
+		bestMT string
+		bestQ  float64
+	)
+	for _, c := range reg.codecs {
+		for _, mt := range c.MediaTypes() {
+			// The most specific matching range decides the quality of mt.
+			for _, ar := range ranges {
+				if !ar.matches(mt) {
+					continue
+				}
+				if ar.q > bestQ {
+					best, bestQ, bestMT = c, ar.q, ar.preferred(c)
+				}
+				break
+			}
+		}
+	}
+	if best == nil {
+		return nil, "", ErrNotAcceptable
+	}
+	return best, bestMT, nil
+}
+
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+func (ar acceptRange) matches(mediaType string) bool {
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	return (ar.typ == "*" || ar.typ == typ) && (ar.sub == "*" || ar.sub == sub)
+}
+
+// preferred returns the first of c's media types that the range admits, so
+// wildcards answer with the codec's canonical type where possible.
+func (ar acceptRange) preferred(c Codec) string {
+	for _, mt := range c.MediaTypes() {
+		if ar.matches(mt) {
+			return mt
+		}
+	}
+	return c.MediaTypes()[0]
+}
+
+func parseAccept(accept string) []acceptRange {
+	var out []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, sub, ok := strings.Cut(mt, "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil && f >= 0 && f <= 1 {
+				q = f
+			}
+		}
+		out = append(out, acceptRange{typ: typ, sub: sub, q: q})
+	}
+	// Most specific ranges first so e.g. "application/xml;q=0" beats "*/*".
+	sort.SliceStable(out, func(i, j int) bool {
+		return strings.Count(out[i].typ+out[i].sub, "*") < strings.Count(out[j].typ+out[j].sub, "*")
+	})
+	return out
+}
+
+type responseCodecKey struct{}
+
+type responseCodec struct {
+	codec     Codec
+	mediaType string
+}
+
+// Negotiated rejects requests whose Accept or Content-Type cannot be served
+// (406/415) before the handler runs, and remembers the response codec for Write.
+func Negotiated(reg *Registry, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, mt, err := reg.Negotiate(r.Header.Get("Accept"))
+		if err != nil {
+			writeProblem(w, r, ProblemNotAcceptable, err.Error(), nil)
+			return
+		}
+		if hasBody(r) && r.Header.Get("Content-Type") != "" {
+			if _, err := reg.ForContentType(r.Header.Get("Content-Type")); err != nil {
+				writeProblem(w, r, ProblemUnsupportedMediaType, err.Error(), nil)
+				return
+			}
+		}
+		ctx := context.WithValue(r.Context(), responseCodecKey{}, responseCodec{codec: c, mediaType: mt})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func hasBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// Decode strictly decodes the request body into v with the codec matching its
+// Content-Type, applying the same body cap as DecodeJSON.
+func Decode(r *http.Request, w http.ResponseWriter, v any) error {
+	c, err := Codecs.ForContentType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	return c.Decode(r.Body, v)
+}
+
+// decodeBody decodes the request into v and, on failure, writes a 415 for
+// unsupported content types or a 400 invalid_json problem otherwise.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := Decode(r, w, v)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrUnsupportedContentType):
+		writeProblem(w, r, ProblemUnsupportedMediaType, err.Error(), nil)
+	case errors.As(err, new(*json.UnmarshalTypeError)):
+		writeError(w, r, err)
+	default:
+		writeProblem(w, r, ProblemInvalidJSON, err.Error(), nil)
+	}
+	return false
+}
+
+// Write encodes v in the media type negotiated for the request.
+func Write(w http.ResponseWriter, r *http.Request, status int, v any) {
+	rc, ok := r.Context().Value(responseCodecKey{}).(responseCodec)
+	if !ok {
+		c, mt, err := Codecs.Negotiate(r.Header.Get("Accept"))
+		if err != nil {
+			writeProblem(w, r, ProblemNotAcceptable, err.Error(), nil)
+			return
+		}
+		rc = responseCodec{codec: c, mediaType: mt}
+	}
+
+	// Encode first so encoding failures can still become a proper error response.
+	var buf bytes.Buffer
+	if err := rc.codec.Encode(&buf, v); err != nil {
+		writeProblem(w, r, ProblemEncoding, err.Error(), nil)
+		return
+	}
+	ct := rc.mediaType
+	if strings.HasPrefix(ct, "text/") || strings.HasSuffix(ct, "json") || strings.HasSuffix(ct, "xml") {
+		ct += "; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func doNegotiated(t *testing.T, method, url, contentType, accept string, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	return resp, readAll(t, resp)
+}
+
+func TestNegotiate_AcceptHeader(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/cbor", "application/cbor"},
+		{"application/x-msgpack", "application/x-msgpack"},
+		{"text/*", "text/xml"},
+		{"text/plain, application/json;q=0.5", "text/plain"},
+		{"application/json;q=0.2, application/xml;q=0.9", "application/xml"},
+		{"application/json;q=0, */*", "application/cbor"},
+		{"image/png, */*;q=0.1", "application/json"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.accept, func(t *testing.T) {
+			_, mt, err := Codecs.Negotiate(tc.accept)
+			if err != nil {
+				t.Fatalf("Negotiate(%q) error: %v", tc.accept, err)
+			}
+			if mt != tc.want {
+				t.Fatalf("Negotiate(%q) = %q; want %q", tc.accept, mt, tc.want)
+			}
+		})
+	}
+
+	for _, accept := range []string{"image/png", "application/json;q=0"} {
+		if _, _, err := Codecs.Negotiate(accept); err != ErrNotAcceptable {
+			t.Fatalf("Negotiate(%q) err = %v; want ErrNotAcceptable", accept, err)
+		}
+	}
+}
+
+func TestNegotiated_AddInEveryFormat(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	cborBody, _ := cbor.Marshal(map[string]any{"a": 1.5, "b": 2.25})
+	mpBody, _ := msgpack.Marshal(map[string]any{"a": 1.5, "b": 2.25})
+
+	cases := []struct {
+		name, contentType string
+		body              []byte
+		decode            func([]byte) (float64, error)
+	}{
+		{"cbor", "application/cbor", cborBody, func(b []byte) (float64, error) {
+			var out struct {
+				Result float64 `cbor:"result"`
+			}
+			err := cbor.Unmarshal(b, &out)
+			return out.Result, err
+		}},
+		{"msgpack", "application/msgpack", mpBody, func(b []byte) (float64, error) {
+			var out struct {
+				Result float64 `msgpack:"result"`
+			}
+			err := msgpack.Unmarshal(b, &out)
+			return out.Result, err
+		}},
+		{"xml", "application/xml", []byte(`<calc><a>1.5</a><b>2.25</b></calc>`), func(b []byte) (float64, error) {
+			if !strings.Contains(string(b), "<result>3.75</result>") {
+				return 0, nil
+			}
+			return 3.75, nil
+		}},
+		{"text/plain", "text/plain", []byte("a=1.5\nb=2.25\n"), func(b []byte) (float64, error) {
+			if string(b) != "result=3.75\n" {
+				return 0, nil
+			}
+			return 3.75, nil
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := doNegotiated(t, http.MethodPost, ts.URL+"/v1/add", tc.contentType, tc.contentType, tc.body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status=%d body=%s", resp.StatusCode, body)
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tc.contentType) {
+				t.Fatalf("Content-Type = %q; want %s", ct, tc.contentType)
+			}
+			got, err := tc.decode(body)
+			if err != nil || got != 3.75 {
+				t.Fatalf("result = %v (%v); body=%q", got, err, body)
+			}
+		})
+	}
+}
+
+func TestNegotiated_ErrorsAreProblems(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	cases := []struct {
+		name, contentType, accept string
+		body                      []byte
+		status                    int
+		title                     string
+	}{
+		{"not acceptable", "application/json", "image/png", []byte(`{"a":1,"b":2}`), http.StatusNotAcceptable, "not_acceptable"},
+		{"unsupported content type", "application/yaml", "", []byte("a: 1\nb: 2\n"), http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{"xml unknown field", "application/xml", "", []byte(`<r><a>1</a><b>2</b><c>3</c></r>`), http.StatusBadRequest, "invalid_json"},
+		{"cbor non-finite", "application/cbor", "", func() []byte { b, _ := cbor.Marshal(map[string]any{"a": math.Inf(1), "b": 1}); return b }(), http.StatusBadRequest, "invalid_input"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := doNegotiated(t, http.MethodPost, ts.URL+"/v1/add", tc.contentType, tc.accept, tc.body)
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status=%d body=%s; want %d", resp.StatusCode, body, tc.status)
+			}
+			var p Problem
+			if err := json.Unmarshal(body, &p); err != nil {
+				t.Fatalf("problem is not JSON: %v; body=%s", err, body)
+			}
+			if p.Title != tc.title {
+				t.Fatalf("title=%q; want %q", p.Title, tc.title)
+			}
+		})
+	}
+}
+
+func TestNegotiated_GetHistoryAsXML(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	_, _ = postJSON(t, ts.URL+"/v1/multiply", map[string]any{"a": 3, "b": 7})
+	resp, body := doNegotiated(t, http.MethodGet, ts.URL+"/v1/history", "", "application/xml", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d body=%s", resp.StatusCode, body)
+	}
+	s := string(body)
+	if !strings.Contains(s, "<response><item><id>0</id>") || !strings.Contains(s, "<op>multiply</op>") || !strings.Contains(s, "<result>21</result>") {
+		t.Fatalf("xml history = %s", s)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"net/http"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// operationInfo describes a registered operation in GET /v1/operations.
+// Uncertainty reports whether operands may be measured values.
+type operationInfo struct {
+	Name        string                   `json:"name"`
+	Aliases     []string                 `json:"aliases,omitempty"`
+	Arity       int                      `json:"arity"`
+	Operands    []calculator.OperandType `json:"operands"`
+	Doc         string                   `json:"doc,omitempty"`
+	Route       string                   `json:"route"`
+	Commutative bool                     `json:"commutative,omitempty"`
+	Uncertainty bool                     `json:"uncertainty"`
+}
+
+type operationsResponse struct {
+	Operations []operationInfo `json:"operations"`
+}
+
+func (a *API) listOperations(w http.ResponseWriter, r *http.Request) {
+	ops := a.svc.Operations().Operations()
+	out := operationsResponse{Operations: make([]operationInfo, len(ops))}
+	for i, op := range ops {
+		out.Operations[i] = operationInfo{
+			Name:        op.Name,
+			Aliases:     op.Aliases,
+			Arity:       op.Arity(),
+			Operands:    op.Operands,
+			Doc:         op.Doc,
+			Route:       "POST /v1/" + op.Name,
+			Commutative: op.Commutative,
+			Uncertainty: op.Partials != nil,
+		}
+	}
+	Write(w, r, http.StatusOK, out)
+}
+
+// openAPI serves an OpenAPI 3.1 description of the registered operations,
+// GET /v1/calculate and GET /v1/operations. It is generated on each request,
+// so it always matches the registry.
+func (a *API) openAPI(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusOK, openAPIDocument(a.svc.Operations()))
+}
+
+type object = map[string]any
+
+func ref(schema string) object { return object{"$ref": "#/components/schemas/" + schema} }
+
+func openAPIDocument(reg *calculator.Registry) object {
+	ops := reg.Operations()
+	var opNames []string
+	paths := object{}
+	for _, op := range ops {
+		opNames = append(opNames, op.Name)
+		opNames = append(opNames, op.Aliases...)
+
+		props, required := object{}, []string{}
+		for i, t := range op.Operands {
+			field := string(rune('a' + i))
+			// OperandType values double as JSON Schema types.
+			schema := object{"type": string(t)}
+			if op.Partials != nil {
+				schema = object{"oneOf": []any{schema, ref("Measurement")}}
+			}
+			props[field] = schema
+			required = append(required, field)
+		}
+		paths["/v1/"+op.Name] = object{"post": object{
+			"operationId": op.Name,
+			"summary":     op.Doc,
+			"tags":        []string{"operations"},
+			"requestBody": object{
+				"required": true,
+				"content": object{"application/json": object{"schema": object{
+					"type":                 "object",
+					"properties":           props,
+					"required":             required,
+					"additionalProperties": false,
+				}}},
+			},
+			"responses": calcResponses(),
+		}}
+	}
+
+	query := func(name, doc string, required bool, schema object) object {
+		return object{"name": name, "in": "query", "description": doc, "required": required, "schema": schema}
+	}
+	paths["/v1/calculate"] = object{"get": object{
+		"operationId": "calculate",
+		"summary":     "Perform an operation given as query parameters.",
+		"parameters": []any{
+			query("op", "Operation name or alias.", true, object{"type": "string", "enum": opNames}),
+			query("a", "First operand.", true, object{"type": "string"}),
+			query("b", "Second operand; omitted for unary operations.", false, object{"type": "string"}),
+			query("ua", "Standard uncertainty of a, propagated to the result.", false, object{"type": "string"}),
+			query("ub", "Standard uncertainty of b.", false, object{"type": "string"}),
+			query("mode", "float64 (default), interval or an integer type such as int32.", false, object{"type": "string"}),
+		},
+		"responses": calcResponses(),
+	}}
+	paths["/v1/operations"] = object{"get": object{
+		"operationId": "listOperations",
+		"summary":     "List the registered operations.",
+		"responses": object{"200": object{
+			"description": "Registered operations.",
+			"content":     object{"application/json": object{"schema": ref("Operations")}},
+		}},
+	}}
+
+	return object{
+		"openapi": "3.1.0",
+		"info":    object{"title": "Calculator API", "version": "1"},
+		"paths":   paths,
+		"components": object{"schemas": object{
+			"Measurement": object{
+				"type": "object",
+				"properties": object{
+					"value":       object{"type": "number"},
+					"uncertainty": object{"type": "number", "minimum": 0},
+				},
+				"required": []string{"value"},
+			},
+			"Result": object{
+				"type": "object",
+				"properties": object{
+					"result":      object{"type": "number"},
+					"formatted":   object{"type": "string"},
+					"uncertainty": object{"type": "number"},
+					"warnings":    object{"type": "array", "items": object{"type": "string"}},
+				},
+			},
+			"Problem": object{
+				"type": "object",
+				"properties": object{
+					"type":   object{"type": "string"},
+					"title":  object{"type": "string"},
+					"status": object{"type": "integer"},
+					"detail": object{"type": "string"},
+					"field":  object{"type": "string"},
+				},
+			},
+			"Operations": object{
+				"type": "object",
+				"properties": object{"operations": object{"type": "array", "items": object{
+					"type": "object",
+					"properties": object{
+						"name":        object{"type": "string"},
+						"aliases":     object{"type": "array", "items": object{"type": "string"}},
+						"arity":       object{"type": "integer"},
+						"operands":    object{"type": "array", "items": object{"type": "string", "enum": []string{"number", "integer"}}},
+						"doc":         object{"type": "string"},
+						"route":       object{"type": "string"},
+						"commutative": object{"type": "boolean"},
+						"uncertainty": object{"type": "boolean"},
+					},
+				}}},
+			},
+		}},
+	}
+}
+
+func calcResponses() object {
+	problem := func(doc string) object {
+		return object{
+			"description": doc,
+			"content":     object{ProblemContentType: object{"schema": ref("Problem")}},
+		}
+	}
+	return object{
+		"200": object{
+			"description": "The result and any precision warnings.",
+			"content":     object{"application/json": object{"schema": ref("Result")}},
+		},
+		"400": problem("Invalid operation or operands, or a calculation error such as division by zero."),
+	}
+}
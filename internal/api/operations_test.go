@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+	service "erikkruuse/calculator/internal/services"
+)
+
+func TestListOperations(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, body := get(t, srv.URL+"/v1/operations")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+	}
+	var out operationsResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Operations) != 4 {
+		t.Fatalf("operations = %+v", out.Operations)
+	}
+	mul := out.Operations[2]
+	if mul.Name != "multiply" || len(mul.Aliases) != 2 || mul.Arity != 2 || mul.Route != "POST /v1/multiply" || !mul.Commutative || !mul.Uncertainty {
+		t.Fatalf("multiply = %+v", mul)
+	}
+}
+
+func TestRegisteredOperations(t *testing.T) {
+	reg := calculator.NewRegistry()
+	err := reg.Register(calculator.Operation{
+		Name: "sqrt", Aliases: []string{"√"}, Operands: []calculator.OperandType{calculator.TypeNumber},
+		Doc:      "Square root of a.",
+		Fn:       func(a, _ float64) (float64, error) { return math.Sqrt(a), nil },
+		Partials: func(a, _ float64) (float64, float64) { return 0.5 / math.Sqrt(a), 0 },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewCalculatorService(service.WithOperations(reg))
+	mux := http.NewServeMux()
+	New(svc).RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Registered after the routes were mounted.
+	err = reg.Register(calculator.Operation{
+		Name: "gcd", Operands: []calculator.OperandType{calculator.TypeInteger, calculator.TypeInteger},
+		Fn: func(a, b float64) (float64, error) {
+			for b != 0 {
+				a, b = b, math.Mod(a, b)
+			}
+			return math.Abs(a), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A fixed route would hide it.
+	err = reg.Register(calculator.Operation{
+		Name: "evaluate", Operands: []calculator.OperandType{calculator.TypeNumber},
+		Fn: func(a, _ float64) (float64, error) { return a, nil },
+	})
+	if err == nil {
+		t.Fatal("operation named after a fixed route registered")
+	}
+
+	cases := []struct {
+		name, method, path, body string
+		status                   int
+		result                   float64
+		code, field              string
+	}{
+		{"unary", "POST", "/v1/sqrt", `{"a":9}`, 200, 3, "", ""},
+		{"measured", "POST", "/v1/sqrt", `{"a":{"value":4,"uncertainty":0.4}}`, 200, 2, "", ""},
+		{"unary with b", "POST", "/v1/sqrt", `{"a":9,"b":1}`, 400, 0, "invalid_input", "b"},
+		{"unary missing a", "POST", "/v1/sqrt", `{}`, 400, 0, "invalid_json", "a"},
+		{"late registration", "POST", "/v1/gcd", `{"a":12,"b":18}`, 200, 6, "", ""},
+		{"fractional integer", "POST", "/v1/gcd", `{"a":12,"b":1.5}`, 400, 0, "invalid_input", "b"},
+		{"no uncertainty support", "POST", "/v1/gcd", `{"a":{"value":12,"uncertainty":1},"b":18}`, 400, 0, "invalid_input", "op"},
+		{"builtin", "POST", "/v1/add", `{"a":1,"b":2}`, 200, 3, "", ""},
+		{"fixed route wins", "POST", "/v1/stats", `{"values":[1,2,3]}`, 200, 0, "", ""},
+		{"unknown", "POST", "/v1/pow", `{"a":1,"b":2}`, 400, 0, "invalid_op", "op"},
+		{"query alias", "GET", "/v1/calculate?op=" + url.QueryEscape("√") + "&a=16", "", 200, 4, "", ""},
+		{"query measured", "GET", "/v1/calculate?op=sqrt&a=4&ua=0.4", "", 200, 2, "", ""},
+		{"query late registration", "GET", "/v1/calculate?op=gcd&a=4&b=6", "", 200, 2, "", ""},
+		{"query unary with b", "GET", "/v1/calculate?op=sqrt&a=16&b=1", "", 400, 0, "invalid_input", "b"},
+		{"query unary missing a", "GET", "/v1/calculate?op=sqrt", "", 400, 0, "missing_params", "a"},
+		{"query unary with ub", "GET", "/v1/calculate?op=sqrt&a=16&ub=1", "", 400, 0, "invalid_input", "ub"},
+		{"query no uncertainty support", "GET", "/v1/calculate?op=gcd&a=4&ua=1&b=6", "", 400, 0, "invalid_input", "op"},
+		{"query binary missing b", "GET", "/v1/calculate?op=gcd&a=4", "", 400, 0, "missing_params", "b"},
+		{"query unknown", "GET", "/v1/calculate?op=pow&a=1&b=2", "", 400, 0, "invalid_op", "op"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				resp *http.Response
+				body []byte
+			)
+			if tc.method == "GET" {
+				resp, body = get(t, srv.URL+tc.path)
+			} else {
+				resp, body = postRaw(t, srv.URL+tc.path, tc.body, "application/json")
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != orNil(tc.field) {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var out calcResponse
+			json.Unmarshal(body, &out)
+			if tc.result != 0 && out.Result != tc.result {
+				t.Fatalf("result = %s; want %v", body, tc.result)
+			}
+		})
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 8 || h[0].Op != "gcd" || h[1].Op != "sqrt" || h[1].B != 0 || h[1].Uncertainty == nil || *h[1].Uncertainty != 0.1 {
+		t.Fatalf("history = %+v", h)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, body := get(t, srv.URL+"/v1/openapi.json")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name   string `json:"name"`
+				Schema struct {
+					Enum []string `json:"enum"`
+				} `json:"schema"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Required []string `json:"required"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Fatalf("openapi = %q", doc.OpenAPI)
+	}
+	for _, name := range []string{"add", "subtract", "multiply", "divide"} {
+		post, ok := doc.Paths["/v1/"+name]["post"]
+		if !ok || post.OperationID != name || len(post.RequestBody.Content["application/json"].Schema.Required) != 2 {
+			t.Errorf("%s path = %+v", name, post)
+		}
+	}
+	calc := doc.Paths["/v1/calculate"]["get"]
+	if len(calc.Parameters) == 0 || calc.Parameters[0].Name != "op" || len(calc.Parameters[0].Schema.Enum) != 9 {
+		t.Errorf("calculate = %+v", calc)
+	}
+	if _, ok := doc.Paths["/v1/operations"]["get"]; !ok {
+		t.Error("missing /v1/operations")
+	}
+	for _, s := range []string{"Measurement", "Result", "Problem", "Operations"} {
+		if doc.Components.Schemas[s] == nil {
+			t.Errorf("missing schema %s", s)
+		}
+	}
+}
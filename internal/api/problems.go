@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"erikkruuse/calculator/calculator"
+	service "erikkruuse/calculator/internal/services"
+)
+
+// ProblemTypeBase prefixes every problem type code to form its type URI.
+const ProblemTypeBase = "urn:calculator:problem:"
+
+// ProblemType is an entry in the catalogue of error classes. Code is stable and
+// doubles as the problem title.
+type ProblemType struct {
+	Code   string
+	Status int
+}
+
+// URI is the stable type URI reported in Problem.Type.
+func (pt ProblemType) URI() string { return ProblemTypeBase + pt.Code }
+
+// The problem catalogue.
+var (
+	ProblemInvalidJSON          = ProblemType{"invalid_json", http.StatusBadRequest}
+	ProblemInvalidInput         = ProblemType{"invalid_input", http.StatusBadRequest}
+	ProblemMissingParams        = ProblemType{"missing_params", http.StatusBadRequest}
+	ProblemInvalidOp            = ProblemType{"invalid_op", http.StatusBadRequest}
+	ProblemCalculation          = ProblemType{"calculation_error", http.StatusBadRequest}
+	ProblemDomain               = ProblemType{"domain_error", http.StatusBadRequest}
+	ProblemOverflow             = ProblemType{"overflow", http.StatusBadRequest}
+	ProblemDimension            = ProblemType{"dimension_mismatch", http.StatusBadRequest}
+	ProblemNotAcceptable        = ProblemType{"not_acceptable", http.StatusNotAcceptable}
+	ProblemUnsupportedMediaType = ProblemType{"unsupported_media_type", http.StatusUnsupportedMediaType}
+	ProblemIdempotencyKeyReused = ProblemType{"idempotency_key_reused", http.StatusUnprocessableEntity}
+	ProblemIdempotencyBusy      = ProblemType{"idempotency_busy", http.StatusServiceUnavailable}
+	ProblemRateLimited          = ProblemType{"rate_limited", http.StatusTooManyRequests}
+	ProblemQuotaExceeded        = ProblemType{"quota_exceeded", http.StatusTooManyRequests}
+	ProblemNoConvergence        = ProblemType{"no_convergence", http.StatusUnprocessableEntity}
+	ProblemMultipleRoots        = ProblemType{"multiple_roots", http.StatusUnprocessableEntity}
+	ProblemBudgetExceeded       = ProblemType{"budget_exceeded", http.StatusUnprocessableEntity}
+	ProblemRatesUnavailable     = ProblemType{"rates_unavailable", http.StatusServiceUnavailable}
+	ProblemEncoding             = ProblemType{"encoding_error", http.StatusInternalServerError}
+	ProblemInternal             = ProblemType{"internal_error", http.StatusInternalServerError}
+)
+
+var problemCatalogue = map[string]ProblemType{}
+
+// RegisterProblemType adds pt to the catalogue so WriteProblem and typed errors
+// with its code resolve to it.
+func RegisterProblemType(pt ProblemType) ProblemType {
+	problemCatalogue[pt.Code] = pt
+	return pt
+}
+
+func init() {
+	for _, pt := range []ProblemType{
+		ProblemInvalidJSON, ProblemInvalidInput, ProblemMissingParams, ProblemInvalidOp,
+		ProblemCalculation, ProblemDomain, ProblemOverflow, ProblemDimension, ProblemNotAcceptable,
+		ProblemUnsupportedMediaType, ProblemIdempotencyKeyReused, ProblemIdempotencyBusy, ProblemRateLimited,
+		ProblemQuotaExceeded, ProblemNoConvergence, ProblemMultipleRoots, ProblemBudgetExceeded,
+		ProblemRatesUnavailable, ProblemEncoding, ProblemInternal,
+	} {
+		RegisterProblemType(pt)
+	}
+}
+
+// LookupProblemType returns the catalogued problem type for code.
+func LookupProblemType(code string) (ProblemType, bool) {
+	pt, ok := problemCatalogue[code]
+	return pt, ok
+}
+
+// Problem is an RFC 9457 problem details object.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions are serialized as top-level members, e.g. "field".
+	Extensions map[string]any `json:"-"`
+}
+
+type problemMembers Problem
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(problemMembers(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range p.Extensions {
+		if _, standard := m[k]; !standard {
+			m[k] = v
+		}
+	}
+	return json.Marshal(m)
+}
+
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*problemMembers)(p)); err != nil {
+		return err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(m, k)
+	}
+	if len(m) > 0 {
+		p.Extensions = m
+	}
+	return nil
+}
+
+// ProblemContentType is the media type of problem responses.
+const ProblemContentType = "application/problem+json"
+
+// WriteProblem writes a standardized error response. The title is looked up in
+// the problem catalogue to fill in the type URI.
+func WriteProblem(w http.ResponseWriter, status int, title string, detail string) {
+	p := Problem{Title: title, Status: status, Detail: detail}
+	if pt, ok := LookupProblemType(title); ok {
+		p.Type = pt.URI()
+	}
+	sendProblem(w, p)
+}
+
+func sendProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// RequestIDHeader, when present, is echoed as the "request_id" problem member.
+const RequestIDHeader = "X-Request-ID"
+
+// writeProblem writes a catalogued problem for r with optional extension members.
+// The detail is translated into the language negotiated from Accept-Language.
+func writeProblem(w http.ResponseWriter, r *http.Request, pt ProblemType, detail string, ext map[string]any) {
+	loc := localeFor(r)
+	setContentLanguage(w, r, loc)
+	p := Problem{
+		Type:       pt.URI(),
+		Title:      pt.Code,
+		Status:     pt.Status,
+		Detail:     loc.Translate(detail),
+		Instance:   r.URL.Path,
+		Extensions: ext,
+	}
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		if p.Extensions == nil {
+			p.Extensions = map[string]any{}
+		}
+		p.Extensions["request_id"] = id
+	}
+	sendProblem(w, p)
+}
+
+// problemError is an API-level failure that already knows its problem type.
+type problemError struct {
+	typ    ProblemType
+	field  string
+	detail string
+	ext    map[string]any // extra members besides "field"
+}
+
+func (e *problemError) Error() string { return e.detail }
+
+func newProblem(pt ProblemType, field, detail string) error {
+	return &problemError{typ: pt, field: field, detail: detail}
+}
+
+func fieldExt(field string) map[string]any {
+	if field == "" {
+		return nil
+	}
+	return map[string]any{"field": field}
+}
+
+// writeError maps typed errors from the api, service and calculator packages
+// onto catalogued problems. Unknown errors are reported as internal errors.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		pe *problemError
+		ce *calculator.Error
+		ie *service.InputError
+		te *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &pe):
+		ext := fieldExt(pe.field)
+		for k, v := range pe.ext {
+			if ext == nil {
+				ext = map[string]any{}
+			}
+			ext[k] = v
+		}
+		writeProblem(w, r, pe.typ, pe.detail, ext)
+	case errors.As(err, &ce):
+		pt, ok := LookupProblemType(string(ce.Kind))
+		if !ok {
+			pt = ProblemCalculation
+		}
+		ext := fieldExt(ce.Field)
+		if ce.Op != "" {
+			if ext == nil {
+				ext = map[string]any{}
+			}
+			ext["op"] = ce.Op
+		}
+		writeProblem(w, r, pt, ce.Msg, ext)
+	case errors.As(err, &ie):
+		writeProblem(w, r, ProblemInvalidInput, ie.Msg, fieldExt(ie.Field))
+	case errors.As(err, &te):
+		writeProblem(w, r, ProblemInvalidJSON, err.Error(), fieldExt(te.Field))
+	default:
+		writeProblem(w, r, ProblemInternal, err.Error(), nil)
+	}
+}
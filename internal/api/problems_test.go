@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+	service "erikkruuse/calculator/internal/services"
+)
+
+func TestProblem_JSONRoundTripWithExtensions(t *testing.T) {
+	p := Problem{
+		Type:       ProblemInvalidInput.URI(),
+		Title:      "invalid_input",
+		Status:     400,
+		Instance:   "/v1/add",
+		Extensions: map[string]any{"field": "a", "title": "ignored"},
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(b, &m)
+	if m["field"] != "a" || m["title"] != "invalid_input" || m["type"] != "urn:calculator:problem:invalid_input" {
+		t.Fatalf("marshaled = %s", b)
+	}
+
+	var back Problem
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if back.Instance != "/v1/add" || back.Extensions["field"] != "a" || len(back.Extensions) != 1 {
+		t.Fatalf("round trip = %+v", back)
+	}
+}
+
+func TestWriteProblem_FillsCatalogueType(t *testing.T) {
+	rr := httptest.NewRecorder()
+	WriteProblem(rr, http.StatusBadRequest, "invalid_op", "nope")
+	var p Problem
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	if p.Type != ProblemInvalidOp.URI() {
+		t.Fatalf("type = %q; want %q", p.Type, ProblemInvalidOp.URI())
+	}
+
+	rr = httptest.NewRecorder()
+	WriteProblem(rr, http.StatusTeapot, "not_catalogued", "")
+	p = Problem{}
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	if p.Type != "" {
+		t.Fatalf("uncatalogued title should have no type, got %q", p.Type)
+	}
+}
+
+func TestWriteError_MapsTypedErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		want  ProblemType
+		field string
+	}{
+		{"api problem", newProblem(ProblemMissingParams, "b", "b is required"), ProblemMissingParams, "b"},
+		{"division by zero", calculator.ErrDivisionByZero, ProblemCalculation, "b"},
+		{"domain", &calculator.Error{Kind: calculator.KindDomain, Field: "a", Msg: "sqrt of negative"}, ProblemDomain, "a"},
+		{"overflow", &calculator.Error{Kind: calculator.KindOverflow, Msg: "too big"}, ProblemOverflow, ""},
+		{"service input", &service.InputError{Field: "tolerance.rel", Msg: "bad"}, ProblemInvalidInput, "tolerance.rel"},
+		{"unknown", errors.New("boom"), ProblemInternal, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/divide", nil)
+			req.Header.Set(RequestIDHeader, "req-42")
+			rr := httptest.NewRecorder()
+			writeError(rr, req, tc.err)
+
+			if rr.Code != tc.want.Status {
+				t.Fatalf("status=%d; want %d", rr.Code, tc.want.Status)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Fatalf("content-type = %q", ct)
+			}
+			var p Problem
+			json.Unmarshal(rr.Body.Bytes(), &p)
+			if p.Type != tc.want.URI() || p.Title != tc.want.Code || p.Instance != "/v1/divide" {
+				t.Fatalf("problem = %+v", p)
+			}
+			if got, _ := p.Extensions["field"].(string); got != tc.field {
+				t.Fatalf("field = %q; want %q", got, tc.field)
+			}
+			if p.Extensions["request_id"] != "req-42" {
+				t.Fatalf("request_id missing: %+v", p.Extensions)
+			}
+		})
+	}
+}
+
+func TestProblems_FromHandlers(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, body := postJSON(t, ts.URL+"/v1/divide", map[string]any{"a": 1, "b": 0})
+	var p Problem
+	json.Unmarshal(body, &p)
+	if resp.StatusCode != 400 || p.Type != ProblemCalculation.URI() || p.Extensions["field"] != "b" || p.Extensions["op"] != "divide" {
+		t.Fatalf("divide by zero problem = %+v", p)
+	}
+
+	resp, body = get(t, ts.URL+"/v1/calculate?op=add&a=1&b=oops")
+	p = Problem{}
+	json.Unmarshal(body, &p)
+	if resp.StatusCode != 400 || p.Title != "invalid_input" || p.Extensions["field"] != "b" || p.Instance != "/v1/calculate" {
+		t.Fatalf("invalid input problem = %+v", p)
+	}
+
+	resp, body = postJSON(t, ts.URL+"/v1/history/replay", map[string]any{"tolerance": map[string]any{"abs": "x"}})
+	p = Problem{}
+	json.Unmarshal(body, &p)
+	if resp.StatusCode != 400 || p.Title != "invalid_json" || p.Extensions["field"] != "tolerance.abs" {
+		t.Fatalf("type mismatch problem = %+v", p)
+	}
+}
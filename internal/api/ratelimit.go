@@ -0,0 +1,303 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyHeader identifies a client for rate limiting; requests without a
+// configured key are keyed by client IP.
+const APIKeyHeader = "X-API-Key"
+
+// RateLimitConfig configures RateLimit. Zero Rate disables the token bucket and
+// zero DailyQuota disables the quota.
+type RateLimitConfig struct {
+	Rate           float64        // sustained requests per second per client
+	Burst          int            // bucket size (default: ceil(Rate))
+	DailyQuota     int            // calculations per client per UTC day
+	TrustedProxies []netip.Prefix // peers allowed to set X-Forwarded-For
+	APIKeys        []string       // X-API-Key values that identify a client; others are ignored
+	MaxClients     int            // clients tracked at most (default 10000)
+
+	now func() time.Time
+}
+
+// ParseTrustedProxies parses a comma-separated list of IPs and CIDR prefixes.
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+// ParseAPIKeys parses a comma-separated list of API keys.
+func ParseAPIKeys(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// RateLimit applies a per-client token bucket to every request and a daily
+// quota to calculation requests, answering 429 with Retry-After when either
+// is exhausted.
+func RateLimit(next http.Handler, cfg RateLimitConfig) http.Handler {
+	if cfg.Burst <= 0 {
+		cfg.Burst = int(math.Ceil(cfg.Rate))
+	}
+	if cfg.MaxClients <= 0 {
+		cfg.MaxClients = 10000
+	}
+	if cfg.now == nil {
+		cfg.now = time.Now
+	}
+	l := &limiter{cfg: cfg, keys: map[string]bool{}, clients: map[string]*clientState{}}
+	for _, k := range cfg.APIKeys {
+		l.keys[k] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.Rate <= 0 && cfg.DailyQuota <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		d := l.allow(l.clientKey(r), isCalculation(r))
+		h := w.Header()
+		if cfg.Rate > 0 {
+			h.Set("RateLimit-Limit", strconv.Itoa(cfg.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+		}
+		if d.quotaApplied {
+			h.Set("X-Quota-Limit", strconv.Itoa(cfg.DailyQuota))
+			h.Set("X-Quota-Remaining", strconv.Itoa(d.quotaRemaining))
+		}
+		if d.rejected.Code != "" {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.retryAfter)))
+			writeProblem(w, r, d.rejected, localeFor(r).Sprintf(d.detail, d.args...), nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isCalculation reports whether a request performs calculations that count
+// against the daily quota.
+func isCalculation(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/v1/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodPost:
+		return true
+	case http.MethodGet:
+		return r.URL.Path == "/v1/calculate"
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+type clientState struct {
+	tokens   float64
+	last     time.Time
+	quotaDay string
+	used     int
+}
+
+type limiter struct {
+	cfg     RateLimitConfig
+	keys    map[string]bool
+	mu      sync.Mutex
+	clients map[string]*clientState
+}
+
+type decision struct {
+	remaining      int
+	reset          time.Duration
+	quotaApplied   bool
+	quotaRemaining int
+
+	rejected   ProblemType // set when the request is refused
+	detail     string      // untranslated format for args
+	args       []any
+	retryAfter time.Duration
+}
+
+func (l *limiter) allow(key string, calculation bool) decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.cfg.now()
+	c, ok := l.clients[key]
+	if !ok {
+		if len(l.clients) >= l.cfg.MaxClients {
+			l.evictLocked(now)
+		}
+		c = &clientState{tokens: float64(l.cfg.Burst), last: now}
+		l.clients[key] = c
+	}
+
+	var d decision
+	if l.cfg.Rate > 0 {
+		c.tokens = math.Min(float64(l.cfg.Burst), c.tokens+now.Sub(c.last).Seconds()*l.cfg.Rate)
+		c.last = now
+		if c.tokens < 1 {
+			d.rejected = ProblemRateLimited
+			d.detail, d.args = "rate limit of %g requests per second exceeded", []any{l.cfg.Rate}
+			d.retryAfter = l.refill(1 - c.tokens)
+		}
+	}
+
+	if l.cfg.DailyQuota > 0 && calculation {
+		day := now.UTC().Format(time.DateOnly)
+		if c.quotaDay != day {
+			c.quotaDay, c.used = day, 0
+		}
+		d.quotaApplied = true
+		if d.rejected.Code == "" && c.used >= l.cfg.DailyQuota {
+			d.rejected = ProblemQuotaExceeded
+			d.detail, d.args = "daily quota of %d calculations exhausted", []any{l.cfg.DailyQuota}
+			midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			d.retryAfter = midnight.Sub(now)
+		}
+		if d.rejected.Code == "" {
+			c.used++
+		}
+		d.quotaRemaining = l.cfg.DailyQuota - c.used
+	}
+
+	if l.cfg.Rate > 0 {
+		if d.rejected.Code == "" {
+			c.tokens--
+		}
+		d.remaining = int(math.Max(0, math.Floor(c.tokens)))
+		d.reset = l.refill(float64(l.cfg.Burst) - c.tokens)
+	}
+	return d
+}
+
+// refill returns how long the bucket takes to gain n tokens.
+func (l *limiter) refill(n float64) time.Duration {
+	return time.Duration(n / l.cfg.Rate * float64(time.Second))
+}
+
+// evictLocked makes room for a new client. It first forgets clients that are
+// indistinguishable from new ones (full bucket, no quota used today), then
+// other clients with a full bucket, and as a last resort the one seen least
+// recently, so the table never exceeds MaxClients.
+func (l *limiter) evictLocked(now time.Time) {
+	today := now.UTC().Format(time.DateOnly)
+	var full []string
+	for key, c := range l.clients {
+		if !l.full(c, now) {
+			continue
+		}
+		if c.quotaDay != today || c.used == 0 {
+			delete(l.clients, key)
+		} else {
+			full = append(full, key)
+		}
+	}
+	for _, key := range full {
+		if len(l.clients) < l.cfg.MaxClients {
+			return
+		}
+		delete(l.clients, key)
+	}
+	for len(l.clients) >= l.cfg.MaxClients {
+		var oldest string
+		for key, c := range l.clients {
+			if oldest == "" || c.last.Before(l.clients[oldest].last) {
+				oldest = key
+			}
+		}
+		delete(l.clients, oldest)
+	}
+}
+
+// full reports whether c's bucket has refilled completely.
+func (l *limiter) full(c *clientState, now time.Time) bool {
+	return l.cfg.Rate <= 0 || c.tokens+now.Sub(c.last).Seconds()*l.cfg.Rate >= float64(l.cfg.Burst)
+}
+
+// clientKey identifies the caller by a configured API key, or by IP address.
+// Unknown keys are ignored so that inventing keys cannot buy fresh buckets.
+// The IP is taken from X-Forwarded-For only when the direct peer is a trusted
+// proxy, walking the chain from the right past any further trusted hops.
+func (l *limiter) clientKey(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get(APIKeyHeader)); l.keys[key] {
+		return "key:" + key
+	}
+
+	ip := remoteIP(r.RemoteAddr)
+	if ip.IsValid() && l.trusted(ip) {
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			ip = hop.Unmap()
+			if !l.trusted(ip) {
+				break
+			}
+		}
+	}
+	if !ip.IsValid() {
+		return "addr:" + r.RemoteAddr
+	}
+	return "ip:" + ip.String()
+}
+
+func (l *limiter) trusted(ip netip.Addr) bool {
+	for _, p := range l.cfg.TrustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(remoteAddr string) netip.Addr {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ip.Unmap()
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+func doLimited(h http.Handler, method, path, remote string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remote
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRateLimit_TokenBucket(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	h := RateLimit(okHandler(), RateLimitConfig{Rate: 1, Burst: 2, now: clock.now})
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		rr := doLimited(h, http.MethodGet, "/health", "10.0.0.1:1234", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d status=%d; want 200", i, rr.Code)
+		}
+		if got := rr.Header().Get("RateLimit-Remaining"); got != wantRemaining {
+			t.Fatalf("request %d RateLimit-Remaining=%q; want %q", i, got, wantRemaining)
+		}
+		if rr.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("RateLimit-Limit=%q; want 2", rr.Header().Get("RateLimit-Limit"))
+		}
+	}
+
+	rr := doLimited(h, http.MethodGet, "/health", "10.0.0.1:1234", nil)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("status=%d; want 429", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Fatalf("Retry-After=%q; want 1", rr.Header().Get("Retry-After"))
+	}
+	var p Problem
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	if p.Title != "rate_limited" || p.Status != http.StatusTooManyRequests {
+		t.Fatalf("problem = %+v", p)
+	}
+
+	// Another client has its own bucket.
+	if rr := doLimited(h, http.MethodGet, "/health", "10.0.0.2:1234", nil); rr.Code != http.StatusOK {
+		t.Fatalf("other client status=%d; want 200", rr.Code)
+	}
+
+	clock.advance(time.Second)
+	if rr := doLimited(h, http.MethodGet, "/health", "10.0.0.1:1234", nil); rr.Code != http.StatusOK {
+		t.Fatalf("after refill status=%d; want 200", rr.Code)
+	}
+}
+
+func TestRateLimit_APIKeyOverridesIP(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	h := RateLimit(okHandler(), RateLimitConfig{Rate: 1, Burst: 1, APIKeys: []string{"secret"}, now: clock.now})
+
+	key := map[string]string{APIKeyHeader: "secret"}
+	if rr := doLimited(h, http.MethodGet, "/health", "10.0.0.1:1", key); rr.Code != http.StatusOK {
+		t.Fatalf("status=%d", rr.Code)
+	}
+	// Same key from a different IP shares the bucket.
+	if rr := doLimited(h, http.MethodGet, "/health", "10.0.0.9:1", key); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("status=%d; want 429 for shared key", rr.Code)
+	}
+	// The IP itself is still fresh.
+	if rr := doLimited(h, http.MethodGet, "/health", "10.0.0.9:1", nil); rr.Code != http.StatusOK {
+		t.Fatalf("status=%d; want 200 for unkeyed IP", rr.Code)
+	}
+}
+
+func TestRateLimit_UnknownAPIKeyFallsBackToIP(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	h := RateLimit(okHandler(), RateLimitConfig{Rate: 1, Burst: 1, APIKeys: []string{"secret"}, now: clock.now})
+
+	if rr := doLimited(h, http.MethodGet, "/health", "10.0.0.1:1", map[string]string{APIKeyHeader: "made-up-1"}); rr.Code != http.StatusOK {
+		t.Fatalf("status=%d", rr.Code)
+	}
+	// A different invented key does not buy a fresh bucket.
+	if rr := doLimited(h, http.MethodGet, "/health", "10.0.0.1:1", map[string]string{APIKeyHeader: "made-up-2"}); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("status=%d; want 429 for unknown key on a drained IP", rr.Code)
+	}
+}
+
+func TestRateLimit_MaxClientsIsAHardCap(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	cfg := RateLimitConfig{Rate: 1, Burst: 1, MaxClients: 3, now: clock.now}
+	l := &limiter{cfg: cfg, clients: map[string]*clientState{}}
+
+	// Three drained clients, one of which refills.
+	for _, k := range []string{"a", "b", "c"} {
+		l.allow(k, false)
+		clock.advance(100 * time.Millisecond)
+	}
+	l.clients["b"].tokens = 1
+
+	l.allow("d", false)
+	if _, ok := l.clients["b"]; ok || len(l.clients) != 3 {
+		t.Fatalf("clients = %v; want the full bucket b evicted", keys(l.clients))
+	}
+	// With every bucket drained the least recently seen client goes.
+	l.allow("e", false)
+	if _, ok := l.clients["a"]; ok || len(l.clients) != 3 {
+		t.Fatalf("clients = %v; want a evicted", keys(l.clients))
+	}
+	for i := 0; i < 100; i++ {
+		l.allow(fmt.Sprint("ip:", i), false)
+	}
+	if len(l.clients) != 3 {
+		t.Fatalf("tracking %d clients; cap is 3", len(l.clients))
+	}
+}
+
+func keys(m map[string]*clientState) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestRateLimit_DailyQuota(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)}
+	h := RateLimit(okHandler(), RateLimitConfig{DailyQuota: 2, now: clock.now})
+
+	for i := 0; i < 2; i++ {
+		rr := doLimited(h, http.MethodPost, "/v1/add", "10.0.0.1:1", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("calc %d status=%d", i, rr.Code)
+		}
+	}
+	// Non-calculation requests are not counted.
+	if rr := doLimited(h, http.MethodGet, "/v1/history", "10.0.0.1:1", nil); rr.Code != http.StatusOK {
+		t.Fatalf("history status=%d; want 200", rr.Code)
+	}
+
+	rr := doLimited(h, http.MethodGet, "/v1/calculate?op=add&a=1&b=2", "10.0.0.1:1", nil)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("status=%d; want 429", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "3600" || rr.Header().Get("X-Quota-Remaining") != "0" {
+		t.Fatalf("headers = %v", rr.Header())
+	}
+	var p Problem
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	if p.Title != "quota_exceeded" {
+		t.Fatalf("problem = %+v", p)
+	}
+
+	clock.advance(time.Hour)
+	if rr := doLimited(h, http.MethodPost, "/v1/add", "10.0.0.1:1", nil); rr.Code != http.StatusOK {
+		t.Fatalf("next day status=%d; want 200", rr.Code)
+	}
+}
+
+func TestRateLimit_TrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	l := &limiter{cfg: RateLimitConfig{TrustedProxies: proxies}}
+
+	cases := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"no proxy", "203.0.113.5:1", "", "ip:203.0.113.5"},
+		{"untrusted peer ignores xff", "203.0.113.5:1", "198.51.100.7", "ip:203.0.113.5"},
+		{"trusted peer uses xff", "10.1.2.3:1", "198.51.100.7", "ip:198.51.100.7"},
+		{"skips trusted hops", "192.168.1.1:1", "198.51.100.7, 10.9.9.9", "ip:198.51.100.7"},
+		{"spoofed left entries ignored", "10.1.2.3:1", "1.1.1.1, 198.51.100.7", "ip:198.51.100.7"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			if tc.xff != "" {
+				req.Header.Set("X-Forwarded-For", tc.xff)
+			}
+			if got := l.clientKey(req); got != tc.want {
+				t.Fatalf("clientKey = %q; want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := ParseTrustedProxies("not-an-ip"); err == nil {
+		t.Fatalf("expected error for invalid proxy")
+	}
+	if got, _ := ParseTrustedProxies("10.1.2.3/8"); len(got) != 1 || got[0] != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Fatalf("prefix not masked: %v", got)
+	}
+}
+
+func TestRateLimit_DisabledPassesThrough(t *testing.T) {
+	h := RateLimit(okHandler(), RateLimitConfig{})
+	rr := doLimited(h, http.MethodPost, "/v1/add", "10.0.0.1:1", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("status=%d headers=%v", rr.Code, rr.Header())
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"erikkruuse/calculator/calculator"
+	service "erikkruuse/calculator/internal/services"
+	"erikkruuse/calculator/script"
+)
+
+// maxScriptSteps caps the max_steps a request may ask for.
+const maxScriptSteps = 100000
+
+// scriptRequest is the body of POST /v1/scripts/run. With record, every
+// operation the script performs is added to history under one run number.
+type scriptRequest struct {
+	Script   string `json:"script"`
+	Record   bool   `json:"record"`
+	MaxSteps *int   `json:"max_steps"`
+}
+
+func (a *API) runScript(w http.ResponseWriter, r *http.Request) {
+	var req scriptRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Script == "" {
+		writeError(w, r, newProblem(ProblemMissingParams, "script", "script is required"))
+		return
+	}
+	limits := script.Limits{Steps: script.DefaultLimits.Steps, Timeout: computeTimeout}
+	if n := req.MaxSteps; n != nil {
+		if *n < 1 || *n > maxScriptSteps {
+			writeError(w, r, newProblem(ProblemInvalidInput, "max_steps", "max_steps must be between 1 and 100000"))
+			return
+		}
+		limits.Steps = *n
+	}
+
+	res, err := a.svcFor(r).RunScript(r.Context(), req.Script, service.ScriptOptions{Record: req.Record, Limits: limits})
+	if err != nil {
+		writeError(w, r, scriptProblem(err, res.Run))
+		return
+	}
+	Write(w, r, http.StatusOK, res)
+}
+
+// scriptProblem reports a failed script with the line where it stopped and,
+// if it was recorded, its run number, so the operations it performed can be
+// found in history.
+func scriptProblem(err error, run int64) error {
+	var (
+		se *script.SyntaxError
+		le *script.LimitError
+		re *script.Error
+	)
+	ext := map[string]any{}
+	if run != 0 {
+		ext["run"] = run
+	}
+	switch {
+	case errors.As(err, &se):
+		ext["line"], ext["column"] = se.Line, se.Column
+		return &problemError{typ: ProblemInvalidInput, field: "script", detail: se.Msg, ext: ext}
+	case errors.As(err, &le):
+		ext["limit"], ext["line"], ext["steps"] = le.Limit, le.Line, le.Steps
+		return &problemError{typ: ProblemBudgetExceeded, detail: le.Error(), ext: ext}
+	case !errors.As(err, &re):
+		return err
+	}
+
+	ext["line"] = re.Line
+	var (
+		ce *calculator.Error
+		ie *service.InputError
+	)
+	switch {
+	case errors.As(re.Err, &ce):
+		pt, ok := LookupProblemType(string(ce.Kind))
+		if !ok {
+			pt = ProblemCalculation
+		}
+		if ce.Op != "" {
+			ext["op"] = ce.Op
+		}
+		return &problemError{typ: pt, field: "script", detail: ce.Msg, ext: ext}
+	case errors.As(re.Err, &ie):
+		return &problemError{typ: ProblemInvalidInput, field: "script", detail: ie.Msg, ext: ext}
+	case re.Err != nil:
+		return err
+	}
+	if re.Name != "" {
+		ext["name"] = re.Name
+	}
+	return &problemError{typ: ProblemInvalidInput, field: "script", detail: re.Msg, ext: ext}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	service "erikkruuse/calculator/internal/services"
+)
+
+func TestRunScript(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		want   float64
+		code   string // problem title for errors
+		field  string
+		ext    map[string]any
+	}{
+		{"loop", `{"script":"s = 0\nfor i = 1 to 4 { s = s + i * i }\ns"}`, 200, 30, "", "", nil},
+		{"branches", `{"script":"x = 7; if x > 5 and x < 10 { y = x * 2 } else { y = 0 }"}`, 200, 14, "", "", nil},
+		{"aliases", `{"script":"x(3, 4) + -(2)"}`, 200, 10, "", "", nil},
+		{"no script", `{}`, 400, 0, "missing_params", "script", nil},
+		{"bad max_steps", `{"script":"1","max_steps":0}`, 400, 0, "invalid_input", "max_steps", nil},
+		{"syntax", `{"script":"x = 1\ny = (x"}`, 400, 0, "invalid_input", "script",
+			map[string]any{"line": float64(2), "column": float64(7)}},
+		{"undefined", `{"script":"x = 1\ny = z"}`, 400, 0, "invalid_input", "script",
+			map[string]any{"line": float64(2), "name": "z"}},
+		{"unknown function", `{"script":"sqrt(2)"}`, 400, 0, "invalid_input", "script",
+			map[string]any{"line": float64(1), "name": "sqrt"}},
+		{"division by zero", `{"script":"x = 0\n\n1 / x"}`, 400, 0, "calculation_error", "script",
+			map[string]any{"line": float64(3), "op": "divide"}},
+		{"steps", `{"script":"for i = 1 to 100 { i }","max_steps":50}`, 422, 0, "budget_exceeded", "",
+			map[string]any{"limit": "steps", "steps": float64(50), "line": float64(1)}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/scripts/run", tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != orNil(tc.field) {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				for k, v := range tc.ext {
+					if p.Extensions[k] != v {
+						t.Fatalf("problem = %s; want %s = %v", body, k, v)
+					}
+				}
+				return
+			}
+			var got service.ScriptResult
+			json.Unmarshal(body, &got)
+			if got.Value == nil || *got.Value != tc.want || got.Run != 0 || len(got.Trace) == 0 {
+				t.Fatalf("response = %s; want %v", body, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunScript_RecordsGroupedHistory(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	postRaw(t, srv.URL+"/v1/add", `{"a":1,"b":2}`, "application/json")
+	resp, body := postRaw(t, srv.URL+"/v1/scripts/run", `{"script":"a = 2 * 3\nb = a - 1","record":true}`, "application/json")
+	var res service.ScriptResult
+	json.Unmarshal(body, &res)
+	if resp.StatusCode != 200 || res.Run == 0 || *res.Value != 5 || res.Variables["a"] != 6 || res.Steps != 4 {
+		t.Fatalf("response = %s", body)
+	}
+	if len(res.Trace) != 4 || res.Trace[0].Op != "multiply" || res.Trace[1].Var != "a" || res.Trace[2].Operands[1] != 1 {
+		t.Fatalf("trace = %+v", res.Trace)
+	}
+
+	_, body = get(t, fmt.Sprintf("%s/v1/history?run=%d", srv.URL, res.Run))
+	var items []service.HistoryEntry
+	json.Unmarshal(body, &items)
+	if len(items) != 2 || items[0].Op != "subtract" || items[1].Op != "multiply" || items[0].Run != res.Run {
+		t.Fatalf("run history = %s", body)
+	}
+	_, body = get(t, srv.URL+"/v1/history")
+	json.Unmarshal(body, &items)
+	if len(items) != 3 {
+		t.Fatalf("history = %s", body)
+	}
+
+	// A failed run reports its number so its entries can be found.
+	resp, body = postRaw(t, srv.URL+"/v1/scripts/run", `{"script":"x = 1 + 1\nx / 0","record":true}`, "application/json")
+	var p Problem
+	json.Unmarshal(body, &p)
+	run, _ := p.Extensions["run"].(float64)
+	if resp.StatusCode != 400 || run <= float64(res.Run) {
+		t.Fatalf("problem = %s", body)
+	}
+	_, body = get(t, fmt.Sprintf("%s/v1/history?run=%d&limit=1", srv.URL, int64(run)))
+	json.Unmarshal(body, &items)
+	if len(items) != 1 || items[0].Op != "divide" || items[0].Error == "" {
+		t.Fatalf("failed run history = %s", body)
+	}
+
+	if resp, _ := get(t, srv.URL+"/v1/history?run=x"); resp.StatusCode != 400 {
+		t.Fatalf("bad run filter: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"math"
+	"net/http"
+
+	"erikkruuse/calculator/expr"
+	"erikkruuse/calculator/solver"
+)
+
+// solveRequest is the body of POST /v1/solve. equation is "lhs = rhs", or
+// an expression solved for zero; var defaults to its only variable. method
+// is auto (the default), polynomial, bracket, newton or secant.
+type solveRequest struct {
+	Equation      string    `json:"equation"`
+	Var           string    `json:"var"`
+	Method        string    `json:"method"`
+	Interval      []float64 `json:"interval"`
+	X0            *float64  `json:"x0"`
+	X1            *float64  `json:"x1"`
+	Tolerance     *float64  `json:"tolerance"`
+	MaxIterations *int      `json:"max_iterations"`
+}
+
+// solveRoot is one root. Complex roots of polynomials carry imag; exact and
+// latex hold the closed form when there is one.
+type solveRoot struct {
+	Value        float64 `json:"value"`
+	Imag         float64 `json:"imag,omitempty"`
+	Exact        string  `json:"exact,omitempty"`
+	LaTeX        string  `json:"latex,omitempty"`
+	Multiplicity int     `json:"multiplicity,omitempty"`
+}
+
+// solveResponse names the method used. Iterative methods also report the
+// iterations taken and |f(root)|.
+type solveResponse struct {
+	Method     string      `json:"method"`
+	Roots      []solveRoot `json:"roots"`
+	Iterations int         `json:"iterations,omitempty"`
+	Residual   *float64    `json:"residual,omitempty"`
+}
+
+// solveMethods lists the arguments each method requires and accepts.
+var solveMethods = map[string]struct{ required, optional []string }{
+	"polynomial": {nil, nil},
+	"bracket":    {[]string{"interval"}, []string{"tolerance", "max_iterations"}},
+	"newton":     {[]string{"x0"}, []string{"tolerance", "max_iterations"}},
+	"secant":     {[]string{"x0", "x1"}, []string{"tolerance", "max_iterations"}},
+}
+
+const maxSolveIterations = 10000
+
+// method resolves auto: an interval means bracketing, starting points mean
+// Newton (or secant with two), and otherwise the equation must be a
+// polynomial.
+func (req *solveRequest) method() string {
+	switch {
+	case req.Method != "" && req.Method != "auto":
+		return req.Method
+	case req.Interval != nil:
+		return "bracket"
+	case req.X0 != nil && req.X1 != nil:
+		return "secant"
+	case req.X0 != nil:
+		return "newton"
+	}
+	return "polynomial"
+}
+
+// check rejects missing arguments, arguments the method does not use and
+// out-of-range limits.
+func (req *solveRequest) check(method string) error {
+	present := map[string]bool{
+		"interval":       req.Interval != nil,
+		"x0":             req.X0 != nil,
+		"x1":             req.X1 != nil,
+		"tolerance":      req.Tolerance != nil,
+		"max_iterations": req.MaxIterations != nil,
+	}
+	spec := solveMethods[method]
+	for _, field := range spec.required {
+		if !present[field] {
+			return newProblem(ProblemMissingParams, field, "missing operand for this operation")
+		}
+		delete(present, field)
+	}
+	for _, field := range spec.optional {
+		delete(present, field)
+	}
+	for field, ok := range present {
+		if ok {
+			return newProblem(ProblemInvalidInput, field, "operand is not used by this operation")
+		}
+	}
+	if req.Interval != nil && (len(req.Interval) != 2 || req.Interval[0] == req.Interval[1]) {
+		return newProblem(ProblemInvalidInput, "interval", "interval must be two different numbers")
+	}
+	if req.X1 != nil && *req.X1 == *req.X0 {
+		return newProblem(ProblemInvalidInput, "x1", "x1 must differ from x0")
+	}
+	if t := req.Tolerance; t != nil && (*t <= 0 || *t > 0.1) {
+		return newProblem(ProblemInvalidInput, "tolerance", "tolerance must be greater than 0 and at most 0.1")
+	}
+	if n := req.MaxIterations; n != nil && (*n < 1 || *n > maxSolveIterations) {
+		return newProblem(ProblemInvalidInput, "max_iterations", "max_iterations must be between 1 and 10000")
+	}
+	return nil
+}
+
+func (req *solveRequest) options() solver.Options {
+	o := solver.DefaultOptions
+	if req.Tolerance != nil {
+		o.Tolerance = *req.Tolerance
+	}
+	if req.MaxIterations != nil {
+		o.MaxIter = *req.MaxIterations
+	}
+	return o
+}
+
+func (a *API) solve(w http.ResponseWriter, r *http.Request) {
+	var req solveRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Equation == "" {
+		writeError(w, r, newProblem(ProblemMissingParams, "equation", "equation is required"))
+		return
+	}
+	method := req.method()
+	if _, ok := solveMethods[method]; !ok {
+		writeError(w, r, newProblem(ProblemInvalidOp, "method", "use auto|polynomial|bracket|newton|secant"))
+		return
+	}
+	if err := req.check(method); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	n, err := solver.ParseEquation(req.Equation)
+	if err != nil {
+		writeError(w, r, syntaxProblem("equation", err))
+		return
+	}
+	v, err := derivativeVar(n, req.Var)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	for _, name := range expr.Vars(n) {
+		if name != v {
+			writeError(w, r, newProblem(ProblemInvalidInput, "equation", "equation may only use the variable being solved for"))
+			return
+		}
+	}
+
+	resp, err := solveWith(method, n, v, &req)
+	if err != nil {
+		writeError(w, r, solveProblem(err))
+		return
+	}
+	Write(w, r, http.StatusOK, resp)
+}
+
+// solveWith runs method on the equation n = 0 in the variable v.
+func solveWith(method string, n expr.Node, v string, req *solveRequest) (solveResponse, error) {
+	resp := solveResponse{Method: method, Roots: []solveRoot{}}
+	if method == "polynomial" {
+		p, ok := solver.FromExpr(n, v)
+		if !ok {
+			if req.Method == "polynomial" {
+				return resp, newProblem(ProblemInvalidInput, "equation", "equation is not a polynomial of degree 64 or less")
+			}
+			return resp, newProblem(ProblemMissingParams, "interval", "interval or x0 is required when the equation is not a polynomial")
+		}
+		roots, err := solver.PolynomialRoots(p)
+		if err != nil {
+			return resp, err
+		}
+		for _, root := range roots {
+			resp.Roots = append(resp.Roots, solveRoot{
+				Value:        real(root.Value),
+				Imag:         imag(root.Value),
+				Exact:        root.Exact(),
+				LaTeX:        root.LaTeX(),
+				Multiplicity: root.Multiplicity,
+			})
+		}
+		return resp, nil
+	}
+
+	f := func(x float64) (float64, error) { return expr.Eval(n, map[string]float64{v: x}) }
+	o := req.options()
+	var (
+		res solver.Result
+		err error
+	)
+	switch method {
+	case "bracket":
+		res, err = solver.Bracket(f, req.Interval[0], req.Interval[1], o)
+	case "newton":
+		d, derr := expr.Derivative(n, v)
+		if derr != nil {
+			return resp, derr
+		}
+		df := func(x float64) (float64, error) { return expr.Eval(d, map[string]float64{v: x}) }
+		res, err = solver.Newton(f, df, *req.X0, o)
+	case "secant":
+		res, err = solver.Secant(f, *req.X0, *req.X1, o)
+	}
+	if err != nil {
+		return resp, err
+	}
+	resp.Roots = append(resp.Roots, solveRoot{Value: res.Root})
+	resp.Iterations = res.Iterations
+	resp.Residual = &res.Residual
+	return resp, nil
+}
+
+// solveProblem attaches the solver's diagnostics to its errors: the last
+// estimate of a method that did not converge, and a bracket around each
+// root of an interval that holds several.
+func solveProblem(err error) error {
+	switch e := err.(type) {
+	case *solver.NoConvergenceError:
+		ext := map[string]any{"method": e.Method, "iterations": e.Iterations}
+		if !math.IsNaN(e.Estimate) {
+			ext["estimate"] = e.Estimate
+		}
+		return &problemError{typ: ProblemNoConvergence, detail: e.Error(), ext: ext}
+	case *solver.MultipleRootsError:
+		if e.Identity {
+			return &problemError{typ: ProblemMultipleRoots, field: "equation", detail: e.Error()}
+		}
+		return &problemError{typ: ProblemMultipleRoots, field: "interval", detail: e.Error(), ext: map[string]any{"brackets": e.Brackets}}
+	}
+	return err
+}
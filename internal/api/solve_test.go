@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		method string
+		roots  []float64 // real parts
+		exact  []string
+		code   string // problem title for errors
+		field  string
+	}{
+		{"cubic bracket", `{"equation":"x^3 - 2x - 5 = 0","interval":[2,3]}`, 200, "bracket", []float64{2.0945514815423265}, nil, "", ""},
+		{"cubic newton", `{"equation":"x^3 - 2x - 5","x0":2}`, 200, "newton", []float64{2.0945514815423265}, nil, "", ""},
+		{"cubic secant", `{"equation":"x^3 = 2x + 5","x0":2,"x1":3}`, 200, "secant", []float64{2.0945514815423265}, nil, "", ""},
+		{"transcendental", `{"equation":"cos(t) = t","var":"t","interval":[0,1]}`, 200, "bracket", []float64{0.7390851332151607}, nil, "", ""},
+		{"quadratic", `{"equation":"x^2 = 2"}`, 200, "polynomial", []float64{-math.Sqrt2, math.Sqrt2}, []string{"-sqrt(2)", "sqrt(2)"}, "", ""},
+		{"rational", `{"equation":"6y^2 - y - 1","method":"polynomial"}`, 200, "polynomial", []float64{-1.0 / 3, 0.5}, []string{"-1/3", "0.5"}, "", ""},
+		{"no real roots", `{"equation":"x^2 + 1 = 0"}`, 200, "polynomial", []float64{0, 0}, []string{"-i", "i"}, "", ""},
+		{"no roots", `{"equation":"x = x + 1"}`, 200, "polynomial", []float64{}, nil, "", ""},
+		{"identity", `{"equation":"2(x + 1) = 2x + 2"}`, 422, "", nil, nil, "multiple_roots", "equation"},
+		{"several in interval", `{"equation":"sin(x)","interval":[1,10]}`, 422, "", nil, nil, "multiple_roots", "interval"},
+		{"no sign change", `{"equation":"x^2 + 1","method":"bracket","interval":[-1,1]}`, 400, "", nil, nil, "domain_error", "interval"},
+		{"diverges", `{"equation":"atan(x)","x0":3}`, 422, "", nil, nil, "no_convergence", ""},
+		{"not a polynomial", `{"equation":"sin(x) = x/2"}`, 400, "", nil, nil, "missing_params", "interval"},
+		{"forced polynomial", `{"equation":"sin(x)","method":"polynomial"}`, 400, "", nil, nil, "invalid_input", "equation"},
+		{"syntax", `{"equation":"x^2 = (1"}`, 400, "", nil, nil, "invalid_input", "equation"},
+		{"other variable", `{"equation":"x = y","var":"x"}`, 400, "", nil, nil, "invalid_input", "equation"},
+		{"ambiguous var", `{"equation":"x = y"}`, 400, "", nil, nil, "missing_params", "var"},
+		{"missing x0", `{"equation":"x","method":"newton"}`, 400, "", nil, nil, "missing_params", "x0"},
+		{"unused interval", `{"equation":"x","method":"newton","x0":1,"interval":[0,1]}`, 400, "", nil, nil, "invalid_input", "interval"},
+		{"unused tolerance", `{"equation":"x","tolerance":1e-9}`, 400, "", nil, nil, "invalid_input", "tolerance"},
+		{"bad interval", `{"equation":"x","interval":[1,1]}`, 400, "", nil, nil, "invalid_input", "interval"},
+		{"bad tolerance", `{"equation":"x","x0":1,"tolerance":0}`, 400, "", nil, nil, "invalid_input", "tolerance"},
+		{"bad max_iterations", `{"equation":"x","x0":1,"max_iterations":0}`, 400, "", nil, nil, "invalid_input", "max_iterations"},
+		{"unknown method", `{"equation":"x","method":"bisection"}`, 400, "", nil, nil, "invalid_op", "method"},
+		{"missing equation", `{}`, 400, "", nil, nil, "missing_params", "equation"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/solve", tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != orNil(tc.field) {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got solveResponse
+			json.Unmarshal(body, &got)
+			if got.Method != tc.method || len(got.Roots) != len(tc.roots) {
+				t.Fatalf("response = %s; want %s with %v", body, tc.method, tc.roots)
+			}
+			for i, want := range tc.roots {
+				if math.Abs(got.Roots[i].Value-want) > 1e-12 {
+					t.Errorf("root %d = %v; want %v", i, got.Roots[i].Value, want)
+				}
+				if tc.exact != nil && got.Roots[i].Exact != tc.exact[i] {
+					t.Errorf("root %d exact = %q; want %q", i, got.Roots[i].Exact, tc.exact[i])
+				}
+			}
+			if tc.method != "polynomial" && (got.Iterations == 0 || got.Residual == nil) {
+				t.Errorf("response = %s; want iterations and residual", body)
+			}
+		})
+	}
+}
+
+// orNil maps "" to the absent field extension.
+func orNil(field string) any {
+	if field == "" {
+		return nil
+	}
+	return field
+}
+
+func TestSolve_Diagnostics(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	_, body := postRaw(t, srv.URL+"/v1/solve", `{"equation":"x^3 - 2x - 5","x0":100,"x1":101,"max_iterations":3}`, "application/json")
+	var p Problem
+	json.Unmarshal(body, &p)
+	if p.Extensions["method"] != "secant" || p.Extensions["iterations"] != float64(3) || p.Extensions["estimate"] == nil {
+		t.Fatalf("no_convergence = %s", body)
+	}
+
+	_, body = postRaw(t, srv.URL+"/v1/solve", `{"equation":"cos(x)","interval":[0,10]}`, "application/json")
+	p = Problem{}
+	json.Unmarshal(body, &p)
+	brackets, _ := p.Extensions["brackets"].([]any)
+	if len(brackets) != 3 {
+		t.Fatalf("multiple_roots = %s", body)
+	}
+
+	_, body = postRaw(t, srv.URL+"/v1/solve", `{"equation":"(x - 1)^2*(x^2 - 3)"}`, "application/json")
+	var got solveResponse
+	json.Unmarshal(body, &got)
+	want := []solveRoot{
+		{Value: -math.Sqrt(3), Exact: "-sqrt(3)", LaTeX: `-\sqrt{3}`, Multiplicity: 1},
+		{Value: 1, Exact: "1", LaTeX: "1", Multiplicity: 2},
+		{Value: math.Sqrt(3), Exact: "sqrt(3)", LaTeX: `\sqrt{3}`, Multiplicity: 1},
+	}
+	if len(got.Roots) != len(want) {
+		t.Fatalf("roots = %s", body)
+	}
+	for i, w := range want {
+		g := got.Roots[i]
+		if math.Abs(g.Value-w.Value) > 1e-12 || g.Exact != w.Exact || g.LaTeX != w.LaTeX || g.Multiplicity != w.Multiplicity {
+			t.Errorf("root %d = %+v; want %+v", i, g, w)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+
+	service "erikkruuse/calculator/internal/services"
+	"erikkruuse/calculator/stats"
+)
+
+// maxStatsValues bounds the dataset size of a single /v1/stats request.
+const maxStatsValues = 100000
+
+// statsRequest describes either an explicit dataset (values) or a range of
+// the tenant's history whose successful real results form the dataset.
+type statsRequest struct {
+	Values    []float64     `json:"values"`
+	History   *historyRange `json:"history"`
+	Quantiles []float64     `json:"quantiles"`
+	Bins      int           `json:"bins"`
+}
+
+// historyRange selects history entries by ID, both ends inclusive. A zero To
+// means up to the newest entry.
+type historyRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// historyValues returns the results of successful real-mode entries in rng,
+// oldest first. Overflowed entries are skipped: they were recorded without a
+// value.
+func historyValues(svc service.CalculatorService, rng historyRange) []float64 {
+	var out []float64
+	for _, e := range svc.GetHistory(0) {
+		if e.Mode != "" || e.Error != "" || e.ID < rng.From || (rng.To > 0 && e.ID > rng.To) {
+			continue
+		}
+		if slices.Contains(e.Warnings, service.WarnOverflow) {
+			continue
+		}
+		out = append(out, e.Result)
+	}
+	slices.Reverse(out)
+	return out
+}
+
+func (a *API) describe(w http.ResponseWriter, r *http.Request) {
+	var req statsRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	svc := a.svcFor(r)
+	values := req.Values
+	switch {
+	case req.History != nil && values != nil:
+		writeError(w, r, newProblem(ProblemInvalidInput, "history", "give either values or history, not both"))
+		return
+	case req.History != nil:
+		if req.History.To > 0 && req.History.To < req.History.From {
+			writeError(w, r, newProblem(ProblemInvalidInput, "history", "history range is empty"))
+			return
+		}
+		values = historyValues(svc, *req.History)
+		if len(values) == 0 {
+			writeError(w, r, newProblem(ProblemInvalidInput, "history", "history range contains no results"))
+			return
+		}
+	case values == nil:
+		writeError(w, r, newProblem(ProblemMissingParams, "values", "give either values or history"))
+		return
+	}
+	if len(values) > maxStatsValues {
+		writeError(w, r, tooLarge("values", maxStatsValues))
+		return
+	}
+
+	sum, err := svc.Stats(values, stats.Options{Quantiles: req.Quantiles, Bins: req.Bins})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	Write(w, r, http.StatusOK, sum)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	service "erikkruuse/calculator/internal/services"
+	"erikkruuse/calculator/stats"
+)
+
+func TestStats_Values(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, body := postRaw(t, srv.URL+"/v1/stats", `{"values":[1,2,3,4],"quantiles":[0.5],"bins":2}`, "application/json")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+	}
+	var s stats.Summary
+	json.Unmarshal(body, &s)
+	if s.Count != 4 || s.Mean != 2.5 || len(s.Quantiles) != 1 || s.Quantiles[0].Value != 2.5 || len(s.Histogram) != 2 {
+		t.Fatalf("summary = %s", body)
+	}
+
+	_, body = get(t, srv.URL+"/v1/history")
+	var h []service.HistoryEntry
+	json.Unmarshal(body, &h)
+	if len(h) != 1 || h[0].Mode != service.ModeStats || h[0].Stats == nil || h[0].Stats.Count != 4 || h[0].Result != 2.5 {
+		t.Fatalf("history = %s", body)
+	}
+}
+
+func TestStats_HistoryRange(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	for _, b := range []float64{1, 2, 3, 0} {
+		postJSON(t, srv.URL+"/v1/divide", map[string]any{"a": 6, "b": b})
+	}
+	// IDs 0..3 hold 6, 3, 2 and a division by zero; 1..3 selects 3 and 2.
+	resp, body := postRaw(t, srv.URL+"/v1/stats", `{"history":{"from":1,"to":3}}`, "application/json")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+	}
+	var s stats.Summary
+	json.Unmarshal(body, &s)
+	if s.Count != 2 || s.Sum != 5 {
+		t.Fatalf("summary = %s", body)
+	}
+
+	// The stats entry itself is not a result of later ranges.
+	_, body = postRaw(t, srv.URL+"/v1/stats", `{"history":{"from":0}}`, "application/json")
+	json.Unmarshal(body, &s)
+	if s.Count != 3 {
+		t.Fatalf("open range summary = %s", body)
+	}
+}
+
+func TestStats_HistoryRangeSkipsOverflow(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	// IDs 0..2 hold 4, an overflow recorded without a value, and 8.
+	postJSON(t, srv.URL+"/v1/add", map[string]any{"a": 2, "b": 2})
+	postJSON(t, srv.URL+"/v1/multiply", map[string]any{"a": 1e308, "b": 10})
+	postJSON(t, srv.URL+"/v1/add", map[string]any{"a": 4, "b": 4})
+	resp, body := postRaw(t, srv.URL+"/v1/stats", `{"history":{"from":0,"to":2}}`, "application/json")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+	}
+	var s stats.Summary
+	json.Unmarshal(body, &s)
+	if s.Count != 2 || s.Mean != 6 || s.Min != 4 {
+		t.Fatalf("summary = %s", body)
+	}
+}
+
+func TestStats_Errors(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		body  string
+		code  string
+		field string
+	}{
+		{`{}`, "missing_params", "values"},
+		{`{"values":[]}`, "domain_error", "values"},
+		{`{"values":[1],"history":{}}`, "invalid_input", "history"},
+		{`{"history":{"from":100}}`, "invalid_input", "history"},
+		{`{"values":[1],"quantiles":[2]}`, "domain_error", "quantiles"},
+		{fmt.Sprintf(`{"values":[1],"bins":%d}`, stats.MaxBins+1), "domain_error", "bins"},
+	}
+	for _, tc := range cases {
+		resp, body := postRaw(t, srv.URL+"/v1/stats", tc.body, "application/json")
+		var p Problem
+		json.Unmarshal(body, &p)
+		if resp.StatusCode != 400 || p.Title != tc.code || p.Extensions["field"] != tc.field {
+			t.Errorf("%s: status=%d problem=%s; want %s on %q", tc.body, resp.StatusCode, body, tc.code, tc.field)
+		}
+	}
+}
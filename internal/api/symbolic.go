@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"erikkruuse/calculator/expr"
+)
+
+// symbolicRequest is the body of POST /v1/symbolic/{op}. var names the
+// variable to differentiate by; it defaults to the expression's only
+// variable.
+type symbolicRequest struct {
+	Expr string `json:"expr"`
+	Var  string `json:"var"`
+}
+
+// symbolicResponse gives the result as infix text that /v1/symbolic accepts
+// back, and as LaTeX.
+type symbolicResponse struct {
+	Result string `json:"result"`
+	LaTeX  string `json:"latex"`
+}
+
+func (a *API) symbolicOp(w http.ResponseWriter, r *http.Request) {
+	op := r.PathValue("op")
+	if op != "derivative" && op != "simplify" {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", "use derivative|simplify"))
+		return
+	}
+
+	var req symbolicRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Expr == "" {
+		writeError(w, r, newProblem(ProblemMissingParams, "expr", "expr is required"))
+		return
+	}
+	n, err := parseExpr(req.Expr)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var res expr.Node
+	switch op {
+	case "simplify":
+		if req.Var != "" {
+			writeError(w, r, newProblem(ProblemInvalidInput, "var", "operand is not used by this operation"))
+			return
+		}
+		res = expr.Simplify(n)
+	case "derivative":
+		v, err := derivativeVar(n, req.Var)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if res, err = expr.Derivative(n, v); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	Write(w, r, http.StatusOK, symbolicResponse{Result: res.String(), LaTeX: expr.LaTeX(res)})
+}
+
+// parseExpr parses an expression field, reporting syntax errors with the
+// byte offset where parsing stopped.
+func parseExpr(s string) (expr.Node, error) {
+	n, err := expr.Parse(s)
+	return n, syntaxProblem("expr", err)
+}
+
+// syntaxProblem reports a syntax error in field as invalid_input with the
+// offset where parsing stopped, and passes other errors through.
+func syntaxProblem(field string, err error) error {
+	var se *expr.SyntaxError
+	if errors.As(err, &se) {
+		return &problemError{
+			typ:    ProblemInvalidInput,
+			field:  field,
+			detail: se.Msg,
+			ext:    map[string]any{"offset": se.Offset},
+		}
+	}
+	return err
+}
+
+// derivativeVar validates the requested variable or picks the expression's
+// only one.
+func derivativeVar(n expr.Node, v string) (string, error) {
+	if v == "" {
+		switch vars := expr.Vars(n); len(vars) {
+		case 0:
+			return "x", nil
+		case 1:
+			return vars[0], nil
+		}
+		return "", newProblem(ProblemMissingParams, "var", "var is required when the expression has several variables")
+	}
+	name, err := expr.Parse(v)
+	if _, ok := name.(*expr.Var); err != nil || !ok || name.String() != v {
+		return "", newProblem(ProblemInvalidInput, "var", "var must be a variable name")
+	}
+	if expr.IsConst(v) {
+		return "", newProblem(ProblemInvalidInput, "var", expr.ErrConstantVariable.Error())
+	}
+	return v, nil
+}
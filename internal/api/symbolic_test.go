@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSymbolicOp(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		op     string
+		body   string
+		status int
+		result string
+		latex  string
+		code   string // problem title for errors
+		field  string
+	}{
+		{"derivative", "derivative", `{"expr":"x^3 - 2x - 5"}`, 200, "3*x^2 - 2", "3x^{2} - 2", "", ""},
+		{"chain rule", "derivative", `{"expr":"sin(t^2)","var":"t"}`, 200, "2*t*cos(t^2)", `2t \cdot \cos\left(t^{2}\right)`, "", ""},
+		{"partial", "derivative", `{"expr":"x^2*y","var":"y"}`, 200, "x^2", "x^{2}", "", ""},
+		{"constant", "derivative", `{"expr":"pi"}`, 200, "0", "0", "", ""},
+		{"simplify", "simplify", `{"expr":"x + 2x - x/2"}`, 200, "5*x/2", `\frac{5x}{2}`, "", ""},
+		{"syntax", "simplify", `{"expr":"2 * (x + 1"}`, 400, "", "", "invalid_input", "expr"},
+		{"ambiguous var", "derivative", `{"expr":"x*y"}`, 400, "", "", "missing_params", "var"},
+		{"bad var", "derivative", `{"expr":"x","var":"x+1"}`, 400, "", "", "invalid_input", "var"},
+		{"constant var", "derivative", `{"expr":"x","var":"pi"}`, 400, "", "", "invalid_input", "var"},
+		{"unused var", "simplify", `{"expr":"x","var":"x"}`, 400, "", "", "invalid_input", "var"},
+		{"missing expr", "simplify", `{}`, 400, "", "", "missing_params", "expr"},
+		{"unknown op", "integral", `{"expr":"x"}`, 400, "", "", "invalid_op", "op"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+"/v1/symbolic/"+tc.op, tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.status != 200 {
+				var p Problem
+				json.Unmarshal(body, &p)
+				if p.Title != tc.code || p.Extensions["field"] != tc.field {
+					t.Fatalf("problem = %s; want %s on %q", body, tc.code, tc.field)
+				}
+				return
+			}
+			var got symbolicResponse
+			json.Unmarshal(body, &got)
+			if got.Result != tc.result || got.LaTeX != tc.latex {
+				t.Fatalf("response = %+v; want %s, %s", got, tc.result, tc.latex)
+			}
+		})
+	}
+}
+
+func TestSymbolicOp_SyntaxOffset(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	_, body := postRaw(t, srv.URL+"/v1/symbolic/simplify", `{"expr":"1 + * 2"}`, "application/json")
+	var p Problem
+	json.Unmarshal(body, &p)
+	if p.Detail != "unexpected symbol" || p.Extensions["offset"] != float64(4) {
+		t.Fatalf("problem = %s", body)
+	}
+}
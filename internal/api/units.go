@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+
+	service "erikkruuse/calculator/internal/services"
+	"erikkruuse/calculator/units"
+)
+
+// unitsRequest is the body of POST /v1/units/{op}: quantities such as "5 km"
+// and an optional target unit.
+type unitsRequest struct {
+	A  string `json:"a"`
+	B  string `json:"b"`
+	To string `json:"to"`
+}
+
+// unitsExprRequest is the body of POST /v1/units, e.g.
+// {"expr": "convert 70 mph to km/h"}.
+type unitsExprRequest struct {
+	Expr string `json:"expr"`
+}
+
+type unitsResponse struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+	Text  string  `json:"text"`
+}
+
+const unitOpsHint = "use add|subtract|multiply|divide|convert"
+
+func (a *API) unitsOp(w http.ResponseWriter, r *http.Request) {
+	op := r.PathValue("op")
+	arity := service.UnitArity(op)
+	if arity == 0 {
+		writeError(w, r, newProblem(ProblemInvalidOp, "op", unitOpsHint))
+		return
+	}
+
+	var req unitsRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	switch {
+	case req.A == "":
+		writeError(w, r, newProblem(ProblemMissingParams, "a", "quantity is required"))
+		return
+	case arity == 2 && req.B == "":
+		writeError(w, r, newProblem(ProblemMissingParams, "b", "quantity is required"))
+		return
+	case arity == 1 && req.B != "":
+		writeError(w, r, newProblem(ProblemInvalidInput, "b", "b is not used by this operation"))
+		return
+	}
+	a.writeUnits(w, r, units.Expr{Op: op, A: req.A, B: req.B, To: req.To})
+}
+
+func (a *API) unitsExpr(w http.ResponseWriter, r *http.Request) {
+	var req unitsExprRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Expr == "" {
+		writeError(w, r, newProblem(ProblemMissingParams, "expr", "expr is required"))
+		return
+	}
+	e, err := units.ParseExpr(req.Expr)
+	if err != nil {
+		writeError(w, r, newProblem(ProblemInvalidInput, "expr", err.Error()))
+		return
+	}
+	if e.Op == "" {
+		writeError(w, r, newProblem(ProblemInvalidInput, "expr", "expression has no operation"))
+		return
+	}
+	a.writeUnits(w, r, e)
+}
+
+func (a *API) writeUnits(w http.ResponseWriter, r *http.Request, e units.Expr) {
+	q, err := a.svcFor(r).CalculateUnits(e.Op, e.A, e.B, e.To)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	Write(w, r, http.StatusOK, unitsResponse{Value: q.Value, Unit: q.Unit.Symbol, Text: q.String()})
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	service "erikkruuse/calculator/internal/services"
+)
+
+func TestUnitsOp(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		text   string
+		typ    string // problem code for errors
+		field  string
+	}{
+		{"add", "/v1/units/add", `{"a":"5 km","b":"300 m"}`, 200, "5.3 km", "", ""},
+		{"convert", "/v1/units/convert", `{"a":"70 mph","to":"km/h"}`, 200, "112.65408 km/h", "", ""},
+		{"divide to", "/v1/units/divide", `{"a":"3 km","b":"30 min","to":"km/h"}`, 200, "6 km/h", "", ""},
+		{"temperature", "/v1/units/convert", `{"a":"100 °C","to":"°F"}`, 200, "212 °F", "", ""},
+		{"expr add", "/v1/units", `{"expr":"5 km + 300 m"}`, 200, "5.3 km", "", ""},
+		{"expr convert", "/v1/units", `{"expr":"convert 70 mph to km/h"}`, 200, "112.65408 km/h", "", ""},
+		{"dimension mismatch", "/v1/units/add", `{"a":"3 kg","b":"2 m"}`, 400, "", "dimension_mismatch", "b"},
+		{"expr mismatch", "/v1/units", `{"expr":"3 kg + 2 m"}`, 400, "", "dimension_mismatch", "b"},
+		{"convert mismatch", "/v1/units/convert", `{"a":"3 kg","to":"m"}`, 400, "", "dimension_mismatch", "to"},
+		{"offset product", "/v1/units/multiply", `{"a":"3 °C","b":"2 m"}`, 400, "", "domain_error", ""},
+		{"unknown unit", "/v1/units/add", `{"a":"1 m","b":"1 parsec"}`, 400, "", "invalid_input", "b"},
+		{"missing target", "/v1/units/convert", `{"a":"1 m"}`, 400, "", "invalid_input", "to"},
+		{"missing b", "/v1/units/add", `{"a":"1 m"}`, 400, "", "missing_params", "b"},
+		{"extra b", "/v1/units/convert", `{"a":"1 m","b":"2 m","to":"ft"}`, 400, "", "invalid_input", "b"},
+		{"expr without op", "/v1/units", `{"expr":"12 ft"}`, 400, "", "invalid_input", "expr"},
+		{"empty expr", "/v1/units", `{}`, 400, "", "missing_params", "expr"},
+		{"unknown op", "/v1/units/pow", `{"a":"1 m","b":"2"}`, 400, "", "invalid_op", "op"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := postRaw(t, srv.URL+tc.path, tc.body, "application/json")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d (%s)", resp.StatusCode, body)
+			}
+			if tc.status == 200 {
+				var got unitsResponse
+				json.Unmarshal(body, &got)
+				if got.Text != tc.text {
+					t.Fatalf("response = %s; want text %q", body, tc.text)
+				}
+				return
+			}
+			var p Problem
+			json.Unmarshal(body, &p)
+			if p.Title != tc.typ || (tc.field != "" && p.Extensions["field"] != tc.field) {
+				t.Fatalf("problem = %s; want %s on %q", body, tc.typ, tc.field)
+			}
+		})
+	}
+}
+
+func TestUnitsOp_History(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	postRaw(t, srv.URL+"/v1/units", `{"expr":"5 km + 300 m in m"}`, "application/json")
+	_, body := get(t, srv.URL+"/v1/history")
+	var h []service.HistoryEntry
+	json.Unmarshal(body, &h)
+	if len(h) != 1 || h[0].Mode != service.ModeUnits || h[0].Quantity != "5300 m" || h[0].Result != 5300 || len(h[0].Quantities) != 2 {
+		t.Fatalf("history = %s", body)
+	}
+}
@@ -0,0 +1,174 @@
+// Package i18n holds the embedded message catalogs and the locale-specific
+// number formats used by the API.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+// Locale is a supported language with its translations and number separators.
+type Locale struct {
+	Tag      language.Tag
+	Decimal  string // decimal separator
+	Group    string // thousands separator
+	messages map[string]string
+	prefixes []string // keys ending in a space, longest first
+}
+
+type catalogFile struct {
+	Tag      string            `json:"tag"`
+	Decimal  string            `json:"decimal"`
+	Group    string            `json:"group"`
+	Messages map[string]string `json:"messages"`
+}
+
+var (
+	// English is the fallback locale; its messages are the catalog keys.
+	English *Locale
+
+	locales []*Locale
+	matcher language.Matcher
+)
+
+func init() {
+	names, err := catalogFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	byTag := map[language.Tag]*Locale{}
+	for _, n := range names {
+		l, err := loadCatalog(path.Join("locales", n.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", n.Name(), err))
+		}
+		byTag[l.Tag] = l
+	}
+	English = byTag[language.English]
+	if English == nil {
+		panic("i18n: missing English catalog")
+	}
+
+	// English goes first so the matcher falls back to it.
+	locales = []*Locale{English}
+	for _, l := range byTag {
+		if l != English {
+			locales = append(locales, l)
+		}
+	}
+	tags := make([]language.Tag, len(locales))
+	for i, l := range locales {
+		tags[i] = l.Tag
+	}
+	matcher = language.NewMatcher(tags)
+}
+
+func loadCatalog(name string) (*Locale, error) {
+	b, err := catalogFiles.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var cf catalogFile
+	if err := json.Unmarshal(b, &cf); err != nil {
+		return nil, err
+	}
+	tag, err := language.Parse(cf.Tag)
+	if err != nil {
+		return nil, err
+	}
+	if cf.Decimal == "" || cf.Decimal == cf.Group {
+		return nil, fmt.Errorf("invalid separators %q and %q", cf.Decimal, cf.Group)
+	}
+	l := &Locale{Tag: tag, Decimal: cf.Decimal, Group: cf.Group, messages: cf.Messages}
+	for key := range cf.Messages {
+		if strings.HasSuffix(key, " ") {
+			l.prefixes = append(l.prefixes, key)
+		}
+	}
+	slices.SortFunc(l.prefixes, func(a, b string) int { return len(b) - len(a) })
+	return l, nil
+}
+
+// Match returns the supported locale that best satisfies an Accept-Language
+// header, falling back to English.
+func Match(acceptLanguage string) *Locale {
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return English
+	}
+	_, i, conf := matcher.Match(prefs...)
+	if conf == language.No {
+		return English
+	}
+	return locales[i]
+}
+
+// Lookup returns the supported locale for a BCP 47 tag such as "de" or "fr-CH".
+func Lookup(name string) (*Locale, bool) {
+	tag, err := language.Parse(name)
+	if err != nil {
+		return nil, false
+	}
+	_, i, conf := matcher.Match(tag)
+	if conf == language.No || conf == language.Low {
+		return nil, false
+	}
+	return locales[i], true
+}
+
+// Translate returns the catalog translation of an English message, or msg
+// itself when the locale has none. A key ending in a space, such as "use ",
+// translates the start of messages that go on with data like operation names.
+func (l *Locale) Translate(msg string) string {
+	if t, ok := l.translate(msg); ok {
+		return t
+	}
+	return msg
+}
+
+func (l *Locale) translate(msg string) (string, bool) {
+	if t, ok := l.messages[msg]; ok && t != "" {
+		return t, true
+	}
+	for _, key := range l.prefixes {
+		if t := l.messages[key]; t != "" && strings.HasPrefix(msg, key) {
+			return t + msg[len(key):], true
+		}
+	}
+	return "", false
+}
+
+// Sprintf translates format and formats args, writing numeric arguments with
+// the locale's separators.
+func (l *Locale) Sprintf(format string, args ...any) string {
+	local := make([]any, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case float64:
+			local[i] = number{l, v}
+		case int:
+			local[i] = number{l, float64(v)}
+		default:
+			local[i] = a
+		}
+	}
+	return fmt.Sprintf(l.Translate(format), local...)
+}
+
+// number prints through the locale regardless of the formatting verb.
+type number struct {
+	l *Locale
+	f float64
+}
+
+func (n number) Format(s fmt.State, _ rune) { _, _ = io.WriteString(s, n.l.FormatFloat(n.f)) }
@@ -0,0 +1,235 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"de-DE,de;q=0.9,en;q=0.8", "de"},
+		{"fr-CH", "fr"},
+		{"pt-BR, es;q=0.5", "es"},
+		{"ja", "en"},
+		{"not a tag!!", "en"},
+	}
+	for _, tc := range cases {
+		if got := Match(tc.header).Tag.String(); got != tc.want {
+			t.Errorf("Match(%q) = %s; want %s", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if l, ok := Lookup("de-AT"); !ok || l.Tag.String() != "de" {
+		t.Fatalf("Lookup(de-AT) = %v, %v", l, ok)
+	}
+	if _, ok := Lookup("ja"); ok {
+		t.Fatalf("Lookup(ja) should fail")
+	}
+}
+
+func TestCatalogsTranslateEveryProblemDetail(t *testing.T) {
+	de := Match("de")
+	if got := de.Translate("division by zero is not allowed"); got != "Division durch null ist nicht erlaubt" {
+		t.Fatalf("Translate = %q", got)
+	}
+	if got := de.Translate("use add|subtract|hypot"); got != "verwenden Sie add|subtract|hypot" {
+		t.Fatalf("prefix Translate = %q", got)
+	}
+	if got := de.Translate("no such message"); got != "no such message" {
+		t.Fatalf("missing translations must fall back to English, got %q", got)
+	}
+
+	details := problemDetails(t)
+	if len(details) < 100 {
+		t.Fatalf("found only %d problem details; the scan is broken", len(details))
+	}
+	for _, l := range locales {
+		for key := range l.messages {
+			if strings.Count(key, "%") != strings.Count(l.Translate(key), "%") {
+				t.Errorf("%s: translation of %q changes its format verbs", l.Tag, key)
+			}
+		}
+		if l == English {
+			continue
+		}
+		for msg, where := range details {
+			if _, ok := l.translate(msg); !ok {
+				t.Errorf("%s: no translation of %q from %s", l.Tag, msg, where)
+			}
+		}
+	}
+}
+
+// problemDetails collects the messages of the module that can reach a client
+// as a problem detail: the details given to newProblem and writeProblem, the
+// Msg and detail fields of error values, and the parsers' syntax error
+// messages. A message built by concatenation contributes its leading literal,
+// which the catalogs translate as a prefix.
+func problemDetails(t *testing.T) map[string]string {
+	t.Helper()
+	found := map[string]string{} // message → where
+	fset := token.NewFileSet()
+	add := func(e ast.Expr) {
+		for {
+			b, ok := e.(*ast.BinaryExpr)
+			if !ok || b.Op != token.ADD {
+				break
+			}
+			e = b.X
+		}
+		lit, ok := e.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return
+		}
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil || s == "" {
+			return
+		}
+		if _, seen := found[s]; !seen {
+			found[s] = fset.Position(lit.Pos()).String()
+		}
+	}
+	root := filepath.Join("..", "..")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				// The parsers' messages for misplaced tokens.
+				if n.Name.Name == "unexpected" && n.Body != nil {
+					ast.Inspect(n.Body, func(n ast.Node) bool {
+						if r, ok := n.(*ast.ReturnStmt); ok && len(r.Results) == 1 {
+							add(r.Results[0])
+						}
+						return true
+					})
+				}
+			case *ast.CallExpr:
+				var name string
+				switch fn := n.Fun.(type) {
+				case *ast.Ident:
+					name = fn.Name
+				case *ast.SelectorExpr:
+					name = fn.Sel.Name
+				}
+				switch {
+				case name == "newProblem" && len(n.Args) == 3:
+					add(n.Args[2])
+				case name == "writeProblem" && len(n.Args) == 5:
+					add(n.Args[3])
+				case name == "fail" && len(n.Args) == 1:
+					add(n.Args[0])
+				}
+			case *ast.CompositeLit:
+				// SyntaxError{pos, msg} in expr, {line, col, msg} in script.
+				if id, ok := n.Type.(*ast.Ident); ok && id.Name == "SyntaxError" && len(n.Elts) > 1 {
+					add(n.Elts[len(n.Elts)-1])
+				}
+				for _, e := range n.Elts {
+					kv, ok := e.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+					if key, ok := kv.Key.(*ast.Ident); ok && slices.Contains([]string{"Msg", "detail"}, key.Name) {
+						add(kv.Value)
+					}
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestSprintf_LocalizesNumbers(t *testing.T) {
+	if got := Match("de").Sprintf("daily quota of %d calculations exhausted", 10000); got != "Tageskontingent von 10.000 Berechnungen ausgeschöpft" {
+		t.Fatalf("Sprintf = %q", got)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	de, fr := Match("de"), Match("fr")
+	cases := []struct {
+		l    *Locale
+		in   float64
+		want string
+	}{
+		{English, 1234567.25, "1,234,567.25"},
+		{de, 1234.5, "1.234,5"},
+		{de, -999, "-999"},
+		{de, 0.000001, "0,000001"},
+		{de, 1e-7, "1e-07"},
+		{de, 2.5e22, "2,5e+22"},
+		{fr, 12345.5, "12 345,5"},
+	}
+	for _, tc := range cases {
+		if got := tc.l.FormatFloat(tc.in); got != tc.want {
+			t.Errorf("%s FormatFloat(%v) = %q; want %q", tc.l.Tag, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	de, fr := Match("de"), Match("fr")
+	ok := []struct {
+		l    *Locale
+		in   string
+		want float64
+	}{
+		{de, "1.234,5", 1234.5},
+		{de, "1234,5", 1234.5},
+		{de, "-12.345.678", -12345678},
+		{de, "0,5", 0.5},
+		{fr, "12 345,5", 12345.5},
+		{fr, "12 345,5", 12345.5},
+		{English, "1,234.5", 1234.5},
+	}
+	for _, tc := range ok {
+		got, err := tc.l.ParseFloat(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("%s ParseFloat(%q) = %v, %v; want %v", tc.l.Tag, tc.in, got, err, tc.want)
+		}
+	}
+
+	bad := []struct {
+		l  *Locale
+		in string
+	}{
+		{de, "1.5"},
+		{de, "1,2,3"},
+		{de, "12.34"},
+		{de, "1..234"},
+		{de, "abc"},
+		{de, "1,"},
+		{de, ",5"},
+		{English, "1.234,5"},
+	}
+	for _, tc := range bad {
+		if got, err := tc.l.ParseFloat(tc.in); err == nil {
+			t.Errorf("%s ParseFloat(%q) = %v; want error", tc.l.Tag, tc.in, got)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package i18n
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidNumber is returned by ParseFloat for input that is not a number in
+// the locale's format.
+var ErrInvalidNumber = errors.New("not a number in this locale")
+
+// FormatFloat formats f with the locale's decimal and grouping separators.
+// Very large and very small magnitudes use exponent notation.
+func (l *Locale) FormatFloat(f float64) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	abs := math.Abs(f)
+	if abs >= 1e21 || (abs != 0 && abs < 1e-6) {
+		return strings.Replace(strconv.FormatFloat(f, 'e', -1, 64), ".", l.Decimal, 1)
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(d)
+	}
+	if hasFrac {
+		b.WriteString(l.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// ParseFloat parses a number written with the locale's separators, such as
+// "1.234,5" in German. Grouping is optional but, when present, must split the
+// integer part into groups of three digits. Locales that group with a space
+// accept any space character.
+func (l *Locale) ParseFloat(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	sign := ""
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		sign, s = s[:1], s[1:]
+	}
+	intPart, frac, hasFrac := strings.Cut(s, l.Decimal)
+	if strings.Contains(frac, l.Decimal) {
+		return 0, ErrInvalidNumber
+	}
+
+	groups := l.splitGroups(intPart)
+	for i, g := range groups {
+		if g == "" || !allDigits(g) {
+			return 0, ErrInvalidNumber
+		}
+		if len(groups) > 1 && ((i == 0 && len(g) > 3) || (i > 0 && len(g) != 3)) {
+			return 0, ErrInvalidNumber
+		}
+	}
+
+	canonical := sign + strings.Join(groups, "")
+	if hasFrac {
+		if frac == "" || !strings.ContainsAny(frac[:1], "0123456789") {
+			return 0, ErrInvalidNumber
+		}
+		canonical += "." + frac
+	}
+	f, err := strconv.ParseFloat(canonical, 64)
+	if err != nil {
+		return 0, ErrInvalidNumber
+	}
+	return f, nil
+}
+
+func (l *Locale) splitGroups(s string) []string {
+	if l.Group == "" {
+		return []string{s}
+	}
+	if r := []rune(l.Group); len(r) == 1 && unicode.IsSpace(r[0]) {
+		return strings.FieldsFunc(s, unicode.IsSpace)
+	}
+	return strings.Split(s, l.Group)
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
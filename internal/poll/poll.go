@@ -0,0 +1,54 @@
+// Package poll runs a function at a fixed interval on its own goroutine, as
+// the rate table and plugin watchers do to pick up changed files.
+package poll
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInterval is returned by Start for an interval that is not positive.
+var ErrInterval = errors.New("watch interval must be positive")
+
+// Poller calls a function every interval until it is stopped.
+type Poller struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Start calls f every interval on a new goroutine. The first call happens one
+// interval after Start returns.
+func Start(every time.Duration, f func()) (*Poller, error) {
+	if every <= 0 {
+		return nil, ErrInterval
+	}
+	p := &Poller{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				f()
+			}
+		}
+	}()
+	return p, nil
+}
+
+// Stop stops the poller and waits for a call in progress to return. It is
+// safe to call more than once and on a nil Poller.
+func (p *Poller) Stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+}
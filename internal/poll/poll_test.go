@@ -0,0 +1,39 @@
+package poll
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	for _, every := range []time.Duration{0, -time.Second} {
+		if p, err := Start(every, func() {}); !errors.Is(err, ErrInterval) || p != nil {
+			t.Fatalf("Start(%s) = %v, %v; want ErrInterval", every, p, err)
+		}
+	}
+
+	var calls atomic.Int32
+	p, err := Start(time.Millisecond, func() { calls.Add(1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("poller did not call f")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	p.Stop()
+	n := calls.Load()
+	time.Sleep(10 * time.Millisecond)
+	if calls.Load() != n {
+		t.Fatal("poller called f after Stop")
+	}
+	p.Stop()
+
+	var nilPoller *Poller
+	nilPoller.Stop()
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// CacheStats describes result cache usage.
+type CacheStats struct {
+	Enabled  bool  `json:"enabled"`
+	Entries  int   `json:"entries"`
+	Capacity int   `json:"capacity"`
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+}
+
+// WithResultCache memoizes up to size results for ttl (zero ttl never expires).
+// Every call is still recorded in history.
+func WithResultCache(size int, ttl time.Duration) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.cacheSize = size
+			c.cacheTTL = ttl
+		}
+	}
+}
+
+type cacheKey struct {
+	op   string
+	id   uint64 // registration, so a replaced operation misses
+	a, b uint64
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	res     float64
+	err     error
+	expires time.Time
+}
+
+// resultCache is an LRU of computed results keyed by op and canonical operands.
+type resultCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[cacheKey]*list.Element
+	lru      *list.List // front = most recently used
+	hits     int64
+	misses   int64
+	now      func() time.Time
+}
+
+func newResultCache(capacity int, ttl time.Duration) *resultCache {
+	return &resultCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  map[cacheKey]*list.Element{},
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// canonicalKey keys on exact operand bits (so -0 and +0 stay distinct, as the
+// ops can observe the sign) with the operands of commutative ops ordered, so
+// they share a cache entry regardless of operand order.
+func canonicalKey(op calculator.Operation, a, b float64) cacheKey {
+	if op.Commutative && a > b {
+		a, b = b, a
+	}
+	return cacheKey{op: op.Name, id: op.ID(), a: math.Float64bits(a), b: math.Float64bits(b)}
+}
+
+// get returns the cached result for op(a, b), computing and storing it on a
+// miss. With lookup false the cache is bypassed but refreshed with the result.
+func (c *resultCache) get(op calculator.Operation, a, b float64, lookup bool) (float64, error) {
+	key := canonicalKey(op, a, b)
+	now := c.now()
+
+	if lookup {
+		c.mu.Lock()
+		if el, ok := c.entries[key]; ok {
+			e := el.Value.(*cacheEntry)
+			if c.ttl <= 0 || now.Before(e.expires) {
+				c.lru.MoveToFront(el)
+				c.hits++
+				c.mu.Unlock()
+				return e.res, e.err
+			}
+			c.removeLocked(el)
+		}
+		c.misses++
+		c.mu.Unlock()
+	}
+
+	res, err := op.Fn(a, b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeLocked(el)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, res: res, err: err, expires: now.Add(c.ttl)})
+	for c.lru.Len() > c.capacity {
+		c.removeLocked(c.lru.Back())
+	}
+	return res, err
+}
+
+func (c *resultCache) removeLocked(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+func (c *resultCache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Enabled:  true,
+		Entries:  c.lru.Len(),
+		Capacity: c.capacity,
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+}
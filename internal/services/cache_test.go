@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func TestResultCache_HitsMissesAndHistory(t *testing.T) {
+	svc := NewCalculatorService(WithResultCache(10, 0))
+
+	svc.Add(2, 3)
+	svc.Add(2, 3)
+	svc.Add(3, 2) // commutative: same entry
+	svc.Subtract(2, 3)
+	svc.Subtract(3, 2) // not commutative: new entry
+
+	st := svc.CacheStats()
+	if !st.Enabled || st.Hits != 2 || st.Misses != 3 || st.Entries != 3 {
+		t.Fatalf("stats = %+v; want 2 hits, 3 misses, 3 entries", st)
+	}
+	if n := len(svc.GetHistory(0)); n != 5 {
+		t.Fatalf("history len=%d; want every call recorded", n)
+	}
+	if got := svc.Subtract(3, 2); got != 1 {
+		t.Fatalf("Subtract(3,2) = %v; want 1", got)
+	}
+}
+
+func TestResultCache_ErrorsAreRecorded(t *testing.T) {
+	svc := NewCalculatorService(WithResultCache(10, 0))
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Divide(1, 0); err == nil {
+			t.Fatalf("call %d: expected division error", i)
+		}
+	}
+	h := svc.GetHistory(0)
+	if len(h) != 2 || h[0].Error == "" || h[1].Error == "" {
+		t.Fatalf("history = %+v; want two error entries", h)
+	}
+	if st := svc.CacheStats(); st.Hits != 1 {
+		t.Fatalf("stats = %+v; want the second call served from cache", st)
+	}
+}
+
+func TestResultCache_LRUAndTTL(t *testing.T) {
+	c := newResultCache(2, time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	add, _ := calculator.DefaultRegistry.Lookup("add")
+
+	c.get(add, 1, 1, true)
+	c.get(add, 2, 2, true)
+	c.get(add, 1, 1, true) // touch 1+1 so 2+2 is least recent
+	c.get(add, 3, 3, true) // evicts 2+2
+	c.get(add, 2, 2, true) // miss
+	if st := c.stats(); st.Hits != 1 || st.Misses != 4 || st.Entries != 2 {
+		t.Fatalf("stats = %+v", st)
+	}
+
+	now = now.Add(2 * time.Minute)
+	c.get(add, 2, 2, true) // expired
+	if st := c.stats(); st.Hits != 1 || st.Misses != 5 {
+		t.Fatalf("stats after expiry = %+v", st)
+	}
+}
+
+func TestResultCache_WithoutCacheRecomputes(t *testing.T) {
+	svc := NewCalculatorService(WithResultCache(10, 0))
+	svc.Multiply(4, 5)
+	svc.WithoutCache().Multiply(4, 5)
+	svc.ForTenant("t").WithoutCache().Multiply(4, 5)
+
+	st := svc.CacheStats()
+	if st.Hits != 0 || st.Misses != 1 {
+		t.Fatalf("stats = %+v; bypassed calls must not hit or miss", st)
+	}
+	if n := len(svc.ForTenant("t").GetHistory(0)); n != 1 {
+		t.Fatalf("tenant view lost by WithoutCache; len=%d", n)
+	}
+}
+
+func TestResultCache_DisabledByDefault(t *testing.T) {
+	svc := NewCalculatorService()
+	svc.Add(1, 1)
+	if st := svc.CacheStats(); st.Enabled || st.Misses != 0 {
+		t.Fatalf("stats = %+v; want disabled cache", st)
+	}
+}
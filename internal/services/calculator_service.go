@@ -1,20 +1,69 @@
 package service
 
 import (
+	"context"
 	"erikkruuse/calculator/calculator"
-	"errors"
-	"sync"
+	"erikkruuse/calculator/currency"
+	"erikkruuse/calculator/stats"
+	"erikkruuse/calculator/temporal"
+	"erikkruuse/calculator/units"
+	"math"
+	"strings"
 	"time"
 )
 
 type HistoryEntry struct {
 	ID     int64     `json:"id"`
 	Time   time.Time `json:"time"`
+	Tenant string    `json:"tenant,omitempty"`
 	Op     string    `json:"op"`
 	A      float64   `json:"a"`
 	B      float64   `json:"b"`
 	Result float64   `json:"result,omitempty"`
 	Error  string    `json:"error,omitempty"`
+	// Warnings lists precision problems detected in Result, e.g. "overflow".
+	Warnings []string `json:"warnings,omitempty"`
+	// Entries with measured operands record the standard uncertainties of A,
+	// B and Result.
+	UncertaintyA *float64 `json:"uncertainty_a,omitempty"`
+	UncertaintyB *float64 `json:"uncertainty_b,omitempty"`
+	Uncertainty  *float64 `json:"uncertainty,omitempty"`
+
+	// Complex-mode entries (Mode "complex") record their operands in Operands
+	// and the result in Complex, Polar or, for real-valued ops, Result.
+	Mode     string               `json:"mode,omitempty"`
+	Operands []calculator.Complex `json:"operands,omitempty"`
+	Complex  *calculator.Complex  `json:"complex,omitempty"`
+	Polar    *calculator.Polar    `json:"polar,omitempty"`
+	// Stats summarizes the dataset of a Mode "stats" entry; Result is its mean.
+	Stats *StatsSummary `json:"stats,omitempty"`
+
+	// Integer-mode entries (Mode "int8" … "uint64") record operands and result
+	// as decimal strings because 64-bit values do not fit in a float64.
+	IntA      string `json:"int_a,omitempty"`
+	IntB      string `json:"int_b,omitempty"`
+	IntResult string `json:"int_result,omitempty"`
+
+	// Unit- and currency-mode entries record operands and result as text, e.g.
+	// "5 km" or "92.17 EUR"; Result holds the numeric part of Quantity.
+	Quantities []string `json:"quantities,omitempty"`
+	Quantity   string   `json:"quantity,omitempty"`
+	// Rates records the exchange rates used by a Mode "currency" entry.
+	Rates *RateSnapshot `json:"rates,omitempty"`
+
+	// Date-mode entries (Mode "dates") record typed operands and result, e.g.
+	// {"type": "duration", "value": "P45D"}, and the business day calendar used.
+	DateOperands []temporal.Value `json:"date_operands,omitempty"`
+	DateResult   *temporal.Value  `json:"date_result,omitempty"`
+	Calendar     string           `json:"calendar,omitempty"`
+
+	// Interval-mode entries (Mode "interval") record operands and result as
+	// [lo, hi] pairs; a quotient split by extended division has two parts.
+	Intervals      []calculator.Interval `json:"intervals,omitempty"`
+	IntervalResult []calculator.Interval `json:"interval_result,omitempty"`
+
+	// Run groups the entries recorded by one RunScript call.
+	Run int64 `json:"run,omitempty"`
 }
 
 type CalculatorService interface {
@@ -22,23 +71,122 @@ type CalculatorService interface {
 	Subtract(a, b float64) float64
 	Multiply(a, b float64) float64
 	Divide(a, b float64) (float64, error)
+	// Calculate performs a registered operation (see Operations), named or
+	// aliased by op, and reports precision warnings alongside the value. b is
+	// ignored by unary operations. In Strict mode overflow is returned as an
+	// error; the plain methods above then return ±Inf.
+	Calculate(op string, a, b float64) (Result, error)
+	// CalculateUncertain performs op like Calculate on measured values and
+	// propagates their standard uncertainties to first order; see
+	// calculator.Uncertain. The result cache is not consulted.
+	CalculateUncertain(op string, a, b calculator.Uncertain) (Result, error)
+	// CalculateComplex performs a complex operation such as "multiply",
+	// "modulus" or "sqrt" on one or two operands; see ComplexArity.
+	CalculateComplex(op string, operands ...calculator.Complex) (ComplexResult, error)
+	// CalculateInterval performs add, subtract, multiply or divide on
+	// intervals with outward rounding. A divisor containing zero fails unless
+	// extended is set, in which case the quotient may be unbounded or split.
+	CalculateInterval(op string, a, b calculator.Interval, extended bool) (IntervalResult, error)
+	// Stats describes a dataset and records a summarized history entry.
+	Stats(values []float64, opts stats.Options) (stats.Summary, error)
+	// CalculateInt performs a fixed-width integer op (see calculator.IntOp),
+	// wrapping on overflow or failing, as selected by wrap.
+	CalculateInt(op string, a, b calculator.Int, wrap bool) (calculator.Int, error)
+	// CalculateUnits adds, subtracts, multiplies or divides quantities such as
+	// "5 km" and "300 m", or converts a to the unit to. The result of add and
+	// subtract is in a's unit unless to is set.
+	CalculateUnits(op, a, b, to string) (units.Quantity, error)
+	// ConvertCurrency converts one amount, or sums several, into currency to at
+	// the rates effective on date (today if zero), rounding the result to to's
+	// minor units. The entry recorded in history keeps the rates used.
+	ConvertCurrency(amounts []currency.Amount, to string, date time.Time) (CurrencyResult, error)
+	// CalculateDate performs a date operation (see temporal.Eval), counting
+	// business days with the named calendar or, if empty, Monday to Friday.
+	CalculateDate(op string, a, b temporal.Value, calendar string) (temporal.Value, error)
+
+	// RunScript parses and runs a script (see package script) whose
+	// operations are performed like Calculate. With opts.Record each of them
+	// is recorded in history under a new run number, also when the script
+	// fails; the result then holds the trace up to the failure.
+	RunScript(ctx context.Context, src string, opts ScriptOptions) (ScriptResult, error)
+
+	// Operations returns the registry consulted by Calculate and
+	// CalculateUncertain. Operations registered on it become available at once.
+	Operations() *calculator.Registry
 
 	GetHistory(limit int) []HistoryEntry
 	ClearHistory()
+
+	// ForTenant returns a view of the service that records and reads history
+	// for the given tenant only. The view shares storage and limits with its parent.
+	ForTenant(tenant string) CalculatorService
+	// WithoutCache returns a view that always recomputes results instead of
+	// serving them from the result cache. History is still recorded.
+	WithoutCache() CalculatorService
+	// CacheStats reports result cache usage.
+	CacheStats() CacheStats
+	// RetentionStats reports the size of the history store and evictions per rule.
+	RetentionStats() RetentionStats
+	// Close stops background work such as the retention janitor.
+	Close()
 }
 
 func NewCalculatorService(opts ...Option) CalculatorService {
-	cfg := config{maxHistory: 1000}
+	cfg := config{
+		retention:       Retention{MaxCount: 1000},
+		janitorEvery:    time.Minute,
+		tenantRetention: map[string]Retention{},
+		units:           units.DefaultRegistry,
+		ops:             calculator.DefaultRegistry,
+	}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
-	return &calcSvc{
-		maxHistory: cfg.maxHistory,
+	st := &store{
+		retention: cfg.retention,
+		tenants:   cfg.tenantRetention,
+		evicted:   map[string]int64{},
+	}
+	if cfg.hasMaxAge() && cfg.janitorEvery > 0 {
+		st.startJanitor(cfg.janitorEvery)
+	}
+	svc := &calcSvc{
+		store:      st,
+		strictness: cfg.strictness,
+		ops:        cfg.ops,
+		units:      cfg.units,
+		rates:      cfg.rates,
+		calendars:  cfg.calendars,
+	}
+	if cfg.cacheSize > 0 {
+		svc.cache = newResultCache(cfg.cacheSize, cfg.cacheTTL)
 	}
+	return svc
 }
 
 type config struct {
-	maxHistory int
+	retention       Retention
+	tenantRetention map[string]Retention
+	janitorEvery    time.Duration
+	cacheSize       int
+	cacheTTL        time.Duration
+	strictness      Strictness
+	ops             *calculator.Registry
+	units           *units.Registry
+	rates           *currency.Source
+	calendars       map[string]*temporal.Calendar
+}
+
+func (c *config) hasMaxAge() bool {
+	if c.retention.MaxAge > 0 {
+		return true
+	}
+	for _, r := range c.tenantRetention {
+		if r.MaxAge > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 type Option func(*config)
@@ -46,69 +194,133 @@ type Option func(*config)
 func WithMaxHistory(n int) Option {
 	return func(c *config) {
 		if n > 0 {
-			c.maxHistory = n
+			c.retention.MaxCount = n
+		}
+	}
+}
+
+// WithOperations sets the operations served by Calculate and
+// CalculateUncertain (default calculator.DefaultRegistry).
+func WithOperations(r *calculator.Registry) Option {
+	return func(c *config) {
+		if r != nil {
+			c.ops = r
 		}
 	}
 }
 
+// calcSvc is a tenant-scoped view over a shared history store and result cache.
 type calcSvc struct {
-	mu         sync.Mutex
-	history    []HistoryEntry
-	nextID     int64
-	maxHistory int
+	*store
+	cache      *resultCache
+	strictness Strictness
+	ops        *calculator.Registry
+	units      *units.Registry
+	rates      *currency.Source
+	calendars  map[string]*temporal.Calendar
+	tenant     string
+	noCache    bool
 }
 
-func (s *calcSvc) record(op string, a, b, result float64, err error) {
+// record stores entry with a fresh ID, time and the view's tenant.
+func (s *calcSvc) record(entry HistoryEntry, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	entry := HistoryEntry{
-		ID:     s.nextID,
-		Time:   time.Now(),
-		Op:     op,
-		A:      a,
-		B:      b,
-		Result: result,
-	}
+	entry.ID = s.nextID
+	entry.Time = time.Now()
+	entry.Tenant = s.tenant
 	s.nextID++
 
+	// Copy so callers cannot alias the stored entry.
+	entry.Warnings = append([]string(nil), entry.Warnings...)
+	entry.Result = recorded(entry.Result)
 	if err != nil {
 		entry.Error = err.Error()
 	}
 
-	s.history = append(s.history, entry)
-	if len(s.history) > s.maxHistory {
-		drop := len(s.history) - s.maxHistory
-		s.history = s.history[drop:]
-	}
+	s.append(entry)
+	s.enforce(entry.Time)
 }
 
 func (s *calcSvc) Add(a, b float64) float64 {
-	res := calculator.Add(a, b)
-	s.record("add", a, b, res, nil)
-	return res
+	res, _ := s.compute(s.builtin("add"), a, b)
+	return res.Value
 }
 
 func (s *calcSvc) Subtract(a, b float64) float64 {
-	res := calculator.Subtract(a, b)
-	s.record("subtract", a, b, res, nil)
-	return res
+	res, _ := s.compute(s.builtin("subtract"), a, b)
+	return res.Value
 }
 
 func (s *calcSvc) Multiply(a, b float64) float64 {
-	res := calculator.Multiply(a, b)
-	s.record("multiply", a, b, res, nil)
-	return res
+	res, _ := s.compute(s.builtin("multiply"), a, b)
+	return res.Value
 }
 
 func (s *calcSvc) Divide(a, b float64) (float64, error) {
-	if b == 0 {
-		err := errors.New("division by zero is not allowed")
-		s.record("divide", a, b, 0, err)
-		return 0, err
+	res, err := s.compute(s.builtin("divide"), a, b)
+	return res.Value, err
+}
+
+// builtin returns an operation every registry made by calculator.NewRegistry
+// holds.
+func (s *calcSvc) builtin(name string) calculator.Operation {
+	op, _ := s.ops.Lookup(name)
+	return op
+}
+
+func (s *calcSvc) Operations() *calculator.Registry { return s.ops }
+
+func (s *calcSvc) Calculate(name string, a, b float64) (Result, error) {
+	op, err := s.lookup(name, a, b)
+	if err != nil {
+		return Result{}, err
+	}
+	if op.Arity() == 1 {
+		b = 0
+	}
+	return s.compute(op, a, b)
+}
+
+// lookup resolves a registered operation by name or alias and checks that
+// integer operands have no fractional part.
+func (s *calcSvc) lookup(name string, operands ...float64) (calculator.Operation, error) {
+	op, ok := s.ops.Lookup(name)
+	if !ok {
+		return op, &InputError{Field: "op", Msg: "use " + strings.Join(s.ops.Names(), "|")}
+	}
+	for i, t := range op.Operands {
+		if t == calculator.TypeInteger && operands[i] != math.Trunc(operands[i]) {
+			return op, &InputError{Field: string(rune('a' + i)), Msg: "operand must be an integer"}
+		}
+	}
+	return op, nil
+}
+
+// compute evaluates op and records the outcome in history either way.
+func (s *calcSvc) compute(op calculator.Operation, a, b float64) (Result, error) {
+	res, err := s.evaluate(op, a, b)
+	s.record(HistoryEntry{Op: op.Name, A: a, B: b, Result: res.Value, Warnings: res.Warnings}, err)
+	return res, err
+}
+
+// evaluate computes op, consulting the result cache when one is configured,
+// and checks the value for precision problems.
+func (s *calcSvc) evaluate(op calculator.Operation, a, b float64) (Result, error) {
+	var (
+		raw float64
+		err error
+	)
+	if s.cache != nil {
+		raw, err = s.cache.get(op, a, b, !s.noCache)
+	} else {
+		raw, err = op.Fn(a, b)
+	}
+	res := Result{Value: raw}
+	if err == nil {
+		res, err = checkResult(op.Name, a, b, raw, s.strictness)
 	}
-	res, err := calculator.Divide(a, b)
-	s.record("divide", a, b, res, err)
 	return res, err
 }
 
@@ -121,12 +333,11 @@ func (s *calcSvc) GetHistory(limit int) []HistoryEntry {
 	}
 
 	out := make([]HistoryEntry, 0, limit)
-	for i := 0; i < limit; i++ {
-		index := len(s.history) - 1 - i
-		if index < 0 {
-			break
+	for i := len(s.history) - 1; i >= 0 && len(out) < limit; i-- {
+		if s.history[i].Tenant != s.tenant {
+			continue
 		}
-		out = append(out, s.history[index])
+		out = append(out, s.history[i].HistoryEntry)
 	}
 	return out
 }
@@ -134,5 +345,21 @@ func (s *calcSvc) GetHistory(limit int) []HistoryEntry {
 func (s *calcSvc) ClearHistory() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.history = nil
+	s.removeIf(func(e *storedEntry) bool { return e.Tenant == s.tenant })
+}
+
+func (s *calcSvc) ForTenant(tenant string) CalculatorService {
+	v := *s
+	v.tenant = tenant
+	return &v
+}
+
+func (s *calcSvc) WithoutCache() CalculatorService {
+	v := *s
+	v.noCache = true
+	return &v
+}
+
+func (s *calcSvc) CacheStats() CacheStats {
+	return s.cache.stats()
 }
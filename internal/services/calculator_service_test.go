@@ -1,7 +1,9 @@
 package service
 
 import (
+	"erikkruuse/calculator/calculator"
 	"errors"
+	"math"
 	"sync"
 	"testing"
 	"time"
@@ -187,3 +189,70 @@ func TestService_Concurrency_IsThreadSafe(t *testing.T) {
 		t.Fatalf("latest history entry looks invalid: %+v", h[0])
 	}
 }
+
+func TestDivideByZero_ReturnsTypedError(t *testing.T) {
+	svc := NewCalculatorService()
+	_, err := svc.Divide(1, 0)
+	if !errors.Is(err, calculator.ErrDivisionByZero) {
+		t.Fatalf("Divide(1,0) error = %v; want calculator.ErrDivisionByZero", err)
+	}
+}
+
+/* ------------------ operation registry ------------------ */
+
+func TestWithOperations_CustomOperations(t *testing.T) {
+	reg := calculator.NewRegistry()
+	for _, op := range []calculator.Operation{
+		{
+			Name: "gcd", Operands: []calculator.OperandType{calculator.TypeInteger, calculator.TypeInteger}, Commutative: true,
+			Fn: func(a, b float64) (float64, error) {
+				for b != 0 {
+					a, b = b, math.Mod(a, b)
+				}
+				return math.Abs(a), nil
+			},
+		},
+		{
+			Name: "sqrt", Aliases: []string{"√"}, Operands: []calculator.OperandType{calculator.TypeNumber},
+			Fn: func(a, _ float64) (float64, error) { return math.Sqrt(a), nil },
+		},
+	} {
+		if err := reg.Register(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := NewCalculatorService(WithOperations(reg), WithResultCache(10, 0))
+	if svc.Operations() != reg {
+		t.Fatal("Operations() is not the configured registry")
+	}
+
+	if res, err := svc.Calculate("gcd", 12, 18); err != nil || res.Value != 6 {
+		t.Fatalf("gcd = %+v, %v", res, err)
+	}
+	if res, err := svc.Calculate("√", 9, 7); err != nil || res.Value != 3 {
+		t.Fatalf("sqrt = %+v, %v", res, err)
+	}
+	var ie *InputError
+	if _, err := svc.Calculate("gcd", 12, 1.5); !errors.As(err, &ie) || ie.Field != "b" {
+		t.Fatalf("fractional operand error = %v", err)
+	}
+	if _, err := svc.Calculate("pow", 2, 3); !errors.As(err, &ie) || ie.Msg != "use add|subtract|multiply|divide|gcd|sqrt" {
+		t.Fatalf("unknown op error = %v", err)
+	}
+	if _, err := svc.CalculateUncertain("sqrt", calculator.Uncertain{Value: 9, Uncertainty: 1}, calculator.Uncertain{}); !errors.As(err, &ie) || ie.Field != "op" {
+		t.Fatalf("uncertain sqrt error = %v", err)
+	}
+	var ce *calculator.Error
+	if _, err := svc.Calculate("sqrt", -1, 0); !errors.As(err, &ce) || ce.Kind != calculator.KindDomain {
+		t.Fatalf("sqrt(-1) error = %v", err)
+	}
+
+	// The unary entry records b as 0, whatever the caller passed.
+	h := svc.GetHistory(0)
+	if len(h) != 3 || h[1].Op != "sqrt" || h[1].B != 0 || h[2].Op != "gcd" {
+		t.Fatalf("history = %+v", h)
+	}
+	if _, ok := NewCalculatorService().Operations().Lookup("gcd"); ok {
+		t.Fatal("custom operation leaked into the default registry")
+	}
+}
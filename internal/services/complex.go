@@ -0,0 +1,186 @@
+package service
+
+import (
+	"math"
+	"slices"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// ModeComplex marks history entries produced by CalculateComplex.
+const ModeComplex = "complex"
+
+// ComplexResult is the outcome of a complex operation. Exactly one of Value,
+// Real and Polar is set, depending on the operation, or none when the result
+// overflowed in Lenient mode.
+type ComplexResult struct {
+	Value    *calculator.Complex `json:"value,omitempty"` // add, subtract, multiply, divide, conjugate, rect, sqrt, exp, log
+	Real     *float64            `json:"real,omitempty"`  // modulus, argument
+	Polar    *calculator.Polar   `json:"polar,omitempty"` // polar
+	Warnings []string            `json:"warnings,omitempty"`
+}
+
+type complexOp struct {
+	arity int
+	fn    func(z []calculator.Complex) (ComplexResult, error)
+}
+
+func complexValue(z calculator.Complex) (ComplexResult, error) { return ComplexResult{Value: &z}, nil }
+
+func realValue(f float64) (ComplexResult, error) { return ComplexResult{Real: &f}, nil }
+
+// complexOps maps the op names accepted by CalculateComplex to their arity and
+// implementation. rect takes the modulus and angle as the real parts of its
+// two operands.
+var complexOps = map[string]complexOp{
+	"add":      {2, func(z []calculator.Complex) (ComplexResult, error) { return complexValue(z[0].Add(z[1])) }},
+	"subtract": {2, func(z []calculator.Complex) (ComplexResult, error) { return complexValue(z[0].Sub(z[1])) }},
+	"multiply": {2, func(z []calculator.Complex) (ComplexResult, error) { return complexValue(z[0].Mul(z[1])) }},
+	"divide": {2, func(z []calculator.Complex) (ComplexResult, error) {
+		q, err := z[0].Div(z[1])
+		if err != nil {
+			return ComplexResult{}, err
+		}
+		return complexValue(q)
+	}},
+	"conjugate": {1, func(z []calculator.Complex) (ComplexResult, error) { return complexValue(z[0].Conj()) }},
+	"modulus":   {1, func(z []calculator.Complex) (ComplexResult, error) { return realValue(z[0].Abs()) }},
+	"argument":  {1, func(z []calculator.Complex) (ComplexResult, error) { return realValue(z[0].Arg()) }},
+	"polar": {1, func(z []calculator.Complex) (ComplexResult, error) {
+		p := z[0].Polar()
+		return ComplexResult{Polar: &p}, nil
+	}},
+	"rect": {2, func(z []calculator.Complex) (ComplexResult, error) {
+		return complexValue(calculator.Polar{R: z[0].Re, Theta: z[1].Re}.Rect())
+	}},
+	"sqrt": {1, func(z []calculator.Complex) (ComplexResult, error) { return complexValue(z[0].Sqrt()) }},
+	"exp":  {1, func(z []calculator.Complex) (ComplexResult, error) { return complexValue(z[0].Exp()) }},
+	"log": {1, func(z []calculator.Complex) (ComplexResult, error) {
+		l, err := z[0].Log()
+		if err != nil {
+			return ComplexResult{}, err
+		}
+		return complexValue(l)
+	}},
+}
+
+// ComplexArity returns the number of operands op takes, or 0 for unknown ops.
+func ComplexArity(op string) int { return complexOps[op].arity }
+
+// evalComplex runs op and applies the same overflow handling as real results:
+// infinite components fail in Strict mode and otherwise leave the result
+// without a value and with a warning. With finite operands a NaN component
+// can only come from an intermediate overflow (Inf-Inf, 0*Inf), which loses
+// even the direction of the result, so it always fails.
+func evalComplex(op string, z []calculator.Complex, mode Strictness) (ComplexResult, error) {
+	res, err := complexOps[op].fn(z)
+	if err != nil {
+		return res, err
+	}
+	overflow := &calculator.Error{Kind: calculator.KindOverflow, Op: op, Msg: "result overflows the float64 range"}
+	for _, f := range res.components() {
+		if math.IsNaN(*f) {
+			return ComplexResult{}, overflow
+		}
+		if !math.IsInf(*f, 0) {
+			continue
+		}
+		if mode == Strict {
+			return ComplexResult{}, overflow
+		}
+		return ComplexResult{Warnings: []string{WarnOverflow}}, nil
+	}
+	return res, nil
+}
+
+// components returns pointers to every float in the result.
+func (r *ComplexResult) components() []*float64 {
+	switch {
+	case r.Value != nil:
+		return []*float64{&r.Value.Re, &r.Value.Im}
+	case r.Real != nil:
+		return []*float64{r.Real}
+	case r.Polar != nil:
+		return []*float64{&r.Polar.R, &r.Polar.Theta}
+	}
+	return nil
+}
+
+func (s *calcSvc) CalculateComplex(op string, operands ...calculator.Complex) (ComplexResult, error) {
+	cop, ok := complexOps[op]
+	if !ok {
+		return ComplexResult{}, &InputError{Field: "op", Msg: "unknown complex operation"}
+	}
+	if len(operands) != cop.arity {
+		return ComplexResult{}, &InputError{Field: "op", Msg: "wrong number of operands for this operation"}
+	}
+	for i, z := range operands {
+		if !isFinite(z.Re) || !isFinite(z.Im) {
+			return ComplexResult{}, &InputError{Field: string(rune('a' + i)), Msg: "inputs must be finite numbers"}
+		}
+		if op == "rect" && z.Im != 0 {
+			return ComplexResult{}, &InputError{Field: string(rune('a' + i)), Msg: "rect takes a real modulus and angle"}
+		}
+	}
+
+	res, err := evalComplex(op, operands, s.strictness)
+	entry := HistoryEntry{
+		Op:       op,
+		Mode:     ModeComplex,
+		Operands: append([]calculator.Complex(nil), operands...),
+		Complex:  res.Value,
+		Polar:    res.Polar,
+		Warnings: res.Warnings,
+	}
+	if res.Real != nil {
+		entry.Result = *res.Real
+	}
+	s.record(entry, err)
+	return res, err
+}
+
+// replayComplex re-executes a complex history entry, reporting a mismatch
+// reason or "" when it matches.
+func replayComplex(e HistoryEntry, tol Tolerance) (ReplayMismatch, bool) {
+	m := ReplayMismatch{Entry: e}
+	cop, ok := complexOps[e.Op]
+	if !ok || len(e.Operands) != cop.arity {
+		return m, false
+	}
+	mode := Lenient
+	if e.Error != "" {
+		mode = Strict
+	}
+	res, err := evalComplex(e.Op, e.Operands, mode)
+	if err != nil {
+		m.Error = err.Error()
+	}
+
+	switch {
+	case err != nil && e.Error == "":
+		m.Reason = "recorded success now fails"
+	case err == nil && e.Error != "":
+		m.Reason = "recorded error now succeeds"
+	case err != nil:
+	case res.Value == nil && res.Polar == nil && res.Real == nil:
+		if e.Complex != nil || e.Polar != nil || e.Result != 0 || !slices.Contains(e.Warnings, WarnOverflow) {
+			m.Reason = "result differs"
+		}
+	case res.Value != nil:
+		m.Complex = res.Value
+		if e.Complex == nil || !tol.equal(res.Value.Re, e.Complex.Re) || !tol.equal(res.Value.Im, e.Complex.Im) {
+			m.Reason = "result differs"
+		}
+	case res.Polar != nil:
+		m.Polar = res.Polar
+		if e.Polar == nil || !tol.equal(res.Polar.R, e.Polar.R) || !tol.equal(res.Polar.Theta, e.Polar.Theta) {
+			m.Reason = "result differs"
+		}
+	case res.Real != nil:
+		m.Result = *res.Real
+		if !tol.equal(*res.Real, e.Result) {
+			m.Reason = "result differs"
+		}
+	}
+	return m, true
+}
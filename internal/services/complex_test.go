@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func TestCalculateComplex_ResultsAndHistory(t *testing.T) {
+	svc := NewCalculatorService()
+	z := calculator.Complex{Re: 3, Im: 4}
+
+	res, err := svc.CalculateComplex("multiply", z, calculator.Complex{Re: 1, Im: -2})
+	if err != nil || res.Value == nil || *res.Value != (calculator.Complex{Re: 11, Im: -2}) {
+		t.Fatalf("multiply = %+v, %v", res, err)
+	}
+	res, err = svc.CalculateComplex("modulus", z)
+	if err != nil || res.Real == nil || *res.Real != 5 {
+		t.Fatalf("modulus = %+v, %v", res, err)
+	}
+	res, err = svc.CalculateComplex("polar", z)
+	if err != nil || res.Polar == nil || res.Polar.R != 5 {
+		t.Fatalf("polar = %+v, %v", res, err)
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 3 {
+		t.Fatalf("history len = %d; want 3", len(h))
+	}
+	if h[0].Mode != ModeComplex || h[0].Polar == nil || len(h[0].Operands) != 1 {
+		t.Fatalf("polar entry = %+v", h[0])
+	}
+	if h[1].Result != 5 || h[2].Complex == nil || h[2].Complex.Im != -2 {
+		t.Fatalf("entries = %+v", h)
+	}
+	if rep := Replay(h, Tolerance{}); !rep.OK() || rep.Matched != 3 {
+		t.Fatalf("replay = %+v", rep)
+	}
+
+	h[2].Complex = &calculator.Complex{Re: 11, Im: 2}
+	if rep := Replay(h, Tolerance{}); len(rep.Mismatches) != 1 || rep.Mismatches[0].Complex == nil {
+		t.Fatalf("tampered replay = %+v", rep)
+	}
+}
+
+func TestCalculateComplex_Errors(t *testing.T) {
+	svc := NewCalculatorService(WithStrictness(Strict))
+	var ie *InputError
+
+	if _, err := svc.CalculateComplex("pow", calculator.Complex{}); !errors.As(err, &ie) || ie.Field != "op" {
+		t.Fatalf("unknown op error = %v", err)
+	}
+	if _, err := svc.CalculateComplex("add", calculator.Complex{}); !errors.As(err, &ie) {
+		t.Fatalf("arity error = %v", err)
+	}
+	if _, err := svc.CalculateComplex("add", calculator.Complex{}, calculator.Complex{Im: math.Inf(1)}); !errors.As(err, &ie) || ie.Field != "b" {
+		t.Fatalf("non-finite error = %v", err)
+	}
+	if _, err := svc.CalculateComplex("rect", calculator.Complex{Re: 1}, calculator.Complex{Im: 1}); !errors.As(err, &ie) {
+		t.Fatalf("rect with complex angle error = %v", err)
+	}
+	if _, err := svc.CalculateComplex("log", calculator.Complex{}); err != calculator.ErrLogOfZero {
+		t.Fatalf("log(0) error = %v", err)
+	}
+
+	var ce *calculator.Error
+	_, err := svc.CalculateComplex("exp", calculator.Complex{Re: 1000})
+	if !errors.As(err, &ce) || ce.Kind != calculator.KindOverflow {
+		t.Fatalf("strict exp overflow error = %v", err)
+	}
+	lenient := NewCalculatorService()
+	res, err := lenient.CalculateComplex("exp", calculator.Complex{Re: 1000})
+	if err != nil || res.Value != nil || len(res.Warnings) != 1 {
+		t.Fatalf("lenient exp overflow = %+v, %v", res, err)
+	}
+	if rep := Replay(lenient.GetHistory(0), Tolerance{}); !rep.OK() || rep.Matched != 1 {
+		t.Fatalf("lenient overflow replay = %+v", rep)
+	}
+
+	if h := svc.GetHistory(0); len(h) != 2 || h[0].Error == "" || h[1].Error == "" {
+		t.Fatalf("only evaluated operations are recorded, got %+v", h)
+	}
+}
+
+func TestCalculateComplex_IntermediateOverflowFails(t *testing.T) {
+	big := calculator.Complex{Re: 1e308, Im: 1e308}
+	_, err := NewCalculatorService().CalculateComplex("multiply", big, big)
+	var ce *calculator.Error
+	if !errors.As(err, &ce) || ce.Kind != calculator.KindOverflow {
+		t.Fatalf("error = %v; want overflow even in lenient mode", err)
+	}
+}
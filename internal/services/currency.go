@@ -0,0 +1,116 @@
+package service
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	"erikkruuse/calculator/currency"
+	"erikkruuse/calculator/decimal"
+)
+
+// ModeCurrency marks history entries produced by ConvertCurrency.
+const ModeCurrency = "currency"
+
+// WithRates sets the exchange-rate source used by ConvertCurrency. Without it
+// currency conversion fails with currency.ErrNoRates.
+func WithRates(src *currency.Source) Option {
+	return func(c *config) {
+		c.rates = src
+	}
+}
+
+// RateSnapshot records the exchange rates a currency entry used, so the entry
+// can be recomputed after the rate file has changed.
+type RateSnapshot struct {
+	ID   string `json:"id"`   // currency.Table.ID of the snapshot
+	Date string `json:"date"` // day the rates were effective for
+	// Rates maps each source currency to its exact rate into the target
+	// currency, as a fraction such as "20/217".
+	Rates map[string]string `json:"rates"`
+}
+
+// CurrencyResult is a converted or summed amount, rounded to the minor units
+// of its currency, together with the rates that produced it.
+type CurrencyResult struct {
+	Amount   currency.Amount
+	Text     string // Amount with exactly the currency's minor units, e.g. "92.17 EUR"
+	Snapshot string
+	Date     time.Time
+	Quotes   []currency.Quote
+}
+
+func (s *calcSvc) ConvertCurrency(amounts []currency.Amount, to string, date time.Time) (CurrencyResult, error) {
+	tbl := s.rates.Current()
+	if tbl == nil {
+		return CurrencyResult{}, currency.ErrNoRates
+	}
+	if len(amounts) == 0 {
+		return CurrencyResult{}, &InputError{Field: "amounts", Msg: "at least one amount is required"}
+	}
+	for _, a := range amounts {
+		if !tbl.Known(a.Code) {
+			return CurrencyResult{}, &InputError{Field: "amounts", Msg: "unknown currency"}
+		}
+	}
+	if !tbl.Known(to) {
+		return CurrencyResult{}, &InputError{Field: "to", Msg: "unknown currency"}
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+	date = date.UTC().Truncate(24 * time.Hour)
+
+	sum, quotes, err := tbl.Sum(amounts, to, date)
+	res := CurrencyResult{Amount: sum, Snapshot: tbl.ID, Date: date, Quotes: quotes}
+
+	entry := HistoryEntry{Op: "convert", Mode: ModeCurrency}
+	if len(amounts) > 1 {
+		entry.Op = "sum"
+	}
+	for _, a := range amounts {
+		entry.Quantities = append(entry.Quantities, a.String())
+	}
+	if err == nil {
+		entry.Rates = &RateSnapshot{ID: tbl.ID, Date: date.Format(time.DateOnly), Rates: map[string]string{}}
+		for _, q := range quotes {
+			entry.Rates.Rates[q.From] = q.Rate.RatString()
+		}
+		res.Text = decimal.Format(sum.Value, tbl.MinorUnits(to)) + " " + to
+		entry.Quantity = res.Text
+		entry.Result, _ = sum.Value.Float64()
+	}
+	s.record(entry, err)
+	return res, err
+}
+
+// replayCurrency recomputes a currency entry from the rates it recorded, so
+// the outcome does not depend on the rate file currently loaded.
+func replayCurrency(e HistoryEntry) (ReplayMismatch, bool) {
+	m := ReplayMismatch{Entry: e}
+	num, to, ok := strings.Cut(e.Quantity, " ")
+	if !ok || e.Rates == nil {
+		return m, false
+	}
+	places := 0
+	if _, frac, ok := strings.Cut(num, "."); ok {
+		places = len(frac)
+	}
+
+	total := new(big.Rat)
+	for _, s := range e.Quantities {
+		a, err := currency.ParseAmount(s)
+		if err != nil {
+			return m, false
+		}
+		rate, ok := new(big.Rat).SetString(e.Rates.Rates[a.Code])
+		if !ok {
+			return m, false
+		}
+		total.Add(total, a.Value.Mul(a.Value, rate))
+	}
+	if got := decimal.Format(total, places) + " " + to; got != e.Quantity {
+		m.Quantity, m.Reason = got, "result differs"
+	}
+	return m, true
+}
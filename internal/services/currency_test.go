@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/currency"
+)
+
+func rateSource(t *testing.T, table string) (*currency.Source, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := currency.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src, path
+}
+
+const serviceRates = `{"base": "EUR", "rates": [
+	{"currency": "USD", "rate": "1.0850", "effective": "2026-10-01"},
+	{"currency": "GBP", "rate": "0.8600", "effective": "2026-10-01"}
+]}`
+
+func amounts(t *testing.T, ss ...string) []currency.Amount {
+	t.Helper()
+	var out []currency.Amount
+	for _, s := range ss {
+		a, err := currency.ParseAmount(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func TestConvertCurrency(t *testing.T) {
+	src, _ := rateSource(t, serviceRates)
+	svc := NewCalculatorService(WithRates(src))
+	date := time.Date(2026, 10, 10, 15, 0, 0, 0, time.UTC)
+
+	res, err := svc.ConvertCurrency(amounts(t, "100 USD"), "EUR", date)
+	if err != nil || res.Text != "92.17 EUR" || res.Snapshot != src.Current().ID || len(res.Quotes) != 1 {
+		t.Fatalf("100 USD in EUR = %+v, %v", res, err)
+	}
+	res, err = svc.ConvertCurrency(amounts(t, "10 USD", "5 GBP", "2.5 EUR"), "USD", date)
+	if err != nil || res.Text != "19.02 USD" {
+		t.Fatalf("sum = %+v, %v", res, err)
+	}
+
+	_, err = svc.ConvertCurrency(amounts(t, "1 USD"), "EUR", time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC))
+	var ce *calculator.Error
+	if !errors.As(err, &ce) || ce.Field != "date" {
+		t.Fatalf("conversion before the first rate error = %v", err)
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 3 || h[0].Error == "" || h[0].Rates != nil {
+		t.Fatalf("history = %+v", h)
+	}
+	sum := h[1]
+	if sum.Op != "sum" || sum.Mode != ModeCurrency || sum.Quantity != "19.02 USD" || len(sum.Quantities) != 3 {
+		t.Fatalf("sum entry = %+v", sum)
+	}
+	if sum.Rates == nil || sum.Rates.ID != src.Current().ID || sum.Rates.Date != "2026-10-10" || sum.Rates.Rates["GBP"] != "217/172" {
+		t.Fatalf("sum rates = %+v", sum.Rates)
+	}
+}
+
+func TestConvertCurrency_InputErrors(t *testing.T) {
+	if _, err := NewCalculatorService().ConvertCurrency(amounts(t, "1 USD"), "EUR", time.Time{}); !errors.Is(err, currency.ErrNoRates) {
+		t.Fatalf("without rates error = %v", err)
+	}
+
+	src, _ := rateSource(t, serviceRates)
+	svc := NewCalculatorService(WithRates(src))
+	for _, tc := range []struct {
+		amounts []currency.Amount
+		to      string
+		field   string
+	}{
+		{nil, "EUR", "amounts"},
+		{amounts(t, "1 CHF"), "EUR", "amounts"},
+		{amounts(t, "1 USD"), "CHF", "to"},
+	} {
+		_, err := svc.ConvertCurrency(tc.amounts, tc.to, time.Time{})
+		var ie *InputError
+		if !errors.As(err, &ie) || ie.Field != tc.field {
+			t.Fatalf("ConvertCurrency(%v, %s) error = %v; want field %s", tc.amounts, tc.to, err, tc.field)
+		}
+	}
+}
+
+func TestReplayCurrency_UsesRecordedRates(t *testing.T) {
+	src, path := rateSource(t, serviceRates)
+	svc := NewCalculatorService(WithRates(src))
+	date := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	svc.ConvertCurrency(amounts(t, "100 USD"), "EUR", date)
+	svc.ConvertCurrency(amounts(t, "1 USD"), "EUR", date.AddDate(-1, 0, 0))
+
+	// New rates must not change how recorded entries replay.
+	later := time.Now().Add(time.Minute)
+	os.WriteFile(path, []byte(`{"base": "EUR", "rates": [{"currency": "USD", "rate": "2", "effective": "2020-01-01"}]}`), 0o644)
+	os.Chtimes(path, later, later)
+	if changed, err := src.Reload(); !changed || err != nil {
+		t.Fatalf("reload = %v, %v", changed, err)
+	}
+
+	h := svc.GetHistory(0)
+	rep := Replay(h, Tolerance{})
+	if rep.Matched != 1 || len(rep.Skipped) != 1 || !rep.OK() {
+		t.Fatalf("replay = %+v", rep)
+	}
+
+	tampered := h[1]
+	tampered.Quantity = "92.18 EUR"
+	rep = Replay([]HistoryEntry{tampered}, Tolerance{})
+	if len(rep.Mismatches) != 1 || rep.Mismatches[0].Quantity != "92.17 EUR" {
+		t.Fatalf("tampered replay = %+v", rep)
+	}
+}
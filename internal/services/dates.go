@@ -0,0 +1,40 @@
+package service
+
+import "erikkruuse/calculator/temporal"
+
+// ModeDates marks history entries produced by CalculateDate.
+const ModeDates = "dates"
+
+// WithCalendars makes holiday calendars available to CalculateDate by name.
+func WithCalendars(cals map[string]*temporal.Calendar) Option {
+	return func(c *config) {
+		c.calendars = cals
+	}
+}
+
+func (s *calcSvc) CalculateDate(op string, a, b temporal.Value, calendar string) (temporal.Value, error) {
+	if !temporal.IsOp(op) {
+		return temporal.Value{}, &InputError{Field: "op", Msg: "use add|subtract|diff|business_days|add_business_days"}
+	}
+	cal := temporal.Weekdays
+	if calendar != "" && calendar != cal.Name {
+		var ok bool
+		if cal, ok = s.calendars[calendar]; !ok {
+			return temporal.Value{}, &InputError{Field: "calendar", Msg: "unknown calendar"}
+		}
+	}
+
+	res, err := temporal.Eval(op, a, b, cal)
+	entry := HistoryEntry{Op: op, Mode: ModeDates, DateOperands: []temporal.Value{a, b}}
+	if op == "business_days" || op == "add_business_days" {
+		entry.Calendar = cal.Name
+	}
+	if err == nil {
+		entry.DateResult = &res
+		if res.Type == temporal.TypeInteger {
+			entry.Result = float64(res.Int)
+		}
+	}
+	s.record(entry, err)
+	return res, err
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"erikkruuse/calculator/temporal"
+)
+
+func TestCalculateDate_RecordsTypedOperands(t *testing.T) {
+	cal, err := temporal.ParseCalendar("us", strings.NewReader(`{"holidays": [{"date": "07-03", "name": "Independence Day (observed)"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewCalculatorService(WithCalendars(map[string]*temporal.Calendar{"us": cal}))
+
+	start, _ := temporal.Parse("2026-03-01", time.UTC)
+	days, _ := temporal.Parse("45 days", time.UTC)
+	res, err := svc.CalculateDate("add", start, days, "")
+	if err != nil || res.String() != "2026-04-15T00:00:00Z" {
+		t.Fatalf("2026-03-01 + 45 days = %s, %v", res, err)
+	}
+
+	from, _ := temporal.Parse("2026-06-30", time.UTC)
+	to, _ := temporal.Parse("2026-07-07", time.UTC)
+	res, err = svc.CalculateDate("business_days", from, to, "us")
+	if err != nil || res.Int != 4 {
+		t.Fatalf("business days = %s, %v", res, err)
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 2 || h[0].Calendar != "us" || h[0].Result != 4 || h[1].Calendar != "" {
+		t.Fatalf("history = %+v", h)
+	}
+	b, _ := json.Marshal(h[1])
+	if !strings.Contains(string(b), `"date_operands":[{"type":"datetime","value":"2026-03-01T00:00:00Z"},{"type":"duration","value":"P45D"}]`) ||
+		!strings.Contains(string(b), `"date_result":{"type":"datetime","value":"2026-04-15T00:00:00Z"}`) {
+		t.Fatalf("entry JSON = %s", b)
+	}
+	if rep := Replay(h, Tolerance{}); len(rep.Skipped) != 2 {
+		t.Fatalf("replay = %+v", rep)
+	}
+}
+
+func TestCalculateDate_InputErrors(t *testing.T) {
+	svc := NewCalculatorService()
+	d, _ := temporal.Parse("2026-03-01", time.UTC)
+	for _, tc := range []struct{ op, cal, field string }{
+		{"pow", "", "op"},
+		{"business_days", "nowhere", "calendar"},
+	} {
+		_, err := svc.CalculateDate(tc.op, d, d, tc.cal)
+		var ie *InputError
+		if !errors.As(err, &ie) || ie.Field != tc.field {
+			t.Fatalf("CalculateDate(%s, %s) error = %v", tc.op, tc.cal, err)
+		}
+	}
+	if _, err := svc.CalculateDate("business_days", d, d, "weekdays"); err != nil {
+		t.Fatalf("the default calendar must be selectable by name: %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+package service
+
+// InputError reports an argument the service cannot work with.
+type InputError struct {
+	Field string // offending field, e.g. "tolerance.abs"
+	Msg   string
+}
+
+func (e *InputError) Error() string { return e.Msg }
@@ -0,0 +1,52 @@
+package service
+
+import "erikkruuse/calculator/calculator"
+
+func (s *calcSvc) CalculateInt(op string, a, b calculator.Int, wrap bool) (calculator.Int, error) {
+	arity := calculator.IntArity(op)
+	if arity == 0 {
+		return calculator.Int{}, &InputError{Field: "op", Msg: "unknown integer operation"}
+	}
+	res, err := calculator.IntOp(op, a, b, wrap)
+
+	entry := HistoryEntry{Op: op, Mode: a.Type.String(), IntA: a.String()}
+	if arity == 2 {
+		entry.IntB = b.String()
+	}
+	if err == nil {
+		entry.IntResult = res.String()
+	}
+	s.record(entry, err)
+	return res, err
+}
+
+// replayInt re-executes an integer-mode entry. Entries that recorded an error
+// are replayed without wrapping so overflow errors reproduce.
+func replayInt(t calculator.IntType, e HistoryEntry) (ReplayMismatch, bool) {
+	m := ReplayMismatch{Entry: e}
+	arity := calculator.IntArity(e.Op)
+	if arity == 0 {
+		return m, false
+	}
+	a, err := t.Parse(e.IntA, false)
+	if err != nil {
+		return m, false
+	}
+	b := calculator.Int{Type: t}
+	if arity == 2 {
+		if b, err = t.Parse(e.IntB, false); err != nil {
+			return m, false
+		}
+	}
+
+	res, err := calculator.IntOp(e.Op, a, b, e.Error == "")
+	switch {
+	case err != nil && e.Error == "":
+		m.Error, m.Reason = err.Error(), "recorded success now fails"
+	case err == nil && e.Error != "":
+		m.IntResult, m.Reason = res.String(), "recorded error now succeeds"
+	case err == nil && res.String() != e.IntResult:
+		m.IntResult, m.Reason = res.String(), "result differs"
+	}
+	return m, true
+}
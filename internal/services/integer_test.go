@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func TestCalculateInt_RecordsAndReplays(t *testing.T) {
+	u64, _ := calculator.ParseIntType("uint64")
+	max, _ := u64.Parse("0xFFFFFFFFFFFFFFFF", false)
+	one, _ := u64.Parse("1", false)
+
+	svc := NewCalculatorService()
+	if res, err := svc.CalculateInt("add", max, one, true); err != nil || res.String() != "0" {
+		t.Fatalf("wrapping add = %v, %v", res, err)
+	}
+	if _, err := svc.CalculateInt("add", max, one, false); err == nil {
+		t.Fatal("trapping add must overflow")
+	}
+	if _, err := svc.CalculateInt("not", one, calculator.Int{}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CalculateInt("pow", one, one, true); err == nil {
+		t.Fatal("unknown op must fail")
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 3 {
+		t.Fatalf("history len = %d; want 3", len(h))
+	}
+	if h[2].Mode != "uint64" || h[2].IntA != "18446744073709551615" || h[2].IntResult != "0" {
+		t.Fatalf("wrapping entry = %+v", h[2])
+	}
+	if h[1].Error == "" || h[0].IntB != "" || h[0].IntResult != "18446744073709551614" {
+		t.Fatalf("entries = %+v", h[:2])
+	}
+	if rep := Replay(h, Tolerance{}); !rep.OK() || rep.Matched != 3 {
+		t.Fatalf("replay = %+v", rep)
+	}
+
+	h[0].IntResult = "1"
+	if rep := Replay(h, Tolerance{}); len(rep.Mismatches) != 1 || rep.Mismatches[0].IntResult != "18446744073709551614" {
+		t.Fatalf("tampered replay = %+v", rep)
+	}
+}
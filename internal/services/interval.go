@@ -0,0 +1,134 @@
+package service
+
+import "erikkruuse/calculator/calculator"
+
+// ModeInterval marks history entries produced by CalculateInterval.
+const ModeInterval = "interval"
+
+// IntervalResult is the outcome of an interval operation. Value encloses the
+// exact result; when extended division splits it into two unbounded pieces,
+// Value is their hull and Parts lists them, lower one first.
+type IntervalResult struct {
+	Value    calculator.Interval   `json:"value"`
+	Parts    []calculator.Interval `json:"parts,omitempty"`
+	Warnings []string              `json:"warnings,omitempty"`
+}
+
+// intervalOps maps the op names accepted by CalculateInterval to their
+// implementation. extended selects DivExtended for divisors containing zero.
+var intervalOps = map[string]func(a, b calculator.Interval, extended bool) ([]calculator.Interval, error){
+	"add": func(a, b calculator.Interval, _ bool) ([]calculator.Interval, error) {
+		return []calculator.Interval{a.Add(b)}, nil
+	},
+	"subtract": func(a, b calculator.Interval, _ bool) ([]calculator.Interval, error) {
+		return []calculator.Interval{a.Sub(b)}, nil
+	},
+	"multiply": func(a, b calculator.Interval, _ bool) ([]calculator.Interval, error) {
+		return []calculator.Interval{a.Mul(b)}, nil
+	},
+	"divide": func(a, b calculator.Interval, extended bool) ([]calculator.Interval, error) {
+		if extended {
+			return a.DivExtended(b)
+		}
+		q, err := a.Div(b)
+		if err != nil {
+			return nil, err
+		}
+		return []calculator.Interval{q}, nil
+	},
+}
+
+// evalInterval runs op. An unbounded result from bounded operands overflowed
+// float64: it fails in Strict mode and is kept with a warning otherwise, as
+// clamping the bound would break the enclosure. Unbounded quotients of
+// divisors containing zero are the point of extended division and pass.
+func evalInterval(op string, a, b calculator.Interval, extended bool, mode Strictness) (IntervalResult, error) {
+	parts, err := intervalOps[op](a, b, extended)
+	if err != nil {
+		return IntervalResult{}, err
+	}
+	res := IntervalResult{Value: calculator.Hull(parts)}
+	if len(parts) > 1 {
+		res.Parts = parts
+	}
+	if res.Value.Bounded() || op == "divide" && b.Contains(0) {
+		return res, nil
+	}
+	if mode == Strict {
+		return IntervalResult{}, &calculator.Error{Kind: calculator.KindOverflow, Op: op, Msg: "result overflows the float64 range"}
+	}
+	res.Warnings = []string{WarnOverflow}
+	return res, nil
+}
+
+func (s *calcSvc) CalculateInterval(op string, a, b calculator.Interval, extended bool) (IntervalResult, error) {
+	if _, ok := intervalOps[op]; !ok {
+		return IntervalResult{}, &InputError{Field: "op", Msg: "unknown interval operation"}
+	}
+	for i, x := range []calculator.Interval{a, b} {
+		if !x.Valid() || !x.Bounded() {
+			return IntervalResult{}, &InputError{Field: string(rune('a' + i)), Msg: "intervals must be finite numbers with lo <= hi"}
+		}
+	}
+
+	res, err := evalInterval(op, a, b, extended, s.strictness)
+	entry := HistoryEntry{
+		Op:        op,
+		Mode:      ModeInterval,
+		Intervals: []calculator.Interval{a, b},
+		Warnings:  res.Warnings,
+	}
+	if err == nil {
+		entry.IntervalResult = intervalParts(res)
+	}
+	s.record(entry, err)
+	return res, err
+}
+
+// intervalParts returns the disjoint pieces of a result.
+func intervalParts(res IntervalResult) []calculator.Interval {
+	if res.Parts != nil {
+		return res.Parts
+	}
+	return []calculator.Interval{res.Value}
+}
+
+// replayInterval re-executes an interval-mode entry. As with integer
+// overflow, the record does not say whether division was extended: a
+// recorded success replays extended, which agrees with plain division
+// wherever that succeeds, and a recorded error replays plain and Strict.
+func replayInterval(e HistoryEntry, tol Tolerance) (ReplayMismatch, bool) {
+	m := ReplayMismatch{Entry: e}
+	if _, ok := intervalOps[e.Op]; !ok || len(e.Intervals) != 2 {
+		return m, false
+	}
+	mode := Lenient
+	if e.Error != "" {
+		mode = Strict
+	}
+	res, err := evalInterval(e.Op, e.Intervals[0], e.Intervals[1], e.Error == "", mode)
+	switch {
+	case err != nil && e.Error == "":
+		m.Error, m.Reason = err.Error(), "recorded success now fails"
+	case err == nil && e.Error != "":
+		m.IntervalResult, m.Reason = intervalParts(res), "recorded error now succeeds"
+	case err == nil:
+		m.IntervalResult = intervalParts(res)
+		if !intervalsEqual(m.IntervalResult, e.IntervalResult, tol) {
+			m.Reason = "result differs"
+		}
+	}
+	return m, true
+}
+
+func intervalsEqual(got, want []calculator.Interval, tol Tolerance) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !tol.equal(got[i].Lo, want[i].Lo) || !tol.equal(got[i].Hi, want[i].Hi) {
+			return false
+		}
+	}
+	return true
+}
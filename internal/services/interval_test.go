@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func TestCalculateInterval_ResultsAndHistory(t *testing.T) {
+	svc := NewCalculatorService()
+	a, b := calculator.Interval{Lo: 1, Hi: 2}, calculator.Interval{Lo: -1, Hi: 4}
+
+	res, err := svc.CalculateInterval("multiply", a, b, false)
+	if err != nil || res.Value != (calculator.Interval{Lo: -2, Hi: 8}) || res.Parts != nil {
+		t.Fatalf("multiply = %+v, %v", res, err)
+	}
+	res, err = svc.CalculateInterval("divide", a, b, true)
+	if err != nil || len(res.Parts) != 2 || res.Parts[0].Hi != -1 || res.Parts[1].Lo != 0.25 || res.Value.Bounded() {
+		t.Fatalf("extended divide = %+v, %v", res, err)
+	}
+	if _, err := svc.CalculateInterval("divide", a, b, false); !errors.Is(err, calculator.ErrDivisorContainsZero) {
+		t.Fatalf("divide error = %v", err)
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 3 {
+		t.Fatalf("history len = %d; want 3", len(h))
+	}
+	if h[0].Mode != ModeInterval || h[0].Error == "" || len(h[0].Intervals) != 2 {
+		t.Fatalf("failed entry = %+v", h[0])
+	}
+	if len(h[1].IntervalResult) != 2 || len(h[2].IntervalResult) != 1 {
+		t.Fatalf("entries = %+v", h)
+	}
+	if rep := Replay(h, Tolerance{}); !rep.OK() || rep.Matched != 3 {
+		t.Fatalf("replay = %+v", rep)
+	}
+
+	h[2].IntervalResult = []calculator.Interval{{Lo: -2, Hi: 7}}
+	if rep := Replay(h, Tolerance{}); len(rep.Mismatches) != 1 || rep.Mismatches[0].IntervalResult[0].Hi != 8 {
+		t.Fatalf("tampered replay = %+v", rep)
+	}
+}
+
+func TestCalculateInterval_Errors(t *testing.T) {
+	big := calculator.Interval{Lo: 1, Hi: math.MaxFloat64}
+	var ie *InputError
+
+	svc := NewCalculatorService()
+	if _, err := svc.CalculateInterval("pow", big, big, false); !errors.As(err, &ie) || ie.Field != "op" {
+		t.Fatalf("unknown op error = %v", err)
+	}
+	if _, err := svc.CalculateInterval("add", big, calculator.Interval{Lo: 2, Hi: 1}, false); !errors.As(err, &ie) || ie.Field != "b" {
+		t.Fatalf("reversed bounds error = %v", err)
+	}
+	if _, err := svc.CalculateInterval("add", calculator.Interval{Lo: math.Inf(-1), Hi: 0}, big, false); !errors.As(err, &ie) || ie.Field != "a" {
+		t.Fatalf("unbounded operand error = %v", err)
+	}
+
+	// Overflow keeps the unbounded side rather than clamping it.
+	res, err := svc.CalculateInterval("add", big, big, false)
+	if err != nil || !math.IsInf(res.Value.Hi, 1) || len(res.Warnings) != 1 || res.Warnings[0] != WarnOverflow {
+		t.Fatalf("lenient overflow = %+v, %v", res, err)
+	}
+	var ce *calculator.Error
+	strict := NewCalculatorService(WithStrictness(Strict))
+	if _, err := strict.CalculateInterval("add", big, big, false); !errors.As(err, &ce) || ce.Kind != calculator.KindOverflow {
+		t.Fatalf("strict overflow = %v", err)
+	}
+	if _, err := strict.CalculateInterval("divide", big, calculator.Interval{Lo: 0, Hi: 1}, true); err != nil {
+		t.Fatalf("strict extended divide = %v", err)
+	}
+	if rep := Replay(strict.GetHistory(0), Tolerance{}); !rep.OK() || rep.Matched != 2 {
+		t.Fatalf("replay = %+v", rep)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"math"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// Strictness selects how results that float64 cannot represent are reported.
+type Strictness int
+
+const (
+	// Lenient reports every precision problem as a warning. An overflowing
+	// result keeps its infinite Value, which has no place in JSON: responses
+	// and history leave it out and the overflow warning says why.
+	Lenient Strictness = iota
+	// Strict fails overflowing results with a calculator.KindOverflow error.
+	// Underflow and cancellation are still reported as warnings.
+	Strict
+)
+
+// WithStrictness sets how overflow is reported (default Lenient).
+func WithStrictness(s Strictness) Option {
+	return func(c *config) {
+		c.strictness = s
+	}
+}
+
+// Warning codes reported in Result.Warnings and HistoryEntry.Warnings.
+const (
+	WarnOverflow     = "overflow"     // result exceeded the float64 range and has no value
+	WarnUnderflow    = "underflow"    // non-zero result rounded to zero
+	WarnSubnormal    = "subnormal"    // result is subnormal and has reduced precision
+	WarnCancellation = "cancellation" // most significant bits of the operands cancelled
+)
+
+// Result is a computed value together with any precision warnings.
+type Result struct {
+	// Value is ±Inf when it overflowed in Lenient mode.
+	Value float64 `json:"value"`
+	// Uncertainty is the propagated standard uncertainty of Value, set only
+	// by CalculateUncertain.
+	Uncertainty *float64 `json:"uncertainty,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// cancellationBits is how many leading bits must cancel in a subtraction (about
+// half of the 53-bit significand) before the result is flagged.
+const cancellationBits = 26
+
+// checkResult inspects res = op(a, b) for overflow, underflow, subnormal results
+// and catastrophic cancellation. Non-finite operands are passed through as is.
+func checkResult(op string, a, b, res float64, mode Strictness) (Result, error) {
+	out := Result{Value: res}
+	if !isFinite(a) || !isFinite(b) {
+		return out, nil
+	}
+	if math.IsNaN(res) {
+		// Only registered operations outside the built-ins can get here.
+		return Result{}, &calculator.Error{Kind: calculator.KindDomain, Op: op, Msg: "result is not a number"}
+	}
+
+	if math.IsInf(res, 0) {
+		if mode == Strict {
+			return out, &calculator.Error{Kind: calculator.KindOverflow, Op: op, Msg: "result overflows the float64 range"}
+		}
+		out.Warnings = append(out.Warnings, WarnOverflow)
+		return out, nil
+	}
+
+	switch {
+	case res == 0 && (op == "multiply" || op == "divide") && a != 0 && b != 0:
+		out.Warnings = append(out.Warnings, WarnUnderflow)
+	case res != 0 && math.Abs(res) < 0x1p-1022:
+		out.Warnings = append(out.Warnings, WarnSubnormal)
+	}
+
+	// a-b and a+(-b) cancel the same way.
+	if op == "add" {
+		b = -b
+	}
+	if (op == "subtract" || op == "add") && res != 0 && math.Signbit(a) == math.Signbit(b) {
+		if lost := math.Log2(math.Max(math.Abs(a), math.Abs(b)) / math.Abs(res)); lost >= cancellationBits {
+			out.Warnings = append(out.Warnings, WarnCancellation)
+		}
+	}
+	return out, nil
+}
+
+func isFinite(f float64) bool { return !math.IsInf(f, 0) && !math.IsNaN(f) }
+
+// recorded is v as history and replay reports hold it: an overflowed result
+// is left out like a zero one, with the overflow warning telling them apart.
+func recorded(v float64) float64 {
+	if math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func TestCheckResult(t *testing.T) {
+	cases := []struct {
+		name  string
+		op    string
+		a, b  float64
+		value float64
+		warns []string
+	}{
+		{"exact", "add", 1, 2, 3, nil},
+		{"overflow", "multiply", 1e308, 10, math.Inf(1), []string{WarnOverflow}},
+		{"negative overflow", "subtract", -math.MaxFloat64, math.MaxFloat64, math.Inf(-1), []string{WarnOverflow}},
+		{"underflow to zero", "multiply", 1e-200, 1e-200, 0, []string{WarnUnderflow}},
+		{"underflow in divide", "divide", 1e-300, 1e300, 0, []string{WarnUnderflow}},
+		{"genuine zero", "multiply", 0, 5, 0, nil},
+		{"subnormal", "divide", 1e-300, 1e10, 1e-310, []string{WarnSubnormal}},
+		{"cancellation", "subtract", 1 + 0x1p-30, 1, 0x1p-30, []string{WarnCancellation}},
+		{"cancellation via add", "add", 1 + 0x1p-30, -1, 0x1p-30, []string{WarnCancellation}},
+		{"mild cancellation", "subtract", 1.5, 1, 0.5, nil},
+		{"exact equality", "subtract", 3, 3, 0, nil},
+		{"opposite signs do not cancel", "subtract", 1, -1, 2, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			op, _ := calculator.DefaultRegistry.Lookup(tc.op)
+			raw, _ := op.Fn(tc.a, tc.b)
+			got, err := checkResult(tc.op, tc.a, tc.b, raw, Lenient)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Value != tc.value || !slices.Equal(got.Warnings, tc.warns) {
+				t.Fatalf("got %+v; want value=%v warnings=%v", got, tc.value, tc.warns)
+			}
+		})
+	}
+}
+
+func TestStrictness_Overflow(t *testing.T) {
+	strict := NewCalculatorService(WithStrictness(Strict))
+	_, err := strict.Calculate("multiply", 1e308, 10)
+	var ce *calculator.Error
+	if !errors.As(err, &ce) || ce.Kind != calculator.KindOverflow || ce.Op != "multiply" {
+		t.Fatalf("strict overflow error = %#v; want KindOverflow", err)
+	}
+	if h := strict.GetHistory(1); len(h) != 1 || h[0].Error == "" {
+		t.Fatalf("strict overflow should be recorded as an error, got %+v", h)
+	}
+
+	lenient := NewCalculatorService()
+	res, err := lenient.Calculate("multiply", 1e308, 10)
+	if err != nil || !math.IsInf(res.Value, 1) {
+		t.Fatalf("lenient overflow = %+v, %v", res, err)
+	}
+	h := lenient.GetHistory(1)
+	if len(h) != 1 || !slices.Equal(h[0].Warnings, []string{WarnOverflow}) || h[0].Result != 0 {
+		t.Fatalf("history should record the warning without a value, got %+v", h)
+	}
+
+	// Both records replay cleanly under their own strictness.
+	if rep := Replay(append(strict.GetHistory(0), h...), Tolerance{}); !rep.OK() {
+		t.Fatalf("replay mismatches: %+v", rep.Mismatches)
+	}
+	// A finite stand-in for the overflowed value does not.
+	h[0].Result = math.MaxFloat64
+	if rep := Replay(h, Tolerance{}); len(rep.Mismatches) != 1 || rep.Mismatches[0].Result != 0 {
+		t.Fatalf("clamped record replay = %+v", rep)
+	}
+}
+
+func TestCalculate_UnknownOp(t *testing.T) {
+	svc := NewCalculatorService()
+	_, err := svc.Calculate("pow", 2, 3)
+	var ie *InputError
+	if !errors.As(err, &ie) || ie.Field != "op" {
+		t.Fatalf("error = %#v; want InputError on op", err)
+	}
+	if len(svc.GetHistory(0)) != 0 {
+		t.Fatalf("unknown ops must not be recorded")
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"math"
+	"slices"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// Tolerance controls how a replayed result is compared with the recorded one.
+// A zero Tolerance requires bit-for-bit equal results.
+type Tolerance struct {
+	Abs float64 `json:"abs,omitempty"`
+	Rel float64 `json:"rel,omitempty"`
+}
+
+// Validate rejects negative or non-finite tolerances.
+func (t Tolerance) Validate() error {
+	for _, f := range []struct {
+		name string
+		v    float64
+	}{{"tolerance.abs", t.Abs}, {"tolerance.rel", t.Rel}} {
+		if math.IsNaN(f.v) || math.IsInf(f.v, 0) || f.v < 0 {
+			return &InputError{Field: f.name, Msg: "tolerance must be non-negative finite numbers"}
+		}
+	}
+	return nil
+}
+
+func (t Tolerance) equal(got, want float64) bool {
+	if got == want {
+		return true
+	}
+	diff := math.Abs(got - want)
+	if diff <= t.Abs {
+		return true
+	}
+	return diff <= t.Rel*math.Max(math.Abs(got), math.Abs(want))
+}
+
+// sameValue reports whether a recomputed value matches the result recorded
+// in e, where an overflowed one is missing and has the overflow warning.
+func sameValue(got float64, e HistoryEntry, tol Tolerance) bool {
+	if math.IsInf(got, 0) {
+		return e.Result == 0 && slices.Contains(e.Warnings, WarnOverflow)
+	}
+	return tol.equal(got, e.Result)
+}
+
+// ReplayMismatch describes a history entry whose recomputation disagrees with the record.
+type ReplayMismatch struct {
+	Entry     HistoryEntry        `json:"entry"`
+	Result    float64             `json:"result"`
+	Complex   *calculator.Complex `json:"complex,omitempty"`
+	Polar     *calculator.Polar   `json:"polar,omitempty"`
+	IntResult string              `json:"int_result,omitempty"`
+	Quantity  string              `json:"quantity,omitempty"`
+	// Uncertainty is the recomputed uncertainty of an entry with measured operands.
+	Uncertainty *float64 `json:"uncertainty,omitempty"`
+	// IntervalResult holds the recomputed parts of an interval-mode result.
+	IntervalResult []calculator.Interval `json:"interval_result,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	Reason         string                `json:"reason"`
+}
+
+// ReplaySkip describes a history entry that could not be re-executed.
+type ReplaySkip struct {
+	ID     int64  `json:"id"`
+	Op     string `json:"op"`
+	Reason string `json:"reason"`
+}
+
+// ReplayReport summarizes a replay run.
+type ReplayReport struct {
+	Total      int              `json:"total"`
+	Matched    int              `json:"matched"`
+	Mismatches []ReplayMismatch `json:"mismatches"`
+	Skipped    []ReplaySkip     `json:"skipped"`
+}
+
+// OK reports whether every replayable entry matched its recorded outcome.
+func (r ReplayReport) OK() bool { return len(r.Mismatches) == 0 }
+
+// Replay re-executes each entry through the current calculator functions and
+// reports entries whose result or error outcome no longer matches the record.
+// Basic operations are looked up in calculator.DefaultRegistry. Nothing is
+// recorded into history.
+func Replay(entries []HistoryEntry, tol Tolerance) ReplayReport {
+	report := ReplayReport{
+		Total:      len(entries),
+		Mismatches: []ReplayMismatch{},
+		Skipped:    []ReplaySkip{},
+	}
+
+	for _, e := range entries {
+		if e.Mode == ModeCurrency && e.Error != "" {
+			report.Skipped = append(report.Skipped, ReplaySkip{ID: e.ID, Op: e.Op, Reason: "failed conversions record no rates"})
+			continue
+		}
+		if t, isInt := calculator.ParseIntType(e.Mode); isInt || e.Mode == ModeComplex || e.Mode == ModeCurrency || e.Mode == ModeInterval || e.UncertaintyA != nil {
+			var (
+				m  ReplayMismatch
+				ok bool
+			)
+			switch {
+			case isInt:
+				m, ok = replayInt(t, e)
+			case e.Mode == ModeCurrency:
+				m, ok = replayCurrency(e)
+			case e.Mode == ModeInterval:
+				m, ok = replayInterval(e, tol)
+			case e.UncertaintyA != nil:
+				m, ok = replayUncertain(e, tol)
+			default:
+				m, ok = replayComplex(e, tol)
+			}
+			switch {
+			case !ok:
+				report.Skipped = append(report.Skipped, ReplaySkip{ID: e.ID, Op: e.Op, Reason: "unknown op"})
+			case m.Reason != "":
+				report.Mismatches = append(report.Mismatches, m)
+			default:
+				report.Matched++
+			}
+			continue
+		}
+		if e.Mode != "" {
+			// Only the summary of a stats entry is recorded, not its dataset.
+			report.Skipped = append(report.Skipped, ReplaySkip{ID: e.ID, Op: e.Op, Reason: "mode cannot be replayed"})
+			continue
+		}
+
+		op, ok := calculator.DefaultRegistry.Lookup(e.Op)
+		if !ok {
+			report.Skipped = append(report.Skipped, ReplaySkip{ID: e.ID, Op: e.Op, Reason: "unknown op"})
+			continue
+		}
+
+		res, err := op.Fn(e.A, e.B)
+		if err == nil {
+			// The record does not say which strictness produced it: a recorded
+			// failure may have been a Strict overflow, a success a Lenient one.
+			mode := Lenient
+			if e.Error != "" {
+				mode = Strict
+			}
+			var checked Result
+			checked, err = checkResult(e.Op, e.A, e.B, res, mode)
+			res = checked.Value
+		}
+		m := ReplayMismatch{Entry: e, Result: recorded(res)}
+		if err != nil {
+			m.Error = err.Error()
+		}
+
+		switch {
+		case err != nil && e.Error == "":
+			m.Reason = "recorded success now fails"
+		case err == nil && e.Error != "":
+			m.Reason = "recorded error now succeeds"
+		case err != nil:
+			// Both failed; the outcome matches even if the wording changed.
+		case !sameValue(res, e, tol):
+			m.Reason = "result differs"
+		}
+
+		if m.Reason != "" {
+			report.Mismatches = append(report.Mismatches, m)
+			continue
+		}
+		report.Matched++
+	}
+	return report
+}
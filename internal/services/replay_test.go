@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestReplay_LiveHistoryMatches(t *testing.T) {
+	svc := NewCalculatorService(WithMaxHistory(100))
+	svc.Add(1, 2)
+	svc.Subtract(10, 4.5)
+	svc.Multiply(3, 7)
+	_, _ = svc.Divide(21, 7)
+	_, _ = svc.Divide(5, 0) // recorded error must replay as an error too
+
+	report := Replay(svc.GetHistory(0), Tolerance{})
+	if report.Total != 5 || report.Matched != 5 {
+		t.Fatalf("report = %+v; want 5/5 matched", report)
+	}
+	if !report.OK() || len(report.Skipped) != 0 {
+		t.Fatalf("unexpected mismatches/skips: %+v", report)
+	}
+}
+
+func TestReplay_DetectsMismatches(t *testing.T) {
+	entries := []HistoryEntry{
+		{ID: 1, Op: "add", A: 1, B: 2, Result: 4},                     // wrong result
+		{ID: 2, Op: "divide", A: 1, B: 0, Result: 0},                  // recorded success, now fails
+		{ID: 3, Op: "multiply", A: 2, B: 3, Result: 6, Error: "boom"}, // recorded error, now succeeds
+		{ID: 4, Op: "pow", A: 2, B: 3, Result: 8},                     // unknown op
+		{ID: 5, Op: "subtract", A: 0.3, B: 0.1, Result: 0.2},          // off by float rounding
+	}
+
+	report := Replay(entries, Tolerance{})
+	if report.Total != 5 {
+		t.Fatalf("total=%d; want 5", report.Total)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].ID != 4 {
+		t.Fatalf("skipped = %+v; want entry 4", report.Skipped)
+	}
+	if len(report.Mismatches) != 4 {
+		t.Fatalf("mismatches = %+v; want 4", report.Mismatches)
+	}
+	wantReasons := []string{"result differs", "recorded success now fails", "recorded error now succeeds", "result differs"}
+	for i, m := range report.Mismatches {
+		if m.Reason != wantReasons[i] {
+			t.Fatalf("mismatch[%d].Reason = %q; want %q", i, m.Reason, wantReasons[i])
+		}
+	}
+	if report.Mismatches[1].Error == "" {
+		t.Fatalf("expected replay error to be reported: %+v", report.Mismatches[1])
+	}
+}
+
+func TestReplay_Tolerance(t *testing.T) {
+	entries := []HistoryEntry{{ID: 1, Op: "subtract", A: 0.3, B: 0.1, Result: 0.2}}
+
+	cases := []struct {
+		name string
+		tol  Tolerance
+		ok   bool
+	}{
+		{"exact", Tolerance{}, false},
+		{"abs", Tolerance{Abs: 1e-12}, true},
+		{"rel", Tolerance{Rel: 1e-12}, true},
+		{"too tight", Tolerance{Abs: 1e-20, Rel: 1e-20}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Replay(entries, tc.tol).OK(); got != tc.ok {
+				t.Fatalf("OK() = %v; want %v", got, tc.ok)
+			}
+		})
+	}
+}
+
+func TestTolerance_ValidateRejectsNegative(t *testing.T) {
+	if err := (Tolerance{Abs: -1}).Validate(); err == nil {
+		t.Fatalf("negative tolerance must be rejected")
+	} else if ie, ok := err.(*InputError); !ok || ie.Field != "tolerance.abs" {
+		t.Fatalf("Validate error = %#v; want InputError on tolerance.abs", err)
+	}
+}
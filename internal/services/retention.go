@@ -0,0 +1,268 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Retention bounds how much history is kept. Zero fields are unlimited.
+type Retention struct {
+	MaxAge   time.Duration
+	MaxCount int
+	MaxBytes int64
+}
+
+// Eviction rule names used in RetentionStats.
+const (
+	RuleMaxAge   = "max_age"
+	RuleMaxCount = "max_count"
+	RuleMaxBytes = "max_bytes"
+)
+
+// RetentionStats describes the history store and how many entries each rule evicted.
+type RetentionStats struct {
+	Entries int              `json:"entries"`
+	Bytes   int64            `json:"bytes"`
+	Evicted map[string]int64 `json:"evicted"`
+}
+
+// WithRetention sets the global age and byte limits and, when non-zero, the count limit.
+func WithRetention(r Retention) Option {
+	return func(c *config) {
+		count := c.retention.MaxCount
+		c.retention = r
+		if r.MaxCount <= 0 {
+			c.retention.MaxCount = count
+		}
+	}
+}
+
+// WithTenantRetention overrides retention for one tenant. The override's MaxAge
+// replaces the global one for that tenant's entries, and its count and byte
+// limits apply to that tenant alone. The global count and byte limits still
+// bound the store as a whole.
+func WithTenantRetention(tenant string, r Retention) Option {
+	return func(c *config) {
+		c.tenantRetention[tenant] = r
+	}
+}
+
+// LoadTenantRetention reads per-tenant retention overrides from JSON mapping
+// tenant IDs to limits, for use with WithTenantRetention:
+//
+//	{"acme": {"max_age": "24h", "max_count": 50, "max_bytes": 1048576}}
+//
+// Omitted limits are unlimited for that tenant.
+func LoadTenantRetention(r io.Reader) (map[string]Retention, error) {
+	var file map[string]struct {
+		MaxAge   string `json:"max_age"`
+		MaxCount int    `json:"max_count"`
+		MaxBytes int64  `json:"max_bytes"`
+	}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, err
+	}
+	out := make(map[string]Retention, len(file))
+	for tenant, f := range file {
+		var ret Retention
+		if f.MaxAge != "" {
+			d, err := time.ParseDuration(f.MaxAge)
+			if err != nil {
+				return nil, fmt.Errorf("%s: max_age: %w", tenant, err)
+			}
+			ret.MaxAge = d
+		}
+		ret.MaxCount, ret.MaxBytes = f.MaxCount, f.MaxBytes
+		if tenant == "" || ret.MaxAge < 0 || ret.MaxCount < 0 || ret.MaxBytes < 0 {
+			return nil, fmt.Errorf("%q: tenant must be named and limits must not be negative", tenant)
+		}
+		out[tenant] = ret
+	}
+	return out, nil
+}
+
+// WithJanitorInterval sets how often the background janitor expires entries by
+// age. The janitor only runs when a MaxAge rule is configured.
+func WithJanitorInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.janitorEvery = d
+		}
+	}
+}
+
+type storedEntry struct {
+	HistoryEntry
+	size int64
+}
+
+// store holds history shared by every tenant view of a service.
+type store struct {
+	mu        sync.Mutex
+	history   []storedEntry // oldest first
+	nextID    int64
+	nextRun   int64
+	bytes     int64
+	retention Retention
+	tenants   map[string]Retention
+	evicted   map[string]int64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// entrySize approximates an entry's footprint by its serialized size.
+func entrySize(e HistoryEntry) int64 {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return 0
+	}
+	return int64(len(b))
+}
+
+func (st *store) append(e HistoryEntry) {
+	se := storedEntry{HistoryEntry: e, size: entrySize(e)}
+	st.history = append(st.history, se)
+	st.bytes += se.size
+}
+
+func (st *store) removeIf(drop func(e *storedEntry) bool) {
+	kept := st.history[:0]
+	for i := range st.history {
+		if drop(&st.history[i]) {
+			st.bytes -= st.history[i].size
+			continue
+		}
+		kept = append(kept, st.history[i])
+	}
+	clear(st.history[len(kept):])
+	st.history = kept
+}
+
+type usage struct {
+	count int
+	bytes int64
+}
+
+// enforce evicts entries that break a retention rule, walking newest to oldest
+// so the oldest entries go first. Callers must hold st.mu.
+func (st *store) enforce(now time.Time) {
+	var (
+		global      usage
+		perTenant   = map[string]*usage{}
+		verdicts    = make([]string, len(st.history))
+		anyEviction bool
+	)
+
+	for i := len(st.history) - 1; i >= 0; i-- {
+		e := &st.history[i]
+		override, hasOverride := st.tenants[e.Tenant]
+
+		maxAge := st.retention.MaxAge
+		if hasOverride && override.MaxAge > 0 {
+			maxAge = override.MaxAge
+		}
+
+		rule := ""
+		switch {
+		case maxAge > 0 && now.Sub(e.Time) > maxAge:
+			rule = RuleMaxAge
+		case hasOverride:
+			u := perTenant[e.Tenant]
+			if u == nil {
+				u = &usage{}
+				perTenant[e.Tenant] = u
+			}
+			rule = u.admit(override, e.size)
+		}
+		if rule == "" {
+			rule = global.admit(st.retention, e.size)
+		}
+
+		verdicts[i] = rule
+		if rule != "" {
+			anyEviction = true
+		}
+	}
+	if !anyEviction {
+		return
+	}
+
+	i := 0
+	st.removeIf(func(e *storedEntry) bool {
+		rule := verdicts[i]
+		i++
+		if rule == "" {
+			return false
+		}
+		st.evicted[rule]++
+		return true
+	})
+}
+
+// admit counts an entry against the limits in r and returns the rule it breaks,
+// if any. Once a limit is hit every older entry breaks it too.
+func (u *usage) admit(r Retention, size int64) string {
+	if r.MaxCount > 0 && u.count >= r.MaxCount {
+		return RuleMaxCount
+	}
+	if r.MaxBytes > 0 && u.bytes+size > r.MaxBytes {
+		u.bytes = r.MaxBytes // saturate so older entries are evicted as well
+		return RuleMaxBytes
+	}
+	u.count++
+	u.bytes += size
+	return ""
+}
+
+func (st *store) startJanitor(every time.Duration) {
+	st.stop = make(chan struct{})
+	st.done = make(chan struct{})
+	go func() {
+		defer close(st.done)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-st.stop:
+				return
+			case now := <-ticker.C:
+				st.mu.Lock()
+				st.enforce(now)
+				st.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Close stops the janitor and waits for it to exit. It is safe to call more than once.
+func (st *store) Close() {
+	st.closeOnce.Do(func() {
+		if st.stop == nil {
+			return
+		}
+		close(st.stop)
+		<-st.done
+	})
+}
+
+func (st *store) RetentionStats() RetentionStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	evicted := map[string]int64{RuleMaxAge: 0, RuleMaxCount: 0, RuleMaxBytes: 0}
+	for rule, n := range st.evicted {
+		evicted[rule] = n
+	}
+	return RetentionStats{
+		Entries: len(st.history),
+		Bytes:   st.bytes,
+		Evicted: evicted,
+	}
+}
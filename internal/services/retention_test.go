@@ -0,0 +1,192 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+/* ------------------ rules ------------------ */
+
+func TestRetention_MaxCountIsReported(t *testing.T) {
+	svc := NewCalculatorService(WithMaxHistory(3))
+	for i := 0; i < 5; i++ {
+		svc.Add(float64(i), 0)
+	}
+
+	st := svc.RetentionStats()
+	if st.Entries != 3 {
+		t.Fatalf("entries=%d; want 3", st.Entries)
+	}
+	if st.Evicted[RuleMaxCount] != 2 || st.Evicted[RuleMaxAge] != 0 || st.Evicted[RuleMaxBytes] != 0 {
+		t.Fatalf("evicted=%v; want 2 by max_count", st.Evicted)
+	}
+	if st.Bytes <= 0 {
+		t.Fatalf("bytes=%d; want > 0", st.Bytes)
+	}
+}
+
+func TestRetention_MaxBytesKeepsNewest(t *testing.T) {
+	// Upper bound for one entry: two-digit ID and a timestamp with all nine fractional digits.
+	ts := time.Now().Truncate(time.Second).Add(123456789)
+	one := entrySize(HistoryEntry{ID: 10, Time: ts, Op: "add", A: 1, B: 1, Result: 2})
+	svc := NewCalculatorService(WithRetention(Retention{MaxBytes: 3*one + one/2}))
+	for i := 0; i < 6; i++ {
+		svc.Add(1, 1)
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 3 {
+		t.Fatalf("len=%d; want 3 (budget of ~3 entries)", len(h))
+	}
+	if h[0].ID != 5 || h[2].ID != 3 {
+		t.Fatalf("want newest entries 5..3 kept, got %d..%d", h[0].ID, h[2].ID)
+	}
+	st := svc.RetentionStats()
+	if st.Evicted[RuleMaxBytes] != 3 {
+		t.Fatalf("evicted=%v; want 3 by max_bytes", st.Evicted)
+	}
+	if st.Bytes > 3*one+one/2 {
+		t.Fatalf("bytes=%d exceeds budget", st.Bytes)
+	}
+}
+
+func TestRetention_JanitorExpiresByAge(t *testing.T) {
+	svc := NewCalculatorService(
+		WithRetention(Retention{MaxAge: 20 * time.Millisecond}),
+		WithJanitorInterval(5*time.Millisecond),
+	)
+	defer svc.Close()
+
+	svc.Add(1, 2)
+	svc.Multiply(3, 4)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(svc.GetHistory(0)) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not expire entries: %+v", svc.GetHistory(0))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := svc.RetentionStats().Evicted[RuleMaxAge]; n != 2 {
+		t.Fatalf("evicted by max_age=%d; want 2", n)
+	}
+}
+
+func TestRetention_CloseStopsJanitor(t *testing.T) {
+	svc := NewCalculatorService(
+		WithRetention(Retention{MaxAge: 10 * time.Millisecond}),
+		WithJanitorInterval(time.Millisecond),
+	)
+	svc.Close()
+	svc.Close() // idempotent
+
+	svc.Add(1, 2)
+	time.Sleep(30 * time.Millisecond)
+	if n := len(svc.GetHistory(0)); n != 1 {
+		t.Fatalf("entry expired after Close; len=%d", n)
+	}
+}
+
+/* ------------------ tenants ------------------ */
+
+func TestTenants_HistoryIsIsolated(t *testing.T) {
+	svc := NewCalculatorService()
+	acme := svc.ForTenant("acme")
+
+	svc.Add(1, 1)
+	acme.Add(2, 2)
+	acme.Multiply(3, 3)
+
+	if h := svc.GetHistory(0); len(h) != 1 || h[0].Tenant != "" {
+		t.Fatalf("default tenant history = %+v", h)
+	}
+	h := acme.GetHistory(0)
+	if len(h) != 2 || h[0].Tenant != "acme" || h[0].Op != "multiply" {
+		t.Fatalf("acme history = %+v", h)
+	}
+
+	acme.ClearHistory()
+	if n := len(acme.GetHistory(0)); n != 0 {
+		t.Fatalf("acme history after clear len=%d", n)
+	}
+	if n := len(svc.GetHistory(0)); n != 1 {
+		t.Fatalf("clearing acme must not touch other tenants; len=%d", n)
+	}
+}
+
+func TestTenants_RetentionOverride(t *testing.T) {
+	svc := NewCalculatorService(
+		WithMaxHistory(10),
+		WithTenantRetention("small", Retention{MaxCount: 2}),
+	)
+	small := svc.ForTenant("small")
+	big := svc.ForTenant("big")
+
+	for i := 0; i < 5; i++ {
+		small.Add(float64(i), 0)
+		big.Add(float64(i), 0)
+	}
+
+	if h := small.GetHistory(0); len(h) != 2 || h[0].A != 4 || h[1].A != 3 {
+		t.Fatalf("small tenant history = %+v; want A=4,3", h)
+	}
+	if n := len(big.GetHistory(0)); n != 5 {
+		t.Fatalf("big tenant len=%d; want 5", n)
+	}
+	if n := svc.RetentionStats().Evicted[RuleMaxCount]; n != 3 {
+		t.Fatalf("evicted by max_count=%d; want 3", n)
+	}
+
+	// The global cap still bounds the whole store.
+	for i := 0; i < 10; i++ {
+		big.Add(float64(i), 1)
+	}
+	if st := svc.RetentionStats(); st.Entries != 10 {
+		t.Fatalf("store entries=%d; want global cap 10", st.Entries)
+	}
+}
+
+func TestTenants_AgeOverride(t *testing.T) {
+	svc := NewCalculatorService(
+		WithTenantRetention("ephemeral", Retention{MaxAge: 10 * time.Millisecond}),
+		WithJanitorInterval(2*time.Millisecond),
+	)
+	defer svc.Close()
+
+	svc.ForTenant("ephemeral").Add(1, 1)
+	svc.Add(2, 2)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(svc.ForTenant("ephemeral").GetHistory(0)) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("ephemeral tenant entry was not expired")
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if n := len(svc.GetHistory(0)); n != 1 {
+		t.Fatalf("default tenant must keep its entry; len=%d", n)
+	}
+}
+
+func TestLoadTenantRetention(t *testing.T) {
+	got, err := LoadTenantRetention(strings.NewReader(`{"acme": {"max_age": "24h", "max_count": 50}, "beta": {"max_bytes": 1024}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["acme"] != (Retention{MaxAge: 24 * time.Hour, MaxCount: 50}) || got["beta"] != (Retention{MaxBytes: 1024}) {
+		t.Fatalf("overrides = %+v", got)
+	}
+
+	for _, bad := range []string{
+		`{"acme": {"max_age": "soon"}}`,
+		`{"acme": {"max_count": -1}}`,
+		`{"acme": {"max_entries": 5}}`,
+		`{"": {"max_count": 5}}`,
+		`[]`,
+	} {
+		if _, err := LoadTenantRetention(strings.NewReader(bad)); err == nil {
+			t.Fatalf("%s accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/script"
+)
+
+// ScriptOptions control RunScript.
+type ScriptOptions struct {
+	// Record adds every operation the script performs to history, tagged
+	// with a run number shared by the whole script.
+	Record bool
+	Limits script.Limits
+}
+
+// ScriptResult is the outcome of RunScript.
+type ScriptResult struct {
+	script.Result
+	// Run numbers the history entries the script recorded; 0 when it was
+	// not recorded.
+	Run int64 `json:"run,omitempty"`
+}
+
+func (s *calcSvc) RunScript(ctx context.Context, src string, opts ScriptOptions) (ScriptResult, error) {
+	p, err := script.Parse(src)
+	if err != nil {
+		return ScriptResult{}, err
+	}
+	ops := scriptOps{s: s}
+	if opts.Record {
+		ops.run = s.newRun()
+	}
+	res, err := script.Run(ctx, p, ops, opts.Limits)
+	return ScriptResult{Result: res, Run: ops.run}, err
+}
+
+// newRun returns the next run number.
+func (st *store) newRun() int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.nextRun++
+	return st.nextRun
+}
+
+// scriptOps performs the operations of a script like Calculate, recording
+// them under run unless it is 0. A script cannot go on with a value that
+// overflowed, so that fails even in Lenient mode.
+type scriptOps struct {
+	s   *calcSvc
+	run int64
+}
+
+func (o scriptOps) Lookup(name string) (string, int, bool) {
+	op, ok := o.s.ops.Lookup(name)
+	return op.Name, op.Arity(), ok
+}
+
+func (o scriptOps) Call(name string, a, b float64) (float64, []string, error) {
+	op, err := o.s.lookup(name, a, b)
+	if err != nil {
+		return 0, nil, err
+	}
+	res, err := o.s.evaluate(op, a, b)
+	if err == nil && math.IsInf(res.Value, 0) {
+		err = &calculator.Error{Kind: calculator.KindOverflow, Op: op.Name, Msg: "result overflows the float64 range"}
+	}
+	if o.run != 0 {
+		o.s.record(HistoryEntry{Op: op.Name, A: a, B: b, Result: res.Value, Warnings: res.Warnings, Run: o.run}, err)
+	}
+	return res.Value, res.Warnings, err
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/script"
+)
+
+func TestRunScript_RecordsGroupedRun(t *testing.T) {
+	svc := NewCalculatorService()
+	svc.Add(1, 1)
+
+	res, err := svc.RunScript(context.Background(), "x = 2 + 3\ny = x * 4\ny / 2", ScriptOptions{Record: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Run == 0 || *res.Value != 10 || len(res.Trace) != 5 {
+		t.Fatalf("result = %+v", res)
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 4 || h[3].Run != 0 {
+		t.Fatalf("history = %+v", h)
+	}
+	for i, op := range []string{"divide", "multiply", "add"} {
+		if h[i].Op != op || h[i].Run != res.Run {
+			t.Fatalf("entry %d = %+v, want %s in run %d", i, h[i], op, res.Run)
+		}
+	}
+	if rep := Replay(h, Tolerance{}); rep.Matched != 4 || !rep.OK() {
+		t.Fatalf("replay = %+v", rep)
+	}
+
+	// Every recorded run gets its own number; unrecorded runs leave no trace.
+	again, _ := svc.RunScript(context.Background(), "1 + 1", ScriptOptions{Record: true})
+	if again.Run == res.Run {
+		t.Fatalf("run number %d reused", again.Run)
+	}
+	quiet, err := svc.RunScript(context.Background(), "1 + 1", ScriptOptions{})
+	if err != nil || quiet.Run != 0 || len(svc.GetHistory(0)) != 5 {
+		t.Fatalf("unrecorded run = %+v, %v; history %d", quiet, err, len(svc.GetHistory(0)))
+	}
+}
+
+func TestRunScript_Errors(t *testing.T) {
+	svc := NewCalculatorService(WithStrictness(Strict))
+
+	var se *script.SyntaxError
+	if _, err := svc.RunScript(context.Background(), "x = ", ScriptOptions{Record: true}); !errors.As(err, &se) {
+		t.Fatalf("syntax error = %v", err)
+	}
+
+	// A failing operation ends the run and is recorded with it.
+	res, err := svc.RunScript(context.Background(), "x = 4 - 4\n1 / x", ScriptOptions{Record: true})
+	var ce *calculator.Error
+	if !errors.As(err, &ce) || ce != calculator.ErrDivisionByZero {
+		t.Fatalf("error = %v", err)
+	}
+	if h := svc.GetHistory(0); len(h) != 2 || h[0].Error == "" || h[0].Run != res.Run || h[1].Run != res.Run {
+		t.Fatalf("history = %+v", h)
+	}
+
+	if _, err := svc.RunScript(context.Background(), "1e308 * 10", ScriptOptions{}); !errors.As(err, &ce) || ce.Kind != calculator.KindOverflow {
+		t.Fatalf("strict overflow = %v", err)
+	}
+	// A script cannot go on with an overflowed value in Lenient mode either.
+	if _, err := NewCalculatorService().RunScript(context.Background(), "1e308 * 10", ScriptOptions{}); !errors.As(err, &ce) || ce.Kind != calculator.KindOverflow {
+		t.Fatalf("lenient overflow = %v", err)
+	}
+
+	// Integer operands are checked as in Calculate.
+	reg := calculator.NewRegistry()
+	if err := reg.Register(calculator.Operation{
+		Name: "mod", Operands: []calculator.OperandType{calculator.TypeInteger, calculator.TypeInteger},
+		Fn: func(a, b float64) (float64, error) { return float64(int64(a) % int64(b)), nil },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc = NewCalculatorService(WithOperations(reg))
+	if res, err := svc.RunScript(context.Background(), "mod(7, 3)", ScriptOptions{}); err != nil || *res.Value != 1 {
+		t.Fatalf("mod(7, 3) = %+v, %v", res, err)
+	}
+	var ie *InputError
+	if _, err := svc.RunScript(context.Background(), "mod(7.5, 3)", ScriptOptions{}); !errors.As(err, &ie) || ie.Field != "a" {
+		t.Fatalf("fractional operand = %v", err)
+	}
+
+	var le *script.LimitError
+	if _, err := svc.RunScript(context.Background(), "for i = 1 to 100 { i + 1 }", ScriptOptions{Limits: script.Limits{Steps: 50}}); !errors.As(err, &le) {
+		t.Fatalf("limit error = %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import "erikkruuse/calculator/stats"
+
+// ModeStats marks history entries produced by Stats.
+const ModeStats = "stats"
+
+// StatsSummary is the digest of a Stats call kept in history; the dataset
+// itself is not stored.
+type StatsSummary struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"` // population standard deviation
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+func (s *calcSvc) Stats(values []float64, opts stats.Options) (stats.Summary, error) {
+	sum, err := stats.Describe(values, opts)
+	entry := HistoryEntry{Op: "describe", Mode: ModeStats}
+	if err == nil {
+		entry.Result = sum.Mean
+		entry.Stats = &StatsSummary{
+			Count:  sum.Count,
+			Mean:   sum.Mean,
+			StdDev: sum.StdDev.Population,
+			Min:    sum.Min,
+			Max:    sum.Max,
+		}
+	}
+	s.record(entry, err)
+	return sum, err
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"testing"
+
+	"erikkruuse/calculator/stats"
+)
+
+func TestStats_RecordsSummaryAndIsNotReplayed(t *testing.T) {
+	svc := NewCalculatorService()
+	if _, err := svc.Stats([]float64{1, 2, 3}, stats.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Stats(nil, stats.Options{}); err == nil {
+		t.Fatal("empty dataset must fail")
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 2 || h[0].Error == "" || h[1].Stats == nil || h[1].Stats.Mean != 2 || h[1].Stats.Max != 3 {
+		t.Fatalf("history = %+v", h)
+	}
+	rep := Replay(h, Tolerance{})
+	if rep.Matched != 0 || len(rep.Skipped) != 2 || !rep.OK() {
+		t.Fatalf("replay = %+v", rep)
+	}
+}
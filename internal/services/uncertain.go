@@ -0,0 +1,104 @@
+package service
+
+import (
+	"math"
+	"slices"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// evalUncertain runs op and checks the value as Calculate does. An
+// uncertainty that overflows is handled like an overflowing value: an error
+// in Strict mode, otherwise left unset with the overflow warning.
+func evalUncertain(op calculator.Operation, a, b calculator.Uncertain, mode Strictness) (Result, error) {
+	u, err := calculator.Propagate(op, a, b)
+	if err != nil {
+		return Result{}, err
+	}
+	res, err := checkResult(op.Name, a.Value, b.Value, u.Value, mode)
+	if err != nil {
+		return res, err
+	}
+	sigma := u.Uncertainty
+	if math.IsInf(sigma, 0) {
+		if mode == Strict {
+			return Result{}, &calculator.Error{Kind: calculator.KindOverflow, Op: op.Name, Msg: "result overflows the float64 range"}
+		}
+		if !slices.Contains(res.Warnings, WarnOverflow) {
+			res.Warnings = append(res.Warnings, WarnOverflow)
+		}
+		return res, nil
+	}
+	res.Uncertainty = &sigma
+	return res, nil
+}
+
+func (s *calcSvc) CalculateUncertain(name string, a, b calculator.Uncertain) (Result, error) {
+	op, err := s.lookup(name, a.Value, b.Value)
+	if err != nil {
+		return Result{}, err
+	}
+	if op.Partials == nil {
+		return Result{}, &InputError{Field: "op", Msg: "operation does not support uncertainties"}
+	}
+	if op.Arity() == 1 {
+		b = calculator.Uncertain{}
+	}
+	for i, x := range []calculator.Uncertain{a, b} {
+		if !isFinite(x.Value) || !isFinite(x.Uncertainty) {
+			return Result{}, &InputError{Field: string(rune('a' + i)), Msg: "inputs must be finite numbers"}
+		}
+		if x.Uncertainty < 0 {
+			return Result{}, &InputError{Field: string(rune('a' + i)), Msg: "uncertainty must not be negative"}
+		}
+	}
+
+	res, err := evalUncertain(op, a, b, s.strictness)
+	s.record(HistoryEntry{
+		Op:           op.Name,
+		A:            a.Value,
+		B:            b.Value,
+		Result:       res.Value,
+		Warnings:     res.Warnings,
+		UncertaintyA: &a.Uncertainty,
+		UncertaintyB: &b.Uncertainty,
+		Uncertainty:  res.Uncertainty,
+	}, err)
+	return res, err
+}
+
+// replayUncertain re-executes an entry with measured operands.
+func replayUncertain(e HistoryEntry, tol Tolerance) (ReplayMismatch, bool) {
+	m := ReplayMismatch{Entry: e}
+	op, ok := calculator.DefaultRegistry.Lookup(e.Op)
+	if !ok || op.Partials == nil {
+		return m, false
+	}
+	a := calculator.Uncertain{Value: e.A, Uncertainty: deref(e.UncertaintyA)}
+	b := calculator.Uncertain{Value: e.B, Uncertainty: deref(e.UncertaintyB)}
+	mode := Lenient
+	if e.Error != "" {
+		mode = Strict
+	}
+	res, err := evalUncertain(op, a, b, mode)
+	switch {
+	case err != nil && e.Error == "":
+		m.Error, m.Reason = err.Error(), "recorded success now fails"
+	case err == nil && e.Error != "":
+		m.Result, m.Uncertainty, m.Reason = recorded(res.Value), res.Uncertainty, "recorded error now succeeds"
+	case err == nil:
+		m.Result, m.Uncertainty = recorded(res.Value), res.Uncertainty
+		if !sameValue(res.Value, e, tol) || !tol.equal(deref(res.Uncertainty), deref(e.Uncertainty)) ||
+			(res.Uncertainty == nil) != (e.Uncertainty == nil) {
+			m.Reason = "result differs"
+		}
+	}
+	return m, true
+}
+
+func deref(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
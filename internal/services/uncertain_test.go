@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func TestCalculateUncertain_ResultsAndHistory(t *testing.T) {
+	svc := NewCalculatorService(WithResultCache(10, 0))
+	g := calculator.Uncertain{Value: 9.81, Uncertainty: 0.02}
+
+	res, err := svc.CalculateUncertain("multiply", g, calculator.Exact(2))
+	if err != nil || res.Value != 19.62 || res.Uncertainty == nil || *res.Uncertainty != 0.04 {
+		t.Fatalf("multiply = %+v, %v", res, err)
+	}
+	res, err = svc.CalculateUncertain("subtract", calculator.Uncertain{Value: 1, Uncertainty: 0.3}, calculator.Uncertain{Value: 1, Uncertainty: 0.4})
+	if err != nil || res.Value != 0 || math.Abs(*res.Uncertainty-0.5) > 1e-15 {
+		t.Fatalf("subtract = %+v, %v", res, err)
+	}
+	if _, err := svc.CalculateUncertain("divide", g, calculator.Uncertain{Uncertainty: 1}); !errors.Is(err, calculator.ErrDivisionByZero) {
+		t.Fatalf("divide error = %v", err)
+	}
+	if st := svc.CacheStats(); st.Hits+st.Misses != 0 {
+		t.Fatalf("cache used: %+v", st)
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 3 {
+		t.Fatalf("history len = %d; want 3", len(h))
+	}
+	if e := h[2]; e.Mode != "" || e.A != 9.81 || *e.UncertaintyA != 0.02 || *e.UncertaintyB != 0 || *e.Uncertainty != 0.04 {
+		t.Fatalf("multiply entry = %+v", e)
+	}
+	if h[0].Error == "" || h[0].Uncertainty != nil {
+		t.Fatalf("failed entry = %+v", h[0])
+	}
+	if rep := Replay(h, Tolerance{}); !rep.OK() || rep.Matched != 3 {
+		t.Fatalf("replay = %+v", rep)
+	}
+
+	tampered := 0.05
+	h[2].Uncertainty = &tampered
+	if rep := Replay(h, Tolerance{}); len(rep.Mismatches) != 1 || *rep.Mismatches[0].Uncertainty != 0.04 {
+		t.Fatalf("tampered replay = %+v", rep)
+	}
+}
+
+func TestCalculateUncertain_Errors(t *testing.T) {
+	big := calculator.Uncertain{Value: 1, Uncertainty: math.MaxFloat64}
+	var ie *InputError
+
+	svc := NewCalculatorService()
+	if _, err := svc.CalculateUncertain("pow", big, big); !errors.As(err, &ie) || ie.Field != "op" {
+		t.Fatalf("unknown op error = %v", err)
+	}
+	if _, err := svc.CalculateUncertain("add", big, calculator.Uncertain{Value: 1, Uncertainty: -1}); !errors.As(err, &ie) || ie.Field != "b" {
+		t.Fatalf("negative uncertainty error = %v", err)
+	}
+	if _, err := svc.CalculateUncertain("add", calculator.Uncertain{Value: 1, Uncertainty: math.NaN()}, big); !errors.As(err, &ie) || ie.Field != "a" {
+		t.Fatalf("NaN uncertainty error = %v", err)
+	}
+
+	// The value is fine; only the uncertainty overflows.
+	res, err := svc.CalculateUncertain("add", big, big)
+	if err != nil || res.Value != 2 || res.Uncertainty != nil || len(res.Warnings) != 1 || res.Warnings[0] != WarnOverflow {
+		t.Fatalf("lenient overflow = %+v, %v", res, err)
+	}
+	var ce *calculator.Error
+	strict := NewCalculatorService(WithStrictness(Strict))
+	if _, err := strict.CalculateUncertain("add", big, big); !errors.As(err, &ce) || ce.Kind != calculator.KindOverflow {
+		t.Fatalf("strict overflow = %v", err)
+	}
+	if rep := Replay(append(svc.GetHistory(0)[:1], strict.GetHistory(0)...), Tolerance{}); !rep.OK() || rep.Matched != 2 {
+		t.Fatalf("replay = %+v", rep)
+	}
+}
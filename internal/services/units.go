@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+
+	"erikkruuse/calculator/units"
+)
+
+// ModeUnits marks history entries produced by CalculateUnits.
+const ModeUnits = "units"
+
+// WithUnits sets the unit table used by CalculateUnits (default
+// units.DefaultRegistry), e.g. one extended from a configuration file.
+func WithUnits(r *units.Registry) Option {
+	return func(c *config) {
+		if r != nil {
+			c.units = r
+		}
+	}
+}
+
+// unitOps lists the unit-aware operations; convert takes a single quantity.
+var unitOps = map[string]func(a, b units.Quantity) (units.Quantity, error){
+	"add":      units.Add,
+	"subtract": units.Subtract,
+	"multiply": units.Multiply,
+	"divide":   units.Divide,
+	"convert":  func(a, _ units.Quantity) (units.Quantity, error) { return a, nil },
+}
+
+// UnitArity returns the number of quantities op takes, or 0 if op is not a
+// unit operation.
+func UnitArity(op string) int {
+	switch _, ok := unitOps[op]; {
+	case !ok:
+		return 0
+	case op == "convert":
+		return 1
+	}
+	return 2
+}
+
+func (s *calcSvc) CalculateUnits(op, a, b, to string) (units.Quantity, error) {
+	fn := unitOps[op]
+	if fn == nil {
+		return units.Quantity{}, &InputError{Field: "op", Msg: "use add|subtract|multiply|divide|convert"}
+	}
+	if op == "convert" && to == "" {
+		return units.Quantity{}, &InputError{Field: "to", Msg: "target unit is required"}
+	}
+
+	qa, err := s.parseQuantity("a", a)
+	if err != nil {
+		return units.Quantity{}, err
+	}
+	var qb units.Quantity
+	if UnitArity(op) == 2 {
+		if qb, err = s.parseQuantity("b", b); err != nil {
+			return units.Quantity{}, err
+		}
+	}
+	var target units.Unit
+	if to != "" {
+		if target, err = s.units.ParseUnit(to); err != nil {
+			return units.Quantity{}, unitInputError("to", err)
+		}
+	}
+
+	res, err := fn(qa, qb)
+	if err == nil && to != "" {
+		res, err = units.Convert(res, target)
+	}
+
+	entry := HistoryEntry{Op: op, Mode: ModeUnits, Quantities: []string{qa.String()}}
+	if UnitArity(op) == 2 {
+		entry.Quantities = append(entry.Quantities, qb.String())
+	}
+	if err == nil {
+		entry.Result, entry.Quantity = res.Value, res.String()
+	}
+	s.record(entry, err)
+	return res, err
+}
+
+func (s *calcSvc) parseQuantity(field, in string) (units.Quantity, error) {
+	if in == "" {
+		return units.Quantity{}, &InputError{Field: field, Msg: "quantity is required"}
+	}
+	q, err := s.units.ParseQuantity(in)
+	if err != nil {
+		return units.Quantity{}, unitInputError(field, err)
+	}
+	return q, nil
+}
+
+// unitInputError reports a parse failure against field. Unknown errors are
+// passed through unchanged.
+func unitInputError(field string, err error) error {
+	for _, known := range []error{units.ErrUnknownUnit, units.ErrSyntax, units.ErrOffsetUnit} {
+		if errors.Is(err, known) {
+			return &InputError{Field: field, Msg: known.Error()}
+		}
+	}
+	return err
+}
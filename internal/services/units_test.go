@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/units"
+)
+
+func TestCalculateUnits(t *testing.T) {
+	svc := NewCalculatorService()
+	res, err := svc.CalculateUnits("add", "5 km", "300 m", "")
+	if err != nil || res.String() != "5.3 km" {
+		t.Fatalf("5 km + 300 m = %v, %v", res, err)
+	}
+	res, err = svc.CalculateUnits("convert", "70 mph", "", "km/h")
+	if err != nil || res.String() != "112.65408 km/h" {
+		t.Fatalf("70 mph in km/h = %v, %v", res, err)
+	}
+	res, err = svc.CalculateUnits("divide", "3 km", "30 min", "km/h")
+	if err != nil || res.String() != "6 km/h" {
+		t.Fatalf("3 km / 30 min in km/h = %v, %v", res, err)
+	}
+
+	_, err = svc.CalculateUnits("add", "3 kg", "2 m", "")
+	var ce *calculator.Error
+	if !errors.As(err, &ce) || ce.Kind != calculator.KindDimension {
+		t.Fatalf("3 kg + 2 m error = %v", err)
+	}
+
+	h := svc.GetHistory(0)
+	if len(h) != 4 || h[0].Error == "" || h[0].Mode != ModeUnits {
+		t.Fatalf("history = %+v", h)
+	}
+	if e := h[3]; e.Quantity != "5.3 km" || e.Result != 5.3 || strings.Join(e.Quantities, ",") != "5 km,300 m" {
+		t.Fatalf("add entry = %+v", e)
+	}
+	if e := h[2]; len(e.Quantities) != 1 || e.Quantity != "112.65408 km/h" {
+		t.Fatalf("convert entry = %+v", e)
+	}
+	if rep := Replay(h, Tolerance{}); len(rep.Skipped) != 4 || !rep.OK() {
+		t.Fatalf("replay = %+v", rep)
+	}
+}
+
+func TestCalculateUnits_InputErrors(t *testing.T) {
+	svc := NewCalculatorService()
+	cases := []struct {
+		op, a, b, to, field string
+	}{
+		{"pow", "1 m", "2 m", "", "op"},
+		{"convert", "1 m", "", "", "to"},
+		{"add", "", "1 m", "", "a"},
+		{"add", "1 m", "1 parsec", "", "b"},
+		{"convert", "1 m", "", "m/", "to"},
+		{"multiply", "1 °C/s", "1 s", "", "a"},
+	}
+	for _, tc := range cases {
+		_, err := svc.CalculateUnits(tc.op, tc.a, tc.b, tc.to)
+		var ie *InputError
+		if !errors.As(err, &ie) || ie.Field != tc.field {
+			t.Fatalf("CalculateUnits(%q, %q, %q, %q) error = %v; want field %q", tc.op, tc.a, tc.b, tc.to, err, tc.field)
+		}
+	}
+	if h := svc.GetHistory(0); len(h) != 0 {
+		t.Fatalf("input errors were recorded: %+v", h)
+	}
+}
+
+func TestWithUnits(t *testing.T) {
+	reg := units.NewRegistry()
+	if err := reg.Load(strings.NewReader(`{"units": [{"symbol": "furlong", "factor": 201.168, "def": "m"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewCalculatorService(WithUnits(reg)).CalculateUnits("convert", "5 furlong", "", "m")
+	if err != nil || res.Value != 1005.84 {
+		t.Fatalf("5 furlong in m = %v, %v", res, err)
+	}
+	if _, err := NewCalculatorService().CalculateUnits("convert", "5 furlong", "", "m"); err == nil {
+		t.Fatal("custom unit leaked into the default table")
+	}
+}
@@ -0,0 +1,276 @@
+package linalg
+
+import (
+	"math"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// ErrSingular is returned when an operation needs an invertible matrix.
+var ErrSingular = &calculator.Error{Kind: calculator.KindCalculation, Field: "a", Msg: "matrix is singular"}
+
+func singular(op string) error {
+	err := *ErrSingular
+	err.Op = op
+	return &err
+}
+
+// LUResult is a decomposition P·A = L·U with L unit lower triangular, U upper
+// triangular and P a permutation matrix.
+type LUResult struct {
+	L Matrix `json:"l"`
+	U Matrix `json:"u"`
+	P Matrix `json:"p"`
+}
+
+// lu factors a in place with partial pivoting. perm[i] is the original row now
+// at row i and sign is the permutation's parity. It reports whether a pivot
+// was zero.
+func lu(a Matrix) (perm []int, sign float64, isSingular bool) {
+	n := len(a)
+	perm = make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	sign = 1
+	for k := 0; k < n; k++ {
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(a[i][k]) > math.Abs(a[p][k]) {
+				p = i
+			}
+		}
+		if a[p][k] == 0 {
+			isSingular = true
+			continue
+		}
+		if p != k {
+			a[p], a[k] = a[k], a[p]
+			perm[p], perm[k] = perm[k], perm[p]
+			sign = -sign
+		}
+		for i := k + 1; i < n; i++ {
+			a[i][k] /= a[k][k]
+			for j := k + 1; j < n; j++ {
+				a[i][j] -= a[i][k] * a[k][j]
+			}
+		}
+	}
+	return perm, sign, isSingular
+}
+
+// LU decomposes a square matrix with partial pivoting.
+func LU(a Matrix) (LUResult, error) {
+	n, err := square("lu", "a", a)
+	if err != nil {
+		return LUResult{}, err
+	}
+	f := a.clone()
+	perm, _, _ := lu(f)
+
+	res := LUResult{L: newMatrix(n, n), U: newMatrix(n, n), P: newMatrix(n, n)}
+	for i := 0; i < n; i++ {
+		res.P[i][perm[i]] = 1
+		for j := 0; j < n; j++ {
+			switch {
+			case j < i:
+				res.L[i][j] = f[i][j]
+			case j == i:
+				res.L[i][j] = 1
+				res.U[i][j] = f[i][j]
+			default:
+				res.U[i][j] = f[i][j]
+			}
+		}
+	}
+	if err := finiteMatrix("lu", res.L); err != nil {
+		return LUResult{}, err
+	}
+	return res, finiteMatrix("lu", res.U)
+}
+
+// Determinant returns det(a).
+func Determinant(a Matrix) (float64, error) {
+	n, err := square("determinant", "a", a)
+	if err != nil {
+		return 0, err
+	}
+	f := a.clone()
+	_, det, isSingular := lu(f)
+	if isSingular {
+		return 0, nil
+	}
+	for i := 0; i < n; i++ {
+		det *= f[i][i]
+	}
+	return det, finite("determinant", det)
+}
+
+// Solve returns x such that a·x = v.
+func Solve(a Matrix, v Vector) (Vector, error) {
+	n, err := square("solve", "a", a)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) != n {
+		return nil, dimensionError("solve", "v", "the length of v must match the rows of a")
+	}
+	f := a.clone()
+	perm, _, isSingular := lu(f)
+	if isSingular {
+		return nil, singular("solve")
+	}
+	x := solveLU(f, perm, v)
+	return x, finite("solve", x...)
+}
+
+// solveLU solves using the in-place factors from lu.
+func solveLU(f Matrix, perm []int, b Vector) Vector {
+	n := len(f)
+	x := make(Vector, n)
+	for i := 0; i < n; i++ {
+		x[i] = b[perm[i]]
+		for j := 0; j < i; j++ {
+			x[i] -= f[i][j] * x[j]
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := i + 1; j < n; j++ {
+			x[i] -= f[i][j] * x[j]
+		}
+		x[i] /= f[i][i]
+	}
+	return x
+}
+
+// Inverse returns a⁻¹.
+func Inverse(a Matrix) (Matrix, error) {
+	n, err := square("inverse", "a", a)
+	if err != nil {
+		return nil, err
+	}
+	f := a.clone()
+	perm, _, isSingular := lu(f)
+	if isSingular {
+		return nil, singular("inverse")
+	}
+	inv := newMatrix(n, n)
+	e := make(Vector, n)
+	for j := 0; j < n; j++ {
+		clear(e)
+		e[j] = 1
+		col := solveLU(f, perm, e)
+		for i := range col {
+			inv[i][j] = col[i]
+		}
+	}
+	return inv, finiteMatrix("inverse", inv)
+}
+
+// Rank returns the numerical rank of a, treating pivots below
+// max(rows, cols)·ε·max|aᵢⱼ| as zero.
+func Rank(a Matrix) (int, error) {
+	rows, cols, err := a.Dims("rank", "a")
+	if err != nil {
+		return 0, err
+	}
+	f := a.clone()
+	var maxAbs float64
+	for _, row := range f {
+		for _, v := range row {
+			maxAbs = math.Max(maxAbs, math.Abs(v))
+		}
+	}
+	tol := float64(max(rows, cols)) * 0x1p-52 * maxAbs
+
+	rank := 0
+	for c := 0; c < cols && rank < rows; c++ {
+		p := rank
+		for i := rank + 1; i < rows; i++ {
+			if math.Abs(f[i][c]) > math.Abs(f[p][c]) {
+				p = i
+			}
+		}
+		if math.Abs(f[p][c]) <= tol {
+			continue
+		}
+		f[p], f[rank] = f[rank], f[p]
+		for i := rank + 1; i < rows; i++ {
+			factor := f[i][c] / f[rank][c]
+			for j := c; j < cols; j++ {
+				f[i][j] -= factor * f[rank][j]
+			}
+		}
+		rank++
+	}
+	return rank, nil
+}
+
+// QRResult is a decomposition A = Q·R with Q orthogonal (m×m) and R upper
+// triangular (m×n).
+type QRResult struct {
+	Q Matrix `json:"q"`
+	R Matrix `json:"r"`
+}
+
+// QR decomposes a using Householder reflections.
+func QR(a Matrix) (QRResult, error) {
+	m, n, err := a.Dims("qr", "a")
+	if err != nil {
+		return QRResult{}, err
+	}
+	r := a.clone()
+	q := Identity(m)
+	v := make(Vector, m)
+
+	for k := 0; k < min(m-1, n); k++ {
+		var norm float64
+		for i := k; i < m; i++ {
+			norm = math.Hypot(norm, r[i][k])
+		}
+		if norm == 0 {
+			continue
+		}
+		alpha := -math.Copysign(norm, r[k][k])
+		clear(v)
+		for i := k; i < m; i++ {
+			v[i] = r[i][k]
+		}
+		v[k] -= alpha
+		var vv float64
+		for i := k; i < m; i++ {
+			vv += v[i] * v[i]
+		}
+		if vv == 0 {
+			continue
+		}
+		// R = H·R and Q = Q·H with H = I - 2vvᵀ/(vᵀv).
+		for j := 0; j < n; j++ {
+			var s float64
+			for i := k; i < m; i++ {
+				s += v[i] * r[i][j]
+			}
+			s *= 2 / vv
+			for i := k; i < m; i++ {
+				r[i][j] -= s * v[i]
+			}
+		}
+		for i := 0; i < m; i++ {
+			var s float64
+			for j := k; j < m; j++ {
+				s += q[i][j] * v[j]
+			}
+			s *= 2 / vv
+			for j := k; j < m; j++ {
+				q[i][j] -= s * v[j]
+			}
+		}
+		for i := k + 1; i < m; i++ {
+			r[i][k] = 0
+		}
+	}
+	if err := finiteMatrix("qr", q); err != nil {
+		return QRResult{}, err
+	}
+	return QRResult{Q: q, R: r}, finiteMatrix("qr", r)
+}
@@ -0,0 +1,196 @@
+package linalg
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b)) }
+
+func matrixNear(t *testing.T, name string, got, want Matrix) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v; want %v", name, got, want)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if !near(got[i][j], want[i][j]) {
+				t.Fatalf("%s = %v; want %v", name, got, want)
+			}
+		}
+	}
+}
+
+func kindOf(err error) calculator.Kind {
+	var ce *calculator.Error
+	if errors.As(err, &ce) {
+		return ce.Kind
+	}
+	return ""
+}
+
+func TestAddMultiplyTranspose(t *testing.T) {
+	a := Matrix{{1, 2, 3}, {4, 5, 6}}
+	sum, err := Add(a, Matrix{{1, 1, 1}, {1, 1, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrixNear(t, "Add", sum, Matrix{{2, 3, 4}, {5, 6, 7}})
+
+	at, _ := Transpose(a)
+	matrixNear(t, "Transpose", at, Matrix{{1, 4}, {2, 5}, {3, 6}})
+
+	prod, err := Multiply(a, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrixNear(t, "Multiply", prod, Matrix{{14, 32}, {32, 77}})
+
+	if _, err := Multiply(a, a); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("Multiply 2x3·2x3 error = %v", err)
+	}
+	if _, err := Add(a, at); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("Add 2x3+3x2 error = %v", err)
+	}
+	if _, err := Transpose(Matrix{{1, 2}, {3}}); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("ragged matrix error = %v", err)
+	}
+	if _, err := Multiply(Matrix{{1e200}}, Matrix{{1e200}}); kindOf(err) != calculator.KindOverflow {
+		t.Fatalf("overflow error = %v", err)
+	}
+}
+
+func TestDeterminantInverseSolve(t *testing.T) {
+	a := Matrix{{0, 2, 1}, {1, 1, 0}, {3, 0, 1}}
+	det, err := Determinant(a)
+	if err != nil || !near(det, -5) {
+		t.Fatalf("Determinant = %v, %v; want -5", det, err)
+	}
+
+	inv, err := Inverse(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := Multiply(a, inv)
+	matrixNear(t, "a·a⁻¹", id, Identity(3))
+
+	x, err := Solve(a, Vector{6, 3, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []float64{1, 2, 2} {
+		if !near(x[i], want) {
+			t.Fatalf("Solve = %v; want [1 2 2]", x)
+		}
+	}
+
+	sing := Matrix{{1, 2}, {2, 4}}
+	if det, err := Determinant(sing); err != nil || det != 0 {
+		t.Fatalf("singular Determinant = %v, %v", det, err)
+	}
+	if _, err := Inverse(sing); kindOf(err) != calculator.KindCalculation {
+		t.Fatalf("singular Inverse error = %v", err)
+	}
+	if _, err := Solve(sing, Vector{1}); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("Solve length mismatch error = %v", err)
+	}
+	if _, err := Determinant(Matrix{{1, 2}}); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("non-square Determinant error = %v", err)
+	}
+}
+
+func TestRank(t *testing.T) {
+	cases := []struct {
+		m    Matrix
+		want int
+	}{
+		{Matrix{{1, 2}, {2, 4}}, 1},
+		{Matrix{{1, 0, 0}, {0, 1, 0}}, 2},
+		{Matrix{{0, 0}, {0, 0}}, 0},
+		{Matrix{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}, 2},
+		{Identity(4), 4},
+	}
+	for _, tc := range cases {
+		if got, err := Rank(tc.m); err != nil || got != tc.want {
+			t.Errorf("Rank(%v) = %d, %v; want %d", tc.m, got, err, tc.want)
+		}
+	}
+}
+
+func TestLU(t *testing.T) {
+	a := Matrix{{2, 1, 1}, {4, -6, 0}, {-2, 7, 2}}
+	res, err := LU(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pa, _ := Multiply(res.P, a)
+	lu, _ := Multiply(res.L, res.U)
+	matrixNear(t, "P·A vs L·U", pa, lu)
+	for i := range res.L {
+		if res.L[i][i] != 1 {
+			t.Fatalf("L must be unit lower triangular: %v", res.L)
+		}
+		for j := 0; j < i; j++ {
+			if res.U[i][j] != 0 || res.L[j][i] != 0 {
+				t.Fatalf("L/U not triangular: %v %v", res.L, res.U)
+			}
+		}
+	}
+}
+
+func TestQR(t *testing.T) {
+	for _, a := range []Matrix{
+		{{12, -51, 4}, {6, 167, -68}, {-4, 24, -41}},
+		{{1, 2}, {3, 4}, {5, 6}},
+		{{1, 2, 3}, {4, 5, 6}},
+	} {
+		res, err := QR(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qr, _ := Multiply(res.Q, res.R)
+		matrixNear(t, "Q·R", qr, a)
+		qt, _ := Transpose(res.Q)
+		qtq, _ := Multiply(qt, res.Q)
+		matrixNear(t, "QᵀQ", qtq, Identity(len(a)))
+		for i := range res.R {
+			for j := 0; j < i && j < len(res.R[i]); j++ {
+				if res.R[i][j] != 0 {
+					t.Fatalf("R not upper triangular: %v", res.R)
+				}
+			}
+		}
+	}
+}
+
+func TestVectors(t *testing.T) {
+	if d, err := Dot(Vector{1, 2, 3}, Vector{4, 5, 6}); err != nil || d != 32 {
+		t.Fatalf("Dot = %v, %v", d, err)
+	}
+	if _, err := Dot(Vector{1}, Vector{1, 2}); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("Dot mismatch error = %v", err)
+	}
+	c, err := Cross(Vector{1, 0, 0}, Vector{0, 1, 0})
+	if err != nil || c[0] != 0 || c[1] != 0 || c[2] != 1 {
+		t.Fatalf("Cross = %v, %v", c, err)
+	}
+	if _, err := Cross(Vector{1, 2}, Vector{1, 2}); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("Cross 2-d error = %v", err)
+	}
+
+	v := Vector{3, -4}
+	for _, tc := range []struct{ p, want float64 }{{1, 7}, {2, 5}, {math.Inf(1), 4}} {
+		if got, err := Norm(v, tc.p); err != nil || got != tc.want {
+			t.Errorf("Norm(p=%v) = %v, %v; want %v", tc.p, got, err, tc.want)
+		}
+	}
+	if got, err := Norm(Vector{1e200, 1e200}, 2); err != nil || !near(got, math.Sqrt2*1e200) {
+		t.Fatalf("Norm must not overflow intermediate squares: %v, %v", got, err)
+	}
+	if _, err := Norm(v, 3); kindOf(err) != calculator.KindDomain {
+		t.Fatalf("Norm(p=3) error = %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+// Package linalg implements dense matrix and vector operations on float64.
+// Failures are reported as *calculator.Error so callers handle them like any
+// other calculation error.
+package linalg
+
+import (
+	"math"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// Matrix is a dense row-major matrix. Valid matrices are non-empty and
+// rectangular.
+type Matrix [][]float64
+
+// Vector is a dense vector.
+type Vector []float64
+
+func dimensionError(op, field, msg string) error {
+	return &calculator.Error{Kind: calculator.KindDimension, Op: op, Field: field, Msg: msg}
+}
+
+// Dims returns the number of rows and columns of m, or an error naming field
+// when m is empty or ragged.
+func (m Matrix) Dims(op, field string) (rows, cols int, err error) {
+	if len(m) == 0 || len(m[0]) == 0 {
+		return 0, 0, dimensionError(op, field, "matrix must not be empty")
+	}
+	for _, row := range m {
+		if len(row) != len(m[0]) {
+			return 0, 0, dimensionError(op, field, "matrix rows must all have the same length")
+		}
+	}
+	return len(m), len(m[0]), nil
+}
+
+func square(op, field string, m Matrix) (int, error) {
+	r, c, err := m.Dims(op, field)
+	if err != nil {
+		return 0, err
+	}
+	if r != c {
+		return 0, dimensionError(op, field, "matrix must be square")
+	}
+	return r, nil
+}
+
+func newMatrix(rows, cols int) Matrix {
+	data := make([]float64, rows*cols)
+	m := make(Matrix, rows)
+	for i := range m {
+		m[i] = data[i*cols : (i+1)*cols : (i+1)*cols]
+	}
+	return m
+}
+
+// Identity returns the n×n identity matrix.
+func Identity(n int) Matrix {
+	m := newMatrix(n, n)
+	for i := range m {
+		m[i][i] = 1
+	}
+	return m
+}
+
+func (m Matrix) clone() Matrix {
+	out := newMatrix(len(m), len(m[0]))
+	for i, row := range m {
+		copy(out[i], row)
+	}
+	return out
+}
+
+// finite rejects results that overflowed to ±Inf or NaN.
+func finite(op string, values ...float64) error {
+	for _, v := range values {
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return &calculator.Error{Kind: calculator.KindOverflow, Op: op, Msg: "result overflows the float64 range"}
+		}
+	}
+	return nil
+}
+
+func finiteMatrix(op string, m Matrix) error {
+	for _, row := range m {
+		if err := finite(op, row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add returns a+b.
+func Add(a, b Matrix) (Matrix, error) {
+	ar, ac, err := a.Dims("add", "a")
+	if err != nil {
+		return nil, err
+	}
+	br, bc, err := b.Dims("add", "b")
+	if err != nil {
+		return nil, err
+	}
+	if ar != br || ac != bc {
+		return nil, dimensionError("add", "b", "matrices must have the same dimensions")
+	}
+	out := newMatrix(ar, ac)
+	for i := range out {
+		for j := range out[i] {
+			out[i][j] = a[i][j] + b[i][j]
+		}
+	}
+	return out, finiteMatrix("add", out)
+}
+
+// Multiply returns the matrix product a·b.
+func Multiply(a, b Matrix) (Matrix, error) {
+	ar, ac, err := a.Dims("multiply", "a")
+	if err != nil {
+		return nil, err
+	}
+	br, bc, err := b.Dims("multiply", "b")
+	if err != nil {
+		return nil, err
+	}
+	if ac != br {
+		return nil, dimensionError("multiply", "b", "the rows of b must match the columns of a")
+	}
+	out := newMatrix(ar, bc)
+	for i := range out {
+		for k := 0; k < ac; k++ {
+			aik := a[i][k]
+			for j := range out[i] {
+				out[i][j] += aik * b[k][j]
+			}
+		}
+	}
+	return out, finiteMatrix("multiply", out)
+}
+
+// Transpose returns aᵀ.
+func Transpose(a Matrix) (Matrix, error) {
+	r, c, err := a.Dims("transpose", "a")
+	if err != nil {
+		return nil, err
+	}
+	out := newMatrix(c, r)
+	for i := range a {
+		for j, v := range a[i] {
+			out[j][i] = v
+		}
+	}
+	return out, nil
+}
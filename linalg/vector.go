@@ -0,0 +1,72 @@
+package linalg
+
+import (
+	"math"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func sameLength(op string, u, v Vector) error {
+	if len(u) == 0 {
+		return dimensionError(op, "u", "vector must not be empty")
+	}
+	if len(u) != len(v) {
+		return dimensionError(op, "v", "vectors must have the same length")
+	}
+	return nil
+}
+
+// Dot returns the inner product u·v.
+func Dot(u, v Vector) (float64, error) {
+	if err := sameLength("dot", u, v); err != nil {
+		return 0, err
+	}
+	var s float64
+	for i := range u {
+		s += u[i] * v[i]
+	}
+	return s, finite("dot", s)
+}
+
+// Cross returns the cross product u×v of two 3-vectors.
+func Cross(u, v Vector) (Vector, error) {
+	if len(u) != 3 {
+		return nil, dimensionError("cross", "u", "cross product needs 3-dimensional vectors")
+	}
+	if len(v) != 3 {
+		return nil, dimensionError("cross", "v", "cross product needs 3-dimensional vectors")
+	}
+	w := Vector{
+		u[1]*v[2] - u[2]*v[1],
+		u[2]*v[0] - u[0]*v[2],
+		u[0]*v[1] - u[1]*v[0],
+	}
+	return w, finite("cross", w...)
+}
+
+// Norm returns the p-norm of v for p = 1, 2 or +Inf (the maximum norm).
+func Norm(v Vector, p float64) (float64, error) {
+	if len(v) == 0 {
+		return 0, dimensionError("norm", "u", "vector must not be empty")
+	}
+	var s float64
+	switch p {
+	case 1:
+		for _, x := range v {
+			s += math.Abs(x)
+		}
+	case 2:
+		// Hypot avoids overflow of the intermediate squares.
+		for _, x := range v {
+			s = math.Hypot(s, x)
+		}
+	default:
+		if !math.IsInf(p, 1) {
+			return 0, &calculator.Error{Kind: calculator.KindDomain, Op: "norm", Field: "p", Msg: "norm order must be 1, 2 or inf"}
+		}
+		for _, x := range v {
+			s = math.Max(s, math.Abs(x))
+		}
+	}
+	return s, finite("norm", s)
+}
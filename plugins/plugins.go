@@ -0,0 +1,382 @@
+// Package plugins registers calculator operations implemented as WebAssembly
+// modules. A Loader reads every *.wasm file in a directory, runs the modules
+// in the sandboxed interpreter of package wasm and keeps the registry in step
+// with the files as they are added, changed and removed.
+//
+// A module describes its operations in an optional custom section named
+// "calculator" holding JSON:
+//
+//	{"operations": [
+//	  {"export": "hypot", "name": "hypot", "aliases": ["pythag"],
+//	   "doc": "Length of the hypotenuse.", "commutative": true,
+//	   "operands": ["number", "number"]}
+//	]}
+//
+// Only export is required; name defaults to it and operands, when given,
+// must agree with the function's signature. Without the section every
+// exported function is registered under its export name.
+//
+// Exported functions take one or two parameters and return one result, each
+// of type i32, i64, f32 or f64. Integer parameters make integer operands and
+// integer values are signed.
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/internal/poll"
+	"erikkruuse/calculator/wasm"
+)
+
+// MetadataSection is the name of the custom section describing a module's
+// operations.
+const MetadataSection = "calculator"
+
+// ErrWatchInterval is returned by Watch for an interval that is not positive.
+var ErrWatchInterval = poll.ErrInterval
+
+// maxModuleSize bounds the size of a module file.
+const maxModuleSize = 16 << 20
+
+// Plugin describes a loaded module file.
+type Plugin struct {
+	File       string    // base name in the plugins directory
+	Operations []string  // names of the operations it registered
+	LoadedAt   time.Time // when the current version was loaded
+}
+
+// Loader keeps the operations of the modules in a directory registered. Each
+// call of an operation runs in a fresh instance, so calls cannot influence
+// one another.
+type Loader struct {
+	dir    string
+	reg    *calculator.Registry
+	limits wasm.Limits
+
+	mu    sync.Mutex // serializes reloads
+	files map[string]*file
+
+	watcher *poll.Poller
+}
+
+// file is the state of a module file that loaded successfully.
+type file struct {
+	modTime  time.Time
+	size     int64
+	ops      []string
+	loadedAt time.Time
+}
+
+// Open loads the modules in dir and registers their operations in reg. Each
+// call of an operation is bounded by limits. If any module fails to load,
+// Open registers nothing and returns the errors.
+func Open(dir string, reg *calculator.Registry, limits wasm.Limits) (*Loader, error) {
+	l := &Loader{dir: dir, reg: reg, limits: limits, files: map[string]*file{}}
+	if _, err := l.Reload(); err != nil {
+		l.unload()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload loads the module files that were added or changed since the last
+// reload and unregisters the operations of removed files. It reports whether
+// the registry changed. A module that fails to load keeps the operations of
+// its previous version and is retried on the next reload; the errors of all
+// failing files are joined.
+func (l *Loader) Reload() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := os.Stat(l.dir); err != nil {
+		return false, err
+	}
+	paths, err := filepath.Glob(filepath.Join(l.dir, "*.wasm"))
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	var errs []error
+	seen := map[string]bool{}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		seen[name] = true
+		fi, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		old := l.files[name]
+		if old != nil && fi.ModTime().Equal(old.modTime) && fi.Size() == old.size {
+			continue
+		}
+		f, err := l.load(path, fi, old)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", name, err))
+			continue
+		}
+		l.files[name] = f
+		changed = true
+	}
+	for name, f := range l.files {
+		if seen[name] {
+			continue
+		}
+		if err := l.reg.Replace(f.ops); err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", name, err))
+			continue
+		}
+		delete(l.files, name)
+		changed = true
+	}
+	return changed, errors.Join(errs...)
+}
+
+// load compiles the module at path and swaps its operations for those of
+// the previous version, old, if any.
+func (l *Loader) load(path string, fi os.FileInfo, old *file) (*file, error) {
+	if fi.Size() > maxModuleSize {
+		return nil, fmt.Errorf("module is larger than %d bytes", maxModuleSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := l.operations(data)
+	if err != nil {
+		return nil, err
+	}
+	var remove []string
+	if old != nil {
+		remove = old.ops
+	}
+	if err := l.reg.Replace(remove, ops...); err != nil {
+		return nil, err
+	}
+	f := &file{modTime: fi.ModTime(), size: fi.Size(), loadedAt: time.Now()}
+	for _, op := range ops {
+		f.ops = append(f.ops, op.Name)
+	}
+	return f, nil
+}
+
+// unload unregisters every loaded operation.
+func (l *Loader) unload() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var names []string
+	for _, f := range l.files {
+		names = append(names, f.ops...)
+	}
+	if l.reg.Replace(names) == nil {
+		clear(l.files)
+	}
+}
+
+// Plugins returns the loaded module files, sorted by name.
+func (l *Loader) Plugins() []Plugin {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	plugins := make([]Plugin, 0, len(l.files))
+	for name, f := range l.files {
+		plugins = append(plugins, Plugin{File: name, Operations: slices.Clone(f.ops), LoadedAt: f.loadedAt})
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].File < plugins[j].File })
+	return plugins
+}
+
+// Watch polls the directory every interval and reloads it when it changes.
+// Reload errors are passed to onError, if set. Close stops the watcher. An
+// interval that is not positive is rejected with ErrWatchInterval.
+func (l *Loader) Watch(every time.Duration, onError func(error)) error {
+	w, err := poll.Start(every, func() {
+		if _, err := l.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	l.watcher = w
+	return nil
+}
+
+// Close stops the watcher and waits for it to exit. The operations stay
+// registered. It is safe to call more than once.
+func (l *Loader) Close() {
+	l.watcher.Stop()
+}
+
+// metadata is the contents of the metadata section.
+type metadata struct {
+	Operations []spec `json:"operations"`
+}
+
+type spec struct {
+	Export      string                   `json:"export"`
+	Name        string                   `json:"name"`
+	Aliases     []string                 `json:"aliases"`
+	Doc         string                   `json:"doc"`
+	Commutative bool                     `json:"commutative"`
+	Operands    []calculator.OperandType `json:"operands"`
+}
+
+// operations compiles a module and returns the operations it provides.
+func (l *Loader) operations(data []byte) ([]calculator.Operation, error) {
+	m, err := wasm.Compile(data)
+	if err != nil {
+		return nil, err
+	}
+	// Instantiate once so that modules whose memory exceeds the limits or
+	// whose start function traps are rejected now rather than on every call.
+	if _, err := m.Instantiate(l.limits); err != nil {
+		return nil, err
+	}
+
+	var meta metadata
+	if b, ok := m.CustomSection(MetadataSection); ok {
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return nil, fmt.Errorf("%s section: %w", MetadataSection, err)
+		}
+		if len(meta.Operations) == 0 {
+			return nil, fmt.Errorf("%s section declares no operations", MetadataSection)
+		}
+	} else {
+		for _, name := range m.ExportedFuncs() {
+			meta.Operations = append(meta.Operations, spec{Export: name})
+		}
+	}
+
+	ops := make([]calculator.Operation, 0, len(meta.Operations))
+	for _, s := range meta.Operations {
+		sig, ok := m.ExportedFunc(s.Export)
+		if !ok {
+			return nil, fmt.Errorf("no exported function %q", s.Export)
+		}
+		operands, err := operandTypes(sig)
+		if err != nil {
+			return nil, fmt.Errorf("function %q: %w", s.Export, err)
+		}
+		if s.Operands != nil && !slices.Equal(s.Operands, operands) {
+			return nil, fmt.Errorf("function %q: declared operands %v do not match its signature %v", s.Export, s.Operands, operands)
+		}
+		if s.Name == "" {
+			s.Name = s.Export
+		}
+		ops = append(ops, calculator.Operation{
+			Name:        s.Name,
+			Aliases:     s.Aliases,
+			Operands:    operands,
+			Doc:         s.Doc,
+			Commutative: s.Commutative,
+			Fn:          l.fn(m, s.Name, s.Export, sig),
+		})
+	}
+	return ops, nil
+}
+
+// operandTypes maps a function signature to operand types.
+func operandTypes(sig wasm.FuncType) ([]calculator.OperandType, error) {
+	if n := len(sig.Params); n != 1 && n != 2 {
+		return nil, fmt.Errorf("must take 1 or 2 parameters, takes %d", n)
+	}
+	if len(sig.Results) != 1 {
+		return nil, fmt.Errorf("must return 1 result, returns %d", len(sig.Results))
+	}
+	operands := make([]calculator.OperandType, len(sig.Params))
+	for i, t := range append(slices.Clone(sig.Params), sig.Results[0]) {
+		var typ calculator.OperandType
+		switch t {
+		case wasm.I32, wasm.I64:
+			typ = calculator.TypeInteger
+		case wasm.F32, wasm.F64:
+			typ = calculator.TypeNumber
+		default:
+			return nil, fmt.Errorf("unsupported value type %s", t)
+		}
+		if i < len(operands) {
+			operands[i] = typ
+		}
+	}
+	return operands, nil
+}
+
+// fn returns the implementation of operation name, which calls export.
+func (l *Loader) fn(m *wasm.Module, name, export string, sig wasm.FuncType) func(a, b float64) (float64, error) {
+	return func(a, b float64) (float64, error) {
+		args := make([]uint64, len(sig.Params))
+		for i, t := range sig.Params {
+			v, field := a, "a"
+			if i == 1 {
+				v, field = b, "b"
+			}
+			arg, ok := toValue(t, v)
+			if !ok {
+				return 0, &calculator.Error{Kind: calculator.KindDomain, Op: name, Field: field,
+					Msg: fmt.Sprintf("%s is out of range for %s", field, t)}
+			}
+			args[i] = arg
+		}
+		in, err := m.Instantiate(l.limits)
+		if err != nil {
+			return 0, failed(name, err)
+		}
+		res, err := in.Call(export, args...)
+		if err != nil {
+			return 0, failed(name, err)
+		}
+		return fromValue(sig.Results[0], res[0]), nil
+	}
+}
+
+func failed(op string, err error) error {
+	msg := strings.TrimPrefix(err.Error(), "wasm: ")
+	return &calculator.Error{Kind: calculator.KindCalculation, Op: op, Msg: "plugin failed: " + msg}
+}
+
+// toValue converts an operand to a value of type t. Integer types reject
+// fractional and out of range operands.
+func toValue(t wasm.ValueType, v float64) (uint64, bool) {
+	switch t {
+	case wasm.I32:
+		if v != math.Trunc(v) || v < math.MinInt32 || v > math.MaxInt32 {
+			return 0, false
+		}
+		return uint64(uint32(int32(v))), true
+	case wasm.I64:
+		if v != math.Trunc(v) || v < -(1<<63) || v >= 1<<63 {
+			return 0, false
+		}
+		return uint64(int64(v)), true
+	case wasm.F32:
+		return uint64(math.Float32bits(float32(v))), true
+	default:
+		return math.Float64bits(v), true
+	}
+}
+
+// fromValue converts a result of type t to a float64.
+func fromValue(t wasm.ValueType, v uint64) float64 {
+	switch t {
+	case wasm.I32:
+		return float64(int32(uint32(v)))
+	case wasm.I64:
+		return float64(int64(v))
+	case wasm.F32:
+		return float64(math.Float32frombits(uint32(v)))
+	default:
+		return math.Float64frombits(v)
+	}
+}
@@ -0,0 +1,266 @@
+package plugins
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/wasm"
+)
+
+// The modules in testdata:
+//
+//	hypot.wasm    hypot(f64, f64) and half(f64), described by a metadata section
+//	integer.wasm  gcd(i64, i64) and idiv(i32, i32) (div_s), without metadata
+//	spin.wasm     spin(f64), which loops forever
+func readModule(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// pluginDir returns a directory holding copies of the named test modules.
+func pluginDir(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), readModule(t, name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestOpen(t *testing.T) {
+	reg := calculator.NewRegistry()
+	l, err := Open(pluginDir(t, "hypot.wasm", "integer.wasm", "spin.wasm"), reg, wasm.Limits{Fuel: 10_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	hypot, ok := reg.Lookup("PYTHAG")
+	if !ok || hypot.Name != "hypot" || !hypot.Commutative || hypot.Arity() != 2 || hypot.Doc == "" {
+		t.Fatalf("hypot = %+v, %v", hypot, ok)
+	}
+	gcd, _ := reg.Lookup("gcd")
+	if !slices.Equal(gcd.Operands, []calculator.OperandType{calculator.TypeInteger, calculator.TypeInteger}) {
+		t.Fatalf("gcd operands = %v", gcd.Operands)
+	}
+	half, _ := reg.Lookup("half")
+	if half.Arity() != 1 {
+		t.Fatalf("half arity = %d", half.Arity())
+	}
+
+	var files []string
+	for _, p := range l.Plugins() {
+		files = append(files, p.File+":"+strings.Join(p.Operations, ","))
+	}
+	if want := []string{"hypot.wasm:hypot,half", "integer.wasm:gcd,idiv", "spin.wasm:spin"}; !slices.Equal(files, want) {
+		t.Fatalf("plugins = %v, want %v", files, want)
+	}
+}
+
+func TestOperations(t *testing.T) {
+	reg := calculator.NewRegistry()
+	if _, err := Open(pluginDir(t, "hypot.wasm", "integer.wasm", "spin.wasm"), reg, wasm.Limits{Fuel: 10_000}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		op      string
+		a, b    float64
+		want    float64
+		kind    calculator.Kind
+		field   string
+		message string
+	}{
+		{op: "hypot", a: 3, b: 4, want: 5},
+		{op: "half", a: -7, want: -3.5},
+		{op: "gcd", a: 84, b: 36, want: 12},
+		{op: "gcd", a: -1 << 40, b: 1 << 20, want: 1 << 20},
+		{op: "idiv", a: -7, b: 2, want: -3},
+		{op: "idiv", a: 1, b: 0, kind: calculator.KindCalculation, message: "plugin failed: trap: integer divide by zero"},
+		{op: "idiv", a: math.MinInt32, b: -1, kind: calculator.KindCalculation, message: "plugin failed: trap: integer overflow"},
+		{op: "idiv", a: 1 << 31, b: 1, kind: calculator.KindDomain, field: "a", message: "a is out of range for i32"},
+		{op: "idiv", a: 1, b: 0.5, kind: calculator.KindDomain, field: "b", message: "b is out of range for i32"},
+		{op: "spin", a: 1, kind: calculator.KindCalculation, message: "plugin failed: trap: instruction limit exceeded"},
+	}
+	for _, tt := range tests {
+		op, ok := reg.Lookup(tt.op)
+		if !ok {
+			t.Fatalf("%s not registered", tt.op)
+		}
+		got, err := op.Fn(tt.a, tt.b)
+		if tt.kind == "" {
+			if err != nil || got != tt.want {
+				t.Errorf("%s(%v, %v) = %v, %v; want %v", tt.op, tt.a, tt.b, got, err, tt.want)
+			}
+			continue
+		}
+		var ce *calculator.Error
+		if !errors.As(err, &ce) || ce.Kind != tt.kind || ce.Field != tt.field || ce.Msg != tt.message || ce.Op != tt.op {
+			t.Errorf("%s(%v, %v) error = %#v, want %s %q", tt.op, tt.a, tt.b, err, tt.kind, tt.message)
+		}
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"garbage", []byte("not a module"), "invalid module"},
+		{"empty", nil, "invalid module"},
+		{"bad metadata", brokenMetadata(t), "calculator section"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := pluginDir(t, "integer.wasm")
+			if err := os.WriteFile(filepath.Join(dir, "bad.wasm"), tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			reg := calculator.NewRegistry()
+			_, err := Open(dir, reg, wasm.Limits{})
+			if err == nil || !strings.Contains(err.Error(), "plugin bad.wasm") || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+			if _, ok := reg.Lookup("gcd"); ok {
+				t.Fatal("failed Open left operations registered")
+			}
+		})
+	}
+
+	if _, err := Open(filepath.Join(t.TempDir(), "missing"), calculator.NewRegistry(), wasm.Limits{}); err == nil {
+		t.Fatal("missing directory accepted")
+	}
+}
+
+// brokenMetadata returns hypot.wasm with the closing brace of its metadata,
+// the last byte of the module, blanked out.
+func brokenMetadata(t *testing.T) []byte {
+	t.Helper()
+	b := readModule(t, "hypot.wasm")
+	b[len(b)-1] = ' '
+	return b
+}
+
+func TestReload(t *testing.T) {
+	dir := pluginDir(t, "hypot.wasm")
+	reg := calculator.NewRegistry()
+	l, err := Open(dir, reg, wasm.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := reg.Lookup("hypot")
+
+	if changed, err := l.Reload(); changed || err != nil {
+		t.Fatalf("unchanged directory reloaded: %v, %v", changed, err)
+	}
+
+	// A new file adds its operations.
+	if err := os.WriteFile(filepath.Join(dir, "integer.wasm"), readModule(t, "integer.wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := l.Reload(); !changed || err != nil {
+		t.Fatalf("new file not loaded: %v, %v", changed, err)
+	}
+	if _, ok := reg.Lookup("gcd"); !ok {
+		t.Fatal("gcd not registered")
+	}
+
+	// Rewriting a module replaces its operations, even under the same name.
+	path := filepath.Join(dir, "hypot.wasm")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := l.Reload(); !changed || err != nil {
+		t.Fatalf("changed file not reloaded: %v, %v", changed, err)
+	}
+	second, _ := reg.Lookup("hypot")
+	if second.ID() == first.ID() {
+		t.Fatal("reloaded operation kept its ID")
+	}
+
+	// A broken version keeps the previous operations and is retried.
+	if err := os.WriteFile(path, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := l.Reload(); err == nil {
+			t.Fatal("broken module accepted")
+		}
+		if op, ok := reg.Lookup("hypot"); !ok || op.ID() != second.ID() {
+			t.Fatal("broken module replaced the operations")
+		}
+	}
+
+	// Operations conflicting with another module are rejected.
+	if err := os.WriteFile(path, readModule(t, "hypot.wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "copy.wasm"), readModule(t, "hypot.wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Reload(); err == nil || !strings.Contains(err.Error(), "plugin copy.wasm") || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("conflict error = %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "copy.wasm")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing a file unregisters its operations.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := l.Reload(); !changed || err != nil {
+		t.Fatalf("removed file not unloaded: %v, %v", changed, err)
+	}
+	if _, ok := reg.Lookup("pythag"); ok {
+		t.Fatal("removed operation still registered")
+	}
+	if got := len(l.Plugins()); got != 1 {
+		t.Fatalf("%d plugins loaded, want 1", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := pluginDir(t)
+	reg := calculator.NewRegistry()
+	l, err := Open(dir, reg, wasm.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Watch(0, nil); !errors.Is(err, ErrWatchInterval) {
+		t.Fatalf("Watch(0) = %v; want ErrWatchInterval", err)
+	}
+	if err := l.Watch(5*time.Millisecond, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	os.WriteFile(filepath.Join(dir, "integer.wasm"), readModule(t, "integer.wasm"), 0o644)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := reg.Lookup("gcd"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("watcher did not pick up the new module")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	l.Close()
+	l.Close()
+}
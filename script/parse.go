@@ -0,0 +1,588 @@
+// Package script runs small calculator programs. A script is a sequence of
+// statements separated by newlines or semicolons:
+//
+//	# compound interest, one year at a time
+//	balance = 1000
+//	for year = 1 to 10 {
+//	  balance = balance * 1.05
+//	  if balance > 1500 { bonus = balance - 1500 }
+//	}
+//	balance - 1000
+//
+// Statements are assignments, expressions, if (with else and else if) and
+// for loops over a counted range, "for i = from to to [step s] { … }", whose
+// bounds are evaluated once. As in Go, else goes on the line of the closing
+// brace before it. There are no other loops, so every script terminates;
+// Limits bound how long it may take.
+//
+// Values are float64. The arithmetic operators + - * / and function calls
+// are performed by an Ops implementation, such as the calculator's
+// operation registry, and each of them is a primitive operation recorded in
+// the trace. Negation, the comparisons < <= > >= == != (which give 1 or 0)
+// and the logical operators and, or and not (which treat 0 as false) are
+// evaluated by the interpreter itself.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on parser input, so a request cannot exhaust the stack or memory.
+const (
+	MaxLength = 64 << 10
+	maxDepth  = 100
+)
+
+// SyntaxError reports malformed input and the position, counted in
+// characters from 1, where parsing stopped.
+type SyntaxError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d", e.Msg, e.Line, e.Column)
+}
+
+// Program is a parsed script. It can be run any number of times, also
+// concurrently.
+type Program struct {
+	body []stmt
+}
+
+type stmt interface{ line() int }
+
+type (
+	// assign is "name = x".
+	assign struct {
+		ln   int
+		name string
+		x    node
+	}
+	// exprStmt is an expression evaluated for its value.
+	exprStmt struct {
+		ln int
+		x  node
+	}
+	// ifStmt runs then if cond is true and els otherwise. An else if is an
+	// ifStmt alone in els.
+	ifStmt struct {
+		ln        int
+		cond      node
+		then, els []stmt
+	}
+	// forStmt runs body for name = from, from+step, … up to and including to.
+	forStmt struct {
+		ln             int
+		name           string
+		from, to, step node // step is nil for 1
+		body           []stmt
+	}
+)
+
+func (s *assign) line() int   { return s.ln }
+func (s *exprStmt) line() int { return s.ln }
+func (s *ifStmt) line() int   { return s.ln }
+func (s *forStmt) line() int  { return s.ln }
+
+// node is an expression: *num, *ident, *unary, *binary or *call.
+type node interface{}
+
+type (
+	num   struct{ v float64 }
+	ident struct {
+		ln   int
+		name string
+	}
+	// unary is "-x" or "not x".
+	unary struct {
+		op string
+		x  node
+	}
+	// binary is a comparison or a logical operator.
+	binary struct {
+		op   string
+		l, r node
+	}
+	// call is a function call or an arithmetic operator, which calls add,
+	// subtract, multiply or divide.
+	call struct {
+		ln   int
+		name string
+		args []node
+	}
+)
+
+// arithmetic maps the arithmetic operators to the operations performing them.
+var arithmetic = map[string]string{"+": "add", "-": "subtract", "*": "multiply", "/": "divide"}
+
+var keywords = map[string]bool{
+	"if": true, "else": true, "for": true, "to": true, "step": true,
+	"and": true, "or": true, "not": true,
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNewline
+	tokNum
+	tokIdent
+	tokKeyword
+	tokSymbol
+)
+
+type token struct {
+	kind      tokKind
+	text      string
+	line, col int
+}
+
+// unexpected is the message for t where it does not belong, written out
+// whole so the catalogs can translate it.
+func (t token) unexpected() string {
+	switch t.kind {
+	case tokEOF:
+		return "unexpected end of script"
+	case tokNewline:
+		return "unexpected end of line"
+	case tokNum:
+		return "unexpected number"
+	case tokIdent:
+		return "unexpected name"
+	case tokKeyword:
+		return "unexpected keyword"
+	}
+	return "unexpected symbol"
+}
+
+// symbols lists the operators and punctuation, two-character ones first.
+var symbols = []string{"==", "!=", "<=", ">=", "+", "-", "*", "/", "<", ">", "=", "(", ")", "{", "}", ",", ";"}
+
+// lex splits src into tokens. Newlines inside parentheses are dropped, so a
+// long call can span lines.
+func lex(src string) ([]token, error) {
+	var toks []token
+	line, col, parens := 1, 1, 0
+	for i := 0; i < len(src); {
+		r, w := utf8.DecodeRuneInString(src[i:])
+		t := token{line: line, col: col}
+		size := w
+		switch {
+		case r == '\n':
+			line, col = line+1, 0
+			if parens == 0 {
+				t.kind, t.text = tokNewline, "\n"
+			}
+		case unicode.IsSpace(r):
+		case r == '#':
+			size = strings.IndexByte(src[i:], '\n')
+			if size < 0 {
+				size = len(src) - i
+			}
+		case r >= '0' && r <= '9' || r == '.':
+			t.kind, t.text = tokNum, scanNumber(src[i:])
+			size = len(t.text)
+		case unicode.IsLetter(r) || r == '_':
+			size = strings.IndexFunc(src[i:], func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+			})
+			if size < 0 {
+				size = len(src) - i
+			}
+			t.kind, t.text = tokIdent, src[i:i+size]
+			if keywords[t.text] {
+				t.kind = tokKeyword
+			}
+		default:
+			for _, s := range symbols {
+				if strings.HasPrefix(src[i:], s) {
+					t.kind, t.text, size = tokSymbol, s, len(s)
+					break
+				}
+			}
+			if t.text == "" {
+				return nil, &SyntaxError{line, col, "unexpected character"}
+			}
+			switch t.text {
+			case "(":
+				parens++
+			case ")":
+				parens = max(parens-1, 0)
+			}
+		}
+		if t.text != "" {
+			toks = append(toks, t)
+		}
+		col += utf8.RuneCountInString(src[i : i+size])
+		i += size
+	}
+	return append(toks, token{kind: tokEOF, line: line, col: col}), nil
+}
+
+// scanNumber returns the longest numeric literal at the start of s.
+func scanNumber(s string) string {
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			i = j
+		}
+	}
+	return s[:i]
+}
+
+// Parse reads a script.
+func Parse(src string) (*Program, error) {
+	if len(src) > MaxLength {
+		return nil, &SyntaxError{1, 1, "script is too long"}
+	}
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	body, err := p.stmts(tokEOF)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, &SyntaxError{1, 1, "script is empty"}
+	}
+	return &Program{body: body}, nil
+}
+
+type parser struct {
+	toks  []token
+	pos   int
+	depth int
+}
+
+func (p *parser) tok() token  { return p.toks[p.pos] }
+func (p *parser) peek() token { return p.toks[min(p.pos+1, len(p.toks)-1)] }
+func (p *parser) next()       { p.pos = min(p.pos+1, len(p.toks)-1) }
+
+func (p *parser) is(kind tokKind, text string) bool {
+	t := p.tok()
+	return t.kind == kind && t.text == text
+}
+
+func (p *parser) fail(msg string) error {
+	t := p.tok()
+	return &SyntaxError{t.line, t.col, msg}
+}
+
+func (p *parser) unexpected() error { return p.fail(p.tok().unexpected()) }
+
+// expect consumes the symbol s or fails with msg.
+func (p *parser) expect(s, msg string) error {
+	if !p.is(tokSymbol, s) {
+		return p.fail(msg)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) enter() error {
+	if p.depth++; p.depth > maxDepth {
+		return p.fail("script is nested too deeply")
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+// stmts = {stmt (newline | ";")} up to end, which is tokEOF or "}".
+func (p *parser) stmts(end tokKind) ([]stmt, error) {
+	var list []stmt
+	for {
+		for p.tok().kind == tokNewline || p.is(tokSymbol, ";") {
+			p.next()
+		}
+		if t := p.tok(); t.kind == tokEOF || end == tokSymbol && p.is(tokSymbol, "}") {
+			if t.kind != end {
+				return nil, p.fail("missing closing brace")
+			}
+			return list, nil
+		}
+		s, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+		if t := p.tok(); t.kind != tokNewline && t.kind != tokEOF && !p.is(tokSymbol, ";") && !p.is(tokSymbol, "}") {
+			return nil, p.fail("statements must be separated by a newline or ;")
+		}
+	}
+}
+
+// block = "{" stmts "}"
+func (p *parser) block() ([]stmt, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if err := p.expect("{", "expected {"); err != nil {
+		return nil, err
+	}
+	body, err := p.stmts(tokSymbol)
+	if err != nil {
+		return nil, err
+	}
+	p.next() // }
+	return body, nil
+}
+
+// stmt = name "=" expr | if | for | expr
+func (p *parser) stmt() (stmt, error) {
+	t := p.tok()
+	switch {
+	case t.kind == tokIdent && p.peek().kind == tokSymbol && p.peek().text == "=":
+		p.next()
+		p.next()
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &assign{t.line, t.text, x}, nil
+	case t.kind == tokKeyword && t.text == "if":
+		return p.ifStmt()
+	case t.kind == tokKeyword && t.text == "for":
+		return p.forStmt()
+	}
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &exprStmt{t.line, x}, nil
+}
+
+// if = "if" expr block ["else" (if | block)]
+func (p *parser) ifStmt() (stmt, error) {
+	ln := p.tok().line
+	p.next()
+	cond, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	s := &ifStmt{ln: ln, cond: cond}
+	if s.then, err = p.block(); err != nil {
+		return nil, err
+	}
+	if !p.is(tokKeyword, "else") {
+		return s, nil
+	}
+	p.next()
+	if p.is(tokKeyword, "if") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		elif, err := p.ifStmt()
+		if err != nil {
+			return nil, err
+		}
+		s.els = []stmt{elif}
+		return s, nil
+	}
+	s.els, err = p.block()
+	return s, err
+}
+
+// for = "for" name "=" expr "to" expr ["step" expr] block
+func (p *parser) forStmt() (stmt, error) {
+	ln := p.tok().line
+	p.next()
+	if p.tok().kind != tokIdent {
+		return nil, p.fail("expected a variable name")
+	}
+	s := &forStmt{ln: ln, name: p.tok().text}
+	p.next()
+	if err := p.expect("=", "expected = after the loop variable"); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.from, err = p.expr(); err != nil {
+		return nil, err
+	}
+	if !p.is(tokKeyword, "to") {
+		return nil, p.fail("expected to")
+	}
+	p.next()
+	if s.to, err = p.expr(); err != nil {
+		return nil, err
+	}
+	if p.is(tokKeyword, "step") {
+		p.next()
+		if s.step, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	s.body, err = p.block()
+	return s, err
+}
+
+// expr = and {"or" and}
+func (p *parser) expr() (node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	return p.logical("or", p.and)
+}
+
+// and = not {"and" not}
+func (p *parser) and() (node, error) { return p.logical("and", p.not) }
+
+func (p *parser) logical(op string, operand func() (node, error)) (node, error) {
+	n, err := operand()
+	for err == nil && p.is(tokKeyword, op) {
+		p.next()
+		var r node
+		if r, err = operand(); err == nil {
+			n = &binary{op, n, r}
+		}
+	}
+	return n, err
+}
+
+// not = "not" not | comparison
+func (p *parser) not() (node, error) {
+	if !p.is(tokKeyword, "not") {
+		return p.comparison()
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	p.next()
+	x, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	return &unary{"not", x}, nil
+}
+
+// comparison = sum [("<" | "<=" | ">" | ">=" | "==" | "!=") sum]
+func (p *parser) comparison() (node, error) {
+	l, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	t := p.tok()
+	switch {
+	case t.kind != tokSymbol:
+		return l, nil
+	case t.text == "<", t.text == "<=", t.text == ">", t.text == ">=", t.text == "==", t.text == "!=":
+		p.next()
+		r, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		return &binary{t.text, l, r}, nil
+	}
+	return l, nil
+}
+
+// sum = product {("+" | "-") product}
+func (p *parser) sum() (node, error) { return p.arithmetic("+-", p.product) }
+
+// product = neg {("*" | "/") neg}
+func (p *parser) product() (node, error) { return p.arithmetic("*/", p.neg) }
+
+func (p *parser) arithmetic(ops string, operand func() (node, error)) (node, error) {
+	n, err := operand()
+	for err == nil && p.tok().kind == tokSymbol && len(p.tok().text) == 1 && strings.Contains(ops, p.tok().text) {
+		t := p.tok()
+		p.next()
+		var r node
+		if r, err = operand(); err == nil {
+			n = &call{t.line, arithmetic[t.text], []node{n, r}}
+		}
+	}
+	return n, err
+}
+
+// neg = "-" neg | atom
+func (p *parser) neg() (node, error) {
+	if !p.is(tokSymbol, "-") {
+		return p.atom()
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	p.next()
+	x, err := p.neg()
+	if err != nil {
+		return nil, err
+	}
+	return &unary{"-", x}, nil
+}
+
+// atom = number | name | name "(" [expr {"," expr}] ")" | "(" expr ")"
+func (p *parser) atom() (node, error) {
+	t := p.tok()
+	switch {
+	case t.kind == tokNum:
+		// Literals too small for float64 round to zero; too large ones fail.
+		v, err := strconv.ParseFloat(t.text, 64)
+		switch {
+		case err != nil && !errors.Is(err, strconv.ErrRange):
+			return nil, p.fail("malformed number")
+		case math.IsInf(v, 0):
+			return nil, p.fail("number is out of range")
+		}
+		p.next()
+		return &num{v}, nil
+
+	case t.kind == tokIdent:
+		p.next()
+		if !p.is(tokSymbol, "(") {
+			return &ident{t.line, t.text}, nil
+		}
+		p.next()
+		c := &call{ln: t.line, name: t.text}
+		for !p.is(tokSymbol, ")") {
+			if len(c.args) > 0 {
+				if err := p.expect(",", "missing closing parenthesis"); err != nil {
+					return nil, err
+				}
+			}
+			a, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, a)
+		}
+		p.next()
+		return c, nil
+
+	case t.kind == tokSymbol && t.text == "(":
+		p.next()
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")", "missing closing parenthesis"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,64 @@
+package script
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	valid := []string{
+		"1",
+		"x = 1; y = x",
+		"# comment only line\nx = 2 # trailing comment\n\n",
+		"if x > 1 { y = 1 } else if x < 0 { y = 2 } else { y = 3 }",
+		"for i = 1 to 10 step 2 {\n  s = s + i\n}",
+		"for i = 10 to 1 step -1 {}",
+		"hypot(\n  3,\n  4\n)",
+		"not a and b or c != 1e-3",
+		"x = -(-2) * .5",
+		"tiny = 1e-400",
+	}
+	for _, src := range valid {
+		if _, err := Parse(src); err != nil {
+			t.Errorf("Parse(%q): %v", src, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src       string
+		line, col int
+		msg       string
+	}{
+		{"", 1, 1, "script is empty"},
+		{"\n;\n", 1, 1, "script is empty"},
+		{"x = 1 @", 1, 7, "unexpected character"},
+		{"x = ", 1, 5, "unexpected end of script"},
+		{"x = 1 y = 2", 1, 7, "statements must be separated by a newline or ;"},
+		{"if x { y = 1 }\nelse { y = 2 }", 2, 1, "unexpected keyword"},
+		{"if x y = 1", 1, 6, "expected {"},
+		{"for i = 1 to 3 {\n x = i", 2, 7, "missing closing brace"},
+		{"x = 1 }", 1, 7, "unexpected symbol"},
+		{"for 1 = 1 to 2 {}", 1, 5, "expected a variable name"},
+		{"for i in 1 to 2 {}", 1, 7, "expected = after the loop variable"},
+		{"for i = 1 until 2 {}", 1, 11, "expected to"},
+		{"f(1 2)", 1, 5, "missing closing parenthesis"},
+		{"(1 + 2", 1, 7, "missing closing parenthesis"},
+		{"x = 1.2.3", 1, 5, "malformed number"},
+		{"x = 1e999", 1, 5, "number is out of range"},
+		{"if = 3", 1, 4, "unexpected symbol"},
+		{"x = 2y", 1, 6, "statements must be separated by a newline or ;"},
+		{"z = ü + $", 1, 9, "unexpected character"},
+		{strings.Repeat("(", maxDepth) + "1" + strings.Repeat(")", maxDepth), 1, maxDepth + 1, "script is nested too deeply"},
+		{strings.Repeat("x", MaxLength+1), 1, 1, "script is too long"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Msg != tt.msg || se.Line != tt.line || se.Column != tt.col {
+			t.Errorf("Parse(%.20q) error = %v, want %s at line %d, column %d", tt.src, err, tt.msg, tt.line, tt.col)
+		}
+	}
+}
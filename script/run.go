@@ -0,0 +1,447 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Ops performs the primitive operations of a script.
+type Ops interface {
+	// Lookup resolves an operation name or alias to its canonical name and
+	// number of operands.
+	Lookup(name string) (canonical string, arity int, ok bool)
+	// Call performs the operation with the canonical name. Unary operations
+	// receive b = 0. Warnings, such as precision problems, are copied to the
+	// trace.
+	Call(name string, a, b float64) (value float64, warnings []string, err error)
+}
+
+// Limits bound the resources of a run. Zero fields take the values in
+// DefaultLimits.
+type Limits struct {
+	// Steps caps the statements, loop iterations and operations executed.
+	Steps int
+	// Memory caps the approximate bytes held by variables and the trace.
+	Memory int
+	// Timeout caps the wall-clock time of the run.
+	Timeout time.Duration
+}
+
+// DefaultLimits allow ten thousand steps, 1 MiB of variables and trace and
+// one second.
+var DefaultLimits = Limits{Steps: 10_000, Memory: 1 << 20, Timeout: time.Second}
+
+func (l Limits) withDefaults() Limits {
+	if l.Steps <= 0 {
+		l.Steps = DefaultLimits.Steps
+	}
+	if l.Memory <= 0 {
+		l.Memory = DefaultLimits.Memory
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultLimits.Timeout
+	}
+	return l
+}
+
+// Approximate memory cost of a variable, besides its name, and of a trace
+// entry, besides its operands and warnings.
+const (
+	varCost   = 32
+	traceCost = 64
+)
+
+// ctxCheckEvery is how many steps pass between deadline checks.
+const ctxCheckEvery = 64
+
+// Step is a trace entry: an operation with its operands, or an assignment to
+// Var.
+type Step struct {
+	Line     int       `json:"line"`
+	Op       string    `json:"op,omitempty"`
+	Operands []float64 `json:"operands,omitempty"`
+	Var      string    `json:"var,omitempty"`
+	Value    float64   `json:"value"`
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	// Value is the value of the last assignment or expression statement
+	// executed, nil if there was none.
+	Value *float64 `json:"value"`
+	// Trace lists the operations and assignments in the order executed.
+	Trace []Step `json:"trace"`
+	// Variables holds the final value of every variable.
+	Variables map[string]float64 `json:"variables"`
+	// Steps counts the statements, loop iterations and operations executed.
+	Steps int `json:"steps"`
+}
+
+// Error is a failure while running a script, such as an undefined variable
+// or an operation returning an error, which Err then holds.
+type Error struct {
+	Line int
+	Name string // variable or function concerned, if any
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Msg, e.Name)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// LimitError is returned when a run exceeds one of its Limits.
+type LimitError struct {
+	Limit string // LimitSteps, LimitMemory or LimitTime
+	Line  int
+	Steps int
+}
+
+// The limits a LimitError can exceed.
+const (
+	LimitSteps  = "steps"
+	LimitMemory = "memory"
+	LimitTime   = "time"
+)
+
+func (e *LimitError) Error() string { return "script ran out of " + e.Limit }
+
+// Run executes p. Before the first step it checks that every function the
+// script calls exists and gets the right number of arguments. On error the
+// result holds the trace up to the failure.
+func Run(ctx context.Context, p *Program, ops Ops, l Limits) (Result, error) {
+	l = l.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, l.Timeout)
+	defer cancel()
+
+	m := &machine{
+		ctx:    ctx,
+		ops:    ops,
+		limits: l,
+		res:    Result{Trace: []Step{}, Variables: map[string]float64{}},
+	}
+	if err := m.check(p.body); err != nil {
+		return m.res, err
+	}
+	err := m.exec(p.body)
+	return m.res, err
+}
+
+type machine struct {
+	ctx    context.Context
+	ops    Ops
+	limits Limits
+	res    Result
+	memory int
+}
+
+// check resolves the calls in body.
+func (m *machine) check(body []stmt) error {
+	for _, s := range body {
+		var (
+			exprs  []node
+			blocks [][]stmt
+		)
+		switch s := s.(type) {
+		case *assign:
+			exprs = []node{s.x}
+		case *exprStmt:
+			exprs = []node{s.x}
+		case *ifStmt:
+			exprs, blocks = []node{s.cond}, [][]stmt{s.then, s.els}
+		case *forStmt:
+			exprs, blocks = []node{s.from, s.to, s.step}, [][]stmt{s.body}
+		}
+		for _, n := range exprs {
+			if err := m.checkExpr(n); err != nil {
+				return err
+			}
+		}
+		for _, b := range blocks {
+			if err := m.check(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *machine) checkExpr(n node) error {
+	var operands []node
+	switch n := n.(type) {
+	case *unary:
+		operands = []node{n.x}
+	case *binary:
+		operands = []node{n.l, n.r}
+	case *call:
+		_, arity, ok := m.ops.Lookup(n.name)
+		if !ok {
+			return &Error{Line: n.ln, Name: n.name, Msg: "unknown function"}
+		}
+		if arity != len(n.args) {
+			return &Error{Line: n.ln, Name: n.name, Msg: "wrong number of arguments"}
+		}
+		operands = n.args
+	}
+	for _, x := range operands {
+		if err := m.checkExpr(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tick counts a step executed on line and enforces the step and time limits.
+func (m *machine) tick(line int) error {
+	m.res.Steps++
+	if m.res.Steps > m.limits.Steps {
+		m.res.Steps--
+		return &LimitError{Limit: LimitSteps, Line: line, Steps: m.res.Steps}
+	}
+	if m.res.Steps%ctxCheckEvery == 0 {
+		switch err := m.ctx.Err(); {
+		case errors.Is(err, context.DeadlineExceeded):
+			return &LimitError{Limit: LimitTime, Line: line, Steps: m.res.Steps}
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+// alloc accounts for n more bytes held on line.
+func (m *machine) alloc(line, n int) error {
+	if m.memory += n; m.memory > m.limits.Memory {
+		return &LimitError{Limit: LimitMemory, Line: line, Steps: m.res.Steps}
+	}
+	return nil
+}
+
+func (m *machine) exec(body []stmt) error {
+	for _, s := range body {
+		if err := m.tick(s.line()); err != nil {
+			return err
+		}
+		if err := m.stmt(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *machine) stmt(s stmt) error {
+	switch s := s.(type) {
+	case *assign:
+		v, err := m.eval(s.x)
+		if err != nil {
+			return err
+		}
+		if err := m.set(s.ln, s.name, v); err != nil {
+			return err
+		}
+		if err := m.alloc(s.ln, traceCost); err != nil {
+			return err
+		}
+		m.res.Trace = append(m.res.Trace, Step{Line: s.ln, Var: s.name, Value: v})
+		m.res.Value = &v
+
+	case *exprStmt:
+		v, err := m.eval(s.x)
+		if err != nil {
+			return err
+		}
+		m.res.Value = &v
+
+	case *ifStmt:
+		cond, err := m.truth(s.ln, s.cond)
+		if err != nil {
+			return err
+		}
+		if cond {
+			return m.exec(s.then)
+		}
+		return m.exec(s.els)
+
+	case *forStmt:
+		return m.loop(s)
+	}
+	return nil
+}
+
+// loop runs a for statement. The loop variable is computed from the
+// iteration count rather than accumulated, so it does not drift.
+func (m *machine) loop(s *forStmt) error {
+	from, err := m.eval(s.from)
+	if err != nil {
+		return err
+	}
+	to, err := m.eval(s.to)
+	if err != nil {
+		return err
+	}
+	step := 1.0
+	if s.step != nil {
+		if step, err = m.eval(s.step); err != nil {
+			return err
+		}
+	}
+	switch {
+	case !finite(from) || !finite(to) || !finite(step):
+		return &Error{Line: s.ln, Msg: "loop bounds must be finite"}
+	case step == 0:
+		return &Error{Line: s.ln, Msg: "loop step must not be zero"}
+	}
+	for k := 0.0; ; k++ {
+		i := from + k*step
+		if step > 0 && i > to || step < 0 && i < to {
+			return nil
+		}
+		if err := m.tick(s.ln); err != nil {
+			return err
+		}
+		if err := m.set(s.ln, s.name, i); err != nil {
+			return err
+		}
+		if err := m.exec(s.body); err != nil {
+			return err
+		}
+	}
+}
+
+// set assigns a variable, accounting for the memory of a new one.
+func (m *machine) set(line int, name string, v float64) error {
+	if _, ok := m.res.Variables[name]; !ok {
+		if err := m.alloc(line, varCost+len(name)); err != nil {
+			return err
+		}
+	}
+	m.res.Variables[name] = v
+	return nil
+}
+
+// truth evaluates a condition; zero is false.
+func (m *machine) truth(line int, n node) (bool, error) {
+	v, err := m.eval(n)
+	if err != nil {
+		return false, err
+	}
+	if math.IsNaN(v) {
+		return false, &Error{Line: line, Msg: "condition is not a number"}
+	}
+	return v != 0, nil
+}
+
+func (m *machine) eval(n node) (float64, error) {
+	switch n := n.(type) {
+	case *num:
+		return n.v, nil
+
+	case *ident:
+		v, ok := m.res.Variables[n.name]
+		if !ok {
+			return 0, &Error{Line: n.ln, Name: n.name, Msg: "undefined variable"}
+		}
+		return v, nil
+
+	case *unary:
+		x, err := m.eval(n.x)
+		if err != nil {
+			return 0, err
+		}
+		if n.op == "not" {
+			return b2f(x == 0), nil
+		}
+		return -x, nil
+
+	case *binary:
+		return m.binary(n)
+
+	case *call:
+		return m.call(n)
+	}
+	panic(fmt.Sprintf("script: unknown node %T", n))
+}
+
+func (m *machine) binary(n *binary) (float64, error) {
+	l, err := m.eval(n.l)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case "and", "or":
+		// Short-circuit: the right operand is only evaluated when needed.
+		if (l != 0) == (n.op == "or") {
+			return b2f(l != 0), nil
+		}
+		r, err := m.eval(n.r)
+		return b2f(r != 0), err
+	}
+	r, err := m.eval(n.r)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case "<":
+		return b2f(l < r), nil
+	case "<=":
+		return b2f(l <= r), nil
+	case ">":
+		return b2f(l > r), nil
+	case ">=":
+		return b2f(l >= r), nil
+	case "==":
+		return b2f(l == r), nil
+	}
+	return b2f(l != r), nil
+}
+
+// call performs an operation and records it in the trace.
+func (m *machine) call(n *call) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, a := range n.args {
+		v, err := m.eval(a)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	if err := m.tick(n.ln); err != nil {
+		return 0, err
+	}
+	// The operation may have been removed since the check.
+	name, arity, ok := m.ops.Lookup(n.name)
+	if !ok || arity != len(args) {
+		return 0, &Error{Line: n.ln, Name: n.name, Msg: "unknown function"}
+	}
+	a, b := args[0], 0.0
+	if arity == 2 {
+		b = args[1]
+	}
+	v, warnings, err := m.ops.Call(name, a, b)
+	if err != nil {
+		return 0, &Error{Line: n.ln, Msg: err.Error(), Err: err}
+	}
+	if err := m.alloc(n.ln, traceCost+8*len(args)+16*len(warnings)); err != nil {
+		return 0, err
+	}
+	m.res.Trace = append(m.res.Trace, Step{Line: n.ln, Op: name, Operands: args, Value: v, Warnings: warnings})
+	return v, nil
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func finite(v float64) bool { return !math.IsNaN(v) && !math.IsInf(v, 0) }
@@ -0,0 +1,207 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testOps provides the four arithmetic operations, hypot (alias pythag),
+// the unary half and, when slow is set, a sleep before every operation.
+type testOps struct {
+	slow  time.Duration
+	calls []string
+}
+
+var errDivide = errors.New("division by zero is not allowed")
+
+func (o *testOps) Lookup(name string) (string, int, bool) {
+	switch name {
+	case "add", "subtract", "multiply", "divide", "hypot":
+		return name, 2, true
+	case "pythag":
+		return "hypot", 2, true
+	case "half":
+		return name, 1, true
+	}
+	return "", 0, false
+}
+
+func (o *testOps) Call(name string, a, b float64) (float64, []string, error) {
+	o.calls = append(o.calls, name)
+	time.Sleep(o.slow)
+	switch name {
+	case "add":
+		return a + b, nil, nil
+	case "subtract":
+		return a - b, nil, nil
+	case "multiply":
+		return a * b, nil, nil
+	case "divide":
+		if b == 0 {
+			return 0, nil, errDivide
+		}
+		return a / b, nil, nil
+	case "hypot":
+		return math.Hypot(a, b), nil, nil
+	case "half":
+		if b != 0 {
+			return 0, nil, errors.New("half got b")
+		}
+		return a / 2, []string{"halved"}, nil
+	}
+	return 0, nil, errors.New("unexpected call")
+}
+
+func run(t *testing.T, src string, ops Ops, l Limits) (Result, error) {
+	t.Helper()
+	p, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	return Run(context.Background(), p, ops, l)
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"-2 * -3", 6},
+		{"x = 3; y = 4; pythag(x, y)", 5},
+		{"half(9)", 4.5},
+		{"s = 0\nfor i = 1 to 10 { s = s + i }\ns", 55},
+		{"s = 0; for i = 10 to 1 step -3 { s = s + i }; s", 22},
+		{"n = 0; for i = 0 to 1 step 0.1 { n = n + 1 }; n", 11},
+		{"for i = 1 to 0 { x = 1 }; i = 7", 7},
+		{"x = 5; if x > 3 { y = 1 } else { y = 2 }; y", 1},
+		{"x = 0; if x > 3 { y = 1 } else if x == 0 { y = 2 } else { y = 3 }; y", 2},
+		{"1 < 2 and 2 <= 2 and 3 >= 3 and not (1 > 2) and 1 != 2", 1},
+		{"0 or 0", 0},
+		{"2 or 1", 1},
+		// Short-circuiting skips the undefined variable.
+		{"0 and missing", 0},
+		{"1 or missing", 1},
+	}
+	for _, tt := range tests {
+		res, err := run(t, tt.src, &testOps{}, Limits{})
+		if err != nil || res.Value == nil || *res.Value != tt.want {
+			t.Errorf("%q = %v, %v; want %v", tt.src, res.Value, err, tt.want)
+		}
+	}
+}
+
+func TestRun_Trace(t *testing.T) {
+	ops := &testOps{}
+	res, err := run(t, "a = 3\nb = pythag(a, 4) + 1\nif b > 5 {\n  half(b)\n}", ops, Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Step{
+		{Line: 1, Var: "a", Value: 3},
+		{Line: 2, Op: "hypot", Operands: []float64{3, 4}, Value: 5},
+		{Line: 2, Op: "add", Operands: []float64{5, 1}, Value: 6},
+		{Line: 2, Var: "b", Value: 6},
+		{Line: 4, Op: "half", Operands: []float64{6}, Value: 3, Warnings: []string{"halved"}},
+	}
+	if !slices.EqualFunc(res.Trace, want, func(a, b Step) bool {
+		return a.Line == b.Line && a.Op == b.Op && a.Var == b.Var && a.Value == b.Value &&
+			slices.Equal(a.Operands, b.Operands) && slices.Equal(a.Warnings, b.Warnings)
+	}) {
+		t.Fatalf("trace = %+v", res.Trace)
+	}
+	if *res.Value != 3 || res.Variables["a"] != 3 || res.Variables["b"] != 6 || len(res.Variables) != 2 {
+		t.Fatalf("value %v, variables %v", *res.Value, res.Variables)
+	}
+	// Four statements, one of them nested, and three operations.
+	if res.Steps != 7 {
+		t.Fatalf("steps = %d", res.Steps)
+	}
+}
+
+func TestRun_NoValue(t *testing.T) {
+	res, err := run(t, "if 0 { x = 1 }", &testOps{}, Limits{})
+	if err != nil || res.Value != nil || len(res.Trace) != 0 {
+		t.Fatalf("result = %+v, %v", res, err)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+		name string
+		msg  string
+	}{
+		{"x = 1\ny = z", 2, "z", "undefined variable"},
+		{"x = 1\nif x { y = sqrt(x) }", 2, "sqrt", "unknown function"},
+		{"hypot(1)", 1, "hypot", "wrong number of arguments"},
+		{"half(1, 2)", 1, "half", "wrong number of arguments"},
+		{"for i = 1 to 2 step 0 {}", 1, "", "loop step must not be zero"},
+		{"x = 1\ny = x / (x - 1)", 2, "", "division by zero is not allowed"},
+	}
+	for _, tt := range tests {
+		ops := &testOps{}
+		_, err := run(t, tt.src, ops, Limits{})
+		var e *Error
+		if !errors.As(err, &e) || e.Line != tt.line || e.Name != tt.name || e.Msg != tt.msg {
+			t.Errorf("%q error = %#v, want %q at line %d", tt.src, err, tt.msg, tt.line)
+		}
+	}
+
+	// Calls are checked before anything runs.
+	ops := &testOps{}
+	if _, err := run(t, "x = 1 + 2\ny = nope(x)", ops, Limits{}); err == nil || len(ops.calls) != 0 {
+		t.Fatalf("error = %v after calls %v", err, ops.calls)
+	}
+
+	// Operation errors are wrapped.
+	res, err := run(t, "x = 2 * 3\ny = x / 0", &testOps{}, Limits{})
+	if !errors.Is(err, errDivide) {
+		t.Fatalf("error = %v", err)
+	}
+	if len(res.Trace) != 2 || res.Variables["x"] != 6 {
+		t.Fatalf("partial result = %+v", res)
+	}
+}
+
+func TestRun_Limits(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		ops    *testOps
+		limits Limits
+		limit  string
+	}{
+		{"steps", "for i = 1 to 1e15 {}", &testOps{}, Limits{}, LimitSteps},
+		{"steps in operations", "s = 0\nfor i = 1 to 100 { s = s + i * i }", &testOps{}, Limits{Steps: 250}, LimitSteps},
+		{"memory in trace", "s = 0\nfor i = 1 to 1000 { s = s + i }", &testOps{}, Limits{Memory: 10_000}, LimitMemory},
+		{"time", "s = 0\nfor i = 1 to 1000 { s = s + 1 }", &testOps{slow: time.Millisecond}, Limits{Timeout: 20 * time.Millisecond}, LimitTime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := run(t, tt.src, tt.ops, tt.limits)
+			var le *LimitError
+			if !errors.As(err, &le) || le.Limit != tt.limit || le.Steps != res.Steps {
+				t.Fatalf("error = %v, want %s limit", err, tt.limit)
+			}
+			if l := tt.limits.withDefaults(); res.Steps > l.Steps {
+				t.Fatalf("ran %d steps, limit %d", res.Steps, l.Steps)
+			}
+		})
+	}
+
+	// A cancelled context stops the run with its own error.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p, _ := Parse("for i = 1 to 1000 {}")
+	if _, err := Run(ctx, p, &testOps{}, Limits{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v", err)
+	}
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/currency"
 	"erikkruuse/calculator/internal/api"
 	service "erikkruuse/calculator/internal/services"
+	"erikkruuse/calculator/plugins"
+	"erikkruuse/calculator/temporal"
+	"erikkruuse/calculator/units"
+	"erikkruuse/calculator/wasm"
 )
 
 func getenv(key, fallback string) string {
@@ -18,6 +28,11 @@ func getenv(key, fallback string) string {
 }
 
 func main() {
+	// Subcommands run instead of the server.
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Respect Cloud Run port environment variable.
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -25,15 +40,110 @@ func main() {
 	}
 	addr := ":" + port
 
-	// Create service layer (calculator + history)
-	svc := service.NewCalculatorService(service.WithMaxHistory(100))
+	// Built-in unit table, extended from UNITS_FILE when set
+	unitTable, err := loadUnits(getenv("UNITS_FILE", ""))
+	if err != nil {
+		log.Fatalf("invalid UNITS_FILE: %v", err)
+	}
+
+	// Exchange rates from RATES_FILE, reloaded when the file changes
+	var rates *currency.Source
+	if path := getenv("RATES_FILE", ""); path != "" {
+		rates, err = currency.Open(path)
+		if err != nil {
+			log.Fatalf("invalid RATES_FILE: %v", err)
+		}
+		err = rates.Watch(getenvDuration("RATES_RELOAD_INTERVAL", 30*time.Second), func(err error) {
+			log.Printf("rates reload failed, keeping snapshot %s: %v", rates.Current().ID, err)
+		})
+		if err != nil {
+			log.Fatalf("invalid RATES_RELOAD_INTERVAL: %v", err)
+		}
+		defer rates.Close()
+	}
+
+	// Holiday calendars for business day counting, one JSON file per calendar
+	var calendars map[string]*temporal.Calendar
+	if dir := getenv("HOLIDAY_CALENDARS_DIR", ""); dir != "" {
+		if calendars, err = temporal.LoadCalendars(dir); err != nil {
+			log.Fatalf("invalid HOLIDAY_CALENDARS_DIR: %v", err)
+		}
+	}
+
+	// WebAssembly operations from PLUGINS_DIR, reloaded when a module changes
+	if dir := getenv("PLUGINS_DIR", ""); dir != "" {
+		loader, err := plugins.Open(dir, calculator.DefaultRegistry, wasm.Limits{
+			Fuel:     uint64(getenvInt64("PLUGIN_FUEL", 0)),
+			MaxPages: uint32(getenvInt64("PLUGIN_MAX_MEMORY_PAGES", 0)),
+		})
+		if err != nil {
+			log.Fatalf("invalid PLUGINS_DIR: %v", err)
+		}
+		err = loader.Watch(getenvDuration("PLUGINS_RELOAD_INTERVAL", 30*time.Second), func(err error) {
+			log.Printf("plugin reload failed, keeping previous versions: %v", err)
+		})
+		if err != nil {
+			log.Fatalf("invalid PLUGINS_RELOAD_INTERVAL: %v", err)
+		}
+		defer loader.Close()
+	}
+
+	// Create service layer (calculator + history). Retention is global, with
+	// per-tenant overrides from HISTORY_TENANT_RETENTION_FILE.
+	tenantRetention, err := loadTenantRetention(getenv("HISTORY_TENANT_RETENTION_FILE", ""))
+	if err != nil {
+		log.Fatalf("invalid HISTORY_TENANT_RETENTION_FILE: %v", err)
+	}
+	opts := []service.Option{
+		service.WithMaxHistory(int(getenvInt64("HISTORY_MAX_COUNT", 100))),
+		service.WithRetention(service.Retention{
+			MaxAge:   getenvDuration("HISTORY_MAX_AGE", 0),
+			MaxBytes: getenvInt64("HISTORY_MAX_BYTES", 0),
+		}),
+		service.WithResultCache(
+			int(getenvInt64("RESULT_CACHE_SIZE", 0)),
+			getenvDuration("RESULT_CACHE_TTL", 10*time.Minute),
+		),
+		service.WithStrictness(strictness(getenv("STRICT_ARITHMETIC", ""))),
+		service.WithUnits(unitTable),
+		service.WithRates(rates),
+		service.WithCalendars(calendars),
+	}
+	for tenant, r := range tenantRetention {
+		opts = append(opts, service.WithTenantRetention(tenant, r))
+	}
+	svc := service.NewCalculatorService(opts...)
+	defer svc.Close()
 
 	// Wire up the API layer
 	handler := http.NewServeMux()
 	api.New(svc).RegisterRoutes(handler)
 
+	// Per-client rate limiting and daily calculation quotas, off by default:
+	// clients are told apart by IP unless TRUSTED_PROXIES or API_KEYS is set,
+	// and behind a load balancer such as Cloud Run's every client would share
+	// the proxy's bucket.
+	proxies, err := api.ParseTrustedProxies(getenv("TRUSTED_PROXIES", ""))
+	if err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	limited := api.RateLimit(handler, api.RateLimitConfig{
+		Rate:           getenvFloat("RATE_LIMIT_RPS", 0),
+		Burst:          int(getenvInt64("RATE_LIMIT_BURST", 0)),
+		DailyQuota:     int(getenvInt64("DAILY_QUOTA", 0)),
+		TrustedProxies: proxies,
+		APIKeys:        api.ParseAPIKeys(getenv("API_KEYS", "")),
+	})
+
+	// Replay retried writes that carry an Idempotency-Key, ahead of the
+	// limiter so replays cost no tokens or quota
+	idempotent := api.Idempotency(limited, api.IdempotencyConfig{
+		TTL:        getenvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		MaxEntries: int(getenvInt64("IDEMPOTENCY_MAX_KEYS", 10000)),
+	})
+
 	// Basic request logging middleware
-	logged := loggingMiddleware(handler)
+	logged := loggingMiddleware(idempotent)
 
 	// Startup banner
 	log.Printf("Starting Calculator API on %s ...", addr)
@@ -46,11 +156,81 @@ func main() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("server error: %v", err)
 	}
 }
 
+func getenvDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(getenv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return d
+}
+
+func getenvInt64(key string, fallback int64) int64 {
+	n, err := strconv.ParseInt(getenv(key, ""), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+func getenvFloat(key string, fallback float64) float64 {
+	f, err := strconv.ParseFloat(getenv(key, ""), 64)
+	if err != nil {
+		return fallback
+	}
+	return f
+}
+
+// strictness maps STRICT_ARITHMETIC to a service mode: any true value makes
+// overflow an error instead of a result left out with a warning.
+func strictness(v string) service.Strictness {
+	if strict, err := strconv.ParseBool(v); err == nil && strict {
+		return service.Strict
+	}
+	return service.Lenient
+}
+
+// loadUnits returns the built-in unit table merged with the definitions in
+// path, if any.
+func loadUnits(path string) (*units.Registry, error) {
+	reg := units.NewRegistry()
+	if path == "" {
+		return reg, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return reg, reg.Load(f)
+}
+
+// loadTenantRetention reads the per-tenant retention overrides in path, if any.
+func loadTenantRetention(path string) (map[string]service.Retention, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return service.LoadTenantRetention(f)
+}
+
 // loggingMiddleware wraps an http.Handler to log simple request summaries.
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	service "erikkruuse/calculator/internal/services"
+)
+
+// runReplay implements the "replay" subcommand: it loads history entries from an
+// export file or a running server and re-executes them with this binary's
+// calculator, printing a JSON report. It returns the process exit code.
+func runReplay(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	file := fs.String("file", "", "history export to replay (JSON array of entries, - for stdin)")
+	url := fs.String("url", "", "base URL of a running server whose history should be replayed")
+	limit := fs.Int("limit", 1000, "maximum number of entries to fetch with -url")
+	abs := fs.Float64("abs", 0, "absolute tolerance for result comparison")
+	rel := fs.Float64("rel", 0, "relative tolerance for result comparison")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if (*file == "") == (*url == "") {
+		fmt.Fprintln(stderr, "replay: exactly one of -file or -url is required")
+		return 2
+	}
+	if *abs < 0 || *rel < 0 {
+		fmt.Fprintln(stderr, "replay: tolerances must be non-negative")
+		return 2
+	}
+
+	var (
+		entries []service.HistoryEntry
+		err     error
+	)
+	if *file != "" {
+		entries, err = loadHistoryFile(*file)
+	} else {
+		entries, err = fetchHistory(*url, *limit)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "replay: %v\n", err)
+		return 1
+	}
+
+	report := service.Replay(entries, service.Tolerance{Abs: *abs, Rel: *rel})
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+func loadHistoryFile(path string) ([]service.HistoryEntry, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var entries []service.HistoryEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func fetchHistory(baseURL string, limit int) ([]service.HistoryEntry, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	url := fmt.Sprintf("%s/v1/history?limit=%d", strings.TrimRight(baseURL, "/"), limit)
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	var entries []service.HistoryEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decode history: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,255 @@
+package solver
+
+import (
+	"math"
+	"math/big"
+
+	"erikkruuse/calculator/expr"
+)
+
+// exact is a root in closed form: re + im·i, with im nil for real roots.
+type exact struct{ re, im expr.Node }
+
+// value evaluates the closed form, reporting false if it cannot be
+// evaluated in floating point.
+func (e exact) value() (complex128, bool) {
+	re, err := expr.Eval(e.re, nil)
+	if err != nil {
+		return 0, false
+	}
+	im := 0.0
+	if e.im != nil {
+		if im, err = expr.Eval(e.im, nil); err != nil {
+			return 0, false
+		}
+	}
+	return complex(re, im), true
+}
+
+// closedForm returns the roots of a square-free polynomial of degree 1 to 4
+// with no rational roots in radicals (or, for a cubic with three real
+// roots, in trigonometric form). It returns nil when no closed form
+// applies.
+func closedForm(p Poly) []exact {
+	m := p.monic()
+	switch p.Degree() {
+	case 1:
+		return []exact{{re: lit(new(big.Rat).Neg(m[0]))}}
+	case 2:
+		return quadratic(m[1], m[0])
+	case 3:
+		return cubic(m[2], m[1], m[0])
+	case 4:
+		return quartic(m[3], m[2], m[1], m[0])
+	}
+	return nil
+}
+
+func lit(r *big.Rat) expr.Node     { return &expr.Num{Value: r} }
+func rat(a, b int64) *big.Rat      { return big.NewRat(a, b) }
+func add(a, b expr.Node) expr.Node { return &expr.Binary{Op: '+', L: a, R: b} }
+func sub(a, b expr.Node) expr.Node { return &expr.Binary{Op: '-', L: a, R: b} }
+func mul(a, b expr.Node) expr.Node { return &expr.Binary{Op: '*', L: a, R: b} }
+func div(a, b expr.Node) expr.Node { return &expr.Binary{Op: '/', L: a, R: b} }
+func call(fn string, u expr.Node) expr.Node {
+	return &expr.Call{Fn: fn, Args: []expr.Node{u}}
+}
+
+// maxSurdBits bounds the radicands sqrt factors.
+const maxSurdBits = 256
+
+// sqrt returns the square root of n, written in simplest radical form when n
+// is a non-negative literal: 2*sqrt(2) rather than sqrt(8), sqrt(3)/2 rather
+// than sqrt(3/4). expr.Simplify leaves radicands alone.
+func sqrt(n expr.Node) expr.Node {
+	n = expr.Simplify(n)
+	x, ok := n.(*expr.Num)
+	if !ok || x.Value.Sign() < 0 {
+		return call("sqrt", n)
+	}
+	// sqrt(p/q) = sqrt(p·q)/q keeps the radicand an integer.
+	q := x.Value.Denom()
+	rest := new(big.Int).Mul(x.Value.Num(), q)
+	if rest.BitLen() > maxSurdBits {
+		return call("sqrt", n)
+	}
+	out := big.NewInt(1)
+	var f, f2, m big.Int
+	for i := int64(2); i < 10000; i++ {
+		f.SetInt64(i)
+		if f2.Mul(&f, &f).Cmp(rest) > 0 {
+			break
+		}
+		for m.Mod(rest, &f2).Sign() == 0 {
+			rest.Quo(rest, &f2)
+			out.Mul(out, &f)
+		}
+	}
+	// Simplify takes the root of a large square left in rest.
+	coef := new(big.Rat).SetFrac(out, q)
+	return expr.Simplify(mul(lit(coef), call("sqrt", lit(new(big.Rat).SetInt(rest)))))
+}
+
+// prod and sum keep the rational coefficient formulas below readable.
+func prod(xs ...*big.Rat) *big.Rat {
+	out := rat(1, 1)
+	for _, x := range xs {
+		out.Mul(out, x)
+	}
+	return out
+}
+
+func sum(xs ...*big.Rat) *big.Rat {
+	out := new(big.Rat)
+	for _, x := range xs {
+		out.Add(out, x)
+	}
+	return out
+}
+
+// quadratic solves x² + b·x + c = 0.
+func quadratic(b, c *big.Rat) []exact {
+	re := prod(rat(-1, 2), b)
+	d := sum(prod(b, b), prod(rat(-4, 1), c))
+	s := expr.Simplify(div(sqrt(lit(new(big.Rat).Abs(d))), lit(rat(2, 1))))
+	if d.Sign() >= 0 {
+		return []exact{
+			{re: expr.Simplify(sub(lit(re), s))},
+			{re: expr.Simplify(add(lit(re), s))},
+		}
+	}
+	return []exact{
+		{re: lit(re), im: expr.Simplify(&expr.Neg{X: s})},
+		{re: lit(re), im: s},
+	}
+}
+
+// quadraticNodes solves x² + b·x + c = 0 for symbolic b and c, deciding
+// between real and complex roots numerically.
+func quadraticNodes(b, c expr.Node) []exact {
+	bv, err1 := expr.Eval(b, nil)
+	cv, err2 := expr.Eval(c, nil)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	re := expr.Simplify(div(&expr.Neg{X: b}, lit(rat(2, 1))))
+	d := sub(mul(b, b), mul(lit(rat(4, 1)), c))
+	if bv*bv-4*cv >= 0 {
+		s := expr.Simplify(div(sqrt(d), lit(rat(2, 1))))
+		return []exact{{re: expr.Simplify(sub(re, s))}, {re: expr.Simplify(add(re, s))}}
+	}
+	s := expr.Simplify(div(sqrt(&expr.Neg{X: d}), lit(rat(2, 1))))
+	return []exact{{re: re, im: expr.Simplify(&expr.Neg{X: s})}, {re: re, im: s}}
+}
+
+// cubic solves x³ + a·x² + b·x + c = 0 by Cardano's method, or in
+// trigonometric form when all three roots are real.
+func cubic(a, b, c *big.Rat) []exact {
+	// Substitute x = t − a/3 to get t³ + p·t + q = 0.
+	shift := lit(prod(rat(-1, 3), a))
+	p := sum(b, prod(rat(-1, 3), a, a))
+	q := sum(prod(rat(2, 27), a, a, a), prod(rat(-1, 3), a, b), c)
+	disc := sum(prod(rat(1, 4), q, q), prod(rat(1, 27), p, p, p))
+
+	switch disc.Sign() {
+	case 0:
+		return nil // repeated root; square-free input never gets here
+	case 1:
+		halfQ := lit(prod(rat(-1, 2), q))
+		sq := sqrt(lit(disc))
+		u, v := call("cbrt", add(halfQ, sq)), call("cbrt", sub(halfQ, sq))
+		re := expr.Simplify(add(div(&expr.Neg{X: add(u, v)}, lit(rat(2, 1))), shift))
+		im := expr.Simplify(mul(div(call("sqrt", lit(rat(3, 1))), lit(rat(2, 1))), sub(u, v)))
+		return []exact{
+			{re: expr.Simplify(add(add(u, v), shift))},
+			{re: re, im: expr.Simplify(&expr.Neg{X: im})},
+			{re: re, im: im},
+		}
+	}
+	// t_k = 2·sqrt(−p/3)·cos(acos(3q/(2p)·sqrt(−3/p))/3 − 2πk/3)
+	m := mul(lit(rat(2, 1)), sqrt(lit(prod(rat(-1, 3), p))))
+	arg := mul(lit(prod(rat(3, 2), q, new(big.Rat).Inv(p))), sqrt(lit(prod(rat(-3, 1), new(big.Rat).Inv(p)))))
+	theta := div(call("acos", arg), lit(rat(3, 1)))
+	out := make([]exact, 3)
+	for k := range out {
+		angle := sub(theta, mul(lit(rat(2*int64(k), 3)), &expr.Var{Name: "pi"}))
+		out[k] = exact{re: expr.Simplify(add(mul(m, call("cos", angle)), shift))}
+	}
+	return out
+}
+
+// quartic solves x⁴ + a·x³ + b·x² + c·x + d = 0 by Ferrari's method.
+func quartic(a, b, c, d *big.Rat) []exact {
+	// Substitute x = y − a/4 to get y⁴ + p·y² + q·y + r = 0.
+	shift := lit(prod(rat(-1, 4), a))
+	p := sum(b, prod(rat(-3, 8), a, a))
+	q := sum(prod(rat(1, 8), a, a, a), prod(rat(-1, 2), a, b), c)
+	r := sum(prod(rat(-3, 256), a, a, a, a), prod(rat(1, 16), a, a, b), prod(rat(-1, 4), a, c), d)
+
+	shifted := func(roots []exact) []exact {
+		for i := range roots {
+			roots[i].re = expr.Simplify(add(roots[i].re, shift))
+		}
+		return roots
+	}
+
+	if q.Sign() == 0 {
+		// Biquadratic: y² = z where z² + p·z + r = 0.
+		zs := quadratic(p, r)
+		if zs[0].im != nil {
+			return nil
+		}
+		var out []exact
+		for _, z := range zs {
+			out = append(out, quadraticNodes(lit(new(big.Rat)), &expr.Neg{X: z.re})...)
+		}
+		return shifted(out)
+	}
+
+	// Resolvent: m³ + p·m² + (p²/4 − r)·m − q²/8 = 0 has a positive root.
+	res := Poly{prod(rat(-1, 8), q, q), sum(prod(rat(1, 4), p, p), prod(rat(-1, 1), r)), p, rat(1, 1)}
+	m := resolventRoot(res)
+	if m == nil {
+		return nil
+	}
+	s2m := sqrt(mul(lit(rat(2, 1)), m))
+	var out []exact
+	for _, sign := range []int64{1, -1} {
+		// y² − s·sqrt(2m)·y + (p/2 + m + s·q/(2·sqrt(2m))) = 0
+		bb := mul(lit(rat(-sign, 1)), s2m)
+		cc := add(add(lit(prod(rat(1, 2), p)), m), div(lit(prod(rat(sign, 2), q)), s2m))
+		roots := quadraticNodes(expr.Simplify(bb), expr.Simplify(cc))
+		if roots == nil {
+			return nil
+		}
+		out = append(out, roots...)
+	}
+	return shifted(out)
+}
+
+// resolventRoot returns a positive real root of the resolvent cubic,
+// rational when possible.
+func resolventRoot(res Poly) expr.Node {
+	zs, err := aberth(res.complex())
+	if err != nil {
+		return nil
+	}
+	for _, z := range zs {
+		if math.Abs(imag(z)) < 1e-9*max(1, math.Abs(real(z))) && real(z) > 0 {
+			if r, ok := rationalRoot(res, real(z)); ok {
+				return lit(r)
+			}
+		}
+	}
+	var best expr.Node
+	bestVal := 0.0
+	for _, e := range closedForm(res) {
+		if e.im != nil {
+			continue
+		}
+		if v, ok := e.value(); ok && real(v) > bestVal {
+			best, bestVal = e.re, real(v)
+		}
+	}
+	return best
+}
@@ -0,0 +1,254 @@
+package solver
+
+import (
+	"math/big"
+	"math/cmplx"
+
+	"erikkruuse/calculator/expr"
+)
+
+// MaxDegree is the highest polynomial degree solved as a polynomial; larger
+// equations go to the iterative methods.
+const MaxDegree = 64
+
+// maxCoefBits bounds coefficient growth during exact gcd computations.
+const maxCoefBits = 8192
+
+// Poly is a polynomial with exact rational coefficients, lowest degree
+// first. The zero polynomial is empty.
+type Poly []*big.Rat
+
+// FromExpr expands n as a polynomial in v. It fails when n uses other
+// variables, constants such as pi, functions, non-integer or negative
+// powers of v, division by a non-constant, or exceeds MaxDegree.
+func FromExpr(n expr.Node, v string) (Poly, bool) {
+	switch n := n.(type) {
+	case *expr.Num:
+		return Poly{new(big.Rat).Set(n.Value)}.trim(), true
+	case *expr.Var:
+		if n.Name != v {
+			return nil, false
+		}
+		return Poly{new(big.Rat), big.NewRat(1, 1)}, true
+	case *expr.Neg:
+		p, ok := FromExpr(n.X, v)
+		return p.scale(big.NewRat(-1, 1)), ok
+	case *expr.Binary:
+		l, ok := FromExpr(n.L, v)
+		if !ok {
+			return nil, false
+		}
+		if n.Op == '^' {
+			e, isNum := n.R.(*expr.Num)
+			if !isNum || !e.Value.IsInt() || e.Value.Sign() < 0 || e.Value.Num().Cmp(big.NewInt(MaxDegree)) > 0 ||
+				e.Value.Num().Int64()*int64(l.Degree()) > MaxDegree {
+				return nil, false
+			}
+			out := Poly{big.NewRat(1, 1)}
+			for range e.Value.Num().Int64() {
+				out = out.mul(l)
+			}
+			return out, true
+		}
+		r, ok := FromExpr(n.R, v)
+		if !ok {
+			return nil, false
+		}
+		switch n.Op {
+		case '+':
+			return l.add(r), true
+		case '-':
+			return l.add(r.scale(big.NewRat(-1, 1))), true
+		case '*':
+			if l.Degree()+r.Degree() > MaxDegree {
+				return nil, false
+			}
+			return l.mul(r), true
+		case '/':
+			if r.Degree() != 0 {
+				return nil, false
+			}
+			return l.scale(new(big.Rat).Inv(r[0])), true
+		}
+	}
+	return nil, false
+}
+
+// Degree returns the degree, or -1 for the zero polynomial.
+func (p Poly) Degree() int { return len(p) - 1 }
+
+// trim drops zero leading coefficients.
+func (p Poly) trim() Poly {
+	for len(p) > 0 && p[len(p)-1].Sign() == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+func (p Poly) add(q Poly) Poly {
+	out := make(Poly, max(len(p), len(q)))
+	for i := range out {
+		out[i] = new(big.Rat)
+		if i < len(p) {
+			out[i].Add(out[i], p[i])
+		}
+		if i < len(q) {
+			out[i].Add(out[i], q[i])
+		}
+	}
+	return out.trim()
+}
+
+func (p Poly) scale(c *big.Rat) Poly {
+	out := make(Poly, len(p))
+	for i, a := range p {
+		out[i] = new(big.Rat).Mul(a, c)
+	}
+	return out.trim()
+}
+
+func (p Poly) mul(q Poly) Poly {
+	if len(p) == 0 || len(q) == 0 {
+		return nil
+	}
+	out := make(Poly, len(p)+len(q)-1)
+	for i := range out {
+		out[i] = new(big.Rat)
+	}
+	var t big.Rat
+	for i, a := range p {
+		for j, b := range q {
+			out[i+j].Add(out[i+j], t.Mul(a, b))
+		}
+	}
+	return out.trim()
+}
+
+// divmod divides p by the non-zero polynomial d.
+func (p Poly) divmod(d Poly) (quo, rem Poly) {
+	rem = p.add(nil)
+	if len(rem) < len(d) {
+		return nil, rem
+	}
+	quo = make(Poly, len(rem)-len(d)+1)
+	lead := d[len(d)-1]
+	var t big.Rat
+	for i := len(quo) - 1; i >= 0; i-- {
+		c := new(big.Rat).Quo(rem[i+len(d)-1], lead)
+		quo[i] = c
+		for j, b := range d {
+			rem[i+j].Sub(rem[i+j], t.Mul(c, b))
+		}
+	}
+	return quo.trim(), rem.trim()
+}
+
+func (p Poly) derivative() Poly {
+	if len(p) < 2 {
+		return nil
+	}
+	out := make(Poly, len(p)-1)
+	for i := range out {
+		out[i] = new(big.Rat).Mul(p[i+1], big.NewRat(int64(i+1), 1))
+	}
+	return out.trim()
+}
+
+func (p Poly) monic() Poly {
+	if len(p) == 0 {
+		return p
+	}
+	return p.scale(new(big.Rat).Inv(p[len(p)-1]))
+}
+
+// tooLarge reports whether a coefficient has outgrown maxCoefBits.
+func (p Poly) tooLarge() bool {
+	for _, c := range p {
+		if c.Num().BitLen() > maxCoefBits || c.Denom().BitLen() > maxCoefBits {
+			return true
+		}
+	}
+	return false
+}
+
+// gcd returns the monic greatest common divisor of p and q.
+func gcd(p, q Poly) (Poly, bool) {
+	for len(q) > 0 {
+		_, r := p.divmod(q)
+		p, q = q, r.monic()
+		if q.tooLarge() {
+			return nil, false
+		}
+	}
+	return p.monic(), true
+}
+
+// factor is a square-free polynomial and the multiplicity of its roots.
+type factor struct {
+	p    Poly
+	mult int
+}
+
+// squareFree splits p into square-free factors by Yun's algorithm, so that
+// p is a constant times the product of f.p^f.mult and each root of f.p is
+// simple.
+func squareFree(p Poly) ([]factor, bool) {
+	dp := p.derivative()
+	a, ok := gcd(p, dp)
+	if !ok {
+		return nil, false
+	}
+	b, _ := p.divmod(a)
+	c, _ := dp.divmod(a)
+	var out []factor
+	for i := 1; b.Degree() > 0; i++ {
+		d := c.add(b.derivative().scale(big.NewRat(-1, 1)))
+		g, ok := gcd(b, d)
+		if !ok {
+			return nil, false
+		}
+		if g.Degree() > 0 {
+			out = append(out, factor{g, i})
+		}
+		b, _ = b.divmod(g)
+		c, _ = d.divmod(g)
+	}
+	return out, true
+}
+
+func (p Poly) evalRat(x *big.Rat) *big.Rat {
+	acc := new(big.Rat)
+	for i := len(p) - 1; i >= 0; i-- {
+		acc.Mul(acc, x).Add(acc, p[i])
+	}
+	return acc
+}
+
+// complex returns the coefficients as complex128 for numeric root finding.
+func (p Poly) complex() []complex128 {
+	out := make([]complex128, len(p))
+	for i, c := range p {
+		f, _ := c.Float64()
+		out[i] = complex(f, 0)
+	}
+	return out
+}
+
+// horner evaluates the polynomial with coefficients c and its derivative at z.
+func horner(c []complex128, z complex128) (v, dv complex128) {
+	for i := len(c) - 1; i >= 0; i-- {
+		dv = dv*z + v
+		v = v*z + c[i]
+	}
+	return v, dv
+}
+
+// cauchyBound returns a radius enclosing every root of c.
+func cauchyBound(c []complex128) float64 {
+	lead := cmplx.Abs(c[len(c)-1])
+	bound := 0.0
+	for _, a := range c[:len(c)-1] {
+		bound = max(bound, cmplx.Abs(a)/lead)
+	}
+	return 1 + bound
+}
@@ -0,0 +1,99 @@
+package solver
+
+import (
+	"math"
+	"math/big"
+	"math/cmplx"
+)
+
+// aberthMaxIter caps the simultaneous iteration; convergence is cubic for
+// simple roots, so a square-free input needs far fewer.
+const aberthMaxIter = 500
+
+// aberth returns all complex roots of the polynomial with coefficients c
+// (lowest degree first, simple roots) by the Aberth–Ehrlich method.
+func aberth(c []complex128) ([]complex128, error) {
+	n := len(c) - 1
+	z := make([]complex128, n)
+	r := cauchyBound(c)
+	for k := range z {
+		// Start on a circle, off the real axis so conjugate pairs separate.
+		z[k] = cmplx.Rect(r, 2*math.Pi*float64(k)/float64(n)+0.4)
+	}
+	for iter := 1; iter <= aberthMaxIter; iter++ {
+		worst := 0.0
+		for k := range z {
+			v, dv := horner(c, z[k])
+			if v == 0 {
+				continue
+			}
+			ratio := v / dv
+			var sum complex128
+			for j := range z {
+				if j != k {
+					sum += 1 / (z[k] - z[j])
+				}
+			}
+			w := ratio / (1 - ratio*sum)
+			z[k] -= w
+			worst = max(worst, cmplx.Abs(w)/max(1, cmplx.Abs(z[k])))
+		}
+		if math.IsNaN(worst) || math.IsInf(worst, 0) {
+			break
+		}
+		if worst < 1e-13 {
+			return z, nil
+		}
+	}
+	return nil, &NoConvergenceError{Method: "polynomial", Iterations: aberthMaxIter, Estimate: math.NaN()}
+}
+
+// polish refines a root with a few Newton steps.
+func polish(c []complex128, z complex128) complex128 {
+	for range 3 {
+		v, dv := horner(c, z)
+		if v == 0 || dv == 0 {
+			break
+		}
+		next := z - v/dv
+		if cmplx.IsNaN(next) || cmplx.IsInf(next) {
+			break
+		}
+		z = next
+	}
+	return z
+}
+
+// maxRationalDenom bounds the denominators tried when recognizing a
+// numeric root as rational.
+const maxRationalDenom = 1 << 30
+
+// rationalRoot returns an exact rational root of p near x, trying the
+// continued-fraction convergents of x. Any rational root p/q close enough
+// to x is one of them.
+func rationalRoot(p Poly, x float64) (*big.Rat, bool) {
+	if math.IsNaN(x) || math.Abs(x) > 1<<53 {
+		return nil, false
+	}
+	h0, h1 := big.NewInt(0), big.NewInt(1) // numerators
+	k0, k1 := big.NewInt(1), big.NewInt(0) // denominators
+	f := x
+	for range 40 {
+		a := math.Floor(f)
+		ai := big.NewInt(int64(a))
+		h0, h1 = h1, new(big.Int).Add(new(big.Int).Mul(ai, h1), h0)
+		k0, k1 = k1, new(big.Int).Add(new(big.Int).Mul(ai, k1), k0)
+		if k1.Cmp(big.NewInt(maxRationalDenom)) > 0 {
+			break
+		}
+		c := new(big.Rat).SetFrac(h1, k1)
+		if p.evalRat(c).Sign() == 0 {
+			return c, true
+		}
+		if f == a {
+			break
+		}
+		f = 1 / (f - a)
+	}
+	return nil, false
+}
@@ -0,0 +1,409 @@
+// Package solver finds roots of equations in one variable: polynomials
+// exactly where possible, and other equations by bracketing, Newton or
+// secant iteration.
+package solver
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"sort"
+	"strings"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/expr"
+)
+
+// ParseEquation parses "lhs = rhs" into the expression lhs − rhs, whose
+// roots solve the equation. Without "=", the expression is solved for zero.
+func ParseEquation(s string) (expr.Node, error) {
+	l, r, found := strings.Cut(s, "=")
+	if !found {
+		return expr.Parse(s)
+	}
+	if i := strings.Index(r, "="); i >= 0 {
+		return nil, &expr.SyntaxError{Offset: len(l) + 1 + i, Msg: "an equation has at most one ="}
+	}
+	ln, err := expr.Parse(l)
+	if err != nil {
+		return nil, err
+	}
+	rn, err := expr.Parse(r)
+	var se *expr.SyntaxError
+	if errors.As(err, &se) {
+		return nil, &expr.SyntaxError{Offset: se.Offset + len(l) + 1, Msg: se.Msg}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &expr.Binary{Op: '-', L: ln, R: rn}, nil
+}
+
+// Root is a root of a polynomial.
+type Root struct {
+	Value        complex128
+	Re, Im       expr.Node // closed form, nil when unknown; Im is nil for real roots
+	Multiplicity int
+}
+
+// IsReal reports whether the root is real.
+func (r Root) IsReal() bool { return imag(r.Value) == 0 }
+
+// Exact returns the closed form as infix text, such as "1 - sqrt(2)" or
+// "-1/2 + sqrt(3)/2*i", or "" when there is none.
+func (r Root) Exact() string {
+	if n := r.exactNode(); n != nil {
+		return n.String()
+	}
+	return ""
+}
+
+// LaTeX returns the closed form as LaTeX, or "" when there is none.
+func (r Root) LaTeX() string {
+	if n := r.exactNode(); n != nil {
+		return expr.LaTeX(n)
+	}
+	return ""
+}
+
+// exactNode joins the real and imaginary closed forms as re ± im·i.
+func (r Root) exactNode() expr.Node {
+	if r.Re == nil || r.Im == nil {
+		return r.Re
+	}
+	im, neg := r.Im, false
+	if v, err := expr.Eval(im, nil); err == nil && v < 0 {
+		im, neg = expr.Simplify(&expr.Neg{X: im}), true
+	}
+	var imI expr.Node = &expr.Var{Name: "i"}
+	if n, ok := im.(*expr.Num); !ok || n.Value.Cmp(big.NewRat(1, 1)) != 0 {
+		imI = mul(im, imI)
+	}
+	switch {
+	case r.Re.String() == "0" && neg:
+		return &expr.Neg{X: imI}
+	case r.Re.String() == "0":
+		return imI
+	case neg:
+		return sub(r.Re, imI)
+	}
+	return add(r.Re, imI)
+}
+
+// PolynomialRoots returns every root of p, real and complex, each once with
+// its multiplicity. Real roots come first in increasing order. Roots get a
+// closed form when they are rational or come from a factor of degree four
+// or less; the rest are numeric only.
+func PolynomialRoots(p Poly) ([]Root, error) {
+	switch p.Degree() {
+	case -1:
+		return nil, &MultipleRootsError{Identity: true}
+	case 0:
+		return []Root{}, nil
+	}
+	factors, ok := squareFree(p)
+	if !ok {
+		// Coefficients grew too large for exact factoring; treat p as
+		// square-free and accept that repeated roots converge slowly.
+		factors = []factor{{p, 1}}
+	}
+
+	var roots []Root
+	for _, f := range factors {
+		rs, err := factorRoots(f.p)
+		if err != nil {
+			return nil, err
+		}
+		for i := range rs {
+			rs[i].Multiplicity = f.mult
+		}
+		roots = append(roots, rs...)
+	}
+	sort.SliceStable(roots, func(i, j int) bool {
+		a, b := roots[i], roots[j]
+		if a.IsReal() != b.IsReal() {
+			return a.IsReal()
+		}
+		if real(a.Value) != real(b.Value) {
+			return real(a.Value) < real(b.Value)
+		}
+		return imag(a.Value) < imag(b.Value)
+	})
+	return roots, nil
+}
+
+// factorRoots finds the simple roots of the square-free polynomial p.
+func factorRoots(p Poly) ([]Root, error) {
+	c := p.complex()
+	zs, err := aberth(c)
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]Root, len(zs))
+	for i, z := range zs {
+		roots[i].Value = polish(c, z)
+	}
+
+	// Divide out rational roots, then match closed forms for the rest.
+	rest := p
+	var open []int
+	for i := range roots {
+		z := roots[i].Value
+		if nearlyReal(z, 1e-7) {
+			if r, ok := rationalRoot(rest, real(z)); ok {
+				f, _ := r.Float64()
+				roots[i] = Root{Value: complex(f, 0), Re: lit(r)}
+				rest, _ = rest.divmod(Poly{new(big.Rat).Neg(r), big.NewRat(1, 1)})
+				continue
+			}
+		}
+		open = append(open, i)
+	}
+	if len(open) > 0 && rest.Degree() <= 4 {
+		matchClosedForms(roots, open, closedForm(rest))
+	}
+	for _, i := range open {
+		if roots[i].Re == nil && nearlyReal(roots[i].Value, 1e-10) {
+			roots[i].Value = complex(real(roots[i].Value), 0)
+		}
+	}
+	return roots, nil
+}
+
+func nearlyReal(z complex128, tol float64) bool {
+	return math.Abs(imag(z)) <= tol*max(1, cmplx.Abs(z))
+}
+
+// matchClosedForms pairs each numeric root in open with the closed form
+// that evaluates closest to it. A closed form that matches nothing is
+// dropped rather than reported.
+func matchClosedForms(roots []Root, open []int, forms []exact) {
+	used := make([]bool, len(forms))
+	for _, i := range open {
+		z := roots[i].Value
+		best, bestDist := -1, 1e-6*max(1, cmplx.Abs(z))
+		for j, e := range forms {
+			v, ok := e.value()
+			if used[j] || !ok {
+				continue
+			}
+			if d := cmplx.Abs(v - z); d <= bestDist {
+				best, bestDist = j, d
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		used[best] = true
+		roots[i].Re, roots[i].Im = forms[best].re, forms[best].im
+		if forms[best].im == nil {
+			roots[i].Value = complex(real(z), 0)
+		}
+	}
+}
+
+// Func is a real function whose root is sought.
+type Func func(x float64) (float64, error)
+
+// Options control the iterative methods.
+type Options struct {
+	// Tolerance is the relative step size, scaled by max(1, |x|), at which
+	// iteration stops.
+	Tolerance float64
+	// MaxIter caps the iterations before a NoConvergenceError.
+	MaxIter int
+}
+
+// DefaultOptions are used by the API when a request sets no limits.
+var DefaultOptions = Options{Tolerance: 1e-12, MaxIter: 100}
+
+// Result is a root found by an iterative method.
+type Result struct {
+	Root       float64
+	Iterations int
+	Residual   float64 // |f(Root)|
+}
+
+// NoConvergenceError is returned when an iterative method stops without
+// meeting its tolerance.
+type NoConvergenceError struct {
+	Method     string
+	Iterations int
+	Estimate   float64 // last iterate, NaN when there is none
+}
+
+func (e *NoConvergenceError) Error() string { return "root finding did not converge" }
+
+// MultipleRootsError is returned when an interval holds more than one root,
+// listing a sub-interval around each, or when every value is a root.
+type MultipleRootsError struct {
+	Brackets [][2]float64
+	Identity bool
+}
+
+func (e *MultipleRootsError) Error() string {
+	if e.Identity {
+		return "every value of the variable is a solution"
+	}
+	return "the interval contains more than one root"
+}
+
+var (
+	// ErrNoSignChange is returned by Bracket when f keeps its sign across
+	// the interval. Roots of even multiplicity, such as x² = 0, do not
+	// change sign and need Newton's method instead.
+	ErrNoSignChange = &calculator.Error{Kind: calculator.KindDomain, Op: "solve", Field: "interval", Msg: "the function does not change sign on the interval"}
+	// ErrDiscontinuity is returned when bracketing closes in on a sign
+	// change that is a pole rather than a root, as for 1/x on [-1, 1].
+	ErrDiscontinuity = &calculator.Error{Kind: calculator.KindDomain, Op: "solve", Field: "interval", Msg: "the function changes sign at a discontinuity, not a root"}
+)
+
+// scanSteps is the number of sub-intervals Bracket samples to find every
+// sign change before refining one.
+const scanSteps = 256
+
+// Bracket finds the root of f in [a, b]. It samples the interval to locate
+// the sign changes, reports a MultipleRootsError when there are several and
+// refines a single one with the Illinois variant of regula falsi.
+func Bracket(f Func, a, b float64, o Options) (Result, error) {
+	if a > b {
+		a, b = b, a
+	}
+	var brackets [][2]float64
+	prevX, prevF := a, 0.0
+	for i := 0; i <= scanSteps; i++ {
+		x := a + (b-a)*float64(i)/scanSteps
+		fx, err := f(x)
+		if err != nil {
+			return Result{}, err
+		}
+		switch {
+		case fx == 0:
+			brackets = append(brackets, [2]float64{x, x})
+		case i > 0 && prevF != 0 && (fx < 0) != (prevF < 0):
+			brackets = append(brackets, [2]float64{prevX, x})
+		}
+		prevX, prevF = x, fx
+	}
+	switch len(brackets) {
+	case 0:
+		return Result{}, ErrNoSignChange
+	case 1:
+	default:
+		return Result{}, &MultipleRootsError{Brackets: brackets}
+	}
+
+	lo, hi := brackets[0][0], brackets[0][1]
+	if lo == hi {
+		return Result{Root: lo}, nil
+	}
+	flo, _ := f(lo)
+	fhi, _ := f(hi)
+	bound := max(math.Abs(flo), math.Abs(fhi))
+	x, side := lo, 0
+	for iter := 1; iter <= o.MaxIter; iter++ {
+		prev := x
+		x = (lo*fhi - hi*flo) / (fhi - flo)
+		fx, err := f(x)
+		if err != nil {
+			return Result{}, err
+		}
+		switch {
+		case fx == 0:
+		case (fx < 0) == (flo < 0):
+			lo, flo = x, fx
+			if side == -1 {
+				fhi /= 2
+			}
+			side = -1
+		default:
+			hi, fhi = x, fx
+			if side == 1 {
+				flo /= 2
+			}
+			side = 1
+		}
+		if fx == 0 || hi-lo <= o.Tolerance*max(1, math.Abs(x)) || math.Abs(x-prev) <= o.Tolerance*max(1, math.Abs(x)) {
+			if math.Abs(fx) > bound {
+				return Result{}, ErrDiscontinuity
+			}
+			return Result{Root: x, Iterations: iter, Residual: math.Abs(fx)}, nil
+		}
+	}
+	return Result{}, &NoConvergenceError{Method: "bracket", Iterations: o.MaxIter, Estimate: x}
+}
+
+// Newton finds a root of f from x0 using its derivative df.
+func Newton(f, df Func, x0 float64, o Options) (Result, error) {
+	x := x0
+	for iter := 1; iter <= o.MaxIter; iter++ {
+		fx, err := f(x)
+		if err != nil {
+			return Result{}, diverged(err, "newton", iter, x)
+		}
+		if fx == 0 {
+			return Result{Root: x, Iterations: iter - 1}, nil
+		}
+		d, err := df(x)
+		if err != nil {
+			return Result{}, diverged(err, "newton", iter, x)
+		}
+		if d == 0 {
+			// A flat tangent never reaches the axis.
+			return Result{}, &NoConvergenceError{Method: "newton", Iterations: iter, Estimate: x}
+		}
+		step := fx / d
+		x -= step
+		if math.Abs(step) <= o.Tolerance*max(1, math.Abs(x)) {
+			return converged(f, x, iter)
+		}
+	}
+	return Result{}, &NoConvergenceError{Method: "newton", Iterations: o.MaxIter, Estimate: x}
+}
+
+// Secant finds a root of f from the starting points x0 and x1 without a
+// derivative.
+func Secant(f Func, x0, x1 float64, o Options) (Result, error) {
+	f0, err := f(x0)
+	if err != nil {
+		return Result{}, err
+	}
+	for iter := 1; iter <= o.MaxIter; iter++ {
+		f1, err := f(x1)
+		if err != nil {
+			return Result{}, diverged(err, "secant", iter, x1)
+		}
+		if f1 == 0 {
+			return Result{Root: x1, Iterations: iter - 1}, nil
+		}
+		if f1 == f0 {
+			return Result{}, &NoConvergenceError{Method: "secant", Iterations: iter, Estimate: x1}
+		}
+		step := f1 * (x1 - x0) / (f1 - f0)
+		x0, f0 = x1, f1
+		x1 -= step
+		if math.Abs(step) <= o.Tolerance*max(1, math.Abs(x1)) {
+			return converged(f, x1, iter)
+		}
+	}
+	return Result{}, &NoConvergenceError{Method: "secant", Iterations: o.MaxIter, Estimate: x1}
+}
+
+// diverged reports an overflow at a later iterate as the method running away
+// from the root rather than as an arithmetic error.
+func diverged(err error, method string, iter int, x float64) error {
+	var ce *calculator.Error
+	if iter > 1 && errors.As(err, &ce) && ce.Kind == calculator.KindOverflow {
+		return &NoConvergenceError{Method: method, Iterations: iter - 1, Estimate: x}
+	}
+	return err
+}
+
+func converged(f Func, x float64, iter int) (Result, error) {
+	fx, err := f(x)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Root: x, Iterations: iter, Residual: math.Abs(fx)}, nil
+}
@@ -0,0 +1,248 @@
+package solver
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+	"erikkruuse/calculator/expr"
+)
+
+func poly(t *testing.T, s string) Poly {
+	t.Helper()
+	n, err := ParseEquation(s)
+	if err != nil {
+		t.Fatalf("ParseEquation(%q): %v", s, err)
+	}
+	p, ok := FromExpr(n, "x")
+	if !ok {
+		t.Fatalf("%q is not a polynomial in x", s)
+	}
+	return p
+}
+
+func fn(t *testing.T, s string) Func {
+	t.Helper()
+	n, err := ParseEquation(s)
+	if err != nil {
+		t.Fatalf("ParseEquation(%q): %v", s, err)
+	}
+	return func(x float64) (float64, error) { return expr.Eval(n, map[string]float64{"x": x}) }
+}
+
+func TestParseEquation(t *testing.T) {
+	n, err := ParseEquation("x^2 = 2x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.String(); got != "x^2 - (2*x + 1)" {
+		t.Errorf("got %q", got)
+	}
+	for _, tc := range []struct {
+		in     string
+		offset int
+	}{
+		{"x = ", 4},
+		{"x = 1 +", 7},
+		{"x = 1 = 2", 6},
+		{"x + = 1", 4},
+	} {
+		_, err := ParseEquation(tc.in)
+		var se *expr.SyntaxError
+		if !errors.As(err, &se) || se.Offset != tc.offset {
+			t.Errorf("ParseEquation(%q) = %v; want syntax error at %d", tc.in, err, tc.offset)
+		}
+	}
+}
+
+func TestFromExpr(t *testing.T) {
+	p := poly(t, "(x - 1)^2*(x + 2)/2")
+	want := []string{"1", "-3/2", "0", "1/2"}
+	if len(p) != len(want) {
+		t.Fatalf("got %v", p)
+	}
+	for i, c := range p {
+		if c.RatString() != want[i] {
+			t.Errorf("coefficient %d = %s; want %s", i, c.RatString(), want[i])
+		}
+	}
+	for _, s := range []string{"sin(x)", "x^-1", "1/x", "x^0.5", "x*y", "pi*x", "x^65"} {
+		n, _ := expr.Parse(s)
+		if _, ok := FromExpr(n, "x"); ok {
+			t.Errorf("FromExpr(%q) succeeded", s)
+		}
+	}
+}
+
+func TestPolynomialRoots(t *testing.T) {
+	type root struct {
+		value complex128
+		exact string
+		mult  int
+	}
+	cases := []struct {
+		eq   string
+		want []root
+	}{
+		{"2x - 3", []root{{1.5, "1.5", 1}}},
+		{"x^2 = 2", []root{{complex(-math.Sqrt2, 0), "-sqrt(2)", 1}, {complex(math.Sqrt2, 0), "sqrt(2)", 1}}},
+		{"x^2 + 1", []root{{-1i, "-i", 1}, {1i, "i", 1}}},
+		{"18x^2 = 4", []root{{complex(-math.Sqrt2/3, 0), "-sqrt(2)/3", 1}, {complex(math.Sqrt2/3, 0), "sqrt(2)/3", 1}}},
+		{"x^2 + x + 1", []root{
+			{complex(-0.5, -math.Sqrt(3)/2), "-0.5 - sqrt(3)/2*i", 1},
+			{complex(-0.5, math.Sqrt(3)/2), "-0.5 + sqrt(3)/2*i", 1},
+		}},
+		{"(x - 1)^2*(x + 2)", []root{{-2, "-2", 1}, {1, "1", 2}}},
+		{"x^4 - 5x^2 + 6", []root{
+			{complex(-math.Sqrt(3), 0), "-sqrt(3)", 1}, {complex(-math.Sqrt2, 0), "-sqrt(2)", 1},
+			{complex(math.Sqrt2, 0), "sqrt(2)", 1}, {complex(math.Sqrt(3), 0), "sqrt(3)", 1},
+		}},
+		{"x^3 - 2x - 5", []root{
+			{2.0945514815423265, "", 1},
+			{complex(-1.0472757407711633, -1.1359398890889283), "", 1},
+			{complex(-1.0472757407711633, 1.1359398890889283), "", 1},
+		}},
+		{"x^5 - x - 1", []root{
+			{1.1673039782614187, "", 1},
+			{complex(-0.7648844336005847, -0.3524715460317263), "", 1},
+			{complex(-0.7648844336005847, 0.3524715460317263), "", 1},
+			{complex(0.18123244446987538, -1.0839541013177107), "", 1},
+			{complex(0.18123244446987538, 1.0839541013177107), "", 1},
+		}},
+		{"x^6 - 1", []root{{-1, "-1", 1}, {1, "1", 1}}},
+		{"7", []root{}},
+	}
+	for _, tc := range cases {
+		got, err := PolynomialRoots(poly(t, tc.eq))
+		if err != nil {
+			t.Errorf("%s: %v", tc.eq, err)
+			continue
+		}
+		if tc.eq == "x^6 - 1" {
+			// Only the real roots are pinned; the other four are complex.
+			if len(got) != 6 {
+				t.Errorf("%s: %d roots", tc.eq, len(got))
+			}
+			got = got[:2]
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %d roots, want %d", tc.eq, len(got), len(tc.want))
+			continue
+		}
+		for i, w := range tc.want {
+			g := got[i]
+			if cmplx.Abs(g.Value-w.value) > 1e-12 || g.Multiplicity != w.mult {
+				t.Errorf("%s root %d = %v ×%d; want %v ×%d", tc.eq, i, g.Value, g.Multiplicity, w.value, w.mult)
+			}
+			if w.exact != "" && g.Exact() != w.exact {
+				t.Errorf("%s root %d exact = %q; want %q", tc.eq, i, g.Exact(), w.exact)
+			}
+			if (imag(w.value) == 0) != g.IsReal() {
+				t.Errorf("%s root %d real = %v", tc.eq, i, g.IsReal())
+			}
+		}
+	}
+}
+
+// TestClosedForms checks that every closed form evaluates to its root.
+func TestClosedForms(t *testing.T) {
+	for _, eq := range []string{
+		"x^3 - 2x - 5",
+		"x^3 - 3x + 1",
+		"x^3 - 2",
+		"x^4 + x + 1",
+		"x^4 - 10x^2 + 1",
+		"x^4 - 2x^3 + 3",
+		"3x^2 - 7x + 1",
+	} {
+		roots, err := PolynomialRoots(poly(t, eq))
+		if err != nil {
+			t.Fatalf("%s: %v", eq, err)
+		}
+		for _, r := range roots {
+			if r.Exact() == "" {
+				t.Errorf("%s: no closed form for %v", eq, r.Value)
+				continue
+			}
+			v, ok := exact{r.Re, r.Im}.value()
+			if !ok || cmplx.Abs(v-r.Value) > 1e-9*max(1, cmplx.Abs(r.Value)) {
+				t.Errorf("%s: %s = %v; want %v", eq, r.Exact(), v, r.Value)
+			}
+			if r.LaTeX() == "" {
+				t.Errorf("%s: no LaTeX for %s", eq, r.Exact())
+			}
+		}
+	}
+}
+
+func TestPolynomialIdentity(t *testing.T) {
+	_, err := PolynomialRoots(poly(t, "(x + 1)^2 = x^2 + 2x + 1"))
+	var mr *MultipleRootsError
+	if !errors.As(err, &mr) || !mr.Identity {
+		t.Errorf("got %v; want an identity", err)
+	}
+}
+
+func TestIterative(t *testing.T) {
+	const want = 2.0945514815423265
+	f := fn(t, "x^3 - 2x - 5")
+	df := fn(t, "3x^2 - 2")
+	o := DefaultOptions
+	check := func(method string, r Result, err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("%s: %v", method, err)
+			return
+		}
+		if math.Abs(r.Root-want) > 1e-12 || r.Iterations == 0 || r.Residual > 1e-12 {
+			t.Errorf("%s = %+v", method, r)
+		}
+	}
+	r, err := Bracket(f, 2, 3, o)
+	check("bracket", r, err)
+	r, err = Newton(f, df, 2, o)
+	check("newton", r, err)
+	r, err = Secant(f, 2, 3, o)
+	check("secant", r, err)
+}
+
+func TestBracketErrors(t *testing.T) {
+	o := DefaultOptions
+	_, err := Bracket(fn(t, "cos(x)"), 0, 10, o)
+	var mr *MultipleRootsError
+	if !errors.As(err, &mr) || len(mr.Brackets) != 3 {
+		t.Errorf("cos on [0, 10]: got %v; want three brackets", err)
+	}
+	if err == nil || mr.Brackets[0][0] > math.Pi/2 || mr.Brackets[0][1] < math.Pi/2 {
+		t.Errorf("first bracket %v does not hold pi/2", mr.Brackets)
+	}
+	if _, err := Bracket(fn(t, "x^2 + 1"), -1, 1, o); err != ErrNoSignChange {
+		t.Errorf("x^2 + 1: got %v", err)
+	}
+	if _, err := Bracket(fn(t, "1/x"), -1, 1.5, o); err != ErrDiscontinuity {
+		t.Errorf("1/x: got %v", err)
+	}
+	var ce *calculator.Error
+	if _, err := Bracket(fn(t, "ln(x)"), -1, 2, o); !errors.As(err, &ce) || ce.Kind != calculator.KindDomain {
+		t.Errorf("ln on [-1, 2]: got %v", err)
+	}
+}
+
+func TestNoConvergence(t *testing.T) {
+	o := Options{Tolerance: 1e-12, MaxIter: 5}
+	_, err := Newton(fn(t, "atan(x)"), fn(t, "1/(1 + x^2)"), 3, DefaultOptions)
+	var nc *NoConvergenceError
+	if !errors.As(err, &nc) || nc.Method != "newton" {
+		t.Errorf("newton on atan from 3: got %v", err)
+	}
+	_, err = Newton(fn(t, "x^2 + 1"), fn(t, "2x"), 0, o)
+	if !errors.As(err, &nc) || nc.Iterations != 1 || nc.Estimate != 0 {
+		t.Errorf("flat tangent: got %v", err)
+	}
+	_, err = Secant(fn(t, "x^3 - 2x - 5"), 100, 101, o)
+	if !errors.As(err, &nc) || nc.Method != "secant" || nc.Iterations != 5 {
+		t.Errorf("secant capped at 5: got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+// Package stats computes descriptive statistics over float64 datasets using
+// compensated summation and Welford's online variance.
+package stats
+
+import (
+	"math"
+	"slices"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// Options tunes Describe. Zero values select the defaults.
+type Options struct {
+	// Quantiles to report, each in [0, 1] (default 0.25, 0.5, 0.75).
+	Quantiles []float64
+	// Bins is the number of histogram bins (default: Sturges' rule, at most MaxBins).
+	Bins int
+}
+
+// MaxBins bounds the histogram size.
+const MaxBins = 1000
+
+// Variance holds the population and sample variance or standard deviation.
+// Sample is nil for datasets with fewer than two values.
+type Variance struct {
+	Population float64  `json:"population"`
+	Sample     *float64 `json:"sample,omitempty"`
+}
+
+// Quantile is the value below which a fraction P of the data lies.
+type Quantile struct {
+	P     float64 `json:"p"`
+	Value float64 `json:"value"`
+}
+
+// Bin is a histogram bucket covering [Lo, Hi); the last bin includes Hi.
+type Bin struct {
+	Lo    float64 `json:"lo"`
+	Hi    float64 `json:"hi"`
+	Count int     `json:"count"`
+}
+
+// Summary describes a dataset.
+type Summary struct {
+	Count     int        `json:"count"`
+	Sum       float64    `json:"sum"`
+	Mean      float64    `json:"mean"`
+	Median    float64    `json:"median"`
+	Mode      []float64  `json:"mode"` // most frequent values; empty when all values are distinct
+	Variance  Variance   `json:"variance"`
+	StdDev    Variance   `json:"stddev"`
+	Min       float64    `json:"min"`
+	Max       float64    `json:"max"`
+	Quantiles []Quantile `json:"quantiles"`
+	Histogram []Bin      `json:"histogram"`
+}
+
+// Errors returned by Describe.
+var (
+	ErrEmpty = &calculator.Error{Kind: calculator.KindDomain, Op: "stats", Field: "values", Msg: "dataset must not be empty"}
+
+	errNonFinite = &calculator.Error{Kind: calculator.KindDomain, Op: "stats", Field: "values", Msg: "inputs must be finite numbers"}
+	errQuantile  = &calculator.Error{Kind: calculator.KindDomain, Op: "stats", Field: "quantiles", Msg: "quantiles must be between 0 and 1"}
+	errBins      = &calculator.Error{Kind: calculator.KindDomain, Op: "stats", Field: "bins", Msg: "bins must be between 1 and 1000"}
+	errOverflow  = &calculator.Error{Kind: calculator.KindOverflow, Op: "stats", Msg: "result overflows the float64 range"}
+)
+
+// Describe summarizes xs. xs is not modified.
+func Describe(xs []float64, opts Options) (Summary, error) {
+	if len(xs) == 0 {
+		return Summary{}, ErrEmpty
+	}
+	for _, x := range xs {
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return Summary{}, errNonFinite
+		}
+	}
+	qs := opts.Quantiles
+	if qs == nil {
+		qs = []float64{0.25, 0.5, 0.75}
+	}
+	for _, q := range qs {
+		if !(q >= 0 && q <= 1) {
+			return Summary{}, errQuantile
+		}
+	}
+	bins := opts.Bins
+	if bins == 0 {
+		bins = min(int(math.Ceil(math.Log2(float64(len(xs)))))+1, MaxBins)
+	}
+	if bins < 1 || bins > MaxBins {
+		return Summary{}, errBins
+	}
+
+	sorted := slices.Clone(xs)
+	slices.Sort(sorted)
+
+	n := len(xs)
+	s := Summary{
+		Count:  n,
+		Sum:    Sum(xs),
+		Min:    sorted[0],
+		Max:    sorted[n-1],
+		Median: quantile(sorted, 0.5),
+		Mode:   mode(sorted),
+	}
+
+	mean, m2 := welford(xs)
+	s.Mean = mean
+	s.Variance.Population = m2 / float64(n)
+	s.StdDev.Population = math.Sqrt(s.Variance.Population)
+	if n > 1 {
+		v := m2 / float64(n-1)
+		sd := math.Sqrt(v)
+		s.Variance.Sample, s.StdDev.Sample = &v, &sd
+	}
+
+	s.Quantiles = make([]Quantile, len(qs))
+	for i, q := range qs {
+		s.Quantiles[i] = Quantile{P: q, Value: quantile(sorted, q)}
+	}
+	s.Histogram = histogram(sorted, bins)
+
+	if !s.finite() {
+		return Summary{}, errOverflow
+	}
+	return s, nil
+}
+
+func (s *Summary) finite() bool {
+	vals := []float64{s.Sum, s.Mean, s.Variance.Population, s.StdDev.Population}
+	if s.Variance.Sample != nil {
+		vals = append(vals, *s.Variance.Sample, *s.StdDev.Sample)
+	}
+	for _, b := range s.Histogram {
+		vals = append(vals, b.Hi-b.Lo)
+	}
+	for _, v := range vals {
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sum returns the sum of xs using Neumaier's variant of Kahan summation.
+func Sum(xs []float64) float64 {
+	var sum, c float64
+	for _, x := range xs {
+		t := sum + x
+		if math.Abs(sum) >= math.Abs(x) {
+			c += (sum - t) + x
+		} else {
+			c += (x - t) + sum
+		}
+		sum = t
+	}
+	return sum + c
+}
+
+// welford returns the mean and the sum of squared deviations from it.
+func welford(xs []float64) (mean, m2 float64) {
+	for i, x := range xs {
+		delta := x - mean
+		mean += delta / float64(i+1)
+		m2 += delta * (x - mean)
+	}
+	return mean, m2
+}
+
+// quantile interpolates linearly between closest ranks of sorted data
+// (Hyndman & Fan type 7, as in most spreadsheets).
+func quantile(sorted []float64, q float64) float64 {
+	h := q * float64(len(sorted)-1)
+	lo := int(math.Floor(h))
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (h-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+func mode(sorted []float64) []float64 {
+	best, out := 1, []float64{}
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j] == sorted[i] {
+			j++
+		}
+		switch run := j - i; {
+		case run > best:
+			best, out = run, []float64{sorted[i]}
+		case run == best && best > 1:
+			out = append(out, sorted[i])
+		}
+		i = j
+	}
+	return out
+}
+
+func histogram(sorted []float64, bins int) []Bin {
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	if lo == hi {
+		return []Bin{{Lo: lo, Hi: hi, Count: len(sorted)}}
+	}
+	width := (hi - lo) / float64(bins)
+	out := make([]Bin, bins)
+	for i := range out {
+		out[i].Lo = lo + float64(i)*width
+		out[i].Hi = lo + float64(i+1)*width
+	}
+	out[bins-1].Hi = hi
+	for _, x := range sorted {
+		i := min(int((x-lo)/width), bins-1)
+		out[i].Count++
+	}
+	return out
+}
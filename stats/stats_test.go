@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func TestDescribe(t *testing.T) {
+	s, err := Describe([]float64{2, 4, 4, 4, 5, 5, 7, 9}, Options{Bins: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Count != 8 || s.Sum != 40 || s.Mean != 5 || s.Min != 2 || s.Max != 9 || s.Median != 4.5 {
+		t.Fatalf("summary = %+v", s)
+	}
+	if !slices.Equal(s.Mode, []float64{4}) {
+		t.Fatalf("mode = %v", s.Mode)
+	}
+	if s.Variance.Population != 4 || s.StdDev.Population != 2 {
+		t.Fatalf("population variance = %+v, stddev = %+v", s.Variance, s.StdDev)
+	}
+	if s.Variance.Sample == nil || math.Abs(*s.Variance.Sample-32.0/7) > 1e-12 {
+		t.Fatalf("sample variance = %v", s.Variance.Sample)
+	}
+	want := []Quantile{{0.25, 4}, {0.5, 4.5}, {0.75, 5.5}}
+	if !slices.Equal(s.Quantiles, want) {
+		t.Fatalf("quantiles = %v; want %v", s.Quantiles, want)
+	}
+	if len(s.Histogram) != 2 || s.Histogram[0].Count != 6 || s.Histogram[1].Count != 2 || s.Histogram[1].Hi != 9 {
+		t.Fatalf("histogram = %+v", s.Histogram)
+	}
+}
+
+func TestDescribe_EdgeCases(t *testing.T) {
+	s, err := Describe([]float64{3}, Options{})
+	if err != nil || s.Variance.Sample != nil || s.Median != 3 || len(s.Mode) != 0 || len(s.Histogram) != 1 {
+		t.Fatalf("single value = %+v, %v", s, err)
+	}
+
+	s, _ = Describe([]float64{1, 1, 2, 2, 3}, Options{})
+	if !slices.Equal(s.Mode, []float64{1, 2}) {
+		t.Fatalf("multimodal mode = %v", s.Mode)
+	}
+
+	cases := []struct {
+		xs   []float64
+		opts Options
+		kind calculator.Kind
+	}{
+		{nil, Options{}, calculator.KindDomain},
+		{[]float64{1, math.NaN()}, Options{}, calculator.KindDomain},
+		{[]float64{1}, Options{Quantiles: []float64{1.5}}, calculator.KindDomain},
+		{[]float64{1}, Options{Bins: MaxBins + 1}, calculator.KindDomain},
+		{[]float64{math.MaxFloat64, math.MaxFloat64}, Options{}, calculator.KindOverflow},
+	}
+	for _, tc := range cases {
+		var ce *calculator.Error
+		if _, err := Describe(tc.xs, tc.opts); !errors.As(err, &ce) || ce.Kind != tc.kind {
+			t.Errorf("Describe(%v, %+v) error = %v; want %s", tc.xs, tc.opts, err, tc.kind)
+		}
+	}
+}
+
+func TestNumericalStability(t *testing.T) {
+	// Naive summation loses the small terms entirely.
+	xs := []float64{1e16, 1, 1, 1, 1, -1e16}
+	if got := Sum(xs); got != 4 {
+		t.Fatalf("Sum = %v; want 4", got)
+	}
+
+	// A large offset must not destroy the variance (naive E[x²]-E[x]² fails here).
+	s, err := Describe([]float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *s.Variance.Sample != 30 {
+		t.Fatalf("sample variance = %v; want 30", *s.Variance.Sample)
+	}
+}
@@ -0,0 +1,127 @@
+package temporal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Calendar decides which days are business days: every day that is neither a
+// weekend day nor a holiday.
+type Calendar struct {
+	Name    string
+	weekend [7]bool
+	fixed   map[string]string // "2026-04-03" → name
+	annual  map[string]string // "12-25" → name, every year
+}
+
+// Weekdays is the default calendar: Monday to Friday, no holidays.
+var Weekdays = &Calendar{
+	Name:    "weekdays",
+	weekend: [7]bool{time.Saturday: true, time.Sunday: true},
+}
+
+// calendarFile is the on-disk format of a holiday calendar:
+//
+//	{
+//	  "weekend": ["Saturday", "Sunday"],
+//	  "holidays": [{"date": "12-25", "name": "Christmas Day"}, {"date": "2026-04-03", "name": "Good Friday"}]
+//	}
+//
+// Dates without a year recur annually. A missing weekend means Saturday and Sunday.
+type calendarFile struct {
+	Weekend  []string `json:"weekend"`
+	Holidays []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"holidays"`
+}
+
+// ParseCalendar reads a calendar definition.
+func ParseCalendar(name string, r io.Reader) (*Calendar, error) {
+	var cf calendarFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cf); err != nil {
+		return nil, fmt.Errorf("calendar %s: %w", name, err)
+	}
+
+	c := &Calendar{Name: name, fixed: map[string]string{}, annual: map[string]string{}}
+	if cf.Weekend == nil {
+		c.weekend = Weekdays.weekend
+	}
+	for _, day := range cf.Weekend {
+		wd, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("calendar %s: unknown weekday %q", name, day)
+		}
+		c.weekend[wd] = true
+	}
+	for _, h := range cf.Holidays {
+		if _, err := time.Parse(time.DateOnly, h.Date); err == nil {
+			c.fixed[h.Date] = h.Name
+			continue
+		}
+		// Parse with a leap year so "02-29" is accepted.
+		if _, err := time.Parse(time.DateOnly, "2000-"+h.Date); err == nil && len(h.Date) == 5 {
+			c.annual[h.Date] = h.Name
+			continue
+		}
+		return nil, fmt.Errorf("calendar %s: holiday date %q must be YYYY-MM-DD or MM-DD", name, h.Date)
+	}
+	return c, nil
+}
+
+var weekdays = map[string]time.Weekday{}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		weekdays[strings.ToLower(d.String())] = d
+	}
+}
+
+// LoadCalendars reads every *.json file in dir as a calendar named after the
+// file, e.g. "de.json" becomes "de".
+func LoadCalendars(dir string) (map[string]*Calendar, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]*Calendar{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(p), ".json")
+		c, err := ParseCalendar(name, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		out[name] = c
+	}
+	return out, nil
+}
+
+// Holiday returns the name of the holiday on t's date, if any.
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	if name, ok := c.fixed[t.Format(time.DateOnly)]; ok {
+		return name, true
+	}
+	name, ok := c.annual[t.Format("01-02")]
+	return name, ok
+}
+
+// IsBusinessDay reports whether t's date is a business day.
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if c.weekend[t.Weekday()] {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
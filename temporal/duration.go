@@ -0,0 +1,227 @@
+package temporal
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDuration is returned for text that is neither an ISO 8601
+// duration nor a phrase such as "45 days".
+var ErrInvalidDuration = errors.New("duration must be ISO 8601 (P1Y2M3DT4H) or like \"45 days\"")
+
+// Duration is a calendar duration. Years, months and days are applied in the
+// time zone of the date they are added to, so "P1D" across a DST change is
+// still one calendar day; Clock is an exact elapsed time. All components share
+// one sign.
+type Duration struct {
+	Years, Months, Days int
+	Clock               time.Duration
+}
+
+func (d Duration) IsZero() bool { return d == Duration{} }
+
+func (d Duration) negative() bool {
+	return d.Years < 0 || d.Months < 0 || d.Days < 0 || d.Clock < 0
+}
+
+// Neg returns -d.
+func (d Duration) Neg() Duration {
+	return Duration{Years: -d.Years, Months: -d.Months, Days: -d.Days, Clock: -d.Clock}
+}
+
+// AddTo returns t shifted by d.
+func (d Duration) AddTo(t time.Time) time.Time {
+	return t.AddDate(d.Years, d.Months, d.Days).Add(d.Clock)
+}
+
+// String formats d in ISO 8601, e.g. "P1Y2M3DT4H5M6.5S" or "-P45D".
+func (d Duration) String() string {
+	if d.IsZero() {
+		return "PT0S"
+	}
+	var b strings.Builder
+	if d.negative() {
+		b.WriteByte('-')
+		d = d.Neg()
+	}
+	b.WriteByte('P')
+	for _, part := range []struct {
+		n    int
+		unit byte
+	}{{d.Years, 'Y'}, {d.Months, 'M'}, {d.Days, 'D'}} {
+		if part.n != 0 {
+			b.WriteString(strconv.Itoa(part.n))
+			b.WriteByte(part.unit)
+		}
+	}
+	if d.Clock != 0 {
+		b.WriteByte('T')
+		h := d.Clock / time.Hour
+		m := (d.Clock % time.Hour) / time.Minute
+		s := d.Clock % time.Minute
+		if h != 0 {
+			b.WriteString(strconv.FormatInt(int64(h), 10) + "H")
+		}
+		if m != 0 {
+			b.WriteString(strconv.FormatInt(int64(m), 10) + "M")
+		}
+		if s != 0 {
+			b.WriteString(strconv.FormatFloat(s.Seconds(), 'f', -1, 64) + "S")
+		}
+	}
+	return b.String()
+}
+
+var isoDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+// phrase matches one "<n> <unit>" part of a duration such as "1 year 2 months".
+var phrase = regexp.MustCompile(`^([+-]?\d+)\s*([a-z]+)$`)
+
+var phraseUnits = map[string]string{
+	"y": "Y", "year": "Y", "years": "Y",
+	"month": "M", "months": "M",
+	"w": "W", "week": "W", "weeks": "W",
+	"d": "D", "day": "D", "days": "D",
+	"h": "h", "hour": "h", "hours": "h",
+	"min": "m", "minute": "m", "minutes": "m",
+	"s": "s", "sec": "s", "second": "s", "seconds": "s",
+}
+
+// maxDurationPart bounds each component so AddDate and time.Duration cannot overflow.
+const maxDurationPart = 1_000_000
+
+// ParseDuration parses an ISO 8601 duration ("P45D", "-PT1H30M", "P2W") or a
+// phrase such as "45 days" or "1 year, 2 months".
+func ParseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	// The pattern also admits "P" and "P1DT", which have no component after the designator.
+	if m := isoDuration.FindStringSubmatch(s); m != nil && !strings.HasSuffix(s, "P") && !strings.HasSuffix(s, "T") {
+		return parseISO(m)
+	}
+
+	var d Duration
+	parts := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r == ',' })
+	var tokens []string
+	for _, p := range parts {
+		tokens = append(tokens, strings.Fields(p)...)
+	}
+	if len(tokens) == 0 {
+		return Duration{}, ErrInvalidDuration
+	}
+	sign := 0
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		// Allow "45 days" as well as "45days".
+		if i+1 < len(tokens) && phrase.FindStringSubmatch(tok) == nil {
+			tok += tokens[i+1]
+			i++
+		}
+		m := phrase.FindStringSubmatch(tok)
+		if m == nil {
+			return Duration{}, ErrInvalidDuration
+		}
+		n, err := strconv.Atoi(m[1])
+		unit, ok := phraseUnits[m[2]]
+		if err != nil || !ok || n > maxDurationPart || n < -maxDurationPart {
+			return Duration{}, ErrInvalidDuration
+		}
+		if s := sgn(n); s != 0 {
+			if sign != 0 && s != sign {
+				return Duration{}, ErrInvalidDuration
+			}
+			sign = s
+		}
+		switch unit {
+		case "Y":
+			d.Years += n
+		case "M":
+			d.Months += n
+		case "W":
+			d.Days += 7 * n
+		case "D":
+			d.Days += n
+		case "h":
+			d.Clock += time.Duration(n) * time.Hour
+		case "m":
+			d.Clock += time.Duration(n) * time.Minute
+		case "s":
+			d.Clock += time.Duration(n) * time.Second
+		}
+	}
+	return d, nil
+}
+
+func sgn(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+func parseISO(m []string) (Duration, error) {
+	var (
+		d    Duration
+		nums [6]int
+	)
+	for i, s := range m[2:8] {
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n > maxDurationPart {
+			return Duration{}, ErrInvalidDuration
+		}
+		nums[i] = n
+	}
+	d.Years, d.Months, d.Days = nums[0], nums[1], nums[2]*7+nums[3]
+	d.Clock = time.Duration(nums[4])*time.Hour + time.Duration(nums[5])*time.Minute
+	if m[8] != "" {
+		secs, err := strconv.ParseFloat(strings.Replace(m[8], ",", ".", 1), 64)
+		if err != nil || secs > maxDurationPart {
+			return Duration{}, ErrInvalidDuration
+		}
+		d.Clock += time.Duration(secs * float64(time.Second))
+	}
+	if m[1] == "-" {
+		d = d.Neg()
+	}
+	return d, nil
+}
+
+// Between returns the calendar duration from a to b: whole months first, then
+// days, then the remaining clock time, so that Between(a, b).AddTo(a) equals b
+// for a before b. Both are taken in a's time zone.
+func Between(a, b time.Time) Duration {
+	if b.Before(a) {
+		return Between(b, a.In(b.Location())).Neg()
+	}
+	b = b.In(a.Location())
+
+	months := (b.Year()-a.Year())*12 + int(b.Month()-a.Month())
+	for months > 0 && a.AddDate(0, months, 0).After(b) {
+		months--
+	}
+	mid := a.AddDate(0, months, 0)
+
+	days := civilDays(mid, b)
+	for days > 0 && mid.AddDate(0, 0, days).After(b) {
+		days--
+	}
+	end := mid.AddDate(0, 0, days)
+	return Duration{Years: months / 12, Months: months % 12, Days: days, Clock: b.Sub(end)}
+}
+
+// civilDays counts calendar days between the dates of a and b, ignoring the
+// time of day.
+func civilDays(a, b time.Time) int {
+	ad := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	bd := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	// Unix seconds rather than Sub, which saturates after about 292 years.
+	return int((bd.Unix() - ad.Unix()) / 86400)
+}
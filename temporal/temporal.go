@@ -0,0 +1,144 @@
+// Package temporal implements date and duration arithmetic: adding calendar
+// durations to zoned date-times, differences between date-times and business
+// day counting against holiday calendars. Time zones come from the tz
+// database embedded in the binary.
+package temporal
+
+import (
+	"slices"
+	"time"
+	_ "time/tzdata"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// maxSpanDays bounds business day walks to about 1000 years.
+const maxSpanDays = 366_000
+
+// Ops lists the operations Eval supports.
+var Ops = []string{"add", "subtract", "diff", "business_days", "add_business_days"}
+
+// IsOp reports whether Eval supports op. Every operation takes two operands.
+func IsOp(op string) bool {
+	return slices.Contains(Ops, op)
+}
+
+func typeError(op, field, msg string) error {
+	return &calculator.Error{Kind: calculator.KindDomain, Op: op, Field: field, Msg: msg}
+}
+
+// Eval performs op on a and b:
+//
+//	add                date-time + duration, duration + date-time or duration + duration
+//	subtract           date-time - duration, date-time - date-time (a duration) or duration - duration
+//	diff               duration from date-time a to date-time b
+//	business_days      business days after a up to and including b (negative if b is before a)
+//	add_business_days  date-time a moved by integer b business days
+//
+// cal decides business days; nil means Weekdays.
+func Eval(op string, a, b Value, cal *Calendar) (Value, error) {
+	if cal == nil {
+		cal = Weekdays
+	}
+	switch op {
+	case "add":
+		switch {
+		case a.Type == TypeDateTime && b.Type == TypeDuration:
+			return checkTime(op, b.Duration.AddTo(a.Time))
+		case a.Type == TypeDuration && b.Type == TypeDateTime:
+			return checkTime(op, a.Duration.AddTo(b.Time))
+		case a.Type == TypeDuration && b.Type == TypeDuration:
+			return addDurations(op, a.Duration, b.Duration)
+		}
+		return Value{}, typeError(op, "b", "add needs a date and a duration, or two durations")
+	case "subtract":
+		switch {
+		case a.Type == TypeDateTime && b.Type == TypeDuration:
+			return checkTime(op, b.Duration.Neg().AddTo(a.Time))
+		case a.Type == TypeDateTime && b.Type == TypeDateTime:
+			return Dur(Between(b.Time, a.Time)), nil
+		case a.Type == TypeDuration && b.Type == TypeDuration:
+			return addDurations(op, a.Duration, b.Duration.Neg())
+		}
+		return Value{}, typeError(op, "b", "subtract needs a date minus a duration or a date, or two durations")
+	case "diff", "business_days":
+		if a.Type != TypeDateTime {
+			return Value{}, typeError(op, "a", "operand must be a date")
+		}
+		if b.Type != TypeDateTime {
+			return Value{}, typeError(op, "b", "operand must be a date")
+		}
+		if op == "diff" {
+			return Dur(Between(a.Time, b.Time)), nil
+		}
+		return businessDays(a.Time, b.Time, cal)
+	case "add_business_days":
+		if a.Type != TypeDateTime {
+			return Value{}, typeError(op, "a", "operand must be a date")
+		}
+		if b.Type != TypeInteger {
+			return Value{}, typeError(op, "b", "operand must be an integer")
+		}
+		return addBusinessDays(a.Time, b.Int, cal)
+	}
+	return Value{}, &calculator.Error{Kind: calculator.KindCalculation, Op: op, Field: "op", Msg: "unknown date operation"}
+}
+
+// addDurations adds component-wise. Mixed signs cannot be expressed as one
+// ISO 8601 duration, so such results are rejected.
+func addDurations(op string, a, b Duration) (Value, error) {
+	d := Duration{Years: a.Years + b.Years, Months: a.Months + b.Months, Days: a.Days + b.Days, Clock: a.Clock + b.Clock}
+	if d.negative() && (d.Years > 0 || d.Months > 0 || d.Days > 0 || d.Clock > 0) {
+		return Value{}, typeError(op, "b", "result mixes positive and negative components")
+	}
+	return Dur(d), nil
+}
+
+// checkTime rejects results outside the years 1–9999, which RFC 3339 cannot represent.
+func checkTime(op string, t time.Time) (Value, error) {
+	if t.Year() < 1 || t.Year() > 9999 {
+		return Value{}, &calculator.Error{Kind: calculator.KindOverflow, Op: op, Msg: "date is outside the years 1 to 9999"}
+	}
+	return DateTime(t), nil
+}
+
+func spanError(op string) error {
+	return &calculator.Error{Kind: calculator.KindDomain, Op: op, Field: "b", Msg: "date range is too large"}
+}
+
+func businessDays(a, b time.Time, cal *Calendar) (Value, error) {
+	b = b.In(a.Location())
+	sign := 1
+	if b.Before(a) {
+		a, b, sign = b, a, -1
+	}
+	span := civilDays(a, b)
+	if span > maxSpanDays {
+		return Value{}, spanError("business_days")
+	}
+	n := 0
+	for i := 1; i <= span; i++ {
+		if cal.IsBusinessDay(a.AddDate(0, 0, i)) {
+			n++
+		}
+	}
+	return Int(sign * n), nil
+}
+
+func addBusinessDays(a time.Time, n int, cal *Calendar) (Value, error) {
+	step := 1
+	if n < 0 {
+		n, step = -n, -1
+	}
+	t := a
+	for walked := 0; n > 0; walked++ {
+		if walked > maxSpanDays {
+			return Value{}, spanError("add_business_days")
+		}
+		t = t.AddDate(0, 0, step)
+		if cal.IsBusinessDay(t) {
+			n--
+		}
+	}
+	return checkTime("add_business_days", t)
+}
@@ -0,0 +1,213 @@
+package temporal
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func mustZone(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := LoadZone(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func mustParse(t *testing.T, s string, loc *time.Location) Value {
+	t.Helper()
+	v, err := Parse(s, loc)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return v
+}
+
+func kindOf(err error) calculator.Kind {
+	var ce *calculator.Error
+	if errors.As(err, &ce) {
+		return ce.Kind
+	}
+	return ""
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]string{
+		"P45D":             "P45D",
+		"P2W":              "P14D",
+		"P1Y2M3DT4H5M6.5S": "P1Y2M3DT4H5M6.5S",
+		"-PT90M":           "-PT1H30M",
+		"PT0S":             "PT0S",
+		"45 days":          "P45D",
+		"45days":           "P45D",
+		"1 year, 2 months": "P1Y2M",
+		"3 weeks 2 hours":  "P21DT2H",
+		"-10 minutes":      "-PT10M",
+		"PT0,5S":           "PT0.5S",
+	}
+	for in, want := range cases {
+		d, err := ParseDuration(in)
+		if err != nil || d.String() != want {
+			t.Fatalf("ParseDuration(%q) = %v, %v; want %s", in, d, err, want)
+		}
+	}
+	for _, bad := range []string{"", "P", "PT", "P1DT", "P1H", "45 fortnights", "1 day -2 hours", "days", "P99999999D"} {
+		if _, err := ParseDuration(bad); !errors.Is(err, ErrInvalidDuration) {
+			t.Fatalf("ParseDuration(%q) error = %v", bad, err)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	berlin := mustZone(t, "Europe/Berlin")
+	cases := []struct {
+		in   string
+		typ  Type
+		want string
+	}{
+		{"2026-03-01", TypeDateTime, "2026-03-01T00:00:00+01:00[Europe/Berlin]"},
+		{"2026-07-01T12:30", TypeDateTime, "2026-07-01T12:30:00+02:00[Europe/Berlin]"},
+		{"2026-03-01T12:00:00Z", TypeDateTime, "2026-03-01T13:00:00+01:00[Europe/Berlin]"},
+		{"2026-03-01T09:00:00-05:00[America/New_York]", TypeDateTime, "2026-03-01T09:00:00-05:00[America/New_York]"},
+		{"45 days", TypeDuration, "P45D"},
+		{"P1M", TypeDuration, "P1M"},
+		{"-3", TypeInteger, "-3"},
+	}
+	for _, tc := range cases {
+		v := mustParse(t, tc.in, berlin)
+		if v.Type != tc.typ || v.String() != tc.want {
+			t.Fatalf("Parse(%q) = %s %s; want %s %s", tc.in, v.Type, v, tc.typ, tc.want)
+		}
+	}
+	if _, err := Parse("2026-02-30", time.UTC); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("invalid date error = %v", err)
+	}
+	if _, err := Parse("2026-03-01[Mars/Olympus]", time.UTC); !errors.Is(err, ErrUnknownZone) {
+		t.Fatalf("unknown zone error = %v", err)
+	}
+	if _, err := LoadZone("Local"); !errors.Is(err, ErrUnknownZone) {
+		t.Fatal("Local must not be accepted")
+	}
+
+	b, _ := json.Marshal(mustParse(t, "2026-03-01", berlin))
+	var back Value
+	if err := json.Unmarshal(b, &back); err != nil || back.String() != "2026-03-01T00:00:00+01:00[Europe/Berlin]" {
+		t.Fatalf("JSON round trip of %s = %s, %v", b, back, err)
+	}
+}
+
+func TestEval_AddAndDiff(t *testing.T) {
+	berlin := mustZone(t, "Europe/Berlin")
+	cases := []struct {
+		op, a, b string
+		want     string
+	}{
+		{"add", "2026-03-01", "45 days", "2026-04-15T00:00:00+02:00[Europe/Berlin]"},
+		// One calendar day across the DST change is 23 hours of elapsed time.
+		{"add", "2026-03-28T12:00", "P1D", "2026-03-29T12:00:00+02:00[Europe/Berlin]"},
+		{"add", "2026-03-28T12:00", "PT24H", "2026-03-29T13:00:00+02:00[Europe/Berlin]"},
+		{"add", "P1D", "2026-01-31", "2026-02-01T00:00:00+01:00[Europe/Berlin]"},
+		{"add", "P1M", "P2DT3H", "P1M2DT3H"},
+		{"subtract", "2026-03-01", "P1M", "2026-02-01T00:00:00+01:00[Europe/Berlin]"},
+		{"subtract", "2026-04-15", "2026-03-01", "P1M14D"},
+		{"diff", "2026-03-01", "2027-05-04T06:00", "P1Y2M3DT6H"},
+		{"diff", "2026-01-31", "2026-03-01", "P29D"},
+		{"diff", "2026-03-01", "2026-02-01", "-P1M"},
+	}
+	for _, tc := range cases {
+		got, err := Eval(tc.op, mustParse(t, tc.a, berlin), mustParse(t, tc.b, berlin), nil)
+		if err != nil || got.String() != tc.want {
+			t.Fatalf("%s(%s, %s) = %s, %v; want %s", tc.op, tc.a, tc.b, got, err, tc.want)
+		}
+	}
+
+	a := mustParse(t, "2026-01-31T08:15", berlin)
+	b := mustParse(t, "2031-07-04T01:00", berlin)
+	d, _ := Eval("diff", a, b, nil)
+	if !d.Duration.AddTo(a.Time).Equal(b.Time) {
+		t.Fatalf("a + diff(a, b) = %s; want %s", d.Duration.AddTo(a.Time), b.Time)
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	d := mustParse(t, "2026-03-01", time.UTC)
+	cases := []struct {
+		op   string
+		a, b Value
+		kind calculator.Kind
+	}{
+		{"add", d, d, calculator.KindDomain},
+		{"add", Int(1), d, calculator.KindDomain},
+		{"subtract", Dur(Duration{Days: 1}), d, calculator.KindDomain},
+		{"add", Dur(Duration{Days: 1}), Dur(Duration{Clock: -time.Hour}), calculator.KindDomain},
+		{"diff", d, Int(3), calculator.KindDomain},
+		{"add_business_days", d, d, calculator.KindDomain},
+		{"add", d, Dur(Duration{Years: 9000}), calculator.KindOverflow},
+		{"business_days", d, mustParse(t, "9999-01-01", time.UTC), calculator.KindDomain},
+		{"pow", d, d, calculator.KindCalculation},
+	}
+	for _, tc := range cases {
+		if _, err := Eval(tc.op, tc.a, tc.b, nil); kindOf(err) != tc.kind {
+			t.Fatalf("%s(%s, %s) error = %v; want %s", tc.op, tc.a, tc.b, err, tc.kind)
+		}
+	}
+}
+
+func TestBusinessDays(t *testing.T) {
+	cal, err := ParseCalendar("de", strings.NewReader(`{
+		"holidays": [
+			{"date": "2026-04-03", "name": "Good Friday"},
+			{"date": "2026-04-06", "name": "Easter Monday"},
+			{"date": "12-25", "name": "Christmas Day"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := cal.Holiday(time.Date(2031, 12, 25, 0, 0, 0, 0, time.UTC)); !ok || name != "Christmas Day" {
+		t.Fatal("annual holiday not recognized")
+	}
+
+	v := func(s string) Value { return mustParse(t, s, time.UTC) }
+	cases := []struct {
+		op, a, b string
+		cal      *Calendar
+		want     string
+	}{
+		// Wed 1 Apr to Wed 8 Apr: Thu, Fri, Mon, Tue, Wed.
+		{"business_days", "2026-04-01", "2026-04-08", nil, "5"},
+		{"business_days", "2026-04-01", "2026-04-08", cal, "3"},
+		{"business_days", "2026-04-08", "2026-04-01", cal, "-3"},
+		{"business_days", "2026-04-04", "2026-04-05", nil, "0"},
+		{"add_business_days", "2026-04-02", "1", cal, "2026-04-07T00:00:00Z"},
+		{"add_business_days", "2026-04-07", "-1", cal, "2026-04-02T00:00:00Z"},
+		{"add_business_days", "2026-04-03", "0", cal, "2026-04-03T00:00:00Z"},
+	}
+	for _, tc := range cases {
+		got, err := Eval(tc.op, v(tc.a), v(tc.b), tc.cal)
+		if err != nil || got.String() != tc.want {
+			t.Fatalf("%s(%s, %s) = %s, %v; want %s", tc.op, tc.a, tc.b, got, err, tc.want)
+		}
+	}
+
+	allWeekend, _ := ParseCalendar("never", strings.NewReader(`{"weekend": ["Monday","Tuesday","Wednesday","Thursday","Friday","Saturday","Sunday"]}`))
+	if _, err := Eval("add_business_days", v("2026-01-01"), Int(1), allWeekend); kindOf(err) != calculator.KindDomain {
+		t.Fatalf("calendar without business days error = %v", err)
+	}
+
+	for _, bad := range []string{
+		`{"weekend": ["Funday"]}`,
+		`{"holidays": [{"date": "25.12."}]}`,
+		`{"holidays": [{"date": "02-30"}]}`,
+		`{"extra": true}`,
+	} {
+		if _, err := ParseCalendar("bad", strings.NewReader(bad)); err == nil {
+			t.Fatalf("ParseCalendar(%s) succeeded", bad)
+		}
+	}
+}
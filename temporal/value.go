@@ -0,0 +1,158 @@
+package temporal
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of a Value.
+type Type string
+
+const (
+	TypeDateTime Type = "datetime"
+	TypeDuration Type = "duration"
+	TypeInteger  Type = "integer"
+)
+
+// Value is an operand or result of a date operation.
+type Value struct {
+	Type     Type
+	Time     time.Time
+	Duration Duration
+	Int      int
+}
+
+// DateTime, Dur and Int build Values.
+func DateTime(t time.Time) Value { return Value{Type: TypeDateTime, Time: t} }
+func Dur(d Duration) Value       { return Value{Type: TypeDuration, Duration: d} }
+func Int(n int) Value            { return Value{Type: TypeInteger, Int: n} }
+
+// String formats v. Date-times use RFC 9557 so the zone name survives, e.g.
+// "2026-03-01T00:00:00+01:00[Europe/Berlin]".
+func (v Value) String() string {
+	switch v.Type {
+	case TypeDateTime:
+		s := v.Time.Format(time.RFC3339Nano)
+		if name := v.Time.Location().String(); name != "UTC" && name != "" && name != "Local" {
+			s += "[" + name + "]"
+		}
+		return s
+	case TypeDuration:
+		return v.Duration.String()
+	case TypeInteger:
+		return strconv.Itoa(v.Int)
+	}
+	return ""
+}
+
+// Errors returned by Parse.
+var (
+	ErrInvalidValue = errors.New("expected a date, date-time, duration or integer")
+	ErrUnknownZone  = errors.New("unknown time zone")
+)
+
+// LoadZone resolves an IANA zone name against the embedded tz database. An
+// empty name is UTC.
+func LoadZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	// time.LoadLocation also accepts "Local", which would depend on the host.
+	if name == "Local" {
+		return nil, ErrUnknownZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrUnknownZone
+	}
+	return loc, nil
+}
+
+// dateLayouts are tried in order for date-times without an offset.
+var dateLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// ParseDateTime parses a date or date-time. Text with an offset is converted
+// to loc; text without one is taken as wall-clock time in loc. A trailing
+// RFC 9557 zone such as "[Europe/Paris]" overrides loc.
+func ParseDateTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '['); i > 0 && strings.HasSuffix(s, "]") {
+		zone, err := LoadZone(s[i+1 : len(s)-1])
+		if err != nil {
+			return time.Time{}, err
+		}
+		s, loc = s[:i], zone
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidValue
+}
+
+// Parse reads a date-time, a duration or an integer, in that order of
+// preference.
+func Parse(s string, loc *time.Location) (Value, error) {
+	t, err := ParseDateTime(s, loc)
+	if err == nil {
+		return DateTime(t), nil
+	}
+	if errors.Is(err, ErrUnknownZone) {
+		return Value{}, err
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return Int(n), nil
+	}
+	if d, err := ParseDuration(s); err == nil {
+		return Dur(d), nil
+	}
+	return Value{}, ErrInvalidValue
+}
+
+type valueJSON struct {
+	Type  Type   `json:"type"`
+	Value string `json:"value"`
+}
+
+// MarshalJSON encodes v as {"type": "duration", "value": "P45D"}.
+func (v Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(valueJSON{Type: v.Type, Value: v.String()})
+}
+
+func (v *Value) UnmarshalJSON(b []byte) error {
+	var raw valueJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var err error
+	switch raw.Type {
+	case TypeDateTime:
+		var t time.Time
+		t, err = ParseDateTime(raw.Value, time.UTC)
+		*v = DateTime(t)
+	case TypeDuration:
+		var d Duration
+		d, err = ParseDuration(raw.Value)
+		*v = Dur(d)
+	case TypeInteger:
+		var n int
+		n, err = strconv.Atoi(raw.Value)
+		*v = Int(n)
+	default:
+		err = ErrInvalidValue
+	}
+	return err
+}
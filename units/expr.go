@@ -0,0 +1,79 @@
+package units
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Expr is a unit calculation written as text, e.g. "5 km + 300 m" or
+// "convert 70 mph to km/h". Op is empty for a bare quantity.
+type Expr struct {
+	Op   string
+	A, B string
+	To   string
+}
+
+// exprOps maps the infix operators to operation names. Operators must be
+// surrounded by spaces so they are not confused with "m/s" or "1e-3".
+var exprOps = []struct{ sym, op string }{
+	{" + ", "add"}, {" - ", "subtract"}, {" * ", "multiply"}, {" × ", "multiply"}, {" / ", "divide"}, {" ÷ ", "divide"},
+}
+
+// ParseExpr splits s into its quantities and operation without resolving any
+// units. A trailing "to <unit>" or "in <unit>" sets To; "convert X to Y" and
+// "X to Y" are conversions. Since "in" is also the inch, the operator is found
+// first and a keyword only ends the last quantity when what follows it reads
+// as a unit and what precedes it as a quantity: "5 in + 3 in" is an addition.
+func ParseExpr(s string) (Expr, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSpace(strings.TrimPrefix(s, "convert "))
+	var e Expr
+	for _, o := range exprOps {
+		// Pad so a dangling operator as in "5 km +" still splits.
+		if a, b, ok := strings.Cut(s+" ", o.sym); ok {
+			e.Op, e.A, s = o.op, strings.TrimSpace(a), strings.TrimSpace(b)
+			break
+		}
+	}
+	last, to := splitTarget(s)
+	e.To = to
+	switch {
+	case e.Op != "":
+		e.B = last
+	case to != "":
+		e.Op, e.A = "convert", last
+	default:
+		e.A = last
+	}
+	if e.A == "" || (e.Op != "" && e.Op != "convert" && e.B == "") || strings.ContainsAny(e.B, "+×÷") {
+		return Expr{}, ErrSyntax
+	}
+	return e, nil
+}
+
+// splitTarget cuts a trailing "to <unit>" or "in <unit>" off the quantity s,
+// trying the rightmost keyword first.
+func splitTarget(s string) (q, to string) {
+	for end := len(s); end > 0; {
+		i := max(strings.LastIndex(s[:end], " to "), strings.LastIndex(s[:end], " in "))
+		if i < 0 {
+			break
+		}
+		q, to = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+len(" to "):])
+		if _, err := tokenize(to); err == nil && isQuantity(q) {
+			return q, to
+		}
+		end = i + len(" to ") - 1
+	}
+	return s, ""
+}
+
+// isQuantity reports whether s reads as a number and an optional unit.
+func isQuantity(s string) bool {
+	num, unit := splitNumber(s)
+	if _, err := strconv.ParseFloat(num, 64); err != nil {
+		return false
+	}
+	_, err := tokenize(unit)
+	return strings.TrimSpace(unit) == "" || err == nil
+}
@@ -0,0 +1,442 @@
+// Package units implements dimensional analysis: parsing quantities such as
+// "70 mph", converting between compatible units and arithmetic that checks
+// dimensions. Units are defined by an embedded table that can be extended.
+package units
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"erikkruuse/calculator/calculator"
+)
+
+// Base dimensions, in the order used by Dimension.
+var baseDimensions = [...]string{"L", "M", "T", "I", "Θ", "N", "J"}
+
+// coherent are the SI units of the base dimensions, used to name derived results.
+var coherent = [...]string{"m", "kg", "s", "A", "K", "mol", "cd"}
+
+// symbolOrder lists dimensions in the order SI symbols are conventionally
+// written, mass first as in kg*m/s^2.
+var symbolOrder = [...]int{1, 0, 2, 3, 4, 5, 6}
+
+// Dimension holds the exponents of length, mass, time, current,
+// temperature, amount and luminous intensity.
+type Dimension [7]int
+
+// Unit scales a value to SI: si = value*Factor + Offset. Only absolute
+// temperature scales have an Offset.
+type Unit struct {
+	Symbol string
+	Factor float64
+	Offset float64
+	Dim    Dimension
+}
+
+// Quantity is a value with a unit.
+type Quantity struct {
+	Value float64
+	Unit  Unit
+}
+
+func (q Quantity) String() string {
+	v := strconv.FormatFloat(q.Value, 'g', -1, 64)
+	if q.Unit.Symbol == "" {
+		return v
+	}
+	return v + " " + q.Unit.Symbol
+}
+
+// Errors returned when parsing quantities and units.
+var (
+	ErrUnknownUnit = errors.New("unknown unit")
+	ErrSyntax      = errors.New("malformed quantity")
+	ErrOffsetUnit  = errors.New("temperature scales with an offset cannot be combined with other units")
+)
+
+//go:embed units.json
+var builtinTable []byte
+
+type tableFile struct {
+	Prefixes map[string]float64 `json:"prefixes"`
+	Units    []unitDef          `json:"units"`
+}
+
+// unitDef defines a unit either from base dimensions (dim) or as factor times
+// an expression over previously defined units (def).
+type unitDef struct {
+	Symbol string         `json:"symbol"`
+	Names  []string       `json:"names"`
+	Factor float64        `json:"factor"`
+	Offset float64        `json:"offset"`
+	Dim    map[string]int `json:"dim"`
+	Def    string         `json:"def"`
+	Prefix bool           `json:"prefix"`
+}
+
+// Registry is a unit table. It is safe for concurrent use.
+type Registry struct {
+	mu         sync.RWMutex
+	units      map[string]Unit
+	prefixable map[string]bool
+	prefixes   map[string]float64
+}
+
+// NewRegistry returns a registry holding the built-in table.
+func NewRegistry() *Registry {
+	r := &Registry{units: map[string]Unit{}, prefixable: map[string]bool{}, prefixes: map[string]float64{}}
+	if err := r.Load(strings.NewReader(string(builtinTable))); err != nil {
+		panic("units: built-in table: " + err.Error())
+	}
+	return r
+}
+
+// Load merges a JSON table with "prefixes" and "units" into the registry.
+// Definitions may refer to units defined earlier in the same table or already
+// in the registry; a symbol that already exists is replaced.
+func (r *Registry) Load(rd io.Reader) error {
+	var tf tableFile
+	dec := json.NewDecoder(rd)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&tf); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p, f := range tf.Prefixes {
+		r.prefixes[p] = f
+	}
+	for _, d := range tf.Units {
+		u, err := r.define(d)
+		if err != nil {
+			return fmt.Errorf("unit %q: %w", d.Symbol, err)
+		}
+		for _, name := range append([]string{d.Symbol}, d.Names...) {
+			r.units[name] = u
+			r.prefixable[name] = d.Prefix && name == d.Symbol
+		}
+	}
+	return nil
+}
+
+func (r *Registry) define(d unitDef) (Unit, error) {
+	if d.Symbol == "" {
+		return Unit{}, errors.New("symbol is required")
+	}
+	factor := d.Factor
+	if factor == 0 {
+		factor = 1
+	}
+	u := Unit{Symbol: d.Symbol, Factor: factor, Offset: d.Offset}
+	if d.Def != "" {
+		base, err := r.parseUnitLocked(d.Def)
+		if err != nil {
+			return Unit{}, err
+		}
+		u.Factor *= base.Factor
+		u.Dim = base.Dim
+		return u, nil
+	}
+	for name, exp := range d.Dim {
+		i := dimIndex(name)
+		if i < 0 {
+			return Unit{}, fmt.Errorf("unknown dimension %q", name)
+		}
+		u.Dim[i] = exp
+	}
+	return u, nil
+}
+
+func dimIndex(name string) int {
+	for i, d := range baseDimensions {
+		if d == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseUnit parses a unit expression such as "km/h", "kg*m/s^2" or "N m".
+func (r *Registry) ParseUnit(expr string) (Unit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.parseUnitLocked(expr)
+}
+
+// ParseQuantity parses a number followed by an optional unit, e.g. "5.3 km".
+func (r *Registry) ParseQuantity(s string) (Quantity, error) {
+	num, unit := splitNumber(s)
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || math.IsInf(v, 0) {
+		return Quantity{}, ErrSyntax
+	}
+	u, err := r.ParseUnit(unit)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: v, Unit: u}, nil
+}
+
+// splitNumber splits a quantity into its number and the unit after it.
+func splitNumber(s string) (num, unit string) {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && strings.IndexByte("0123456789+-.eE", s[end]) >= 0 {
+		// An "e" not followed by a digit or sign starts the unit, as in "5 eV".
+		if (s[end] == 'e' || s[end] == 'E') && (end+1 >= len(s) || strings.IndexByte("0123456789+-", s[end+1]) < 0) {
+			break
+		}
+		end++
+	}
+	return s[:end], s[end:]
+}
+
+// lookupLocked resolves a single symbol, trying SI prefixes on prefixable units.
+func (r *Registry) lookupLocked(sym string) (Unit, bool) {
+	if u, ok := r.units[sym]; ok {
+		return u, true
+	}
+	// Prefer the longest prefix so the result does not depend on map order.
+	best := ""
+	for p := range r.prefixes {
+		base, ok := strings.CutPrefix(sym, p)
+		if ok && r.prefixable[base] && len(p) > len(best) {
+			best = p
+		}
+	}
+	if best == "" {
+		return Unit{}, false
+	}
+	u := r.units[sym[len(best):]]
+	u.Symbol = sym
+	u.Factor *= r.prefixes[best]
+	return u, true
+}
+
+func (r *Registry) parseUnitLocked(expr string) (Unit, error) {
+	expr = strings.TrimSpace(expr)
+	out := Unit{Symbol: expr, Factor: 1}
+	if expr == "" {
+		return out, nil
+	}
+
+	toks, err := tokenize(expr)
+	if err != nil {
+		return Unit{}, err
+	}
+	if len(toks) == 1 && toks[0].exp == 1 {
+		// A lone unit keeps its offset; offsets cannot combine with anything else.
+		if toks[0].sym == "1" {
+			return out, nil
+		}
+		u, ok := r.lookupLocked(toks[0].sym)
+		if !ok {
+			return Unit{}, ErrUnknownUnit
+		}
+		u.Symbol = expr
+		return u, nil
+	}
+	for _, t := range toks {
+		if t.sym == "1" {
+			continue
+		}
+		u, ok := r.lookupLocked(t.sym)
+		if !ok {
+			return Unit{}, ErrUnknownUnit
+		}
+		if u.Offset != 0 {
+			return Unit{}, ErrOffsetUnit
+		}
+		out.Factor *= math.Pow(u.Factor, float64(t.exp))
+		for i := range out.Dim {
+			out.Dim[i] += u.Dim[i] * t.exp
+		}
+	}
+	return out, nil
+}
+
+type token struct {
+	sym string
+	exp int
+}
+
+var superscripts = map[rune]rune{'⁻': '-', '¹': '1', '²': '2', '³': '3', '⁴': '4'}
+
+// tokenize splits a unit expression into symbols with signed exponents. "*",
+// "·" and whitespace multiply; "/" divides the following symbol only.
+func tokenize(expr string) ([]token, error) {
+	var (
+		toks   []token
+		sign   = 1
+		rs     = []rune(expr)
+		expect = true // a symbol must come next
+	)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case c == ' ' || c == '*' || c == '·':
+			i++
+			continue
+		case c == '/':
+			if expect {
+				return nil, ErrSyntax
+			}
+			sign, expect = -1, true
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(rs) && !strings.ContainsRune(" *·/^", rs[j]) && superscripts[rs[j]] == 0 {
+			j++
+		}
+		if j == i {
+			return nil, ErrSyntax
+		}
+		sym := string(rs[i:j])
+
+		exp := 1
+		var digits []rune
+		if j < len(rs) && rs[j] == '^' {
+			j++
+			for j < len(rs) && (rs[j] == '-' || (rs[j] >= '0' && rs[j] <= '9')) {
+				digits = append(digits, rs[j])
+				j++
+			}
+		} else {
+			for j < len(rs) && superscripts[rs[j]] != 0 {
+				digits = append(digits, superscripts[rs[j]])
+				j++
+			}
+		}
+		if len(digits) > 0 || (j > 0 && rs[j-1] == '^') {
+			n, err := strconv.Atoi(string(digits))
+			if err != nil || n == 0 {
+				return nil, ErrSyntax
+			}
+			exp = n
+		}
+
+		toks = append(toks, token{sym: sym, exp: exp * sign})
+		sign, expect = 1, false
+		i = j
+	}
+	if expect && len(toks) > 0 || len(toks) == 0 {
+		return nil, ErrSyntax
+	}
+	return toks, nil
+}
+
+// DefaultRegistry holds the built-in table.
+var DefaultRegistry = NewRegistry()
+
+func mismatch(op, field string) error {
+	return &calculator.Error{Kind: calculator.KindDimension, Op: op, Field: field, Msg: "units have incompatible dimensions"}
+}
+
+func offsetError(op string) error {
+	return &calculator.Error{Kind: calculator.KindDomain, Op: op, Msg: "cannot multiply or divide temperatures on an offset scale"}
+}
+
+// toSI returns q's value in coherent SI units.
+func (q Quantity) toSI() float64 { return q.Value*q.Unit.Factor + q.Unit.Offset }
+
+// Convert expresses q in unit to. The result is rounded to 15 significant
+// digits so that conversion factors do not leave noise such as 211.99999999999997 °F.
+func Convert(q Quantity, to Unit) (Quantity, error) {
+	if q.Unit.Dim != to.Dim {
+		return Quantity{}, mismatch("convert", "to")
+	}
+	v := (q.toSI() - to.Offset) / to.Factor
+	v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+	return checked("convert", Quantity{Value: v, Unit: to})
+}
+
+// Add returns a+b in a's unit. On offset scales b is taken as a temperature
+// difference, so 20 °C + 5 K is 25 °C.
+func Add(a, b Quantity) (Quantity, error) {
+	if a.Unit.Dim != b.Unit.Dim {
+		return Quantity{}, mismatch("add", "b")
+	}
+	return checked("add", Quantity{Value: a.Value + b.Value*b.Unit.Factor/a.Unit.Factor, Unit: a.Unit})
+}
+
+// Subtract returns a-b in a's unit. Subtracting two absolute temperatures
+// yields a difference, which is reported in kelvin-sized steps of a's scale.
+func Subtract(a, b Quantity) (Quantity, error) {
+	if a.Unit.Dim != b.Unit.Dim {
+		return Quantity{}, mismatch("subtract", "b")
+	}
+	if a.Unit.Offset != 0 && b.Unit.Offset != 0 {
+		return checked("subtract", Quantity{Value: (a.toSI() - b.toSI()) / a.Unit.Factor, Unit: a.Unit})
+	}
+	return checked("subtract", Quantity{Value: a.Value - b.Value*b.Unit.Factor/a.Unit.Factor, Unit: a.Unit})
+}
+
+// Multiply returns a·b in coherent SI units.
+func Multiply(a, b Quantity) (Quantity, error) {
+	if a.Unit.Offset != 0 || b.Unit.Offset != 0 {
+		return Quantity{}, offsetError("multiply")
+	}
+	var dim Dimension
+	for i := range dim {
+		dim[i] = a.Unit.Dim[i] + b.Unit.Dim[i]
+	}
+	return checked("multiply", Quantity{Value: a.toSI() * b.toSI(), Unit: SI(dim)})
+}
+
+// Divide returns a/b in coherent SI units.
+func Divide(a, b Quantity) (Quantity, error) {
+	if a.Unit.Offset != 0 || b.Unit.Offset != 0 {
+		return Quantity{}, offsetError("divide")
+	}
+	if b.Value == 0 {
+		return Quantity{}, calculator.ErrDivisionByZero
+	}
+	var dim Dimension
+	for i := range dim {
+		dim[i] = a.Unit.Dim[i] - b.Unit.Dim[i]
+	}
+	return checked("divide", Quantity{Value: a.toSI() / b.toSI(), Unit: SI(dim)})
+}
+
+func checked(op string, q Quantity) (Quantity, error) {
+	if math.IsInf(q.Value, 0) || math.IsNaN(q.Value) {
+		return Quantity{}, &calculator.Error{Kind: calculator.KindOverflow, Op: op, Msg: "result overflows the float64 range"}
+	}
+	return q, nil
+}
+
+// SI returns the coherent SI unit of dim, e.g. "m/s^2" or "kg*m^2/s^2".
+func SI(dim Dimension) Unit {
+	var num, den []string
+	for _, i := range symbolOrder {
+		e := dim[i]
+		switch {
+		case e == 1:
+			num = append(num, coherent[i])
+		case e > 1:
+			num = append(num, coherent[i]+"^"+strconv.Itoa(e))
+		case e == -1:
+			den = append(den, coherent[i])
+		case e < -1:
+			den = append(den, coherent[i]+"^"+strconv.Itoa(-e))
+		}
+	}
+	sym := strings.Join(num, "*")
+	if len(den) > 0 {
+		if sym == "" {
+			sym = "1"
+		}
+		sym += "/" + strings.Join(den, "/")
+	}
+	return Unit{Symbol: sym, Factor: 1, Dim: dim}
+}
@@ -0,0 +1,252 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"erikkruuse/calculator/calculator"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b)) }
+
+func kindOf(err error) calculator.Kind {
+	var ce *calculator.Error
+	if errors.As(err, &ce) {
+		return ce.Kind
+	}
+	return ""
+}
+
+func mustQuantity(t *testing.T, s string) Quantity {
+	t.Helper()
+	q, err := DefaultRegistry.ParseQuantity(s)
+	if err != nil {
+		t.Fatalf("ParseQuantity(%q): %v", s, err)
+	}
+	return q
+}
+
+func mustUnit(t *testing.T, s string) Unit {
+	t.Helper()
+	u, err := DefaultRegistry.ParseUnit(s)
+	if err != nil {
+		t.Fatalf("ParseUnit(%q): %v", s, err)
+	}
+	return u
+}
+
+func TestParseUnit(t *testing.T) {
+	cases := []struct {
+		in     string
+		factor float64
+		dim    Dimension
+	}{
+		{"m", 1, Dimension{1}},
+		{"km", 1000, Dimension{1}},
+		{"kg", 1, Dimension{0, 1}},
+		{"ms", 1e-3, Dimension{0, 0, 1}},
+		{"µs", 1e-6, Dimension{0, 0, 1}},
+		{"dam", 10, Dimension{1}},
+		{"hPa", 100, Dimension{-1, 1, -2}},
+		{"km/h", 1000.0 / 3600, Dimension{1, 0, -1}},
+		{"m/s^2", 1, Dimension{1, 0, -2}},
+		{"m/s²", 1, Dimension{1, 0, -2}},
+		{"kg*m^2/s^2", 1, Dimension{2, 1, -2}},
+		{"N m", 1, Dimension{2, 1, -2}},
+		{"kWh", 3.6e6, Dimension{2, 1, -2}},
+		{"mph", 0.44704, Dimension{1, 0, -1}},
+		{"1/s", 1, Dimension{0, 0, -1}},
+		{"miles", 1609.344, Dimension{1}},
+	}
+	for _, tc := range cases {
+		u, err := DefaultRegistry.ParseUnit(tc.in)
+		if err != nil {
+			t.Fatalf("ParseUnit(%q): %v", tc.in, err)
+		}
+		if !near(u.Factor, tc.factor) || u.Dim != tc.dim {
+			t.Fatalf("ParseUnit(%q) = %+v; want factor %g dim %v", tc.in, u, tc.factor, tc.dim)
+		}
+	}
+
+	for in, want := range map[string]error{
+		"furlong": ErrUnknownUnit,
+		"kmi":     ErrUnknownUnit, // miles take no prefix
+		"m/":      ErrSyntax,
+		"/s":      ErrSyntax,
+		"m^0":     ErrSyntax,
+		"m^":      ErrSyntax,
+		"°C/s":    ErrOffsetUnit,
+		"°F^2":    ErrOffsetUnit,
+	} {
+		if _, err := DefaultRegistry.ParseUnit(in); !errors.Is(err, want) {
+			t.Fatalf("ParseUnit(%q) error = %v; want %v", in, err, want)
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	for in, want := range map[string]string{
+		"5 km":      "5 km",
+		"5km":       "5 km",
+		"-2.5e3 m":  "-2500 m",
+		"5 eV":      "5 eV",
+		"7":         "7",
+		" 70 mph  ": "70 mph",
+	} {
+		if got := mustQuantity(t, in).String(); got != want {
+			t.Fatalf("ParseQuantity(%q) = %q; want %q", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "km", "1e999 m", "five m"} {
+		if _, err := DefaultRegistry.ParseQuantity(in); err == nil {
+			t.Fatalf("ParseQuantity(%q) succeeded", in)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		q, to string
+		want  float64
+	}{
+		{"70 mph", "km/h", 112.65408},
+		{"1 mi", "ft", 5280},
+		{"100 °C", "°F", 212},
+		{"-40 °F", "°C", -40},
+		{"0 °C", "K", 273.15},
+		{"1 kWh", "J", 3.6e6},
+		{"1 atm", "psi", 14.6959487755134},
+		{"180 deg", "rad", math.Pi},
+	}
+	for _, tc := range cases {
+		got, err := Convert(mustQuantity(t, tc.q), mustUnit(t, tc.to))
+		if err != nil {
+			t.Fatalf("Convert(%s, %s): %v", tc.q, tc.to, err)
+		}
+		if !near(got.Value, tc.want) || got.Unit.Symbol != tc.to {
+			t.Fatalf("Convert(%s, %s) = %v; want %g", tc.q, tc.to, got, tc.want)
+		}
+	}
+	if got, _ := Convert(mustQuantity(t, "100 °C"), mustUnit(t, "°F")); got.String() != "212 °F" {
+		t.Fatalf("100 °C in °F = %q; want exactly 212 °F", got)
+	}
+	if _, err := Convert(mustQuantity(t, "3 kg"), mustUnit(t, "m")); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("kg -> m error = %v", err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	if got, err := Add(mustQuantity(t, "5 km"), mustQuantity(t, "300 m")); err != nil || got.String() != "5.3 km" {
+		t.Fatalf("5 km + 300 m = %v, %v", got, err)
+	}
+	if got, _ := Subtract(mustQuantity(t, "1 h"), mustQuantity(t, "15 min")); got.String() != "0.75 h" {
+		t.Fatalf("1 h - 15 min = %v", got)
+	}
+	if got, _ := Add(mustQuantity(t, "20 °C"), mustQuantity(t, "5 K")); got.String() != "25 °C" {
+		t.Fatalf("20 °C + 5 K = %v", got)
+	}
+	if got, _ := Subtract(mustQuantity(t, "68 °F"), mustQuantity(t, "20 °C")); !near(got.Value, 0) {
+		t.Fatalf("68 °F - 20 °C = %v", got)
+	}
+	if got, _ := Divide(mustQuantity(t, "3 km"), mustQuantity(t, "30 min")); got.String() != "1.6666666666666667 m/s" {
+		t.Fatalf("3 km / 30 min = %v", got)
+	}
+	if got, _ := Multiply(mustQuantity(t, "2 N"), mustQuantity(t, "3 m")); got.String() != "6 kg*m^2/s^2" {
+		t.Fatalf("2 N * 3 m = %v", got)
+	}
+	if got, _ := Divide(mustQuantity(t, "6 m"), mustQuantity(t, "2 m")); got.String() != "3" {
+		t.Fatalf("6 m / 2 m = %v", got)
+	}
+
+	if _, err := Add(mustQuantity(t, "3 kg"), mustQuantity(t, "2 m")); kindOf(err) != calculator.KindDimension {
+		t.Fatalf("3 kg + 2 m error = %v", err)
+	}
+	if _, err := Multiply(mustQuantity(t, "3 °C"), mustQuantity(t, "2 m")); kindOf(err) != calculator.KindDomain {
+		t.Fatalf("°C * m error = %v", err)
+	}
+	if _, err := Divide(mustQuantity(t, "3 m"), mustQuantity(t, "0 s")); !errors.Is(err, calculator.ErrDivisionByZero) {
+		t.Fatalf("divide by 0 s error = %v", err)
+	}
+	if _, err := Multiply(mustQuantity(t, "1e200 m"), mustQuantity(t, "1e200 m")); kindOf(err) != calculator.KindOverflow {
+		t.Fatalf("overflow error = %v", err)
+	}
+}
+
+func TestRegistryLoad(t *testing.T) {
+	r := NewRegistry()
+	err := r.Load(strings.NewReader(`{
+		"prefixes": {"Ki": 1024},
+		"units": [
+			{"symbol": "B", "names": ["byte", "bytes"], "dim": {}, "prefix": true},
+			{"symbol": "furlong", "factor": 201.168, "def": "m"},
+			{"symbol": "fpf", "def": "furlong/(14 d)"}
+		]
+	}`))
+	if err == nil {
+		t.Fatal("Load accepted a definition with a malformed def")
+	}
+
+	r = NewRegistry()
+	if err := r.Load(strings.NewReader(`{
+		"prefixes": {"Ki": 1024},
+		"units": [
+			{"symbol": "B", "names": ["byte", "bytes"], "dim": {}, "prefix": true},
+			{"symbol": "furlong", "factor": 201.168, "def": "m"},
+			{"symbol": "fortnight", "factor": 14, "def": "d"}
+		]
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ParseUnit("KiB")
+	if err != nil || got.Factor != 1024 {
+		t.Fatalf("KiB = %+v, %v", got, err)
+	}
+	q, _ := r.ParseQuantity("1 furlong/fortnight")
+	si, _ := Convert(q, SI(q.Unit.Dim))
+	if !near(si.Value, 201.168/(14*86400)) {
+		t.Fatalf("furlong/fortnight = %v", si)
+	}
+	if _, err := DefaultRegistry.ParseUnit("furlong"); !errors.Is(err, ErrUnknownUnit) {
+		t.Fatal("Load on one registry leaked into DefaultRegistry")
+	}
+
+	for _, bad := range []string{
+		`{"units": [{"symbol": "x", "dim": {"Q": 1}}]}`,
+		`{"units": [{"symbol": "x", "def": "nope"}]}`,
+		`{"units": [{"names": ["x"]}]}`,
+		`{"unknown": true}`,
+	} {
+		if err := NewRegistry().Load(strings.NewReader(bad)); err == nil {
+			t.Fatalf("Load(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	cases := map[string]Expr{
+		"5 km + 300 m":           {Op: "add", A: "5 km", B: "300 m"},
+		"convert 70 mph to km/h": {Op: "convert", A: "70 mph", To: "km/h"},
+		"100 °C in °F":           {Op: "convert", A: "100 °C", To: "°F"},
+		"3 km / 30 min to km/h":  {Op: "divide", A: "3 km", B: "30 min", To: "km/h"},
+		"9.81 m/s^2 * 2 kg":      {Op: "multiply", A: "9.81 m/s^2", B: "2 kg"},
+		"1e-3 m - -2 mm":         {Op: "subtract", A: "1e-3 m", B: "-2 mm"},
+		"  12 ft  ":              {A: "12 ft"},
+		"5 in + 3 in":            {Op: "add", A: "5 in", B: "3 in"},
+		"5 in + 3 in in cm":      {Op: "add", A: "5 in", B: "3 in", To: "cm"},
+		"12 in in ft":            {Op: "convert", A: "12 in", To: "ft"},
+		"2 in * 3 in to cm^2":    {Op: "multiply", A: "2 in", B: "3 in", To: "cm^2"},
+	}
+	for in, want := range cases {
+		got, err := ParseExpr(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseExpr(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "5 km + ", "1 m + 2 m + 3 m"} {
+		if _, err := ParseExpr(in); !errors.Is(err, ErrSyntax) {
+			t.Fatalf("ParseExpr(%q) error = %v", in, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package wasm
+
+import "encoding/binary"
+
+// instr is a decoded instruction with its immediates and, for structured
+// control instructions, the positions of the matching else and end.
+type instr struct {
+	op  uint16 // opcode; 0xfc-prefixed instructions are 0xfc00|subopcode
+	imm uint64 // constant bits, index, branch depth or memory offset
+
+	end, els        int32  // block, loop, if and else: index of the matching end and else (-1 if none)
+	params, results uint16 // block signature
+	targets         []uint32
+
+	raw []byte // a function body's instructions before compilation
+}
+
+// Opcodes the interpreter dispatches on by name.
+const (
+	opUnreachable  = 0x00
+	opNop          = 0x01
+	opBlock        = 0x02
+	opLoop         = 0x03
+	opIf           = 0x04
+	opElse         = 0x05
+	opEnd          = 0x0b
+	opBr           = 0x0c
+	opBrIf         = 0x0d
+	opBrTable      = 0x0e
+	opReturn       = 0x0f
+	opCall         = 0x10
+	opCallIndirect = 0x11
+	opDrop         = 0x1a
+	opSelect       = 0x1b
+	opSelectT      = 0x1c
+	opLocalGet     = 0x20
+	opLocalSet     = 0x21
+	opLocalTee     = 0x22
+	opGlobalGet    = 0x23
+	opGlobalSet    = 0x24
+	opMemorySize   = 0x3f
+	opMemoryGrow   = 0x40
+	opI32Const     = 0x41
+	opI64Const     = 0x42
+	opF32Const     = 0x43
+	opF64Const     = 0x44
+	opMemoryCopy   = 0xfc0a
+	opMemoryFill   = 0xfc0b
+)
+
+// compile decodes f's instructions, resolving block structure and checking
+// every index against the module.
+func (m *Module) compile(f *function) error {
+	r := &reader{b: f.code[0].raw}
+	typ := m.types[f.typ]
+	locals := uint64(len(typ.Params) + len(f.locals))
+
+	var code []instr
+	blocks := []int{-1} // positions of the open block, loop and if instructions; -1 is the body
+	for len(blocks) > 0 {
+		b, err := r.byte()
+		if err != nil {
+			return err
+		}
+		in := instr{op: uint16(b), end: -1, els: -1}
+		switch op := in.op; {
+		case op == opBlock || op == opLoop || op == opIf:
+			if in.params, in.results, err = m.blockType(r); err != nil {
+				return err
+			}
+			blocks = append(blocks, len(code))
+		case op == opElse:
+			top := blocks[len(blocks)-1]
+			if top < 0 || code[top].op != opIf || code[top].els >= 0 {
+				return invalid("else without if")
+			}
+			code[top].els = int32(len(code))
+		case op == opEnd:
+			top := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			if top >= 0 {
+				code[top].end = int32(len(code))
+				if els := code[top].els; els >= 0 {
+					code[els].end = int32(len(code))
+				}
+			}
+		case op == opBr || op == opBrIf:
+			if in.imm, err = r.uleb(32); err == nil && in.imm >= uint64(len(blocks)) {
+				err = invalid("branch depth %d out of range", in.imm)
+			}
+		case op == opBrTable:
+			err = r.vec(func() error {
+				d, err := r.u32()
+				in.targets = append(in.targets, d)
+				return err
+			})
+			if err == nil {
+				var d uint32
+				d, err = r.u32()
+				in.targets = append(in.targets, d)
+			}
+			for _, d := range in.targets {
+				if err == nil && int(d) >= len(blocks) {
+					err = invalid("branch depth %d out of range", d)
+				}
+			}
+		case op == opCall:
+			if in.imm, err = r.uleb(32); err == nil && in.imm >= uint64(len(m.funcs)) {
+				err = invalid("call to function %d out of range", in.imm)
+			}
+		case op == opCallIndirect:
+			if in.imm, err = r.uleb(32); err == nil && in.imm >= uint64(len(m.types)) {
+				err = invalid("type %d out of range", in.imm)
+			}
+			if t, e := r.byte(); err == nil && (e != nil || t != 0 || m.table == nil) {
+				err = invalid("call_indirect without table 0")
+			}
+		case op == opSelectT:
+			var ts []ValueType
+			if ts, err = r.valueTypes(); err == nil && len(ts) != 1 {
+				err = invalid("select must name one type")
+			}
+			in.op = opSelect
+		case op >= opLocalGet && op <= opLocalTee:
+			if in.imm, err = r.uleb(32); err == nil && in.imm >= locals {
+				err = invalid("local %d out of range", in.imm)
+			}
+		case op == opGlobalGet || op == opGlobalSet:
+			if in.imm, err = r.uleb(32); err == nil && in.imm >= uint64(len(m.globals)) {
+				err = invalid("global %d out of range", in.imm)
+			}
+			if err == nil && op == opGlobalSet && !m.globals[in.imm].mutable {
+				err = invalid("global %d is immutable", in.imm)
+			}
+		case op >= 0x28 && op <= 0x3e:
+			if _, err = r.u32(); err == nil { // alignment hint
+				in.imm, err = r.uleb(32)
+			}
+			if err == nil && m.memory == nil {
+				err = invalid("memory access without a memory")
+			}
+		case op == opMemorySize || op == opMemoryGrow:
+			if z, e := r.byte(); e != nil || z != 0 || m.memory == nil {
+				err = invalid("memory instruction without memory 0")
+			}
+		case op == opI32Const:
+			var c int64
+			c, err = r.sleb(32)
+			in.imm = uint64(uint32(c))
+		case op == opI64Const:
+			var c int64
+			c, err = r.sleb(64)
+			in.imm = uint64(c)
+		case op == opF32Const:
+			var c []byte
+			if c, err = r.bytes(4); err == nil {
+				in.imm = uint64(binary.LittleEndian.Uint32(c))
+			}
+		case op == opF64Const:
+			var c []byte
+			if c, err = r.bytes(8); err == nil {
+				in.imm = binary.LittleEndian.Uint64(c)
+			}
+		case op == 0xfc:
+			var sub uint32
+			if sub, err = r.u32(); err != nil {
+				return err
+			}
+			in.op = 0xfc00 | uint16(sub)
+			switch {
+			case sub <= 7: // saturating truncation
+			case in.op == opMemoryCopy || in.op == opMemoryFill:
+				zeros := 2
+				if in.op == opMemoryFill {
+					zeros = 1
+				}
+				for range zeros {
+					if z, e := r.byte(); e != nil || z != 0 || m.memory == nil {
+						err = invalid("memory instruction without memory 0")
+					}
+				}
+			default:
+				err = invalid("unsupported instruction 0xfc %d", sub)
+			}
+		case op == opUnreachable || op == opNop || op == opReturn || op == opDrop || op == opSelect:
+		case op >= 0x45 && op <= 0xc4:
+			// Numeric instructions have no immediates.
+		default:
+			err = invalid("unsupported instruction 0x%02x", op)
+		}
+		if err != nil {
+			return err
+		}
+		code = append(code, in)
+	}
+	if r.len() != 0 {
+		return invalid("instructions after the end of the body")
+	}
+	f.code = code
+	return nil
+}
+
+// blockType decodes a block signature: empty, one result type or a type index.
+func (m *Module) blockType(r *reader) (params, results uint16, err error) {
+	if r.len() == 0 {
+		return 0, 0, errEOF
+	}
+	switch b := r.b[r.pos]; {
+	case b == 0x40:
+		r.pos++
+		return 0, 0, nil
+	case ValueType(b) == I32 || ValueType(b) == I64 || ValueType(b) == F32 || ValueType(b) == F64:
+		r.pos++
+		return 0, 1, nil
+	}
+	i, err := r.sleb(33)
+	if err != nil {
+		return 0, 0, err
+	}
+	if i < 0 || i >= int64(len(m.types)) {
+		return 0, 0, invalid("block type %d out of range", i)
+	}
+	t := m.types[i]
+	return uint16(len(t.Params)), uint16(len(t.Results)), nil
+}
@@ -0,0 +1,397 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrTrap wraps every runtime failure of a module, such as an
+	// unreachable instruction, an integer division by zero or an out of
+	// bounds memory access.
+	ErrTrap = errors.New("wasm: trap")
+	// ErrFuelExhausted reports that an instance ran out of instructions.
+	ErrFuelExhausted = fmt.Errorf("%w: instruction limit exceeded", ErrTrap)
+	// ErrCallDepth reports that calls nested deeper than Limits.MaxCallDepth.
+	ErrCallDepth = fmt.Errorf("%w: call stack exhausted", ErrTrap)
+	// ErrMemoryLimit reports a module whose initial memory exceeds
+	// Limits.MaxPages. Growing memory beyond the limit fails inside the
+	// module instead, as memory.grow returns -1.
+	ErrMemoryLimit = errors.New("wasm: memory limit exceeded")
+)
+
+func trap(msg string) error { return fmt.Errorf("%w: %s", ErrTrap, msg) }
+
+var (
+	errOutOfBounds  = trap("out of bounds memory access")
+	errDivideByZero = trap("integer divide by zero")
+	errIntOverflow  = trap("integer overflow")
+	errBadConvert   = trap("invalid conversion to integer")
+)
+
+// Limits bound the resources of an instance. Zero fields take the values in
+// DefaultLimits.
+type Limits struct {
+	// Fuel is the number of instructions the instance may execute over its
+	// lifetime, including its start function.
+	Fuel uint64
+	// MaxPages caps linear memory, in 64 KiB pages.
+	MaxPages uint32
+	// MaxCallDepth caps nested calls.
+	MaxCallDepth int
+}
+
+// DefaultLimits allow ten million instructions, 16 MiB of memory and a
+// thousand nested calls.
+var DefaultLimits = Limits{Fuel: 10_000_000, MaxPages: 256, MaxCallDepth: 1000}
+
+func (l Limits) withDefaults() Limits {
+	if l.Fuel == 0 {
+		l.Fuel = DefaultLimits.Fuel
+	}
+	if l.MaxPages == 0 {
+		l.MaxPages = DefaultLimits.MaxPages
+	}
+	if l.MaxCallDepth == 0 {
+		l.MaxCallDepth = DefaultLimits.MaxCallDepth
+	}
+	return l
+}
+
+// maxStack bounds the operand stack of one call.
+const maxStack = 1 << 16
+
+// maxTable bounds the size of a table.
+const maxTable = 1 << 20
+
+// Instance is an instantiated module with its own memory, globals and
+// table. It is not safe for concurrent use.
+//
+// Values are passed as raw bits: i32 in the low 32 bits, f32 and f64 as
+// math.Float32bits and math.Float64bits.
+type Instance struct {
+	m        *Module
+	mem      []byte
+	maxPages uint32
+	globals  []uint64
+	table    []int32 // function indices, -1 if uninitialized
+	fuel     uint64
+	maxDepth int
+}
+
+// Instantiate creates an instance of m, initializes its memory and table
+// and runs its start function.
+func (m *Module) Instantiate(l Limits) (*Instance, error) {
+	l = l.withDefaults()
+	in := &Instance{m: m, fuel: l.Fuel, maxDepth: l.MaxCallDepth}
+	if m.memory != nil {
+		in.maxPages = l.MaxPages
+		if m.memory.hasMax && m.memory.max < in.maxPages {
+			in.maxPages = m.memory.max
+		}
+		if m.memory.min > in.maxPages {
+			return nil, ErrMemoryLimit
+		}
+		in.mem = make([]byte, int(m.memory.min)*PageSize)
+	}
+	if m.table != nil {
+		if m.table.min > maxTable {
+			return nil, ErrMemoryLimit
+		}
+		in.table = make([]int32, m.table.min)
+		for i := range in.table {
+			in.table[i] = -1
+		}
+	}
+	in.globals = make([]uint64, len(m.globals))
+	for i, g := range m.globals {
+		in.globals[i] = g.init
+	}
+	for _, seg := range m.elems {
+		if uint64(seg.offset)+uint64(len(seg.funcs)) > uint64(len(in.table)) {
+			return nil, trap("out of bounds table access")
+		}
+		for i, f := range seg.funcs {
+			in.table[int(seg.offset)+i] = int32(f)
+		}
+	}
+	for _, seg := range m.data {
+		if uint64(seg.offset)+uint64(len(seg.data)) > uint64(len(in.mem)) {
+			return nil, errOutOfBounds
+		}
+		copy(in.mem[seg.offset:], seg.data)
+	}
+	if m.start >= 0 {
+		if _, err := in.invoke(uint32(m.start), nil); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+// Call invokes the exported function name with args.
+func (in *Instance) Call(name string, args ...uint64) ([]uint64, error) {
+	e, ok := in.m.exports[name]
+	if !ok || e.kind != exportFunc {
+		return nil, fmt.Errorf("wasm: no exported function %q", name)
+	}
+	if t := in.m.types[in.m.funcs[e.index].typ]; len(args) != len(t.Params) {
+		return nil, fmt.Errorf("wasm: %s takes %d arguments, got %d", name, len(t.Params), len(args))
+	}
+	return in.invoke(e.index, args)
+}
+
+// Fuel returns the instructions the instance may still execute.
+func (in *Instance) Fuel() uint64 { return in.fuel }
+
+// invoke runs function f. Validation does not type-check the operand stack,
+// so a malformed body can index outside it; that surfaces as a trap.
+func (in *Instance) invoke(f uint32, args []uint64) (res []uint64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, fmt.Errorf("%w: %v", ErrTrap, r)
+		}
+	}()
+	return in.call(f, args, 1)
+}
+
+type label struct {
+	height int // operand stack height below the block's parameters
+	arity  int // values a branch to the label carries
+	cont   int // where a branch to the label continues
+}
+
+func (in *Instance) call(fi uint32, args []uint64, depth int) ([]uint64, error) {
+	if depth > in.maxDepth {
+		return nil, ErrCallDepth
+	}
+	f := &in.m.funcs[fi]
+	typ := in.m.types[f.typ]
+	locals := make([]uint64, len(typ.Params)+len(f.locals))
+	copy(locals, args)
+
+	code := f.code
+	st := make([]uint64, 0, 16)
+	labels := []label{{arity: len(typ.Results), cont: len(code)}}
+	branch := func(depth int) int {
+		l := labels[len(labels)-1-depth]
+		copy(st[l.height:], st[len(st)-l.arity:])
+		st = st[:l.height+l.arity]
+		labels = labels[:len(labels)-1-depth]
+		return l.cont
+	}
+
+	for pc := 0; pc < len(code); {
+		if in.fuel == 0 {
+			return nil, ErrFuelExhausted
+		}
+		in.fuel--
+		if len(st) > maxStack {
+			return nil, trap("operand stack exhausted")
+		}
+
+		c := &code[pc]
+		n := len(st)
+		switch c.op {
+		case opUnreachable:
+			return nil, trap("unreachable")
+		case opNop:
+		case opBlock:
+			labels = append(labels, label{height: n - int(c.params), arity: int(c.results), cont: int(c.end) + 1})
+		case opLoop:
+			labels = append(labels, label{height: n - int(c.params), arity: int(c.params), cont: pc})
+		case opIf:
+			cond := uint32(st[n-1])
+			st = st[:n-1]
+			switch {
+			case cond != 0:
+			case c.els >= 0:
+				pc = int(c.els)
+			default:
+				pc = int(c.end) + 1
+				continue
+			}
+			labels = append(labels, label{height: n - 1 - int(c.params), arity: int(c.results), cont: int(c.end) + 1})
+		case opElse:
+			// The then branch is done; its end pops the label.
+			pc = int(c.end)
+			continue
+		case opEnd:
+			labels = labels[:len(labels)-1]
+		case opBr:
+			pc = branch(int(c.imm))
+			continue
+		case opBrIf:
+			cond := uint32(st[n-1])
+			st = st[:n-1]
+			if cond != 0 {
+				pc = branch(int(c.imm))
+				continue
+			}
+		case opBrTable:
+			i := uint32(st[n-1])
+			st = st[:n-1]
+			d := c.targets[len(c.targets)-1]
+			if int(i) < len(c.targets)-1 {
+				d = c.targets[i]
+			}
+			pc = branch(int(d))
+			continue
+		case opReturn:
+			pc = branch(len(labels) - 1)
+			continue
+		case opCall:
+			k := len(in.m.types[in.m.funcs[c.imm].typ].Params)
+			res, err := in.call(uint32(c.imm), st[n-k:], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			st = append(st[:n-k], res...)
+		case opCallIndirect:
+			i := uint32(st[n-1])
+			st = st[:n-1]
+			if int(i) >= len(in.table) {
+				return nil, trap("undefined table element")
+			}
+			f := in.table[i]
+			if f < 0 {
+				return nil, trap("uninitialized table element")
+			}
+			want := in.m.types[c.imm]
+			if !in.m.types[in.m.funcs[f].typ].equal(want) {
+				return nil, trap("indirect call type mismatch")
+			}
+			k := len(want.Params)
+			res, err := in.call(uint32(f), st[n-1-k:], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			st = append(st[:n-1-k], res...)
+		case opDrop:
+			st = st[:n-1]
+		case opSelect:
+			if uint32(st[n-1]) == 0 {
+				st[n-3] = st[n-2]
+			}
+			st = st[:n-2]
+		case opLocalGet:
+			st = append(st, locals[c.imm])
+		case opLocalSet:
+			locals[c.imm] = st[n-1]
+			st = st[:n-1]
+		case opLocalTee:
+			locals[c.imm] = st[n-1]
+		case opGlobalGet:
+			st = append(st, in.globals[c.imm])
+		case opGlobalSet:
+			in.globals[c.imm] = st[n-1]
+			st = st[:n-1]
+		case opMemorySize:
+			st = append(st, uint64(len(in.mem)/PageSize))
+		case opMemoryGrow:
+			old := uint32(len(in.mem) / PageSize)
+			if delta := uint32(st[n-1]); uint64(old)+uint64(delta) > uint64(in.maxPages) {
+				st[n-1] = uint64(math.MaxUint32) // -1
+			} else {
+				in.mem = append(in.mem, make([]byte, int(delta)*PageSize)...)
+				st[n-1] = uint64(old)
+			}
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			st = append(st, c.imm)
+		case opMemoryCopy:
+			dst, src, size := uint64(uint32(st[n-3])), uint64(uint32(st[n-2])), uint64(uint32(st[n-1]))
+			if dst+size > uint64(len(in.mem)) || src+size > uint64(len(in.mem)) {
+				return nil, errOutOfBounds
+			}
+			copy(in.mem[dst:dst+size], in.mem[src:src+size])
+			st = st[:n-3]
+		case opMemoryFill:
+			dst, v, size := uint64(uint32(st[n-3])), byte(st[n-2]), uint64(uint32(st[n-1]))
+			if dst+size > uint64(len(in.mem)) {
+				return nil, errOutOfBounds
+			}
+			b := in.mem[dst : dst+size]
+			for i := range b {
+				b[i] = v
+			}
+			st = st[:n-3]
+		default:
+			var err error
+			switch {
+			case c.op >= 0x28 && c.op <= 0x35:
+				err = in.load(c.op, c.imm, st)
+			case c.op >= 0x36 && c.op <= 0x3e:
+				err = in.store(c.op, c.imm, st)
+				st = st[:n-2]
+			default:
+				st, err = numeric(c.op, st)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		pc++
+	}
+	k := len(typ.Results)
+	return append([]uint64(nil), st[len(st)-k:]...), nil
+}
+
+// load replaces the address on top of st with the value it points to.
+func (in *Instance) load(op uint16, offset uint64, st []uint64) error {
+	size := [...]uint64{4, 8, 4, 8, 1, 1, 2, 2, 1, 1, 2, 2, 4, 4}[op-0x28]
+	n := len(st)
+	ea := uint64(uint32(st[n-1])) + offset
+	if ea+size > uint64(len(in.mem)) {
+		return errOutOfBounds
+	}
+	b := in.mem[ea : ea+size]
+	var v uint64
+	switch op {
+	case 0x28, 0x2a: // i32.load, f32.load
+		v = uint64(binary.LittleEndian.Uint32(b))
+	case 0x29, 0x2b: // i64.load, f64.load
+		v = binary.LittleEndian.Uint64(b)
+	case 0x2c: // i32.load8_s
+		v = uint64(uint32(int8(b[0])))
+	case 0x2d, 0x31: // i32.load8_u, i64.load8_u
+		v = uint64(b[0])
+	case 0x2e: // i32.load16_s
+		v = uint64(uint32(int16(binary.LittleEndian.Uint16(b))))
+	case 0x2f, 0x33: // i32.load16_u, i64.load16_u
+		v = uint64(binary.LittleEndian.Uint16(b))
+	case 0x30: // i64.load8_s
+		v = uint64(int8(b[0]))
+	case 0x32: // i64.load16_s
+		v = uint64(int16(binary.LittleEndian.Uint16(b)))
+	case 0x34: // i64.load32_s
+		v = uint64(int32(binary.LittleEndian.Uint32(b)))
+	case 0x35: // i64.load32_u
+		v = uint64(binary.LittleEndian.Uint32(b))
+	}
+	st[n-1] = v
+	return nil
+}
+
+// store writes the value on top of st to the address below it.
+func (in *Instance) store(op uint16, offset uint64, st []uint64) error {
+	size := [...]uint64{4, 8, 4, 8, 1, 2, 1, 2, 4}[op-0x36]
+	n := len(st)
+	ea := uint64(uint32(st[n-2])) + offset
+	if ea+size > uint64(len(in.mem)) {
+		return errOutOfBounds
+	}
+	b, v := in.mem[ea:ea+size], st[n-1]
+	switch size {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	case 8:
+		binary.LittleEndian.PutUint64(b, v)
+	}
+	return nil
+}
@@ -0,0 +1,697 @@
+// Package wasm is a small, sandboxed WebAssembly interpreter written in pure
+// Go. It runs self-contained modules of the WebAssembly 1.0 core
+// specification, plus sign extension, saturating truncation and bulk memory
+// copy and fill. Modules cannot import anything, so their only effects are
+// the values they return; every call is bounded by an instruction budget,
+// a memory limit and a call depth limit.
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ValueType is the type of a WebAssembly value.
+type ValueType byte
+
+const (
+	I32 ValueType = 0x7f
+	I64 ValueType = 0x7e
+	F32 ValueType = 0x7d
+	F64 ValueType = 0x7c
+
+	funcRef ValueType = 0x70
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	}
+	return fmt.Sprintf("type(0x%02x)", byte(t))
+}
+
+// FuncType is the signature of a function.
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+func (t FuncType) equal(u FuncType) bool {
+	return string(valueBytes(t.Params)) == string(valueBytes(u.Params)) &&
+		string(valueBytes(t.Results)) == string(valueBytes(u.Results))
+}
+
+func valueBytes(ts []ValueType) []byte {
+	b := make([]byte, len(ts))
+	for i, t := range ts {
+		b[i] = byte(t)
+	}
+	return b
+}
+
+// ErrInvalidModule wraps every decoding and validation failure.
+var ErrInvalidModule = errors.New("wasm: invalid module")
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidModule, fmt.Sprintf(format, args...))
+}
+
+// Module is a decoded and compiled module. It is immutable and safe for
+// concurrent use; each Instantiate gets fresh memory, globals and table.
+type Module struct {
+	types   []FuncType
+	funcs   []function
+	table   *limits
+	memory  *limits
+	globals []global
+	exports map[string]export
+	start   int // function index, or -1
+	elems   []segment
+	data    []segment
+	customs map[string][]byte
+}
+
+type function struct {
+	typ    uint32
+	locals []ValueType // excluding parameters
+	code   []instr
+}
+
+type limits struct {
+	min, max uint32
+	hasMax   bool
+}
+
+type global struct {
+	typ     ValueType
+	mutable bool
+	init    uint64
+}
+
+const (
+	exportFunc   = 0
+	exportTable  = 1
+	exportMemory = 2
+	exportGlobal = 3
+)
+
+type export struct {
+	kind  byte
+	index uint32
+}
+
+// segment is an active element or data segment. Element segments hold
+// function indices in funcs, data segments bytes in data.
+type segment struct {
+	offset uint32
+	funcs  []uint32
+	data   []byte
+}
+
+// PageSize is the size of a WebAssembly memory page.
+const PageSize = 65536
+
+// maxLocals bounds the locals of one function so a tiny module cannot
+// demand a huge allocation per call.
+const maxLocals = 50000
+
+// Compile decodes and validates the binary module b.
+func Compile(b []byte) (*Module, error) {
+	r := &reader{b: b}
+	if magic, err := r.bytes(4); err != nil || string(magic) != "\x00asm" {
+		return nil, invalid("missing magic number")
+	}
+	if v, err := r.bytes(4); err != nil || binary.LittleEndian.Uint32(v) != 1 {
+		return nil, invalid("unsupported version")
+	}
+
+	m := &Module{exports: map[string]export{}, customs: map[string][]byte{}, start: -1}
+	var (
+		funcTypes []uint32
+		last      byte
+	)
+	for r.len() > 0 {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		body, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+		if id != 0 {
+			// Known sections appear at most once, in order; the data count
+			// section (12) sits between the element and code sections.
+			if order(id) <= order(last) {
+				return nil, invalid("section %d out of order", id)
+			}
+			last = id
+		}
+		s := &reader{b: body}
+		switch id {
+		case 0:
+			name, err := s.name()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := m.customs[name]; !dup {
+				m.customs[name] = s.b[s.pos:]
+			}
+			continue
+		case 1:
+			err = s.vec(func() error {
+				t, err := s.funcType()
+				m.types = append(m.types, t)
+				return err
+			})
+		case 2:
+			err = s.vec(func() error {
+				return invalid("imports are not supported; modules must be self-contained")
+			})
+		case 3:
+			err = s.vec(func() error {
+				t, err := s.u32()
+				if err == nil && int(t) >= len(m.types) {
+					err = invalid("function type %d out of range", t)
+				}
+				funcTypes = append(funcTypes, t)
+				return err
+			})
+		case 4:
+			err = s.vec(func() error {
+				if m.table != nil {
+					return invalid("multiple tables")
+				}
+				if t, err := s.byte(); err != nil || ValueType(t) != funcRef {
+					return invalid("tables must hold funcref")
+				}
+				l, err := s.limits()
+				m.table = &l
+				return err
+			})
+		case 5:
+			err = s.vec(func() error {
+				if m.memory != nil {
+					return invalid("multiple memories")
+				}
+				l, err := s.limits()
+				if err == nil && (l.min > 65536 || l.hasMax && l.max > 65536) {
+					err = invalid("memory larger than 4 GiB")
+				}
+				m.memory = &l
+				return err
+			})
+		case 6:
+			err = s.vec(func() error {
+				g, err := s.global()
+				m.globals = append(m.globals, g)
+				return err
+			})
+		case 7:
+			err = s.vec(func() error { return s.export(m) })
+		case 8:
+			var start uint32
+			if start, err = s.u32(); err == nil {
+				m.start = int(start)
+			}
+		case 9:
+			err = s.vec(func() error {
+				seg, err := s.elem()
+				m.elems = append(m.elems, seg)
+				return err
+			})
+		case 10:
+			if len(m.funcs) == 0 && len(funcTypes) > 0 {
+				m.funcs = make([]function, len(funcTypes))
+			}
+			i := 0
+			err = s.vec(func() error {
+				if i >= len(funcTypes) {
+					return invalid("more function bodies than functions")
+				}
+				f, err := s.body(funcTypes[i])
+				m.funcs[i] = f
+				i++
+				return err
+			})
+			if err == nil && i != len(funcTypes) {
+				err = invalid("%d functions but %d bodies", len(funcTypes), i)
+			}
+		case 11:
+			err = s.vec(func() error {
+				seg, err := s.dataSegment()
+				m.data = append(m.data, seg)
+				return err
+			})
+		case 12:
+			_, err = s.u32()
+		default:
+			err = invalid("unknown section %d", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.len() != 0 {
+			return nil, invalid("section %d has trailing bytes", id)
+		}
+	}
+	if len(funcTypes) > 0 && len(m.funcs) == 0 {
+		return nil, invalid("missing code section")
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// order ranks section ids by their required position.
+func order(id byte) int {
+	switch {
+	case id == 12:
+		return 10 // after element (9), before code (10)
+	case id >= 10:
+		return int(id) + 1
+	}
+	return int(id)
+}
+
+// validate checks the cross references between sections and compiles each
+// function body.
+func (m *Module) validate() error {
+	for _, e := range m.exports {
+		var n int
+		switch e.kind {
+		case exportFunc:
+			n = len(m.funcs)
+		case exportTable:
+			n = count(m.table != nil)
+		case exportMemory:
+			n = count(m.memory != nil)
+		case exportGlobal:
+			n = len(m.globals)
+		}
+		if int(e.index) >= n {
+			return invalid("export index %d out of range", e.index)
+		}
+	}
+	if m.start >= 0 {
+		if m.start >= len(m.funcs) {
+			return invalid("start function %d out of range", m.start)
+		}
+		if t := m.types[m.funcs[m.start].typ]; len(t.Params) != 0 || len(t.Results) != 0 {
+			return invalid("start function must take and return nothing")
+		}
+	}
+	for _, seg := range m.elems {
+		if m.table == nil {
+			return invalid("element segment without a table")
+		}
+		for _, f := range seg.funcs {
+			if int(f) >= len(m.funcs) {
+				return invalid("element function %d out of range", f)
+			}
+		}
+	}
+	if len(m.data) > 0 && m.memory == nil {
+		return invalid("data segment without a memory")
+	}
+	for i := range m.funcs {
+		if err := m.compile(&m.funcs[i]); err != nil {
+			return fmt.Errorf("function %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func count(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ExportedFunc returns the signature of the exported function name.
+func (m *Module) ExportedFunc(name string) (FuncType, bool) {
+	e, ok := m.exports[name]
+	if !ok || e.kind != exportFunc {
+		return FuncType{}, false
+	}
+	return m.types[m.funcs[e.index].typ], true
+}
+
+// ExportedFuncs returns the names of the exported functions, sorted.
+func (m *Module) ExportedFuncs() []string {
+	var names []string
+	for name, e := range m.exports {
+		if e.kind == exportFunc {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CustomSection returns the contents of the first custom section called name.
+func (m *Module) CustomSection(name string) ([]byte, bool) {
+	b, ok := m.customs[name]
+	return b, ok
+}
+
+// reader decodes the binary format.
+type reader struct {
+	b   []byte
+	pos int
+}
+
+var errEOF = invalid("unexpected end")
+
+func (r *reader) len() int { return len(r.b) - r.pos }
+
+func (r *reader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, errEOF
+	}
+	r.pos++
+	return r.b[r.pos-1], nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > r.len() {
+		return nil, errEOF
+	}
+	r.pos += n
+	return r.b[r.pos-n : r.pos], nil
+}
+
+// uleb decodes an unsigned LEB128 number of at most bits bits.
+func (r *reader) uleb(bits uint) (uint64, error) {
+	var (
+		v     uint64
+		shift uint
+	)
+	for {
+		c, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			if shift > bits && c>>(bits+7-shift) != 0 {
+				return 0, invalid("integer too large")
+			}
+			return v, nil
+		}
+		if shift >= bits {
+			return 0, invalid("integer representation too long")
+		}
+	}
+}
+
+// sleb decodes a signed LEB128 number of at most bits bits.
+func (r *reader) sleb(bits uint) (int64, error) {
+	var (
+		v     int64
+		shift uint
+	)
+	for {
+		c, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		v |= int64(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			if shift < 64 && c&0x40 != 0 {
+				v |= -1 << shift
+			}
+			if shift > bits {
+				// The unused bits must all equal the sign bit.
+				rest := int8(c<<1) >> (bits + 7 - shift)
+				if rest != 0 && rest != -1 {
+					return 0, invalid("integer too large")
+				}
+			}
+			return v, nil
+		}
+		if shift >= bits {
+			return 0, invalid("integer representation too long")
+		}
+	}
+}
+
+func (r *reader) u32() (uint32, error) {
+	v, err := r.uleb(32)
+	return uint32(v), err
+}
+
+func (r *reader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	return string(b), err
+}
+
+// vec decodes a vector, calling item for each element. The count is checked
+// against the remaining bytes so it cannot trigger a large allocation.
+func (r *reader) vec(item func() error) error {
+	n, err := r.u32()
+	if err != nil {
+		return err
+	}
+	if int(n) > r.len() {
+		return errEOF
+	}
+	for range n {
+		if err := item(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reader) valueType() (ValueType, error) {
+	b, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch t := ValueType(b); t {
+	case I32, I64, F32, F64:
+		return t, nil
+	}
+	return 0, invalid("unsupported value type 0x%02x", b)
+}
+
+func (r *reader) valueTypes() ([]ValueType, error) {
+	var ts []ValueType
+	err := r.vec(func() error {
+		t, err := r.valueType()
+		ts = append(ts, t)
+		return err
+	})
+	return ts, err
+}
+
+func (r *reader) funcType() (FuncType, error) {
+	if b, err := r.byte(); err != nil || b != 0x60 {
+		return FuncType{}, invalid("malformed function type")
+	}
+	params, err := r.valueTypes()
+	if err != nil {
+		return FuncType{}, err
+	}
+	results, err := r.valueTypes()
+	return FuncType{Params: params, Results: results}, err
+}
+
+func (r *reader) limits() (limits, error) {
+	flag, err := r.byte()
+	if err != nil {
+		return limits{}, err
+	}
+	if flag > 1 {
+		return limits{}, invalid("unsupported limits flag %d", flag)
+	}
+	var l limits
+	if l.min, err = r.u32(); err != nil {
+		return l, err
+	}
+	if flag == 1 {
+		l.hasMax = true
+		if l.max, err = r.u32(); err == nil && l.max < l.min {
+			err = invalid("maximum below minimum")
+		}
+	}
+	return l, err
+}
+
+// constExpr decodes an initializer of the form t.const c; end.
+func (r *reader) constExpr(want ValueType) (uint64, error) {
+	op, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	switch {
+	case op == 0x41 && want == I32:
+		var c int64
+		c, err = r.sleb(32)
+		v = uint64(uint32(c))
+	case op == 0x42 && want == I64:
+		var c int64
+		c, err = r.sleb(64)
+		v = uint64(c)
+	case op == 0x43 && want == F32:
+		var b []byte
+		b, err = r.bytes(4)
+		if err == nil {
+			v = uint64(binary.LittleEndian.Uint32(b))
+		}
+	case op == 0x44 && want == F64:
+		var b []byte
+		b, err = r.bytes(8)
+		if err == nil {
+			v = binary.LittleEndian.Uint64(b)
+		}
+	default:
+		return 0, invalid("unsupported constant expression")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if end, err := r.byte(); err != nil || end != 0x0b {
+		return 0, invalid("constant expression not terminated")
+	}
+	return v, nil
+}
+
+func (r *reader) global() (global, error) {
+	t, err := r.valueType()
+	if err != nil {
+		return global{}, err
+	}
+	mut, err := r.byte()
+	if err != nil || mut > 1 {
+		return global{}, invalid("malformed global mutability")
+	}
+	init, err := r.constExpr(t)
+	return global{typ: t, mutable: mut == 1, init: init}, err
+}
+
+func (r *reader) export(m *Module) error {
+	name, err := r.name()
+	if err != nil {
+		return err
+	}
+	kind, err := r.byte()
+	if err != nil {
+		return err
+	}
+	if kind > exportGlobal {
+		return invalid("unknown export kind %d", kind)
+	}
+	index, err := r.u32()
+	if err != nil {
+		return err
+	}
+	if _, dup := m.exports[name]; dup {
+		return invalid("duplicate export %q", name)
+	}
+	m.exports[name] = export{kind: kind, index: index}
+	return nil
+}
+
+func (r *reader) elem() (segment, error) {
+	flags, err := r.u32()
+	if err != nil {
+		return segment{}, err
+	}
+	if flags != 0 {
+		return segment{}, invalid("only active element segments for table 0 are supported")
+	}
+	offset, err := r.constExpr(I32)
+	if err != nil {
+		return segment{}, err
+	}
+	seg := segment{offset: uint32(offset)}
+	err = r.vec(func() error {
+		f, err := r.u32()
+		seg.funcs = append(seg.funcs, f)
+		return err
+	})
+	return seg, err
+}
+
+func (r *reader) dataSegment() (segment, error) {
+	flags, err := r.u32()
+	if err != nil {
+		return segment{}, err
+	}
+	if flags != 0 {
+		return segment{}, invalid("only active data segments for memory 0 are supported")
+	}
+	offset, err := r.constExpr(I32)
+	if err != nil {
+		return segment{}, err
+	}
+	n, err := r.u32()
+	if err != nil {
+		return segment{}, err
+	}
+	data, err := r.bytes(int(n))
+	return segment{offset: uint32(offset), data: data}, err
+}
+
+// body decodes the locals of a function; its instructions are compiled once
+// all sections are known.
+func (r *reader) body(typ uint32) (function, error) {
+	size, err := r.u32()
+	if err != nil {
+		return function{}, err
+	}
+	b, err := r.bytes(int(size))
+	if err != nil {
+		return function{}, err
+	}
+	br := &reader{b: b}
+	f := function{typ: typ}
+	err = br.vec(func() error {
+		n, err := br.u32()
+		if err != nil {
+			return err
+		}
+		t, err := br.valueType()
+		if err != nil {
+			return err
+		}
+		if len(f.locals)+int(n) > maxLocals {
+			return invalid("too many locals")
+		}
+		for range n {
+			f.locals = append(f.locals, t)
+		}
+		return nil
+	})
+	// Stash the undecoded instructions; compile replaces them.
+	f.code = []instr{{raw: br.b[br.pos:]}}
+	return f, err
+}
+
+func f32bits(f float32) uint64 { return uint64(math.Float32bits(f)) }
+func f32of(v uint64) float32   { return math.Float32frombits(uint32(v)) }
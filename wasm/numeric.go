@@ -0,0 +1,420 @@
+package wasm
+
+import (
+	"math"
+	"math/bits"
+)
+
+func b2i(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// numeric executes a numeric instruction (opcodes 0x45 to 0xc4 and the
+// saturating truncations) on top of st.
+func numeric(op uint16, st []uint64) ([]uint64, error) {
+	n := len(st)
+	switch {
+	case op == 0x45: // i32.eqz
+		st[n-1] = b2i(uint32(st[n-1]) == 0)
+		return st, nil
+	case op == 0x50: // i64.eqz
+		st[n-1] = b2i(st[n-1] == 0)
+		return st, nil
+	case op >= 0x46 && op <= 0x66:
+		st[n-2] = b2i(compare(op, st[n-2], st[n-1]))
+		return st[:n-1], nil
+	case op >= 0x67 && op <= 0x69, op >= 0x79 && op <= 0x7b, op >= 0x8b && op <= 0x91, op >= 0x99 && op <= 0x9f, op >= 0xa7:
+		v, err := unary(op, st[n-1])
+		st[n-1] = v
+		return st, err
+	default:
+		v, err := binaryOp(op, st[n-2], st[n-1])
+		st[n-2] = v
+		return st[:n-1], err
+	}
+}
+
+func compare(op uint16, a, b uint64) bool {
+	a32, b32 := uint32(a), uint32(b)
+	switch op {
+	case 0x46:
+		return a32 == b32
+	case 0x47:
+		return a32 != b32
+	case 0x48:
+		return int32(a32) < int32(b32)
+	case 0x49:
+		return a32 < b32
+	case 0x4a:
+		return int32(a32) > int32(b32)
+	case 0x4b:
+		return a32 > b32
+	case 0x4c:
+		return int32(a32) <= int32(b32)
+	case 0x4d:
+		return a32 <= b32
+	case 0x4e:
+		return int32(a32) >= int32(b32)
+	case 0x4f:
+		return a32 >= b32
+
+	case 0x51:
+		return a == b
+	case 0x52:
+		return a != b
+	case 0x53:
+		return int64(a) < int64(b)
+	case 0x54:
+		return a < b
+	case 0x55:
+		return int64(a) > int64(b)
+	case 0x56:
+		return a > b
+	case 0x57:
+		return int64(a) <= int64(b)
+	case 0x58:
+		return a <= b
+	case 0x59:
+		return int64(a) >= int64(b)
+	case 0x5a:
+		return a >= b
+	}
+
+	var x, y float64
+	if op <= 0x60 {
+		x, y = float64(f32of(a)), float64(f32of(b))
+		op += 0x61 - 0x5b
+	} else {
+		x, y = math.Float64frombits(a), math.Float64frombits(b)
+	}
+	switch op {
+	case 0x61:
+		return x == y
+	case 0x62:
+		return x != y
+	case 0x63:
+		return x < y
+	case 0x64:
+		return x > y
+	case 0x65:
+		return x <= y
+	}
+	return x >= y
+}
+
+func unary(op uint16, a uint64) (uint64, error) {
+	a32 := uint32(a)
+	f32, f64 := f32of(a), math.Float64frombits(a)
+	switch op {
+	case 0x67:
+		return uint64(bits.LeadingZeros32(a32)), nil
+	case 0x68:
+		return uint64(bits.TrailingZeros32(a32)), nil
+	case 0x69:
+		return uint64(bits.OnesCount32(a32)), nil
+	case 0x79:
+		return uint64(bits.LeadingZeros64(a)), nil
+	case 0x7a:
+		return uint64(bits.TrailingZeros64(a)), nil
+	case 0x7b:
+		return uint64(bits.OnesCount64(a)), nil
+
+	// Sign manipulation works on the bits so NaN payloads survive.
+	case 0x8b: // f32.abs
+		return a &^ (1 << 31), nil
+	case 0x8c: // f32.neg
+		return uint64(a32 ^ 1<<31), nil
+	case 0x8d:
+		return f32bits(float32(math.Ceil(float64(f32)))), nil
+	case 0x8e:
+		return f32bits(float32(math.Floor(float64(f32)))), nil
+	case 0x8f:
+		return f32bits(float32(math.Trunc(float64(f32)))), nil
+	case 0x90:
+		return f32bits(float32(math.RoundToEven(float64(f32)))), nil
+	case 0x91:
+		// The float64 root rounds correctly to float32.
+		return f32bits(float32(math.Sqrt(float64(f32)))), nil
+	case 0x99: // f64.abs
+		return a &^ (1 << 63), nil
+	case 0x9a: // f64.neg
+		return a ^ 1<<63, nil
+	case 0x9b:
+		return math.Float64bits(math.Ceil(f64)), nil
+	case 0x9c:
+		return math.Float64bits(math.Floor(f64)), nil
+	case 0x9d:
+		return math.Float64bits(math.Trunc(f64)), nil
+	case 0x9e:
+		return math.Float64bits(math.RoundToEven(f64)), nil
+	case 0x9f:
+		return math.Float64bits(math.Sqrt(f64)), nil
+
+	case 0xa7: // i32.wrap_i64
+		return uint64(a32), nil
+	case 0xa8:
+		return truncate(float64(f32), true, 32)
+	case 0xa9:
+		return truncate(float64(f32), false, 32)
+	case 0xaa:
+		return truncate(f64, true, 32)
+	case 0xab:
+		return truncate(f64, false, 32)
+	case 0xac: // i64.extend_i32_s
+		return uint64(int32(a32)), nil
+	case 0xad: // i64.extend_i32_u
+		return uint64(a32), nil
+	case 0xae:
+		return truncate(float64(f32), true, 64)
+	case 0xaf:
+		return truncate(float64(f32), false, 64)
+	case 0xb0:
+		return truncate(f64, true, 64)
+	case 0xb1:
+		return truncate(f64, false, 64)
+	case 0xb2:
+		return f32bits(float32(int32(a32))), nil
+	case 0xb3:
+		return f32bits(float32(a32)), nil
+	case 0xb4:
+		return f32bits(float32(int64(a))), nil
+	case 0xb5:
+		return f32bits(float32(a)), nil
+	case 0xb6: // f32.demote_f64
+		return f32bits(float32(f64)), nil
+	case 0xb7:
+		return math.Float64bits(float64(int32(a32))), nil
+	case 0xb8:
+		return math.Float64bits(float64(a32)), nil
+	case 0xb9:
+		return math.Float64bits(float64(int64(a))), nil
+	case 0xba:
+		return math.Float64bits(float64(a)), nil
+	case 0xbb: // f64.promote_f32
+		return math.Float64bits(float64(f32)), nil
+	case 0xbc, 0xbe: // i32.reinterpret_f32, f32.reinterpret_i32
+		return uint64(a32), nil
+	case 0xbd, 0xbf: // i64.reinterpret_f64, f64.reinterpret_i64
+		return a, nil
+	case 0xc0: // i32.extend8_s
+		return uint64(uint32(int8(a))), nil
+	case 0xc1: // i32.extend16_s
+		return uint64(uint32(int16(a))), nil
+	case 0xc2: // i64.extend8_s
+		return uint64(int8(a)), nil
+	case 0xc3: // i64.extend16_s
+		return uint64(int16(a)), nil
+	case 0xc4: // i64.extend32_s
+		return uint64(int32(a)), nil
+
+	case 0xfc00, 0xfc01, 0xfc04, 0xfc05: // trunc_sat from f32
+		return saturate(float64(f32), op&1 == 0, 32<<(op&4>>2)), nil
+	case 0xfc02, 0xfc03, 0xfc06, 0xfc07: // trunc_sat from f64
+		return saturate(f64, op&1 == 0, 32<<(op&4>>2)), nil
+	}
+	return 0, trap("unsupported instruction")
+}
+
+// truncRange returns the bounds a truncated float must lie in, lo inclusive
+// and hi exclusive, to convert to an integer of the given signedness and size.
+func truncRange(signed bool, size int) (lo, hi float64) {
+	switch {
+	case signed && size == 32:
+		return -(1 << 31), 1 << 31
+	case signed:
+		return -(1 << 63), 1 << 63
+	case size == 32:
+		return 0, 1 << 32
+	}
+	return 0, 1 << 64
+}
+
+// toInt converts t, an integer-valued float within truncRange, to an
+// integer's bits.
+func toInt(t float64, signed bool, size int) uint64 {
+	switch {
+	case signed && size == 32:
+		return uint64(uint32(int32(t)))
+	case signed:
+		return uint64(int64(t))
+	case t >= 1<<63:
+		return uint64(t-(1<<63)) | 1<<63
+	}
+	return uint64(t)
+}
+
+func truncate(f float64, signed bool, size int) (uint64, error) {
+	if math.IsNaN(f) {
+		return 0, errBadConvert
+	}
+	t := math.Trunc(f)
+	if lo, hi := truncRange(signed, size); t < lo || t >= hi {
+		return 0, errIntOverflow
+	}
+	return toInt(t, signed, size), nil
+}
+
+func saturate(f float64, signed bool, size int) uint64 {
+	if math.IsNaN(f) {
+		return 0
+	}
+	t := math.Trunc(f)
+	lo, hi := truncRange(signed, size)
+	switch {
+	case t < lo:
+		return toInt(lo, signed, size)
+	case t >= hi:
+		return saturatedMax(signed, size)
+	}
+	return toInt(t, signed, size)
+}
+
+// saturatedMax is the bits of the largest integer of the given signedness
+// and size.
+func saturatedMax(signed bool, size int) uint64 {
+	switch {
+	case signed && size == 32:
+		return math.MaxInt32
+	case signed:
+		return math.MaxInt64
+	case size == 32:
+		return math.MaxUint32
+	}
+	return math.MaxUint64
+}
+
+func binaryOp(op uint16, a, b uint64) (uint64, error) {
+	a32, b32 := uint32(a), uint32(b)
+	switch op {
+	case 0x6a:
+		return uint64(a32 + b32), nil
+	case 0x6b:
+		return uint64(a32 - b32), nil
+	case 0x6c:
+		return uint64(a32 * b32), nil
+	case 0x6d: // i32.div_s
+		if b32 == 0 {
+			return 0, errDivideByZero
+		}
+		if int32(a32) == math.MinInt32 && int32(b32) == -1 {
+			return 0, errIntOverflow
+		}
+		return uint64(uint32(int32(a32) / int32(b32))), nil
+	case 0x6e:
+		if b32 == 0 {
+			return 0, errDivideByZero
+		}
+		return uint64(a32 / b32), nil
+	case 0x6f: // i32.rem_s; Go yields 0 for MinInt32 % -1, as required
+		if b32 == 0 {
+			return 0, errDivideByZero
+		}
+		return uint64(uint32(int32(a32) % int32(b32))), nil
+	case 0x70:
+		if b32 == 0 {
+			return 0, errDivideByZero
+		}
+		return uint64(a32 % b32), nil
+	case 0x71:
+		return uint64(a32 & b32), nil
+	case 0x72:
+		return uint64(a32 | b32), nil
+	case 0x73:
+		return uint64(a32 ^ b32), nil
+	case 0x74:
+		return uint64(a32 << (b32 & 31)), nil
+	case 0x75:
+		return uint64(uint32(int32(a32) >> (b32 & 31))), nil
+	case 0x76:
+		return uint64(a32 >> (b32 & 31)), nil
+	case 0x77:
+		return uint64(bits.RotateLeft32(a32, int(b32&31))), nil
+	case 0x78:
+		return uint64(bits.RotateLeft32(a32, -int(b32&31))), nil
+
+	case 0x7c:
+		return a + b, nil
+	case 0x7d:
+		return a - b, nil
+	case 0x7e:
+		return a * b, nil
+	case 0x7f: // i64.div_s
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			return 0, errIntOverflow
+		}
+		return uint64(int64(a) / int64(b)), nil
+	case 0x80:
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		return a / b, nil
+	case 0x81:
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		return uint64(int64(a) % int64(b)), nil
+	case 0x82:
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		return a % b, nil
+	case 0x83:
+		return a & b, nil
+	case 0x84:
+		return a | b, nil
+	case 0x85:
+		return a ^ b, nil
+	case 0x86:
+		return a << (b & 63), nil
+	case 0x87:
+		return uint64(int64(a) >> (b & 63)), nil
+	case 0x88:
+		return a >> (b & 63), nil
+	case 0x89:
+		return bits.RotateLeft64(a, int(b&63)), nil
+	case 0x8a:
+		return bits.RotateLeft64(a, -int(b&63)), nil
+
+	case 0x92, 0x93, 0x94, 0x95, 0x96, 0x97:
+		x, y := f32of(a), f32of(b)
+		var r float32
+		switch op {
+		case 0x92:
+			r = x + y
+		case 0x93:
+			r = x - y
+		case 0x94:
+			r = x * y
+		case 0x95:
+			r = x / y
+		case 0x96:
+			r = float32(math.Min(float64(x), float64(y)))
+		case 0x97:
+			r = float32(math.Max(float64(x), float64(y)))
+		}
+		return f32bits(r), nil
+	case 0x98: // f32.copysign
+		return uint64(a32&^(1<<31) | b32&(1<<31)), nil
+	case 0xa0:
+		return math.Float64bits(math.Float64frombits(a) + math.Float64frombits(b)), nil
+	case 0xa1:
+		return math.Float64bits(math.Float64frombits(a) - math.Float64frombits(b)), nil
+	case 0xa2:
+		return math.Float64bits(math.Float64frombits(a) * math.Float64frombits(b)), nil
+	case 0xa3:
+		return math.Float64bits(math.Float64frombits(a) / math.Float64frombits(b)), nil
+	case 0xa4:
+		return math.Float64bits(math.Min(math.Float64frombits(a), math.Float64frombits(b))), nil
+	case 0xa5:
+		return math.Float64bits(math.Max(math.Float64frombits(a), math.Float64frombits(b))), nil
+	case 0xa6: // f64.copysign
+		return a&^(1<<63) | b&(1<<63), nil
+	}
+	return 0, trap("unsupported instruction")
+}
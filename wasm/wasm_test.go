@@ -0,0 +1,384 @@
+package wasm
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// A minimal assembler for test modules.
+
+func uleb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func sleb(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func cat(parts ...[]byte) []byte { return slices.Concat(parts...) }
+
+func vec(items ...[]byte) []byte { return cat(uleb(uint64(len(items))), cat(items...)) }
+
+func section(id byte, content ...[]byte) []byte {
+	c := cat(content...)
+	return cat([]byte{id}, uleb(uint64(len(c))), c)
+}
+
+func name(s string) []byte { return cat(uleb(uint64(len(s))), []byte(s)) }
+
+func funcType(params, results []ValueType) []byte {
+	return cat([]byte{0x60}, vec(valueItems(params)...), vec(valueItems(results)...))
+}
+
+func valueItems(ts []ValueType) [][]byte {
+	items := make([][]byte, len(ts))
+	for i, t := range ts {
+		items[i] = []byte{byte(t)}
+	}
+	return items
+}
+
+// body encodes a function body with one group of locals of type t.
+func body(locals int, t ValueType, code ...byte) []byte {
+	l := vec()
+	if locals > 0 {
+		l = vec(cat(uleb(uint64(locals)), []byte{byte(t)}))
+	}
+	b := cat(l, code, []byte{opEnd})
+	return cat(uleb(uint64(len(b))), b)
+}
+
+type testFunc struct {
+	params, results []ValueType
+	body            []byte
+	export          string
+}
+
+// build assembles a module of funcs, each with its own type, plus any extra
+// sections, which must be given in order and fit between exports and code.
+func build(funcs []testFunc, memory []byte, extra ...[]byte) []byte {
+	var types, indices, exports, bodies [][]byte
+	for i, f := range funcs {
+		types = append(types, funcType(f.params, f.results))
+		indices = append(indices, uleb(uint64(i)))
+		if f.export != "" {
+			exports = append(exports, cat(name(f.export), []byte{exportFunc}, uleb(uint64(i))))
+		}
+		bodies = append(bodies, f.body)
+	}
+	b := cat([]byte("\x00asm\x01\x00\x00\x00"), section(1, vec(types...)), section(3, vec(indices...)))
+	if memory != nil {
+		b = cat(b, section(5, vec(memory)))
+	}
+	b = cat(b, section(7, vec(exports...)))
+	for _, s := range extra {
+		b = cat(b, s)
+	}
+	return cat(b, section(10, vec(bodies...)))
+}
+
+func single(params, results []ValueType, body []byte) []byte {
+	return build([]testFunc{{params, results, body, "f"}}, nil)
+}
+
+func run(t *testing.T, b []byte, l Limits, args ...uint64) ([]uint64, error) {
+	t.Helper()
+	m, err := Compile(b)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	in, err := m.Instantiate(l)
+	if err != nil {
+		return nil, err
+	}
+	return in.Call("f", args...)
+}
+
+func f64(x float64) uint64 { return math.Float64bits(x) }
+
+var (
+	i32x1 = []ValueType{I32}
+	i32x2 = []ValueType{I32, I32}
+	i64x1 = []ValueType{I64}
+	i64x2 = []ValueType{I64, I64}
+	f32x1 = []ValueType{F32}
+	f64x1 = []ValueType{F64}
+	f64x2 = []ValueType{F64, F64}
+)
+
+func TestPrograms(t *testing.T) {
+	hypot := single(f64x2, f64x1, body(0, 0,
+		opLocalGet, 0, opLocalGet, 0, 0xa2, // f64.mul
+		opLocalGet, 1, opLocalGet, 1, 0xa2,
+		0xa0, 0x9f)) // f64.add, f64.sqrt
+	if got, err := run(t, hypot, Limits{}, f64(3), f64(4)); err != nil || got[0] != f64(5) {
+		t.Errorf("hypot = %v, %v", got, err)
+	}
+
+	factorial := single(i64x1, i64x1, body(1, I64,
+		opI64Const, 1, opLocalSet, 1,
+		opBlock, 0x40, opLoop, 0x40,
+		opLocalGet, 0, 0x50, opBrIf, 1, // i64.eqz
+		opLocalGet, 1, opLocalGet, 0, 0x7e, opLocalSet, 1, // i64.mul
+		opLocalGet, 0, opI64Const, 1, 0x7d, opLocalSet, 0, // i64.sub
+		opBr, 0,
+		opEnd, opEnd,
+		opLocalGet, 1))
+	if got, err := run(t, factorial, Limits{}, 20); err != nil || got[0] != 2432902008176640000 {
+		t.Errorf("factorial = %v, %v", got, err)
+	}
+
+	fib := single(i32x1, i32x1, body(0, 0,
+		opLocalGet, 0, opI32Const, 2, 0x49, // i32.lt_u
+		opIf, byte(I32),
+		opLocalGet, 0,
+		opElse,
+		opLocalGet, 0, opI32Const, 1, 0x6b, opCall, 0, // i32.sub
+		opLocalGet, 0, opI32Const, 2, 0x6b, opCall, 0,
+		0x6a, // i32.add
+		opEnd))
+	if got, err := run(t, fib, Limits{}, 20); err != nil || got[0] != 6765 {
+		t.Errorf("fib = %v, %v", got, err)
+	}
+
+	// br_table picks the block to leave; each exit returns a different value.
+	choose := single(i32x1, i32x1, body(0, 0,
+		opBlock, 0x40, opBlock, 0x40, opBlock, 0x40,
+		opLocalGet, 0, opBrTable, 2, 0, 1, 2,
+		opEnd, opI32Const, 10, opReturn,
+		opEnd, opI32Const, 20, opReturn,
+		opEnd, opI32Const, 30))
+	for arg, want := range map[uint64]uint64{0: 10, 1: 20, 2: 30, 7: 30} {
+		if got, err := run(t, choose, Limits{}, arg); err != nil || got[0] != want {
+			t.Errorf("br_table(%d) = %v, %v; want %d", arg, got, err, want)
+		}
+	}
+}
+
+func TestNumeric(t *testing.T) {
+	minI32 := uint64(1 << 31)
+	cases := []struct {
+		name    string
+		params  []ValueType
+		result  ValueType
+		op      []byte
+		args    []uint64
+		want    uint64
+		wantErr error
+	}{
+		{"i32.add wraps", i32x2, I32, []byte{0x6a}, []uint64{math.MaxUint32, 2}, 1, nil},
+		{"i32.div_s", i32x2, I32, []byte{0x6d}, []uint64{uint64(uint32(0xfffffff9)), 2}, uint64(uint32(0xfffffffd)), nil},
+		{"i32.div_s by zero", i32x2, I32, []byte{0x6d}, []uint64{1, 0}, 0, errDivideByZero},
+		{"i32.div_s overflow", i32x2, I32, []byte{0x6d}, []uint64{minI32, math.MaxUint32}, 0, errIntOverflow},
+		{"i32.rem_s min by -1", i32x2, I32, []byte{0x6f}, []uint64{minI32, math.MaxUint32}, 0, nil},
+		{"i32.shl masks count", i32x2, I32, []byte{0x74}, []uint64{1, 33}, 2, nil},
+		{"i32.rotr", i32x2, I32, []byte{0x78}, []uint64{1, 1}, minI32, nil},
+		{"i32.clz", i32x1, I32, []byte{0x67}, []uint64{1}, 31, nil},
+		{"i32.extend8_s", i32x1, I32, []byte{0xc0}, []uint64{0x80}, 0xffffff80, nil},
+		{"i64.shr_s", i64x2, I64, []byte{0x87}, []uint64{1 << 63, 63}, math.MaxUint64, nil},
+		{"i64.rem_u by zero", i64x2, I64, []byte{0x82}, []uint64{1, 0}, 0, errDivideByZero},
+		{"i64.extend_i32_s", i32x1, I64, []byte{0xac}, []uint64{math.MaxUint32}, math.MaxUint64, nil},
+		{"i32.trunc_f64_s", f64x1, I32, []byte{0xaa}, []uint64{f64(-2.9)}, uint64(uint32(0xfffffffe)), nil},
+		{"i32.trunc_f64_s overflow", f64x1, I32, []byte{0xaa}, []uint64{f64(1 << 31)}, 0, errIntOverflow},
+		{"i32.trunc_f64_u of -0.5", f64x1, I32, []byte{0xab}, []uint64{f64(-0.5)}, 0, nil},
+		{"i64.trunc_f64_u large", f64x1, I64, []byte{0xb1}, []uint64{f64(1 << 63)}, 1 << 63, nil},
+		{"i64.trunc_f64_s NaN", f64x1, I64, []byte{0xb0}, []uint64{f64(math.NaN())}, 0, errBadConvert},
+		{"i32.trunc_sat_f64_s high", f64x1, I32, []byte{0xfc, 2}, []uint64{f64(1e10)}, math.MaxInt32, nil},
+		{"i32.trunc_sat_f64_s low", f64x1, I32, []byte{0xfc, 2}, []uint64{f64(-1e10)}, minI32, nil},
+		{"i64.trunc_sat_f64_u NaN", f64x1, I64, []byte{0xfc, 7}, []uint64{f64(math.NaN())}, 0, nil},
+		{"f64.convert_i64_u", i64x1, F64, []byte{0xba}, []uint64{math.MaxUint64}, f64(1 << 64), nil},
+		{"f64.min signed zeros", f64x2, F64, []byte{0xa4}, []uint64{f64(0), f64(math.Copysign(0, -1))}, f64(math.Copysign(0, -1)), nil},
+		{"f64.max NaN", f64x2, F64, []byte{0xa5}, []uint64{f64(1), f64(math.NaN())}, f64(math.NaN()), nil},
+		{"f64.nearest ties to even", f64x1, F64, []byte{0x9e}, []uint64{f64(2.5)}, f64(2), nil},
+		{"f64.copysign", f64x2, F64, []byte{0xa6}, []uint64{f64(3), f64(-1)}, f64(-3), nil},
+		{"f64.lt", f64x2, I32, []byte{0x63}, []uint64{f64(1), f64(2)}, 1, nil},
+		{"f32.demote rounds", f64x1, F32, []byte{0xb6}, []uint64{f64(0.1)}, f32bits(0.1), nil},
+		{"f32.neg", f32x1, F32, []byte{0x8c}, []uint64{f32bits(1.5)}, f32bits(-1.5), nil},
+		{"f32.sqrt", f32x1, F32, []byte{0x91}, []uint64{f32bits(2)}, f32bits(float32(math.Sqrt(2))), nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var code []byte
+			for i := range tc.params {
+				code = append(code, opLocalGet, byte(i))
+			}
+			b := single(tc.params, []ValueType{tc.result}, body(0, 0, append(code, tc.op...)...))
+			got, err := run(t, b, Limits{}, tc.args...)
+			switch {
+			case tc.wantErr != nil:
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("error = %v; want %v", err, tc.wantErr)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case tc.want == f64(math.NaN()):
+				if !math.IsNaN(math.Float64frombits(got[0])) {
+					t.Fatalf("got %#x; want NaN", got[0])
+				}
+			case got[0] != tc.want:
+				t.Fatalf("got %#x; want %#x", got[0], tc.want)
+			}
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	data := section(11, vec(cat(uleb(0), []byte{opI32Const}, sleb(8), []byte{opEnd}, name("hi"))))
+	funcs := []testFunc{
+		// load8_u(a)
+		{i32x1, i32x1, body(0, 0, opLocalGet, 0, 0x2d, 0, 0), "f"},
+		// memory.grow(a)
+		{i32x1, i32x1, body(0, 0, opLocalGet, 0, opMemoryGrow, 0), "grow"},
+		// store a at 0 with offset 16, then read it back as an i64
+		{i64x1, i64x1, body(0, 0, opI32Const, 0, opLocalGet, 0, 0x37, 3, 16, opI32Const, 16, 0x29, 3, 0), "roundtrip"},
+		// fill 4 bytes at a with 7 and return the i32 there
+		{i32x1, i32x1, body(0, 0, opLocalGet, 0, opI32Const, 7, opI32Const, 4, 0xfc, 11, 0, opLocalGet, 0, 0x28, 2, 0), "fill"},
+	}
+	m, err := Compile(cat(build(funcs, []byte{0, 1}), data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := m.Instantiate(Limits{MaxPages: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := in.Call("f", 9); err != nil || got[0] != 'i' {
+		t.Errorf("load = %v, %v", got, err)
+	}
+	if _, err := in.Call("f", PageSize); !errors.Is(err, errOutOfBounds) {
+		t.Errorf("out of bounds load error = %v", err)
+	}
+	if got, _ := in.Call("grow", 1); got[0] != 1 {
+		t.Errorf("grow to 2 pages = %d; want old size 1", got[0])
+	}
+	if got, _ := in.Call("grow", 1); got[0] != math.MaxUint32 {
+		t.Errorf("grow past the limit = %d; want -1", got[0])
+	}
+	if got, err := in.Call("f", PageSize); err != nil || got[0] != 0 {
+		t.Errorf("load from grown page = %v, %v", got, err)
+	}
+	if got, err := in.Call("roundtrip", 0x0102030405060708); err != nil || got[0] != 0x0102030405060708 {
+		t.Errorf("roundtrip = %#x, %v", got, err)
+	}
+	if got, err := in.Call("fill", 100); err != nil || got[0] != 0x07070707 {
+		t.Errorf("fill = %#x, %v", got, err)
+	}
+
+	if _, err := m.Instantiate(Limits{MaxPages: 1}); err != nil {
+		t.Errorf("instantiate at the limit: %v", err)
+	}
+	big, _ := Compile(build(funcs[:1], []byte{0, 3}))
+	if _, err := big.Instantiate(Limits{MaxPages: 2}); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("initial memory over the limit error = %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	spin := single(nil, nil, body(0, 0, opLoop, 0x40, opBr, 0, opEnd))
+	_, err := run(t, spin, Limits{Fuel: 1000})
+	if !errors.Is(err, ErrFuelExhausted) || !errors.Is(err, ErrTrap) {
+		t.Errorf("spin error = %v", err)
+	}
+
+	recurse := single(nil, nil, body(0, 0, opCall, 0))
+	if _, err := run(t, recurse, Limits{MaxCallDepth: 50}); !errors.Is(err, ErrCallDepth) {
+		t.Errorf("recursion error = %v", err)
+	}
+
+	trapping := single(nil, nil, body(0, 0, opUnreachable))
+	if _, err := run(t, trapping, Limits{}); !errors.Is(err, ErrTrap) {
+		t.Errorf("unreachable error = %v", err)
+	}
+
+	// Validation does not type-check the stack; an underflow still traps.
+	underflow := single(nil, i32x1, body(0, 0, 0x6a))
+	if _, err := run(t, underflow, Limits{}); !errors.Is(err, ErrTrap) {
+		t.Errorf("stack underflow error = %v", err)
+	}
+
+	// Each instance has its own budget.
+	m, _ := Compile(single(nil, nil, body(0, 0, opNop, opNop)))
+	in, _ := m.Instantiate(Limits{Fuel: 5})
+	if _, err := in.Call("f"); err != nil || in.Fuel() != 2 {
+		t.Errorf("first call: fuel %d, %v", in.Fuel(), err)
+	}
+	if _, err := in.Call("f"); !errors.Is(err, ErrFuelExhausted) {
+		t.Errorf("second call error = %v", err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	header := []byte("\x00asm\x01\x00\x00\x00")
+	cases := map[string][]byte{
+		"bad magic":      []byte("\x00wasm\x01\x00\x00"),
+		"truncated":      cat(header, []byte{1, 5, 1}),
+		"imports":        cat(header, section(1, vec(funcType(nil, nil))), section(2, vec(cat(name("env"), name("f"), []byte{0, 0})))),
+		"unknown opcode": single(nil, nil, body(0, 0, 0xfe)),
+		"branch depth":   single(nil, nil, body(0, 0, opBr, 1)),
+		"local index":    single(i32x1, nil, body(0, 0, opLocalGet, 1)),
+		"call index":     single(nil, nil, body(0, 0, opCall, 1)),
+		"memory absent":  single(nil, i32x1, body(0, 0, opI32Const, 0, 0x28, 2, 0)),
+		"missing end":    single(nil, nil, body(0, 0, opBlock, 0x40)),
+		"stray else":     single(nil, nil, body(0, 0, opElse)),
+		"order":          cat(header, section(3, vec()), section(1, vec())),
+		"long leb":       single(nil, i32x1, body(0, 0, opI32Const, 0x80, 0x80, 0x80, 0x80, 0x80, 0)),
+		"i32 too large":  single(nil, i32x1, body(0, 0, opI32Const, 0x80, 0x80, 0x80, 0x80, 0x10)),
+	}
+	for name, b := range cases {
+		if _, err := Compile(b); !errors.Is(err, ErrInvalidModule) {
+			t.Errorf("%s: error = %v", name, err)
+		}
+	}
+	// -1 as five bytes is a valid i32.
+	b := single(nil, i32x1, body(0, 0, opI32Const, 0xff, 0xff, 0xff, 0xff, 0x7f))
+	if got, err := run(t, b, Limits{}); err != nil || got[0] != math.MaxUint32 {
+		t.Errorf("i32.const -1 = %v, %v", got, err)
+	}
+}
+
+func TestExportsAndCustomSections(t *testing.T) {
+	funcs := []testFunc{
+		{f64x1, f64x1, body(0, 0, opLocalGet, 0), "id"},
+		{nil, nil, body(0, 0), ""},
+		{i32x2, nil, body(0, 0), "pair"},
+	}
+	meta := section(0, name("calculator"), []byte(`{"x":1}`))
+	m, err := Compile(cat(build(funcs, nil), meta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.ExportedFuncs(); !slices.Equal(got, []string{"id", "pair"}) {
+		t.Errorf("ExportedFuncs = %v", got)
+	}
+	if ft, ok := m.ExportedFunc("pair"); !ok || !slices.Equal(ft.Params, i32x2) || len(ft.Results) != 0 {
+		t.Errorf("ExportedFunc(pair) = %v, %v", ft, ok)
+	}
+	if c, ok := m.CustomSection("calculator"); !ok || string(c) != `{"x":1}` {
+		t.Errorf("CustomSection = %q, %v", c, ok)
+	}
+	in, _ := m.Instantiate(Limits{})
+	if _, err := in.Call("id"); err == nil {
+		t.Error("call with missing argument succeeded")
+	}
+	if _, err := in.Call("nope"); err == nil {
+		t.Error("call to missing export succeeded")
+	}
+}